@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 
 	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/audit"
 	"github.com/hardik/kubectl-rbac-why/pkg/cmd/cani"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/daemon"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/lint"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/snapshot"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/teams"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/usages"
 )
 
 func main() {
@@ -20,8 +29,28 @@ func main() {
 	}
 
 	cmd := cani.NewCmdRbacWhy(streams)
+	cmd.AddCommand(daemon.NewCmdDaemon(streams))
+	cmd.AddCommand(cani.NewCmdExplainError(streams))
+	cmd.AddCommand(cani.NewCmdDiff(streams))
+	cmd.AddCommand(cani.NewCmdNamespaces(streams))
+	cmd.AddCommand(cani.NewCmdWhoCan(streams))
+	cmd.AddCommand(audit.NewCmdAudit(streams))
+	cmd.AddCommand(lint.NewCmdLint(streams))
+	cmd.AddCommand(usages.NewCmdUsages(streams))
+	cmd.AddCommand(teams.NewCmdTeams(streams))
+	cmd.AddCommand(snapshot.NewCmdSnapshot(streams))
+	cmd.AddCommand(cani.NewCmdWhoAmI(streams))
+	cmd.AddCommand(cani.NewCmdList(streams))
+	cmd.AddCommand(cani.NewCmdSimulate(streams))
 
-	if err := cmd.Execute(); err != nil {
-		os.Exit(1)
+	// Ctrl-C cancels the command's context, which stops in-flight requests;
+	// a second Ctrl-C exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := cmd.ExecuteContext(ctx); err != nil {
+		os.Exit(exitcode.For(err))
 	}
 }
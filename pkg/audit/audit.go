@@ -0,0 +1,302 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// FindingSet holds findings indexed by Key
+type FindingSet map[string]notify.Finding
+
+// Key identifies a finding across scans
+func Key(f notify.Finding) string {
+	return f.Subject + "|" + f.Category + "|" + f.Binding + "|" + f.Role
+}
+
+// ScanOptions configures a Scan
+type ScanOptions struct {
+	SeverityOverrides output.SeverityOverrides
+	// SensitiveNamespaces defaults to DefaultSensitiveNamespaces when nil
+	SensitiveNamespaces []string
+	// KeepGoing skips namespaces and subjects that fail to evaluate instead
+	// of aborting the scan
+	KeepGoing bool
+	// Index, when set, is refreshed and used to find each subject's
+	// bindings, so repeated scans only re-index the bindings that changed.
+	// The bindings are then listed once for the scan, not per namespace.
+	Index *rbac.BindingIndex
+}
+
+// SkippedScope is a namespace or subject a KeepGoing scan could not evaluate
+type SkippedScope struct {
+	Scope string // e.g. "namespace prod"
+	Err   error
+}
+
+// Scan runs the risky permission analysis for every subject that appears in a
+// binding. An empty namespace scans RoleBindings in all namespaces. When c
+// implements client.TokenClient, findings for ServiceAccounts whose token is
+// exposed are raised one severity level. With opts.KeepGoing, scopes that
+// fail are returned as skipped and the findings are partial.
+func Scan(ctx context.Context, c client.RBACClient, namespace string, opts ScanOptions) (FindingSet, []SkippedScope, error) {
+	targets, err := boundSubjects(ctx, c, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var skipped []SkippedScope
+	skippedScopes := make(map[string]bool)
+	skip := func(scope string, err error) error {
+		if !opts.KeepGoing {
+			return err
+		}
+		if !skippedScopes[scope] {
+			skippedScopes[scope] = true
+			skipped = append(skipped, SkippedScope{Scope: scope, Err: err})
+		}
+		return nil
+	}
+
+	sensitive := opts.SensitiveNamespaces
+	if sensitive == nil {
+		sensitive = DefaultSensitiveNamespaces
+	}
+	tokens, _ := c.(client.TokenClient)
+
+	resolver := rbac.NewResolver(c, scanIndex(ctx, c, opts.Index)...)
+	findings := make(FindingSet)
+	for _, t := range targets {
+		var exposure *rbac.TokenExposure
+		if tokens != nil {
+			if exposure, err = CheckTokenExposure(ctx, tokens, t.subject, sensitive); err != nil {
+				if err := skip(t.subject.String()+" token exposure", err); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+
+		for _, ns := range t.namespaces {
+			grants, err := resolver.ResolveAllPermissions(ctx, t.subject, ns)
+			if err != nil {
+				scope := "cluster-wide bindings"
+				if ns != "" {
+					scope = "namespace " + ns
+				}
+				if err := skip(scope, err); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			risks := output.AnalyzeRiskyPermissionsWithOverrides(grants, opts.SeverityOverrides)
+			for _, f := range toFindings(t.subject, output.RaiseForExposure(risks, exposure)) {
+				findings[Key(f)] = f
+			}
+		}
+	}
+	return findings, skipped, nil
+}
+
+// scanIndex refreshes the scan's index, if it has one, for looking up the
+// bindings of every bound subject. When the bindings in every namespace
+// can't be listed, the scan goes without it.
+func scanIndex(ctx context.Context, c client.RBACClient, idx *rbac.BindingIndex) []rbac.ResolverOption {
+	if idx == nil {
+		return nil
+	}
+	if _, err := idx.Refresh(ctx, c); err != nil {
+		return nil
+	}
+	return []rbac.ResolverOption{rbac.WithBindingIndex(idx)}
+}
+
+// toFindings flattens risky permissions into one finding per grant
+func toFindings(subject rbac.Subject, risks []rbac.RiskyPermission) []notify.Finding {
+	var findings []notify.Finding
+	for _, risk := range risks {
+		for _, grant := range risk.Grants {
+			f := notify.Finding{
+				Subject:     subject.Canonical(),
+				SubjectKind: subject.Kind,
+				Category:    risk.Category,
+				Severity:    risk.Severity,
+				Description: risk.Description,
+				Binding:     grant.Binding.Kind + "/" + qualifiedName(grant.Binding.Namespace, grant.Binding.Name),
+				Role:        grant.Role.Kind + "/" + qualifiedName(grant.Role.Namespace, grant.Role.Name),
+			}
+			if owner := grant.Owner(); owner != nil {
+				f.Owner = owner.String()
+			}
+			if risk.Exposure != nil {
+				f.Exposure = risk.Exposure.String()
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// Diff returns the findings in curr that are not in prev, and those in prev
+// that are no longer in curr. Both are sorted by severity, then key.
+func Diff(prev, curr FindingSet) (added, resolved []notify.Finding) {
+	for k, f := range curr {
+		if _, ok := prev[k]; !ok {
+			added = append(added, f)
+		}
+	}
+	for k, f := range prev {
+		if _, ok := curr[k]; !ok {
+			resolved = append(resolved, f)
+		}
+	}
+	Sort(added)
+	Sort(resolved)
+	return added, resolved
+}
+
+// Sort orders findings by descending severity, then by key
+func Sort(findings []notify.Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		ri, rj := rbac.SeverityRank(findings[i].Severity), rbac.SeverityRank(findings[j].Severity)
+		if ri != rj {
+			return ri > rj
+		}
+		return Key(findings[i]) < Key(findings[j])
+	})
+}
+
+// target is a subject and the namespaces its permissions need resolving in
+type target struct {
+	subject    rbac.Subject
+	namespaces []string
+}
+
+// boundSubjects collects the distinct subjects referenced by bindings
+func boundSubjects(ctx context.Context, c client.RBACClient, namespace string) ([]target, error) {
+	crbs, err := c.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	rbs, err := c.ListRoleBindings(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+
+	index := make(map[string]int)
+	var targets []target
+	add := func(s rbacv1.Subject, bindingNamespace, scanNamespace string) {
+		subject := toSubject(s, bindingNamespace)
+		key := subject.String()
+		i, ok := index[key]
+		if !ok {
+			i = len(targets)
+			index[key] = i
+			targets = append(targets, target{subject: subject})
+		}
+		for _, ns := range targets[i].namespaces {
+			if ns == scanNamespace {
+				return
+			}
+		}
+		targets[i].namespaces = append(targets[i].namespaces, scanNamespace)
+	}
+
+	for _, crb := range crbs.Items {
+		for _, s := range crb.Subjects {
+			add(s, "", namespace)
+		}
+	}
+	for _, rb := range rbs.Items {
+		for _, s := range rb.Subjects {
+			add(s, rb.Namespace, rb.Namespace)
+		}
+	}
+	return targets, nil
+}
+
+// toSubject converts a binding subject; ServiceAccounts without a namespace
+// default to the binding's namespace
+func toSubject(s rbacv1.Subject, bindingNamespace string) rbac.Subject {
+	if s.Kind == "ServiceAccount" {
+		ns := s.Namespace
+		if ns == "" {
+			ns = bindingNamespace
+		}
+		return rbac.Subject{Kind: "ServiceAccount", Namespace: ns, Name: s.Name}
+	}
+	return rbac.Subject{Kind: s.Kind, Name: s.Name}
+}
+
+// qualifiedName returns namespace/name, or just name for cluster-scoped objects
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// Ways findings can be grouped
+const (
+	GroupBySeverity = "severity"
+	GroupByOwner    = "owner"
+	GroupBySubject  = "subject"
+)
+
+// Group rolls findings up by severity, owner, or subject. Findings without an
+// owner are grouped under "unmanaged". Severity groups are ordered from most
+// to least severe; other groups by name, with "unmanaged" last.
+func Group(set FindingSet, by string) ([]output.FindingGroup, error) {
+	findings := make([]notify.Finding, 0, len(set))
+	for _, f := range set {
+		findings = append(findings, f)
+	}
+	Sort(findings)
+
+	var key func(notify.Finding) string
+	switch by {
+	case GroupBySeverity:
+		key = func(f notify.Finding) string { return f.Severity }
+	case GroupByOwner:
+		key = func(f notify.Finding) string {
+			if f.Owner == "" {
+				return rbac.UnmanagedOwner
+			}
+			return f.Owner
+		}
+	case GroupBySubject:
+		key = func(f notify.Finding) string { return f.Subject }
+	default:
+		return nil, fmt.Errorf("invalid group-by %q (valid: %s, %s, %s)", by, GroupBySeverity, GroupByOwner, GroupBySubject)
+	}
+
+	index := make(map[string]int)
+	var groups []output.FindingGroup
+	for _, f := range findings {
+		k := key(f)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, output.FindingGroup{Name: k})
+		}
+		groups[i].Findings = append(groups[i].Findings, f)
+	}
+
+	// Findings are already in severity order, so severity groups are too
+	if by != GroupBySeverity {
+		sort.SliceStable(groups, func(i, j int) bool {
+			if groups[i].Name == rbac.UnmanagedOwner || groups[j].Name == rbac.UnmanagedOwner {
+				return groups[j].Name == rbac.UnmanagedOwner && groups[i].Name != rbac.UnmanagedOwner
+			}
+			return groups[i].Name < groups[j].Name
+		})
+	}
+	return groups, nil
+}
@@ -0,0 +1,209 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func newSecretsMock() *client.MockRBACClient {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-secrets", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "api", Namespace: "prod"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	return mock
+}
+
+func TestScan(t *testing.T) {
+	findings, _, err := Scan(context.Background(), newSecretsMock(), "", ScanOptions{})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	want := notify.Finding{
+		Subject:  "system:serviceaccount:prod:api",
+		Category: "secrets-access",
+		Binding:  "RoleBinding/prod/read-secrets",
+		Role:     "ClusterRole/secret-reader",
+	}
+	f, ok := findings[Key(want)]
+	if !ok {
+		t.Fatalf("expected finding %s, got %v", Key(want), findings)
+	}
+	if f.Severity != "critical" {
+		t.Errorf("expected critical severity, got %s", f.Severity)
+	}
+
+	// Scoping to another namespace skips the RoleBinding
+	findings, _, err = Scan(context.Background(), newSecretsMock(), "dev", ScanOptions{})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected no findings in dev, got %v", findings)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := notify.Finding{Subject: "a", Category: "pod-exec", Severity: "critical"}
+	b := notify.Finding{Subject: "b", Category: "pod-create", Severity: "high"}
+	c := notify.Finding{Subject: "c", Category: "secrets-access", Severity: "critical"}
+
+	prev := FindingSet{Key(a): a, Key(b): b}
+	curr := FindingSet{Key(b): b, Key(c): c}
+
+	added, resolved := Diff(prev, curr)
+	if len(added) != 1 || added[0].Subject != "c" {
+		t.Errorf("added = %v, want [c]", added)
+	}
+	if len(resolved) != 1 || resolved[0].Subject != "a" {
+		t.Errorf("resolved = %v, want [a]", resolved)
+	}
+
+	// Without a previous scan everything is new
+	added, _ = Diff(nil, curr)
+	if len(added) != 2 || added[0].Severity != "critical" {
+		t.Errorf("expected both findings, critical first, got %v", added)
+	}
+}
+
+func TestState_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	set, err := LoadState(path)
+	if err != nil || set != nil {
+		t.Fatalf("LoadState() on missing file = %v, %v; want nil, nil", set, err)
+	}
+
+	f := notify.Finding{Subject: "a", Category: "pod-exec", Severity: "critical"}
+	if err := SaveState(path, FindingSet{Key(f): f}); err != nil {
+		t.Fatalf("SaveState() error = %v", err)
+	}
+
+	set, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if _, ok := set[Key(f)]; !ok || len(set) != 1 {
+		t.Errorf("unexpected state after round trip: %v", set)
+	}
+}
+
+func TestCheckTokenExposure(t *testing.T) {
+	disabled := false
+	subject := rbac.Subject{Kind: "ServiceAccount", Name: "api", Namespace: "prod"}
+
+	tests := []struct {
+		name      string
+		sensitive []string
+		setup     func(m *client.MockRBACClient)
+		want      string // TokenExposure.String(), or "" when not exposed
+	}{
+		{
+			name:  "automount outside sensitive namespaces",
+			setup: func(m *client.MockRBACClient) { m.AddServiceAccount("prod", "api") },
+		},
+		{
+			name:      "automount in a sensitive namespace",
+			sensitive: []string{"prod"},
+			setup: func(m *client.MockRBACClient) {
+				m.AddServiceAccount("prod", "api")
+				m.Pods = []corev1.Pod{
+					{ObjectMeta: metav1.ObjectMeta{Name: "api-1", Namespace: "prod"}, Spec: corev1.PodSpec{ServiceAccountName: "api"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "api-2", Namespace: "prod"}, Spec: corev1.PodSpec{ServiceAccountName: "api", AutomountServiceAccountToken: &disabled}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "prod"}},
+				}
+			},
+			want: "automountServiceAccountToken enabled in a sensitive namespace; token mounted by pod(s) api-1",
+		},
+		{
+			name: "legacy token secret",
+			setup: func(m *client.MockRBACClient) {
+				m.AddServiceAccount("prod", "api")
+				m.ServiceAccounts[0].AutomountServiceAccountToken = &disabled
+				m.Secrets = []corev1.Secret{{
+					ObjectMeta: metav1.ObjectMeta{Name: "api-token", Namespace: "prod", Annotations: map[string]string{
+						corev1.ServiceAccountNameKey: "api",
+					}},
+					Type: corev1.SecretTypeServiceAccountToken,
+				}}
+			},
+			want: "legacy token Secret(s) api-token",
+		},
+		{
+			name:      "missing service account",
+			sensitive: []string{"prod"},
+			setup:     func(m *client.MockRBACClient) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := client.NewMockRBACClient()
+			tt.setup(mock)
+
+			exposure, err := CheckTokenExposure(context.Background(), mock, subject, tt.sensitive)
+			if err != nil {
+				t.Fatalf("CheckTokenExposure() error = %v", err)
+			}
+			got := ""
+			if exposure != nil {
+				got = exposure.String()
+			}
+			if got != tt.want {
+				t.Errorf("CheckTokenExposure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScan_RaisesExposedServiceAccounts(t *testing.T) {
+	mock := newSecretsMock()
+	mock.AddServiceAccount("prod", "api")
+
+	// pod-exec is high by default, so the raise is visible
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "api", Namespace: "prod"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+
+	findings, _, err := Scan(context.Background(), mock, "", ScanOptions{SensitiveNamespaces: []string{"prod"}})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	f, ok := findings[Key(notify.Finding{
+		Subject:  "system:serviceaccount:prod:api",
+		Category: "pod-exec",
+		Binding:  "RoleBinding/prod/exec",
+		Role:     "ClusterRole/exec",
+	})]
+	if !ok {
+		t.Fatalf("expected a pod-exec finding, got %v", findings)
+	}
+	if f.Severity != "critical" || f.Exposure == "" {
+		t.Errorf("expected exposure to raise pod-exec to critical, got severity %s, exposure %q", f.Severity, f.Exposure)
+	}
+}
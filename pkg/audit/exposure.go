@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// DefaultSensitiveNamespaces is used when no sensitive namespaces are configured
+var DefaultSensitiveNamespaces = []string{"kube-system"}
+
+// CheckTokenExposure looks up legacy token Secrets and automount settings for
+// a ServiceAccount subject. Pods are only inspected when they can be listed;
+// that lookup failing is not an error. It returns nil for other subjects and
+// when the token is not exposed.
+func CheckTokenExposure(ctx context.Context, c client.TokenClient, subject rbac.Subject, sensitiveNamespaces []string) (*rbac.TokenExposure, error) {
+	if subject.Kind != "ServiceAccount" {
+		return nil, nil
+	}
+
+	sa, err := c.GetServiceAccount(ctx, subject.Namespace, subject.Name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account %s/%s: %w", subject.Namespace, subject.Name, err)
+	}
+
+	exposure := &rbac.TokenExposure{
+		Automount: sa.AutomountServiceAccountToken == nil || *sa.AutomountServiceAccountToken,
+	}
+	for _, ns := range sensitiveNamespaces {
+		if ns == subject.Namespace {
+			exposure.SensitiveNamespace = true
+		}
+	}
+
+	secrets, err := c.ListServiceAccountTokens(ctx, subject.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token secrets in %s: %w", subject.Namespace, err)
+	}
+	for _, s := range secrets.Items {
+		if s.Annotations[corev1.ServiceAccountNameKey] == subject.Name {
+			exposure.LegacyTokenSecrets = append(exposure.LegacyTokenSecrets, s.Name)
+		}
+	}
+	sort.Strings(exposure.LegacyTokenSecrets)
+
+	// A pod's own setting takes precedence over the ServiceAccount's
+	if pods, err := c.ListPods(ctx, subject.Namespace); err == nil {
+		for _, p := range pods.Items {
+			if podServiceAccount(p) != subject.Name {
+				continue
+			}
+			mounted := exposure.Automount
+			if p.Spec.AutomountServiceAccountToken != nil {
+				mounted = *p.Spec.AutomountServiceAccountToken
+			}
+			if mounted {
+				exposure.AutomountingPods = append(exposure.AutomountingPods, p.Name)
+			}
+		}
+		sort.Strings(exposure.AutomountingPods)
+	}
+
+	if !exposure.Exposed() {
+		return nil, nil
+	}
+	return exposure, nil
+}
+
+func podServiceAccount(p corev1.Pod) string {
+	if p.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return p.Spec.ServiceAccountName
+}
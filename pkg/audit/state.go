@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+)
+
+// LoadState reads findings saved by SaveState. A missing file returns a nil
+// set, meaning there is no previous scan.
+func LoadState(path string) (FindingSet, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var findings []notify.Finding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+
+	set := make(FindingSet, len(findings))
+	for _, f := range findings {
+		set[Key(f)] = f
+	}
+	return set, nil
+}
+
+// SaveState writes findings to path, replacing it atomically
+func SaveState(path string, set FindingSet) error {
+	findings := make([]notify.Finding, 0, len(set))
+	for _, f := range set {
+		findings = append(findings, f)
+	}
+	Sort(findings)
+
+	data, err := json.MarshalIndent(findings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// TeamOptions configures a TeamReport
+type TeamOptions struct {
+	// Key is the label or annotation holding the team name. A label wins
+	// over an annotation, and the subject's own metadata over its namespace's.
+	Key string
+	// IncludeUsers also reports Users referenced by bindings. Users carry no
+	// labels, so they are attributed to the team of the namespaces they are
+	// bound in when those agree.
+	IncludeUsers bool
+	// SeverityOverrides adjust the severity of risky permission categories
+	SeverityOverrides output.SeverityOverrides
+}
+
+// teamSubject is a subject awaiting analysis and the team it belongs to
+type teamSubject struct {
+	subject rbac.Subject
+	team    string
+	source  string
+}
+
+// TeamReport enumerates ServiceAccounts, groups them by team, and runs the
+// risky permission analysis for each against a single snapshot of the
+// cluster's RBAC objects. c must implement client.SubjectClient.
+func TeamReport(ctx context.Context, c client.RBACClient, opts TeamOptions) (*output.TeamReport, error) {
+	subjects, ok := c.(client.SubjectClient)
+	if !ok {
+		return nil, fmt.Errorf("team report needs to list ServiceAccounts and namespaces, which this client does not support")
+	}
+
+	nsList, err := subjects.ListNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	nsTeams := make(map[string]teamSubject)
+	for _, ns := range nsList.Items {
+		if team, from := teamFromMeta(ns.ObjectMeta, opts.Key); team != "" {
+			nsTeams[ns.Name] = teamSubject{team: team, source: from + " on namespace " + ns.Name}
+		}
+	}
+
+	sas, err := subjects.ListServiceAccounts(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	snapshot, err := client.TakeSnapshot(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []teamSubject
+	for _, sa := range sas.Items {
+		t := teamSubject{subject: rbac.Subject{Kind: "ServiceAccount", Namespace: sa.Namespace, Name: sa.Name}}
+		if team, from := teamFromMeta(sa.ObjectMeta, opts.Key); team != "" {
+			t.team, t.source = team, from+" on ServiceAccount"
+		} else if nsTeam, ok := nsTeams[sa.Namespace]; ok {
+			t.team, t.source = nsTeam.team, nsTeam.source
+		}
+		targets = append(targets, t)
+	}
+	if opts.IncludeUsers {
+		targets = append(targets, boundUsers(snapshot, nsTeams)...)
+	}
+
+	namespaces := []string{""}
+	seen := make(map[string]bool)
+	for _, rb := range snapshot.RoleBindings {
+		if !seen[rb.Namespace] {
+			seen[rb.Namespace] = true
+			namespaces = append(namespaces, rb.Namespace)
+		}
+	}
+
+	resolver := rbac.NewResolver(snapshot)
+	teams := make(map[string]*output.TeamRollup)
+	clusterWide := make(map[string]map[string]bool)
+	for _, t := range targets {
+		if t.team == "" {
+			t.team = output.UnassignedTeam
+		}
+		found := make(FindingSet)
+		for _, ns := range namespaces {
+			grants, err := resolver.ResolveAllPermissions(ctx, t.subject, ns)
+			if err != nil {
+				return nil, err
+			}
+			risks := output.AnalyzeRiskyPermissionsWithOverrides(grants, opts.SeverityOverrides)
+			for _, f := range toFindings(t.subject, risks) {
+				found[Key(f)] = f
+			}
+		}
+
+		subject := output.TeamSubject{Subject: t.subject.Canonical(), Kind: t.subject.Kind, Source: t.source, Findings: []notify.Finding{}}
+		for _, f := range found {
+			subject.Findings = append(subject.Findings, f)
+		}
+		Sort(subject.Findings)
+		if len(subject.Findings) > 0 {
+			subject.WorstSeverity = subject.Findings[0].Severity
+		}
+
+		rollup, ok := teams[t.team]
+		if !ok {
+			rollup = &output.TeamRollup{Team: t.team, ClusterWide: []string{}}
+			teams[t.team] = rollup
+			clusterWide[t.team] = make(map[string]bool)
+		}
+		rollup.Subjects = append(rollup.Subjects, subject)
+		rollup.Findings += len(subject.Findings)
+		if rbac.SeverityRank(subject.WorstSeverity) > rbac.SeverityRank(rollup.WorstSeverity) {
+			rollup.WorstSeverity = subject.WorstSeverity
+		}
+		for _, f := range subject.Findings {
+			grant := f.Binding + " -> " + f.Role
+			if strings.HasPrefix(f.Binding, "ClusterRoleBinding/") && !clusterWide[t.team][grant] {
+				clusterWide[t.team][grant] = true
+				rollup.ClusterWide = append(rollup.ClusterWide, grant)
+			}
+		}
+	}
+
+	report := &output.TeamReport{Key: opts.Key, Teams: []output.TeamRollup{}}
+	for _, rollup := range teams {
+		sort.Strings(rollup.ClusterWide)
+		sort.Slice(rollup.Subjects, func(i, j int) bool { return rollup.Subjects[i].Subject < rollup.Subjects[j].Subject })
+		report.Teams = append(report.Teams, *rollup)
+	}
+	sort.Slice(report.Teams, func(i, j int) bool {
+		if report.Teams[i].Team == output.UnassignedTeam || report.Teams[j].Team == output.UnassignedTeam {
+			return report.Teams[i].Team == output.UnassignedTeam
+		}
+		return report.Teams[i].Team < report.Teams[j].Team
+	})
+	if rollup, ok := teams[output.UnassignedTeam]; ok {
+		report.Unassigned = len(rollup.Subjects)
+	}
+	return report, nil
+}
+
+// boundUsers collects the Users referenced by bindings. A User bound only in
+// namespaces that all belong to one team is attributed to that team.
+func boundUsers(snapshot *client.Snapshot, nsTeams map[string]teamSubject) []teamSubject {
+	index := make(map[string]int)
+	var users []teamSubject
+	var teamsOf []map[string]bool
+	add := func(name, namespace string) {
+		i, ok := index[name]
+		if !ok {
+			i = len(users)
+			index[name] = i
+			users = append(users, teamSubject{subject: rbac.Subject{Kind: "User", Name: name}})
+			teamsOf = append(teamsOf, make(map[string]bool))
+		}
+		// An unlabelled namespace or a ClusterRoleBinding counts as no team
+		teamsOf[i][nsTeams[namespace].team] = true
+	}
+
+	for _, crb := range snapshot.ClusterRoleBindings {
+		for _, s := range crb.Subjects {
+			if s.Kind == "User" {
+				add(s.Name, "")
+			}
+		}
+	}
+	for _, rb := range snapshot.RoleBindings {
+		for _, s := range rb.Subjects {
+			if s.Kind == "User" {
+				add(s.Name, rb.Namespace)
+			}
+		}
+	}
+
+	for i := range users {
+		if len(teamsOf[i]) != 1 {
+			continue
+		}
+		for team := range teamsOf[i] {
+			if team != "" {
+				users[i].team, users[i].source = team, "namespaces of its RoleBindings"
+			}
+		}
+	}
+	return users
+}
+
+// teamFromMeta reads key from labels, then annotations, and reports which
+func teamFromMeta(meta metav1.ObjectMeta, key string) (team, from string) {
+	if team := meta.Labels[key]; team != "" {
+		return team, "label"
+	}
+	if team := meta.Annotations[key]; team != "" {
+		return team, "annotation"
+	}
+	return "", ""
+}
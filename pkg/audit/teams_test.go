@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+func TestTeamReport(t *testing.T) {
+	mock := newSecretsMock()
+	mock.Namespaces = []corev1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"team": "payments"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "tools"}},
+	}
+	mock.ServiceAccounts = []corev1.ServiceAccount{
+		{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "prod"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "tools", Annotations: map[string]string{"team": "platform"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: "tools"}},
+	}
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ci-secrets"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "ci", Namespace: "tools"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+
+	report, err := TeamReport(context.Background(), mock, TeamOptions{Key: "team", IncludeUsers: true})
+	if err != nil {
+		t.Fatalf("TeamReport() error = %v", err)
+	}
+
+	var teams []string
+	byTeam := make(map[string]output.TeamRollup)
+	for _, r := range report.Teams {
+		teams = append(teams, r.Team)
+		byTeam[r.Team] = r
+	}
+	if want := []string{"unassigned", "payments", "platform"}; len(teams) != 3 || teams[0] != want[0] || teams[1] != want[1] || teams[2] != want[2] {
+		t.Fatalf("teams = %v, want %v", teams, want)
+	}
+	if report.Unassigned != 1 {
+		t.Errorf("Unassigned = %d, want 1", report.Unassigned)
+	}
+
+	payments := byTeam["payments"]
+	if len(payments.Subjects) != 2 || payments.WorstSeverity != "critical" {
+		t.Errorf("payments = %+v, want api and alice with critical findings", payments)
+	}
+	if src := payments.Subjects[0].Source; src != "namespaces of its RoleBindings" {
+		t.Errorf("alice attributed by %q", src)
+	}
+
+	platform := byTeam["platform"]
+	if len(platform.ClusterWide) != 1 || platform.ClusterWide[0] != "ClusterRoleBinding/ci-secrets -> ClusterRole/secret-reader" {
+		t.Errorf("platform cluster-wide grants = %v", platform.ClusterWide)
+	}
+	if byTeam["unassigned"].WorstSeverity != "" {
+		t.Errorf("orphan should have no findings, got %+v", byTeam["unassigned"])
+	}
+}
@@ -0,0 +1,236 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// Expected verdicts for a Check
+const (
+	ExpectAllowed = "allowed"
+	ExpectDenied  = "denied"
+)
+
+// Check is a single permission assertion from a checks file
+type Check struct {
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	As        string `json:"as,omitempty" yaml:"as,omitempty"` // Defaults to the invocation's subject
+	Verb      string `json:"verb" yaml:"verb"`
+	Resource  string `json:"resource" yaml:"resource"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Expect    string `json:"expect,omitempty" yaml:"expect,omitempty"` // allowed, denied, or empty to only report
+}
+
+// DisplayName returns the check name, or a generated one from its fields
+func (c Check) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	name := c.Verb + " " + c.Resource
+	if c.As != "" {
+		name = c.As + " " + name
+	}
+	if c.Namespace != "" {
+		name += " -n " + c.Namespace
+	}
+	return name
+}
+
+// Validate checks that the required fields are present
+func (c Check) Validate() error {
+	if c.Verb == "" {
+		return fmt.Errorf("check %q: verb is required", c.DisplayName())
+	}
+	if c.Resource == "" {
+		return fmt.Errorf("check %q: resource is required", c.DisplayName())
+	}
+	if c.Expect != "" && c.Expect != ExpectAllowed && c.Expect != ExpectDenied {
+		return fmt.Errorf("check %q: invalid expect value %q (valid: allowed, denied)", c.DisplayName(), c.Expect)
+	}
+	return nil
+}
+
+// Result is the outcome of evaluating a single Check
+type Result struct {
+	Check    Check
+	Source   string // Checks file the check was read from
+	Line     int    // Line the check starts on in Source
+	Result   *rbac.PermissionResult
+	Err      error
+	Duration time.Duration
+}
+
+// Passed reports whether the check matched its expectation.
+// Checks without an expectation pass as long as they could be evaluated.
+func (r Result) Passed() bool {
+	if r.Err != nil || r.Result == nil {
+		return false
+	}
+	switch r.Check.Expect {
+	case ExpectAllowed:
+		return r.Result.Allowed
+	case ExpectDenied:
+		return !r.Result.Allowed
+	}
+	return true
+}
+
+// Entry is a check read from a checks stream, or the reason it could not be
+// read. Line is where the entry starts in the input.
+type Entry struct {
+	Check Check
+	Line  int
+	Err   error
+}
+
+// LoadChecksFile reads checks from a YAML file. Each document may be a single
+// check or a list of checks.
+func LoadChecksFile(path string) ([]Check, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checks file: %w", err)
+	}
+	checks, err := ParseChecks(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return checks, nil
+}
+
+// ParseChecks parses a stream of checks, failing on the first malformed entry
+func ParseChecks(data []byte) ([]Check, error) {
+	var checks []Check
+	err := ReadChecks(bytes.NewReader(data), func(e Entry) error {
+		if e.Err != nil {
+			return e.Err
+		}
+		checks = append(checks, e.Check)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+// ReadChecks streams checks from r, calling fn as each one is read. The input
+// is either a YAML stream, where each document is a check or a list of checks,
+// or newline-delimited JSON with one check per line. Malformed entries are
+// passed to fn with Err set; reading stops when fn returns an error, or when
+// the input itself can no longer be parsed.
+func ReadChecks(r io.Reader, fn func(Entry) error) error {
+	br := bufio.NewReader(r)
+	if isNDJSON(br) {
+		return readNDJSON(br, fn)
+	}
+	return readYAML(br, fn)
+}
+
+// isNDJSON reports whether the input starts with a JSON object
+func isNDJSON(br *bufio.Reader) bool {
+	for i := 1; ; i++ {
+		peek, _ := br.Peek(i)
+		if len(peek) < i {
+			return false
+		}
+		switch peek[i-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		}
+		return false
+	}
+}
+
+// readNDJSON reads one JSON check per line; blank lines are skipped
+func readNDJSON(r io.Reader, fn func(Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		entry := Entry{Line: line}
+		if err := json.Unmarshal(text, &entry.Check); err != nil {
+			entry.Err = fmt.Errorf("line %d: invalid check: %w", line, err)
+		} else if err := entry.Check.Validate(); err != nil {
+			entry.Err = fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read checks: %w", err)
+	}
+	return nil
+}
+
+// readYAML reads a multi-document YAML stream of checks
+func readYAML(r io.Reader, fn func(Entry) error) error {
+	decoder := yaml.NewDecoder(r)
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("invalid checks YAML: %w", err)
+		}
+
+		for _, entry := range decodeDocument(&node) {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decodeDocument decodes a single YAML document holding a check or a list of checks
+func decodeDocument(node *yaml.Node) []Entry {
+	if len(node.Content) == 0 {
+		return nil
+	}
+
+	content := node.Content[0]
+	switch content.Kind {
+	case yaml.SequenceNode:
+		entries := make([]Entry, 0, len(content.Content))
+		for _, item := range content.Content {
+			entries = append(entries, decodeCheck(item))
+		}
+		return entries
+	case yaml.MappingNode:
+		return []Entry{decodeCheck(content)}
+	default:
+		return []Entry{{Line: content.Line, Err: fmt.Errorf("line %d: expected a check or a list of checks", content.Line)}}
+	}
+}
+
+// decodeCheck decodes and validates a single check node
+func decodeCheck(node *yaml.Node) Entry {
+	entry := Entry{Line: node.Line}
+	if node.Kind != yaml.MappingNode {
+		entry.Err = fmt.Errorf("line %d: expected a check", node.Line)
+		return entry
+	}
+	if err := node.Decode(&entry.Check); err != nil {
+		entry.Err = fmt.Errorf("line %d: invalid check: %w", node.Line, err)
+	} else if err := entry.Check.Validate(); err != nil {
+		entry.Err = fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return entry
+}
@@ -0,0 +1,111 @@
+package batch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseChecks(t *testing.T) {
+	data := []byte(`
+- name: api can read pods
+  as: system:serviceaccount:prod:api
+  verb: get
+  resource: pods
+  namespace: prod
+  expect: allowed
+- verb: delete
+  resource: secrets
+  expect: denied
+---
+verb: list
+resource: nodes
+`)
+
+	checks, err := ParseChecks(data)
+	if err != nil {
+		t.Fatalf("ParseChecks() error: %v", err)
+	}
+	if len(checks) != 3 {
+		t.Fatalf("ParseChecks() returned %d checks, expected 3", len(checks))
+	}
+	if checks[0].DisplayName() != "api can read pods" {
+		t.Errorf("checks[0].DisplayName() = %q", checks[0].DisplayName())
+	}
+	if checks[1].Expect != ExpectDenied {
+		t.Errorf("checks[1].Expect = %q, expected denied", checks[1].Expect)
+	}
+	if checks[2].Resource != "nodes" {
+		t.Errorf("checks[2].Resource = %q, expected nodes", checks[2].Resource)
+	}
+}
+
+func TestParseChecks_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "missing verb", data: "resource: pods\n"},
+		{name: "bad expect", data: "verb: get\nresource: pods\nexpect: maybe\n"},
+		{name: "scalar document", data: "just a string\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseChecks([]byte(tt.data)); err == nil {
+				t.Errorf("ParseChecks() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestReadChecks_NDJSON(t *testing.T) {
+	data := `{"verb": "get", "resource": "pods", "expect": "allowed"}
+
+{"verb": "list"}
+{"verb": "delete", "resource": "secrets"}
+`
+	var entries []Entry
+	err := ReadChecks(strings.NewReader(data), func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadChecks() error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("ReadChecks() returned %d entries, expected 3", len(entries))
+	}
+	if entries[0].Err != nil || entries[0].Check.Resource != "pods" {
+		t.Errorf("entries[0] = %+v", entries[0])
+	}
+	if entries[1].Err == nil || entries[1].Line != 3 || !strings.Contains(entries[1].Err.Error(), "line 3") {
+		t.Errorf("expected a line 3 error for the check without a resource, got %+v", entries[1])
+	}
+	if entries[2].Err != nil || entries[2].Line != 4 {
+		t.Errorf("entries[2] = %+v", entries[2])
+	}
+}
+
+func TestReadChecks_YAMLLineNumbers(t *testing.T) {
+	data := `- verb: get
+  resource: pods
+- verb: list
+---
+resource: nodes
+`
+	var lines []int
+	var errs int
+	err := ReadChecks(strings.NewReader(data), func(e Entry) error {
+		lines = append(lines, e.Line)
+		if e.Err != nil {
+			errs++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadChecks() error: %v", err)
+	}
+	if len(lines) != 3 || lines[1] != 3 || lines[2] != 5 || errs != 2 {
+		t.Errorf("lines = %v, errors = %d; expected [1 3 5] with 2 errors", lines, errs)
+	}
+}
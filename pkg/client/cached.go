@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Cached remembers the lists and roles another RBACClient returns, so
+// resolving several requests for the same subject reads each object once.
+// Unlike a Snapshot, it only reads what the requests need. Errors are not
+// cached.
+type Cached struct {
+	base RBACClient
+
+	mu                  sync.Mutex
+	roles               map[string]*rbacv1.RoleList
+	roleBindings        map[string]*rbacv1.RoleBindingList
+	clusterRoles        *rbacv1.ClusterRoleList
+	clusterRoleBindings *rbacv1.ClusterRoleBindingList
+	role                map[objectKey]*rbacv1.Role
+	clusterRole         map[string]*rbacv1.ClusterRole
+}
+
+// NewCached wraps base
+func NewCached(base RBACClient) *Cached {
+	return &Cached{
+		base:         base,
+		roles:        make(map[string]*rbacv1.RoleList),
+		roleBindings: make(map[string]*rbacv1.RoleBindingList),
+		role:         make(map[objectKey]*rbacv1.Role),
+		clusterRole:  make(map[string]*rbacv1.ClusterRole),
+	}
+}
+
+func (c *Cached) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if list, ok := c.roles[namespace]; ok {
+		return list, nil
+	}
+	list, err := c.base.ListRoles(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.roles[namespace] = list
+	return list, nil
+}
+
+func (c *Cached) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clusterRoles != nil {
+		return c.clusterRoles, nil
+	}
+	list, err := c.base.ListClusterRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.clusterRoles = list
+	return list, nil
+}
+
+func (c *Cached) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if list, ok := c.roleBindings[namespace]; ok {
+		return list, nil
+	}
+	list, err := c.base.ListRoleBindings(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.roleBindings[namespace] = list
+	return list, nil
+}
+
+func (c *Cached) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clusterRoleBindings != nil {
+		return c.clusterRoleBindings, nil
+	}
+	list, err := c.base.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.clusterRoleBindings = list
+	return list, nil
+}
+
+func (c *Cached) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := objectKey{namespace, name}
+	if role, ok := c.role[key]; ok {
+		return role, nil
+	}
+	role, err := c.base.GetRole(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	c.role[key] = role
+	return role, nil
+}
+
+func (c *Cached) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if role, ok := c.clusterRole[name]; ok {
+		return role, nil
+	}
+	role, err := c.base.GetClusterRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.clusterRole[name] = role
+	return role, nil
+}
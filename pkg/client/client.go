@@ -2,9 +2,18 @@ package client
 
 import (
 	"context"
+	"io"
+	"sync"
 
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -19,45 +28,293 @@ type RBACClient interface {
 	GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error)
 }
 
+// SubjectClient lists the objects that binding subjects refer to. It is
+// optional: checks that need it are skipped for clients that don't implement it.
+type SubjectClient interface {
+	ListServiceAccounts(ctx context.Context, namespace string) (*corev1.ServiceAccountList, error)
+	ListNamespaces(ctx context.Context) (*corev1.NamespaceList, error)
+	GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error)
+}
+
+// TokenClient looks up where a ServiceAccount's token can be read from. It is
+// optional, like SubjectClient.
+type TokenClient interface {
+	GetServiceAccount(ctx context.Context, namespace, name string) (*corev1.ServiceAccount, error)
+	// ListServiceAccountTokens lists the legacy kubernetes.io/service-account-token Secrets
+	ListServiceAccountTokens(ctx context.Context, namespace string) (*corev1.SecretList, error)
+	ListPods(ctx context.Context, namespace string) (*corev1.PodList, error)
+}
+
+// PodClient reads a single Pod. It is optional, like SubjectClient.
+type PodClient interface {
+	GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error)
+}
+
+// WorkloadClient reads the workloads whose Pod template names a
+// ServiceAccount. It is optional, like SubjectClient.
+type WorkloadClient interface {
+	GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	GetStatefulSet(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error)
+	GetDaemonSet(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error)
+	GetJob(ctx context.Context, namespace, name string) (*batchv1.Job, error)
+	GetCronJob(ctx context.Context, namespace, name string) (*batchv1.CronJob, error)
+}
+
+// DiscoveryClient lists the resources the API server serves, including
+// subresources as "resource/subresource" entries. It is optional, like
+// SubjectClient.
+type DiscoveryClient interface {
+	ServerResources(ctx context.Context) ([]*metav1.APIResourceList, error)
+}
+
+// ClusterInfoClient reads the cluster details recorded in a snapshot. It is
+// optional, like SubjectClient.
+type ClusterInfoClient interface {
+	ServerVersion(ctx context.Context) (string, error)
+	GetConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error)
+}
+
+// AccessReviewClient asks the API server's authorizers for a decision. It is
+// optional, like SubjectClient.
+type AccessReviewClient interface {
+	CreateSubjectAccessReview(ctx context.Context, review *authorizationv1.SubjectAccessReview) (*authorizationv1.SubjectAccessReview, error)
+}
+
+// SelfReviewClient asks the API server what the caller itself may do, which
+// needs no permission to read RBAC objects. It is optional, like SubjectClient.
+type SelfReviewClient interface {
+	CreateSelfSubjectAccessReview(ctx context.Context, review *authorizationv1.SelfSubjectAccessReview) (*authorizationv1.SelfSubjectAccessReview, error)
+	CreateSelfSubjectRulesReview(ctx context.Context, review *authorizationv1.SelfSubjectRulesReview) (*authorizationv1.SelfSubjectRulesReview, error)
+}
+
+// IdentityClient asks the API server who the caller is authenticated as. It
+// is optional, like SubjectClient.
+type IdentityClient interface {
+	SelfSubjectReview(ctx context.Context) (*authenticationv1.UserInfo, error)
+}
+
 // K8sRBACClient implements RBACClient using the Kubernetes API
 type K8sRBACClient struct {
 	clientset kubernetes.Interface
+
+	// PageSize is how many objects each list request asks for; 0 lists
+	// everything at once
+	PageSize int64
+	// Warnings receives notes about listings that had to start over
+	Warnings io.Writer
+
+	// discovery is fetched once per client, however many checks need it
+	discoveryOnce sync.Once
+	resources     []*metav1.APIResourceList
+	discoveryErr  error
 }
 
-// NewK8sRBACClient creates a new Kubernetes RBAC client
+// DefaultAcceptContentTypes asks the API server for protobuf, which decodes
+// several times faster than JSON for large lists of built-in objects, and
+// for JSON from servers that don't serve protobuf
+const DefaultAcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+
+// NewK8sRBACClient creates a new Kubernetes RBAC client. Responses are
+// requested as DefaultAcceptContentTypes unless config sets
+// AcceptContentTypes. The client only reads built-in types; custom
+// resources aren't served as protobuf, so clients for them must be built
+// from a config of their own that asks for JSON.
 func NewK8sRBACClient(config *rest.Config) (*K8sRBACClient, error) {
+	if config.AcceptContentTypes == "" {
+		config = rest.CopyConfig(config)
+		config.AcceptContentTypes = DefaultAcceptContentTypes
+	}
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
-	return &K8sRBACClient{clientset: clientset}, nil
+	return &K8sRBACClient{clientset: clientset, PageSize: DefaultPageSize}, nil
 }
 
 // NewK8sRBACClientFromClientset creates a client from an existing clientset
 func NewK8sRBACClientFromClientset(clientset kubernetes.Interface) *K8sRBACClient {
-	return &K8sRBACClient{clientset: clientset}
+	return &K8sRBACClient{clientset: clientset, PageSize: DefaultPageSize}
 }
 
+// The RBAC objects are listed a page at a time; see PageSize
+
 func (c *K8sRBACClient) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
-	return c.clientset.RbacV1().Roles(namespace).List(ctx, metav1.ListOptions{})
+	list := &rbacv1.RoleList{}
+	if err := c.eachRole(ctx, namespace, collect(&list.Items)); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 func (c *K8sRBACClient) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
-	return c.clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	list := &rbacv1.ClusterRoleList{}
+	if err := c.eachClusterRole(ctx, collect(&list.Items)); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 func (c *K8sRBACClient) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
-	return c.clientset.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
+	list := &rbacv1.RoleBindingList{}
+	if err := c.EachRoleBinding(ctx, namespace, collect(&list.Items)); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 func (c *K8sRBACClient) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
-	return c.clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	list := &rbacv1.ClusterRoleBindingList{}
+	if err := c.EachClusterRoleBinding(ctx, collect(&list.Items)); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 func (c *K8sRBACClient) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	SetPhase(ctx, "fetching Role %s/%s", namespace, name)
 	return c.clientset.RbacV1().Roles(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
 func (c *K8sRBACClient) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	SetPhase(ctx, "fetching ClusterRole %s", name)
 	return c.clientset.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
 }
+
+func (c *K8sRBACClient) ListServiceAccounts(ctx context.Context, namespace string) (*corev1.ServiceAccountList, error) {
+	SetPhase(ctx, "listing %s", describeList("ServiceAccounts", namespace))
+	return c.clientset.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
+}
+
+func (c *K8sRBACClient) ListNamespaces(ctx context.Context) (*corev1.NamespaceList, error) {
+	SetPhase(ctx, "listing Namespaces")
+	return c.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+}
+
+func (c *K8sRBACClient) GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	SetPhase(ctx, "fetching Namespace %s", name)
+	return c.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) GetServiceAccount(ctx context.Context, namespace, name string) (*corev1.ServiceAccount, error) {
+	SetPhase(ctx, "fetching ServiceAccount %s/%s", namespace, name)
+	return c.clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) ListServiceAccountTokens(ctx context.Context, namespace string) (*corev1.SecretList, error) {
+	SetPhase(ctx, "listing %s", describeList("ServiceAccount token Secrets", namespace))
+	return c.clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + string(corev1.SecretTypeServiceAccountToken),
+	})
+}
+
+func (c *K8sRBACClient) ListPods(ctx context.Context, namespace string) (*corev1.PodList, error) {
+	SetPhase(ctx, "listing %s", describeList("Pods", namespace))
+	return c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+}
+
+func (c *K8sRBACClient) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	SetPhase(ctx, "fetching Pod %s/%s", namespace, name)
+	return c.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	SetPhase(ctx, "fetching Deployment %s/%s", namespace, name)
+	return c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) GetStatefulSet(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error) {
+	SetPhase(ctx, "fetching StatefulSet %s/%s", namespace, name)
+	return c.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) GetDaemonSet(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error) {
+	SetPhase(ctx, "fetching DaemonSet %s/%s", namespace, name)
+	return c.clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) GetJob(ctx context.Context, namespace, name string) (*batchv1.Job, error) {
+	SetPhase(ctx, "fetching Job %s/%s", namespace, name)
+	return c.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) GetCronJob(ctx context.Context, namespace, name string) (*batchv1.CronJob, error) {
+	SetPhase(ctx, "fetching CronJob %s/%s", namespace, name)
+	return c.clientset.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) ServerResources(ctx context.Context) ([]*metav1.APIResourceList, error) {
+	SetPhase(ctx, "discovering the resources the cluster serves")
+	return awaitContext(ctx, func() ([]*metav1.APIResourceList, error) {
+		c.discoveryOnce.Do(func() {
+			_, lists, err := c.clientset.Discovery().ServerGroupsAndResources()
+			// Groups that failed discovery (e.g. an unavailable aggregated API)
+			// are left out; the rest is still usable
+			if err != nil && discovery.IsGroupDiscoveryFailedError(err) && len(lists) > 0 {
+				err = nil
+			}
+			c.resources, c.discoveryErr = lists, err
+		})
+		return c.resources, c.discoveryErr
+	})
+}
+
+func (c *K8sRBACClient) ServerVersion(ctx context.Context) (string, error) {
+	SetPhase(ctx, "reading the server version")
+	return awaitContext(ctx, func() (string, error) {
+		info, err := c.clientset.Discovery().ServerVersion()
+		if err != nil {
+			return "", err
+		}
+		return info.GitVersion, nil
+	})
+}
+
+// awaitContext runs fn, a discovery call that takes no context, and stops
+// waiting for it once ctx is done. fn is left to finish in the background.
+func awaitContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (c *K8sRBACClient) GetConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	SetPhase(ctx, "fetching ConfigMap %s/%s", namespace, name)
+	return c.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (c *K8sRBACClient) CreateSubjectAccessReview(ctx context.Context, review *authorizationv1.SubjectAccessReview) (*authorizationv1.SubjectAccessReview, error) {
+	SetPhase(ctx, "creating a SubjectAccessReview")
+	return c.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+}
+
+func (c *K8sRBACClient) CreateSelfSubjectAccessReview(ctx context.Context, review *authorizationv1.SelfSubjectAccessReview) (*authorizationv1.SelfSubjectAccessReview, error) {
+	SetPhase(ctx, "creating a SelfSubjectAccessReview")
+	return c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+}
+
+func (c *K8sRBACClient) CreateSelfSubjectRulesReview(ctx context.Context, review *authorizationv1.SelfSubjectRulesReview) (*authorizationv1.SelfSubjectRulesReview, error) {
+	SetPhase(ctx, "creating a SelfSubjectRulesReview")
+	return c.clientset.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, review, metav1.CreateOptions{})
+}
+
+func (c *K8sRBACClient) SelfSubjectReview(ctx context.Context) (*authenticationv1.UserInfo, error) {
+	SetPhase(ctx, "creating a SelfSubjectReview")
+	resp, err := c.clientset.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Status.UserInfo, nil
+}
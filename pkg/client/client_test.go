@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func TestNewK8sRBACClient_AcceptContentTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "protobuf first by default", want: DefaultAcceptContentTypes},
+		{name: "override", accept: runtime.ContentTypeJSON, want: runtime.ContentTypeJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			// The server answers in JSON, as one without protobuf would
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("Accept")
+				w.Header().Set("Content-Type", runtime.ContentTypeJSON)
+				_, _ = fmt.Fprint(w, `{"kind":"ClusterRoleList","apiVersion":"rbac.authorization.k8s.io/v1","items":[{"metadata":{"name":"view"}}]}`)
+			}))
+			defer server.Close()
+
+			config := &rest.Config{Host: server.URL}
+			config.AcceptContentTypes = tt.accept
+			c, err := NewK8sRBACClient(config)
+			if err != nil {
+				t.Fatalf("NewK8sRBACClient() error = %v", err)
+			}
+			list, err := c.ListClusterRoles(context.Background())
+			if err != nil {
+				t.Fatalf("ListClusterRoles() error = %v", err)
+			}
+			if len(list.Items) != 1 || list.Items[0].Name != "view" {
+				t.Errorf("ListClusterRoles() = %+v, want the view ClusterRole", list.Items)
+			}
+			if got != tt.want {
+				t.Errorf("Accept = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// benchmarkDecodeClusterRoles decodes a list of 5,000 ClusterRoles encoded
+// as mediaType, as the client does with each list response
+func benchmarkDecodeClusterRoles(b *testing.B, mediaType string) {
+	list := &rbacv1.ClusterRoleList{TypeMeta: metav1.TypeMeta{Kind: "ClusterRoleList", APIVersion: "rbac.authorization.k8s.io/v1"}}
+	for i := range 5000 {
+		list.Items = append(list.Items, rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("role-%d", i), Labels: map[string]string{"team": "platform"}},
+			Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments", "configmaps"}},
+				{Verbs: []string{"create", "update"}, APIGroups: []string{"batch"}, Resources: []string{"jobs", "cronjobs"}},
+			},
+		})
+	}
+	info, ok := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		b.Fatalf("no serializer for %s", mediaType)
+	}
+	encoder := scheme.Codecs.EncoderForVersion(info.Serializer, rbacv1.SchemeGroupVersion)
+	data, err := runtime.Encode(encoder, list)
+	if err != nil {
+		b.Fatal(err)
+	}
+	decoder := scheme.Codecs.WithoutConversion().DecoderToVersion(info.Serializer, rbacv1.SchemeGroupVersion)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := runtime.Decode(decoder, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeClusterRoles_JSON(b *testing.B) {
+	benchmarkDecodeClusterRoles(b, runtime.ContentTypeJSON)
+}
+
+func BenchmarkDecodeClusterRoles_Protobuf(b *testing.B) {
+	benchmarkDecodeClusterRoles(b, runtime.ContentTypeProtobuf)
+}
+
+func TestDiskCacheDir_Identity(t *testing.T) {
+	base := &rest.Config{Host: "https://api.example.com:6443", BearerToken: "alice-token"}
+	impersonating := rest.CopyConfig(base)
+	impersonating.Impersonate.UserName = "bob"
+	otherToken := rest.CopyConfig(base)
+	otherToken.BearerToken = "carol-token"
+
+	dir := DiskCacheDir("/cache", base.Host, CacheIdentity(base, "prod"))
+	if want := "/cache/api.example.com_6443/"; !strings.HasPrefix(dir, want) {
+		t.Errorf("DiskCacheDir() = %q, want it under %q", dir, want)
+	}
+	if strings.Contains(dir, "alice-token") {
+		t.Errorf("DiskCacheDir() = %q contains the token", dir)
+	}
+	if again := DiskCacheDir("/cache", base.Host, CacheIdentity(rest.CopyConfig(base), "prod")); again != dir {
+		t.Errorf("DiskCacheDir() = %q for the same identity, want %q", again, dir)
+	}
+	for name, other := range map[string]string{
+		"impersonation": CacheIdentity(impersonating, "prod"),
+		"credentials":   CacheIdentity(otherToken, "prod"),
+		"context":       CacheIdentity(base, "staging"),
+	} {
+		if DiskCacheDir("/cache", base.Host, other) == dir {
+			t.Errorf("another %s shares the cache directory %q", name, dir)
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+// DiskCache keeps the Roles, ClusterRoles, and bindings another RBACClient
+// lists in files under a directory, so commands run in a row within the TTL
+// share one listing. Roles are looked up in the cached lists while those are
+// fresh, so a run that finds its lists cached reads nothing from the
+// cluster. Errors are not cached, and a cache that can't be written is
+// skipped.
+type DiskCache struct {
+	base RBACClient
+	dir  string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]any // decoded lists by file name
+	oldest  time.Time      // when the oldest list served from disk was listed
+}
+
+// diskCacheEntry is a cached list with when it was listed
+type diskCacheEntry[T any] struct {
+	ListedAt time.Time `json:"listedAt"`
+	Items    []T       `json:"items"`
+}
+
+// NewDiskCache wraps base, caching its lists in dir for ttl
+func NewDiskCache(base RBACClient, dir string, ttl time.Duration) *DiskCache {
+	return &DiskCache{base: base, dir: dir, ttl: ttl, entries: make(map[string]any)}
+}
+
+var unsafeCacheChars = regexp.MustCompile(`[^A-Za-z0-9.\-]`)
+
+// DiskCacheDir returns the directory under root for the cluster at host,
+// like kubectl's discovery cache, and for the identity it is read as, e.g.
+// root/api.example.com_6443/3f9a0c2e5b7d1e48. Identities can be refused
+// different objects, so they never share a listing.
+func DiskCacheDir(root, host, identity string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	return filepath.Join(root, unsafeCacheChars.ReplaceAllString(host, "_"), identity)
+}
+
+// CacheIdentity returns a short hash of who config authenticates and
+// impersonates as, together with the kubeconfig context, for DiskCacheDir.
+// Credentials are only ever stored hashed.
+func CacheIdentity(config *rest.Config, context string) string {
+	h := sha256.New()
+	add := func(values ...string) {
+		for _, v := range values {
+			_, _ = fmt.Fprintf(h, "%d:%s;", len(v), v)
+		}
+	}
+	add(context, config.Username, config.Password, config.BearerToken, config.BearerTokenFile)
+	add(config.CertFile, config.KeyFile, string(config.CertData), string(config.KeyData))
+	if exec := config.ExecProvider; exec != nil {
+		add(exec.Command)
+		add(exec.Args...)
+		for _, env := range exec.Env {
+			add(env.Name, env.Value)
+		}
+	}
+	if auth := config.AuthProvider; auth != nil {
+		add(auth.Name)
+		for _, k := range slices.Sorted(maps.Keys(auth.Config)) {
+			add(k, auth.Config[k])
+		}
+	}
+	impersonate := config.Impersonate
+	add(impersonate.UserName, impersonate.UID)
+	add(impersonate.Groups...)
+	for _, k := range slices.Sorted(maps.Keys(impersonate.Extra)) {
+		add(k)
+		add(impersonate.Extra[k]...)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Age reports how old the oldest cached data served so far is, and whether
+// any was
+func (c *DiskCache) Age() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.oldest.IsZero() {
+		return 0, false
+	}
+	return time.Since(c.oldest), true
+}
+
+// cachedList returns the items cached in file while they are fresh
+func cachedList[T any](c *DiskCache, file string) ([]T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[file].(*diskCacheEntry[T])
+	if !ok {
+		data, err := os.ReadFile(filepath.Join(c.dir, file))
+		if err != nil {
+			return nil, false
+		}
+		entry = &diskCacheEntry[T]{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, false
+		}
+		c.entries[file] = entry
+	}
+	if time.Since(entry.ListedAt) >= c.ttl {
+		return nil, false
+	}
+	if c.oldest.IsZero() || entry.ListedAt.Before(c.oldest) {
+		c.oldest = entry.ListedAt
+	}
+	return entry.Items, true
+}
+
+// listCached returns the items cached in file, or lists them and caches them
+func listCached[T any](c *DiskCache, file string, list func() ([]T, error)) ([]T, error) {
+	if items, ok := cachedList[T](c, file); ok {
+		return items, nil
+	}
+	items, err := list()
+	if err != nil {
+		return nil, err
+	}
+	entry := &diskCacheEntry[T]{ListedAt: time.Now(), Items: items}
+	c.mu.Lock()
+	c.entries[file] = entry
+	c.mu.Unlock()
+	c.write(file, entry)
+	return items, nil
+}
+
+// eachCached passes the items cached in file to fn while they are fresh.
+// Otherwise it passes those stream yields, writing them to file as they
+// arrive, so the list is never held whole. The file is only written when
+// the stream ends without an error.
+func eachCached[T any](c *DiskCache, file string, fn func(*T) error, stream func(func(*T) error) error) error {
+	if items, ok := cachedList[T](c, file); ok {
+		return each(items, fn)
+	}
+	w := c.newStreamWriter(file)
+	err := stream(func(item *T) error {
+		if err := fn(item); err != nil {
+			return err
+		}
+		w.add(item)
+		return nil
+	})
+	if err != nil {
+		w.abort()
+		return err
+	}
+	w.commit()
+	return nil
+}
+
+// streamWriter writes a cache entry one item at a time through a temporary
+// file. After a failed write it does nothing, leaving the cache as it was.
+type streamWriter struct {
+	c    *DiskCache
+	file string
+	tmp  *os.File
+	n    int
+	err  error
+}
+
+func (c *DiskCache) newStreamWriter(file string) *streamWriter {
+	w := &streamWriter{c: c, file: file}
+	listedAt, err := json.Marshal(time.Now())
+	if err == nil {
+		err = os.MkdirAll(c.dir, 0o700)
+	}
+	if err == nil {
+		w.tmp, err = os.CreateTemp(c.dir, file+".*")
+	}
+	w.err = err
+	w.write([]byte(`{"listedAt":`), listedAt, []byte(`,"items":[`))
+	return w
+}
+
+func (w *streamWriter) write(chunks ...[]byte) {
+	for _, chunk := range chunks {
+		if w.err != nil {
+			return
+		}
+		_, w.err = w.tmp.Write(chunk)
+	}
+}
+
+func (w *streamWriter) add(item any) {
+	if w.err != nil {
+		return
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		w.err = err
+		return
+	}
+	if w.n > 0 {
+		w.write([]byte(","))
+	}
+	w.write(data)
+	w.n++
+}
+
+func (w *streamWriter) abort() {
+	if w.tmp != nil {
+		_ = w.tmp.Close()
+		_ = os.Remove(w.tmp.Name())
+	}
+}
+
+// commit moves the entry into place, replacing any older one the cache
+// holds in memory
+func (w *streamWriter) commit() {
+	w.write([]byte("]}"))
+	if w.err != nil {
+		w.abort()
+		return
+	}
+	if err := w.tmp.Close(); err != nil {
+		_ = os.Remove(w.tmp.Name())
+		return
+	}
+	if err := os.Rename(w.tmp.Name(), filepath.Join(w.c.dir, w.file)); err != nil {
+		_ = os.Remove(w.tmp.Name())
+		return
+	}
+	w.c.mu.Lock()
+	delete(w.c.entries, w.file)
+	w.c.mu.Unlock()
+}
+
+// write saves an entry through a temporary file, so concurrent runs never
+// read a partial one
+func (c *DiskCache) write(file string, entry any) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, file+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, file))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// namespacedFile names the cache file of a list in namespace, or across all
+// namespaces when it is empty
+func namespacedFile(kind, namespace string) string {
+	if namespace == "" {
+		return kind + ".json"
+	}
+	return kind + "-" + namespace + ".json"
+}
+
+// inNamespace filters items listed across all namespaces
+func inNamespace[T any](items []T, namespace string, ns func(*T) string) []T {
+	var filtered []T
+	for i := range items {
+		if ns(&items[i]) == namespace {
+			filtered = append(filtered, items[i])
+		}
+	}
+	return filtered
+}
+
+func (c *DiskCache) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
+	if all, ok := cachedList[rbacv1.Role](c, namespacedFile("roles", "")); ok && namespace != "" {
+		return &rbacv1.RoleList{Items: inNamespace(all, namespace, func(r *rbacv1.Role) string { return r.Namespace })}, nil
+	}
+	items, err := listCached(c, namespacedFile("roles", namespace), func() ([]rbacv1.Role, error) {
+		list, err := c.base.ListRoles(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rbacv1.RoleList{Items: items}, nil
+}
+
+func (c *DiskCache) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+	items, err := listCached(c, "clusterroles.json", func() ([]rbacv1.ClusterRole, error) {
+		list, err := c.base.ListClusterRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rbacv1.ClusterRoleList{Items: items}, nil
+}
+
+func (c *DiskCache) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	if all, ok := cachedList[rbacv1.RoleBinding](c, namespacedFile("rolebindings", "")); ok && namespace != "" {
+		return &rbacv1.RoleBindingList{Items: inNamespace(all, namespace, func(rb *rbacv1.RoleBinding) string { return rb.Namespace })}, nil
+	}
+	items, err := listCached(c, namespacedFile("rolebindings", namespace), func() ([]rbacv1.RoleBinding, error) {
+		list, err := c.base.ListRoleBindings(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rbacv1.RoleBindingList{Items: items}, nil
+}
+
+func (c *DiskCache) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+	items, err := listCached(c, "clusterrolebindings.json", func() ([]rbacv1.ClusterRoleBinding, error) {
+		list, err := c.base.ListClusterRoleBindings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return list.Items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rbacv1.ClusterRoleBindingList{Items: items}, nil
+}
+
+// GetRole looks the Role up in a fresh cached list of its namespace, or of
+// every namespace. Without one it reads the Role alone, since listing every
+// Role to find one costs more than it saves.
+func (c *DiskCache) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	items, ok := cachedList[rbacv1.Role](c, namespacedFile("roles", namespace))
+	if !ok {
+		if items, ok = cachedList[rbacv1.Role](c, namespacedFile("roles", "")); !ok {
+			return c.base.GetRole(ctx, namespace, name)
+		}
+	}
+	for i := range items {
+		if items[i].Namespace == namespace && items[i].Name == name {
+			return &items[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(rbacv1.Resource("roles"), name)
+}
+
+// GetClusterRole looks the ClusterRole up in a fresh cached list, like
+// GetRole
+func (c *DiskCache) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	items, ok := cachedList[rbacv1.ClusterRole](c, "clusterroles.json")
+	if !ok {
+		return c.base.GetClusterRole(ctx, name)
+	}
+	for i := range items {
+		if items[i].Name == name {
+			return &items[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(rbacv1.Resource("clusterroles"), name)
+}
+
+// CachedK8sRBACClient is a K8sRBACClient whose RBAC objects come from a
+// DiskCache. Everything else, such as discovery and access reviews, is
+// still read from the cluster.
+type CachedK8sRBACClient struct {
+	*K8sRBACClient
+	Cache *DiskCache
+}
+
+// NewCachedK8sRBACClient caches c's RBAC objects in dir for ttl
+func NewCachedK8sRBACClient(c *K8sRBACClient, dir string, ttl time.Duration) *CachedK8sRBACClient {
+	return &CachedK8sRBACClient{K8sRBACClient: c, Cache: NewDiskCache(c, dir, ttl)}
+}
+
+func (c *CachedK8sRBACClient) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
+	return c.Cache.ListRoles(ctx, namespace)
+}
+
+func (c *CachedK8sRBACClient) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+	return c.Cache.ListClusterRoles(ctx)
+}
+
+func (c *CachedK8sRBACClient) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	return c.Cache.ListRoleBindings(ctx, namespace)
+}
+
+func (c *CachedK8sRBACClient) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+	return c.Cache.ListClusterRoleBindings(ctx)
+}
+
+func (c *CachedK8sRBACClient) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	return c.Cache.GetRole(ctx, namespace, name)
+}
+
+func (c *CachedK8sRBACClient) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	return c.Cache.GetClusterRole(ctx, name)
+}
+
+// EachClusterRoleBinding streams the ClusterRoleBindings from the cache,
+// or from the cluster a page at a time while caching them
+func (c *CachedK8sRBACClient) EachClusterRoleBinding(ctx context.Context, fn func(*rbacv1.ClusterRoleBinding) error) error {
+	return eachCached(c.Cache, "clusterrolebindings.json", fn, func(fn func(*rbacv1.ClusterRoleBinding) error) error {
+		return c.K8sRBACClient.EachClusterRoleBinding(ctx, fn)
+	})
+}
+
+// EachRoleBinding streams the RoleBindings like EachClusterRoleBinding.
+// Those of one namespace come from the cached list of every namespace
+// when it is fresh.
+func (c *CachedK8sRBACClient) EachRoleBinding(ctx context.Context, namespace string, fn func(*rbacv1.RoleBinding) error) error {
+	if all, ok := cachedList[rbacv1.RoleBinding](c.Cache, namespacedFile("rolebindings", "")); ok && namespace != "" {
+		return each(inNamespace(all, namespace, func(rb *rbacv1.RoleBinding) string { return rb.Namespace }), fn)
+	}
+	return eachCached(c.Cache, namespacedFile("rolebindings", namespace), fn, func(fn func(*rbacv1.RoleBinding) error) error {
+		return c.K8sRBACClient.EachRoleBinding(ctx, namespace, fn)
+	})
+}
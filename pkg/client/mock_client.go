@@ -2,9 +2,15 @@ package client
 
 import (
 	"context"
-	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // MockRBACClient is a mock implementation of RBACClient for testing
@@ -13,14 +19,41 @@ type MockRBACClient struct {
 	ClusterRoles        *rbacv1.ClusterRoleList
 	RoleBindings        map[string]*rbacv1.RoleBindingList // namespace -> RoleBindingList
 	ClusterRoleBindings *rbacv1.ClusterRoleBindingList
+	ServiceAccounts     []corev1.ServiceAccount
+	Namespaces          []corev1.Namespace
+	Secrets             []corev1.Secret
+	Pods                []corev1.Pod
+	Deployments         []appsv1.Deployment
+	StatefulSets        []appsv1.StatefulSet
+	DaemonSets          []appsv1.DaemonSet
+	Jobs                []batchv1.Job
+	CronJobs            []batchv1.CronJob
+	Resources           []*metav1.APIResourceList
+	ConfigMaps          []corev1.ConfigMap
+	Version             string
+
+	// AccessReviewStatus is the decision returned for every SubjectAccessReview;
+	// the reviews received are recorded in AccessReviews
+	AccessReviewStatus authorizationv1.SubjectAccessReviewStatus
+	AccessReviews      []authorizationv1.SubjectAccessReview
+	// SelfAccessReviewStatus and SelfRulesReviewStatus answer the caller's
+	// own reviews
+	SelfAccessReviewStatus authorizationv1.SubjectAccessReviewStatus
+	SelfRulesReviewStatus  authorizationv1.SubjectRulesReviewStatus
+	// SelfUser is who the caller is authenticated as; nil acts like a
+	// server without the SelfSubjectReview API
+	SelfUser *authenticationv1.UserInfo
 
 	// Error simulation
-	ListRolesError               error
-	ListClusterRolesError        error
-	ListRoleBindingsError        error
+	ListRolesError        error
+	ListClusterRolesError error
+	ListRoleBindingsError error
+	// ListRoleBindingsErrors fails listing in individual namespaces
+	ListRoleBindingsErrors       map[string]error
 	ListClusterRoleBindingsError error
 	GetRoleError                 error
 	GetClusterRoleError          error
+	ServerResourcesError         error
 }
 
 // NewMockRBACClient creates a new mock client with empty data
@@ -37,6 +70,13 @@ func (m *MockRBACClient) ListRoles(ctx context.Context, namespace string) (*rbac
 	if m.ListRolesError != nil {
 		return nil, m.ListRolesError
 	}
+	if namespace == "" {
+		all := &rbacv1.RoleList{}
+		for _, roles := range m.Roles {
+			all.Items = append(all.Items, roles.Items...)
+		}
+		return all, nil
+	}
 	if roles, ok := m.Roles[namespace]; ok {
 		return roles, nil
 	}
@@ -57,6 +97,17 @@ func (m *MockRBACClient) ListRoleBindings(ctx context.Context, namespace string)
 	if m.ListRoleBindingsError != nil {
 		return nil, m.ListRoleBindingsError
 	}
+	if err := m.ListRoleBindingsErrors[namespace]; err != nil {
+		return nil, err
+	}
+	// Like the API server, an empty namespace lists across all namespaces
+	if namespace == "" {
+		all := &rbacv1.RoleBindingList{}
+		for _, bindings := range m.RoleBindings {
+			all.Items = append(all.Items, bindings.Items...)
+		}
+		return all, nil
+	}
 	if bindings, ok := m.RoleBindings[namespace]; ok {
 		return bindings, nil
 	}
@@ -84,7 +135,7 @@ func (m *MockRBACClient) GetRole(ctx context.Context, namespace, name string) (*
 			}
 		}
 	}
-	return nil, fmt.Errorf("role %s not found in namespace %s", name, namespace)
+	return nil, apierrors.NewNotFound(rbacv1.Resource("roles"), name)
 }
 
 func (m *MockRBACClient) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
@@ -98,7 +149,7 @@ func (m *MockRBACClient) GetClusterRole(ctx context.Context, name string) (*rbac
 			}
 		}
 	}
-	return nil, fmt.Errorf("clusterrole %s not found", name)
+	return nil, apierrors.NewNotFound(rbacv1.Resource("clusterroles"), name)
 }
 
 // AddRole adds a role to the mock
@@ -134,3 +185,173 @@ func (m *MockRBACClient) AddClusterRoleBinding(crb rbacv1.ClusterRoleBinding) {
 	}
 	m.ClusterRoleBindings.Items = append(m.ClusterRoleBindings.Items, crb)
 }
+
+func (m *MockRBACClient) ListServiceAccounts(ctx context.Context, namespace string) (*corev1.ServiceAccountList, error) {
+	list := &corev1.ServiceAccountList{}
+	for _, sa := range m.ServiceAccounts {
+		if namespace == "" || sa.Namespace == namespace {
+			list.Items = append(list.Items, sa)
+		}
+	}
+	return list, nil
+}
+
+func (m *MockRBACClient) ListNamespaces(ctx context.Context) (*corev1.NamespaceList, error) {
+	return &corev1.NamespaceList{Items: m.Namespaces}, nil
+}
+
+func (m *MockRBACClient) GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	for _, ns := range m.Namespaces {
+		if ns.Name == name {
+			return &ns, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("namespaces"), name)
+}
+
+func (m *MockRBACClient) GetServiceAccount(ctx context.Context, namespace, name string) (*corev1.ServiceAccount, error) {
+	for _, sa := range m.ServiceAccounts {
+		if sa.Namespace == namespace && sa.Name == name {
+			return &sa, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("serviceaccounts"), name)
+}
+
+func (m *MockRBACClient) ListServiceAccountTokens(ctx context.Context, namespace string) (*corev1.SecretList, error) {
+	list := &corev1.SecretList{}
+	for _, s := range m.Secrets {
+		if s.Namespace == namespace && s.Type == corev1.SecretTypeServiceAccountToken {
+			list.Items = append(list.Items, s)
+		}
+	}
+	return list, nil
+}
+
+func (m *MockRBACClient) ListPods(ctx context.Context, namespace string) (*corev1.PodList, error) {
+	list := &corev1.PodList{}
+	for _, p := range m.Pods {
+		if p.Namespace == namespace {
+			list.Items = append(list.Items, p)
+		}
+	}
+	return list, nil
+}
+
+func (m *MockRBACClient) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	for _, p := range m.Pods {
+		if p.Namespace == namespace && p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("pods"), name)
+}
+
+func (m *MockRBACClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	for _, d := range m.Deployments {
+		if d.Namespace == namespace && d.Name == name {
+			return &d, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(appsv1.Resource("deployments"), name)
+}
+
+func (m *MockRBACClient) GetStatefulSet(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error) {
+	for _, s := range m.StatefulSets {
+		if s.Namespace == namespace && s.Name == name {
+			return &s, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(appsv1.Resource("statefulsets"), name)
+}
+
+func (m *MockRBACClient) GetDaemonSet(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error) {
+	for _, d := range m.DaemonSets {
+		if d.Namespace == namespace && d.Name == name {
+			return &d, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(appsv1.Resource("daemonsets"), name)
+}
+
+func (m *MockRBACClient) GetJob(ctx context.Context, namespace, name string) (*batchv1.Job, error) {
+	for _, j := range m.Jobs {
+		if j.Namespace == namespace && j.Name == name {
+			return &j, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(batchv1.Resource("jobs"), name)
+}
+
+func (m *MockRBACClient) GetCronJob(ctx context.Context, namespace, name string) (*batchv1.CronJob, error) {
+	for _, c := range m.CronJobs {
+		if c.Namespace == namespace && c.Name == name {
+			return &c, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(batchv1.Resource("cronjobs"), name)
+}
+
+func (m *MockRBACClient) ServerResources(ctx context.Context) ([]*metav1.APIResourceList, error) {
+	if m.ServerResourcesError != nil {
+		return nil, m.ServerResourcesError
+	}
+	return m.Resources, nil
+}
+
+func (m *MockRBACClient) ServerVersion(ctx context.Context) (string, error) {
+	return m.Version, nil
+}
+
+func (m *MockRBACClient) GetConfigMap(ctx context.Context, namespace, name string) (*corev1.ConfigMap, error) {
+	for _, cm := range m.ConfigMaps {
+		if cm.Namespace == namespace && cm.Name == name {
+			return &cm, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+}
+
+func (m *MockRBACClient) CreateSubjectAccessReview(ctx context.Context, review *authorizationv1.SubjectAccessReview) (*authorizationv1.SubjectAccessReview, error) {
+	m.AccessReviews = append(m.AccessReviews, *review)
+	resp := review.DeepCopy()
+	resp.Status = m.AccessReviewStatus
+	return resp, nil
+}
+
+func (m *MockRBACClient) CreateSelfSubjectAccessReview(ctx context.Context, review *authorizationv1.SelfSubjectAccessReview) (*authorizationv1.SelfSubjectAccessReview, error) {
+	resp := review.DeepCopy()
+	resp.Status = m.SelfAccessReviewStatus
+	return resp, nil
+}
+
+func (m *MockRBACClient) CreateSelfSubjectRulesReview(ctx context.Context, review *authorizationv1.SelfSubjectRulesReview) (*authorizationv1.SelfSubjectRulesReview, error) {
+	resp := review.DeepCopy()
+	resp.Status = m.SelfRulesReviewStatus
+	return resp, nil
+}
+
+func (m *MockRBACClient) SelfSubjectReview(ctx context.Context) (*authenticationv1.UserInfo, error) {
+	if m.SelfUser == nil {
+		return nil, apierrors.NewNotFound(authenticationv1.Resource("selfsubjectreviews"), "")
+	}
+	return m.SelfUser, nil
+}
+
+// AddServiceAccount adds a service account, and its namespace, to the mock
+func (m *MockRBACClient) AddServiceAccount(namespace, name string) {
+	m.AddNamespace(namespace)
+	m.ServiceAccounts = append(m.ServiceAccounts, corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	})
+}
+
+// AddNamespace adds a namespace to the mock if it is not already present
+func (m *MockRBACClient) AddNamespace(name string) {
+	for _, ns := range m.Namespaces {
+		if ns.Name == name {
+			return
+		}
+	}
+	m.Namespaces = append(m.Namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Overlay layers proposed changes over another RBACClient, so permissions can
+// be resolved as they would be after a kubectl apply. Objects in Apply replace
+// those of the same name in the base client, or are added; objects in Delete
+// are removed.
+type Overlay struct {
+	base   RBACClient
+	apply  *Snapshot
+	remove *Snapshot
+}
+
+// NewOverlay layers apply and remove over base. Either may be nil.
+func NewOverlay(base RBACClient, apply, remove *Snapshot) *Overlay {
+	if apply == nil {
+		apply = &Snapshot{}
+	}
+	if remove == nil {
+		remove = &Snapshot{}
+	}
+	return &Overlay{base: base, apply: apply, remove: remove}
+}
+
+// objectKey identifies an object by namespace and name
+type objectKey struct{ namespace, name string }
+
+// overridden reports whether the overlay replaces or removes the object
+func overridden(key objectKey, changed ...map[objectKey]bool) bool {
+	for _, keys := range changed {
+		if keys[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func roleKeys(roles []rbacv1.Role) map[objectKey]bool {
+	keys := make(map[objectKey]bool, len(roles))
+	for _, r := range roles {
+		keys[objectKey{r.Namespace, r.Name}] = true
+	}
+	return keys
+}
+
+func clusterRoleKeys(roles []rbacv1.ClusterRole) map[objectKey]bool {
+	keys := make(map[objectKey]bool, len(roles))
+	for _, r := range roles {
+		keys[objectKey{name: r.Name}] = true
+	}
+	return keys
+}
+
+func roleBindingKeys(rbs []rbacv1.RoleBinding) map[objectKey]bool {
+	keys := make(map[objectKey]bool, len(rbs))
+	for _, rb := range rbs {
+		keys[objectKey{rb.Namespace, rb.Name}] = true
+	}
+	return keys
+}
+
+func clusterRoleBindingKeys(crbs []rbacv1.ClusterRoleBinding) map[objectKey]bool {
+	keys := make(map[objectKey]bool, len(crbs))
+	for _, crb := range crbs {
+		keys[objectKey{name: crb.Name}] = true
+	}
+	return keys
+}
+
+func (o *Overlay) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
+	base, err := o.base.ListRoles(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	applied, _ := o.apply.ListRoles(ctx, namespace)
+	applyKeys, removeKeys := roleKeys(o.apply.Roles), roleKeys(o.remove.Roles)
+	list := &rbacv1.RoleList{}
+	for _, r := range base.Items {
+		if !overridden(objectKey{r.Namespace, r.Name}, applyKeys, removeKeys) {
+			list.Items = append(list.Items, r)
+		}
+	}
+	for _, r := range applied.Items {
+		if !removeKeys[objectKey{r.Namespace, r.Name}] {
+			list.Items = append(list.Items, r)
+		}
+	}
+	return list, nil
+}
+
+func (o *Overlay) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+	base, err := o.base.ListClusterRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applyKeys, removeKeys := clusterRoleKeys(o.apply.ClusterRoles), clusterRoleKeys(o.remove.ClusterRoles)
+	list := &rbacv1.ClusterRoleList{}
+	for _, r := range base.Items {
+		if !overridden(objectKey{name: r.Name}, applyKeys, removeKeys) {
+			list.Items = append(list.Items, r)
+		}
+	}
+	for _, r := range o.apply.ClusterRoles {
+		if !removeKeys[objectKey{name: r.Name}] {
+			list.Items = append(list.Items, r)
+		}
+	}
+	return list, nil
+}
+
+func (o *Overlay) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	base, err := o.base.ListRoleBindings(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	applied, _ := o.apply.ListRoleBindings(ctx, namespace)
+	applyKeys, removeKeys := roleBindingKeys(o.apply.RoleBindings), roleBindingKeys(o.remove.RoleBindings)
+	list := &rbacv1.RoleBindingList{}
+	for _, rb := range base.Items {
+		if !overridden(objectKey{rb.Namespace, rb.Name}, applyKeys, removeKeys) {
+			list.Items = append(list.Items, rb)
+		}
+	}
+	for _, rb := range applied.Items {
+		if !removeKeys[objectKey{rb.Namespace, rb.Name}] {
+			list.Items = append(list.Items, rb)
+		}
+	}
+	return list, nil
+}
+
+func (o *Overlay) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+	base, err := o.base.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applyKeys, removeKeys := clusterRoleBindingKeys(o.apply.ClusterRoleBindings), clusterRoleBindingKeys(o.remove.ClusterRoleBindings)
+	list := &rbacv1.ClusterRoleBindingList{}
+	for _, crb := range base.Items {
+		if !overridden(objectKey{name: crb.Name}, applyKeys, removeKeys) {
+			list.Items = append(list.Items, crb)
+		}
+	}
+	for _, crb := range o.apply.ClusterRoleBindings {
+		if !removeKeys[objectKey{name: crb.Name}] {
+			list.Items = append(list.Items, crb)
+		}
+	}
+	return list, nil
+}
+
+func (o *Overlay) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	if roleKeys(o.remove.Roles)[objectKey{namespace, name}] {
+		return nil, apierrors.NewNotFound(rbacv1.Resource("roles"), name)
+	}
+	if role, err := o.apply.GetRole(ctx, namespace, name); err == nil {
+		return role, nil
+	}
+	return o.base.GetRole(ctx, namespace, name)
+}
+
+func (o *Overlay) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	if clusterRoleKeys(o.remove.ClusterRoles)[objectKey{name: name}] {
+		return nil, apierrors.NewNotFound(rbacv1.Resource("clusterroles"), name)
+	}
+	if role, err := o.apply.GetClusterRole(ctx, name); err == nil {
+		return role, nil
+	}
+	return o.base.GetClusterRole(ctx, name)
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultPageSize is how many objects K8sRBACClient asks for per list
+// request
+const DefaultPageSize = 500
+
+// BindingStreamer passes bindings to a callback a page at a time, so callers
+// that keep only a few of them never hold the whole list. It is optional,
+// like SubjectClient.
+type BindingStreamer interface {
+	EachClusterRoleBinding(ctx context.Context, fn func(*rbacv1.ClusterRoleBinding) error) error
+	// EachRoleBinding streams the RoleBindings in namespace, or in every
+	// namespace when it is empty
+	EachRoleBinding(ctx context.Context, namespace string, fn func(*rbacv1.RoleBinding) error) error
+}
+
+// paginate lists the objects what describes a page at a time. list fetches the page opts asks
+// for and returns its items and continue token. When a continue token
+// expires, the listing starts over with a warning, and objects already
+// passed to fn are skipped.
+func paginate[T any](ctx context.Context, c *K8sRBACClient, what string, list func(opts metav1.ListOptions) ([]T, string, error), key func(*T) string, fn func(*T) error) error {
+	seen := make(map[string]bool)
+	opts := metav1.ListOptions{Limit: c.PageSize}
+	for {
+		SetPhase(ctx, "listing %s", what)
+		items, next, err := list(opts)
+		if err != nil && apierrors.IsResourceExpired(err) && opts.Continue != "" {
+			c.warnf("Warning: listing %s took too long and the page token expired; listing again from the start\n", what)
+			opts.Continue = ""
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			k := key(&items[i])
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if err := fn(&items[i]); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		opts.Continue = next
+	}
+}
+
+func (c *K8sRBACClient) warnf(format string, args ...any) {
+	if c.Warnings != nil {
+		_, _ = fmt.Fprintf(c.Warnings, format, args...)
+	}
+}
+
+func objectName(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (c *K8sRBACClient) eachRole(ctx context.Context, namespace string, fn func(*rbacv1.Role) error) error {
+	return paginate(ctx, c, describeList("Roles", namespace), func(opts metav1.ListOptions) ([]rbacv1.Role, string, error) {
+		list, err := c.clientset.RbacV1().Roles(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, func(r *rbacv1.Role) string { return objectName(r.Namespace, r.Name) }, fn)
+}
+
+func (c *K8sRBACClient) eachClusterRole(ctx context.Context, fn func(*rbacv1.ClusterRole) error) error {
+	return paginate(ctx, c, "ClusterRoles", func(opts metav1.ListOptions) ([]rbacv1.ClusterRole, string, error) {
+		list, err := c.clientset.RbacV1().ClusterRoles().List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, func(r *rbacv1.ClusterRole) string { return r.Name }, fn)
+}
+
+func (c *K8sRBACClient) EachRoleBinding(ctx context.Context, namespace string, fn func(*rbacv1.RoleBinding) error) error {
+	return paginate(ctx, c, describeList("RoleBindings", namespace), func(opts metav1.ListOptions) ([]rbacv1.RoleBinding, string, error) {
+		list, err := c.clientset.RbacV1().RoleBindings(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, func(rb *rbacv1.RoleBinding) string { return objectName(rb.Namespace, rb.Name) }, fn)
+}
+
+func (c *K8sRBACClient) EachClusterRoleBinding(ctx context.Context, fn func(*rbacv1.ClusterRoleBinding) error) error {
+	return paginate(ctx, c, "ClusterRoleBindings", func(opts metav1.ListOptions) ([]rbacv1.ClusterRoleBinding, string, error) {
+		list, err := c.clientset.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		if err != nil {
+			return nil, "", err
+		}
+		return list.Items, list.Continue, nil
+	}, func(crb *rbacv1.ClusterRoleBinding) string { return crb.Name }, fn)
+}
+
+// collect returns a function that appends the objects passed to it to items
+func collect[T any](items *[]T) func(*T) error {
+	return func(item *T) error {
+		*items = append(*items, *item)
+		return nil
+	}
+}
+
+// each passes every item to fn, stopping at its first error
+func each[T any](items []T, fn func(*T) error) error {
+	for i := range items {
+		if err := fn(&items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// pagedClusterRoleBindings serves n ClusterRoleBindings a page at a time,
+// with the offset of the next page as the continue token. The token
+// expiresAt, when set, expires the first time it is used.
+type pagedClusterRoleBindings struct {
+	n         int
+	expiresAt string
+	limits    []int64
+}
+
+func (p *pagedClusterRoleBindings) react(action k8stesting.Action) (bool, runtime.Object, error) {
+	opts := action.(k8stesting.ListActionImpl).GetListOptions()
+	p.limits = append(p.limits, opts.Limit)
+	if opts.Continue != "" && opts.Continue == p.expiresAt {
+		p.expiresAt = ""
+		return true, nil, apierrors.NewResourceExpired("continue token expired")
+	}
+	start := 0
+	if opts.Continue != "" {
+		start, _ = strconv.Atoi(opts.Continue)
+	}
+	end := p.n
+	if opts.Limit > 0 {
+		end = min(start+int(opts.Limit), p.n)
+	}
+	list := &rbacv1.ClusterRoleBindingList{}
+	for i := start; i < end; i++ {
+		list.Items = append(list.Items, rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("crb-%d", i)}})
+	}
+	if end < p.n {
+		list.Continue = strconv.Itoa(end)
+	}
+	return true, list, nil
+}
+
+func TestK8sRBACClient_Pagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageSize  int64
+		expiresAt string
+		// wantLimits are the page sizes of the list requests
+		wantLimits  []int64
+		wantWarning bool
+	}{
+		{name: "pages", pageSize: 2, wantLimits: []int64{2, 2, 2}},
+		{name: "unpaginated", pageSize: 0, wantLimits: []int64{0}},
+		{name: "expired continue token", pageSize: 2, expiresAt: "4", wantLimits: []int64{2, 2, 2, 2, 2, 2}, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := &pagedClusterRoleBindings{n: 5, expiresAt: tt.expiresAt}
+			clientset := fake.NewClientset()
+			clientset.PrependReactor("list", "clusterrolebindings", pages.react)
+			var warnings bytes.Buffer
+			c := NewK8sRBACClientFromClientset(clientset)
+			c.PageSize = tt.pageSize
+			c.Warnings = &warnings
+
+			list, err := c.ListClusterRoleBindings(context.Background())
+			if err != nil {
+				t.Fatalf("ListClusterRoleBindings() error = %v", err)
+			}
+			var got []string
+			for _, crb := range list.Items {
+				got = append(got, crb.Name)
+			}
+			want := []string{"crb-0", "crb-1", "crb-2", "crb-3", "crb-4"}
+			if !slices.Equal(got, want) {
+				t.Errorf("listed %v, want each binding once: %v", got, want)
+			}
+			if !slices.Equal(pages.limits, tt.wantLimits) {
+				t.Errorf("requested pages of %v, want %v", pages.limits, tt.wantLimits)
+			}
+			if gotWarning := strings.Contains(warnings.String(), "listing again from the start"); gotWarning != tt.wantWarning {
+				t.Errorf("warnings = %q, want a restart warning: %v", warnings.String(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestK8sRBACClient_EachClusterRoleBinding(t *testing.T) {
+	pages := &pagedClusterRoleBindings{n: 5}
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "clusterrolebindings", pages.react)
+	c := NewK8sRBACClientFromClientset(clientset)
+	c.PageSize = 2
+
+	// Returning an error stops the listing before the next page
+	stop := errors.New("stop")
+	var seen int
+	err := c.EachClusterRoleBinding(context.Background(), func(*rbacv1.ClusterRoleBinding) error {
+		seen++
+		if seen == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("EachClusterRoleBinding() error = %v, want %v", err, stop)
+	}
+	if len(pages.limits) != 1 {
+		t.Errorf("made %d list requests, want 1", len(pages.limits))
+	}
+}
+
+func TestCachedK8sRBACClient_EachClusterRoleBinding(t *testing.T) {
+	pages := &pagedClusterRoleBindings{n: 5}
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "clusterrolebindings", pages.react)
+	base := NewK8sRBACClientFromClientset(clientset)
+	base.PageSize = 2
+	c := NewCachedK8sRBACClient(base, t.TempDir(), time.Minute)
+
+	// Bindings are passed on a page at a time, and a stopped listing isn't cached
+	stop := errors.New("stop")
+	err := c.EachClusterRoleBinding(context.Background(), func(*rbacv1.ClusterRoleBinding) error { return stop })
+	if !errors.Is(err, stop) || len(pages.limits) != 1 {
+		t.Fatalf("EachClusterRoleBinding() error = %v after %d list request(s), want %v after 1", err, len(pages.limits), stop)
+	}
+
+	want := []string{"crb-0", "crb-1", "crb-2", "crb-3", "crb-4"}
+	for _, wantRequests := range []int{4, 4} {
+		var got []string
+		err := c.EachClusterRoleBinding(context.Background(), func(crb *rbacv1.ClusterRoleBinding) error {
+			got = append(got, crb.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("EachClusterRoleBinding() error = %v", err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("streamed %v, want %v", got, want)
+		}
+		// The second run is served from the cache the first one wrote
+		if len(pages.limits) != wantRequests {
+			t.Errorf("made %d list request(s), want %d", len(pages.limits), wantRequests)
+		}
+	}
+	if _, cached := c.Cache.Age(); !cached {
+		t.Error("Age() reports nothing served from the cache")
+	}
+}
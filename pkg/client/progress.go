@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Progress records the call to the cluster that a command started last, so
+// a command that times out can say what it was waiting for
+type Progress struct {
+	mu    sync.Mutex
+	phase string
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose calls record their phase in p
+func WithProgress(ctx context.Context, p *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// SetPhase records a phase, e.g. "listing ClusterRoleBindings", on the
+// Progress of ctx. Once ctx is done the phase is left alone, so it keeps
+// naming the call that was cut short rather than the ones failing after it.
+func SetPhase(ctx context.Context, format string, args ...any) {
+	p, ok := ctx.Value(progressKey{}).(*Progress)
+	if !ok || ctx.Err() != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase = fmt.Sprintf(format, args...)
+}
+
+// Phase returns the phase started last, or "" if there was none
+func (p *Progress) Phase() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+// describeList names the objects of kind in namespace for a phase, e.g.
+// "RoleBindings in namespace prod"
+func describeList(kind, namespace string) string {
+	if namespace == "" {
+		return kind + " in all namespaces"
+	}
+	return kind + " in namespace " + namespace
+}
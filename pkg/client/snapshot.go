@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Snapshot is a point-in-time copy of a cluster's RBAC objects. It implements
+// RBACClient from memory, so analyses that resolve many subjects read the
+// cluster once and all see the same state.
+type Snapshot struct {
+	Roles               []rbacv1.Role
+	ClusterRoles        []rbacv1.ClusterRole
+	RoleBindings        []rbacv1.RoleBinding
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+}
+
+// TakeSnapshot lists every Role, ClusterRole, RoleBinding, and
+// ClusterRoleBinding in the cluster
+func TakeSnapshot(ctx context.Context, c RBACClient) (*Snapshot, error) {
+	roles, err := c.ListRoles(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	clusterRoles, err := c.ListClusterRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster roles: %w", err)
+	}
+	rbs, err := c.ListRoleBindings(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	crbs, err := c.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	return &Snapshot{
+		Roles:               roles.Items,
+		ClusterRoles:        clusterRoles.Items,
+		RoleBindings:        rbs.Items,
+		ClusterRoleBindings: crbs.Items,
+	}, nil
+}
+
+func (s *Snapshot) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
+	list := &rbacv1.RoleList{}
+	for _, role := range s.Roles {
+		if namespace == "" || role.Namespace == namespace {
+			list.Items = append(list.Items, role)
+		}
+	}
+	return list, nil
+}
+
+func (s *Snapshot) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+	return &rbacv1.ClusterRoleList{Items: s.ClusterRoles}, nil
+}
+
+func (s *Snapshot) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	list := &rbacv1.RoleBindingList{}
+	for _, rb := range s.RoleBindings {
+		if namespace == "" || rb.Namespace == namespace {
+			list.Items = append(list.Items, rb)
+		}
+	}
+	return list, nil
+}
+
+func (s *Snapshot) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+	return &rbacv1.ClusterRoleBindingList{Items: s.ClusterRoleBindings}, nil
+}
+
+func (s *Snapshot) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	for i := range s.Roles {
+		if s.Roles[i].Namespace == namespace && s.Roles[i].Name == name {
+			return &s.Roles[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(rbacv1.Resource("roles"), name)
+}
+
+func (s *Snapshot) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	for i := range s.ClusterRoles {
+		if s.ClusterRoles[i].Name == name {
+			return &s.ClusterRoles[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(rbacv1.Resource("clusterroles"), name)
+}
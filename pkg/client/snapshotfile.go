@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Snapshot file identifiers. The version changes when the format does.
+const (
+	SnapshotFileKind    = "RBACSnapshot"
+	SnapshotFileVersion = "rbac-why.io/v1"
+)
+
+// SnapshotFile is a Snapshot saved to disk, with where and when it was taken
+type SnapshotFile struct {
+	APIVersion    string    `json:"apiVersion"`
+	Kind          string    `json:"kind"`
+	Cluster       string    `json:"cluster,omitempty"`
+	ServerVersion string    `json:"serverVersion,omitempty"`
+	CapturedAt    time.Time `json:"capturedAt"`
+
+	Roles               []rbacv1.Role               `json:"roles"`
+	ClusterRoles        []rbacv1.ClusterRole        `json:"clusterRoles"`
+	RoleBindings        []rbacv1.RoleBinding        `json:"roleBindings"`
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding `json:"clusterRoleBindings"`
+
+	// AWSAuth is the kube-system/aws-auth ConfigMap of an EKS cluster, when
+	// it was captured
+	AWSAuth *corev1.ConfigMap `json:"awsAuth,omitempty"`
+}
+
+// NewSnapshotFile wraps s for saving, stamped with the current time
+func NewSnapshotFile(s *Snapshot) *SnapshotFile {
+	return &SnapshotFile{
+		APIVersion:          SnapshotFileVersion,
+		Kind:                SnapshotFileKind,
+		CapturedAt:          time.Now().UTC().Truncate(time.Second),
+		Roles:               s.Roles,
+		ClusterRoles:        s.ClusterRoles,
+		RoleBindings:        s.RoleBindings,
+		ClusterRoleBindings: s.ClusterRoleBindings,
+	}
+}
+
+// Snapshot returns the RBAC objects as an in-memory RBACClient
+func (f *SnapshotFile) Snapshot() *Snapshot {
+	return &Snapshot{
+		Roles:               f.Roles,
+		ClusterRoles:        f.ClusterRoles,
+		RoleBindings:        f.RoleBindings,
+		ClusterRoleBindings: f.ClusterRoleBindings,
+	}
+}
+
+// Describe summarizes what the snapshot was taken of, e.g. "cluster prod
+// (v1.30.2), captured 2026-01-02T15:04:05Z"
+func (f *SnapshotFile) Describe() string {
+	var b strings.Builder
+	if f.Cluster != "" {
+		b.WriteString("cluster " + f.Cluster)
+	} else {
+		b.WriteString("unnamed cluster")
+	}
+	if f.ServerVersion != "" {
+		b.WriteString(" (" + f.ServerVersion + ")")
+	}
+	b.WriteString(", captured " + f.CapturedAt.Format(time.RFC3339))
+	return b.String()
+}
+
+// Write saves the snapshot as indented JSON
+func (f *SnapshotFile) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(f)
+}
+
+// ReadSnapshotFile loads a snapshot saved by SnapshotFile.Write
+func ReadSnapshotFile(path string) (*SnapshotFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	f := &SnapshotFile{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	if f.Kind != SnapshotFileKind {
+		return nil, fmt.Errorf("%s is not an RBAC snapshot (kind %q, expected %s)", path, f.Kind, SnapshotFileKind)
+	}
+	if f.APIVersion != SnapshotFileVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %q in %s (expected %s)", f.APIVersion, path, SnapshotFileVersion)
+	}
+	return f, nil
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const tracerName = "github.com/hardik/kubectl-rbac-why/pkg/client"
+
+// TracedRBACClient records a span for every call to the wrapped client
+type TracedRBACClient struct {
+	client RBACClient
+	tracer trace.Tracer
+}
+
+// NewTracedRBACClient wraps c so that listing and role lookups are traced
+func NewTracedRBACClient(c RBACClient, tp trace.TracerProvider) *TracedRBACClient {
+	return &TracedRBACClient{client: c, tracer: tp.Tracer(tracerName)}
+}
+
+func (c *TracedRBACClient) ListRoles(ctx context.Context, namespace string) (*rbacv1.RoleList, error) {
+	ctx, span := c.start(ctx, "ListRoles", attribute.String("rbac.namespace", namespace))
+	list, err := c.client.ListRoles(ctx, namespace)
+	if list != nil {
+		span.SetAttributes(attribute.Int("rbac.items", len(list.Items)))
+	}
+	end(span, err)
+	return list, err
+}
+
+func (c *TracedRBACClient) ListClusterRoles(ctx context.Context) (*rbacv1.ClusterRoleList, error) {
+	ctx, span := c.start(ctx, "ListClusterRoles")
+	list, err := c.client.ListClusterRoles(ctx)
+	if list != nil {
+		span.SetAttributes(attribute.Int("rbac.items", len(list.Items)))
+	}
+	end(span, err)
+	return list, err
+}
+
+func (c *TracedRBACClient) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	ctx, span := c.start(ctx, "ListRoleBindings", attribute.String("rbac.namespace", namespace))
+	list, err := c.client.ListRoleBindings(ctx, namespace)
+	if list != nil {
+		span.SetAttributes(attribute.Int("rbac.items", len(list.Items)))
+	}
+	end(span, err)
+	return list, err
+}
+
+func (c *TracedRBACClient) ListClusterRoleBindings(ctx context.Context) (*rbacv1.ClusterRoleBindingList, error) {
+	ctx, span := c.start(ctx, "ListClusterRoleBindings")
+	list, err := c.client.ListClusterRoleBindings(ctx)
+	if list != nil {
+		span.SetAttributes(attribute.Int("rbac.items", len(list.Items)))
+	}
+	end(span, err)
+	return list, err
+}
+
+func (c *TracedRBACClient) GetRole(ctx context.Context, namespace, name string) (*rbacv1.Role, error) {
+	ctx, span := c.start(ctx, "GetRole", attribute.String("rbac.namespace", namespace), attribute.String("rbac.name", name))
+	role, err := c.client.GetRole(ctx, namespace, name)
+	end(span, err)
+	return role, err
+}
+
+func (c *TracedRBACClient) GetClusterRole(ctx context.Context, name string) (*rbacv1.ClusterRole, error) {
+	ctx, span := c.start(ctx, "GetClusterRole", attribute.String("rbac.name", name))
+	role, err := c.client.GetClusterRole(ctx, name)
+	end(span, err)
+	return role, err
+}
+
+func (c *TracedRBACClient) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, "rbac."+name, trace.WithAttributes(attrs...))
+}
+
+// end records err on the span, if any, and ends it
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// EachClusterRoleBinding streams from the wrapped client when it can, or
+// from its list otherwise
+func (c *TracedRBACClient) EachClusterRoleBinding(ctx context.Context, fn func(*rbacv1.ClusterRoleBinding) error) error {
+	streamer, ok := c.client.(BindingStreamer)
+	if !ok {
+		list, err := c.ListClusterRoleBindings(ctx)
+		if err != nil {
+			return err
+		}
+		return each(list.Items, fn)
+	}
+	ctx, span := c.start(ctx, "EachClusterRoleBinding")
+	err := streamer.EachClusterRoleBinding(ctx, fn)
+	end(span, err)
+	return err
+}
+
+// EachRoleBinding streams from the wrapped client when it can, like
+// EachClusterRoleBinding
+func (c *TracedRBACClient) EachRoleBinding(ctx context.Context, namespace string, fn func(*rbacv1.RoleBinding) error) error {
+	streamer, ok := c.client.(BindingStreamer)
+	if !ok {
+		list, err := c.ListRoleBindings(ctx, namespace)
+		if err != nil {
+			return err
+		}
+		return each(list.Items, fn)
+	}
+	ctx, span := c.start(ctx, "EachRoleBinding", attribute.String("rbac.namespace", namespace))
+	err := streamer.EachRoleBinding(ctx, namespace, fn)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Kinds of change in an RBACEvent
+const (
+	RBACEventAdded   = "added"
+	RBACEventUpdated = "updated"
+	RBACEventDeleted = "deleted"
+)
+
+// RBACEvent is a change to a Role, ClusterRole, RoleBinding, or
+// ClusterRoleBinding
+type RBACEvent struct {
+	Type      string // RBACEventAdded, RBACEventUpdated, or RBACEventDeleted
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// String describes the event, e.g. "RoleBinding prod/edit updated"
+func (e RBACEvent) String() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s %s %s", e.Kind, e.Name, e.Type)
+	}
+	return fmt.Sprintf("%s %s/%s %s", e.Kind, e.Namespace, e.Name, e.Type)
+}
+
+// WatchClient reports changes to RBAC objects as they happen. It is
+// optional, like SubjectClient.
+type WatchClient interface {
+	// WatchRBAC sends the changes to ClusterRoles and ClusterRoleBindings,
+	// and to the Roles and RoleBindings in namespace unless it is empty,
+	// until ctx is done, when the channel is closed. Objects that exist
+	// when it is called are not reported.
+	WatchRBAC(ctx context.Context, namespace string) (<-chan RBACEvent, error)
+}
+
+// WatchRBAC runs informers on the RBAC objects and returns once their
+// initial lists are complete. Failing to list or watch an object before
+// then is an error; after, informers retry on their own.
+func (c *K8sRBACClient) WatchRBAC(ctx context.Context, namespace string) (<-chan RBACEvent, error) {
+	opts := []informers.SharedInformerOption{}
+	if namespace != "" {
+		opts = append(opts, informers.WithNamespace(namespace))
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0, opts...)
+	v1 := factory.Rbac().V1()
+	watched := map[string]cache.SharedIndexInformer{
+		"ClusterRole":        v1.ClusterRoles().Informer(),
+		"ClusterRoleBinding": v1.ClusterRoleBindings().Informer(),
+	}
+	if namespace != "" {
+		watched["Role"] = v1.Roles().Informer()
+		watched["RoleBinding"] = v1.RoleBindings().Informer()
+	}
+
+	events := make(chan RBACEvent)
+	synced := make(chan struct{})
+	failed := make(chan error, 1)
+	for kind, informer := range watched {
+		if _, err := informer.AddEventHandler(rbacEventHandler(ctx, kind, events)); err != nil {
+			return nil, err
+		}
+		err := informer.SetWatchErrorHandlerWithContext(func(ctx context.Context, r *cache.Reflector, err error) {
+			select {
+			case <-synced:
+				cache.DefaultWatchErrorHandler(ctx, r, err)
+			default:
+				// Only the first error is reported; the others may come
+				// while the informers shut down
+				select {
+				case failed <- fmt.Errorf("failed to watch %ss: %w", kind, err):
+				default:
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	factory.Start(watchCtx.Done())
+	go func() {
+		factory.WaitForCacheSync(watchCtx.Done())
+		close(synced)
+	}()
+	select {
+	case <-synced:
+	case err := <-failed:
+		cancel()
+		factory.Shutdown()
+		return nil, err
+	}
+	// A cancelled ctx also ends the wait for the caches
+	if ctx.Err() != nil {
+		cancel()
+		factory.Shutdown()
+		return nil, ctx.Err()
+	}
+
+	go func() {
+		<-ctx.Done()
+		cancel()
+		factory.Shutdown()
+		close(events)
+	}()
+	return events, nil
+}
+
+// rbacEventHandler sends the changes an informer of kind sees to events,
+// skipping objects in its initial list and resyncs that change nothing
+func rbacEventHandler(ctx context.Context, kind string, events chan<- RBACEvent) cache.ResourceEventHandler {
+	send := func(eventType string, obj any) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		m, ok := obj.(metav1.Object)
+		if !ok {
+			return
+		}
+		select {
+		case events <- RBACEvent{Type: eventType, Kind: kind, Namespace: m.GetNamespace(), Name: m.GetName()}:
+		case <-ctx.Done():
+		}
+	}
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, isInInitialList bool) {
+			if !isInInitialList {
+				send(RBACEventAdded, obj)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			o, oldOK := oldObj.(metav1.Object)
+			n, newOK := newObj.(metav1.Object)
+			if oldOK && newOK && o.GetResourceVersion() == n.GetResourceVersion() {
+				return
+			}
+			send(RBACEventUpdated, newObj)
+		},
+		DeleteFunc: func(obj any) {
+			send(RBACEventDeleted, obj)
+		},
+	}
+}
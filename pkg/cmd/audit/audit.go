@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	rbacaudit "github.com/hardik/kubectl-rbac-why/pkg/audit"
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/config"
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+var (
+	longDesc = `Runs the risky permission analysis once for every subject referenced
+by a ClusterRoleBinding or RoleBinding and reports the findings.
+
+Findings can be grouped by severity, subject, or owner. Owners are the
+Helm release or Argo CD application that manages the binding or role,
+read from its labels, annotations, and owner references. Findings whose
+objects are not attributed to anything are grouped under "unmanaged".`
+
+	examples = `  # Audit the current namespace
+  kubectl rbac-why audit
+
+  # Roll up findings across the cluster per Helm release / Argo CD application
+  kubectl rbac-why audit -A --group-by owner
+
+  # Aggregate counts instead of every finding on a large cluster
+  kubectl rbac-why audit -A --summary
+
+  # Skip namespaces that can't be read instead of aborting the scan
+  kubectl rbac-why audit -A --keep-going
+
+  # Machine-readable output with severity overrides applied
+  kubectl rbac-why audit -A --config rbac-why.yaml -o json`
+)
+
+// AuditOptions contains the options for the audit command
+type AuditOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	AllNamespaces bool
+	Namespace     string
+	GroupBy       string
+	Output        string
+	ConfigFile    string
+	KeepGoing     bool
+	Strict        bool
+	Summary       bool
+
+	// RBACClient, when set, is used instead of connecting to a cluster
+	RBACClient client.RBACClient
+
+	scanOptions rbacaudit.ScanOptions
+
+	genericclioptions.IOStreams
+}
+
+// NewAuditOptions creates new AuditOptions with defaults
+func NewAuditOptions(streams genericclioptions.IOStreams) *AuditOptions {
+	return &AuditOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		GroupBy:     rbacaudit.GroupBySeverity,
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdAudit creates the audit command
+func NewCmdAudit(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewAuditOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "audit [flags]",
+		Short:   "Report risky permissions for every bound subject",
+		Long:    longDesc,
+		Example: examples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", false, "Scan RoleBindings in all namespaces")
+	cmd.Flags().StringVar(&o.GroupBy, "group-by", o.GroupBy, "Group findings by: severity, owner, subject")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json")
+	cmd.Flags().StringVar(&o.ConfigFile, "config", "", "Configuration file with severity override settings")
+	cmd.Flags().BoolVar(&o.KeepGoing, "keep-going", false, "Skip namespaces and subjects that fail to evaluate, with a warning, and mark the results as partial")
+	cmd.Flags().BoolVar(&o.Summary, "summary", false, "Print counts by severity, subject kind, role, and namespace instead of every finding")
+	cmd.Flags().BoolVar(&o.Strict, "strict", false, "With --keep-going, exit with code 3 when the results are partial")
+
+	return cmd
+}
+
+// Complete fills in the namespace and severity overrides
+func (o *AuditOptions) Complete() error {
+	if !o.AllNamespaces {
+		if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+			o.Namespace = *o.ConfigFlags.Namespace
+		} else {
+			ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to determine namespace: %w", err)
+			}
+			o.Namespace = ns
+		}
+	}
+
+	if o.ConfigFile != "" {
+		cfg, err := config.Load(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		o.scanOptions = rbacaudit.ScanOptions{
+			SeverityOverrides:   cfg.SeverityOverrides,
+			SensitiveNamespaces: cfg.SensitiveNamespaces,
+		}
+	}
+	o.scanOptions.KeepGoing = o.KeepGoing
+	return nil
+}
+
+// Validate checks the audit options
+func (o *AuditOptions) Validate() error {
+	switch o.GroupBy {
+	case rbacaudit.GroupBySeverity, rbacaudit.GroupByOwner, rbacaudit.GroupBySubject:
+	default:
+		return fmt.Errorf("invalid --group-by %q (valid: %s, %s, %s)", o.GroupBy,
+			rbacaudit.GroupBySeverity, rbacaudit.GroupByOwner, rbacaudit.GroupBySubject)
+	}
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", o.Output)
+	}
+	if o.Strict && !o.KeepGoing {
+		return fmt.Errorf("--strict requires --keep-going")
+	}
+	return nil
+}
+
+// Run scans the cluster once and prints the grouped findings
+func (o *AuditOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	findings, skipped, err := rbacaudit.Scan(ctx, rbacClient, o.Namespace, o.scanOptions)
+	if err != nil {
+		return err
+	}
+	groups, err := rbacaudit.Group(findings, o.GroupBy)
+	if err != nil {
+		return err
+	}
+
+	var skippedOutput []output.SkippedScopeOutput
+	for _, s := range skipped {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: skipping %s: %v\n", s.Scope, s.Err)
+		skippedOutput = append(skippedOutput, output.SkippedScopeOutput{Scope: s.Scope, Error: s.Err.Error()})
+	}
+
+	switch {
+	case o.Summary:
+		var all []notify.Finding
+		for _, g := range groups {
+			all = append(all, g.Findings...)
+		}
+		summary := output.SummarizeFindings(all)
+		summary.Partial, summary.Skipped = len(skippedOutput) > 0, skippedOutput
+		if o.Output == "json" {
+			err = output.PrintFindingsSummaryJSON(o.Out, summary)
+		} else {
+			output.PrintFindingsSummary(o.Out, summary)
+			output.PrintSkippedScopes(o.Out, skippedOutput)
+		}
+	case o.Output == "json":
+		err = output.PrintFindingGroupsJSON(o.Out, groups, skippedOutput)
+	default:
+		output.PrintFindingGroups(o.Out, groups)
+		output.PrintSkippedScopes(o.Out, skippedOutput)
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.Strict && len(skipped) > 0 {
+		return exitcode.Partial(len(skipped))
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func TestRun_GroupByOwner(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-exec", Namespace: "prod", Annotations: map[string]string{
+			"meta.helm.sh/release-name":      "api",
+			"meta.helm.sh/release-namespace": "prod",
+		}},
+		Subjects: []rbacv1.Subject{{Kind: "ServiceAccount", Name: "api", Namespace: "prod"}},
+		RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "debug-exec", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+
+	out := &bytes.Buffer{}
+	o := NewAuditOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	o.AllNamespaces = true
+	o.GroupBy = "owner"
+	o.Output = "json"
+
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.FindingGroupsOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if got.Partial || len(got.Skipped) != 0 {
+		t.Errorf("partial = %v, skipped = %+v; want complete results", got.Partial, got.Skipped)
+	}
+	groups := got.Groups
+	want := map[string]string{
+		"Helm release prod/api": "system:serviceaccount:prod:api",
+		rbac.UnmanagedOwner:     "alice",
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	if groups[len(groups)-1].Name != rbac.UnmanagedOwner {
+		t.Errorf("last group = %q, want %q", groups[len(groups)-1].Name, rbac.UnmanagedOwner)
+	}
+	for _, g := range groups {
+		subject, ok := want[g.Name]
+		if !ok {
+			t.Errorf("unexpected group %q", g.Name)
+			continue
+		}
+		for _, f := range g.Findings {
+			if f.Subject != subject {
+				t.Errorf("group %q contains finding for %q, want %q", g.Name, f.Subject, subject)
+			}
+		}
+	}
+}
+
+func TestValidate_GroupBy(t *testing.T) {
+	o := NewAuditOptions(genericclioptions.IOStreams{})
+	o.GroupBy = "team"
+	if err := o.Validate(); err == nil {
+		t.Error("Validate() expected error for unknown --group-by, got nil")
+	}
+}
+
+func TestRun_KeepGoing(t *testing.T) {
+	newMock := func() *client.MockRBACClient {
+		mock := client.NewMockRBACClient()
+		mock.AddClusterRole(rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "exec"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}}},
+		})
+		for _, ns := range []string{"dev", "prod"} {
+			mock.AddRoleBinding(rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: ns},
+				Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+			})
+		}
+		mock.ListRoleBindingsErrors = map[string]error{"prod": errors.New("admission webhook denied the request")}
+		return mock
+	}
+
+	tests := []struct {
+		name        string
+		keepGoing   bool
+		strict      bool
+		wantCode    int
+		wantSkipped int
+	}{
+		{name: "aborts by default", wantCode: exitcode.Error},
+		{name: "keep going", keepGoing: true, wantSkipped: 1},
+		{name: "keep going strict", keepGoing: true, strict: true, wantCode: exitcode.Incomplete, wantSkipped: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+			o := NewAuditOptions(genericclioptions.IOStreams{Out: out, ErrOut: errOut})
+			o.RBACClient = newMock()
+			o.AllNamespaces = true
+			o.KeepGoing = tt.keepGoing
+			o.Strict = tt.strict
+			o.Output = "json"
+			if err := o.Complete(); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			err := o.Run(context.Background())
+			if code := exitcode.For(err); code != tt.wantCode {
+				t.Fatalf("Run() error = %v, exit code %d; want %d", err, code, tt.wantCode)
+			}
+			if !tt.keepGoing {
+				return
+			}
+
+			var got output.FindingGroupsOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v", err)
+			}
+			if !got.Partial || len(got.Skipped) != tt.wantSkipped || got.Skipped[0].Scope != "namespace prod" {
+				t.Errorf("partial = %v, skipped = %+v, want namespace prod skipped", got.Partial, got.Skipped)
+			}
+			if len(got.Groups) == 0 {
+				t.Error("expected findings from the namespaces that could be read")
+			}
+			if !strings.Contains(errOut.String(), "Warning: skipping namespace prod") {
+				t.Errorf("stderr = %q, want a warning for namespace prod", errOut.String())
+			}
+		})
+	}
+}
+
+func TestRun_Summary(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "oncall-exec"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "oncall"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+	for _, ns := range []string{"dev", "prod"} {
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}, {Kind: "ServiceAccount", Name: "debug", Namespace: ns}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+		})
+	}
+
+	out := &bytes.Buffer{}
+	o := NewAuditOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	o.AllNamespaces = true
+	o.Summary = true
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.FindingsSummary
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	// Five grants, each raising the same number of risky categories
+	per := got.Total / 5
+	if per == 0 || got.Total%5 != 0 || got.Subjects != 4 {
+		t.Fatalf("total = %d, subjects = %d, want a multiple of 5 findings for 4 subjects", got.Total, got.Subjects)
+	}
+	wantKinds := []output.Count{{Name: "ServiceAccount", Count: 2 * per}, {Name: "User", Count: 2 * per}, {Name: "Group", Count: per}}
+	if fmt.Sprint(got.BySubjectKind) != fmt.Sprint(wantKinds) {
+		t.Errorf("BySubjectKind = %v, want %v", got.BySubjectKind, wantKinds)
+	}
+	wantNamespaces := []output.Count{{Name: "dev", Count: 2 * per}, {Name: "prod", Count: 2 * per}, {Name: "(cluster-wide)", Count: per}}
+	if fmt.Sprint(got.Namespaces) != fmt.Sprint(wantNamespaces) {
+		t.Errorf("Namespaces = %v, want %v", got.Namespaces, wantNamespaces)
+	}
+}
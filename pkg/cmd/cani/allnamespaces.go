@@ -0,0 +1,55 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// runAllNamespaces evaluates the check in every namespace the cluster has,
+// or in those matching --namespace-selector
+func (o *RbacWhyOptions) runAllNamespaces(ctx context.Context, rbacClient client.RBACClient, resolver *rbac.Resolver, subject rbac.Subject) error {
+	namespaces, err := o.listNamespaces(ctx, rbacClient)
+	if err != nil {
+		return err
+	}
+
+	result, err := resolver.ResolveInNamespaces(ctx, subject, o.ToPermissionRequest(), namespaces)
+	if err != nil {
+		return fmt.Errorf("failed to resolve permission: %w", err)
+	}
+	result.Selector = o.NamespaceSelector
+
+	if o.Quiet {
+		return o.printQuiet(allowedAnywhere(result))
+	}
+
+	switch o.Output {
+	case "json":
+		err = output.PrintAllNamespacesJSON(o.Out, result)
+	case "yaml":
+		err = output.PrintAllNamespacesYAML(o.Out, result)
+	case "dot":
+		err = output.PrintAllNamespacesDot(o.Out, result)
+	case "mermaid":
+		err = output.PrintAllNamespacesMermaid(o.Out, result)
+	default:
+		output.PrintAllNamespaces(o.Out, result)
+	}
+	if err != nil {
+		return err
+	}
+	return o.verdict(allowedAnywhere(result))
+}
+
+// allowedAnywhere reports whether the check is allowed in at least one namespace
+func allowedAnywhere(result *rbac.AllNamespacesResult) bool {
+	if result.BypassedVia != "" || result.ClusterWideAllowed() {
+		return true
+	}
+	return slices.ContainsFunc(result.Namespaces, func(ns rbac.NamespaceResult) bool { return ns.Allowed })
+}
@@ -0,0 +1,95 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// ApplyRoleVerb is the pseudo-verb that checks whether a proposed Role,
+// ClusterRole, or binding passes the API server's escalation check
+const ApplyRoleVerb = "apply-role"
+
+// loadRBACObject reads a Role, ClusterRole, RoleBinding, or ClusterRoleBinding
+// manifest from path, or from in when path is "-"
+func loadRBACObject(path string, in io.Reader) (interface{}, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(in)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if meta.APIVersion != rbacv1.SchemeGroupVersion.String() {
+		return nil, fmt.Errorf("unsupported apiVersion %q (expected %s)", meta.APIVersion, rbacv1.SchemeGroupVersion)
+	}
+
+	var obj interface{}
+	switch meta.Kind {
+	case "Role":
+		obj = &rbacv1.Role{}
+	case "ClusterRole":
+		obj = &rbacv1.ClusterRole{}
+	case "RoleBinding":
+		obj = &rbacv1.RoleBinding{}
+	case "ClusterRoleBinding":
+		obj = &rbacv1.ClusterRoleBinding{}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q (expected Role, ClusterRole, RoleBinding, or ClusterRoleBinding)", meta.Kind)
+	}
+	if err := yaml.UnmarshalStrict(data, obj); err != nil {
+		return nil, fmt.Errorf("invalid %s manifest: %w", meta.Kind, err)
+	}
+	return obj, nil
+}
+
+// runApplyRole checks whether the subject may create the proposed object
+func (o *RbacWhyOptions) runApplyRole(ctx context.Context, resolver *rbac.Resolver, subject rbac.Subject) error {
+	check, err := resolver.CheckEscalation(ctx, subject, o.ProposedObject, o.Namespace)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return output.PrintEscalationCheckJSON(o.Out, check)
+	}
+	output.PrintEscalationCheck(o.Out, check)
+	return nil
+}
+
+// runEscalationCheck checks whether the subject can get around escalation
+// prevention with the escalate or bind verbs. Like a single check, it exits
+// 0 when the subject can.
+func (o *RbacWhyOptions) runEscalationCheck(ctx context.Context, resolver *rbac.Resolver, subject rbac.Subject) error {
+	check, err := resolver.CheckEscalationVerbs(ctx, subject, o.Namespace)
+	if err != nil {
+		return err
+	}
+	switch o.Output {
+	case "json":
+		err = output.PrintEscalationVerbsJSON(o.Out, check)
+	case "yaml":
+		err = output.PrintEscalationVerbsYAML(o.Out, check)
+	default:
+		output.PrintEscalationVerbs(o.Out, check)
+	}
+	if err != nil {
+		return err
+	}
+	return o.verdict(check.CanEscalate() || check.CanBind())
+}
@@ -6,9 +6,12 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
 )
 
 // AWSAuthMapping represents a mapping entry in the aws-auth ConfigMap
@@ -35,17 +38,22 @@ func ResolveAWSAuthIdentity(ctx context.Context, restConfig *rest.Config, iamArn
 	}
 
 	// Read the aws-auth ConfigMap from kube-system namespace
+	client.SetPhase(ctx, "fetching ConfigMap kube-system/aws-auth")
 	cm, err := clientset.CoreV1().ConfigMaps("kube-system").Get(ctx, "aws-auth", metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get aws-auth ConfigMap: %w", err)
 	}
+	return awsAuthIdentityFromConfigMap(cm, iamArn), nil
+}
 
+// awsAuthIdentityFromConfigMap looks up an IAM ARN in a loaded aws-auth ConfigMap
+func awsAuthIdentityFromConfigMap(cm *corev1.ConfigMap, iamArn string) *AWSAuthIdentity {
 	// Parse mapRoles
 	if mapRolesData, ok := cm.Data["mapRoles"]; ok {
 		var mappings []AWSAuthMapping
 		if err := yaml.Unmarshal([]byte(mapRolesData), &mappings); err == nil {
 			if identity := findMappingForArn(mappings, iamArn, true); identity != nil {
-				return identity, nil
+				return identity
 			}
 		}
 	}
@@ -55,7 +63,7 @@ func ResolveAWSAuthIdentity(ctx context.Context, restConfig *rest.Config, iamArn
 		var mappings []AWSAuthMapping
 		if err := yaml.Unmarshal([]byte(mapUsersData), &mappings); err == nil {
 			if identity := findMappingForArn(mappings, iamArn, false); identity != nil {
-				return identity, nil
+				return identity
 			}
 		}
 	}
@@ -66,7 +74,7 @@ func ResolveAWSAuthIdentity(ctx context.Context, restConfig *rest.Config, iamArn
 		Username: iamArn,
 		Groups:   nil,
 		Found:    false,
-	}, nil
+	}
 }
 
 // findMappingForArn searches for a matching ARN in the mappings
@@ -0,0 +1,187 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/batch"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// runBatch evaluates every check from the --checks-file inputs and fails if
+// any check does not match its expected verdict. Text and ndjson results are
+// written as each check completes.
+func (o *RbacWhyOptions) runBatch(ctx context.Context, resolver *rbac.Resolver, defaultSubject rbac.Subject) error {
+	var results []batch.Result
+	var skipped []output.SkippedScopeOutput
+	for _, path := range o.ChecksFiles {
+		fileResults, fileSkipped, err := o.runChecksFile(ctx, resolver, defaultSubject, path)
+		results = append(results, fileResults...)
+		skipped = append(skipped, fileSkipped...)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch o.Output {
+	case "junit":
+		if err := output.PrintJUnit(o.Out, results); err != nil {
+			return err
+		}
+	case "text":
+		output.PrintBatchSummary(o.Out, results)
+		output.PrintSkippedScopes(o.Out, skipped)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return exitcode.Failure(fmt.Errorf("%d of %d check(s) failed", failed, len(results)))
+	}
+	if o.Strict && len(skipped) > 0 {
+		return exitcode.Partial(len(skipped))
+	}
+	return nil
+}
+
+// printJUnitCheck reports a single check as a JUnit report with one suite
+// of one test, so CI sees the same format as for --checks-file. Like the
+// exit code, the test expects the check to be allowed; a resolveErr is
+// reported as a test error and returned.
+func (o *RbacWhyOptions) printJUnitCheck(subject rbac.Subject, request rbac.PermissionRequest, result *rbac.PermissionResult, resolveErr error, elapsed time.Duration) error {
+	check := batch.Check{
+		As:        subject.AsString(),
+		Verb:      request.Verb,
+		Resource:  checkResource(request),
+		Namespace: request.Namespace,
+		Expect:    batch.ExpectAllowed,
+	}
+	res := batch.Result{Check: check, Source: "can-i", Result: result, Err: resolveErr, Duration: elapsed}
+	if err := output.PrintJUnit(o.Out, []batch.Result{res}); err != nil {
+		return err
+	}
+	if resolveErr != nil {
+		return fmt.Errorf("failed to resolve permission: %w", resolveErr)
+	}
+	return o.verdict(result.Allowed)
+}
+
+// checkResource writes request's resource the way a checks file gives it,
+// e.g. deployments.apps/web/scale
+func checkResource(request rbac.PermissionRequest) string {
+	if request.NonResourceURL != "" {
+		return request.NonResourceURL
+	}
+	resource := request.Resource
+	if request.APIGroup != "" {
+		resource += "." + request.APIGroup
+	}
+	if request.ResourceName != "" {
+		resource += "/" + request.ResourceName
+	}
+	if request.Subresource != "" {
+		resource += "/" + request.Subresource
+	}
+	return resource
+}
+
+// runChecksFile streams the checks in path ("-" for stdin) through runCheck.
+// Malformed checks abort the run unless --keep-going is set, in which case
+// they are skipped with a warning and returned, and the remaining checks
+// still run.
+func (o *RbacWhyOptions) runChecksFile(ctx context.Context, resolver *rbac.Resolver, defaultSubject rbac.Subject, path string) ([]batch.Result, []output.SkippedScopeOutput, error) {
+	source := path
+	var r io.Reader = o.In
+	if path == "-" {
+		source = "stdin"
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read checks file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	var results []batch.Result
+	var skipped []output.SkippedScopeOutput
+	err := batch.ReadChecks(r, func(e batch.Entry) error {
+		if e.Err != nil {
+			if !o.KeepGoing {
+				return e.Err
+			}
+			scope := fmt.Sprintf("check at %s line %d", source, e.Line)
+			msg := strings.TrimPrefix(e.Err.Error(), fmt.Sprintf("line %d: ", e.Line))
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: skipping %s: %s\n", scope, msg)
+			skipped = append(skipped, output.SkippedScopeOutput{Scope: scope, Error: msg})
+			return nil
+		}
+		res := o.runCheck(ctx, resolver, defaultSubject, source, e.Check)
+		res.Line = e.Line
+		results = append(results, res)
+
+		switch o.Output {
+		case "ndjson":
+			return output.PrintBatchResultNDJSON(o.Out, res)
+		case "text":
+			output.PrintBatchResult(o.Out, res)
+		}
+		return nil
+	})
+	if err != nil {
+		return results, skipped, fmt.Errorf("%s: %w", source, err)
+	}
+	return results, skipped, nil
+}
+
+// runCheck evaluates a single check, defaulting the subject and namespace
+// to those of the invocation
+func (o *RbacWhyOptions) runCheck(ctx context.Context, resolver *rbac.Resolver, defaultSubject rbac.Subject, source string, check batch.Check) (res batch.Result) {
+	start := time.Now()
+	res = batch.Result{Check: check, Source: source}
+	defer func() {
+		res.Duration = time.Since(start)
+	}()
+
+	subject := defaultSubject
+	if check.As != "" {
+		parsed, err := rbac.ParseSubject(check.As)
+		if err != nil {
+			res.Err = fmt.Errorf("failed to parse subject: %w", err)
+			return res
+		}
+		subject = parsed
+	}
+
+	parsed, err := parseResourceArg(check.Resource)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	namespace := check.Namespace
+	if namespace == "" && parsed.NonResourceURL == "" {
+		namespace = o.Namespace
+	}
+
+	res.Result, res.Err = resolver.ResolvePermission(ctx, subject, rbac.PermissionRequest{
+		Verb:           check.Verb,
+		APIGroup:       parsed.APIGroup,
+		Resource:       parsed.Resource,
+		Subresource:    parsed.Subresource,
+		ResourceName:   parsed.ResourceName,
+		Namespace:      namespace,
+		NonResourceURL: parsed.NonResourceURL,
+	})
+	return res
+}
@@ -2,16 +2,35 @@ package cani
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
 
+	"github.com/hardik/kubectl-rbac-why/pkg/audit"
 	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/discovery"
+	"github.com/hardik/kubectl-rbac-why/pkg/manifest"
 	"github.com/hardik/kubectl-rbac-why/pkg/output"
 	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+	"github.com/hardik/kubectl-rbac-why/pkg/tracing"
 )
 
+const tracerName = "github.com/hardik/kubectl-rbac-why/pkg/cmd/cani"
+
 var (
 	longDesc = `Explains why a permission is granted in Kubernetes RBAC.
 
@@ -24,7 +43,18 @@ yes/no, it shows you the complete RBAC chain that grants
 the permission.
 
 If --as is not specified, the tool uses the current kubeconfig
-context to determine the subject.`
+context to determine the subject.
+
+The kind of an --as subject is guessed from its name:
+system:serviceaccount:NAMESPACE:NAME is a ServiceAccount, other
+system: names are Groups, and anything else is a User. Prefix it
+with user:, group:, or sa: (sa:NAMESPACE:NAME or
+sa://NAMESPACE/NAME) to choose the kind.
+
+--as-uid sets the subject's UID, and --as-user-extra its extra
+attributes. RBAC matching ignores both, so they never change the
+result, but --verify and -o sar put them in the SubjectAccessReview
+for webhook authorizers that use them.`
 
 	examples = `  # Check why the current user can get secrets (uses current kubeconfig context)
   kubectl rbac-why can-i get secrets -n default
@@ -32,12 +62,36 @@ context to determine the subject.`
   # Check why a specific service account can get secrets
   kubectl rbac-why can-i --as system:serviceaccount:default:my-sa get secrets -n default
 
+  # Same check using the --sa shorthand (namespace defaults to -n or the context namespace)
+  kubectl rbac-why can-i --sa default/my-sa get secrets -n default
+
+  # Check as a group whose name doesn't start with system:, e.g. from OIDC
+  kubectl rbac-why can-i --as group:platform-team list deployments -n prod
+
   # Check cluster-wide permissions for listing nodes
   kubectl rbac-why can-i --as system:serviceaccount:kube-system:admin list nodes
 
+  # Branch on the answer in a script
+  if kubectl rbac-why can-i -q --sa default/my-sa get secrets -n default; then echo ok; fi
+
   # Check pod exec permissions for current user
   kubectl rbac-why can-i create pods/exec -n default
 
+  # The same, with the subresource as a flag like kubectl auth can-i
+  kubectl rbac-why can-i create pods --subresource exec -n default
+
+  # Short names and Kinds are mapped to the served resource (deploy → deployments.apps)
+  kubectl rbac-why can-i --sa prod/api get deploy -n prod
+
+  # Check several verbs at once, one row per verb
+  kubectl rbac-why can-i --sa default/my-sa get,list,watch,delete pods -n default
+
+  # Show every namespace where a service account can list secrets
+  kubectl rbac-why can-i --sa ci/deployer list secrets -A
+
+  # Check access to one specific secret
+  kubectl rbac-why can-i get secrets/db-password -n prod
+
   # Output as JSON for programmatic use
   kubectl rbac-why can-i get pods -o json
 
@@ -51,7 +105,61 @@ context to determine the subject.`
   kubectl rbac-why can-i --as system:serviceaccount:default:my-sa --show-risky -n default
 
   # Show risky permissions for current user
-  kubectl rbac-why can-i --show-risky -n default`
+  kubectl rbac-why can-i --show-risky -n default
+
+  # Fail when a risky finding is high severity or worse, using custom severities
+  kubectl rbac-why can-i --sa default/my-sa --show-risky --fail-on high --config rbac-why.yaml
+
+  # Emit risky findings as GitHub Actions annotations
+  kubectl rbac-why can-i --as system:serviceaccount:default:my-sa --show-risky -n default -o gha
+
+  # Check the permission behind a request from a proxy or audit log
+  kubectl rbac-why can-i --sa prod/api --request-path 'GET /apis/apps/v1/namespaces/prod/deployments'
+
+  # Explain a SubjectAccessReview exactly as written (user and groups are not derived)
+  kubectl rbac-why can-i -f sar.yaml -o json
+
+  # Add group memberships from an IdP the cluster can't be asked about
+  kubectl rbac-why can-i get nodes --as jane@platform.example.com --groups-file groups.yaml
+
+  # Check whether a service account may create this Role without escalating privileges
+  kubectl rbac-why can-i apply-role -f role.yaml --sa ci/deployer -n prod
+
+  # Evaluate a file of expected allowed/denied checks and emit a JUnit report
+  kubectl rbac-why can-i --checks-file checks.yaml -o junit > rbac-report.xml
+
+  # Stream generated checks through stdin, one JSON result per line
+  generate-checks | kubectl rbac-why can-i --checks-file - -o ndjson --keep-going
+
+  # Show every binding examined and why each rule matched or not
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod --trace --skip-system-bindings
+
+  # Log why each binding was skipped and which part of each rule failed to match
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod --debug
+
+  # Print the check as a SubjectAccessReview and ask the API server
+  kubectl rbac-why can-i --sa default/api get secrets -n default -o sar --no-exit-code | kubectl create -o yaml -f -
+
+  # Print just the names of the bindings that grant the check, one per line
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod -o go-template='{{range .grants}}{{.binding.name}}{{"\n"}}{{end}}'
+
+  # Print kubectl commands to reproduce the check and inspect each binding and role
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod --show-commands
+
+  # Cross-check the answer with the API server's own SubjectAccessReview
+  kubectl rbac-why can-i --sa default/api get secrets -n default --verify
+
+  # Print the Role and RoleBinding that would allow a denied check, and apply them
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod --suggest | kubectl apply -f -
+
+  # Show a narrower rule for grants that only match through a wildcard
+  kubectl rbac-why can-i --sa prod/api get configmaps -n prod --suggest-least-privilege
+
+  # Keep a terminal open during an incident to see when a fix lands
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod --watch
+
+  # Compare client-side resolution with the API server's SelfSubjectRulesReview
+  kubectl rbac-why can-i --server-rules -n default`
 )
 
 // NewCmdRbacWhy creates the rbac-why root command
@@ -69,14 +177,32 @@ func NewCmdRbacWhy(streams genericclioptions.IOStreams) *cobra.Command {
 			if len(args) > 0 && args[0] == "can-i" {
 				args = args[1:]
 			}
+			if len(args) > 0 && args[0] == ApplyRoleVerb {
+				o.ApplyRole = true
+				args = args[1:]
+			}
 
-			if err := o.Complete(args); err != nil {
+			// --watch runs until stopped, so only a single check is bounded
+			timeout := o.Timeout
+			if o.Watch {
+				timeout = 0
+			}
+			ctx, progress, cancel := withTimeout(cmd.Context(), timeout)
+			defer cancel()
+			if err := o.CompleteContext(ctx, args); err != nil {
 				return err
 			}
 			if err := o.Validate(); err != nil {
 				return err
 			}
-			return o.Run(cmd.Context())
+			// Usage is only helpful for argument errors, not evaluation failures
+			cmd.SilenceUsage = true
+			err := timeoutError(ctx, o.Run(ctx), o.Timeout, progress)
+			// A denied result has been printed; only the exit code is left
+			if errors.Is(err, exitcode.ErrDenied) {
+				cmd.SilenceErrors = true
+			}
+			return err
 		},
 	}
 
@@ -84,41 +210,133 @@ func NewCmdRbacWhy(streams genericclioptions.IOStreams) *cobra.Command {
 	o.ConfigFlags.AddFlags(cmd.Flags())
 
 	// Add our custom flags
-	cmd.Flags().StringVarP(&o.Output, "output", "o", "text", "Output format: text, json, yaml, dot, mermaid")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "text", fmt.Sprintf("Output format: %s, gha (GitHub Actions annotations, --show-risky only), junit (--checks-file or a single check), ndjson (--checks-file only)", strings.Join(output.Formats(), ", ")))
+	cmd.Flags().StringVar(&o.Template, "template", "", "Template, or template file, for -o go-template or -o go-template-file; fields are named as in -o json, e.g. {{.allowed}}")
+	cmd.Flags().BoolVar(&o.NoHeaders, "no-headers", false, "With -o csv, leave out the header row so outputs can be concatenated")
 	cmd.Flags().BoolVar(&o.ShowRisky, "show-risky", false, "Analyze and show risky permissions for the subject")
+	cmd.Flags().StringVar(&o.FailOn, "fail-on", "", "With --show-risky, exit with an error if any finding is at or above this severity (critical, high, medium, low)")
+	cmd.Flags().StringVar(&o.ConfigFile, "config", "", "Configuration file (severityOverrides for --show-risky)")
+	cmd.Flags().StringVar(&o.FromSnapshot, "from-snapshot", "", "Resolve against a file saved by the snapshot command instead of the cluster")
+	cmd.Flags().StringArrayVar(&o.FromFiles, "from-file", nil, "Resolve against the Role, ClusterRole, RoleBinding, and ClusterRoleBinding manifests in this file or directory instead of a cluster (repeatable)")
+	cmd.Flags().StringVarP(&o.Filename, "filename", "f", "", "SubjectAccessReview or LocalSubjectAccessReview manifest to check, or the Role/binding for apply-role (- for stdin)")
+	cmd.Flags().StringVar(&o.RequestPath, "request-path", "", "Check the permission an API request needs, e.g. 'GET /api/v1/namespaces/prod/pods/api-123/log'")
+	cmd.Flags().StringArrayVar(&o.ChecksFiles, "checks-file", nil, "YAML or ndjson file of permission checks with expected verdicts to evaluate in batch, - for stdin (repeatable)")
+	cmd.Flags().BoolVar(&o.AutoCorrect, "auto-correct", false, "Check what a kubectl command used as the verb (exec, logs, port-forward, ...) actually needs, e.g. create pods/exec for exec")
+	cmd.Flags().BoolVar(&o.KeepGoing, "keep-going", false, "Skip malformed checks, with a warning, and mark the results as partial (--checks-file only)")
+	cmd.Flags().BoolVar(&o.Strict, "strict", false, "With --keep-going, exit with code 3 when the results are partial")
+	cmd.Flags().StringVar(&o.UsageFrom, "usage-from", "", "API server audit log file or directory; report the subject's grants that its logged requests never exercised")
+	cmd.Flags().BoolVar(&o.Trace, "trace", false, "Show every binding examined, whether it applies to the subject, and why each of its rules matched or not")
+	cmd.Flags().BoolVar(&o.Debug, "debug", false, "Log to stderr whether each binding applies to the subject, through which subject or group, and which part of each rule fails to match")
+	cmd.Flags().IntVarP(&o.Verbosity, "v", "v", 0, "Log verbosity, as with kubectl; any level above 0 is the same as --debug")
+	cmd.Flags().BoolVar(&o.SkipSystemBindings, "skip-system-bindings", false, "With --trace, leave system:* bindings out of the trace")
+	cmd.Flags().BoolVar(&o.NoDedupe, "no-dedupe", false, "Show a path for every matching rule instead of one per binding and role")
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", false, "Evaluate the check in every namespace and report where it is allowed")
+	cmd.Flags().IntVar(&o.Concurrency, "concurrency", rbac.DefaultConcurrency, "How many namespaces -A and --namespace-selector evaluate at once")
+	cmd.Flags().StringVar(&o.NamespaceSelector, "namespace-selector", "", "Evaluate the check, or --show-risky, in each namespace whose labels match this selector, e.g. team=payments")
+	cmd.Flags().BoolVar(&o.EscalationCheck, "escalation-check", false, "Check whether the subject can create roles with escalate, or bindings with bind, in the namespace (cluster-wide without -n), and show the path that grants each")
+	cmd.Flags().BoolVar(&o.ShowImpersonation, "show-impersonation", false, "List the users, groups, ServiceAccounts, UIDs, and user extras the subject can impersonate, and the grant behind each")
+	cmd.Flags().BoolVar(&o.Verify, "verify", false, "Cross-check the result with a SubjectAccessReview and warn if the API server decides differently")
+	cmd.Flags().BoolVar(&o.ShowCommands, "show-commands", false, "Print the kubectl auth can-i command that asks the API server the same question, and the kubectl get and describe commands for each path's binding and role")
+	cmd.Flags().BoolVar(&o.SuggestLeastPrivilege, "suggest-least-privilege", false, "For grants that match only through a wildcard verb, API group, or resource, show the narrower rule that would still allow the check")
+	cmd.Flags().BoolVar(&o.Any, "any", false, "With several comma-separated verbs, exit 0 when any verb is allowed instead of all")
+	cmd.Flags().StringVar(&o.SubresourceFlag, "subresource", "", "Subresource to check, as with kubectl auth can-i (e.g. status, scale, log, exec); same as RESOURCE/SUBRESOURCE")
+	cmd.Flags().BoolVarP(&o.Quiet, "quiet", "q", false, "Print only yes or no, like kubectl auth can-i --quiet; warnings still go to stderr")
+	cmd.Flags().BoolVar(&o.NoExitCode, "no-exit-code", false, "Exit 0 when the check is denied; by default DENIED exits 1 and errors exit 2, like kubectl auth can-i")
+	cmd.Flags().BoolVar(&o.Verbose, "verbose", false, "Print the resource, version, and API group the RESOURCE argument was parsed as")
+	cmd.Flags().BoolVar(&o.SkipNamespaceCheck, "skip-namespace-check", false, "Use the namespace without checking that it exists, e.g. when you can't get namespaces or it isn't created yet")
+	cmd.Flags().BoolVar(&o.NoNormalize, "no-normalize", false, "Check the resource exactly as typed instead of mapping short names, singulars, and Kinds (deploy, pod, Deployment) to the plural resource and group discovery serves")
+	cmd.Flags().BoolVar(&o.Suggest, "suggest", false, "When denied, print a minimal Role and RoleBinding (or ClusterRole and ClusterRoleBinding) granting the check; in text mode the YAML goes to stdout and the result to stderr")
+	cmd.Flags().BoolVar(&o.Watch, "watch", false, "Keep running and evaluate the check again whenever a Role, ClusterRole, or binding it could depend on changes")
+	cmd.Flags().BoolVar(&o.ServerRules, "server-rules", false, "Compare client-side resolution against SelfSubjectRulesReview (current context only)")
+	cmd.Flags().StringVar(&o.GroupsFile, "groups-file", "", "YAML file mapping usernames or glob patterns to groups, added to the subject's groups")
+	cmd.Flags().StringVar(&o.ServiceAccount, "sa", "", "ServiceAccount to check as NAMESPACE/NAME or NAME (shorthand for --as system:serviceaccount:...)")
+	cmd.Flags().StringArrayVar(&o.ExtraGroups, "extra-group", nil, "Group to add to the subject's groups, e.g. an OIDC group claim; can be repeated and, unlike --as-group, works without --as")
+	cmd.Flags().BoolVar(&o.IncludeImplicitGroups, "include-implicit-groups", true, "Add the implicit groups system:authenticated and system:serviceaccounts[:NAMESPACE]; set to false to evaluate only the explicit groups")
+	cmd.Flags().StringVar(&o.Pod, "pod", "", "Pod to check as NAMESPACE/NAME or NAME; the subject is the ServiceAccount the Pod runs as")
+	cmd.Flags().StringVar(&o.Workload, "workload", "", "Workload to check as KIND/NAMESPACE/NAME or KIND/NAME, where KIND is deployment, statefulset, daemonset, job, or cronjob; the subject is the ServiceAccount of its Pod template")
 	cmd.Flags().StringVarP(&o.AWSProfile, "profile", "p", "", "AWS profile to use for authentication (for EKS clusters)")
+	o.Listing.AddFlags(cmd.Flags(), o.ConfigFlags)
+	addTimeoutFlag(cmd.Flags(), &o.Timeout)
+
+	_ = cmd.RegisterFlagCompletionFunc("sa", completeServiceAccountNames(o))
+	_ = cmd.RegisterFlagCompletionFunc("subresource", cobra.FixedCompletions(discovery.BuiltinSubresources(), cobra.ShellCompDirectiveNoFileComp))
 
 	return cmd
 }
 
 // Run executes the rbac-why command
 func (o *RbacWhyOptions) Run(ctx context.Context) error {
-	// Create Kubernetes client WITHOUT impersonation
-	// We need to read RBAC resources with the actual user's permissions,
-	// not as the subject being checked
-	savedImpersonate := o.ConfigFlags.Impersonate
-	savedImpersonateGroup := o.ConfigFlags.ImpersonateGroup
-	savedImpersonateUID := o.ConfigFlags.ImpersonateUID
+	tp := o.TracerProvider
+	if tp == nil {
+		var shutdown func(context.Context) error
+		var err error
+		tp, shutdown, err = tracing.Setup(ctx)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = shutdown(context.WithoutCancel(ctx)) }()
+	}
+	tracer := tp.Tracer(tracerName)
 
-	// Temporarily clear impersonation settings
-	emptyString := ""
-	o.ConfigFlags.Impersonate = &emptyString
-	o.ConfigFlags.ImpersonateGroup = &[]string{}
-	o.ConfigFlags.ImpersonateUID = &emptyString
+	ctx, span := tracer.Start(ctx, "rbac-why.can-i")
+	defer span.End()
 
-	restConfig, err := o.ConfigFlags.ToRESTConfig()
-	if err != nil {
-		return fmt.Errorf("failed to create REST config: %w", err)
+	// Read RBAC objects from the injected client when embedding as a library,
+	// from local manifests with --from-file, otherwise from the cluster
+	rbacClient := o.RBACClient
+	var restConfig *rest.Config
+	var snapshotFile *client.SnapshotFile
+	if rbacClient == nil && o.FromSnapshot != "" {
+		var err error
+		snapshotFile, err = client.ReadSnapshotFile(o.FromSnapshot)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(o.ErrOut, "Evaluating against snapshot of %s\n", snapshotFile.Describe())
+		snapshot := snapshotFile.Snapshot()
+		o.aggregateClusterRoles(snapshot)
+		rbacClient = snapshot
+	} else if rbacClient == nil && len(o.FromFiles) > 0 {
+		namespace := o.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		snapshot, warnings, err := manifest.Load(o.FromFiles, namespace)
+		if err != nil {
+			return err
+		}
+		for _, w := range warnings {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", w)
+		}
+		o.aggregateClusterRoles(snapshot)
+		rbacClient = snapshot
+	} else if rbacClient == nil {
+		var err error
+		restConfig, err = o.restConfigWithoutImpersonation()
+		if err != nil {
+			return err
+		}
+
+		rbacClient, err = o.Listing.newClient(restConfig, o.ErrOut)
+		if err != nil {
+			return err
+		}
+		defer o.Listing.printAge(o.ErrOut)
 	}
 
-	// Restore impersonation settings
-	o.ConfigFlags.Impersonate = savedImpersonate
-	o.ConfigFlags.ImpersonateGroup = savedImpersonateGroup
-	o.ConfigFlags.ImpersonateUID = savedImpersonateUID
+	idCtx, identitySpan := tracer.Start(ctx, "rbac-why.identity")
 
-	// For AWS IAM auth, resolve the actual K8s identity from aws-auth ConfigMap
-	if !o.AsProvided && o.CurrentContext != nil && o.CurrentContext.AuthMethod == "aws-iam" && o.CurrentContext.AWSIamArn != "" {
-		identity, err := ResolveAWSAuthIdentity(ctx, restConfig, o.CurrentContext.AWSIamArn)
+	// For AWS IAM auth, resolve the actual K8s identity from aws-auth ConfigMap,
+	// or from the copy saved in a snapshot
+	awsAuthAvailable := restConfig != nil || (snapshotFile != nil && snapshotFile.AWSAuth != nil)
+	if awsAuthAvailable && !o.AsProvided && o.CurrentContext != nil && o.CurrentContext.AuthMethod == "aws-iam" && o.CurrentContext.AWSIamArn != "" {
+		var identity *AWSAuthIdentity
+		var err error
+		if restConfig != nil {
+			identity, err = ResolveAWSAuthIdentity(idCtx, restConfig, o.CurrentContext.AWSIamArn)
+		} else {
+			identity = awsAuthIdentityFromConfigMap(snapshotFile.AWSAuth, o.CurrentContext.AWSIamArn)
+		}
 		if err != nil {
 			// Log warning but continue with IAM ARN as username
 			_, _ = fmt.Fprintf(o.ErrOut, "Warning: failed to read aws-auth ConfigMap: %v\n", err)
@@ -136,11 +354,21 @@ func (o *RbacWhyOptions) Run(ctx context.Context) error {
 		}
 	}
 
+	if o.workload.kind != "" {
+		if err := o.resolveWorkloadSubject(idCtx, rbacClient); err != nil {
+			identitySpan.End()
+			return err
+		}
+	}
+
 	// Parse the subject
 	subject, err := rbac.ParseSubject(o.As)
 	if err != nil {
+		identitySpan.End()
 		return fmt.Errorf("failed to parse subject: %w", err)
 	}
+	subject.Origin = o.SubjectOrigin
+	o.hintServiceAccount(subject)
 
 	// If we extracted groups from the current context (e.g., from client certificate or aws-auth),
 	// add them to the subject so they're used in RBAC resolution
@@ -148,56 +376,813 @@ func (o *RbacWhyOptions) Run(ctx context.Context) error {
 		subject.Groups = o.CurrentContext.Groups
 	}
 
-	rbacClient, err := client.NewK8sRBACClient(restConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create RBAC client: %w", err)
+	// --as-group adds to the groups of the --as or --sa subject
+	subject.Groups = append(subject.Groups, o.AsGroups...)
+	if o.AsUID != "" {
+		subject.UID = o.AsUID
+	}
+	subject.Extra = o.AsUserExtra
+
+	// A SubjectAccessReview's user and groups are used exactly as given
+	if o.Review != nil {
+		subject = subjectFromReview(o.Review)
+		subject.Origin = o.SubjectOrigin
+	}
+
+	if o.GroupMapping != nil {
+		o.GroupMapping.Apply(&subject)
+	}
+
+	subject.Groups = append(subject.Groups, o.ExtraGroups...)
+	if !o.IncludeImplicitGroups {
+		subject.ExactGroups = true
+	}
+
+	identitySpan.SetAttributes(
+		attribute.String("rbac.subject.kind", subject.Kind),
+		attribute.Int("rbac.subject.groups", len(subject.Groups)),
+		attribute.Bool("rbac.subject.from_context", !o.AsProvided),
+	)
+	identitySpan.End()
+
+	if rbac.IsNode(subject) {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", rbac.NodeAuthorizerWarning(subject).Message)
+	}
+
+	resolverOpts := []rbac.ResolverOption{rbac.WithTracerProvider(tp), rbac.WithConcurrency(o.Concurrency)}
+	if o.Trace {
+		resolverOpts = append(resolverOpts, rbac.WithEvaluationTrace())
+	}
+	if o.NoDedupe {
+		resolverOpts = append(resolverOpts, rbac.WithoutDedupe())
+	}
+	if o.Debug || o.Verbosity > 0 {
+		logger := slog.New(slog.NewTextHandler(o.ErrOut, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		resolverOpts = append(resolverOpts, rbac.WithLogger(logger))
+	}
+	resolver := rbac.NewResolver(rbacClient, resolverOpts...)
+
+	if o.ApplyRole {
+		return o.runApplyRole(ctx, resolver, subject)
+	}
+	if o.EscalationCheck {
+		return o.runEscalationCheck(ctx, resolver, subject)
+	}
+	if o.ShowImpersonation {
+		return o.runImpersonation(ctx, resolver, subject)
 	}
 
-	resolver := rbac.NewResolver(rbacClient)
+	// A "*" resource audits wildcard rules, with or without --show-risky
+	if o.wildcardAudit() {
+		return o.runWildcardAudit(ctx, resolver, subject)
+	}
 
 	// Handle --show-risky flag
+	if o.ShowRisky && o.NamespaceSelector != "" {
+		return o.runSelectedRiskyAnalysis(ctx, rbacClient, resolver, subject)
+	}
 	if o.ShowRisky {
-		return o.runRiskyAnalysis(ctx, resolver, subject)
+		return o.runRiskyAnalysis(ctx, rbacClient, resolver, subject)
+	}
+
+	// Handle --usage-from audit log comparison
+	if o.UsageFrom != "" {
+		return o.runUsageAnalysis(ctx, resolver, subject)
+	}
+
+	// Handle --checks-file batch mode
+	if len(o.ChecksFiles) > 0 {
+		// Checks look up bindings over and over, so they are indexed once
+		// when every binding can be listed
+		if idx, err := rbac.NewBindingIndex(ctx, rbacClient); err == nil {
+			resolver = rbac.NewResolver(rbacClient, append(resolverOpts, rbac.WithBindingIndex(idx))...)
+		}
+		return o.runBatch(ctx, resolver, subject)
+	}
+
+	// Handle --server-rules flag
+	if o.ServerRules {
+		reviewer, ok := rbacClient.(client.SelfReviewClient)
+		if !ok {
+			return fmt.Errorf("--server-rules requires a live cluster connection")
+		}
+		return o.runServerRulesComparison(ctx, reviewer, resolver, subject)
+	}
+
+	o.resolveVersion(ctx, rbacClient)
+	o.normalizeResource(ctx, rbacClient)
+	o.disambiguateName(ctx, rbacClient)
+	if o.Verbose {
+		o.printParsedResource()
+	}
+	if err := o.scopeNamespace(ctx, rbacClient); err != nil {
+		return err
+	}
+	if err := o.checkNamespace(ctx, rbacClient); err != nil {
+		return err
+	}
+	if o.acrossNamespaces() {
+		return o.runAllNamespaces(ctx, rbacClient, resolver, subject)
+	}
+	if o.Watch {
+		return o.runWatch(ctx, rbacClient, resolver, subject)
+	}
+
+	if len(o.Verbs) > 1 {
+		return o.runVerbs(ctx, rbacClient, subject, resolverOpts)
 	}
 
 	// Normal permission check
 	request := o.ToPermissionRequest()
+	unknown, discoveryErr := o.unknownResource(ctx, rbacClient, request)
+	if unknown != "" {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", unknown)
+	}
+	o.warnUnknownSubresource(ctx, rbacClient, request)
+	start := time.Now()
 	result, err := resolver.ResolvePermission(ctx, subject, request)
+	if reviewer, ok := o.selfReviewFallback(rbacClient, err); ok {
+		result, err = resolveWithSelfAccessReview(ctx, reviewer, subject, request, err)
+	}
+	if o.Output == "junit" {
+		return o.printJUnitCheck(subject, request, result, err, time.Since(start))
+	}
 	if err != nil {
 		return fmt.Errorf("failed to resolve permission: %w", err)
 	}
+	if unknown != "" {
+		result.Warnings = append(result.Warnings, rbac.Warning{Code: rbac.WarningUnknownResource, Message: unknown})
+	}
+	if discoveryErr != nil {
+		result.Diagnostics = append(result.Diagnostics, rbac.DiscoveryDiagnostic(discoveryErr))
+	}
+	o.warnClusterScoped(result)
+
+	o.warnDeprecatedGroups(ctx, rbacClient, resolver, subject, request, result)
+	if o.Trace {
+		o.filterTrace(result)
+	}
+
+	if o.Verify {
+		if err := o.verify(ctx, rbacClient, subject, request, result); err != nil {
+			return err
+		}
+	}
+
+	if o.SuggestLeastPrivilege {
+		for i, g := range result.Grants {
+			if len(g.Wildcards) > 0 {
+				narrowed := rbac.NarrowRule(g.MatchingRule, request)
+				result.Grants[i].NarrowedRule = &narrowed
+			}
+		}
+	}
+	if o.Suggest && !result.Allowed {
+		result.Suggestion = rbac.SuggestGrant(subject, request)
+	}
+	if o.ShowCommands {
+		result.Commands = []string{rbac.CanICommand(subject, request, o.AsProvided)}
+		for i, g := range result.Grants {
+			result.Grants[i].Commands = rbac.GrantCommands(g)
+		}
+	}
+
+	// Echo the review back with its status for traceability
+	if o.Review != nil {
+		review := o.Review.DeepCopy()
+		review.Status = reviewStatus(result)
+		result.Review = review
+	}
+
+	if o.Quiet {
+		if mismatch := output.VerificationMismatch(result); mismatch != "" {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", mismatch)
+		}
+		return o.printQuiet(result.Allowed)
+	}
 
 	// Print result
-	printer, err := output.NewPrinter(o.Output)
+	printer, err := output.NewPrinterWithOptions(o.Output, output.PrinterOptions{NoHeaders: o.NoHeaders, Template: o.templateText})
 	if err != nil {
 		return err
 	}
 
-	// Convert context info for output if using current context
-	var ctxInfo *output.ContextInfo
-	if !o.AsProvided && o.CurrentContext != nil {
-		ctxInfo = &output.ContextInfo{
-			ContextName: o.CurrentContext.ContextName,
-			ClusterName: o.CurrentContext.ClusterName,
-			AuthInfo:    o.CurrentContext.AuthInfo,
-			UserName:    o.CurrentContext.UserName,
-			Groups:      o.CurrentContext.Groups,
-			AuthMethod:  o.CurrentContext.AuthMethod,
-			Namespace:   o.CurrentContext.Namespace,
+	ctxInfo := o.contextInfo()
+
+	_, outputSpan := tracer.Start(ctx, "rbac-why.output", trace.WithAttributes(attribute.String("rbac.output", o.Output)))
+	defer outputSpan.End()
+	// With --suggest, stdout is kept for the manifest so it can be piped to kubectl
+	out := o.Out
+	if o.Suggest && o.Output == "text" {
+		out = o.ErrOut
+	}
+	if err := printer.Print(out, result, ctxInfo); err != nil {
+		return err
+	}
+	if o.Trace && o.Output == "text" {
+		_, _ = fmt.Fprintln(out)
+		output.PrintTrace(out, result.Trace)
+	}
+	if result.Verification != nil {
+		if o.Output == "text" {
+			_, _ = fmt.Fprintln(out)
+			output.PrintVerification(out, result)
+		} else if mismatch := output.VerificationMismatch(result); mismatch != "" {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", mismatch)
+		}
+	}
+	if o.Output == "text" {
+		output.PrintCommands(out, result)
+	}
+	if o.SuggestLeastPrivilege && o.Output == "text" && len(result.Grants) > 0 && !slices.ContainsFunc(result.Grants, func(g rbac.PermissionGrant) bool { return g.NarrowedRule != nil }) {
+		_, _ = fmt.Fprintf(out, "No path matches through a wildcard; nothing to narrow.\n")
+	}
+	if result.Suggestion != nil && o.Output == "text" {
+		if err := output.PrintSuggestion(o.Out, result.Suggestion); err != nil {
+			return err
 		}
 	}
+	return o.verdict(result.Allowed)
+}
+
+// contextInfo converts the current context for output, when the subject
+// was read from it rather than given with --as
+func (o *RbacWhyOptions) contextInfo() *output.ContextInfo {
+	if o.AsProvided || o.CurrentContext == nil {
+		return nil
+	}
+	return &output.ContextInfo{
+		ContextName: o.CurrentContext.ContextName,
+		ClusterName: o.CurrentContext.ClusterName,
+		AuthInfo:    o.CurrentContext.AuthInfo,
+		UserName:    o.CurrentContext.UserName,
+		Groups:      o.CurrentContext.Groups,
+		AuthMethod:  o.CurrentContext.AuthMethod,
+		Namespace:   o.CurrentContext.Namespace,
+	}
+}
+
+// verdict is the error Run returns for a printed answer: ErrDenied when it is
+// no, unless --no-exit-code is set
+func (o *RbacWhyOptions) verdict(allowed bool) error {
+	if !allowed && !o.NoExitCode {
+		return exitcode.ErrDenied
+	}
+	return nil
+}
+
+// hintServiceAccount points out that an --as value shaped like
+// NAMESPACE/NAME is checked as a User, since that is a common way to mistype
+// a ServiceAccount
+func (o *RbacWhyOptions) hintServiceAccount(subject rbac.Subject) {
+	if !o.AsProvided || subject.Kind != "User" || o.SubjectOrigin != "" {
+		return
+	}
+	namespace, name, found := strings.Cut(subject.Name, "/")
+	if !found || len(validation.IsDNS1123Label(namespace)) > 0 || len(validation.IsDNS1123Subdomain(name)) > 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(o.ErrOut, "Note: %s is checked as a User; for a ServiceAccount use --sa %s or --as sa:%s:%s\n", subject.Name, subject.Name, namespace, name)
+}
+
+// aggregateClusterRoles fills in the rules of aggregated ClusterRoles in an
+// offline snapshot, which no aggregation controller has done
+func (o *RbacWhyOptions) aggregateClusterRoles(snapshot *client.Snapshot) {
+	if names := rbac.AggregateClusterRoles(snapshot.ClusterRoles); len(names) > 0 {
+		_, _ = fmt.Fprintf(o.ErrOut, "Note: computed the rules of aggregated ClusterRole(s) %s from their aggregationRule\n", strings.Join(names, ", "))
+	}
+}
 
-	return printer.Print(o.Out, result, ctxInfo)
+// printQuiet prints only yes or no, like kubectl auth can-i --quiet
+func (o *RbacWhyOptions) printQuiet(allowed bool) error {
+	answer := "no"
+	if allowed {
+		answer = "yes"
+	}
+	_, _ = fmt.Fprintln(o.Out, answer)
+	return o.verdict(allowed)
+}
+
+// unknownResource describes a resource discovery doesn't list, such as a typo
+// or a CRD that isn't installed, with the served resources and groups it
+// might have meant. The check still runs, since RBAC rules can name a resource
+// before its CRD is installed. It returns "" for a served resource, or when
+// discovery isn't available, along with the error reading it if any.
+func (o *RbacWhyOptions) unknownResource(ctx context.Context, rbacClient client.RBACClient, request rbac.PermissionRequest) (string, error) {
+	dc, ok := rbacClient.(client.DiscoveryClient)
+	if !ok || request.NonResourceURL != "" || request.Resource == "*" {
+		return "", nil
+	}
+	lists, err := dc.ServerResources(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(lists) == 0 {
+		return "", nil
+	}
+	if _, found := discovery.Subresources(lists, request.APIGroup, request.Resource); found {
+		return "", nil
+	}
+
+	typed := schema.GroupResource{Group: request.APIGroup, Resource: request.Resource}
+	msg := fmt.Sprintf("the cluster doesn't serve %s.", typed)
+
+	// A bare resource is compared by name, and one with a group by its full
+	// name, so a typo in the group is caught too
+	var candidates []string
+	display := make(map[string]string)
+	for _, gr := range discovery.Resources(lists) {
+		key := gr.Resource
+		if request.APIGroup != "" {
+			key = gr.String()
+		}
+		if _, ok := display[key]; !ok {
+			display[key] = gr.String()
+			candidates = append(candidates, key)
+		}
+	}
+	similar := discovery.Similar(typed.String(), candidates, 3)
+	for i, c := range similar {
+		similar[i] = display[c]
+	}
+	// A short name or Kind checked with --no-normalize
+	if normalized, ok := discovery.Normalize(lists, request.APIGroup, request.Resource); ok {
+		similar = []string{normalized.String()}
+	}
+	if len(similar) > 0 {
+		msg += fmt.Sprintf(" Did you mean %s?", strings.Join(similar, ", "))
+	}
+
+	groups := discovery.ServedGroups(lists)
+	if request.APIGroup != "" && !slices.Contains(groups, request.APIGroup) {
+		msg += fmt.Sprintf(" No API group %s is served", request.APIGroup)
+		if similar := discovery.Similar(request.APIGroup, groups, 3); len(similar) > 0 {
+			msg += fmt.Sprintf(" (similar: %s)", strings.Join(similar, ", "))
+		}
+		msg += "; is its CRD installed?"
+	}
+	return msg + " The check still runs, as RBAC rules can name a resource before its CRD is installed.", nil
+}
+
+// resolveVersion checks the version the RESOURCE argument named against
+// discovery. When the group isn't served but the version and group together
+// name one that is, as for a CRD group like v1.example.com, the argument is
+// read that way instead. A version the group doesn't serve is only warned
+// about, since RBAC rules don't name versions.
+func (o *RbacWhyOptions) resolveVersion(ctx context.Context, rbacClient client.RBACClient) {
+	dc, ok := rbacClient.(client.DiscoveryClient)
+	if !ok || o.apiVersion == "" || o.NonResourceURL != "" {
+		return
+	}
+	lists, err := dc.ServerResources(ctx)
+	if err != nil || len(lists) == 0 {
+		return
+	}
+	served := func(group string) bool {
+		if _, found := discovery.Subresources(lists, group, o.Resource); found {
+			return true
+		}
+		_, found := discovery.Normalize(lists, group, o.Resource)
+		return found
+	}
+	if dotted := o.apiVersion + "." + o.APIGroup; o.APIGroup != "" && !served(o.APIGroup) && served(dotted) {
+		_, _ = fmt.Fprintf(o.ErrOut, "Note: %s is in API group %s; %s is not a version here\n", o.Resource, dotted, o.apiVersion)
+		o.APIGroup, o.apiVersion = dotted, ""
+		return
+	}
+	gv := schema.GroupVersion{Group: o.APIGroup, Version: o.apiVersion}.String()
+	for _, list := range lists {
+		if list.GroupVersion == gv {
+			return
+		}
+	}
+	if slices.Contains(discovery.ServedGroups(lists), o.APIGroup) {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: the cluster doesn't serve %s; RBAC rules don't name versions, so the check still runs\n", gv)
+	}
+}
+
+// printParsedResource prints the resource, version, and API group the
+// RESOURCE argument resolved to
+func (o *RbacWhyOptions) printParsedResource() {
+	if o.resourceArg == "" || o.NonResourceURL != "" {
+		return
+	}
+	version, group := o.apiVersion, o.APIGroup
+	if version == "" {
+		version = "(any)"
+	}
+	if group == "" {
+		group = "core"
+	}
+	_, _ = fmt.Fprintf(o.ErrOut, "Parsed %s as resource %s, version %s, API group %s\n", o.resourceArg, o.Resource, version, group)
+}
+
+// scopeNamespace fits the namespace to the scope discovery lists for the
+// resource. Like kubectl, a namespaced resource is checked in "default" when
+// no namespace is given, rather than cluster-wide where only
+// ClusterRoleBindings apply. A cluster-scoped resource can't be checked with
+// -n, and the context namespace doesn't apply to it.
+func (o *RbacWhyOptions) scopeNamespace(ctx context.Context, rbacClient client.RBACClient) error {
+	if o.exactNamespace || o.NonResourceURL != "" || o.Resource == "*" {
+		return nil
+	}
+	typed := schema.GroupResource{Group: o.APIGroup, Resource: o.Resource}
+	var lists []*metav1.APIResourceList
+	if dc, ok := rbacClient.(client.DiscoveryClient); ok {
+		lists, _ = dc.ServerResources(ctx)
+	}
+	if len(lists) == 0 {
+		o.scopeNamespaceBuiltin(typed)
+		return nil
+	}
+	namespaced, found := discovery.Namespaced(lists, o.APIGroup, o.Resource)
+	if !found {
+		return nil
+	}
+	switch {
+	case namespaced && o.Namespace == "" && !o.acrossNamespaces():
+		o.defaultNamespace(typed)
+	case !namespaced && o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "":
+		return fmt.Errorf("%s is cluster-scoped and cannot be checked in a namespace; drop -n %s", typed, *o.ConfigFlags.Namespace)
+	case !namespaced && o.AllNamespaces:
+		return fmt.Errorf("%s is cluster-scoped, so --all-namespaces does not apply to it", typed)
+	case !namespaced && o.NamespaceSelector != "":
+		return fmt.Errorf("%s is cluster-scoped, so --namespace-selector does not apply to it", typed)
+	case !namespaced:
+		o.Namespace = ""
+	}
+	return nil
+}
+
+// scopeNamespaceBuiltin is scopeNamespace without discovery, as with
+// --from-file: a built-in namespaced resource is still checked in "default",
+// and for a resource the built-in table doesn't know a warning says that
+// only cluster-wide grants are checked.
+func (o *RbacWhyOptions) scopeNamespaceBuiltin(typed schema.GroupResource) {
+	if o.Namespace != "" || o.acrossNamespaces() {
+		return
+	}
+	namespaced, found := rbac.BuiltinNamespaced(typed.Group, typed.Resource)
+	switch {
+	case namespaced:
+		o.defaultNamespace(typed)
+	case !found:
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: no namespace given, and without discovery it isn't known whether %s is namespaced, so only cluster-wide grants are checked; use -n to check a namespace\n", typed)
+	}
+}
+
+// defaultNamespace checks a namespaced resource in "default", like kubectl
+func (o *RbacWhyOptions) defaultNamespace(typed schema.GroupResource) {
+	o.Namespace = "default"
+	_, _ = fmt.Fprintf(o.ErrOut, "Note: no namespace given, so %s is checked in namespace default (like kubectl); use -n to choose one or -A to check every namespace\n", typed)
+}
+
+// warnClusterScoped repeats on stderr the resolver's warning that the
+// RoleBindings in the namespace were left out for a cluster-scoped resource
+func (o *RbacWhyOptions) warnClusterScoped(result *rbac.PermissionResult) {
+	for _, w := range result.Warnings {
+		if w.Code == rbac.WarningClusterScoped {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", w.Message)
+		}
+	}
+}
+
+// warnUnknownSubresource warns when discovery lists the resource but not the
+// requested subresource, which would otherwise just show up as DENIED.
+// Discovery can lag behind newly installed CRDs, so this is never an error.
+func (o *RbacWhyOptions) warnUnknownSubresource(ctx context.Context, rbacClient client.RBACClient, request rbac.PermissionRequest) {
+	dc, ok := rbacClient.(client.DiscoveryClient)
+	if !ok || request.Subresource == "" || request.Subresource == "*" || request.Resource == "*" {
+		return
+	}
+	lists, err := dc.ServerResources(ctx)
+	if err != nil {
+		return
+	}
+	valid, found := discovery.Subresources(lists, request.APIGroup, request.Resource)
+	if !found || slices.Contains(valid, request.Subresource) {
+		return
+	}
+
+	_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s has no subresource %q", request.Resource, request.Subresource)
+	if len(valid) > 0 {
+		_, _ = fmt.Fprintf(o.ErrOut, " (valid: %s)", strings.Join(valid, ", "))
+	}
+	if suggestion := discovery.Closest(request.Subresource, valid); suggestion != "" {
+		_, _ = fmt.Fprintf(o.ErrOut, "; did you mean %s/%s?", request.Resource, suggestion)
+	}
+	_, _ = fmt.Fprintln(o.ErrOut)
+}
+
+// normalizeResource maps a short name, singular, or Kind such as "deploy" to
+// the plural resource and group discovery serves, which is what RBAC rules
+// name, and notes the mapping. A bare resource gets its group from discovery
+// too, with a warning when several groups serve it. Without discovery the
+// resource is left as typed.
+func (o *RbacWhyOptions) normalizeResource(ctx context.Context, rbacClient client.RBACClient) {
+	dc, ok := rbacClient.(client.DiscoveryClient)
+	if !ok || o.NoNormalize || o.NonResourceURL != "" || o.Resource == "*" {
+		return
+	}
+	lists, err := dc.ServerResources(ctx)
+	if err != nil {
+		return
+	}
+	bare := o.APIGroup == ""
+	if normalized, ok := discovery.Normalize(lists, o.APIGroup, o.Resource); ok {
+		typed := schema.GroupResource{Group: o.APIGroup, Resource: o.Resource}
+		_, _ = fmt.Fprintf(o.ErrOut, "Note: %s → %s\n", typed, normalized)
+		o.Resource, o.APIGroup = normalized.Resource, normalized.Group
+	}
+	if groups := discovery.Groups(lists, o.Resource); bare && len(groups) > 1 {
+		names := make([]string, 0, len(groups))
+		other := ""
+		for _, g := range groups {
+			if g == "" {
+				g = "core"
+			} else if g != o.APIGroup && other == "" {
+				other = g
+			}
+			names = append(names, g)
+		}
+		checked := schema.GroupResource{Group: o.APIGroup, Resource: o.Resource}
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s is served by several API groups (%s); checking %s, name the group to check another, e.g. %s.%s\n",
+			o.Resource, strings.Join(names, ", "), checked, o.Resource, other)
+	}
+}
+
+// disambiguateName checks a RESOURCE/NAME argument against discovery: a name
+// the resource serves as a subresource, such as a CRD's custom subresource,
+// is checked as that subresource, and one a typo away from a subresource
+// prints a warning
+func (o *RbacWhyOptions) disambiguateName(ctx context.Context, rbacClient client.RBACClient) {
+	dc, ok := rbacClient.(client.DiscoveryClient)
+	if !ok || !o.nameFromArg {
+		return
+	}
+	lists, err := dc.ServerResources(ctx)
+	if err != nil {
+		return
+	}
+	valid, found := discovery.Subresources(lists, o.APIGroup, o.Resource)
+	if !found {
+		return
+	}
+	if slices.Contains(valid, o.ResourceName) {
+		o.Subresource, o.ResourceName = o.ResourceName, ""
+		return
+	}
+	if suggestion := discovery.Closest(o.ResourceName, valid); suggestion != "" {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s has no subresource %q (valid: %s), so it is checked as an object name; did you mean %s/%s?\n",
+			o.Resource, o.ResourceName, strings.Join(valid, ", "), o.Resource, suggestion)
+	}
+}
+
+// warnDeprecatedGroups warns about matched rules, and for denied requests
+// rules that would match in another API group, that reference a group the
+// cluster no longer serves for the resource, such as extensions/deployments
+func (o *RbacWhyOptions) warnDeprecatedGroups(ctx context.Context, rbacClient client.RBACClient, resolver *rbac.Resolver, subject rbac.Subject, request rbac.PermissionRequest, result *rbac.PermissionResult) {
+	if request.Resource == "*" || request.NonResourceURL != "" {
+		return
+	}
+	var lists []*metav1.APIResourceList
+	if dc, ok := rbacClient.(client.DiscoveryClient); ok {
+		lists, _ = dc.ServerResources(ctx)
+	}
+
+	candidates := result.Grants
+	if !result.Allowed {
+		grants, err := resolver.ResolveAllPermissions(ctx, subject, request.Namespace)
+		if err != nil {
+			return
+		}
+		candidates = grants
+	}
+
+	warned := make(map[string]bool)
+	for _, g := range candidates {
+		for _, group := range g.MatchingRule.APIGroups {
+			if group == request.APIGroup {
+				continue
+			}
+			msg := discovery.DeadReference(lists, group, request.FullResource())
+			there := request
+			there.APIGroup = group
+			if msg == "" || !rbac.RuleMatches(g.MatchingRule, there) {
+				continue
+			}
+			key := fmt.Sprintf("%s/%s/%s#%d/%s", g.Role.Kind, g.Role.Namespace, g.Role.Name, g.RuleIndex, group)
+			if warned[key] {
+				continue
+			}
+			warned[key] = true
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s/%s rules[%d]: %s\n", g.Role.Kind, g.Role.Name, g.RuleIndex, msg)
+		}
+	}
+}
+
+// restConfigWithoutImpersonation builds a REST config for the caller's own
+// identity. RBAC objects must be read with the actual user's permissions,
+// not as the subject being checked.
+func (o *RbacWhyOptions) restConfigWithoutImpersonation() (*rest.Config, error) {
+	savedImpersonate := o.ConfigFlags.Impersonate
+	savedImpersonateGroup := o.ConfigFlags.ImpersonateGroup
+	savedImpersonateUID := o.ConfigFlags.ImpersonateUID
+	savedImpersonateUserExtra := o.ConfigFlags.ImpersonateUserExtra
+
+	// Temporarily clear impersonation settings
+	emptyString := ""
+	o.ConfigFlags.Impersonate = &emptyString
+	o.ConfigFlags.ImpersonateGroup = &[]string{}
+	o.ConfigFlags.ImpersonateUID = &emptyString
+	o.ConfigFlags.ImpersonateUserExtra = &[]string{}
+
+	restConfig, err := o.ConfigFlags.ToRESTConfig()
+
+	// Restore impersonation settings
+	o.ConfigFlags.Impersonate = savedImpersonate
+	o.ConfigFlags.ImpersonateGroup = savedImpersonateGroup
+	o.ConfigFlags.ImpersonateUID = savedImpersonateUID
+	o.ConfigFlags.ImpersonateUserExtra = savedImpersonateUserExtra
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create REST config: %w", err)
+	}
+	return restConfig, nil
+}
+
+// noteUnusedNamespace notes when -n was given to --show-risky but no
+// RoleBinding in that namespace applies to the subject, so the namespace
+// doesn't change the analysis and only cluster-wide grants are analyzed
+func (o *RbacWhyOptions) noteUnusedNamespace(subject rbac.Subject, grants []rbac.PermissionGrant, dangling []rbac.DanglingBinding) {
+	if o.ConfigFlags.Namespace == nil || *o.ConfigFlags.Namespace == "" {
+		return
+	}
+	for _, g := range grants {
+		if g.Scope == rbac.ScopeNamespace {
+			return
+		}
+	}
+	for _, d := range dangling {
+		if d.Binding.Namespace != "" {
+			return
+		}
+	}
+	_, _ = fmt.Fprintf(o.ErrOut, "Note: no RoleBindings in namespace %s apply to %s, so -n doesn't change the analysis; only cluster-wide grants are analyzed\n", o.Namespace, subject)
 }
 
 // runRiskyAnalysis shows risky permissions for a subject
-func (o *RbacWhyOptions) runRiskyAnalysis(ctx context.Context, resolver *rbac.Resolver, subject rbac.Subject) error {
+func (o *RbacWhyOptions) runRiskyAnalysis(ctx context.Context, rbacClient client.RBACClient, resolver *rbac.Resolver, subject rbac.Subject) error {
+	if err := o.checkNamespace(ctx, rbacClient); err != nil {
+		return err
+	}
 	grants, err := resolver.ResolveAllPermissions(ctx, subject, o.Namespace)
+	var notice string
+	if reviewer, ok := o.selfReviewFallback(rbacClient, err); ok {
+		// Without read access, analyze the rules the API server reports instead
+		namespace := o.Namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		notice = limitation("SelfSubjectRulesReview in namespace "+namespace, err)
+		var serverRules *ServerRules
+		if serverRules, err = FetchServerRules(ctx, reviewer, namespace); err == nil {
+			grants = rbac.GrantsFromRules(serverRules.Rules, namespace)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to resolve permissions: %w", err)
 	}
 
-	risks := output.AnalyzeRiskyPermissions(grants)
-	output.PrintRiskyPermissions(o.Out, risks)
+	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "rbac-why.risky-analysis")
+	risks := output.AnalyzeRiskyPermissionsWithOverrides(grants, o.SeverityOverrides)
+	// A system:masters member is allowed everything whatever its bindings say
+	if group := rbac.BypassingGroup(subject, rbac.GetImplicitGroups(subject)); group != "" {
+		risks = append([]rbac.RiskyPermission{output.SuperuserRisk(group)}, risks...)
+	}
+	span.SetAttributes(
+		attribute.Int("rbac.grants", len(grants)),
+		attribute.Int("rbac.risks", len(risks)),
+	)
+	span.End()
+
+	// A stolen token makes every risky permission easier to abuse
+	risks = output.RaiseForExposure(risks, o.tokenExposure(ctx, rbacClient, subject))
+
+	// Bindings to missing roles grant nothing, but are worth cleaning up.
+	// Without read access there are no bindings to check.
+	var dangling []rbac.DanglingBinding
+	if notice == "" {
+		if dangling, err = resolver.DanglingBindings(ctx, &subject, o.Namespace); err != nil {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: failed to check for dangling bindings: %v\n", err)
+		}
+	}
+
+	if notice == "" {
+		o.noteUnusedNamespace(subject, grants, dangling)
+	}
+
+	switch o.Output {
+	case "gha":
+		if notice != "" {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", notice)
+		}
+		output.PrintRiskyGHA(o.Out, subject, risks)
+		output.PrintDanglingBindingsGHA(o.Out, dangling)
+	case "csv":
+		if notice != "" {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", notice)
+		}
+		if err := output.PrintRiskyCSV(o.Out, risks, o.NoHeaders); err != nil {
+			return err
+		}
+		// Dangling bindings aren't findings, so they stay out of the rows
+		output.PrintDanglingBindings(o.ErrOut, dangling)
+	case "markdown":
+		if notice != "" {
+			_, _ = fmt.Fprintf(o.Out, "> Limited analysis: %s.\n\n", notice)
+		}
+		output.PrintRiskyMarkdown(o.Out, risks)
+		output.PrintDanglingBindings(o.ErrOut, dangling)
+	default:
+		if notice != "" {
+			_, _ = fmt.Fprintf(o.Out, "Limited analysis: %s.\n\n", notice)
+		}
+		output.PrintRiskyPermissions(o.Out, risks)
+		output.PrintDanglingBindings(o.Out, dangling)
+	}
+
+	return o.failOn(risks)
+}
+
+// tokenExposure checks where the subject's token can be read from, or
+// returns nil when the client can't tell
+func (o *RbacWhyOptions) tokenExposure(ctx context.Context, rbacClient client.RBACClient, subject rbac.Subject) *rbac.TokenExposure {
+	tokens, ok := rbacClient.(client.TokenClient)
+	if !ok {
+		return nil
+	}
+	sensitive := o.SensitiveNamespaces
+	if sensitive == nil {
+		sensitive = audit.DefaultSensitiveNamespaces
+	}
+	exposure, err := audit.CheckTokenExposure(ctx, tokens, subject, sensitive)
+	if err != nil {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: failed to check token exposure: %v\n", err)
+	}
+	return exposure
+}
+
+// failOn fails with a non-zero exit code when any risk is at or above the
+// --fail-on severity
+func (o *RbacWhyOptions) failOn(risks []rbac.RiskyPermission) error {
+	if o.FailOn == "" {
+		return nil
+	}
+	threshold := rbac.SeverityRank(o.FailOn)
+	failing := 0
+	for _, risk := range risks {
+		if rbac.SeverityRank(risk.Severity) >= threshold {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return exitcode.Failure(fmt.Errorf("%d risky permission pattern(s) at or above %s severity", failing, o.FailOn))
+	}
+	return nil
+}
+
+// runServerRulesComparison compares the caller's rules as enumerated by the API
+// server against the rules the client-side resolver finds
+func (o *RbacWhyOptions) runServerRulesComparison(ctx context.Context, reviewer client.SelfReviewClient, resolver *rbac.Resolver, subject rbac.Subject) error {
+	// SelfSubjectRulesReview always needs a namespace; mirror kubectl's default
+	namespace := o.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	serverRules, err := FetchServerRules(ctx, reviewer, namespace)
+	if err != nil {
+		return err
+	}
+
+	grants, err := resolver.ResolveAllPermissions(ctx, subject, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+
+	clientRules := make([]rbacv1.PolicyRule, 0, len(grants))
+	for _, grant := range grants {
+		clientRules = append(clientRules, grant.MatchingRule)
+	}
+
+	onlyServer, onlyClient := rbac.DiffRules(serverRules.Rules, clientRules)
+	output.PrintRulesComparison(o.Out, output.RulesComparison{
+		Namespace:       namespace,
+		OnlyServer:      onlyServer,
+		OnlyClient:      onlyClient,
+		Incomplete:      serverRules.Incomplete,
+		EvaluationError: serverRules.EvaluationError,
+	})
 	return nil
 }
@@ -0,0 +1,4464 @@
+package cani
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/snapshot"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// ticketPrinter is a bespoke format registered by the test
+type ticketPrinter struct{}
+
+func (p *ticketPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *output.ContextInfo) error {
+	_, err := fmt.Fprintf(w, "TICKET subject=%s allowed=%t grants=%d\n", result.Subject.Canonical(), result.Allowed, len(result.Grants))
+	return err
+}
+
+// newTestOptions builds options that run against a mock client as the given subject
+func newTestOptions(mock client.RBACClient, as, namespace string) (*RbacWhyOptions, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	streams := genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: &bytes.Buffer{}}
+
+	o := NewRbacWhyOptions(streams)
+	o.RBACClient = mock
+	o.ConfigFlags.Impersonate = &as
+	o.ConfigFlags.Namespace = &namespace
+	// Most tests inspect the printed result; TestRun_ExitCode covers the exit code
+	o.NoExitCode = true
+	// The mocks hold no namespaces; TestRun_NamespaceCheck covers the check
+	o.SkipNamespaceCheck = true
+	return o, out
+}
+
+func newPodReaderMock() *client.MockRBACClient {
+	mock := client.NewMockRBACClient()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-reader", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-pods", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+	return mock
+}
+
+func TestRun_RegisteredPrinter(t *testing.T) {
+	output.Register("ticket", func(output.PrinterOptions) output.Printer { return &ticketPrinter{} })
+
+	o, out := newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "default")
+	o.Output = "ticket"
+
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() rejected a registered format: %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	expected := "TICKET subject=system:serviceaccount:default:test-sa allowed=true grants=1"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Run() output = %q, expected it to contain %q", out.String(), expected)
+	}
+}
+
+func TestValidate_UnknownFormat(t *testing.T) {
+	o, _ := newTestOptions(newPodReaderMock(), "jane", "default")
+	o.Output = "no-such-format"
+
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error: %v", err)
+	}
+	err := o.Validate()
+	if err == nil {
+		t.Fatalf("Validate() expected error for unknown format, got nil")
+	}
+	if !strings.Contains(err.Error(), "json") {
+		t.Errorf("Validate() error %q should list the registered formats", err)
+	}
+}
+
+func TestExplainError(t *testing.T) {
+	o, out := newTestOptions(newPodReaderMock(), "", "")
+	msg := `Error from server (Forbidden): pods is forbidden: User "system:serviceaccount:default:test-sa" cannot delete resource "pods" in API group "" in the namespace "default"`
+
+	if err := o.completeFromForbiddenMessage(msg); err != nil {
+		t.Fatalf("completeFromForbiddenMessage() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"system:serviceaccount:default:test-sa", "delete", "DENIED"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRun_SubjectAccessReview(t *testing.T) {
+	mock := newPodReaderMock()
+	// Only reachable through an implicit group, which a review must not add
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "authenticated-secrets"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:authenticated"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+
+	tests := []struct {
+		name        string
+		manifest    string
+		wantAllowed bool
+	}{
+		{
+			name: "namespaced review via role binding",
+			manifest: `apiVersion: authorization.k8s.io/v1
+kind: LocalSubjectAccessReview
+metadata:
+  namespace: default
+spec:
+  user: system:serviceaccount:default:test-sa
+  resourceAttributes:
+    verb: get
+    resource: pods
+`,
+			wantAllowed: true,
+		},
+		{
+			name: "no implicit groups",
+			manifest: `apiVersion: authorization.k8s.io/v1
+kind: SubjectAccessReview
+spec:
+  user: alice
+  resourceAttributes:
+    verb: get
+    resource: secrets
+    namespace: default
+`,
+			wantAllowed: false,
+		},
+		{
+			name: "explicit groups are honored",
+			manifest: `apiVersion: authorization.k8s.io/v1
+kind: SubjectAccessReview
+spec:
+  user: alice
+  groups: ["system:authenticated"]
+  resourceAttributes:
+    verb: get
+    resource: secrets
+    namespace: default
+`,
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "", "")
+			o.In = strings.NewReader(tt.manifest)
+			o.Filename = "-"
+			o.Output = "json"
+
+			if err := o.Complete(nil); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			status, _ := got.SubjectAccessReview["status"].(map[string]interface{})
+			if status["allowed"] != tt.wantAllowed {
+				t.Errorf("echoed review status = %v, want allowed=%v", status, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestRunBatch_StdinStream(t *testing.T) {
+	stream := `- name: can read pods
+  verb: get
+  resource: pods
+  expect: allowed
+- name: cannot delete pods
+  verb: delete
+  resource: pods
+  expect: denied
+---
+name: missing resource
+verb: get
+---
+name: after the bad entry
+verb: list
+resource: pods
+expect: allowed
+`
+
+	tests := []struct {
+		name      string
+		keepGoing bool
+		strict    bool
+		wantLines int
+		wantCode  int
+		wantErr   string
+	}{
+		{name: "aborts on malformed entry", wantLines: 2, wantCode: exitcode.Error, wantErr: "stdin: line 10"},
+		// The malformed check is skipped, not run as a failed check
+		{name: "keep going", keepGoing: true, wantLines: 3},
+		{name: "keep going strict", keepGoing: true, strict: true, wantLines: 3, wantCode: exitcode.Incomplete, wantErr: "results are incomplete: 1 scope(s) skipped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "default")
+			o.In = strings.NewReader(stream)
+			o.ChecksFiles = []string{"-"}
+			o.KeepGoing = tt.keepGoing
+			o.Strict = tt.strict
+			o.Output = "ndjson"
+
+			if err := o.Complete(nil); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if code := exitcode.For(err); code != tt.wantCode || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Run() error = %v, exit code %d; want %q, %d", err, code, tt.wantErr, tt.wantCode)
+			}
+			if warning := "Warning: skipping check at stdin line 10: "; tt.keepGoing && !strings.Contains(o.ErrOut.(*bytes.Buffer).String(), warning) {
+				t.Errorf("stderr = %q, want %q", o.ErrOut.(*bytes.Buffer).String(), warning)
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != tt.wantLines {
+				t.Fatalf("got %d result lines, want %d:\n%s", len(lines), tt.wantLines, out.String())
+			}
+			for i, line := range lines {
+				var res output.BatchResultOutput
+				if err := json.Unmarshal([]byte(line), &res); err != nil {
+					t.Fatalf("line %d is not JSON: %v", i+1, err)
+				}
+				if res.Source != "stdin" {
+					t.Errorf("line %d: source = %q, want stdin", i+1, res.Source)
+				}
+				if res.Name == "missing resource" || !res.Passed {
+					t.Errorf("%s: passed = %v, want only the well-formed checks, passing", res.Name, res.Passed)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_JUnitSingleCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		listErr     error
+		wantCode    int
+		wantSuite   string
+		wantCase    string
+		wantElement string
+	}{
+		{
+			name:      "allowed",
+			args:      []string{"get", "pods"},
+			wantCode:  exitcode.OK,
+			wantSuite: `<testsuite name="can-i" tests="1" failures="0" errors="0"`,
+			wantCase:  `<testcase name="system:serviceaccount:default:test-sa get pods -n default" classname="rbac-why.can-i"`,
+		},
+		{
+			name:        "denied",
+			args:        []string{"delete", "pods"},
+			wantCode:    exitcode.Denied,
+			wantSuite:   `<testsuite name="can-i" tests="1" failures="1" errors="0"`,
+			wantCase:    `<testcase name="system:serviceaccount:default:test-sa delete pods -n default"`,
+			wantElement: `<failure message="expected allowed" type="UnexpectedDeny">DENIED: No RBAC rules grant delete pods`,
+		},
+		{
+			name:        "evaluation error",
+			args:        []string{"get", "pods"},
+			listErr:     errors.New("connection refused"),
+			wantCode:    exitcode.Error,
+			wantSuite:   `<testsuite name="can-i" tests="1" failures="0" errors="1"`,
+			wantElement: `<error message="failed to list cluster role bindings: connection refused" type="EvaluationError">`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newPodReaderMock()
+			mock.ListClusterRoleBindingsError = tt.listErr
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.Output, o.NoExitCode = "junit", false
+			if err := o.Complete(tt.args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if code := exitcode.For(err); code != tt.wantCode {
+				t.Errorf("exit code = %d (%v), want %d", code, err, tt.wantCode)
+			}
+			for _, want := range []string{tt.wantSuite, tt.wantCase, tt.wantElement} {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("report missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	o, _ := newTestOptions(newPodReaderMock(), "alice", "default")
+	o.Output, o.ShowRisky = "junit", true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "junit is only supported with --checks-file or a single VERB RESOURCE check") {
+		t.Errorf("Validate() error = %v, want -o junit rejected with --show-risky", err)
+	}
+}
+
+func TestRunRiskyAnalysis_SeverityOverrides(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-secrets", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "secret-reader"},
+	})
+
+	tests := []struct {
+		name       string
+		overrides  output.SeverityOverrides
+		failOn     string
+		wantErr    bool
+		wantOutput string
+	}{
+		{name: "finding meets --fail-on threshold", failOn: "critical", wantErr: true, wantOutput: "CRITICAL:"},
+		{
+			name:       "override regroups finding",
+			overrides:  output.SeverityOverrides{"secrets-access": "medium"},
+			wantOutput: "MEDIUM:\n  - secrets-access (severity overridden from critical)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "alice", "default")
+			o.ShowRisky = true
+			o.FailOn = tt.failOn
+			o.SeverityOverrides = tt.overrides
+
+			if err := o.Complete(nil); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("output missing %q:\n%s", tt.wantOutput, out.String())
+			}
+		})
+	}
+}
+
+func TestRun_ResourceNameRestricted(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls-reader", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"my-secret"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls-reader", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "tls-reader"},
+	})
+
+	// Without a name the answer is partial, and the command still fails
+	o, out := newTestOptions(mock, "alice", "default")
+	o.NoExitCode = false
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); !errors.Is(err, exitcode.ErrDenied) {
+		t.Errorf("Run() error = %v, want ErrDenied", err)
+	}
+	if !strings.HasPrefix(out.String(), "ALLOWED only for: my-secret\n") || !strings.Contains(out.String(), "Condition: only for resourceNames [my-secret]") {
+		t.Errorf("text output doesn't show the restriction:\n%s", out.String())
+	}
+
+	o, out = newTestOptions(mock, "alice", "default")
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Allowed || !got.PartiallyAllowed || !slices.Equal(got.AllowedNames, []string{"my-secret"}) {
+		t.Errorf("allowed = %v, partiallyAllowed = %v, allowedNames = %v; want false, true, [my-secret]", got.Allowed, got.PartiallyAllowed, got.AllowedNames)
+	}
+	if len(got.Grants) != 1 || !got.Grants[0].Conditional {
+		t.Errorf("grants = %+v, want one conditional grant", got.Grants)
+	}
+
+	// Naming the object allows it outright
+	o, out = newTestOptions(mock, "alice", "default")
+	if err := o.Complete([]string{"get", "secrets/my-secret"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "ALLOWED: User alice can get secrets (name: my-secret)") {
+		t.Errorf("output = %q, want a full ALLOWED", out.String())
+	}
+}
+
+func TestRun_Dedupe(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"*"}},
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "ops"},
+	})
+
+	tests := []struct {
+		name     string
+		noDedupe bool
+		output   string
+		want     []string
+		wantNot  []string
+	}{
+		{
+			name: "merged",
+			want: []string{"Permission granted through 1 path(s)", "Rule: rules[1] apiGroups=[\"\"], resources=[pods]", "Also matched: rules[0] apiGroups=[\"\"], resources=[*]"},
+		},
+		{
+			name:     "no dedupe",
+			noDedupe: true,
+			want:     []string{"Permission granted through 2 path(s)", "Rule: rules[0]", "Rule: rules[1]"},
+			wantNot:  []string{"Also matched"},
+		},
+		{
+			name:     "graph draws the role once",
+			noDedupe: true,
+			output:   "dot",
+			want:     []string{"binding_0 [label=", "role_0 [label="},
+			wantNot:  []string{"binding_1", "role_1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "alice", "default")
+			o.NoDedupe = tt.noDedupe
+			if tt.output != "" {
+				o.Output = tt.output
+			}
+			if err := o.Complete([]string{"get", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(out.String(), unwanted) {
+					t.Errorf("output contains %q:\n%s", unwanted, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRun_DanglingBindings(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "old-secrets", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "secret-reader"},
+	})
+	const as = "system:serviceaccount:default:test-sa"
+	const warning = "Warnings:\n  RoleBinding default/old-secrets refers to Role default/secret-reader, which doesn't exist\n"
+
+	o, out := newTestOptions(mock, as, "default")
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), warning) {
+		t.Errorf("text output missing the dangling binding:\n%s", out.String())
+	}
+
+	o, out = newTestOptions(mock, as, "default")
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	want := []output.DanglingBindingOutput{{
+		Binding: output.BindingOutput{Kind: "RoleBinding", Name: "old-secrets", Namespace: "default"},
+		RoleRef: output.RoleRefOutput{Kind: "Role", Name: "secret-reader"},
+	}}
+	if !slices.Equal(got.DanglingBindings, want) || len(got.Errors) != 0 {
+		t.Errorf("danglingBindings = %+v, errors = %v; want %+v and no errors", got.DanglingBindings, got.Errors, want)
+	}
+	if !slices.ContainsFunc(got.DenialReasons, func(r output.DenialReasonOutput) bool { return r.Code == rbac.DenialDanglingBinding }) {
+		t.Errorf("denialReasons = %+v, want %s", got.DenialReasons, rbac.DenialDanglingBinding)
+	}
+
+	// --show-risky reports it too
+	o, out = newTestOptions(mock, as, "default")
+	o.ShowRisky = true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), warning) {
+		t.Errorf("--show-risky output missing the dangling binding:\n%s", out.String())
+	}
+}
+
+func TestRun_Spans(t *testing.T) {
+	// Each phase is a child of the command's span, not of the phase before it
+	tests := []struct {
+		name      string
+		args      []string
+		showRisky bool
+		phases    []string
+	}{
+		{
+			name:   "check",
+			args:   []string{"get", "pods"},
+			phases: []string{"rbac-why.identity", "rbac.ResolvePermission", "rbac-why.output"},
+		},
+		{
+			name:      "show risky",
+			showRisky: true,
+			phases:    []string{"rbac-why.identity", "rbac.ResolveAllPermissions", "rbac-why.risky-analysis"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			o, _ := newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "default")
+			o.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			o.ShowRisky = tt.showRisky
+			if err := o.Complete(tt.args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			spans := make(map[string]sdktrace.ReadOnlySpan)
+			for _, s := range recorder.Ended() {
+				spans[s.Name()] = s
+			}
+			root := spans["rbac-why.can-i"]
+			if root == nil {
+				t.Fatal("missing span rbac-why.can-i")
+			}
+			for _, name := range tt.phases {
+				span := spans[name]
+				if span == nil {
+					t.Errorf("missing span %s", name)
+					continue
+				}
+				if span.Parent().SpanID() != root.SpanContext().SpanID() {
+					t.Errorf("%s span is not a child of rbac-why.can-i", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Diagnostics(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+	})
+	mock.GetClusterRoleError = apierrors.NewForbidden(rbacv1.Resource("clusterroles"), "view", errors.New("no access"))
+	mock.ServerResourcesError = errors.New("connection refused")
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	var codes []string
+	for _, d := range got.Diagnostics {
+		codes = append(codes, d.Code+"/"+d.Severity+"/"+d.Category+"/"+d.Object)
+	}
+	want := []string{"RoleForbidden/error/forbidden/ClusterRole/view", "DiscoveryUnavailable/info/other/"}
+	if !slices.Equal(codes, want) {
+		t.Errorf("diagnostics = %v, want %v", codes, want)
+	}
+	// The deprecated errors field keeps only the error messages
+	if len(got.Errors) != 1 || !strings.HasPrefix(got.Errors[0], "failed to get cluster role view: ") {
+		t.Errorf("errors = %v, want the role error only", got.Errors)
+	}
+
+	// Text output lists the errors with advice for each
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "\n    Hint: grant yourself get on clusterroles to read it\n") {
+		t.Errorf("text output missing the advice for the forbidden role:\n%s", out.String())
+	}
+}
+
+func TestRun_SuperuserPaths(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: rbac.ClusterAdminRole},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops-admin"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: rbac.ClusterAdminRole},
+	})
+
+	// A cluster-admin binding is labeled in every format
+	o, out := newTestOptions(mock, "alice", "default")
+	o.Output = "json"
+	if err := o.Complete([]string{"delete", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got.Grants) != 1 || !got.Grants[0].Superuser {
+		t.Fatalf("grants = %+v, want one superuser grant", got.Grants)
+	}
+	if g := got.Grants[0]; g.RuleIndex != 0 || g.SubjectIndex == nil || *g.SubjectIndex != 0 {
+		t.Errorf("ruleIndex = %d, subjectIndex = %v; want 0 and 0", g.RuleIndex, g.SubjectIndex)
+	}
+
+	o, out = newTestOptions(mock, "alice", "default")
+	if err := o.Complete([]string{"delete", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "ClusterRoleBinding: ops-admin subjects[0]") || !strings.Contains(out.String(), "Rule: rules[0] apiGroups=[*]") {
+		t.Errorf("text output missing the subject and rule indexes:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "ClusterRole: cluster-admin (superuser)") {
+		t.Errorf("text output missing the superuser label:\n%s", out.String())
+	}
+
+	// system:masters leads --show-risky, with no RBAC grant behind it
+	o, out = newTestOptions(mock, "bob", "default")
+	o.ConfigFlags.ImpersonateGroup = &[]string{rbac.SystemMastersGroup}
+	o.ShowRisky = true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "SUPERUSER: member of system:masters, which bypasses RBAC; every request is allowed.\n\nFound 1 risky permission pattern(s):\n\nCRITICAL:\n  - superuser-group\n"
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("output = %q, want prefix %q", out.String(), want)
+	}
+}
+
+func TestRun_EscalationCheck(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "binder", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"create"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles", "rolebindings"}},
+			{Verbs: []string{"bind"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "binder", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "binder"},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.EscalationCheck = true
+	o.Output = "json"
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.EscalationVerbsOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	var checks []string
+	for _, c := range got.Checks {
+		checks = append(checks, fmt.Sprintf("%s %s %v", c.Request.Verb, c.Request.Resource, c.Allowed))
+	}
+	wantChecks := []string{"create roles true", "escalate roles false", "create rolebindings true", "bind roles true", "bind clusterroles false"}
+	if got.CanEscalate || !got.CanBind || !slices.Equal(checks, wantChecks) {
+		t.Errorf("canEscalate = %v, canBind = %v, checks = %v; want false, true, %v", got.CanEscalate, got.CanBind, checks, wantChecks)
+	}
+
+	out.Reset()
+	o.Output = "text"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"Escalation check for ServiceAccount default/test-sa in namespace default",
+		"Create roles beyond its own permissions: no (create and escalate on roles)",
+		"Bind roles beyond its own permissions: YES (create on rolebindings and bind on roles or clusterroles)",
+		"  bind roles: RoleBinding/default/binder -> Role/default/binder: rules[1]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// The escalate and bind verbs are flagged by --show-risky
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.ShowRisky = true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "bind-verb") || strings.Contains(out.String(), "escalate-verb") {
+		t.Errorf("risky output should list bind-verb but not escalate-verb:\n%s", out.String())
+	}
+
+	// bind on clusterroles alone is enough, since a RoleBinding can
+	// reference a ClusterRole
+	mock = newPodReaderMock()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "binder", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"create"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"rolebindings"}},
+			{Verbs: []string{"bind"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "binder", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "binder"},
+	})
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.EscalationCheck = true
+	o.Output = "json"
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got = output.EscalationVerbsOutput{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	checks = nil
+	for _, c := range got.Checks {
+		checks = append(checks, fmt.Sprintf("%s %s %v", c.Request.Verb, c.Request.Resource, c.Allowed))
+	}
+	wantChecks = []string{"create roles false", "escalate roles false", "create rolebindings true", "bind roles false", "bind clusterroles true"}
+	if !got.CanBind || !slices.Equal(checks, wantChecks) {
+		t.Errorf("canBind = %v, checks = %v; want true, %v", got.CanBind, checks, wantChecks)
+	}
+}
+
+func TestRun_NamespaceCheck(t *testing.T) {
+	mock := newPodReaderMock()
+	for _, name := range []string{"default", "production", "staging-1", "staging-2"} {
+		mock.Namespaces = append(mock.Namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		skip      bool
+		risky     bool
+		wantErr   string
+	}{
+		{name: "exists", namespace: "default"},
+		{name: "typo", namespace: "porduction", wantErr: `namespace "porduction" not found; did you mean production? (use --skip-namespace-check to check it anyway)`},
+		{name: "several close matches", namespace: "stagin-1", wantErr: `namespace "stagin-1" not found; did you mean staging-1, staging-2?`},
+		{name: "no close match", namespace: "zzz", wantErr: `namespace "zzz" not found (use --skip-namespace-check to check it anyway)`},
+		{name: "skipped", namespace: "porduction", skip: true},
+		{name: "show-risky", namespace: "porduction", risky: true, wantErr: `namespace "porduction" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := newTestOptions(mock, "system:serviceaccount:default:test-sa", tt.namespace)
+			o.SkipNamespaceCheck = tt.skip
+			o.ShowRisky = tt.risky
+			var args []string
+			if !tt.risky {
+				args = []string{"get", "pods"}
+			}
+			if err := o.Complete(args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Run() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun_ShowRiskyUnusedNamespace(t *testing.T) {
+	mock := newPodReaderMock()
+	const note = "Note: no RoleBindings in namespace kube-system apply to ServiceAccount default/test-sa, so -n doesn't change the analysis"
+
+	for _, tt := range []struct {
+		namespace string
+		wantNote  bool
+	}{
+		{namespace: "kube-system", wantNote: true},
+		{namespace: "default"},
+		{namespace: ""},
+	} {
+		t.Run(tt.namespace, func(t *testing.T) {
+			o, _ := newTestOptions(mock, "system:serviceaccount:default:test-sa", tt.namespace)
+			o.ShowRisky = true
+			if err := o.Complete(nil); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			stderr := o.ErrOut.(*bytes.Buffer).String()
+			if strings.Contains(stderr, note) != tt.wantNote {
+				t.Errorf("stderr = %q, want note %v", stderr, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestRun_EscalateVerbGroup(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "escalator"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"escalate"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterroles"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "escalator"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "escalator"},
+	})
+
+	o, out := newTestOptions(mock, "alice", "")
+	o.Output = "json"
+	if err := o.Complete([]string{"escalate", "clusterroles"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !got.Allowed || got.Request.APIGroup != "rbac.authorization.k8s.io" {
+		t.Errorf("allowed = %v, group = %q; want allowed in the RBAC group", got.Allowed, got.Request.APIGroup)
+	}
+}
+
+func TestRun_ShowImpersonation(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer-impersonator", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"impersonate"}, APIGroups: []string{""}, Resources: []string{"serviceaccounts"}, ResourceNames: []string{"deployer"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer-impersonator", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "deployer-impersonator"},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.ShowImpersonation = true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "Can become:\n  ServiceAccount default/deployer  via RoleBinding/default/deployer-impersonator -> Role/default/deployer-impersonator: rules[0]"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.ImpersonationOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !got.CanImpersonate || len(got.Targets) != 1 || got.Targets[0].Kind != "ServiceAccount" || got.Targets[0].Namespace != "default" || !slices.Equal(got.Targets[0].Names, []string{"deployer"}) {
+		t.Errorf("unexpected JSON output: %+v", got)
+	}
+
+	// A subject without impersonate grants is reported as denied
+	o, out = newTestOptions(mock, "alice", "default")
+	o.ShowImpersonation = true
+	o.NoExitCode = false
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); !errors.Is(err, exitcode.ErrDenied) {
+		t.Errorf("Run() error = %v, want ErrDenied", err)
+	}
+	if !strings.Contains(out.String(), "User alice cannot impersonate anyone") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+
+	o, _ = newTestOptions(mock, "alice", "default")
+	o.ShowImpersonation = true
+	if err := o.Complete([]string{"get", "pods"}); err == nil {
+		t.Error("Complete() with arguments should fail")
+	}
+}
+
+func TestRun_ImplicitGroups(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "namespace-sas", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts:default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "oidc-devs", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "oidc:devs"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+
+	tests := []struct {
+		name        string
+		noImplicit  bool
+		extra       []string
+		wantAllowed bool
+		wantOutput  string
+	}{
+		{name: "implicit groups", wantAllowed: true, wantOutput: "v  (matched via Group system:serviceaccounts:default)\n  RoleBinding: namespace-sas"},
+		{name: "direct bindings only", noImplicit: true, wantOutput: "Effective groups: none (no implicit groups)"},
+		{
+			name:        "extra group without implicit groups",
+			noImplicit:  true,
+			extra:       []string{"oidc:devs"},
+			wantAllowed: true,
+			wantOutput:  "Effective groups: oidc:devs (no implicit groups)",
+		},
+		{
+			name:        "extra group",
+			extra:       []string{"oidc:devs"},
+			wantAllowed: true,
+			wantOutput:  "Effective groups: oidc:devs, system:authenticated, system:serviceaccounts, system:serviceaccounts:default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.IncludeImplicitGroups = !tt.noImplicit
+			o.ExtraGroups = tt.extra
+			if err := o.Complete([]string{"list", "secrets"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if allowed := strings.HasPrefix(out.String(), "ALLOWED") || strings.Contains(out.String(), "\nALLOWED"); allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v:\n%s", allowed, tt.wantAllowed, out.String())
+			}
+			if tt.wantOutput != "" && !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("output missing %q:\n%s", tt.wantOutput, out.String())
+			}
+		})
+	}
+}
+
+func TestRun_RequestPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		namespace   string
+		wantAllowed bool
+		wantErr     string
+	}{
+		{name: "named get", path: "GET /api/v1/namespaces/default/pods/web-1", wantAllowed: true},
+		{name: "collection delete", path: "DELETE /api/v1/namespaces/default/pods", wantAllowed: false},
+		{name: "namespace flag agrees", path: "GET /api/v1/namespaces/default/pods", namespace: "default", wantAllowed: true},
+		{name: "namespace flag conflicts", path: "GET /api/v1/namespaces/default/pods", namespace: "prod", wantErr: "conflicts"},
+		{name: "non-resource path", path: "GET /metrics", wantAllowed: true},
+		{name: "non-resource path not granted", path: "GET /healthz", wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newPodReaderMock()
+			mock.AddClusterRole(rbacv1.ClusterRole{
+				ObjectMeta: metav1.ObjectMeta{Name: "metrics-reader"},
+				Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}}},
+			})
+			mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "metrics-reader"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "metrics-reader"},
+			})
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", tt.namespace)
+			o.RequestPath = tt.path
+			o.Output = "json"
+
+			err := o.Complete(nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Complete() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestDiff_AcrossContexts(t *testing.T) {
+	staging := newPodReaderMock()
+	staging.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "edit", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-deleter"},
+	})
+	staging.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-deleter", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+	})
+
+	out := &bytes.Buffer{}
+	o := NewDiffOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+	as, namespace := "system:serviceaccount:default:test-sa", "default"
+	o.ConfigFlags.Impersonate = &as
+	o.ConfigFlags.Namespace = &namespace
+	o.Contexts = []string{"staging", "prod"}
+	o.Clients = map[string]client.RBACClient{"staging": staging, "prod": newPodReaderMock()}
+	o.Output = "json"
+
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.DiffOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if got.Left.Label != "staging" || got.Right.Label != "prod" {
+		t.Errorf("sides = %q, %q, want staging, prod", got.Left.Label, got.Right.Label)
+	}
+	if len(got.OnlyLeft) != 1 || got.OnlyLeft[0].Permission != "delete pods" {
+		t.Fatalf("onlyLeft = %+v, want [delete pods]", got.OnlyLeft)
+	}
+	if via := got.OnlyLeft[0].GrantedVia; len(via) != 1 || via[0].Binding.Name != "edit" {
+		t.Errorf("grantedVia = %+v, want the edit binding", via)
+	}
+	if len(got.OnlyRight) != 0 {
+		t.Errorf("onlyRight = %+v, want none", got.OnlyRight)
+	}
+}
+
+func TestDiff_Subjects(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "devs-read", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "dev"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "devs-delete", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "dev"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-deleter"},
+	})
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-deleter", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+	})
+
+	out := &bytes.Buffer{}
+	o := NewDiffOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+	as, namespace := "system:serviceaccount:default:test-sa", "default"
+	o.ConfigFlags.Impersonate = &as
+	o.ConfigFlags.Namespace = &namespace
+	o.As2, o.As2Groups = "jane", []string{"dev"}
+	o.Clients = map[string]client.RBACClient{"": mock}
+	o.Output = "json"
+
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.DiffOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(got.OnlyLeft) != 0 {
+		t.Errorf("onlyLeft = %+v, want none", got.OnlyLeft)
+	}
+	if len(got.OnlyRight) != 1 || got.OnlyRight[0].Permission != "delete pods" {
+		t.Fatalf("onlyRight = %+v, want [delete pods]", got.OnlyRight)
+	}
+	if via := got.OnlyRight[0].GrantedVia; len(via) != 1 || via[0].MatchedVia != "Group dev" {
+		t.Errorf("grantedVia = %+v, want devs-delete through Group dev", via)
+	}
+	if len(got.Shared) != 2 {
+		t.Errorf("shared = %+v, want get and list pods", got.Shared)
+	}
+}
+
+func TestRun_ApplyRole(t *testing.T) {
+	const readPods = `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: proposed
+  namespace: default
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get"]
+`
+	const deletePods = `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: proposed
+  namespace: default
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "delete"]
+`
+	tests := []struct {
+		name          string
+		manifest      string
+		extraVerbs    []string
+		wantAllowed   bool
+		wantUncovered []string
+	}{
+		{name: "subset of own permissions", manifest: readPods, wantAllowed: true},
+		{name: "grants more than held", manifest: deletePods, wantAllowed: false, wantUncovered: []string{"delete pods"}},
+		{name: "escalate bypasses coverage", manifest: deletePods, extraVerbs: []string{"escalate"}, wantAllowed: true, wantUncovered: []string{"delete pods"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newPodReaderMock()
+			mock.AddRole(rbacv1.Role{
+				ObjectMeta: metav1.ObjectMeta{Name: "role-writer", Namespace: "default"},
+				Rules: []rbacv1.PolicyRule{{
+					Verbs:     append([]string{"create"}, tt.extraVerbs...),
+					APIGroups: []string{"rbac.authorization.k8s.io"},
+					Resources: []string{"roles"},
+				}},
+			})
+			mock.AddRoleBinding(rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "write-roles", Namespace: "default"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "role-writer"},
+			})
+
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "")
+			o.ApplyRole = true
+			o.In = strings.NewReader(tt.manifest)
+			o.Filename = "-"
+			o.Output = "json"
+
+			if err := o.Complete(nil); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			var got output.EscalationOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if tt.wantUncovered != nil && fmt.Sprint(got.Uncovered) != fmt.Sprint(tt.wantUncovered) {
+				t.Errorf("Uncovered = %v, want %v", got.Uncovered, tt.wantUncovered)
+			}
+		})
+	}
+}
+
+func TestRun_GroupsFile(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform-nodes"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "platform-admins"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "node-reader"},
+	})
+
+	path := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(path, []byte("\"*@platform.example.com\": [platform-admins]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	o, out := newTestOptions(mock, "jane@platform.example.com", "")
+	o.GroupsFile = path
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "nodes"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if !got.Allowed {
+		t.Error("expected the mapped group's binding to allow the request")
+	}
+	if len(got.Subject.GroupMappings) != 1 || got.Subject.GroupMappings[0].Source != "groups.yaml:1" {
+		t.Errorf("GroupMappings = %+v, want the groups.yaml:1 entry", got.Subject.GroupMappings)
+	}
+}
+
+func TestRun_UnknownSubresourceWarning(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}, {Name: "pods/log", Namespaced: true}, {Name: "pods/exec", Namespaced: true}},
+	}}
+
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{resource: "pods/logz", want: `Warning: pods has no subresource "logz" (valid: exec, log), so it is checked as an object name; did you mean pods/log?`},
+		{resource: "pods/web-0/logz", want: `Warning: pods has no subresource "logz" (valid: exec, log); did you mean pods/log?`},
+		{resource: "pods/log"},
+		{resource: "pods/web-0"},
+		{resource: "widgets/status", want: "Warning: the cluster doesn't serve widgets. The check still runs, as RBAC rules can name a resource before its CRD is installed."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			o, _ := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			got := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String())
+			if got != tt.want {
+				t.Errorf("warning = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_NormalizeResource(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployment-reader", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-deployments", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "deployment-reader"},
+	})
+	mock.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Namespaced: true, SingularName: "pod", Kind: "Pod", ShortNames: []string{"po"}},
+			{Name: "events", Namespaced: true, SingularName: "event", Kind: "Event", ShortNames: []string{"ev"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Namespaced: true, SingularName: "deployment", Kind: "Deployment", ShortNames: []string{"deploy"}},
+		}},
+		{GroupVersion: "events.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "events", Namespaced: true, SingularName: "event", Kind: "Event", ShortNames: []string{"ev"}},
+		}},
+	}
+
+	tests := []struct {
+		resource    string
+		noNormalize bool
+		wantAllowed bool
+		wantNote    string
+	}{
+		{resource: "po", wantAllowed: true, wantNote: "Note: po → pods"},
+		{resource: "deploy", wantAllowed: true, wantNote: "Note: deploy → deployments.apps"},
+		{resource: "Deployment", wantAllowed: true, wantNote: "Note: Deployment → deployments.apps"},
+		{resource: "deployments", wantAllowed: true, wantNote: "Note: deployments → deployments.apps"},
+		{resource: "deployments.apps", wantAllowed: true},
+		{resource: "deploy", noNormalize: true, wantNote: "Warning: the cluster doesn't serve deploy. Did you mean deployments.apps? The check still runs, as RBAC rules can name a resource before its CRD is installed."},
+		{resource: "events", wantNote: "Warning: events is served by several API groups (core, events.k8s.io); checking events, name the group to check another, e.g. events.events.k8s.io"},
+		{resource: "events.events.k8s.io"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.NoNormalize = tt.noNormalize
+			o.Output = "json"
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if note := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String()); note != tt.wantNote {
+				t.Errorf("note = %q, want %q", note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestRun_ResourceVersion(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}}},
+		{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{{Name: "cronjobs", Namespaced: true}}},
+		{GroupVersion: "v2.example.com/v1", APIResources: []metav1.APIResource{{Name: "widgets", Namespaced: true}}},
+	}
+
+	tests := []struct {
+		resource    string
+		wantAllowed bool
+		wantGroup   string
+		wantStderr  string
+	}{
+		{resource: "pods.v1", wantAllowed: true, wantStderr: "Parsed pods.v1 as resource pods, version v1, API group core"},
+		{resource: "cronjobs.v1.batch", wantGroup: "batch", wantStderr: "Parsed cronjobs.v1.batch as resource cronjobs, version v1, API group batch"},
+		{resource: "cronjobs.batch", wantGroup: "batch", wantStderr: "Parsed cronjobs.batch as resource cronjobs, version (any), API group batch"},
+		{
+			resource:   "cronjobs.v2.batch",
+			wantGroup:  "batch",
+			wantStderr: "Warning: the cluster doesn't serve batch/v2; RBAC rules don't name versions, so the check still runs\nParsed cronjobs.v2.batch as resource cronjobs, version v2, API group batch",
+		},
+		{
+			resource:   "widgets.v2.example.com",
+			wantGroup:  "v2.example.com",
+			wantStderr: "Note: widgets is in API group v2.example.com; v2 is not a version here\nParsed widgets.v2.example.com as resource widgets, version (any), API group v2.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.Output = "json"
+			o.Verbose = true
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed || got.Request.APIGroup != tt.wantGroup {
+				t.Errorf("allowed = %v, group = %q, want %v, %q", got.Allowed, got.Request.APIGroup, tt.wantAllowed, tt.wantGroup)
+			}
+			if stderr := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String()); stderr != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun_UnknownResource(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}, {Name: "pods/log", Namespaced: true}}},
+		{GroupVersion: "stable.example.com/v1", APIResources: []metav1.APIResource{{Name: "crontabs", Namespaced: true}, {Name: "crontabs/status", Namespaced: true}}},
+	}
+
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{resource: "pods"},
+		{resource: "crontabs.stable.example.com"},
+		{resource: "pdos", want: "the cluster doesn't serve pdos. Did you mean pods? The check still runs, as RBAC rules can name a resource before its CRD is installed."},
+		{resource: "crontab.stable.example.com", want: "the cluster doesn't serve crontab.stable.example.com. Did you mean crontabs.stable.example.com? The check still runs, as RBAC rules can name a resource before its CRD is installed."},
+		{resource: "crontabs.stabel.example.com", want: "the cluster doesn't serve crontabs.stabel.example.com. Did you mean crontabs.stable.example.com? No API group stabel.example.com is served (similar: stable.example.com); is its CRD installed? The check still runs, as RBAC rules can name a resource before its CRD is installed."},
+		{resource: "certificates.cert-manager.io", want: "the cluster doesn't serve certificates.cert-manager.io. No API group cert-manager.io is served; is its CRD installed? The check still runs, as RBAC rules can name a resource before its CRD is installed."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.Output = "json"
+			o.NoNormalize = true
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			var want []output.WarningOutput
+			if tt.want != "" {
+				want = []output.WarningOutput{{Code: rbac.WarningUnknownResource, Message: tt.want}}
+			}
+			if !slices.Equal(got.Warnings, want) {
+				t.Errorf("warnings = %+v, want %+v", got.Warnings, want)
+			}
+			if stderr := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String()); tt.want != "" && stderr != "Warning: "+tt.want {
+				t.Errorf("stderr = %q, want the warning", stderr)
+			}
+		})
+	}
+}
+
+func TestRun_NamespaceScope(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}, {Name: "nodes"}}},
+	}
+
+	tests := []struct {
+		name          string
+		namespace     string
+		allNamespaces bool
+		resource      string
+		wantAllowed   bool
+		wantNote      string
+		wantErr       string
+	}{
+		{
+			name:        "namespaced resource defaults to default",
+			resource:    "pods",
+			wantAllowed: true,
+			wantNote:    "Note: no namespace given, so pods is checked in namespace default (like kubectl); use -n to choose one or -A to check every namespace",
+		},
+		{name: "namespaced resource with -n", namespace: "default", resource: "pods", wantAllowed: true},
+		{name: "cluster-scoped resource", resource: "nodes"},
+		{name: "cluster-scoped resource with -n", namespace: "default", resource: "nodes", wantErr: "nodes is cluster-scoped and cannot be checked in a namespace; drop -n default"},
+		{name: "cluster-scoped resource with -A", allNamespaces: true, resource: "nodes", wantErr: "nodes is cluster-scoped, so --all-namespaces does not apply to it"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", tt.namespace)
+			o.Output = "json"
+			o.AllNamespaces = tt.allNamespaces
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if note := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String()); note != tt.wantNote {
+				t.Errorf("note = %q, want %q", note, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestRun_NamespaceScopeWithoutDiscovery(t *testing.T) {
+	mock := newPodReaderMock()
+
+	tests := []struct {
+		resource    string
+		wantAllowed bool
+		wantStderr  string
+	}{
+		{
+			resource:    "pods",
+			wantAllowed: true,
+			wantStderr:  "Note: no namespace given, so pods is checked in namespace default (like kubectl); use -n to choose one or -A to check every namespace",
+		},
+		{resource: "nodes"},
+		{
+			resource:   "widgets.example.com",
+			wantStderr: "Warning: no namespace given, and without discovery it isn't known whether widgets.example.com is namespaced, so only cluster-wide grants are checked; use -n to check a namespace",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "")
+			o.Output = "json"
+			o.NoNormalize = true
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if got.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if stderr := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String()); stderr != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun_ClusterScopedRoleBinding(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"nodes"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-nodes", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "node-reader"},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output = "json"
+	if err := o.Complete([]string{"list", "nodes"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Allowed {
+		t.Error("allowed = true, want denied: a RoleBinding doesn't grant nodes")
+	}
+	if len(got.DenialReasons) != 1 || got.DenialReasons[0].Code != rbac.DenialClusterScoped {
+		t.Errorf("denialReasons = %+v, want one %s", got.DenialReasons, rbac.DenialClusterScoped)
+	}
+	want := "Warning: nodes is cluster-scoped, so RoleBindings in namespace default don't grant it; only ClusterRoleBindings do"
+	if stderr := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String()); stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+}
+
+func TestRun_RoleBindingToClusterRoleScope(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-admin", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+	})
+
+	for _, tt := range []struct {
+		output string
+		want   string
+	}{
+		{output: "text", want: "  Scope: namespace (effective only in namespace dev)\n  Note: RoleBinding confines ClusterRole cluster-admin to namespace dev"},
+		{output: "dot", want: `[label="refs\n(only in ns: dev)"]`},
+		{output: "mermaid", want: "-->|refs only in ns dev|"},
+		{output: "json", want: `"effectiveNamespace": "dev"`},
+	} {
+		t.Run(tt.output, func(t *testing.T) {
+			o, out := newTestOptions(mock, "alice", "dev")
+			o.Output = tt.output
+			if err := o.Complete([]string{"delete", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output missing %q:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
+
+func TestRun_NodeSubject(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "system:node"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"nodes"}},
+		},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "system:node"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:nodes"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "system:node"},
+	})
+
+	o, out := newTestOptions(mock, "system:node:worker-1", "")
+	o.Output = "json"
+	if err := o.Complete([]string{"list", "nodes"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !got.Allowed || got.Subject.Kind != "User" {
+		t.Errorf("allowed = %v, subject kind = %s; want allowed for a User via system:nodes", got.Allowed, got.Subject.Kind)
+	}
+	if len(got.Warnings) != 1 || got.Warnings[0].Code != rbac.WarningNodeAuthorizer {
+		t.Errorf("warnings = %+v, want one %s warning", got.Warnings, rbac.WarningNodeAuthorizer)
+	}
+	if stderr := o.ErrOut.(*bytes.Buffer).String(); !strings.Contains(stderr, "Warning: system:node:worker-1 is a kubelet") {
+		t.Errorf("stderr = %q, want the Node authorizer caveat", stderr)
+	}
+}
+
+func TestRun_SubjectKindPrefix(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-read-pods", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "platform-team"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+
+	tests := []struct {
+		as          string
+		wantKind    string
+		wantName    string
+		wantAllowed bool
+	}{
+		{as: "group:platform-team", wantKind: "Group", wantName: "platform-team", wantAllowed: true},
+		// Without the prefix the name is guessed to be a User's
+		{as: "platform-team", wantKind: "User", wantName: "platform-team"},
+		{as: "sa:default:test-sa", wantKind: "ServiceAccount", wantName: "test-sa", wantAllowed: true},
+		{as: "user:jane", wantKind: "User", wantName: "jane"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.as, func(t *testing.T) {
+			o, out := newTestOptions(mock, tt.as, "default")
+			o.Output = "json"
+			if err := o.Complete([]string{"get", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			var got output.JSONOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if got.Subject.Kind != tt.wantKind || got.Subject.Name != tt.wantName || got.Allowed != tt.wantAllowed {
+				t.Errorf("subject = %s %s, allowed = %v; want %s %s, %v",
+					got.Subject.Kind, got.Subject.Name, got.Allowed, tt.wantKind, tt.wantName, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestRun_ServiceAccountShorthand(t *testing.T) {
+	mock := newPodReaderMock()
+	for _, as := range []string{"sa:default:test-sa", "sa://default/test-sa"} {
+		t.Run(as, func(t *testing.T) {
+			o, out := newTestOptions(mock, as, "default")
+			if err := o.Complete([]string{"get", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			want := "Subject: system:serviceaccount:default:test-sa (from --as " + as + ")"
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("output missing %q:\n%s", want, out.String())
+			}
+		})
+	}
+
+	// A missing namespace is reported with the expected form
+	o, _ := newTestOptions(mock, "sa://test-sa", "default")
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	err := o.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "the namespace is missing (expected sa://NAMESPACE/NAME, or use --sa test-sa") {
+		t.Errorf("Run() error = %v, want a missing namespace error", err)
+	}
+
+	// NAMESPACE/NAME without a prefix is a User, with a hint
+	o, _ = newTestOptions(mock, "default/test-sa", "default")
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "Note: default/test-sa is checked as a User; for a ServiceAccount use --sa default/test-sa or --as sa:default:test-sa"
+	if got := o.ErrOut.(*bytes.Buffer).String(); !strings.Contains(got, want) {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
+
+func TestComplete_ServiceAccountFlag(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+- name: dev
+  context: {cluster: dev-cluster, user: jane, namespace: team-a}
+clusters:
+- name: dev-cluster
+  cluster: {server: "https://127.0.0.1:6443"}
+users:
+- name: jane
+  user: {token: secret}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		sa        string
+		as        string
+		namespace string
+		wantAs    string
+		wantErr   string
+	}{
+		{name: "namespace and name", sa: "prod/api", namespace: "default", wantAs: "system:serviceaccount:prod:api"},
+		{name: "name in -n", sa: "api", namespace: "staging", wantAs: "system:serviceaccount:staging:api"},
+		{name: "name in the context namespace", sa: "api", wantAs: "system:serviceaccount:team-a:api"},
+		{name: "with --as", sa: "prod/api", as: "alice", wantErr: "--sa and --as are mutually exclusive"},
+		{name: "empty namespace", sa: "/api", wantErr: `invalid --sa value "/api": namespace is empty`},
+		{name: "empty name", sa: "prod/", wantErr: `invalid --sa value "prod/"`},
+		{name: "too many parts", sa: "prod/api/x", wantErr: `invalid --sa value "prod/api/x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := newTestOptions(newPodReaderMock(), tt.as, tt.namespace)
+			o.ConfigFlags.KubeConfig = &kubeconfig
+			o.ServiceAccount = tt.sa
+			err := o.Complete([]string{"get", "pods"})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Complete() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if o.As != tt.wantAs || !o.AsProvided {
+				t.Errorf("As = %q, AsProvided = %v; want %q, true", o.As, o.AsProvided, tt.wantAs)
+			}
+		})
+	}
+
+	// The output shows the subject --sa expands to
+	o, out := newTestOptions(newPodReaderMock(), "", "default")
+	o.ServiceAccount = "test-sa"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"Subject: system:serviceaccount:default:test-sa (from --sa test-sa)", "ALLOWED"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_DeprecatedGroupWarning(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer"},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{"extensions"},
+			Resources: []string{"deployments"},
+			Verbs:     []string{"get", "update"},
+		}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "ci", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "deployer"},
+	})
+	mock.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods", Namespaced: true}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true}}},
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"get", "deployments.apps"}, want: "Warning: ClusterRole/deployer rules[0]: rule targets deprecated group 'extensions'; on this cluster deployments are served from 'apps'"},
+		{args: []string{"delete", "deployments.apps"}},
+		{args: []string{"get", "pods"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			o, _ := newTestOptions(mock, "system:serviceaccount:default:ci", "default")
+			if err := o.Complete(tt.args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			got := strings.TrimSpace(o.ErrOut.(*bytes.Buffer).String())
+			if got != tt.want {
+				t.Errorf("warning = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_Debug(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "admins"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "cluster-admin"},
+	})
+	run := func(debug bool) (string, string) {
+		t.Helper()
+		o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+		o.Debug = debug
+		if err := o.Complete([]string{"delete", "pods"}); err != nil {
+			t.Fatalf("Complete() error = %v", err)
+		}
+		if err := o.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if err := o.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return out.String(), o.ErrOut.(*bytes.Buffer).String()
+	}
+
+	plain, plainErr := run(false)
+	out, errOut := run(true)
+	if out != plain {
+		t.Errorf("--debug changed the output:\n%s\nwant:\n%s", out, plain)
+	}
+	if plainErr != "" {
+		t.Errorf("stderr without --debug = %q, want nothing", plainErr)
+	}
+	for _, want := range []string{
+		`msg="binding skipped" kind=ClusterRoleBinding name=admins reason="no subject matches"`,
+		`msg="binding applies" kind=RoleBinding name=read-pods namespace=default via="ServiceAccount default/test-sa"`,
+		`msg="rule skipped" binding="RoleBinding default/read-pods" role="Role pod-reader" rule=0 reason="verb \"delete\" not in [\"get\" \"list\"]"`,
+	} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("stderr missing %q:\n%s", want, errOut)
+		}
+	}
+
+	o, _ := newTestOptions(mock, "alice", "default")
+	o.Verbosity = -1
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "--v must not be negative") {
+		t.Errorf("Validate() error = %v, want a negative --v rejected", err)
+	}
+}
+
+func TestRun_Trace(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "system:basic-user"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:authenticated"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "system:basic-user"},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Trace = true
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"Evaluation trace (2 binding(s) examined, 2 matched the subject):",
+		"  ClusterRoleBinding/system:basic-user -> ClusterRole/system:basic-user\n    subject matched via Group system:authenticated\n    roleRef not resolved: ClusterRole system:basic-user doesn't exist",
+		"  RoleBinding/default/read-pods -> Role/pod-reader\n    subject matched via ServiceAccount default/test-sa\n    rules[0] no: verb \"delete\" not in [\"get\" \"list\"]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Trace, o.SkipSystemBindings = true, true
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if strings.Contains(out.String(), "ClusterRoleBinding/system:basic-user") {
+		t.Errorf("--skip-system-bindings kept a system binding:\n%s", out.String())
+	}
+	// The binding is still reported as dangling outside the trace
+	if !strings.Contains(out.String(), "Warnings:\n  ClusterRoleBinding system:basic-user refers to ClusterRole system:basic-user, which doesn't exist\n") {
+		t.Errorf("output missing the dangling binding warning:\n%s", out.String())
+	}
+
+	o, _ = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Trace, o.ShowRisky = true, true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil {
+		t.Error("Validate() accepted --trace with --show-risky")
+	}
+}
+
+func TestRun_AsGroup(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "developers-read-pods", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "developers"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+
+	tests := []struct {
+		name   string
+		groups []string
+		want   []string
+	}{
+		{
+			name:   "allowed only via group",
+			groups: []string{"developers"},
+			want:   []string{"Effective groups: developers, system:authenticated", "ALLOWED: User jane can get pods", "RoleBinding: developers-read-pods"},
+		},
+		{
+			name:   "repeated groups",
+			groups: []string{"qa", "developers", "system:authenticated"},
+			want:   []string{"Effective groups: qa, developers, system:authenticated\n", "ALLOWED"},
+		},
+		{
+			name: "no group",
+			want: []string{"DENIED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "jane", "default")
+			o.ConfigFlags.ImpersonateGroup = &tt.groups
+			if err := o.Complete([]string{"get", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	o, _ := newTestOptions(mock, "", "default")
+	o.ConfigFlags.ImpersonateGroup = &[]string{"developers"}
+	if err := o.Complete([]string{"get", "pods"}); err == nil || !strings.Contains(err.Error(), "--as-group requires --as") {
+		t.Errorf("Complete() error = %v, want --as-group requires --as", err)
+	}
+}
+
+func TestRun_NonResourceURL(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics", "/metrics/*"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "jane-metrics"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "jane"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "metrics-reader"},
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{name: "exact path", args: []string{"get", "/metrics"}, want: []string{"ALLOWED: User jane can get /metrics", "nonResourceURLs=[/metrics /metrics/*]"}},
+		{name: "prefix wildcard", args: []string{"get", "/metrics/cadvisor"}, want: []string{"ALLOWED"}},
+		{name: "other path", args: []string{"get", "/healthz"}, want: []string{"DENIED"}},
+		{name: "other verb", args: []string{"post", "/metrics"}, want: []string{"DENIED"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "jane", "default")
+			if err := o.Complete(tt.args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddNamespace("default")
+	mock.AddNamespace("kube-system")
+
+	tests := []struct {
+		name          string
+		args          []string
+		namespace     string
+		allNamespaces bool
+		output        string
+		noExitCode    bool
+		want          int
+	}{
+		{name: "allowed", args: []string{"get", "pods"}, namespace: "default", want: exitcode.OK},
+		{name: "denied", args: []string{"delete", "pods"}, namespace: "default", want: exitcode.Denied},
+		{name: "denied json", args: []string{"delete", "pods"}, namespace: "default", output: "json", want: exitcode.Denied},
+		{name: "denied with --no-exit-code", args: []string{"delete", "pods"}, namespace: "default", noExitCode: true, want: exitcode.OK},
+		{name: "allowed in one namespace", args: []string{"get", "pods"}, allNamespaces: true, want: exitcode.OK},
+		{name: "denied in every namespace", args: []string{"delete", "pods"}, allNamespaces: true, want: exitcode.Denied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", tt.namespace)
+			o.NoExitCode = tt.noExitCode
+			o.AllNamespaces = tt.allNamespaces
+			if tt.output != "" {
+				o.Output = tt.output
+			}
+			if err := o.Complete(tt.args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if got := exitcode.For(err); got != tt.want {
+				t.Errorf("exit code = %d (error %v), want %d", got, err, tt.want)
+			}
+			if tt.output == "json" && !json.Valid(out.Bytes()) {
+				t.Errorf("JSON output not fully written before exiting:\n%s", out.String())
+			}
+		})
+	}
+
+	if got := exitcode.For(fmt.Errorf("failed to resolve permission: %w", fmt.Errorf("forbidden"))); got != exitcode.Error {
+		t.Errorf("exit code for an evaluation error = %d, want %d", got, exitcode.Error)
+	}
+}
+
+func TestRun_Quiet(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddNamespace("default")
+	mock.AddNamespace("kube-system")
+
+	tests := []struct {
+		name          string
+		args          []string
+		namespace     string
+		allNamespaces bool
+		want          string
+		wantErr       error
+	}{
+		{name: "allowed", args: []string{"get", "pods"}, namespace: "default", want: "yes\n"},
+		{name: "denied", args: []string{"delete", "pods"}, namespace: "default", want: "no\n", wantErr: exitcode.ErrDenied},
+		{name: "all namespaces", args: []string{"list", "pods"}, allNamespaces: true, want: "yes\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", tt.namespace)
+			o.Quiet = true
+			o.NoExitCode = false
+			o.AllNamespaces = tt.allNamespaces
+			if err := o.Complete(tt.args); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+
+	for _, format := range []string{"dot", "mermaid"} {
+		o, _ := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+		o.Quiet = true
+		o.Output = format
+		if err := o.Complete([]string{"get", "pods"}); err != nil {
+			t.Fatalf("Complete() error = %v", err)
+		}
+		want := "--quiet prints only yes or no and cannot be used with -o " + format
+		if err := o.Validate(); err == nil || err.Error() != want {
+			t.Errorf("Validate() with -o %s error = %v, want %q", format, err, want)
+		}
+	}
+}
+
+func TestRun_WorkloadSubject(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.Pods = []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "api-7f9c", Namespace: "default"}, Spec: corev1.PodSpec{ServiceAccountName: "test-sa"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "bare", Namespace: "default"}},
+	}
+	template := corev1.PodTemplateSpec{Spec: corev1.PodSpec{ServiceAccountName: "test-sa"}}
+	mock.Deployments = []appsv1.Deployment{
+		{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}, Spec: appsv1.DeploymentSpec{Template: template}},
+	}
+	mock.CronJobs = []batchv1.CronJob{{
+		ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+		Spec:       batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template}}},
+	}}
+
+	tests := []struct {
+		name     string
+		pod      string
+		workload string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "service account",
+			pod:  "default/api-7f9c",
+			want: []string{"Subject: system:serviceaccount:default:test-sa (from pod default/api-7f9c)", "ALLOWED"},
+		},
+		{
+			name: "namespace from -n, default service account",
+			pod:  "bare",
+			want: []string{"Subject: system:serviceaccount:default:default (from pod default/bare)", "DENIED"},
+		},
+		{name: "missing pod", pod: "default/gone", wantErr: "pod default/gone not found"},
+		{
+			name:     "deployment",
+			workload: "deploy/default/api",
+			want:     []string{"Subject: system:serviceaccount:default:test-sa (from deployment default/api)", "ALLOWED"},
+		},
+		{
+			name:     "cronjob job template",
+			workload: "CronJob/report",
+			want:     []string{"Subject: system:serviceaccount:default:test-sa (from cronjob default/report)", "ALLOWED"},
+		},
+		{name: "missing statefulset", workload: "statefulset/default/db", wantErr: "statefulset default/db not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "", "default")
+			o.Pod, o.Workload = tt.pod, tt.workload
+			if err := o.Complete([]string{"get", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	o, _ := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Pod = "api-7f9c"
+	if err := o.Complete([]string{"get", "pods"}); err == nil {
+		t.Error("Complete() with --as and --pod succeeded, want error")
+	}
+
+	o, _ = newTestOptions(mock, "", "default")
+	o.Workload = "replicaset/default/api"
+	if err := o.Complete([]string{"get", "pods"}); err == nil || !strings.Contains(err.Error(), "unsupported --workload kind") {
+		t.Errorf("Complete() with a ReplicaSet error = %v, want unsupported kind", err)
+	}
+}
+
+// countingClient counts how often the RoleBindings of each namespace are listed
+type countingClient struct {
+	*client.MockRBACClient
+	roleBindingLists map[string]int
+}
+
+func (c *countingClient) ListRoleBindings(ctx context.Context, namespace string) (*rbacv1.RoleBindingList, error) {
+	c.roleBindingLists[namespace]++
+	return c.MockRBACClient.ListRoleBindings(ctx, namespace)
+}
+
+func TestRun_Verbs(t *testing.T) {
+	tests := []struct {
+		name   string
+		verbs  string
+		any    bool
+		output string
+		want   []string
+		wantOK bool
+	}{
+		{
+			name:  "text",
+			verbs: "get,list,delete",
+			want: []string{
+				"Checking whether ServiceAccount default/test-sa can get,list,delete pods in namespace default",
+				"get     ALLOWED  RoleBinding/default/read-pods -> Role/default/pod-reader: apiGroups=[\"\"], resources=[pods], verbs=[get list]",
+				"list    ALLOWED  RoleBinding/default/read-pods -> Role/default/pod-reader: apiGroups=[\"\"], resources=[pods], verbs=[get list]",
+				"delete  DENIED",
+				"2 of 3 verb(s) allowed",
+			},
+		},
+		{name: "any", verbs: "get,delete", any: true, wantOK: true},
+		{name: "all allowed", verbs: "get,list", wantOK: true},
+		{name: "json", verbs: "get,delete", output: "json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &countingClient{MockRBACClient: newPodReaderMock(), roleBindingLists: make(map[string]int)}
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.NoExitCode = false
+			o.Any = tt.any
+			if tt.output != "" {
+				o.Output = tt.output
+			}
+			if err := o.Complete([]string{tt.verbs, "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			err := o.Run(context.Background())
+			if (err == nil) != tt.wantOK {
+				t.Errorf("Run() error = %v, want ok = %v", err, tt.wantOK)
+			}
+			for namespace, n := range mock.roleBindingLists {
+				if n != 1 {
+					t.Errorf("RoleBindings in %q listed %d times, want once", namespace, n)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			if tt.output == "json" {
+				var got []output.JSONOutput
+				if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+					t.Fatalf("invalid JSON: %v", err)
+				}
+				if len(got) != 2 || got[0].Request.Verb != "get" || !got[0].Allowed || got[1].Request.Verb != "delete" || got[1].Allowed {
+					t.Errorf("results = %+v, want get allowed and delete denied", got)
+				}
+			}
+		})
+	}
+
+	o, _ := newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "default")
+	if err := o.Complete([]string{"get,,list", "pods"}); err == nil {
+		t.Error("Complete() with an empty verb: expected an error")
+	}
+}
+
+func TestRun_AllNamespaces(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddNamespace("default")
+	mock.AddNamespace("kube-system")
+	mock.AddNamespace("restricted")
+	mock.ListRoleBindingsErrors = map[string]error{"restricted": fmt.Errorf("rolebindings is forbidden")}
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "")
+	o.AllNamespaces = true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"in 3 namespace(s)",
+		"Cluster-wide: no ClusterRoleBinding grants it",
+		"default      ALLOWED  RoleBinding/default/read-pods -> Role/default/pod-reader",
+		"kube-system  DENIED",
+		"restricted   ERROR    failed to list role bindings in namespace restricted: rolebindings is forbidden",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "")
+	o.AllNamespaces = true
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.AllNamespacesOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !got.Namespaces["default"].Allowed || got.Namespaces["kube-system"].Allowed || got.Namespaces["restricted"].Error == "" {
+		t.Errorf("namespaces = %+v, want default allowed, kube-system denied, restricted with an error", got.Namespaces)
+	}
+
+	o, _ = newTestOptions(mock, "system:serviceaccount:default:test-sa", "")
+	o.AllNamespaces = true
+	if err := o.Complete([]string{"get", "/metrics"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "non-resource URLs") {
+		t.Errorf("Validate() error = %v, want --all-namespaces rejected for a non-resource URL", err)
+	}
+}
+
+func TestRun_AllNamespaces_ResourceNames(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddNamespace("prod")
+	mock.AddNamespace("staging")
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"tls"}}},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	for ns, role := range map[string]string{"prod": "tls-reader", "staging": "secret-reader"} {
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "read-secrets", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: role},
+		})
+	}
+
+	o, out := newTestOptions(mock, "alice", "")
+	o.AllNamespaces = true
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"prod       PARTIAL  RoleBinding/prod/read-secrets -> ClusterRole/tls-reader only for tls",
+		"staging    ALLOWED  RoleBinding/staging/read-secrets -> ClusterRole/secret-reader",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, out = newTestOptions(mock, "alice", "")
+	o.AllNamespaces = true
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.AllNamespacesOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	prod := got.Namespaces["prod"]
+	if prod.Allowed || !prod.PartiallyAllowed || fmt.Sprint(prod.AllowedNames) != "[tls]" {
+		t.Errorf("prod = %+v, want partially allowed for tls only", prod)
+	}
+	if staging := got.Namespaces["staging"]; !staging.Allowed || staging.PartiallyAllowed {
+		t.Errorf("staging = %+v, want allowed", staging)
+	}
+
+	// A ClusterRoleBinding limited to names doesn't allow the request anywhere
+	mock.RoleBindings = nil
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-tls"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "tls-reader"},
+	})
+	o, out = newTestOptions(mock, "alice", "")
+	o.AllNamespaces = true
+	o.NoExitCode = false
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); !errors.Is(err, exitcode.ErrDenied) {
+		t.Errorf("Run() error = %v, want a denial", err)
+	}
+	for _, want := range []string{
+		"Cluster-wide: allowed only for tls in every namespace through 1 path(s):",
+		"prod       PARTIAL  (cluster-wide) only for tls",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRun_NamespaceSelector(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	for name, team := range map[string]string{"payments-api": "payments", "payments-web": "payments", "search": "search"} {
+		mock.Namespaces = append(mock.Namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}}})
+	}
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	for _, ns := range []string{"payments-web", "search"} {
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "read-secrets", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+		})
+	}
+
+	run := func(t *testing.T, output string, showRisky bool) string {
+		t.Helper()
+		o, out := newTestOptions(mock, "alice", "")
+		o.NamespaceSelector = "team=payments"
+		o.Output = output
+		o.ShowRisky = showRisky
+		var args []string
+		if !showRisky {
+			args = []string{"get", "secrets"}
+		}
+		if err := o.Complete(args); err != nil {
+			t.Fatalf("Complete() error = %v", err)
+		}
+		if err := o.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if err := o.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return out.String()
+	}
+
+	for _, tt := range []struct {
+		output string
+		risky  bool
+		want   []string
+	}{
+		{output: "text", want: []string{
+			"in 2 namespace(s) matching team=payments",
+			"payments-api  DENIED",
+			"payments-web  ALLOWED  RoleBinding/payments-web/read-secrets -> ClusterRole/secret-reader",
+		}},
+		{output: "json", want: []string{`"namespaceSelector": "team=payments"`, `"payments-web": {`}},
+		{output: "dot", want: []string{`label="namespace payments-api (DENIED)";`, `label="namespace payments-web (ALLOWED)";`}},
+		{output: "mermaid", want: []string{`subgraph ns1["namespace payments-web (ALLOWED)"]`, "binding0 -->|refs| role0"}},
+		{output: "text", risky: true, want: []string{
+			"Risky permissions in 2 namespace(s) matching team=payments",
+			"Cluster-wide, in every namespace:\nNo risky permissions detected.",
+			"Namespace payments-api, through its RoleBindings:\nNo risky permissions detected.",
+			"Namespace payments-web, through its RoleBindings:\nFound ",
+			"  - secrets-access",
+		}},
+	} {
+		t.Run(fmt.Sprintf("%s risky=%v", tt.output, tt.risky), func(t *testing.T) {
+			got := run(t, tt.output, tt.risky)
+			if strings.Contains(got, "search") {
+				t.Errorf("output includes namespace search, which doesn't match:\n%s", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name      string
+		namespace string
+		selector  string
+		all       bool
+		wantErr   string
+	}{
+		{name: "invalid", selector: "team in (", wantErr: "invalid --namespace-selector"},
+		{name: "with -A", selector: "team=payments", all: true, wantErr: "cannot be used together"},
+		{name: "with -n", namespace: "default", selector: "team=payments", wantErr: "cannot be combined with -n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := newTestOptions(mock, "alice", tt.namespace)
+			o.NamespaceSelector = tt.selector
+			o.AllNamespaces = tt.all
+			if err := o.Complete([]string{"get", "secrets"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun_FromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.yaml")
+	manifests := `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: pod-reader
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: read-pods
+subjects:
+- kind: ServiceAccount
+  name: test-sa
+  namespace: default
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: pod-reader
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+`
+	if err := os.WriteFile(path, []byte(manifests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, out := newTestOptions(nil, "system:serviceaccount:default:test-sa", "default")
+	o.RBACClient = nil
+	o.FromFiles = []string{path}
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"ALLOWED", "RoleBinding: read-pods", "Rule defined at " + path + "#doc1 rules[0]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if got := o.ErrOut.(*bytes.Buffer).String(); !strings.Contains(got, "Warning: "+path+"#doc3: skipping apps/v1 Deployment") {
+		t.Errorf("stderr = %q, want a warning for the Deployment", got)
+	}
+}
+
+func TestRun_FromFileAggregation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.yaml")
+	manifests := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: edit
+aggregationRule:
+  clusterRoleSelectors:
+  - matchLabels:
+      rbac.authorization.k8s.io/aggregate-to-edit: "true"
+rules: []
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: pod-editor
+  labels:
+    rbac.authorization.k8s.io/aggregate-to-edit: "true"
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "delete"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: pod-exec
+  labels:
+    rbac.authorization.k8s.io/aggregate-to-edit: "true"
+rules:
+- apiGroups: [""]
+  resources: ["pods/exec"]
+  verbs: ["create"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: edit
+subjects:
+- kind: User
+  name: alice
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: edit
+`
+	if err := os.WriteFile(path, []byte(manifests), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, out := newTestOptions(nil, "alice", "default")
+	o.RBACClient = nil
+	o.FromFiles = []string{path}
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"ALLOWED", "(aggregated from, computed locally)", "ClusterRole: pod-editor", "Rule defined at " + path + "#doc2 rules[0]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if got := o.ErrOut.(*bytes.Buffer).String(); !strings.Contains(got, "Note: computed the rules of aggregated ClusterRole(s) edit from their aggregationRule") {
+		t.Errorf("stderr = %q, want a note for the computed aggregate", got)
+	}
+
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got.Grants) != 1 || got.Grants[0].AggregatedFrom == nil || !got.Grants[0].AggregatedFrom.Computed {
+		t.Fatalf("grants = %+v, want one computed aggregatedFrom", got.Grants)
+	}
+	// The rule is located in the role it was aggregated from, as in the text
+	if source := got.Grants[0].Source; source == nil || *source != (output.RuleSourceOutput{File: path, Document: 2, Rule: 0}) {
+		t.Errorf("source = %+v, want %s#doc2 rules[0]", source, path)
+	}
+
+	out.Reset()
+	o.Output = "gha"
+	o.ShowRisky = true
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := fmt.Sprintf("::error file=%s,line=22,title=RBAC risky permission%%3A pod-exec::", path)
+	if !strings.Contains(out.String(), want) || !strings.Contains(out.String(), "Rule defined at "+path+"#doc3 rules[0].") {
+		t.Errorf("output = %q, want the pod-exec finding annotated on %s#doc3", out.String(), path)
+	}
+}
+
+func TestRun_FromSnapshot(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.Version = "v1.30.2"
+	path := filepath.Join(t.TempDir(), "rbac-snapshot.json")
+
+	streams := genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+	so := snapshot.NewSnapshotOptions(streams)
+	so.RBACClient = mock
+	so.ClusterName = "prod"
+	so.OutputFile = path
+	if err := so.Run(context.Background()); err != nil {
+		t.Fatalf("snapshot Run() error = %v", err)
+	}
+
+	o, out := newTestOptions(nil, "system:serviceaccount:default:test-sa", "default")
+	o.RBACClient = nil
+	o.FromSnapshot = path
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "ALLOWED") || !strings.Contains(out.String(), "RoleBinding: read-pods") {
+		t.Errorf("output = %s, want allowed via read-pods", out.String())
+	}
+	if got := o.ErrOut.(*bytes.Buffer).String(); !strings.Contains(got, "Evaluating against snapshot of cluster prod (v1.30.2), captured ") {
+		t.Errorf("stderr = %q, want the snapshot's cluster, version, and capture time", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"apiVersion": "rbac-why.io/v0", "kind": "RBACSnapshot"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o, _ = newTestOptions(nil, "system:serviceaccount:default:test-sa", "default")
+	o.RBACClient = nil
+	o.FromSnapshot = path
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+		t.Errorf("Run() error = %v, want an unsupported version error", err)
+	}
+}
+
+func TestRun_Verify(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AccessReviewStatus = authorizationv1.SubjectAccessReviewStatus{Allowed: true, Reason: "RBAC: allowed by RoleBinding \"read-pods/default\""}
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Verify = true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "The API server agrees.") {
+		t.Errorf("output = %s, want agreement", out.String())
+	}
+	if len(mock.AccessReviews) != 1 {
+		t.Fatalf("reviews = %d, want 1", len(mock.AccessReviews))
+	}
+	spec := mock.AccessReviews[0].Spec
+	if spec.User != "system:serviceaccount:default:test-sa" || spec.ResourceAttributes.Namespace != "default" || spec.ResourceAttributes.Resource != "pods" ||
+		!slices.Contains(spec.Groups, "system:serviceaccounts:default") {
+		t.Errorf("review spec = %+v, want the subject's user, groups, and request", spec)
+	}
+
+	// A webhook allows what RBAC doesn't grant
+	mock.AccessReviewStatus = authorizationv1.SubjectAccessReviewStatus{Allowed: true, Reason: "allowed by webhook"}
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Verify = true
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"Local resolution: DENIED", "API server:       ALLOWED (allowed by webhook)", "Warning: the API server allows"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Verify = true
+	o.Output = "json"
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Verification == nil || !got.Verification.Allowed || got.Verification.Agrees || got.Verification.Reason != "allowed by webhook" {
+		t.Errorf("verification = %+v, want a disagreeing allowed decision", got.Verification)
+	}
+	if !strings.Contains(o.ErrOut.(*bytes.Buffer).String(), "Warning: the API server allows") {
+		t.Errorf("stderr = %q, want the mismatch warning", o.ErrOut.(*bytes.Buffer).String())
+	}
+
+	o, _ = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Verify = true
+	o.FromSnapshot = "rbac-snapshot.json"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "--from-snapshot") {
+		t.Errorf("Validate() error = %v, want --verify rejected offline", err)
+	}
+}
+
+func TestRun_AsUID(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AccessReviewStatus = authorizationv1.SubjectAccessReviewStatus{Allowed: true}
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	uid := "4b2d6a1e-0f3c-4e8a-9c1d-2a7b5e6f8d90"
+	o.ConfigFlags.ImpersonateUID = &uid
+	o.Verify = true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if want := "UID: " + uid + " (not used by RBAC; sent with --verify)"; !strings.Contains(out.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, out.String())
+	}
+	if len(mock.AccessReviews) != 1 || mock.AccessReviews[0].Spec.UID != uid {
+		t.Errorf("reviews = %+v, want one with uid %s", mock.AccessReviews, uid)
+	}
+
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Subject.UID != uid || !got.Allowed {
+		t.Errorf("subject uid = %q, allowed = %v; want %q, true", got.Subject.UID, got.Allowed, uid)
+	}
+
+	// Like --as-group, --as-uid needs a subject to go with
+	o, _ = newTestOptions(mock, "", "default")
+	o.ConfigFlags.ImpersonateUID = &uid
+	if err := o.Complete([]string{"get", "pods"}); err == nil || !strings.Contains(err.Error(), "--as-uid requires --as or --sa") {
+		t.Errorf("Complete() error = %v, want --as-uid rejected without --as", err)
+	}
+}
+
+func TestRun_OutputSAR(t *testing.T) {
+	mock := newPodReaderMock()
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	uid := "4b2d6a1e-0f3c-4e8a-9c1d-2a7b5e6f8d90"
+	extra := []string{"reason=incident-42", "scopes=read", "scopes=write"}
+	o.ConfigFlags.ImpersonateUID = &uid
+	o.ConfigFlags.ImpersonateUserExtra = &extra
+	o.Output = "sar"
+	if err := o.Complete([]string{"get", "pods/api-123/log"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var review authorizationv1.SubjectAccessReview
+	if err := yaml.UnmarshalStrict(out.Bytes(), &review); err != nil {
+		t.Fatalf("invalid SubjectAccessReview: %v\n%s", err, out.String())
+	}
+	want := authorizationv1.SubjectAccessReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "authorization.k8s.io/v1", Kind: "SubjectAccessReview"},
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   "system:serviceaccount:default:test-sa",
+			Groups: []string{"system:authenticated", "system:serviceaccounts", "system:serviceaccounts:default"},
+			UID:    uid,
+			Extra:  map[string]authorizationv1.ExtraValue{"reason": {"incident-42"}, "scopes": {"read", "write"}},
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: "default", Verb: "get", Resource: "pods", Subresource: "log", Name: "api-123",
+			},
+		},
+	}
+	if !reflect.DeepEqual(review, want) {
+		t.Errorf("review = %+v, want %+v", review, want)
+	}
+	if strings.Contains(out.String(), "status") {
+		t.Errorf("review has a status:\n%s", out.String())
+	}
+
+	// Several verbs print one review each
+	o, out = newTestOptions(mock, "alice", "default")
+	o.Output = "sar"
+	if err := o.Complete([]string{"get,delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"---\napiVersion: authorization.k8s.io/v1", "verb: get", "verb: delete", "user: alice"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, _ = newTestOptions(mock, "alice", "default")
+	o.Output, o.ShowRisky = "sar", true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "output format sar is only supported for a single VERB RESOURCE check") {
+		t.Errorf("Validate() error = %v, want -o sar rejected with --show-risky", err)
+	}
+
+	bad := []string{"reason"}
+	o, _ = newTestOptions(mock, "alice", "default")
+	o.ConfigFlags.ImpersonateUserExtra = &bad
+	if err := o.Complete([]string{"get", "pods"}); err == nil || !strings.Contains(err.Error(), "expected KEY=VALUE") {
+		t.Errorf("Complete() error = %v, want a malformed --as-user-extra rejected", err)
+	}
+}
+
+func TestRun_OutputCSV(t *testing.T) {
+	mock := newPodReaderMock()
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output = "csv"
+	if err := o.Complete([]string{"list", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "subject,verb,resource,namespace,binding_kind,binding_name,binding_namespace,role_kind,role_name,role_namespace,rule_verbs,rule_resources,scope\n" +
+		"system:serviceaccount:default:test-sa,list,pods,default,RoleBinding,read-pods,default,Role,pod-reader,default,\"get,list\",pods,namespace\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output, o.NoHeaders = "csv", true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if strings.HasPrefix(out.String(), "subject,") || !strings.HasPrefix(out.String(), "system:serviceaccount:default:test-sa,get,pods,") {
+		t.Errorf("output = %q, want the grant row without a header", out.String())
+	}
+
+	// --show-risky has one row per finding and grant
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-secrets", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "secret-reader"},
+	})
+	o, out = newTestOptions(mock, "alice", "default")
+	o.Output, o.ShowRisky = "csv", true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	wantRecords := [][]string{
+		{"category", "severity", "binding", "role", "rule"},
+		{"secrets-access", "critical", "RoleBinding/default/read-secrets", "Role/default/secret-reader", output.FormatRule(rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"secrets"}})},
+	}
+	if len(records) < 2 || !reflect.DeepEqual(records[:2], wantRecords) {
+		t.Errorf("records = %q, want them to start with %q", records, wantRecords)
+	}
+
+	o, _ = newTestOptions(mock, "alice", "default")
+	o.NoHeaders = true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "--no-headers is only supported with -o csv") {
+		t.Errorf("Validate() error = %v, want --no-headers rejected without -o csv", err)
+	}
+}
+
+func TestRun_OutputMarkdown(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec-pods", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "pod-exec"},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-exec"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}}},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output = "markdown"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := "| `RoleBinding/default/read-pods` | `Role/default/pod-reader` | `apiGroups=[\"\"], resources=[pods], verbs=[get list]` | namespace |\n"
+	if !strings.HasPrefix(out.String(), "**ALLOWED**: ") || !strings.HasSuffix(out.String(), want) {
+		t.Errorf("output = %q, want the verdict and then a table ending with %q", out.String(), want)
+	}
+
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output, o.ShowRisky = "markdown", true
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "| `pod-exec` | `RoleBinding/default/exec-pods` | `ClusterRole/pod-exec` |") {
+		t.Errorf("output missing the pod-exec finding:\n%s", out.String())
+	}
+}
+
+func TestRun_OutputGoTemplate(t *testing.T) {
+	mock := newPodReaderMock()
+	file := filepath.Join(t.TempDir(), "names.tmpl")
+	if err := os.WriteFile(file, []byte("{{range .grants}}{{.binding.name | lower}}\n{{end}}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		output   string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{name: "inline", output: "go-template={{.allowed}}", want: "true"},
+		{name: "template flag", output: "go-template", template: `{{range .grants}}{{join "," .matchingRule.verbs}}{{end}}`, want: "get,list"},
+		{name: "file", output: "go-template-file=" + file, want: "read-pods\n"},
+		{name: "parse error", output: "go-template={{.allowed}}\n{{nope .grants}}", wantErr: `invalid go-template at line 2, column 3: function "nope" not defined`},
+		{name: "execution error", output: `go-template={{join "," .allowed}}`, wantErr: "failed to execute go-template at line 1, column 3: "},
+		{name: "template without go-template", output: "json", template: "{{.allowed}}", wantErr: "--template is only supported with -o go-template"},
+		{name: "no template", output: "go-template", wantErr: "-o go-template requires a template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.Output, o.Template = tt.output, tt.template
+			err := o.Complete([]string{"get", "pods"})
+			if err == nil {
+				err = o.Validate()
+			}
+			if err == nil {
+				err = o.Run(context.Background())
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_OutputWide(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "workload-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "sa-readers"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "workload-reader"},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "any-group-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{"*"}, Resources: []string{"pods"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "any-group-readers"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "any-group-reader"},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.Output = "wide"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want := `ALLOWED: ServiceAccount default/test-sa can get pods in namespace default
+
+PATH  BINDING                               ROLE                          RULE                                             SCOPE         MATCHED-VIA
+1     ClusterRoleBinding/sa-readers         ClusterRole/workload-reader   get pods,deployments,pods.apps,deployments.apps  cluster-wide  Group system:serviceaccounts
+2     ClusterRoleBinding/any-group-readers  ClusterRole/any-group-reader  get pods (all API groups)                        cluster-wide  ServiceAccount default/test-sa
+3     RoleBinding/default/read-pods         Role/default/pod-reader       get,list pods                                    namespace     ServiceAccount default/test-sa
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	o, out = newTestOptions(mock, "alice", "default")
+	o.Output = "wide"
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "DENIED: No RBAC rules grant delete pods to User alice in namespace default\n") {
+		t.Errorf("output = %q, want the verdict only", out.String())
+	}
+}
+
+func TestRun_ShowCommands(t *testing.T) {
+	mock := newPodReaderMock()
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	groups := []string{"ci"}
+	o.ConfigFlags.ImpersonateGroup = &groups
+	o.ShowCommands = true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	canI := "kubectl auth can-i get pods -n default --as system:serviceaccount:default:test-sa --as-group ci"
+	for _, want := range []string{
+		"Commands:\n  # Ask the API server the same question\n  " + canI + "\n",
+		"  # Path 1: RoleBinding read-pods -> Role pod-reader\n  kubectl get rolebinding read-pods -n default -o yaml\n  kubectl describe rolebinding read-pods -n default\n",
+		"  kubectl get role pod-reader -n default -o yaml\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !slices.Equal(got.Commands, []string{canI}) || len(got.Grants) != 1 || len(got.Grants[0].Commands) != 4 {
+		t.Errorf("commands = %q, grants = %+v; want the can-i command and four per grant", got.Commands, got.Grants)
+	}
+
+	o, _ = newTestOptions(mock, "alice", "default")
+	o.ShowCommands, o.Quiet = true, true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "--show-commands is only supported for a single VERB RESOURCE check") {
+		t.Errorf("Validate() error = %v, want --show-commands rejected with --quiet", err)
+	}
+}
+
+func TestRun_SelfReviewFallback(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.ListClusterRoleBindingsError = apierrors.NewForbidden(rbacv1.Resource("clusterrolebindings"), "", fmt.Errorf("no access"))
+	mock.SelfAccessReviewStatus = authorizationv1.SubjectAccessReviewStatus{Allowed: true, Reason: "allowed by webhook"}
+
+	// The caller's own identity, as resolved from the current context
+	o, out := newTestOptions(mock, "", "default")
+	o.As, o.Verb, o.Resource, o.Namespace = "alice", "get", "pods", "default"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"ALLOWED: User alice can get pods in namespace default (according to the API server)", "Why chain unavailable: you cannot read the RBAC objects", "API server reason: allowed by webhook"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, out = newTestOptions(mock, "", "default")
+	o.As, o.Verb, o.Resource, o.Namespace, o.Output = "alice", "get", "pods", "default", "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Mode != rbac.ModeSelfAccessReview || !got.Allowed || got.Limitation == "" {
+		t.Errorf("mode = %q, allowed = %t, limitation = %q, want an allowed self-access-review result", got.Mode, got.Allowed, got.Limitation)
+	}
+
+	mock.SelfRulesReviewStatus = authorizationv1.SubjectRulesReviewStatus{
+		ResourceRules: []authorizationv1.ResourceRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	}
+	o, out = newTestOptions(mock, "", "default")
+	o.As, o.Namespace, o.ShowRisky = "alice", "default", true
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{"Limited analysis: you cannot read the RBAC objects", "SelfSubjectRulesReview in namespace default", "(binding unknown)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// Another subject's permissions can't be asked about this way
+	o, _ = newTestOptions(mock, "bob", "default")
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Run() error = %v, want the forbidden error for --as", err)
+	}
+}
+
+func TestWhoAmI(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+- name: dev
+  context: {cluster: dev-cluster, user: jane, namespace: team-a}
+clusters:
+- name: dev-cluster
+  cluster: {server: "https://127.0.0.1:6443"}
+users:
+- name: jane
+  user: {token: secret}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mock := client.NewMockRBACClient()
+	mock.SelfUser = &authenticationv1.UserInfo{Username: "jane@example.com", Groups: []string{"dev", "system:authenticated"}}
+
+	out := &bytes.Buffer{}
+	o := NewWhoAmIOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: &bytes.Buffer{}})
+	o.ConfigFlags.KubeConfig = &kubeconfig
+	o.IdentityClient = mock
+	if err := o.Complete(); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"AuthMethod: token",
+		"From kubeconfig (used by can-i without --as):\n  Username: jane\n",
+		"Confirmed by the API server (SelfSubjectReview):\n  Username: jane@example.com\n  Groups:   dev, system:authenticated",
+		"Warning: the API server sees a different identity than the kubeconfig shows. To check the server's view, pass --as jane@example.com --as-group dev to can-i.",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	mock.SelfUser = nil
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.WhoAmIOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Kubeconfig.UserName != "jane" || got.Server != nil || !strings.Contains(got.ServerError, "does not serve SelfSubjectReview") {
+		t.Errorf("output = %+v, want the kubeconfig identity and a server error", got)
+	}
+}
+
+func TestParseResourceArg(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    parsedResource
+		wantErr bool
+	}{
+		{arg: "pods", want: parsedResource{Resource: "pods"}},
+		{arg: "pods/exec", want: parsedResource{Resource: "pods", Subresource: "exec"}},
+		{arg: "pods/*", want: parsedResource{Resource: "pods", Subresource: "*"}},
+		{arg: "secrets/db-password", want: parsedResource{Resource: "secrets", ResourceName: "db-password"}},
+		{arg: "pods/web-0/exec", want: parsedResource{Resource: "pods", ResourceName: "web-0", Subresource: "exec"}},
+		{arg: "deployments.apps/api/scale", want: parsedResource{Resource: "deployments", APIGroup: "apps", ResourceName: "api", Subresource: "scale"}},
+		{arg: "deployments.apps/v1", want: parsedResource{Resource: "deployments", APIGroup: "apps", Version: "v1"}},
+		{arg: "deployments.apps/v1/api", want: parsedResource{Resource: "deployments", APIGroup: "apps", Version: "v1", ResourceName: "api"}},
+		{arg: "pods.v1", want: parsedResource{Resource: "pods", Version: "v1"}},
+		{arg: "cronjobs.v1.batch", want: parsedResource{Resource: "cronjobs", APIGroup: "batch", Version: "v1"}},
+		{arg: "deployments.v1.apps/api/scale", want: parsedResource{Resource: "deployments", APIGroup: "apps", Version: "v1", ResourceName: "api", Subresource: "scale"}},
+		{arg: "networkpolicies.networking.k8s.io", want: parsedResource{Resource: "networkpolicies", APIGroup: "networking.k8s.io"}},
+		{arg: "ingresses.v1beta1.networking.k8s.io", want: parsedResource{Resource: "ingresses", APIGroup: "networking.k8s.io", Version: "v1beta1"}},
+		{arg: "certificates.cert-manager.io", want: parsedResource{Resource: "certificates", APIGroup: "cert-manager.io"}},
+		{arg: "widgets.v2.example.com", want: parsedResource{Resource: "widgets", APIGroup: "example.com", Version: "v2"}},
+		{arg: "deployments.v1.apps/v2", wantErr: true},
+		{arg: "/metrics", want: parsedResource{NonResourceURL: "/metrics"}},
+		{arg: "secrets/", wantErr: true},
+		{arg: "pods/a/b/c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, err := parseResourceArg(tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResourceArg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseResourceArg() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComplete_SubresourceFlag(t *testing.T) {
+	tests := []struct {
+		resource    string
+		subresource string
+		wantName    string
+		wantSub     string
+		wantErr     string
+	}{
+		{resource: "pods", subresource: "exec", wantSub: "exec"},
+		{resource: "pods/exec", subresource: "exec", wantSub: "exec"},
+		{resource: "pods/web-0", subresource: "log", wantName: "web-0", wantSub: "log"},
+		{resource: "crontabs.stable.example.com", subresource: "rollback", wantSub: "rollback"},
+		{resource: "pods/log", subresource: "exec", wantErr: "--subresource exec conflicts with the log subresource in pods/log"},
+		{resource: "deployments", subresource: "sacle", wantErr: `unknown subresource "sacle"; did you mean scale?`},
+		{resource: "/metrics", subresource: "status", wantErr: "--subresource cannot be used with a non-resource URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource+"+"+tt.subresource, func(t *testing.T) {
+			o, _ := newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "default")
+			o.SubresourceFlag = tt.subresource
+			err := o.Complete([]string{"get", tt.resource})
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Complete() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if o.ResourceName != tt.wantName || o.Subresource != tt.wantSub {
+				t.Errorf("name, subresource = %q, %q, want %q, %q", o.ResourceName, o.Subresource, tt.wantName, tt.wantSub)
+			}
+		})
+	}
+}
+
+func TestRun_ResourceName(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-secret", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"db-password"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-db-secret", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "db-secret"},
+	})
+
+	tests := []struct {
+		resource string
+		want     []string
+	}{
+		{resource: "secrets/db-password", want: []string{"ALLOWED: ServiceAccount default/test-sa can get secrets (name: db-password)", "Name: db-password is listed in resourceNames"}},
+		{resource: "pods/web-0", want: []string{"ALLOWED", "Name: rule has no resourceNames, so it covers every pods, not only web-0"}},
+		{resource: "secrets/api-token", want: []string{"DENIED: No RBAC rules grant get secrets (name: api-token)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			if err := o.Complete([]string{"get", tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_KubectlVerb(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-exec", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec-pods", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-exec"},
+	})
+
+	o, _ := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	if err := o.Complete([]string{"exec", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	err := o.Validate()
+	if err == nil || !strings.Contains(err.Error(), "pod exec requires: create pods/exec") {
+		t.Fatalf("Validate() error = %v, want a suggestion to check create pods/exec", err)
+	}
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.AutoCorrect = true
+	if err := o.Complete([]string{"exec", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if o.Verb != "create" || o.Subresource != "exec" {
+		t.Errorf("corrected request = %s %s/%s, want create pods/exec", o.Verb, o.Resource, o.Subresource)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "ALLOWED") {
+		t.Errorf("expected the corrected check to be allowed, got:\n%s", out.String())
+	}
+}
+
+func TestRun_WildcardAudit(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "everything"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "break-glass"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "everything"},
+	})
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps-reader", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"*"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-apps", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "apps-reader"},
+	})
+
+	tests := []struct {
+		verb, resource string
+		showRisky      bool
+		wantFull       int
+		wantGroup      int
+	}{
+		{verb: "get", resource: "*", wantFull: 1, wantGroup: 1},
+		{verb: "get", resource: "*.apps", wantFull: 1, wantGroup: 1},
+		{verb: "get", resource: "*.batch", wantFull: 1},
+		{verb: "delete", resource: "*", wantFull: 1},
+		{verb: "get", resource: "*", showRisky: true, wantFull: 1, wantGroup: 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s risky=%t", tt.verb, tt.resource, tt.showRisky), func(t *testing.T) {
+			o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+			o.Output = "json"
+			o.ShowRisky = tt.showRisky
+			if err := o.Complete([]string{tt.verb, tt.resource}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if err := o.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			var got output.WildcardAuditOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v\n%s", err, out.String())
+			}
+			if got.FullWildcards != tt.wantFull || got.GroupWildcards != tt.wantGroup {
+				t.Errorf("full = %d, group = %d, want %d and %d", got.FullWildcards, got.GroupWildcards, tt.wantFull, tt.wantGroup)
+			}
+			if !got.Grants[0].AllGroups || got.Grants[0].Role.Name != "everything" {
+				t.Errorf("first grant = %+v, want the full wildcard first", got.Grants[0])
+			}
+			if hasRisks := len(got.Grants[0].Risks) > 0; hasRisks != tt.showRisky {
+				t.Errorf("risks = %v, want them only with --show-risky", got.Grants[0].Risks)
+			}
+		})
+	}
+}
+
+func TestWhoCan(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-pods-again", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+
+	out := &bytes.Buffer{}
+	o := NewWhoCanOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	namespace := "default"
+	o.ConfigFlags.Namespace = &namespace
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := `1 subject(s) can get pods in namespace default:
+
+ServiceAccount default/test-sa
+  RoleBinding/default/read-pods -> Role/default/pod-reader
+    Rule: apiGroups=[""], resources=[pods], verbs=[get list]
+  RoleBinding/default/read-pods-again -> Role/default/pod-reader
+    Rule: apiGroups=[""], resources=[pods], verbs=[get list]
+`
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("output = %q, want it to start with %q", out.String(), want)
+	}
+
+	out.Reset()
+	o.Output = "wide"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	want = `1 subject(s) can get pods in namespace default:
+
+SUBJECT                         BINDING                              ROLE                     RULE           SCOPE
+ServiceAccount default/test-sa  RoleBinding/default/read-pods        Role/default/pod-reader  get,list pods  namespace
+ServiceAccount default/test-sa  RoleBinding/default/read-pods-again  Role/default/pod-reader  get,list pods  namespace
+`
+	if !strings.HasPrefix(out.String(), want) {
+		t.Errorf("output = %q, want it to start with %q", out.String(), want)
+	}
+
+	// A subject limited to resourceNames is marked as such
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-reader", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web-0"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-web", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "web-reader"},
+	})
+	out.Reset()
+	o.Output = ""
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "\nUser alice (only web-0)\n") {
+		t.Errorf("output = %q, want alice restricted to web-0", out.String())
+	}
+
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.WhoCanOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, s := range got.Subjects {
+		restricted := s.Name == "alice"
+		if s.Restricted != restricted || (restricted && fmt.Sprint(s.AllowedNames) != "[web-0]") {
+			t.Errorf("subject %s: restricted = %v, allowedNames = %v", s.Name, s.Restricted, s.AllowedNames)
+		}
+	}
+}
+
+func TestWhoCan_KeepGoing(t *testing.T) {
+	newMock := func() *client.MockRBACClient {
+		mock := newPodReaderMock()
+		mock.AddClusterRole(rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "view"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+		})
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "read-pods", Namespace: "prod"},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+		})
+		for _, ns := range []string{"default", "prod"} {
+			mock.Namespaces = append(mock.Namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		}
+		// RoleBindings can't be listed across namespaces, nor in prod
+		denied := errors.New("admission webhook denied the request")
+		mock.ListRoleBindingsErrors = map[string]error{"": denied, "prod": denied}
+		return mock
+	}
+
+	tests := []struct {
+		name      string
+		keepGoing bool
+		strict    bool
+		wantCode  int
+	}{
+		{name: "aborts by default", wantCode: exitcode.Error},
+		{name: "keep going", keepGoing: true},
+		{name: "keep going strict", keepGoing: true, strict: true, wantCode: exitcode.Incomplete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+			o := NewWhoCanOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: errOut})
+			o.RBACClient = newMock()
+			o.AllNamespaces = true
+			o.KeepGoing = tt.keepGoing
+			o.Strict = tt.strict
+			o.Output = "json"
+			if err := o.Complete([]string{"get", "pods"}); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if err := o.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			err := o.Run(context.Background())
+			if code := exitcode.For(err); code != tt.wantCode {
+				t.Fatalf("Run() error = %v, exit code %d; want %d", err, code, tt.wantCode)
+			}
+			if !tt.keepGoing {
+				return
+			}
+
+			var got output.WhoCanOutput
+			if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON output: %v", err)
+			}
+			if !got.Partial || len(got.Skipped) != 1 || got.Skipped[0].Scope != "namespace prod" {
+				t.Errorf("partial = %v, skipped = %+v; want namespace prod skipped", got.Partial, got.Skipped)
+			}
+			if len(got.Subjects) != 1 || got.Subjects[0].Name != "test-sa" {
+				t.Errorf("subjects = %+v, want the ServiceAccount bound in default", got.Subjects)
+			}
+			if !strings.Contains(errOut.String(), "Warning: skipping namespace prod") {
+				t.Errorf("stderr = %q, want a warning for namespace prod", errOut.String())
+			}
+		})
+	}
+
+	// --keep-going is for -A, where namespaces can be skipped
+	o := NewWhoCanOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}})
+	namespace := "default"
+	o.ConfigFlags.Namespace = &namespace
+	o.KeepGoing = true
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "only supported with -A") {
+		t.Errorf("Validate() error = %v, want --keep-going to require -A", err)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "edit"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get", "create", "delete"}, APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-edit", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "staging"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "someone-else", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+	})
+
+	out := &bytes.Buffer{}
+	o := NewNamespacesOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	as := "system:serviceaccount:default:test-sa"
+	o.ConfigFlags.Impersonate = &as
+	o.Output = "json"
+	if err := o.Complete(); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.NamespacesOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if got.ClusterWide != nil {
+		t.Errorf("ClusterWide = %+v, want none", got.ClusterWide)
+	}
+	var summaries []string
+	for _, ns := range got.Namespaces {
+		summaries = append(summaries, ns.Namespace+": "+ns.Summary)
+	}
+	want := []string{"default: view-equivalent via RoleBinding read-pods", "prod: edit-equivalent via RoleBinding dev-edit"}
+	if fmt.Sprint(summaries) != fmt.Sprint(want) {
+		t.Errorf("namespaces = %v, want %v", summaries, want)
+	}
+}
+
+func TestList(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-admin"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+		},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-admins"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "pod-admin"},
+	})
+
+	as, namespace := "system:serviceaccount:default:test-sa", "default"
+	out := &bytes.Buffer{}
+	o := NewListOptions(genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	o.ConfigFlags.Impersonate = &as
+	o.ConfigFlags.Namespace = &namespace
+	if err := o.Complete(); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"Permissions of ServiceAccount default/test-sa (in namespace default):",
+		"get   (core)    pods       *                ClusterRoleBinding/pod-admins -> ClusterRole/pod-admin (+1 more)",
+		"list  (core)    pods       *                RoleBinding/default/read-pods -> Role/default/pod-reader",
+		"*     (core)    pods/exec  *      yes       ClusterRoleBinding/pod-admins -> ClusterRole/pod-admin",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	o.Output = "json"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.ListOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(got.Sources) != 2 || got.Sources[0].Binding.Name != "pod-admins" || len(got.Sources[0].Rules) != 2 || !got.Sources[0].Rules[1].Wildcard {
+		t.Errorf("sources = %+v, want pod-admins with two rules, the second a wildcard, then read-pods", got.Sources)
+	}
+
+	out.Reset()
+	o.Output = "wide"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"VERB  APIGROUP  RESOURCE   NAMES  WILDCARD  BINDING                        ROLE                     RULE\n",
+		"get   (core)    pods       *                ClusterRoleBinding/pod-admins  ClusterRole/pod-admin    get pods\n",
+		"get   (core)    pods       *                RoleBinding/default/read-pods  Role/default/pod-reader  get,list pods\n",
+		"*     (core)    pods/exec  *      yes       ClusterRoleBinding/pod-admins  ClusterRole/pod-admin    * pods/exec\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	o.Output = "csv"
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	records, err := csv.NewReader(out).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	// A header, then get pods through both grants, list pods, and * pods/exec
+	if len(records) != 5 || records[0][0] != "subject" || records[4][1] != "*" || records[4][2] != "pods/exec" || records[4][12] != "cluster-wide" {
+		t.Errorf("records = %q", records)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	const changes = `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: pod-deleter
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["delete"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: delete-pods
+subjects:
+- kind: ServiceAccount
+  name: test-sa
+  namespace: default
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: pod-deleter
+---
+# delete
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: read-pods
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: pod-reader
+`
+	path := filepath.Join(t.TempDir(), "changes.yaml")
+	if err := os.WriteFile(path, []byte(changes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) output.SimulationOutput {
+		t.Helper()
+		out := &bytes.Buffer{}
+		o := NewSimulateOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+		as, namespace := "system:serviceaccount:default:test-sa", "default"
+		o.ConfigFlags.Impersonate = &as
+		o.ConfigFlags.Namespace = &namespace
+		o.Filenames = []string{path}
+		o.RBACClient = newPodReaderMock()
+		o.Output = "json"
+		if err := o.Complete(args); err != nil {
+			t.Fatalf("Complete() error = %v", err)
+		}
+		if err := o.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if err := o.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		var got output.SimulationOutput
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON output: %v", err)
+		}
+		return got
+	}
+
+	got := run()
+	if len(got.Gained) != 1 || got.Gained[0].Permission != "delete pods" || got.Gained[0].GrantedVia[0].Binding.Name != "delete-pods" {
+		t.Errorf("gained = %+v, want delete pods via delete-pods", got.Gained)
+	}
+	if len(got.Lost) != 2 {
+		t.Errorf("lost = %+v, want get and list pods from the deleted read-pods", got.Lost)
+	}
+
+	got = run("delete", "pods")
+	if got.Before == nil || got.Before.Allowed || got.After == nil || !got.After.Allowed {
+		t.Errorf("before = %+v, after = %+v, want denied then allowed", got.Before, got.After)
+	}
+}
+
+func TestSimulate_Remove(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-admins", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-admin"},
+	})
+	mock.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-admin", Namespace: "default"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get", "delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+	})
+
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	o := NewSimulateOptions(genericclioptions.IOStreams{Out: out, ErrOut: errOut})
+	as, namespace := "system:serviceaccount:default:test-sa", "default"
+	o.ConfigFlags.Impersonate = &as
+	o.ConfigFlags.Namespace = &namespace
+	o.Remove = []string{"rolebinding/default/read-pods", "clusterrolebinding/typo"}
+	o.RBACClient = mock
+	o.Output = "json"
+
+	if err := o.Complete(nil); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var got output.SimulationOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(got.Lost) != 1 || got.Lost[0].Permission != "list pods" {
+		t.Errorf("lost = %+v, want [list pods]", got.Lost)
+	}
+	if len(got.StillGranted) != 1 || got.StillGranted[0].Permission != "get pods" || got.StillGranted[0].GrantedVia[0].Binding.Name != "pod-admins" {
+		t.Errorf("stillGranted = %+v, want get pods via pod-admins", got.StillGranted)
+	}
+	if !strings.Contains(errOut.String(), "ClusterRoleBinding typo not found") {
+		t.Errorf("stderr = %q, want a warning for the missing binding", errOut.String())
+	}
+
+	o.Remove = []string{"rolebinding/read-pods"}
+	if err := o.Validate(); err == nil {
+		t.Error("Validate() accepted a RoleBinding reference without a namespace")
+	}
+}
+
+func TestRun_Suggest(t *testing.T) {
+	o, out := newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "default")
+	o.Suggest = true
+	if err := o.Complete([]string{"delete", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// stdout holds only the manifest, so it can be piped to kubectl apply
+	for _, want := range []string{"kind: Role\n", "kind: RoleBinding\n", "name: rbacwhy-default-test-sa-delete-pods\n", "- delete\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stdout missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "DENIED") || !strings.Contains(o.ErrOut.(*bytes.Buffer).String(), "DENIED") {
+		t.Errorf("the result should go to stderr, stdout:\n%s", out.String())
+	}
+
+	o, out = newTestOptions(newPodReaderMock(), "system:serviceaccount:default:test-sa", "")
+	o.Suggest = true
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "nodes"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got.Suggestion) != 2 || got.Suggestion[0]["kind"] != "ClusterRole" || got.Suggestion[1]["kind"] != "ClusterRoleBinding" {
+		t.Errorf("suggestion = %v, want a ClusterRole and ClusterRoleBinding for nodes", got.Suggestion)
+	}
+}
+
+func TestRun_SuggestLeastPrivilege(t *testing.T) {
+	mock := newPodReaderMock()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "everything"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"*"}}},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "everything"},
+	})
+
+	o, out := newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.SuggestLeastPrivilege = true
+	if err := o.Complete([]string{"get", "configmaps"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	for _, want := range []string{
+		"Every path matches only through a wildcard rule",
+		"Note: matched via wildcard verb, resource",
+		`Least-privilege rule: apiGroups=[""], resources=[configmaps], verbs=[get]`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	o, out = newTestOptions(mock, "system:serviceaccount:default:test-sa", "default")
+	o.SuggestLeastPrivilege = true
+	o.Output = "json"
+	if err := o.Complete([]string{"get", "pods"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var got output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, g := range got.Grants {
+		wildcard := g.Role.Name == "everything"
+		if wildcard != (g.NarrowedRule != nil) || wildcard != slices.Equal(g.Wildcards, []string{"verb", "resource"}) {
+			t.Errorf("grant %s: wildcards = %v, narrowedRule = %+v", g.Role.Name, g.Wildcards, g.NarrowedRule)
+		}
+	}
+}
+
+func TestNewCmd_Flags(t *testing.T) {
+	// Building a command panics if a flag is registered twice, e.g. one of
+	// the listing flags with one of kubectl's
+	streams := genericclioptions.IOStreams{In: &bytes.Buffer{}, Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}
+	for name, newCmd := range map[string]func(genericclioptions.IOStreams) *cobra.Command{
+		"can-i":   NewCmdRbacWhy,
+		"who-can": NewCmdWhoCan,
+		"list":    NewCmdList,
+	} {
+		cmd := newCmd(streams)
+		if cmd.Flags().Lookup("cache-dir") == nil || cmd.Flags().Lookup("cache-ttl") == nil {
+			t.Errorf("%s: --cache-dir or --cache-ttl is not registered", name)
+		}
+	}
+}
+
+func TestListingOptions_Cache(t *testing.T) {
+	dir := t.TempDir()
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "view"}})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+	})
+	ctx := context.Background()
+
+	// The first run lists from the cluster and reports no cached data
+	first := client.NewDiskCache(mock, dir, DefaultCacheTTL)
+	if _, err := first.ListClusterRoleBindings(ctx); err != nil {
+		t.Fatalf("ListClusterRoleBindings() error = %v", err)
+	}
+	o := ListingOptions{cache: first}
+	var errOut bytes.Buffer
+	o.printAge(&errOut)
+	if errOut.Len() != 0 {
+		t.Errorf("first run noted %q, want nothing", errOut.String())
+	}
+
+	// A later run within the TTL reads the cached list, not the changes
+	mock.ClusterRoleBindings.Items = nil
+	second := client.NewDiskCache(mock, dir, DefaultCacheTTL)
+	crbs, err := second.ListClusterRoleBindings(ctx)
+	if err != nil {
+		t.Fatalf("ListClusterRoleBindings() error = %v", err)
+	}
+	if len(crbs.Items) != 1 {
+		t.Errorf("cached run listed %d bindings, want 1", len(crbs.Items))
+	}
+	o = ListingOptions{cache: second}
+	o.printAge(&errOut)
+	if !strings.Contains(errOut.String(), "Note: used RBAC objects cached 0s ago (use --no-cache") {
+		t.Errorf("stderr = %q, want a note with the cache's age", errOut.String())
+	}
+
+	// A role that isn't cached is read alone, without listing every role
+	if _, err := second.GetClusterRole(ctx, "view"); err != nil {
+		t.Errorf("GetClusterRole() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clusterroles.json")); !os.IsNotExist(err) {
+		t.Errorf("GetClusterRole() cached the ClusterRole list: %v", err)
+	}
+
+	// Once the roles are listed, they are looked up in the cached list
+	if _, err := second.ListClusterRoles(ctx); err != nil {
+		t.Fatalf("ListClusterRoles() error = %v", err)
+	}
+	mock.ClusterRoles.Items = nil
+	if _, err := second.GetClusterRole(ctx, "view"); err != nil {
+		t.Errorf("GetClusterRole() error = %v", err)
+	}
+	if _, err := second.GetClusterRole(ctx, "missing"); !apierrors.IsNotFound(err) {
+		t.Errorf("GetClusterRole() error = %v, want NotFound", err)
+	}
+
+	// With no TTL, nothing is reused
+	crbs, err = client.NewDiskCache(mock, dir, 0).ListClusterRoleBindings(ctx)
+	if err != nil || len(crbs.Items) != 0 {
+		t.Errorf("ListClusterRoleBindings() = %d bindings, %v; want the fresh empty list", len(crbs.Items), err)
+	}
+
+	o = NewListingOptions()
+	o.CacheTTL = -time.Minute
+	if err := o.Validate(); err == nil {
+		t.Error("Validate() accepted a negative --cache-ttl")
+	}
+	o = NewListingOptions()
+	o.PageSize = -1
+	if err := o.Validate(); err == nil {
+		t.Error("Validate() accepted a negative --page-size")
+	}
+}
+
+// lockedBuffer is a buffer a command writes to while the test reads it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitForOutput waits until b contains want
+func waitForOutput(t *testing.T, b *lockedBuffer, want string) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(b.String(), want) {
+			return
+		}
+	}
+	t.Fatalf("output never contained %q:\n%s", want, b.String())
+}
+
+func TestRun_Watch(t *testing.T) {
+	clientset := fake.NewClientset(&rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	o, _ := newTestOptions(client.NewK8sRBACClientFromClientset(clientset), "system:serviceaccount:default:test-sa", "default")
+	out, errOut := &lockedBuffer{}, &lockedBuffer{}
+	o.Out, o.ErrOut = out, errOut
+	o.Watch = true
+	o.watchQuiet = 10 * time.Millisecond
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- o.Run(ctx) }()
+
+	waitForOutput(t, errOut, "Watching for RBAC changes")
+	if !strings.Contains(out.String(), "DENIED") {
+		t.Fatalf("first result = %q, want DENIED", out.String())
+	}
+	_, err := clientset.RbacV1().RoleBindings("default").Create(ctx, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-secrets", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "test-sa", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForOutput(t, out, "RoleBinding default/read-secrets added → now ALLOWED")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v, want nil once cancelled", err)
+	}
+	if !o.Listing.NoCache {
+		t.Error("--watch left the cache on, which would hide changes")
+	}
+}
+
+func TestCollectEvents(t *testing.T) {
+	events := make(chan client.RBACEvent, 3)
+	events <- client.RBACEvent{Type: client.RBACEventAdded, Kind: "RoleBinding", Namespace: "prod", Name: "edit"}
+	events <- client.RBACEvent{Type: client.RBACEventUpdated, Kind: "ClusterRole", Name: "view"}
+	events <- client.RBACEvent{Type: client.RBACEventDeleted, Kind: "RoleBinding", Namespace: "prod", Name: "edit"}
+
+	// A burst is one batch, with the changes to each object merged
+	changes, ok := collectEvents(context.Background(), events, 10*time.Millisecond, time.Second)
+	if !ok {
+		t.Fatal("collectEvents() = false, want the burst")
+	}
+	if got, want := describeEvents(changes), "RoleBinding prod/edit deleted (+1 more)"; got != want {
+		t.Errorf("describeEvents() = %q, want %q", got, want)
+	}
+
+	close(events)
+	if _, ok := collectEvents(context.Background(), events, 10*time.Millisecond, time.Second); ok {
+		t.Error("collectEvents() = true after the watch ended")
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	// The API server never answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	// Discovery takes no context, so its request is still waiting
+	defer server.CloseClientConnections()
+	c, err := client.NewK8sRBACClient(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := newTestOptions(c, "system:serviceaccount:default:test-sa", "default")
+	if err := o.Complete([]string{"get", "secrets"}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	const timeout = 100 * time.Millisecond
+	ctx, progress, cancel := withTimeout(context.Background(), timeout)
+	defer cancel()
+	err = timeoutError(ctx, o.Run(ctx), timeout, progress)
+	want := "timed out after 100ms while discovering the resources the cluster serves; raise --timeout"
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Run() error = %v, want %q", err, want)
+	}
+	if exitcode.For(err) != exitcode.Error {
+		t.Errorf("exit code = %d, want %d", exitcode.For(err), exitcode.Error)
+	}
+}
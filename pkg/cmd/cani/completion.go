@@ -0,0 +1,49 @@
+package cani
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// completeServiceAccountNames completes --sa values from the ServiceAccounts in
+// the namespace being typed (NAMESPACE/...) or the current namespace
+func completeServiceAccountNames(o *RbacWhyOptions) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		namespace, prefix := "", ""
+		if idx := strings.Index(toComplete, "/"); idx != -1 {
+			namespace = toComplete[:idx]
+			prefix = namespace + "/"
+		} else {
+			namespace, _, err = o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+		}
+
+		sas, err := clientset.CoreV1().ServiceAccounts(namespace).List(cmd.Context(), metav1.ListOptions{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		var completions []string
+		for _, sa := range sas.Items {
+			candidate := prefix + sa.Name
+			if strings.HasPrefix(candidate, toComplete) {
+				completions = append(completions, candidate)
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
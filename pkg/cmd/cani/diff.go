@@ -0,0 +1,279 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	diffLong = `Compares effective permissions: one subject in two kubeconfig contexts,
+or two subjects in one.
+
+Answers "why does this work in staging but not in prod?" by resolving
+every grant for the subject against each cluster and printing the
+permissions present on only one side, with the binding and role that
+grant them. Wildcards are taken into account, so "*" on one side and an
+explicit verb on the other is not a difference.
+
+The subject is --as if given; otherwise each context's own user is used.
+The namespace is -n if given; otherwise each context's default namespace.
+
+With --as2, compares --as against a second subject in the current context
+(or the one --context names) instead, for example when migrating a
+workload to a new ServiceAccount. The permissions both share are listed
+too. Grants that reach a subject through a group are marked; use
+--as-group and --as2-group for group memberships RBAC objects don't record.`
+
+	diffExamples = `  # Why does this ServiceAccount work in staging but not prod?
+  kubectl rbac-why diff --as system:serviceaccount:api:api --context staging --context prod -n api
+
+  # Compare your own identity in two clusters, as JSON
+  kubectl rbac-why diff --context staging --context prod -o json
+
+  # What is the new ServiceAccount missing?
+  kubectl rbac-why diff --as system:serviceaccount:api:old --as2 system:serviceaccount:api:new -n api`
+)
+
+// DiffOptions contains the options for the diff command
+type DiffOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Contexts []string
+	Output   string
+
+	// As2 and As2Groups are the second subject, compared with --as in a
+	// single context
+	As2       string
+	As2Groups []string
+
+	// Clients, when set, supplies the RBAC client for each context instead
+	// of connecting to its cluster
+	Clients map[string]client.RBACClient
+
+	genericclioptions.IOStreams
+}
+
+// NewDiffOptions creates new DiffOptions with defaults
+func NewDiffOptions(streams genericclioptions.IOStreams) *DiffOptions {
+	flags := genericclioptions.NewConfigFlags(true)
+	// --context is repeatable here, so the single-context flag is not registered
+	flags.Context = nil
+	return &DiffOptions{
+		ConfigFlags: flags,
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdDiff creates the diff command
+func NewCmdDiff(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewDiffOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "diff (--context A --context B [--as SUBJECT] | --as SUBJECT_A --as2 SUBJECT_B) [flags]",
+		Short:   "Compare permissions across two contexts or two subjects",
+		Long:    diffLong,
+		Example: diffExamples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringArrayVar(&o.Contexts, "context", nil, "Kubeconfig context to compare (exactly two, or at most one with --as2)")
+	cmd.Flags().StringVar(&o.As2, "as2", "", "Second subject to compare with --as, in a single context")
+	cmd.Flags().StringArrayVar(&o.As2Groups, "as2-group", nil, "Group of the --as2 subject (repeatable)")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json")
+
+	return cmd
+}
+
+// Validate checks the diff options
+func (o *DiffOptions) Validate() error {
+	if len(o.As2Groups) > 0 && o.As2 == "" {
+		return fmt.Errorf("--as2-group requires --as2")
+	}
+	if o.As2 != "" {
+		if o.ConfigFlags.Impersonate == nil || *o.ConfigFlags.Impersonate == "" {
+			return fmt.Errorf("--as2 requires --as for the first subject")
+		}
+		if len(o.Contexts) > 1 {
+			return fmt.Errorf("--as2 compares two subjects in one context; give at most one --context")
+		}
+	} else if len(o.Contexts) != 2 {
+		return fmt.Errorf("exactly two --context flags are required, got %d", len(o.Contexts))
+	}
+	if o.As2 == "" && o.Contexts[0] == o.Contexts[1] {
+		return fmt.Errorf("--context %s was given twice; compare two different contexts", o.Contexts[0])
+	}
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", o.Output)
+	}
+	return nil
+}
+
+// Run resolves the subject's grants in each context and prints the difference
+func (o *DiffOptions) Run(ctx context.Context) error {
+	if o.As2 != "" {
+		return o.runSubjects(ctx)
+	}
+
+	var sides [2]output.DiffSide
+	var grants [2][]rbac.PermissionGrant
+	for i, name := range o.Contexts {
+		side, rbacClient, err := o.loadContext(ctx, name)
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		g, err := rbac.NewResolver(rbacClient).ResolveAllPermissions(ctx, side.Subject, side.Namespace)
+		if err != nil {
+			return fmt.Errorf("context %s: failed to resolve permissions: %w", name, err)
+		}
+		sides[i], grants[i] = side, g
+	}
+
+	onlyLeft, onlyRight := rbac.DiffGrants(grants[0], grants[1])
+	if o.Output == "json" {
+		return output.PrintDiffJSON(o.Out, sides[0], sides[1], onlyLeft, onlyRight, nil)
+	}
+	output.PrintDiff(o.Out, sides[0], sides[1], onlyLeft, onlyRight, nil)
+	return nil
+}
+
+// runSubjects compares --as with --as2 in a single context
+func (o *DiffOptions) runSubjects(ctx context.Context) error {
+	contextName := ""
+	if len(o.Contexts) == 1 {
+		contextName = o.Contexts[0]
+	}
+	left, rbacClient, err := o.loadContext(ctx, contextName)
+	if err != nil {
+		return err
+	}
+	subject, err := rbac.ParseSubject(o.As2)
+	if err != nil {
+		return fmt.Errorf("failed to parse --as2 subject: %w", err)
+	}
+	subject.Groups = o.As2Groups
+	right := left
+	right.Subject = subject
+	left.Label, right.Label = left.Subject.String(), right.Subject.String()
+
+	resolver := rbac.NewResolver(rbacClient)
+	var grants [2][]rbac.PermissionGrant
+	for i, side := range []output.DiffSide{left, right} {
+		if grants[i], err = resolver.ResolveAllPermissions(ctx, side.Subject, side.Namespace); err != nil {
+			return fmt.Errorf("failed to resolve permissions of %s: %w", side.Subject, err)
+		}
+		if len(grants[i]) == 0 {
+			flag := "--as-group"
+			if i == 1 {
+				flag = "--as2-group"
+			}
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: no binding grants %s anything; if its access comes from groups your identity provider assigns, pass them with %s\n", side.Subject, flag)
+		}
+	}
+
+	onlyLeft, onlyRight := rbac.DiffGrants(grants[0], grants[1])
+	shared := rbac.SharedGrants(grants[0], grants[1])
+	if shared == nil {
+		shared = []rbac.PermissionDelta{}
+	}
+	if o.Output == "json" {
+		return output.PrintDiffJSON(o.Out, left, right, onlyLeft, onlyRight, shared)
+	}
+	output.PrintDiff(o.Out, left, right, onlyLeft, onlyRight, shared)
+	return nil
+}
+
+// loadContext determines the subject and namespace for a context and builds
+// a client that reads RBAC objects as the context's own user. An empty name
+// is the current context.
+func (o *DiffOptions) loadContext(ctx context.Context, name string) (output.DiffSide, client.RBACClient, error) {
+	side := output.DiffSide{Label: name}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if o.ConfigFlags.KubeConfig != nil && *o.ConfigFlags.KubeConfig != "" {
+		rules.ExplicitPath = *o.ConfigFlags.KubeConfig
+	}
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: name})
+
+	// Injected clients stand in for clusters that don't need a kubeconfig
+	rbacClient := o.Clients[name]
+	var authInfoName string
+	var authInfo *api.AuthInfo
+	if rbacClient == nil {
+		rawConfig, err := loader.RawConfig()
+		if err != nil {
+			return side, nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		if name == "" {
+			name = rawConfig.CurrentContext
+			side.Label = name
+		}
+		kubeContext, ok := rawConfig.Contexts[name]
+		if !ok {
+			return side, nil, fmt.Errorf("context not found in kubeconfig")
+		}
+		side.Cluster = kubeContext.Cluster
+		authInfoName, authInfo = kubeContext.AuthInfo, rawConfig.AuthInfos[kubeContext.AuthInfo]
+
+		restConfig, err := loader.ClientConfig()
+		if err != nil {
+			return side, nil, fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return side, nil, fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	switch {
+	case o.ConfigFlags.Impersonate != nil && *o.ConfigFlags.Impersonate != "":
+		subject, err := rbac.ParseSubject(*o.ConfigFlags.Impersonate)
+		if err != nil {
+			return side, nil, fmt.Errorf("failed to parse subject: %w", err)
+		}
+		if o.ConfigFlags.ImpersonateGroup != nil {
+			subject.Groups = *o.ConfigFlags.ImpersonateGroup
+		}
+		side.Subject = subject
+	case authInfo != nil:
+		userName, groups, authMethod := extractUserIdentity(ctx, authInfo, authInfoName, "")
+		subject, err := rbac.ParseSubject(userName)
+		if err != nil {
+			return side, nil, fmt.Errorf("failed to parse subject: %w", err)
+		}
+		subject.Groups = groups
+		subject.Origin = "context user (" + authMethod + ")"
+		side.Subject = subject
+	default:
+		return side, nil, fmt.Errorf("could not determine the context's user; use --as to specify a subject")
+	}
+
+	if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+		side.Namespace = *o.ConfigFlags.Namespace
+	} else if o.Clients[name] == nil {
+		ns, _, err := loader.Namespace()
+		if err != nil {
+			return side, nil, fmt.Errorf("failed to determine namespace: %w", err)
+		}
+		side.Namespace = ns
+	}
+	return side, rbacClient, nil
+}
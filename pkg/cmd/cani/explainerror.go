@@ -0,0 +1,104 @@
+package cani
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	explainErrorLong = `Explains a Forbidden error returned by the API server.
+
+The subject, verb, resource, API group, namespace, and object name are
+parsed from the error message and checked as if they had been passed to
+'can-i'. The message can be given as arguments or on stdin.`
+
+	explainErrorExamples = `  # Explain an error copied from kubectl
+  kubectl rbac-why explain-error 'Error from server (Forbidden): pods is forbidden: User "system:serviceaccount:prod:api" cannot list resource "pods" in API group "" in the namespace "prod"'
+
+  # Pipe a failing command's error output straight in
+  kubectl get secrets -n prod 2>&1 | kubectl rbac-why explain-error`
+)
+
+// NewCmdExplainError creates the explain-error command
+func NewCmdExplainError(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewRbacWhyOptions(streams)
+	// The denial is already known; the explanation is the point
+	o.NoExitCode = true
+
+	cmd := &cobra.Command{
+		Use:     "explain-error [MESSAGE | -]",
+		Short:   "Explain a Forbidden error message",
+		Long:    explainErrorLong,
+		Example: explainErrorExamples,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			msg, err := readErrorMessage(args, o.In)
+			if err != nil {
+				return err
+			}
+			// Parse failures already describe the expected message shapes
+			cmd.SilenceUsage = true
+			if err := o.completeFromForbiddenMessage(msg); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "text", fmt.Sprintf("Output format: %s", strings.Join(output.Formats(), ", ")))
+	cmd.Flags().StringVar(&o.Template, "template", "", "Template, or template file, for -o go-template or -o go-template-file")
+
+	return cmd
+}
+
+// readErrorMessage joins the arguments, or reads stdin when there are none or
+// the only argument is "-"
+func readErrorMessage(args []string, in io.Reader) (string, error) {
+	if len(args) > 0 && !(len(args) == 1 && args[0] == "-") {
+		return strings.Join(args, " "), nil
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return "", fmt.Errorf("failed to read error message from stdin: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("no error message given; pass it as an argument or on stdin")
+	}
+	return string(data), nil
+}
+
+// completeFromForbiddenMessage fills in the subject and request from msg
+func (o *RbacWhyOptions) completeFromForbiddenMessage(msg string) error {
+	if err := o.completeTemplate(); err != nil {
+		return err
+	}
+	parsed, err := rbac.ParseForbiddenMessage(msg)
+	if err != nil {
+		return err
+	}
+	o.As = parsed.User
+	o.AsProvided = true
+	o.SubjectOrigin = "Forbidden error"
+	o.Verb = parsed.Request.Verb
+	if parsed.NonResourcePath != "" {
+		o.NonResourceURL = parsed.NonResourcePath
+		return nil
+	}
+	o.Resource = parsed.Request.Resource
+	o.Subresource = parsed.Request.Subresource
+	o.APIGroup = parsed.Request.APIGroup
+	o.ResourceName = parsed.Request.ResourceName
+	o.Namespace = parsed.Request.Namespace
+	o.exactNamespace = true
+	return nil
+}
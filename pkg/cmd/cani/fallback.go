@@ -0,0 +1,52 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// selfReviewFallback returns the client to ask the API server directly when
+// reading the RBAC objects failed with err. That only answers for the
+// caller's own identity, so it applies without --as and -f.
+func (o *RbacWhyOptions) selfReviewFallback(rbacClient client.RBACClient, err error) (client.SelfReviewClient, bool) {
+	if !apierrors.IsForbidden(err) || o.AsProvided || o.Review != nil {
+		return nil, false
+	}
+	reviewer, ok := rbacClient.(client.SelfReviewClient)
+	return reviewer, ok
+}
+
+// limitation explains a result reached without reading the RBAC objects
+func limitation(review string, cause error) string {
+	return fmt.Sprintf("you cannot read the RBAC objects (%v), so the bindings and roles behind this answer are unknown; it comes from a %s", cause, review)
+}
+
+// resolveWithSelfAccessReview answers request with a SelfSubjectAccessReview
+func resolveWithSelfAccessReview(ctx context.Context, reviewer client.SelfReviewClient, subject rbac.Subject, request rbac.PermissionRequest, cause error) (*rbac.PermissionResult, error) {
+	spec := rbac.NewSubjectAccessReview(subject, request).Spec
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes:    spec.ResourceAttributes,
+			NonResourceAttributes: spec.NonResourceAttributes,
+		},
+	}
+	resp, err := reviewer.CreateSelfSubjectAccessReview(ctx, review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SelfSubjectAccessReview after %v: %w", cause, err)
+	}
+	return &rbac.PermissionResult{
+		Request:      request,
+		Subject:      subject,
+		Allowed:      resp.Status.Allowed,
+		Grants:       []rbac.PermissionGrant{},
+		Mode:         rbac.ModeSelfAccessReview,
+		Limitation:   limitation("SelfSubjectAccessReview", cause),
+		ServerReason: resp.Status.Reason,
+	}, nil
+}
@@ -0,0 +1,29 @@
+package cani
+
+import (
+	"context"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// runImpersonation lists the identities the subject can impersonate. Like a
+// single check, it exits 0 when there is at least one.
+func (o *RbacWhyOptions) runImpersonation(ctx context.Context, resolver *rbac.Resolver, subject rbac.Subject) error {
+	result, err := resolver.ResolveImpersonation(ctx, subject)
+	if err != nil {
+		return err
+	}
+	switch o.Output {
+	case "json":
+		err = output.PrintImpersonationJSON(o.Out, result)
+	case "yaml":
+		err = output.PrintImpersonationYAML(o.Out, result)
+	default:
+		output.PrintImpersonation(o.Out, result)
+	}
+	if err != nil {
+		return err
+	}
+	return o.verdict(result.CanImpersonate())
+}
@@ -0,0 +1,197 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	listLong = `Lists everything a subject may do, with where each permission comes from.
+
+Like 'kubectl auth can-i --list', but every row names the binding and role
+that grant it. Rules are expanded into one row per verb, API group, and
+resource or non-resource URL; a row granted by several rules is listed once.
+Rows that use "*" are flagged as wildcards.
+
+Without --as, lists the current context's own permissions. Without a
+namespace, only cluster-wide grants from ClusterRoleBindings are listed.
+
+JSON and YAML output group the rules by the binding and role they come
+from instead, so each can be traced back to the object to edit. Wide output
+has one row per verb, resource, and grant, with the binding, role, and rule
+of each. CSV output has the same rows, for spreadsheets.`
+
+	listExamples = `  # What can I do in my current namespace, and why?
+  kubectl rbac-why list
+
+  # What can this ServiceAccount do in prod?
+  kubectl rbac-why list --as system:serviceaccount:prod:api -n prod
+
+  # Rules grouped by binding and role, for tooling
+  kubectl rbac-why list --as jane@example.com -n dev -o json
+
+  # One row per grant, for a spreadsheet
+  kubectl rbac-why list --as jane@example.com -n dev -o csv > jane-dev.csv`
+)
+
+// ListOptions contains the options for the list command
+type ListOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	Listing     ListingOptions
+
+	Output     string
+	NoHeaders  bool
+	AWSProfile string
+	Timeout    time.Duration
+
+	// RBACClient, when set, is used instead of connecting to the cluster
+	RBACClient client.RBACClient
+
+	subject   rbac.Subject
+	namespace string
+
+	genericclioptions.IOStreams
+}
+
+// NewListOptions creates new ListOptions with defaults
+func NewListOptions(streams genericclioptions.IOStreams) *ListOptions {
+	return &ListOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Listing:     NewListingOptions(),
+		Timeout:     DefaultTimeout,
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdList creates the list command
+func NewCmdList(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "list [--as SUBJECT] [-n NAMESPACE] [flags]",
+		Short:   "List a subject's effective permissions and the bindings that grant them",
+		Long:    listLong,
+		Example: listExamples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, progress, cancel := withTimeout(cmd.Context(), o.Timeout)
+			defer cancel()
+			if err := o.CompleteContext(ctx); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return timeoutError(ctx, o.Run(ctx), o.Timeout, progress)
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	o.Listing.AddFlags(cmd.Flags(), o.ConfigFlags)
+	addTimeoutFlag(cmd.Flags(), &o.Timeout)
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, wide, json, yaml, csv")
+	cmd.Flags().BoolVar(&o.NoHeaders, "no-headers", false, "With -o csv, leave out the header row so outputs can be concatenated")
+	cmd.Flags().StringVarP(&o.AWSProfile, "profile", "p", "", "AWS profile to use for authentication (for EKS clusters)")
+
+	return cmd
+}
+
+// Complete parses the subject from --as, or from the current context
+func (o *ListOptions) Complete() error {
+	return o.CompleteContext(context.Background())
+}
+
+// CompleteContext is Complete with a context bounding the commands it runs,
+// like RbacWhyOptions.CompleteContext
+func (o *ListOptions) CompleteContext(ctx context.Context) error {
+	if o.ConfigFlags.Namespace != nil {
+		o.namespace = *o.ConfigFlags.Namespace
+	}
+
+	var groups []string
+	if o.ConfigFlags.ImpersonateGroup != nil {
+		groups = *o.ConfigFlags.ImpersonateGroup
+	}
+	as := ""
+	if o.ConfigFlags.Impersonate != nil {
+		as = *o.ConfigFlags.Impersonate
+	}
+	if as == "" {
+		if len(groups) > 0 {
+			return fmt.Errorf("--as-group requires --as")
+		}
+		ro := &RbacWhyOptions{ConfigFlags: o.ConfigFlags, AWSProfile: o.AWSProfile, Namespace: o.namespace}
+		if err := ro.completeFromCurrentContext(ctx); err != nil {
+			return err
+		}
+		as, groups, o.namespace = ro.As, ro.CurrentContext.Groups, ro.Namespace
+	}
+
+	subject, err := rbac.ParseSubject(as)
+	if err != nil {
+		return fmt.Errorf("failed to parse subject: %w", err)
+	}
+	subject.Groups = groups
+	o.subject = subject
+	return nil
+}
+
+// Validate checks the list options
+func (o *ListOptions) Validate() error {
+	if o.Output != "text" && o.Output != "wide" && o.Output != "json" && o.Output != "yaml" && o.Output != "csv" {
+		return fmt.Errorf("invalid output format: %s (valid: text, wide, json, yaml, csv)", o.Output)
+	}
+	if o.NoHeaders && o.Output != "csv" {
+		return fmt.Errorf("--no-headers is only supported with -o csv")
+	}
+	if err := validateTimeout(o.Timeout); err != nil {
+		return err
+	}
+	return o.Listing.Validate()
+}
+
+// Run resolves and prints the subject's permissions
+func (o *ListOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		// Read RBAC objects as the caller, not as the subject
+		o.ConfigFlags.Impersonate = nil
+		o.ConfigFlags.ImpersonateGroup = nil
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		rbacClient, err = o.Listing.newClient(restConfig, o.ErrOut)
+		if err != nil {
+			return err
+		}
+		defer o.Listing.printAge(o.ErrOut)
+	}
+
+	grants, err := rbac.NewResolver(rbacClient).ResolveAllPermissions(ctx, o.subject, o.namespace)
+	if err != nil {
+		return fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+	switch o.Output {
+	case "json":
+		return output.PrintListJSON(o.Out, o.subject, o.namespace, grants)
+	case "yaml":
+		return output.PrintListYAML(o.Out, o.subject, o.namespace, grants)
+	case "wide":
+		return output.PrintListWide(o.Out, o.subject, o.namespace, rbac.ListPermissions(grants))
+	case "csv":
+		return output.PrintListCSV(o.Out, o.subject, o.namespace, rbac.ListPermissions(grants), o.NoHeaders)
+	}
+	output.PrintList(o.Out, o.subject, o.namespace, rbac.ListPermissions(grants))
+	return nil
+}
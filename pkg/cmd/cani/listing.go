@@ -0,0 +1,122 @@
+package cani
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+// DefaultCacheTTL is how long listed RBAC objects are reused by later runs
+const DefaultCacheTTL = 2 * time.Minute
+
+// ListingOptions configures how RBAC objects are listed from a cluster: a
+// page at a time, and through a disk cache shared by runs in a row
+type ListingOptions struct {
+	CacheTTL time.Duration
+	NoCache  bool
+	PageSize int64
+	// AcceptContentType overrides the media types responses are requested
+	// as, client.DefaultAcceptContentTypes
+	AcceptContentType string
+
+	// configFlags are kubectl's flags; the RBAC objects are cached in the
+	// rbac-why directory of its --cache-dir, per cluster and identity
+	configFlags *genericclioptions.ConfigFlags
+
+	// cache is the cache in use, once a cluster client has been created
+	cache *client.DiskCache
+}
+
+// NewListingOptions returns the listing defaults: pages of
+// client.DefaultPageSize objects, cached for DefaultCacheTTL
+func NewListingOptions() ListingOptions {
+	return ListingOptions{
+		CacheTTL: DefaultCacheTTL,
+		PageSize: client.DefaultPageSize,
+	}
+}
+
+// AddFlags registers --cache-ttl, --no-cache, and --page-size. The cache
+// goes under the --cache-dir of configFlags.
+func (l *ListingOptions) AddFlags(flags *pflag.FlagSet, configFlags *genericclioptions.ConfigFlags) {
+	l.configFlags = configFlags
+	flags.DurationVar(&l.CacheTTL, "cache-ttl", l.CacheTTL, "How long cached RBAC objects are reused (0 disables the cache)")
+	flags.BoolVar(&l.NoCache, "no-cache", false, "Read RBAC objects from the cluster instead of the cache")
+	flags.Int64Var(&l.PageSize, "page-size", l.PageSize, "How many objects to ask the API server for per list request (0 lists everything at once)")
+	flags.StringVar(&l.AcceptContentType, "accept-content-type", "", fmt.Sprintf("Media types to request responses as, in order of preference (default %q)", client.DefaultAcceptContentTypes))
+}
+
+// Validate checks the listing flags
+func (l *ListingOptions) Validate() error {
+	if l.CacheTTL < 0 {
+		return fmt.Errorf("--cache-ttl must not be negative, got %s", l.CacheTTL)
+	}
+	if l.PageSize < 0 {
+		return fmt.Errorf("--page-size must not be negative, got %d", l.PageSize)
+	}
+	if l.AcceptContentType != "" {
+		for _, mediaType := range strings.Split(l.AcceptContentType, ",") {
+			if _, _, err := mime.ParseMediaType(mediaType); err != nil {
+				return fmt.Errorf("invalid --accept-content-type %q: %w", mediaType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// newClient connects to the cluster at restConfig. RBAC objects are served
+// from the cache unless it is disabled, and warnings about listings go to
+// warnings.
+func (l *ListingOptions) newClient(restConfig *rest.Config, warnings io.Writer) (client.RBACClient, error) {
+	if l.AcceptContentType != "" {
+		restConfig = rest.CopyConfig(restConfig)
+		restConfig.AcceptContentTypes = l.AcceptContentType
+	}
+	k8sClient, err := client.NewK8sRBACClient(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RBAC client: %w", err)
+	}
+	k8sClient.PageSize = l.PageSize
+	k8sClient.Warnings = warnings
+	if l.NoCache || l.CacheTTL == 0 || l.configFlags == nil || l.configFlags.CacheDir == nil || *l.configFlags.CacheDir == "" {
+		return k8sClient, nil
+	}
+	cacheDir := filepath.Join(*l.configFlags.CacheDir, "rbac-why")
+	identity := client.CacheIdentity(restConfig, l.kubeContext())
+	cached := client.NewCachedK8sRBACClient(k8sClient, client.DiskCacheDir(cacheDir, restConfig.Host, identity), l.CacheTTL)
+	l.cache = cached.Cache
+	return cached, nil
+}
+
+// kubeContext returns the kubeconfig context in use, from --context or the
+// kubeconfig's current context
+func (l *ListingOptions) kubeContext() string {
+	if l.configFlags.Context != nil && *l.configFlags.Context != "" {
+		return *l.configFlags.Context
+	}
+	raw, err := l.configFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	return raw.CurrentContext
+}
+
+// printAge notes on w when cached RBAC objects were used, and how old they
+// are, since changes made since then aren't reflected
+func (l *ListingOptions) printAge(w io.Writer) {
+	if l.cache == nil {
+		return
+	}
+	if age, ok := l.cache.Age(); ok {
+		_, _ = fmt.Fprintf(w, "Note: used RBAC objects cached %s ago (use --no-cache to read them fresh)\n", age.Round(time.Second))
+	}
+}
@@ -0,0 +1,44 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/discovery"
+)
+
+// checkNamespace fails when the namespace to check in doesn't exist, which
+// would otherwise just show up as DENIED since it has no RoleBindings, and
+// suggests the existing namespaces closest to it. Without a cluster
+// connection, or without permission to get namespaces, it is used as given.
+func (o *RbacWhyOptions) checkNamespace(ctx context.Context, rbacClient client.RBACClient) error {
+	sc, ok := rbacClient.(client.SubjectClient)
+	if !ok || o.SkipNamespaceCheck || o.Namespace == "" {
+		return nil
+	}
+	_, err := sc.GetNamespace(ctx, o.Namespace)
+	switch {
+	case err == nil:
+		return nil
+	case apierrors.IsNotFound(err):
+	default:
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: cannot check that namespace %s exists (use --skip-namespace-check to skip the check): %v\n", o.Namespace, err)
+		return nil
+	}
+
+	msg := fmt.Sprintf("namespace %q not found", o.Namespace)
+	if list, err := sc.ListNamespaces(ctx); err == nil {
+		names := make([]string, 0, len(list.Items))
+		for _, ns := range list.Items {
+			names = append(names, ns.Name)
+		}
+		if similar := discovery.Similar(o.Namespace, names, 3); len(similar) > 0 {
+			msg += "; did you mean " + strings.Join(similar, ", ") + "?"
+		}
+	}
+	return fmt.Errorf("%s (use --skip-namespace-check to check it anyway)", msg)
+}
@@ -0,0 +1,130 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	namespacesLong = `Lists every namespace where a subject has any access.
+
+Scans ClusterRoleBindings and RoleBindings in all namespaces for bindings
+that match the subject, directly or through its groups. For each
+namespace it prints the strongest access found, approximated against the
+default admin, edit, and view ClusterRoles, and the binding that grants
+it. Access from a ClusterRoleBinding applies to all namespaces and is
+listed separately.`
+
+	namespacesExamples = `  # Where does this ServiceAccount have anything at all?
+  kubectl rbac-why namespaces --as system:serviceaccount:ci:deployer
+
+  # The same, as JSON
+  kubectl rbac-why namespaces --as jane@example.com -o json`
+)
+
+// NamespacesOptions contains the options for the namespaces command
+type NamespacesOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Output string
+
+	// RBACClient, when set, is used instead of connecting to the cluster
+	RBACClient client.RBACClient
+
+	subject rbac.Subject
+
+	genericclioptions.IOStreams
+}
+
+// NewNamespacesOptions creates new NamespacesOptions with defaults
+func NewNamespacesOptions(streams genericclioptions.IOStreams) *NamespacesOptions {
+	return &NamespacesOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdNamespaces creates the namespaces command
+func NewCmdNamespaces(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewNamespacesOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "namespaces --as SUBJECT [flags]",
+		Short:   "List the namespaces where a subject has any access",
+		Long:    namespacesLong,
+		Example: namespacesExamples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json")
+
+	return cmd
+}
+
+// Complete parses the subject
+func (o *NamespacesOptions) Complete() error {
+	if o.ConfigFlags.Impersonate == nil || *o.ConfigFlags.Impersonate == "" {
+		return fmt.Errorf("--as is required")
+	}
+	subject, err := rbac.ParseSubject(*o.ConfigFlags.Impersonate)
+	if err != nil {
+		return fmt.Errorf("failed to parse subject: %w", err)
+	}
+	o.subject = subject
+	return nil
+}
+
+// Validate checks the namespaces options
+func (o *NamespacesOptions) Validate() error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", o.Output)
+	}
+	return nil
+}
+
+// Run finds and prints the namespaces where the subject has access
+func (o *NamespacesOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		// Read RBAC objects as the caller, not as the subject
+		o.ConfigFlags.Impersonate = nil
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	cluster, namespaces, err := rbac.NewResolver(rbacClient).AccessByNamespace(ctx, o.subject)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return output.PrintNamespacesJSON(o.Out, o.subject, cluster, namespaces)
+	}
+	output.PrintNamespaces(o.Out, o.subject, cluster, namespaces)
+	return nil
+}
@@ -2,17 +2,30 @@ package cani
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/tools/clientcmd/api"
 
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/config"
+	"github.com/hardik/kubectl-rbac-why/pkg/discovery"
+	"github.com/hardik/kubectl-rbac-why/pkg/groups"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
 	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
 )
 
@@ -36,28 +49,245 @@ type RbacWhyOptions struct {
 	// Whether --as was explicitly provided (false means using current context)
 	AsProvided bool
 
+	// ServiceAccount is the --sa NAMESPACE/NAME shorthand for a ServiceAccount subject
+	ServiceAccount string
+
+	// Pod is the --pod [NAMESPACE/]NAME, and Workload the --workload
+	// KIND/[NAMESPACE/]NAME, whose ServiceAccount is the subject. Complete
+	// parses them into workload; Run reads the object.
+	Pod      string
+	Workload string
+
+	workload workloadRef
+
+	// exactNamespace is set when the request names its own namespace, as a
+	// review, request path, or Forbidden error does, so it isn't defaulted
+	exactNamespace bool
+
+	// ExtraGroups are the --extra-group values, added to the groups of any
+	// subject, unlike --as-group which needs --as
+	ExtraGroups []string
+
+	// IncludeImplicitGroups adds system:authenticated and the ServiceAccount
+	// groups; with --include-implicit-groups=false only the explicit groups
+	// are evaluated
+	IncludeImplicitGroups bool
+
+	// AsGroups are the --as-group values, added to the subject's groups
+	AsGroups []string
+
+	// AsUID is the --as-uid value. RBAC ignores it, but --verify sends it
+	// in the SubjectAccessReview.
+	AsUID string
+
+	// AsUserExtra holds the --as-user-extra KEY=VALUE values. Like AsUID,
+	// RBAC ignores them, but --verify and -o sar send them.
+	AsUserExtra map[string][]string
+
+	// SubjectOrigin describes how the subject was derived, for display
+	SubjectOrigin string
+
 	// Current context information (populated when --as is not provided)
 	CurrentContext *ContextInfo
 
 	// Permission request
-	Verb        string
-	Resource    string
-	Subresource string
-	APIGroup    string
+	Verb         string
+	Resource     string
+	Subresource  string
+	APIGroup     string
+	ResourceName string
+
+	// NonResourceURL is set instead of Resource when RESOURCE is a path
+	// such as /metrics
+	NonResourceURL string
+
+	// Verbs are the verbs of a comma-separated VERB list; Verb is the first
+	Verbs []string
+	// Any makes a check of several verbs succeed when any verb is allowed
+	Any bool
+
+	// SubresourceFlag is the --subresource value, which Complete merges
+	// into Subresource
+	SubresourceFlag string
+
+	// nameFromArg means ResourceName was read from RESOURCE/NAME and may
+	// really be a subresource the built-in list doesn't know, e.g. of a CRD
+	nameFromArg bool
+
+	// resourceArg is the RESOURCE argument as typed, and apiVersion the
+	// version it named, if any. RBAC doesn't match on versions, but
+	// discovery is asked whether the group serves it.
+	resourceArg string
+	apiVersion  string
+
+	// Verbose prints how the RESOURCE argument was parsed
+	Verbose bool
 
 	// Namespace
 	Namespace string
 
 	// Output options
-	Output    string // text, json, yaml, dot, mermaid
+	Output    string // Any registered printer format, or gha/junit/ndjson
 	ShowRisky bool
 
+	// NoHeaders leaves the header row out of -o csv
+	NoHeaders bool
+
+	// Template is the template for -o go-template, or its file for
+	// -o go-template-file; templateText is the template itself
+	Template     string
+	templateText string
+
+	// FailOn makes --show-risky fail when a finding is at or above this severity
+	FailOn string
+
+	// ConfigFile is the rbac-why configuration file (severity overrides)
+	ConfigFile string
+
+	// SeverityOverrides replace the built-in severity of risky categories
+	SeverityOverrides output.SeverityOverrides
+	// SensitiveNamespaces raise risks for automounted tokens; nil means the default
+	SensitiveNamespaces []string
+
+	// ServerRules compares client-side resolution with SelfSubjectRulesReview
+	ServerRules bool
+
+	// UsageFrom is an API server audit log file or directory; the subject's
+	// grants are compared with the requests it made there
+	UsageFrom string
+
+	// ChecksFiles are YAML or ndjson files of permission assertions evaluated
+	// in batch ("-" for stdin)
+	ChecksFiles []string
+
+	// KeepGoing skips malformed checks with a warning instead of aborting,
+	// and marks the results as partial
+	KeepGoing bool
+	// Strict fails a KeepGoing run with exitcode.Incomplete when it skipped
+	// anything
+	Strict bool
+
+	// AutoCorrect checks the RBAC attributes a kubectl command such as
+	// "exec" needs instead of rejecting it as a verb
+	AutoCorrect bool
+
+	// Trace records every binding examined for a single check and why it
+	// did or didn't grant the request
+	Trace bool
+
+	// SkipSystemBindings leaves system:* bindings out of the trace
+	SkipSystemBindings bool
+
+	// Debug, or a Verbosity above 0, logs each step of the evaluation to
+	// ErrOut
+	Debug     bool
+	Verbosity int
+
+	// NoDedupe shows a path for every matching rule instead of merging the
+	// rules of one binding and role into a single path
+	NoDedupe bool
+
+	// Verify cross-checks a single check with a SubjectAccessReview
+	Verify bool
+
+	// ShowCommands adds the kubectl commands that reproduce a single check
+	// and show each grant's binding and role
+	ShowCommands bool
+
+	// Suggest emits the Role and binding that would grant a denied check
+	Suggest bool
+
+	// SuggestLeastPrivilege narrows the wildcard rules that grant a check
+	SuggestLeastPrivilege bool
+
+	// Quiet prints only yes or no
+	Quiet bool
+
+	// NoExitCode exits 0 for a denied check instead of 1
+	NoExitCode bool
+
+	// SkipNamespaceCheck uses the namespace without checking that it exists
+	SkipNamespaceCheck bool
+
+	// NoNormalize checks the resource exactly as typed, without mapping
+	// short names, singulars, and Kinds through discovery
+	NoNormalize bool
+
+	// AllNamespaces evaluates a single check in every namespace
+	AllNamespaces bool
+
+	// Concurrency is how many namespaces are evaluated at once across
+	// namespaces
+	Concurrency int
+
+	// NamespaceSelector evaluates a single check, or the risky analysis, in
+	// each namespace whose labels match it
+	NamespaceSelector string
+
+	// FromFiles are RBAC manifest files or directories to resolve against
+	// instead of a cluster
+	FromFiles []string
+
+	// FromSnapshot is a file saved by the snapshot command to resolve
+	// against instead of a cluster
+	FromSnapshot string
+
+	// Filename is a SubjectAccessReview manifest to check ("-" for stdin)
+	Filename string
+
+	// Review is the loaded SubjectAccessReview, if Filename was given
+	Review *authorizationv1.SubjectAccessReview
+
+	// ApplyRole checks the object in Filename against the escalation rules
+	// instead of treating Filename as a SubjectAccessReview
+	ApplyRole bool
+
+	// EscalationCheck checks whether the subject can create roles with
+	// escalate or bindings with bind
+	EscalationCheck bool
+
+	// ShowImpersonation lists the identities the subject can assume with
+	// the impersonate verb
+	ShowImpersonation bool
+
+	// ProposedObject is the Role, ClusterRole, or binding loaded for ApplyRole
+	ProposedObject interface{}
+
+	// RequestPath is an HTTP request line ("GET /api/v1/...") to check instead
+	// of VERB RESOURCE
+	RequestPath string
+
+	// GroupsFile maps usernames to groups the cluster cannot tell us about,
+	// loaded into GroupMapping
+	GroupsFile   string
+	GroupMapping *groups.Mapping
+
 	// AWS options
 	AWSProfile string // AWS profile to use for authentication
 
 	// Kubernetes config
 	ConfigFlags *genericclioptions.ConfigFlags
 
+	// Listing configures how RBAC objects are listed from the cluster
+	Listing ListingOptions
+
+	// Timeout bounds the time spent waiting on the cluster, 0 for no limit
+	Timeout time.Duration
+
+	// Watch evaluates a single check again whenever RBAC objects change,
+	// after a burst of changes settles for watchQuiet or for at most
+	// watchMaxWait
+	Watch                    bool
+	watchQuiet, watchMaxWait time.Duration
+
+	// RBACClient, when set, is used instead of a client built from ConfigFlags.
+	// This lets the command run against in-memory or embedded RBAC data.
+	RBACClient client.RBACClient
+
+	// TracerProvider, when set, receives spans for each phase. Otherwise spans
+	// are exported over OTLP if OTEL_EXPORTER_OTLP_ENDPOINT is set.
+	TracerProvider trace.TracerProvider
+
 	// IO streams
 	genericclioptions.IOStreams
 }
@@ -68,30 +298,122 @@ func NewRbacWhyOptions(streams genericclioptions.IOStreams) *RbacWhyOptions {
 		ConfigFlags: genericclioptions.NewConfigFlags(true),
 		IOStreams:   streams,
 		Output:      "text",
+
+		IncludeImplicitGroups: true,
+		Concurrency:           rbac.DefaultConcurrency,
+		Listing:               NewListingOptions(),
+		Timeout:               DefaultTimeout,
+		watchQuiet:            defaultWatchQuiet,
+		watchMaxWait:          defaultWatchMaxWait,
 	}
 }
 
 // Complete fills in fields that were not specified
 func (o *RbacWhyOptions) Complete(args []string) error {
-	// For --show-risky, we don't need VERB RESOURCE
-	if !o.ShowRisky {
+	return o.CompleteContext(context.Background(), args)
+}
+
+// CompleteContext is Complete with a context bounding the commands it runs,
+// such as aws sts get-caller-identity for an EKS context's identity
+func (o *RbacWhyOptions) CompleteContext(ctx context.Context, args []string) error {
+	if err := o.completeTemplate(); err != nil {
+		return err
+	}
+
+	if o.ConfigFile != "" {
+		cfg, err := config.Load(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		o.SeverityOverrides = cfg.SeverityOverrides
+		o.SensitiveNamespaces = cfg.SensitiveNamespaces
+	}
+
+	if o.GroupsFile != "" {
+		mapping, err := groups.Load(o.GroupsFile)
+		if err != nil {
+			return err
+		}
+		o.GroupMapping = mapping
+	}
+
+	// A SubjectAccessReview specifies both the subject and the request exactly
+	if o.Filename != "" && !o.ApplyRole {
+		return o.completeFromFilename(args)
+	}
+
+	// Whole-subject modes don't need VERB RESOURCE
+	if o.EscalationCheck && len(args) > 0 {
+		return fmt.Errorf("--escalation-check takes no VERB RESOURCE arguments")
+	}
+	if o.ShowImpersonation && len(args) > 0 {
+		return fmt.Errorf("--show-impersonation takes no VERB RESOURCE arguments")
+	}
+	if o.ApplyRole {
+		if len(args) > 0 {
+			return fmt.Errorf("%s takes no arguments; pass the manifest with -f", ApplyRoleVerb)
+		}
+		if o.Filename == "" {
+			return fmt.Errorf("%s requires -f with a Role, ClusterRole, RoleBinding, or ClusterRoleBinding manifest", ApplyRoleVerb)
+		}
+		obj, err := loadRBACObject(o.Filename, o.In)
+		if err != nil {
+			return err
+		}
+		o.ProposedObject = obj
+	} else if o.RequestPath != "" {
+		if err := o.completeFromRequestPath(args); err != nil {
+			return err
+		}
+	} else if o.needsPermissionArgs() || (o.ShowRisky && len(args) >= 2) {
+		// --show-risky takes VERB RESOURCE only to audit a "*" resource
 		if len(args) < 2 {
 			return fmt.Errorf("requires at least 2 arguments: VERB RESOURCE")
 		}
 
-		o.Verb = args[0]
+		o.Verbs = strings.Split(args[0], ",")
+		if slices.Contains(o.Verbs, "") {
+			return fmt.Errorf("invalid verb list %q: empty verb", args[0])
+		}
+		o.Verb = o.Verbs[0]
 		resourceArg := args[1]
 
 		// Parse resource which may include API group (e.g., "pods.v1" or "deployments.apps")
 		if err := o.parseResource(resourceArg); err != nil {
 			return err
 		}
+		if err := o.applySubresourceFlag(); err != nil {
+			return err
+		}
 	}
 
 	// Get --as value from ConfigFlags
 	if o.ConfigFlags.Impersonate != nil && *o.ConfigFlags.Impersonate != "" {
 		o.As = *o.ConfigFlags.Impersonate
 		o.AsProvided = true
+		// Like --sa, the sa: forms are shorthand; the output shows what they
+		// expand to
+		if strings.HasPrefix(o.As, rbac.ServiceAccountPrefix) {
+			o.SubjectOrigin = "--as " + o.As
+		}
+	}
+	if o.ConfigFlags.ImpersonateGroup != nil {
+		o.AsGroups = *o.ConfigFlags.ImpersonateGroup
+	}
+	if o.ConfigFlags.ImpersonateUID != nil {
+		o.AsUID = *o.ConfigFlags.ImpersonateUID
+	}
+	if o.ConfigFlags.ImpersonateUserExtra != nil {
+		for _, extra := range *o.ConfigFlags.ImpersonateUserExtra {
+			key, value, ok := strings.Cut(extra, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid --as-user-extra %q (expected KEY=VALUE)", extra)
+			}
+			if o.AsUserExtra == nil {
+				o.AsUserExtra = make(map[string][]string)
+			}
+			o.AsUserExtra[key] = append(o.AsUserExtra[key], value)
+		}
 	}
 
 	// Get namespace from ConfigFlags
@@ -99,18 +421,138 @@ func (o *RbacWhyOptions) Complete(args []string) error {
 		o.Namespace = *o.ConfigFlags.Namespace
 	}
 
+	// --sa is shorthand for --as system:serviceaccount:NAMESPACE:NAME
+	if o.ServiceAccount != "" {
+		if err := o.completeServiceAccount(); err != nil {
+			return err
+		}
+	}
+
+	// --pod and --workload name the subject too, but it's only known once
+	// the object is read
+	if o.Pod != "" {
+		if err := o.completePod(); err != nil {
+			return err
+		}
+	}
+	if o.Workload != "" {
+		if err := o.completeWorkload(); err != nil {
+			return err
+		}
+	}
+
+	// Like kubectl, groups can only be impersonated along with a user
+	if len(o.AsGroups) > 0 && !o.AsProvided {
+		return fmt.Errorf("--as-group requires --as or --sa")
+	}
+	if o.AsUID != "" && !o.AsProvided {
+		return fmt.Errorf("--as-uid requires --as or --sa")
+	}
+	if len(o.AsUserExtra) > 0 && !o.AsProvided {
+		return fmt.Errorf("--as-user-extra requires --as or --sa")
+	}
+
 	// If --as is not provided, get subject from current context
 	if !o.AsProvided {
-		if err := o.completeFromCurrentContext(); err != nil {
+		if err := o.completeFromCurrentContext(ctx); err != nil {
 			return err
 		}
 	}
 
+	// Cached objects would hide the changes being watched
+	if o.Watch {
+		o.Listing.NoCache = true
+	}
+
+	return nil
+}
+
+// completeFromFilename loads the subject and request from a SubjectAccessReview
+func (o *RbacWhyOptions) completeFromFilename(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("-f cannot be combined with VERB RESOURCE arguments")
+	}
+	if (o.ConfigFlags.Impersonate != nil && *o.ConfigFlags.Impersonate != "") || o.ServiceAccount != "" || o.Pod != "" || o.Workload != "" ||
+		(o.ConfigFlags.ImpersonateGroup != nil && len(*o.ConfigFlags.ImpersonateGroup) > 0) ||
+		(o.ConfigFlags.ImpersonateUID != nil && *o.ConfigFlags.ImpersonateUID != "") ||
+		(o.ConfigFlags.ImpersonateUserExtra != nil && len(*o.ConfigFlags.ImpersonateUserExtra) > 0) {
+		return fmt.Errorf("-f cannot be combined with --as, --as-group, --as-uid, --as-user-extra, --sa, --pod, or --workload; the review specifies the subject")
+	}
+
+	review, err := loadSubjectAccessReview(o.Filename, o.In)
+	if err != nil {
+		return err
+	}
+	o.completeFromReview(review)
+	return nil
+}
+
+// completeFromRequestPath fills in the permission, including its namespace,
+// from an API request line
+func (o *RbacWhyOptions) completeFromRequestPath(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("--request-path cannot be combined with VERB RESOURCE arguments")
+	}
+	request, err := rbac.ParseRequestPath(o.RequestPath)
+	if err != nil {
+		return err
+	}
+	if ns := o.ConfigFlags.Namespace; ns != nil && *ns != "" && *ns != request.Namespace {
+		return fmt.Errorf("-n %s conflicts with the request path, which is %s", *ns, describeNamespace(request.Namespace))
+	}
+
+	o.Verb = request.Verb
+	o.Resource = request.Resource
+	o.Subresource = request.Subresource
+	o.APIGroup = request.APIGroup
+	o.ResourceName = request.ResourceName
+	o.NonResourceURL = request.NonResourceURL
+	o.Namespace = request.Namespace
+	o.exactNamespace = true
+	return nil
+}
+
+func describeNamespace(namespace string) string {
+	if namespace == "" {
+		return "cluster-wide"
+	}
+	return "in namespace " + namespace
+}
+
+// completeServiceAccount expands --sa into the canonical ServiceAccount subject
+func (o *RbacWhyOptions) completeServiceAccount() error {
+	if o.AsProvided {
+		return fmt.Errorf("--sa and --as are mutually exclusive")
+	}
+
+	namespace, name := "", o.ServiceAccount
+	if idx := strings.Index(o.ServiceAccount, "/"); idx != -1 {
+		namespace, name = o.ServiceAccount[:idx], o.ServiceAccount[idx+1:]
+		if namespace == "" {
+			return fmt.Errorf("invalid --sa value %q: namespace is empty (expected NAMESPACE/NAME or NAME)", o.ServiceAccount)
+		}
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid --sa value %q (expected NAMESPACE/NAME or NAME)", o.ServiceAccount)
+	}
+
+	// Default to -n, then the context namespace, then "default" (like kubectl)
+	if namespace == "" {
+		ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return fmt.Errorf("failed to determine namespace for --sa: %w", err)
+		}
+		namespace = ns
+	}
+
+	o.As = "system:serviceaccount:" + namespace + ":" + name
+	o.AsProvided = true
+	o.SubjectOrigin = "--sa " + o.ServiceAccount
 	return nil
 }
 
 // completeFromCurrentContext populates options from the current kubeconfig context
-func (o *RbacWhyOptions) completeFromCurrentContext() error {
+func (o *RbacWhyOptions) completeFromCurrentContext(ctx context.Context) error {
 	rawConfig, err := o.ConfigFlags.ToRawKubeConfigLoader().RawConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load kubeconfig: %w", err)
@@ -139,7 +581,7 @@ func (o *RbacWhyOptions) completeFromCurrentContext() error {
 	}
 
 	// Try to determine the actual user identity
-	userName, groups, authMethod := extractUserIdentity(authInfo, authInfoName, o.AWSProfile)
+	userName, groups, authMethod := extractUserIdentity(ctx, authInfo, authInfoName, o.AWSProfile)
 
 	// Store context info for display
 	o.CurrentContext = &ContextInfo{
@@ -160,8 +602,9 @@ func (o *RbacWhyOptions) completeFromCurrentContext() error {
 	// Use the extracted user name as the subject
 	o.As = userName
 
-	// If namespace not specified via flag, use context's default namespace
-	if o.Namespace == "" && currentContext.Namespace != "" {
+	// If namespace not specified via flag, use context's default namespace.
+	// A request path always determines the namespace itself.
+	if o.Namespace == "" && currentContext.Namespace != "" && o.RequestPath == "" {
 		o.Namespace = currentContext.Namespace
 	}
 
@@ -170,7 +613,7 @@ func (o *RbacWhyOptions) completeFromCurrentContext() error {
 
 // extractUserIdentity tries to determine the actual user identity from authInfo
 // Returns: userName, groups, authMethod
-func extractUserIdentity(authInfo *api.AuthInfo, fallbackName string, awsProfile string) (string, []string, string) {
+func extractUserIdentity(ctx context.Context, authInfo *api.AuthInfo, fallbackName string, awsProfile string) (string, []string, string) {
 	// Try client certificate first (most common for local clusters like Docker Desktop, kind, minikube)
 	if len(authInfo.ClientCertificateData) > 0 {
 		if userName, groups, err := parseClientCertificate(authInfo.ClientCertificateData); err == nil {
@@ -197,7 +640,7 @@ func extractUserIdentity(authInfo *api.AuthInfo, fallbackName string, awsProfile
 	if authInfo.Exec != nil {
 		// Try to extract identity for AWS IAM authenticator
 		if isAWSAuth(authInfo.Exec) {
-			if userName, groups, err := extractAWSIdentity(authInfo.Exec, awsProfile); err == nil {
+			if userName, groups, err := extractAWSIdentity(ctx, authInfo.Exec, awsProfile); err == nil {
 				return userName, groups, "aws-iam"
 			}
 			// Fall through to fallback if AWS identity extraction fails
@@ -265,7 +708,7 @@ type stsGetCallerIdentityResponse struct {
 // extractAWSIdentity extracts the AWS IAM identity using aws sts get-caller-identity
 // It also checks if a role is being assumed via the exec config
 // awsProfile is the profile from --profile flag, if empty will try to extract from exec args
-func extractAWSIdentity(execConfig *api.ExecConfig, awsProfile string) (string, []string, error) {
+func extractAWSIdentity(ctx context.Context, execConfig *api.ExecConfig, awsProfile string) (string, []string, error) {
 	// Check if a role is specified in the exec arguments
 	roleArn := extractRoleFromArgs(execConfig.Args)
 
@@ -287,7 +730,8 @@ func extractAWSIdentity(execConfig *api.ExecConfig, awsProfile string) (string,
 		cmdArgs = append(cmdArgs, "--profile", profile)
 	}
 
-	cmd := exec.Command("aws", cmdArgs...)
+	client.SetPhase(ctx, "running aws sts get-caller-identity")
+	cmd := exec.CommandContext(ctx, "aws", cmdArgs...)
 	cmd.Env = append(os.Environ(), envVars...)
 
 	var stdout, stderr bytes.Buffer
@@ -373,65 +817,476 @@ func convertRoleToAssumedRoleArn(roleArn, accountId string) string {
 	return fmt.Sprintf("arn:aws:sts::%s:assumed-role/%s", accountId, roleName)
 }
 
-// parseResource parses a resource string like "pods", "pods/log", "deployments.apps"
+// parsedResource holds the components of a RESOURCE argument
+type parsedResource struct {
+	Resource     string
+	Subresource  string
+	ResourceName string
+	APIGroup     string
+	// Version is the API version the argument named, if any
+	Version string
+
+	// NonResourceURL is set instead of the others for a path like /metrics
+	NonResourceURL string
+}
+
+// parseResource parses a resource string like "pods", "pods/log",
+// "secrets/db-password", or "deployments.apps"
 func (o *RbacWhyOptions) parseResource(resource string) error {
-	// Handle subresource (e.g., "pods/exec")
-	if idx := strings.Index(resource, "/"); idx != -1 {
-		o.Resource = resource[:idx]
-		o.Subresource = resource[idx+1:]
-		resource = o.Resource
-	} else {
-		o.Resource = resource
+	parsed, err := parseResourceArg(resource)
+	if err != nil {
+		return err
+	}
+
+	o.Resource = parsed.Resource
+	o.Subresource = parsed.Subresource
+	o.ResourceName = parsed.ResourceName
+	o.APIGroup = parsed.APIGroup
+	o.NonResourceURL = parsed.NonResourceURL
+	o.resourceArg = resource
+	o.apiVersion = parsed.Version
+	o.nameFromArg = parsed.ResourceName != "" && parsed.Subresource == ""
+	return nil
+}
+
+// applySubresourceFlag sets the subresource from --subresource, as kubectl
+// auth can-i takes it. A near miss of a built-in subresource is rejected as a
+// typo; other names are accepted, since CRDs can serve their own, and the
+// RESOURCE/SUBRESOURCE form is checked against discovery instead.
+func (o *RbacWhyOptions) applySubresourceFlag() error {
+	flag := o.SubresourceFlag
+	if flag == "" {
+		return nil
+	}
+	if o.NonResourceURL != "" {
+		return fmt.Errorf("--subresource cannot be used with a non-resource URL")
+	}
+	if o.Subresource != "" && o.Subresource != flag {
+		return fmt.Errorf("--subresource %s conflicts with the %s subresource in %s/%s", flag, o.Subresource, o.Resource, o.Subresource)
 	}
+	if flag != "*" && !discovery.IsBuiltinSubresource(flag) {
+		if suggestion := discovery.Closest(flag, discovery.BuiltinSubresources()); suggestion != "" {
+			return fmt.Errorf("unknown subresource %q; did you mean %s? A CRD's own subresource can be given as RESOURCE/SUBRESOURCE instead", flag, suggestion)
+		}
+	}
+	o.Subresource = flag
+	o.nameFromArg = false
+	return nil
+}
 
-	// Handle API group (e.g., "deployments.apps" or "deployments.apps/v1")
-	if idx := strings.Index(resource, "."); idx != -1 {
-		o.Resource = resource[:idx]
-		o.APIGroup = resource[idx+1:]
-		// Remove version if present (e.g., "apps/v1" -> "apps")
-		if vIdx := strings.Index(o.APIGroup, "/"); vIdx != -1 {
-			o.APIGroup = o.APIGroup[:vIdx]
+// parseResourceArg splits a resource string into resource, subresource, object
+// name, and API group. RESOURCE/X is a subresource when X is a built-in
+// subresource and an object name otherwise; RESOURCE/NAME/SUBRESOURCE names both.
+func parseResourceArg(resource string) (parsedResource, error) {
+	var parsed parsedResource
+
+	// A path such as /metrics or /api/* is a non-resource URL
+	if strings.HasPrefix(resource, "/") {
+		parsed.NonResourceURL = resource
+		return parsed, nil
+	}
+
+	parts := strings.Split(resource, "/")
+	// A version after the group (e.g., "deployments.apps/v1")
+	if len(parts) > 1 && strings.Contains(parts[0], ".") && versionRe.MatchString(parts[1]) {
+		parsed.Version = parts[1]
+		parts = append(parts[:1], parts[2:]...)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return parsed, fmt.Errorf("invalid resource %q: empty segment", resource)
 		}
 	}
+	switch len(parts) {
+	case 1:
+	case 2:
+		if parts[1] == "*" || discovery.IsBuiltinSubresource(parts[1]) {
+			parsed.Subresource = parts[1]
+		} else {
+			parsed.ResourceName = parts[1]
+		}
+	case 3:
+		parsed.ResourceName, parsed.Subresource = parts[1], parts[2]
+	default:
+		return parsed, fmt.Errorf("invalid resource %q: expected RESOURCE, RESOURCE/SUBRESOURCE, RESOURCE/NAME, or RESOURCE/NAME/SUBRESOURCE", resource)
+	}
 
-	return nil
+	// Handle version and API group (e.g., "deployments.v1.apps")
+	var version string
+	parsed.Resource, version, parsed.APIGroup = splitResourceGroup(parts[0])
+	if version != "" {
+		if parsed.Version != "" && parsed.Version != version {
+			return parsed, fmt.Errorf("invalid resource %q: names versions %s and %s", resource, version, parsed.Version)
+		}
+		parsed.Version = version
+	}
+
+	return parsed, nil
 }
 
+// splitResourceGroup splits RESOURCE[.VERSION][.GROUP] like kubectl. The
+// segment after the resource is a version when it looks like one, so pods.v1
+// is in the core group and cronjobs.v1.batch in batch. resolveVersion checks
+// this against discovery, for a group whose first segment looks like a version.
+func splitResourceGroup(s string) (resource, version, group string) {
+	resource, rest, found := strings.Cut(s, ".")
+	if !found {
+		return s, "", ""
+	}
+	first, group, _ := strings.Cut(rest, ".")
+	if versionRe.MatchString(first) {
+		return resource, first, group
+	}
+	return resource, "", rest
+}
+
+// versionRe matches an API version such as v1 or v1beta2
+var versionRe = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
 // Validate checks that the options are valid
 func (o *RbacWhyOptions) Validate() error {
-	// At this point, o.As should be set either from --as flag or from current context
-	if o.As == "" {
+	// At this point, o.As should be set either from --as flag or from current
+	// context, unless --pod or --workload leaves it to Run
+	if o.As == "" && o.workload.kind == "" {
 		return fmt.Errorf("could not determine subject: either use --as flag or ensure kubeconfig has a valid current context")
 	}
+	if o.workload.kind != "" && (o.FromSnapshot != "" || len(o.FromFiles) > 0) {
+		return fmt.Errorf("%s reads the %s from the cluster and cannot be used with --from-file or --from-snapshot", o.workload.flag(), o.workload.kind)
+	}
 
-	// For --show-risky, we don't need verb/resource
-	if !o.ShowRisky {
+	if o.FromSnapshot != "" && len(o.FromFiles) > 0 {
+		return fmt.Errorf("--from-snapshot and --from-file cannot be used together")
+	}
+	if o.Review != nil && (len(o.ExtraGroups) > 0 || !o.IncludeImplicitGroups) {
+		return fmt.Errorf("-f uses the review's groups exactly and cannot be combined with --extra-group or --include-implicit-groups=false")
+	}
+	if o.ServerRules {
+		if len(o.FromFiles) > 0 || o.FromSnapshot != "" {
+			return fmt.Errorf("--server-rules asks the API server and cannot be used with --from-file or --from-snapshot")
+		}
+		if o.AsProvided {
+			return fmt.Errorf("--server-rules compares the caller's own rules and cannot be used with --as")
+		}
+		if o.ShowRisky {
+			return fmt.Errorf("--server-rules and --show-risky cannot be used together")
+		}
+	}
+
+	// Whole-subject modes don't need verb/resource
+	if o.needsPermissionArgs() {
 		if o.Verb == "" {
 			return fmt.Errorf("verb is required")
 		}
 
-		if o.Resource == "" {
+		if o.Resource == "" && o.NonResourceURL == "" {
 			return fmt.Errorf("resource is required")
 		}
+		if err := o.correctKubectlVerb(); err != nil {
+			return err
+		}
+		o.completeEscalationVerb()
 	}
 
-	validOutputs := map[string]bool{
-		"text": true, "json": true, "yaml": true, "dot": true, "mermaid": true,
+	if o.EscalationCheck {
+		if o.ShowRisky || o.ServerRules || len(o.ChecksFiles) > 0 || o.ApplyRole || o.Filename != "" || o.RequestPath != "" || o.UsageFrom != "" {
+			return fmt.Errorf("--escalation-check cannot be combined with --show-risky, --server-rules, --checks-file, -f, --request-path, or --usage-from")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with --escalation-check (valid: text, json, yaml)", o.Output)
+		}
+	}
+
+	if o.ShowImpersonation {
+		if o.EscalationCheck || o.ShowRisky || o.ServerRules || len(o.ChecksFiles) > 0 || o.ApplyRole || o.Filename != "" || o.RequestPath != "" || o.UsageFrom != "" {
+			return fmt.Errorf("--show-impersonation cannot be combined with --escalation-check, --show-risky, --server-rules, --checks-file, -f, --request-path, or --usage-from")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with --show-impersonation (valid: text, json, yaml)", o.Output)
+		}
+	}
+
+	if o.ApplyRole {
+		if o.ShowRisky || o.ServerRules || len(o.ChecksFiles) > 0 || o.RequestPath != "" {
+			return fmt.Errorf("%s cannot be combined with --show-risky, --server-rules, --checks-file, or --request-path", ApplyRoleVerb)
+		}
+		if o.Output != "text" && o.Output != "json" {
+			return fmt.Errorf("output format %s is not supported with %s (valid: text, json)", o.Output, ApplyRoleVerb)
+		}
+	}
+
+	if o.Filename != "" && (o.ShowRisky || o.ServerRules || len(o.ChecksFiles) > 0) {
+		return fmt.Errorf("-f cannot be combined with --show-risky, --server-rules, or --checks-file")
+	}
+
+	if o.RequestPath != "" && (o.Filename != "" || o.ShowRisky || o.ServerRules || len(o.ChecksFiles) > 0) {
+		return fmt.Errorf("--request-path cannot be combined with -f, --show-risky, --server-rules, or --checks-file")
+	}
+
+	if len(o.Verbs) > 1 {
+		if o.wildcardAudit() || o.acrossNamespaces() || o.Trace || o.Suggest || o.SuggestLeastPrivilege || o.Verify || o.Quiet {
+			return fmt.Errorf("several verbs cannot be combined with a \"*\" resource, -A, --trace, --suggest, --suggest-least-privilege, --verify, or --quiet")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with several verbs (valid: text, json, yaml)", o.Output)
+		}
+		for _, verb := range o.Verbs {
+			if _, ok := rbac.LookupKubectlVerb(verb); ok {
+				return fmt.Errorf("%q is a kubectl command, not an RBAC verb; check it on its own to see the check it needs", verb)
+			}
+		}
+	}
+	if o.Any && len(o.Verbs) < 2 {
+		return fmt.Errorf("--any is only supported with several comma-separated verbs")
+	}
+
+	if o.SubresourceFlag != "" && (!o.needsPermissionArgs() || o.Filename != "" || o.RequestPath != "") {
+		return fmt.Errorf("--subresource is only supported with VERB RESOURCE arguments")
+	}
+
+	if o.UsageFrom != "" {
+		if o.ShowRisky || o.ServerRules || len(o.ChecksFiles) > 0 || o.Filename != "" || o.RequestPath != "" {
+			return fmt.Errorf("--usage-from cannot be combined with --show-risky, --server-rules, --checks-file, -f, or --request-path")
+		}
+		if o.Output != "text" && o.Output != "json" {
+			return fmt.Errorf("output format %s is not supported with --usage-from (valid: text, json)", o.Output)
+		}
+	}
+
+	if len(o.ChecksFiles) > 0 && (o.ShowRisky || o.ServerRules) {
+		return fmt.Errorf("--checks-file cannot be combined with --show-risky or --server-rules")
+	}
+
+	// Printer formats come from the output registry; gha and junit are
+	// mode-specific renderers validated below
+	if !output.IsRegistered(o.Output) && o.Output != "gha" && o.Output != "junit" && o.Output != "ndjson" {
+		valid := append(output.Formats(), "gha", "junit", "ndjson")
+		return fmt.Errorf("invalid output format: %s (valid: %s)", o.Output, strings.Join(valid, ", "))
+	}
+	if o.Output == "gha" && !o.ShowRisky {
+		return fmt.Errorf("output format gha is only supported with --show-risky")
+	}
+	if o.Output == "junit" && len(o.ChecksFiles) == 0 && (!o.needsPermissionArgs() || o.wildcardAudit() || o.acrossNamespaces()) {
+		return fmt.Errorf("output format junit is only supported with --checks-file or a single VERB RESOURCE check")
+	}
+	if o.NoHeaders && o.Output != "csv" {
+		return fmt.Errorf("--no-headers is only supported with -o csv")
 	}
-	if !validOutputs[o.Output] {
-		return fmt.Errorf("invalid output format: %s (valid: text, json, yaml, dot, mermaid)", o.Output)
+	if o.Output == "ndjson" && len(o.ChecksFiles) == 0 {
+		return fmt.Errorf("output format ndjson is only supported with --checks-file")
+	}
+	if len(o.ChecksFiles) > 0 && o.Output != "text" && o.Output != "junit" && o.Output != "ndjson" {
+		return fmt.Errorf("output format %s is not supported with --checks-file (valid: text, junit, ndjson)", o.Output)
+	}
+	if o.wildcardAudit() && o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("output format %s is not supported for a wildcard resource audit (valid: text, json)", o.Output)
+	}
+	if o.FailOn != "" {
+		if !o.ShowRisky {
+			return fmt.Errorf("--fail-on is only supported with --show-risky")
+		}
+		if rbac.SeverityRank(o.FailOn) == 0 {
+			return fmt.Errorf("invalid --fail-on severity %q (valid: %s)", o.FailOn, strings.Join(rbac.Severities, ", "))
+		}
+	}
+	if o.Trace && (!o.needsPermissionArgs() || o.wildcardAudit()) {
+		return fmt.Errorf("--trace is only supported for a single VERB RESOURCE check")
+	}
+	if o.Trace && o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+		return fmt.Errorf("output format %s is not supported with --trace (valid: text, json, yaml)", o.Output)
+	}
+	if o.SkipSystemBindings && !o.Trace {
+		return fmt.Errorf("--skip-system-bindings is only supported with --trace")
+	}
+	if o.SuggestLeastPrivilege {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.ApplyRole || o.UsageFrom != "" || len(o.ChecksFiles) > 0 || o.acrossNamespaces() {
+			return fmt.Errorf("--suggest-least-privilege is only supported for a single VERB RESOURCE check")
+		}
+	}
+	if o.Suggest {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.ApplyRole || o.UsageFrom != "" || len(o.ChecksFiles) > 0 || o.acrossNamespaces() {
+			return fmt.Errorf("--suggest is only supported for a single VERB RESOURCE check")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with --suggest (valid: text, json, yaml)", o.Output)
+		}
+	}
+	if o.Verify {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.ApplyRole || o.UsageFrom != "" || len(o.ChecksFiles) > 0 || o.acrossNamespaces() {
+			return fmt.Errorf("--verify is only supported for a single VERB RESOURCE check")
+		}
+		if len(o.FromFiles) > 0 || o.FromSnapshot != "" {
+			return fmt.Errorf("--verify asks the API server and cannot be used with --from-file or --from-snapshot")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with --verify (valid: text, json, yaml)", o.Output)
+		}
+	}
+	if o.ShowCommands {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.acrossNamespaces() || len(o.Verbs) > 1 || o.Watch || o.Quiet {
+			return fmt.Errorf("--show-commands is only supported for a single VERB RESOURCE check")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with --show-commands (valid: text, json, yaml)", o.Output)
+		}
+	}
+	if o.Quiet {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.ApplyRole || o.UsageFrom != "" || len(o.ChecksFiles) > 0 {
+			return fmt.Errorf("--quiet is only supported for a single VERB RESOURCE check")
+		}
+		if o.Output != "text" {
+			return fmt.Errorf("--quiet prints only yes or no and cannot be used with -o %s", o.Output)
+		}
+		if o.Trace || o.Suggest || o.SuggestLeastPrivilege {
+			return fmt.Errorf("--quiet cannot be used with --trace, --suggest, or --suggest-least-privilege")
+		}
+	}
+	if o.AllNamespaces {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.ApplyRole || o.Filename != "" || o.RequestPath != "" || o.UsageFrom != "" || len(o.ChecksFiles) > 0 {
+			return fmt.Errorf("--all-namespaces is only supported for a single VERB RESOURCE check")
+		}
+		if o.Trace {
+			return fmt.Errorf("--all-namespaces and --trace cannot be used together")
+		}
+		if o.NonResourceURL != "" {
+			return fmt.Errorf("--all-namespaces does not apply to non-resource URLs, which are only granted cluster-wide")
+		}
+		if !slices.Contains(namespacesFormats, o.Output) {
+			return fmt.Errorf("output format %s is not supported with --all-namespaces (valid: %s)", o.Output, strings.Join(namespacesFormats, ", "))
+		}
+	}
+	if o.Output == "sar" {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.acrossNamespaces() {
+			return fmt.Errorf("output format sar is only supported for a single VERB RESOURCE check")
+		}
+	}
+	if o.Watch {
+		if !o.needsPermissionArgs() || o.wildcardAudit() || o.acrossNamespaces() || o.Filename != "" || len(o.Verbs) > 1 {
+			return fmt.Errorf("--watch is only supported for a single VERB RESOURCE check")
+		}
+		if len(o.FromFiles) > 0 || o.FromSnapshot != "" {
+			return fmt.Errorf("--watch follows changes on the cluster and cannot be used with --from-file or --from-snapshot")
+		}
+		if o.Quiet || o.Trace || o.Suggest || o.SuggestLeastPrivilege || o.Verify {
+			return fmt.Errorf("--watch cannot be combined with --quiet, --trace, --suggest, --suggest-least-privilege, or --verify")
+		}
+		if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+			return fmt.Errorf("output format %s is not supported with --watch (valid: text, json, yaml)", o.Output)
+		}
+	}
+	if o.Concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	if err := o.Listing.Validate(); err != nil {
+		return err
+	}
+	if err := validateTimeout(o.Timeout); err != nil {
+		return err
+	}
+	if o.Verbosity < 0 {
+		return fmt.Errorf("--v must not be negative, got %d", o.Verbosity)
+	}
+	if o.NamespaceSelector != "" {
+		if _, err := labels.Parse(o.NamespaceSelector); err != nil {
+			return fmt.Errorf("invalid --namespace-selector %q: %w", o.NamespaceSelector, err)
+		}
+		if o.AllNamespaces {
+			return fmt.Errorf("--namespace-selector and --all-namespaces cannot be used together")
+		}
+		if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+			return fmt.Errorf("--namespace-selector chooses the namespaces and cannot be combined with -n")
+		}
+		if o.ShowRisky {
+			if o.wildcardAudit() || (o.Output != "text" && o.Output != "gha") {
+				return fmt.Errorf("--show-risky with --namespace-selector only supports -o text or gha, without a \"*\" resource")
+			}
+		} else {
+			if !o.needsPermissionArgs() || o.wildcardAudit() || o.ApplyRole || o.Filename != "" || o.RequestPath != "" || o.UsageFrom != "" || len(o.ChecksFiles) > 0 {
+				return fmt.Errorf("--namespace-selector is only supported for a single VERB RESOURCE check or --show-risky")
+			}
+			if o.Trace {
+				return fmt.Errorf("--namespace-selector and --trace cannot be used together")
+			}
+			if o.NonResourceURL != "" {
+				return fmt.Errorf("--namespace-selector does not apply to non-resource URLs, which are only granted cluster-wide")
+			}
+			if !slices.Contains(namespacesFormats, o.Output) {
+				return fmt.Errorf("output format %s is not supported with --namespace-selector (valid: %s)", o.Output, strings.Join(namespacesFormats, ", "))
+			}
+		}
+	}
+	if o.KeepGoing && len(o.ChecksFiles) == 0 {
+		return fmt.Errorf("--keep-going is only supported with --checks-file")
+	}
+	if o.Strict && !o.KeepGoing {
+		return fmt.Errorf("--strict requires --keep-going")
+	}
+	stdinUses := 0
+	for _, path := range o.ChecksFiles {
+		if path == "-" {
+			stdinUses++
+		}
+	}
+	if o.Filename == "-" {
+		stdinUses++
+	}
+	if stdinUses > 1 {
+		return fmt.Errorf("stdin (-) can only be read once")
 	}
 
 	return nil
 }
 
+// correctKubectlVerb catches kubectl commands such as "exec" or "logs" given
+// as the verb. With --auto-correct the request is rewritten to the check the
+// command needs; otherwise that check is suggested in the error.
+func (o *RbacWhyOptions) correctKubectlVerb() error {
+	kv, ok := rbac.LookupKubectlVerb(o.Verb)
+	// Validate rejects kubectl commands in a list of several verbs
+	if !ok || o.NonResourceURL != "" || len(o.Verbs) > 1 {
+		return nil
+	}
+	request := o.ToPermissionRequest()
+	corrected := kv.Correct(request)
+	if !o.AutoCorrect {
+		return fmt.Errorf("%q is a kubectl command, not an RBAC verb: %s — run `kubectl rbac-why can-i %s %s` or pass --auto-correct",
+			o.Verb, kv.Suggestion(request), corrected.Verb, rbac.ResourceArg(corrected))
+	}
+	_, _ = fmt.Fprintf(o.ErrOut, "Note: %q is not an RBAC verb; %s\n", o.Verb, kv.Suggestion(request))
+	o.Verb, o.Resource, o.Subresource, o.APIGroup = corrected.Verb, corrected.Resource, corrected.Subresource, corrected.APIGroup
+	return nil
+}
+
+// completeEscalationVerb places bare roles and clusterroles in the RBAC API
+// group for escalate and bind, which only apply there, so the check works
+// without discovery too
+func (o *RbacWhyOptions) completeEscalationVerb() {
+	if !rbac.IsEscalationVerb(o.Verb) || o.APIGroup != "" || o.NonResourceURL != "" {
+		return
+	}
+	if o.Resource == "roles" || o.Resource == "clusterroles" {
+		o.APIGroup = rbacv1.GroupName
+		return
+	}
+	_, _ = fmt.Fprintf(o.ErrOut, "Warning: the API server only checks %s on roles and clusterroles, so a grant on %s has no effect\n", o.Verb, o.Resource)
+}
+
+// needsPermissionArgs reports whether the mode checks a single VERB RESOURCE
+func (o *RbacWhyOptions) needsPermissionArgs() bool {
+	return !o.ShowRisky && !o.ServerRules && len(o.ChecksFiles) == 0 && !o.ApplyRole && o.UsageFrom == "" && !o.EscalationCheck && !o.ShowImpersonation
+}
+
 // ToPermissionRequest converts options to a PermissionRequest
 func (o *RbacWhyOptions) ToPermissionRequest() rbac.PermissionRequest {
+	// Non-resource URLs are not namespaced
+	if o.NonResourceURL != "" {
+		return rbac.PermissionRequest{Verb: o.Verb, NonResourceURL: o.NonResourceURL}
+	}
 	return rbac.PermissionRequest{
-		Verb:        o.Verb,
-		APIGroup:    o.APIGroup,
-		Resource:    o.Resource,
-		Subresource: o.Subresource,
-		Namespace:   o.Namespace,
+		Verb:         o.Verb,
+		APIGroup:     o.APIGroup,
+		Resource:     o.Resource,
+		Subresource:  o.Subresource,
+		ResourceName: o.ResourceName,
+		Namespace:    o.Namespace,
 	}
 }
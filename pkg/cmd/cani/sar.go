@@ -0,0 +1,162 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// loadSubjectAccessReview reads a SubjectAccessReview or LocalSubjectAccessReview
+// manifest from path, or from in when path is "-". A LocalSubjectAccessReview is
+// returned as a SubjectAccessReview with its kind preserved.
+func loadSubjectAccessReview(path string, in io.Reader) (*authorizationv1.SubjectAccessReview, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(in)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SubjectAccessReview: %w", err)
+	}
+
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid SubjectAccessReview manifest: %w", err)
+	}
+	if meta.APIVersion != authorizationv1.SchemeGroupVersion.String() {
+		return nil, fmt.Errorf("unsupported apiVersion %q (expected %s)", meta.APIVersion, authorizationv1.SchemeGroupVersion)
+	}
+
+	review := &authorizationv1.SubjectAccessReview{}
+	switch meta.Kind {
+	case "SubjectAccessReview":
+		if err := yaml.UnmarshalStrict(data, review); err != nil {
+			return nil, fmt.Errorf("invalid SubjectAccessReview manifest: %w", err)
+		}
+	case "LocalSubjectAccessReview":
+		local := &authorizationv1.LocalSubjectAccessReview{}
+		if err := yaml.UnmarshalStrict(data, local); err != nil {
+			return nil, fmt.Errorf("invalid LocalSubjectAccessReview manifest: %w", err)
+		}
+		// The API server requires the two namespaces to agree
+		if ra := local.Spec.ResourceAttributes; ra != nil && ra.Namespace != "" && ra.Namespace != local.Namespace {
+			return nil, fmt.Errorf("LocalSubjectAccessReview: spec.resourceAttributes.namespace %q does not match metadata.namespace %q", ra.Namespace, local.Namespace)
+		}
+		review.TypeMeta = local.TypeMeta
+		review.ObjectMeta = local.ObjectMeta
+		review.Spec = local.Spec
+		if review.Spec.ResourceAttributes != nil {
+			review.Spec.ResourceAttributes.Namespace = local.Namespace
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q (expected SubjectAccessReview or LocalSubjectAccessReview)", meta.Kind)
+	}
+
+	if review.Spec.User == "" {
+		return nil, fmt.Errorf("spec.user is required")
+	}
+	if nra := review.Spec.NonResourceAttributes; nra != nil {
+		if nra.Verb == "" || nra.Path == "" {
+			return nil, fmt.Errorf("spec.nonResourceAttributes requires verb and path")
+		}
+		return review, nil
+	}
+	ra := review.Spec.ResourceAttributes
+	if ra == nil || ra.Verb == "" || ra.Resource == "" {
+		return nil, fmt.Errorf("spec.resourceAttributes with verb and resource is required")
+	}
+	return review, nil
+}
+
+// subjectFromReview builds the subject exactly as the review specifies it.
+// Unlike --as, a "system:" user is not treated as a group.
+func subjectFromReview(review *authorizationv1.SubjectAccessReview) rbac.Subject {
+	subject := rbac.Subject{Kind: "User", Name: review.Spec.User}
+	if strings.HasPrefix(review.Spec.User, "system:serviceaccount:") {
+		if sa, err := rbac.ParseSubject(review.Spec.User); err == nil {
+			subject = sa
+		}
+	}
+	subject.Groups = review.Spec.Groups
+	subject.ExactGroups = true
+	subject.UID = review.Spec.UID
+	for key, values := range review.Spec.Extra {
+		if subject.Extra == nil {
+			subject.Extra = make(map[string][]string, len(review.Spec.Extra))
+		}
+		subject.Extra[key] = values
+	}
+	return subject
+}
+
+// completeFromReview fills in the permission request from a loaded review
+func (o *RbacWhyOptions) completeFromReview(review *authorizationv1.SubjectAccessReview) {
+	ra := review.Spec.ResourceAttributes
+	o.Review = review
+	o.As = review.Spec.User
+	o.AsProvided = true
+	o.SubjectOrigin = review.Kind + " " + o.Filename
+	if nra := review.Spec.NonResourceAttributes; nra != nil {
+		o.Verb, o.NonResourceURL = nra.Verb, nra.Path
+		return
+	}
+	o.Verb = ra.Verb
+	o.APIGroup = ra.Group
+	o.Resource = ra.Resource
+	o.Subresource = ra.Subresource
+	o.ResourceName = ra.Name
+	o.Namespace = ra.Namespace
+	o.exactNamespace = true
+}
+
+// reviewStatus describes the result the way the API server's RBAC authorizer would
+func reviewStatus(result *rbac.PermissionResult) authorizationv1.SubjectAccessReviewStatus {
+	if result.BypassedVia != "" {
+		return authorizationv1.SubjectAccessReviewStatus{Allowed: true, Reason: "member of " + result.BypassedVia}
+	}
+	if !result.Allowed {
+		return authorizationv1.SubjectAccessReviewStatus{}
+	}
+	grant := result.Grants[0]
+	binding := grant.Binding.Name
+	if grant.Binding.Namespace != "" {
+		binding = grant.Binding.Namespace + "/" + binding
+	}
+	return authorizationv1.SubjectAccessReviewStatus{
+		Allowed: true,
+		Reason:  fmt.Sprintf("RBAC: allowed by %s %q of %s %q", grant.Binding.Kind, binding, grant.Role.Kind, grant.Role.Name),
+	}
+}
+
+// verify submits a SubjectAccessReview for the same request and records the
+// API server's decision on result
+func (o *RbacWhyOptions) verify(ctx context.Context, rbacClient client.RBACClient, subject rbac.Subject, request rbac.PermissionRequest, result *rbac.PermissionResult) error {
+	reviewer, ok := rbacClient.(client.AccessReviewClient)
+	if !ok {
+		return fmt.Errorf("--verify requires a live cluster connection")
+	}
+	review := rbac.NewSubjectAccessReview(subject, request)
+	if o.Review != nil {
+		// Keep the uid and extra fields of a loaded review
+		review = o.Review.DeepCopy()
+		review.TypeMeta, review.ObjectMeta = metav1.TypeMeta{}, metav1.ObjectMeta{}
+		review.Status = authorizationv1.SubjectAccessReviewStatus{}
+	}
+	resp, err := reviewer.CreateSubjectAccessReview(ctx, review)
+	if err != nil {
+		return fmt.Errorf("failed to create SubjectAccessReview: %w", err)
+	}
+	result.Verification = rbac.VerificationFromStatus(resp.Status)
+	return nil
+}
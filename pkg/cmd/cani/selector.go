@@ -0,0 +1,126 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// namespacesFormats are the output formats of a check evaluated in several
+// namespaces
+var namespacesFormats = []string{"text", "json", "yaml", "dot", "mermaid"}
+
+// acrossNamespaces reports whether a single check is evaluated in several
+// namespaces, with -A or --namespace-selector
+func (o *RbacWhyOptions) acrossNamespaces() bool {
+	return o.AllNamespaces || o.NamespaceSelector != ""
+}
+
+// listNamespaces lists the namespaces to evaluate a check in: those matching
+// --namespace-selector, or every namespace, sorted by name
+func (o *RbacWhyOptions) listNamespaces(ctx context.Context, rbacClient client.RBACClient) ([]string, error) {
+	flag := "--all-namespaces"
+	selector := labels.Everything()
+	if o.NamespaceSelector != "" {
+		flag = "--namespace-selector"
+		var err error
+		if selector, err = labels.Parse(o.NamespaceSelector); err != nil {
+			return nil, fmt.Errorf("invalid --namespace-selector %q: %w", o.NamespaceSelector, err)
+		}
+	}
+	sc, ok := rbacClient.(client.SubjectClient)
+	if !ok {
+		return nil, fmt.Errorf("%s needs a client that can list namespaces", flag)
+	}
+	list, err := sc.ListNamespaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	var namespaces []string
+	for _, ns := range list.Items {
+		if selector.Matches(labels.Set(ns.Labels)) {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	if len(namespaces) == 0 && o.NamespaceSelector != "" {
+		return nil, fmt.Errorf("no namespaces match --namespace-selector %s", o.NamespaceSelector)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// runSelectedRiskyAnalysis shows the subject's risky permissions in each
+// namespace matching --namespace-selector. Those granted cluster-wide are
+// shown once, and each namespace lists the ones its RoleBindings add.
+func (o *RbacWhyOptions) runSelectedRiskyAnalysis(ctx context.Context, rbacClient client.RBACClient, resolver *rbac.Resolver, subject rbac.Subject) error {
+	namespaces, err := o.listNamespaces(ctx, rbacClient)
+	if err != nil {
+		return err
+	}
+
+	clusterWide, err := resolver.ResolveAllPermissions(ctx, subject, "")
+	if err != nil {
+		return fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+
+	// Like -A, namespaces are evaluated concurrently into their own slots,
+	// and one that can't be read is reported without failing the others
+	perNamespace := make([]output.NamespaceRisks, len(namespaces))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(o.Concurrency)
+	for i, ns := range namespaces {
+		g.Go(func() error {
+			perNamespace[i].Namespace = ns
+			grants, err := resolver.ResolveAllPermissions(gctx, subject, ns)
+			switch {
+			case err != nil && gctx.Err() != nil:
+				return gctx.Err()
+			case err != nil:
+				perNamespace[i].Err = err
+				return nil
+			}
+			var own []rbac.PermissionGrant
+			for _, grant := range grants {
+				if grant.Scope == rbac.ScopeNamespace {
+					own = append(own, grant)
+				}
+			}
+			perNamespace[i].Risks = output.AnalyzeRiskyPermissionsWithOverrides(own, o.SeverityOverrides)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	clusterRisks := output.AnalyzeRiskyPermissionsWithOverrides(clusterWide, o.SeverityOverrides)
+	if group := rbac.BypassingGroup(subject, rbac.GetImplicitGroups(subject)); group != "" {
+		clusterRisks = append([]rbac.RiskyPermission{output.SuperuserRisk(group)}, clusterRisks...)
+	}
+	exposure := o.tokenExposure(ctx, rbacClient, subject)
+	clusterRisks = output.RaiseForExposure(clusterRisks, exposure)
+	all := clusterRisks
+	for i := range perNamespace {
+		perNamespace[i].Risks = output.RaiseForExposure(perNamespace[i].Risks, exposure)
+		all = append(all, perNamespace[i].Risks...)
+	}
+
+	if o.Output == "gha" {
+		for _, ns := range perNamespace {
+			if ns.Err != nil {
+				_, _ = fmt.Fprintf(o.ErrOut, "Warning: namespace %s: %v\n", ns.Namespace, ns.Err)
+			}
+		}
+		output.PrintRiskyGHA(o.Out, subject, all)
+	} else {
+		output.PrintNamespaceRisks(o.Out, o.NamespaceSelector, clusterRisks, perNamespace)
+	}
+	return o.failOn(all)
+}
@@ -0,0 +1,55 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+// ServerRules holds the caller's rules as enumerated by the API server
+type ServerRules struct {
+	Rules           []rbacv1.PolicyRule
+	Incomplete      bool
+	EvaluationError string
+}
+
+// FetchServerRules issues a SelfSubjectRulesReview for the caller in the given
+// namespace and converts the response into RBAC policy rules
+func FetchServerRules(ctx context.Context, reviewer client.SelfReviewClient, namespace string) (*ServerRules, error) {
+	review := &authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{
+			Namespace: namespace,
+		},
+	}
+
+	resp, err := reviewer.CreateSelfSubjectRulesReview(ctx, review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SelfSubjectRulesReview: %w", err)
+	}
+
+	rules := make([]rbacv1.PolicyRule, 0, len(resp.Status.ResourceRules)+len(resp.Status.NonResourceRules))
+	for _, r := range resp.Status.ResourceRules {
+		rules = append(rules, rbacv1.PolicyRule{
+			Verbs:         r.Verbs,
+			APIGroups:     r.APIGroups,
+			Resources:     r.Resources,
+			ResourceNames: r.ResourceNames,
+		})
+	}
+	for _, r := range resp.Status.NonResourceRules {
+		rules = append(rules, rbacv1.PolicyRule{
+			Verbs:           r.Verbs,
+			NonResourceURLs: r.NonResourceURLs,
+		})
+	}
+
+	return &ServerRules{
+		Rules:           rules,
+		Incomplete:      resp.Status.Incomplete,
+		EvaluationError: resp.Status.EvaluationError,
+	}, nil
+}
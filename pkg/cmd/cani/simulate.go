@@ -0,0 +1,321 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/manifest"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	simulateLong = `Previews what a subject could do after applying or removing RBAC objects.
+
+Answers "if I apply this RoleBinding, what will the ServiceAccount be able
+to do that it can't today?" without touching the cluster. The Roles,
+ClusterRoles, and bindings in the -f files are layered over the live RBAC
+objects: an object replaces the one of the same name, or is added.
+
+A document with a "# delete" comment line is removed instead, so deletions
+can be previewed alongside additions. With --delete, every object in the
+files is removed, as with 'kubectl delete -f'. --remove removes a live
+object by reference instead, such as rolebinding/prod/edit or
+clusterrole/legacy-admin, without a manifest.
+
+With VERB RESOURCE, the check is evaluated before and after the changes.
+Otherwise the subject's full grant set is compared, and the permissions it
+gains and loses are printed with the binding and role behind each.
+Permissions whose grant was removed but that another binding still
+covers are listed too, with that binding.`
+
+	simulateExamples = `  # What will the ServiceAccount gain from this RoleBinding?
+  kubectl rbac-why simulate -f rolebinding.yaml --as system:serviceaccount:prod:api -n prod
+
+  # Will it be able to read secrets afterwards?
+  kubectl rbac-why simulate -f rolebinding.yaml --as system:serviceaccount:prod:api -n prod get secrets
+
+  # What does jane lose if these bindings are deleted?
+  kubectl rbac-why simulate --delete -f old-bindings.yaml --as jane -n dev
+
+  # Is it safe to clean up this binding?
+  kubectl rbac-why simulate --remove rolebinding/prod/edit-binding --as system:serviceaccount:prod:api -n prod`
+)
+
+// SimulateOptions contains the options for the simulate command
+type SimulateOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Filenames []string
+	Delete    bool
+	// Remove are live objects to leave out, as KIND/NAMESPACE/NAME or KIND/NAME
+	Remove []string
+	Output string
+
+	// RBACClient, when set, is used instead of connecting to the cluster
+	RBACClient client.RBACClient
+
+	subject   rbac.Subject
+	namespace string
+	request   *rbac.PermissionRequest
+
+	genericclioptions.IOStreams
+}
+
+// NewSimulateOptions creates new SimulateOptions with defaults
+func NewSimulateOptions(streams genericclioptions.IOStreams) *SimulateOptions {
+	return &SimulateOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdSimulate creates the simulate command
+func NewCmdSimulate(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewSimulateOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "simulate (-f FILE | --remove KIND/[NAMESPACE/]NAME) --as SUBJECT [VERB RESOURCE] [flags]",
+		Short:   "Preview a subject's permissions after applying or removing RBAC objects",
+		Long:    simulateLong,
+		Example: simulateExamples,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("expected VERB RESOURCE or no arguments, got %d argument(s)", len(args))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringArrayVarP(&o.Filenames, "filename", "f", nil, "Manifest file or directory with the changes (repeatable)")
+	cmd.Flags().BoolVar(&o.Delete, "delete", false, "Preview deleting the objects in the files instead of applying them")
+	cmd.Flags().StringArrayVar(&o.Remove, "remove", nil, "Live object to leave out, e.g. rolebinding/prod/edit or clusterrole/admin (repeatable)")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json")
+
+	return cmd
+}
+
+// Complete parses the subject, the namespace, and VERB RESOURCE if given
+func (o *SimulateOptions) Complete(args []string) error {
+	if o.ConfigFlags.Impersonate == nil || *o.ConfigFlags.Impersonate == "" {
+		return fmt.Errorf("--as is required: simulate previews another subject's permissions")
+	}
+	subject, err := rbac.ParseSubject(*o.ConfigFlags.Impersonate)
+	if err != nil {
+		return fmt.Errorf("failed to parse subject: %w", err)
+	}
+	if o.ConfigFlags.ImpersonateGroup != nil {
+		subject.Groups = *o.ConfigFlags.ImpersonateGroup
+	}
+	o.subject = subject
+
+	if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+		o.namespace = *o.ConfigFlags.Namespace
+	} else if o.RBACClient == nil {
+		ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return fmt.Errorf("failed to determine namespace: %w", err)
+		}
+		o.namespace = ns
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+	parsed, err := parseResourceArg(args[1])
+	if err != nil {
+		return err
+	}
+	o.request = &rbac.PermissionRequest{
+		Verb:           args[0],
+		APIGroup:       parsed.APIGroup,
+		Resource:       parsed.Resource,
+		Subresource:    parsed.Subresource,
+		ResourceName:   parsed.ResourceName,
+		NonResourceURL: parsed.NonResourceURL,
+	}
+	if parsed.NonResourceURL == "" {
+		o.request.Namespace = o.namespace
+	}
+	return nil
+}
+
+// Validate checks the simulate options
+func (o *SimulateOptions) Validate() error {
+	if len(o.Filenames) == 0 && len(o.Remove) == 0 {
+		return fmt.Errorf("-f or --remove is required: pass the changes to simulate")
+	}
+	if o.Delete && len(o.Filenames) == 0 {
+		return fmt.Errorf("--delete requires -f")
+	}
+	for _, ref := range o.Remove {
+		if err := addObjectRef(&client.Snapshot{}, ref); err != nil {
+			return err
+		}
+	}
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", o.Output)
+	}
+	return nil
+}
+
+// Run resolves the subject's permissions with and without the changes and
+// prints the difference
+func (o *SimulateOptions) Run(ctx context.Context) error {
+	namespace := o.namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	changes := &manifest.Changes{Apply: &client.Snapshot{}, Delete: &client.Snapshot{}}
+	if len(o.Filenames) > 0 {
+		var warnings []string
+		var err error
+		if changes, warnings, err = manifest.LoadChanges(o.Filenames, namespace, o.Delete); err != nil {
+			return err
+		}
+		for _, w := range warnings {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", w)
+		}
+	}
+	for _, ref := range o.Remove {
+		if err := addObjectRef(changes.Delete, ref); err != nil {
+			return err
+		}
+	}
+
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		// Read RBAC objects as the caller, not as the subject
+		o.ConfigFlags.Impersonate = nil
+		o.ConfigFlags.ImpersonateGroup = nil
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+	o.warnMissing(ctx, rbacClient, changes.Delete)
+	before := rbac.NewResolver(rbacClient)
+	after := rbac.NewResolver(client.NewOverlay(rbacClient, changes.Apply, changes.Delete))
+
+	sim := output.Simulation{Subject: o.subject, Namespace: o.namespace, Request: o.request}
+	if o.request != nil {
+		var err error
+		if sim.Before, err = before.ResolvePermission(ctx, o.subject, *o.request); err != nil {
+			return fmt.Errorf("failed to resolve permission: %w", err)
+		}
+		if sim.After, err = after.ResolvePermission(ctx, o.subject, *o.request); err != nil {
+			return fmt.Errorf("failed to resolve permission with the changes: %w", err)
+		}
+	} else {
+		current, err := before.ResolveAllPermissions(ctx, o.subject, o.namespace)
+		if err != nil {
+			return fmt.Errorf("failed to resolve permissions: %w", err)
+		}
+		changed, err := after.ResolveAllPermissions(ctx, o.subject, o.namespace)
+		if err != nil {
+			return fmt.Errorf("failed to resolve permissions with the changes: %w", err)
+		}
+		sim.Gained, sim.Lost = rbac.DiffGrants(changed, current)
+		sim.StillGranted = rbac.ReroutedGrants(current, changed)
+	}
+
+	if o.Output == "json" {
+		return output.PrintSimulationJSON(o.Out, sim)
+	}
+	output.PrintSimulation(o.Out, sim)
+	return nil
+}
+
+// addObjectRef adds the object ref names, such as rolebinding/prod/edit or
+// clusterrole/admin, to s
+func addObjectRef(s *client.Snapshot, ref string) error {
+	parts := strings.Split(ref, "/")
+	kind := strings.TrimSuffix(strings.ToLower(parts[0]), "s")
+	for _, p := range parts[1:] {
+		if p == "" {
+			return fmt.Errorf("invalid object reference %q: empty segment", ref)
+		}
+	}
+	namespaced := kind == "role" || kind == "rolebinding"
+	switch {
+	case namespaced && len(parts) != 3:
+		return fmt.Errorf("invalid object reference %q: expected %s/NAMESPACE/NAME", ref, kind)
+	case (kind == "clusterrole" || kind == "clusterrolebinding") && len(parts) != 2:
+		return fmt.Errorf("invalid object reference %q: expected %s/NAME", ref, kind)
+	}
+
+	switch kind {
+	case "role":
+		s.Roles = append(s.Roles, rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: parts[1], Name: parts[2]}})
+	case "rolebinding":
+		s.RoleBindings = append(s.RoleBindings, rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: parts[1], Name: parts[2]}})
+	case "clusterrole":
+		s.ClusterRoles = append(s.ClusterRoles, rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: parts[1]}})
+	case "clusterrolebinding":
+		s.ClusterRoleBindings = append(s.ClusterRoleBindings, rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: parts[1]}})
+	default:
+		return fmt.Errorf("invalid object reference %q: kind must be role, clusterrole, rolebinding, or clusterrolebinding", ref)
+	}
+	return nil
+}
+
+// warnMissing warns about removals of objects the cluster doesn't have,
+// which are most likely typos
+func (o *SimulateOptions) warnMissing(ctx context.Context, rbacClient client.RBACClient, removals *client.Snapshot) {
+	for _, r := range removals.Roles {
+		if _, err := rbacClient.GetRole(ctx, r.Namespace, r.Name); err != nil {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: Role %s/%s not found; nothing to remove\n", r.Namespace, r.Name)
+		}
+	}
+	for _, r := range removals.ClusterRoles {
+		if _, err := rbacClient.GetClusterRole(ctx, r.Name); err != nil {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: ClusterRole %s not found; nothing to remove\n", r.Name)
+		}
+	}
+	for _, rb := range removals.RoleBindings {
+		list, err := rbacClient.ListRoleBindings(ctx, rb.Namespace)
+		if err != nil {
+			continue
+		}
+		if !slices.ContainsFunc(list.Items, func(b rbacv1.RoleBinding) bool { return b.Name == rb.Name }) {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: RoleBinding %s/%s not found; nothing to remove\n", rb.Namespace, rb.Name)
+		}
+	}
+	if len(removals.ClusterRoleBindings) == 0 {
+		return
+	}
+	list, err := rbacClient.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return
+	}
+	for _, crb := range removals.ClusterRoleBindings {
+		if !slices.ContainsFunc(list.Items, func(b rbacv1.ClusterRoleBinding) bool { return b.Name == crb.Name }) {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: ClusterRoleBinding %s not found; nothing to remove\n", crb.Name)
+		}
+	}
+}
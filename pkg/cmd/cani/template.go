@@ -0,0 +1,42 @@
+package cani
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+// completeTemplate splits -o go-template=TEMPLATE and
+// -o go-template-file=FILE, as kubectl accepts them, into the format and
+// --template, then reads and parses the template
+func (o *RbacWhyOptions) completeTemplate() error {
+	if format, arg, ok := strings.Cut(o.Output, "="); ok && (format == "go-template" || format == "go-template-file") {
+		if o.Template != "" {
+			return fmt.Errorf("give the template either as -o %s=... or with --template, not both", format)
+		}
+		o.Output, o.Template = format, arg
+	}
+	switch o.Output {
+	case "go-template", "go-template-file":
+	default:
+		if o.Template != "" {
+			return fmt.Errorf("--template is only supported with -o go-template or -o go-template-file")
+		}
+		return nil
+	}
+	if o.Template == "" {
+		return fmt.Errorf("-o %s requires a template, e.g. -o go-template='{{.allowed}}'", o.Output)
+	}
+	o.templateText = o.Template
+	if o.Output == "go-template-file" {
+		data, err := os.ReadFile(o.Template)
+		if err != nil {
+			return fmt.Errorf("failed to read go-template file: %w", err)
+		}
+		o.templateText = string(data)
+	}
+	_, err := output.ParseTemplate(o.templateText)
+	return err
+}
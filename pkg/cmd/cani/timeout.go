@@ -0,0 +1,54 @@
+package cani
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+// DefaultTimeout is how long a command waits on the cluster before giving up
+const DefaultTimeout = 30 * time.Second
+
+// addTimeoutFlag registers --timeout
+func addTimeoutFlag(flags *pflag.FlagSet, timeout *time.Duration) {
+	flags.DurationVar(timeout, "timeout", *timeout, "How long the command may wait on the cluster in all, e.g. 2m (0 waits forever)")
+}
+
+// validateTimeout checks the --timeout flag
+func validateTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("--timeout must not be negative, got %s", timeout)
+	}
+	return nil
+}
+
+// withTimeout bounds ctx by timeout, unless it is 0, and records the phase
+// of the calls made with it
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, *client.Progress, context.CancelFunc) {
+	progress := &client.Progress{}
+	ctx = client.WithProgress(ctx, progress)
+	if timeout <= 0 {
+		return ctx, progress, func() {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, progress, cancel
+}
+
+// timeoutError replaces err, once ctx's deadline has passed, with an error
+// naming the phase the timeout cut short. A result printed by then may be
+// incomplete, so a command that returned no error fails too.
+func timeoutError(ctx context.Context, err error, timeout time.Duration, progress *client.Progress) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	phase := progress.Phase()
+	if phase == "" {
+		phase = "connecting to the cluster"
+	}
+	return fmt.Errorf("timed out after %s while %s; raise --timeout or check that the API server is reachable: %w", timeout, phase, context.DeadlineExceeded)
+}
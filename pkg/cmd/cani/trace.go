@@ -0,0 +1,30 @@
+package cani
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// traceWarnBindings is the trace size above which --trace warns that the
+// output will be long
+const traceWarnBindings = 1000
+
+// filterTrace drops system:* bindings from the trace with
+// --skip-system-bindings, and warns when the trace is still very large
+func (o *RbacWhyOptions) filterTrace(result *rbac.PermissionResult) {
+	if o.SkipSystemBindings {
+		var kept []rbac.BindingTrace
+		for _, t := range result.Trace {
+			if !strings.HasPrefix(t.Binding.Name, "system:") {
+				kept = append(kept, t)
+			}
+		}
+		result.Trace = kept
+		return
+	}
+	if len(result.Trace) > traceWarnBindings {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: the trace covers %d bindings; pass --skip-system-bindings to leave out system:* bindings\n", len(result.Trace))
+	}
+}
@@ -0,0 +1,34 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+	"github.com/hardik/kubectl-rbac-why/pkg/usage"
+)
+
+// runUsageAnalysis compares every grant of the subject with the requests it
+// made according to the audit log in --usage-from
+func (o *RbacWhyOptions) runUsageAnalysis(ctx context.Context, resolver *rbac.Resolver, subject rbac.Subject) error {
+	observed, err := usage.Load(o.UsageFrom, subject)
+	if err != nil {
+		return err
+	}
+	grants, err := resolver.AllGrants(ctx, subject)
+	if err != nil {
+		return fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+
+	report := output.UsageReport{
+		Subject:  subject,
+		Source:   o.UsageFrom,
+		Observed: observed,
+		Grants:   usage.Compare(grants, observed),
+	}
+	if o.Output == "json" {
+		return output.PrintUsageReportJSON(o.Out, report)
+	}
+	return output.PrintUsageReport(o.Out, report)
+}
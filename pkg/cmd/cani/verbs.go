@@ -0,0 +1,62 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// runVerbs checks each verb of a comma-separated list against the same
+// resource. The RBAC objects are read once and shared by every check.
+func (o *RbacWhyOptions) runVerbs(ctx context.Context, rbacClient client.RBACClient, subject rbac.Subject, resolverOpts []rbac.ResolverOption) error {
+	request := o.ToPermissionRequest()
+	unknown, discoveryErr := o.unknownResource(ctx, rbacClient, request)
+	if unknown != "" {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", unknown)
+	}
+	o.warnUnknownSubresource(ctx, rbacClient, request)
+
+	resolver := rbac.NewResolver(client.NewCached(rbacClient), resolverOpts...)
+	results := make([]*rbac.PermissionResult, 0, len(o.Verbs))
+	allowed := 0
+	for _, verb := range o.Verbs {
+		request.Verb = verb
+		result, err := resolver.ResolvePermission(ctx, subject, request)
+		if err != nil {
+			return fmt.Errorf("failed to resolve permission to %s: %w", verb, err)
+		}
+		if unknown != "" {
+			result.Warnings = append(result.Warnings, rbac.Warning{Code: rbac.WarningUnknownResource, Message: unknown})
+		}
+		if discoveryErr != nil {
+			result.Diagnostics = append(result.Diagnostics, rbac.DiscoveryDiagnostic(discoveryErr))
+		}
+		if result.Allowed {
+			allowed++
+		}
+		results = append(results, result)
+	}
+	o.warnClusterScoped(results[0])
+
+	var err error
+	switch o.Output {
+	case "json":
+		err = output.PrintVerbsJSON(o.Out, results, o.contextInfo())
+	case "yaml":
+		err = output.PrintVerbsYAML(o.Out, results, o.contextInfo())
+	case "sar":
+		err = output.PrintSubjectAccessReviews(o.Out, results)
+	default:
+		output.PrintVerbs(o.Out, results)
+	}
+	if err != nil {
+		return err
+	}
+	if o.Any {
+		return o.verdict(allowed > 0)
+	}
+	return o.verdict(allowed == len(results))
+}
@@ -0,0 +1,160 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// With --watch, changes are evaluated once none arrive for
+// defaultWatchQuiet, or defaultWatchMaxWait after the first, so a burst
+// such as kubectl apply of a directory prints one result
+const (
+	defaultWatchQuiet   = 500 * time.Millisecond
+	defaultWatchMaxWait = 5 * time.Second
+)
+
+// runWatch evaluates the check, then again each time a Role, ClusterRole,
+// or binding it could depend on changes, until ctx is done
+func (o *RbacWhyOptions) runWatch(ctx context.Context, rbacClient client.RBACClient, resolver *rbac.Resolver, subject rbac.Subject) error {
+	watcher, ok := rbacClient.(client.WatchClient)
+	if !ok {
+		return fmt.Errorf("--watch requires a live cluster connection")
+	}
+	printer, err := output.NewPrinter(o.Output)
+	if err != nil {
+		return err
+	}
+
+	request := o.ToPermissionRequest()
+	unknown, _ := o.unknownResource(ctx, rbacClient, request)
+	if unknown != "" {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: %s\n", unknown)
+	}
+	events, err := watcher.WatchRBAC(ctx, request.Namespace)
+	if err != nil {
+		return err
+	}
+
+	result, err := resolver.ResolvePermission(ctx, subject, request)
+	if err != nil {
+		return fmt.Errorf("failed to resolve permission: %w", err)
+	}
+	if err := printer.Print(o.Out, result, o.contextInfo()); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(o.ErrOut, "Watching for RBAC changes; press Ctrl-C to stop\n")
+
+	for {
+		changes, ok := collectEvents(ctx, events, o.watchQuiet, o.watchMaxWait)
+		if !ok {
+			return nil
+		}
+		previous := result
+		result, err = resolver.ResolvePermission(ctx, subject, request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: failed to evaluate the check again after %s: %v\n", describeEvents(changes), err)
+			result = previous
+			continue
+		}
+
+		// The change line goes to stderr when stdout is a stream of documents
+		w := o.Out
+		if o.Output != "text" {
+			w = o.ErrOut
+		} else {
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "[%s] %s → %s\n", time.Now().Format(time.TimeOnly), describeEvents(changes), verdictChange(previous, result))
+		if o.Output == "text" {
+			_, _ = fmt.Fprintln(w)
+		}
+		if err := printer.Print(o.Out, result, o.contextInfo()); err != nil {
+			return err
+		}
+	}
+}
+
+// collectEvents waits for a change, then gathers those that follow within
+// quiet of each other, up to maxWait after the first. Changes to the same
+// object are merged, keeping the last. It returns false once ctx is done or
+// events is closed.
+func collectEvents(ctx context.Context, events <-chan client.RBACEvent, quiet, maxWait time.Duration) ([]client.RBACEvent, bool) {
+	var changes []client.RBACEvent
+	add := func(e client.RBACEvent) {
+		for i, c := range changes {
+			if c.Kind == e.Kind && c.Namespace == e.Namespace && c.Name == e.Name {
+				changes[i].Type = e.Type
+				return
+			}
+		}
+		changes = append(changes, e)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case e, ok := <-events:
+		if !ok {
+			return nil, false
+		}
+		add(e)
+	}
+
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+	idle := time.NewTimer(quiet)
+	defer idle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case e, ok := <-events:
+			if !ok {
+				return changes, true
+			}
+			add(e)
+			idle.Reset(quiet)
+		case <-idle.C:
+			return changes, true
+		case <-deadline.C:
+			return changes, true
+		}
+	}
+}
+
+// describeEvents names the first change and counts the rest, e.g.
+// "RoleBinding prod/edit updated (+2 more)"
+func describeEvents(changes []client.RBACEvent) string {
+	if len(changes) == 1 {
+		return changes[0].String()
+	}
+	return fmt.Sprintf("%s (+%d more)", changes[0], len(changes)-1)
+}
+
+// verdictChange says whether the verdict changed, e.g. "now ALLOWED" or
+// "still DENIED"
+func verdictChange(previous, current *rbac.PermissionResult) string {
+	if verdictLabel(previous) == verdictLabel(current) {
+		return "still " + verdictLabel(current)
+	}
+	return "now " + verdictLabel(current)
+}
+
+func verdictLabel(result *rbac.PermissionResult) string {
+	switch {
+	case result.Allowed:
+		return "ALLOWED"
+	case result.PartiallyAllowed:
+		return "PARTIALLY ALLOWED"
+	default:
+		return "DENIED"
+	}
+}
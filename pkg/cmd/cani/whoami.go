@@ -0,0 +1,161 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+var (
+	whoamiLong = `Shows the identity can-i uses when --as is not given.
+
+Reads the current kubeconfig context the same way can-i does: the CN and O
+of a client certificate, the IAM identity of an EKS exec plugin (mapped
+through the aws-auth ConfigMap), or the kubeconfig user name for tokens
+and other exec plugins. It then asks the API server who the caller really
+is with a SelfSubjectReview (Kubernetes 1.28+) and shows both, warning
+when they differ.`
+
+	whoamiExamples = `  # Who would can-i check as?
+  kubectl rbac-why whoami
+
+  # In another context, as JSON
+  kubectl rbac-why whoami --context prod -o json`
+)
+
+// WhoAmIOptions contains the options for the whoami command
+type WhoAmIOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Output     string
+	AWSProfile string
+
+	// IdentityClient, when set, is used instead of connecting to the cluster
+	IdentityClient client.IdentityClient
+
+	context *ContextInfo
+
+	genericclioptions.IOStreams
+}
+
+// NewWhoAmIOptions creates new WhoAmIOptions with defaults
+func NewWhoAmIOptions(streams genericclioptions.IOStreams) *WhoAmIOptions {
+	return &WhoAmIOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdWhoAmI creates the whoami command
+func NewCmdWhoAmI(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewWhoAmIOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "whoami [flags]",
+		Short:   "Show the subject can-i uses for the current context",
+		Long:    whoamiLong,
+		Example: whoamiExamples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json, yaml")
+	cmd.Flags().StringVarP(&o.AWSProfile, "profile", "p", "", "AWS profile to use for authentication (for EKS clusters)")
+
+	return cmd
+}
+
+// Complete derives the identity from the current context, like can-i
+func (o *WhoAmIOptions) Complete() error {
+	if o.ConfigFlags.Impersonate != nil && *o.ConfigFlags.Impersonate != "" {
+		return fmt.Errorf("whoami shows the kubeconfig identity and cannot be used with --as")
+	}
+	ro := &RbacWhyOptions{ConfigFlags: o.ConfigFlags, AWSProfile: o.AWSProfile}
+	if err := ro.completeFromCurrentContext(context.Background()); err != nil {
+		return err
+	}
+	o.context = ro.CurrentContext
+	return nil
+}
+
+// Validate checks the whoami options
+func (o *WhoAmIOptions) Validate() error {
+	if o.Output != "text" && o.Output != "json" && o.Output != "yaml" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json, yaml)", o.Output)
+	}
+	return nil
+}
+
+// Run asks the API server for its view of the identity and prints both
+func (o *WhoAmIOptions) Run(ctx context.Context) error {
+	ic := o.IdentityClient
+	if ic == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		ic = k8sClient
+	}
+
+	// EKS maps the IAM identity to a username through aws-auth
+	if o.context.AuthMethod == "aws-iam" && o.context.AWSIamArn != "" {
+		if info, ok := ic.(client.ClusterInfoClient); ok {
+			cm, err := info.GetConfigMap(ctx, "kube-system", "aws-auth")
+			if err != nil {
+				_, _ = fmt.Fprintf(o.ErrOut, "Warning: failed to read aws-auth ConfigMap: %v\n", err)
+			} else {
+				identity := awsAuthIdentityFromConfigMap(cm, o.context.AWSIamArn)
+				o.context.UserName, o.context.Groups = identity.Username, identity.Groups
+				if identity.Found {
+					o.context.AuthMethod = "aws-iam (via aws-auth)"
+				} else {
+					o.context.AuthMethod = "aws-iam (not in aws-auth)"
+				}
+			}
+		}
+	}
+
+	user, err := ic.SelfSubjectReview(ctx)
+	if apierrors.IsNotFound(err) {
+		err = fmt.Errorf("the API server does not serve SelfSubjectReview (Kubernetes 1.28+)")
+	}
+
+	out := output.BuildWhoAmIOutput(&output.ContextInfo{
+		ContextName: o.context.ContextName,
+		ClusterName: o.context.ClusterName,
+		AuthInfo:    o.context.AuthInfo,
+		UserName:    o.context.UserName,
+		Groups:      o.context.Groups,
+		AuthMethod:  o.context.AuthMethod,
+		Namespace:   o.context.Namespace,
+	}, user, err)
+	switch o.Output {
+	case "json":
+		return output.PrintWhoAmIJSON(o.Out, out)
+	case "yaml":
+		return output.PrintWhoAmIYAML(o.Out, out)
+	}
+	output.PrintWhoAmI(o.Out, out)
+	return nil
+}
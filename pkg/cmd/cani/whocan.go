@@ -0,0 +1,213 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	whoCanLong = `Lists every subject that is granted VERB RESOURCE.
+
+Walks all ClusterRoleBindings and the RoleBindings in the namespace (or in
+every namespace with -A) and prints each User, Group, and ServiceAccount
+they name whose role has a matching rule, with the binding and role chain.
+A subject bound more than once is listed once, with every path.
+
+Only subjects named in bindings are listed: members of a bound Group are
+not expanded, and members of system:masters are always allowed.`
+
+	whoCanExamples = `  # Who can read secrets in the prod namespace?
+  kubectl rbac-why who-can get secrets -n prod
+
+  # Who can exec into pods anywhere?
+  kubectl rbac-why who-can create pods/exec -A
+
+  # Skip namespaces whose RoleBindings can't be listed
+  kubectl rbac-why who-can create pods/exec -A --keep-going
+
+  # One line per subject and path, with the rule that matched
+  kubectl rbac-why who-can get secrets -n prod -o wide
+
+  # Who can delete nodes, as JSON
+  kubectl rbac-why who-can delete nodes -o json`
+)
+
+// WhoCanOptions contains the options for the who-can command
+type WhoCanOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+	Listing     ListingOptions
+
+	AllNamespaces bool
+	Output        string
+	Timeout       time.Duration
+	KeepGoing     bool
+	Strict        bool
+
+	// RBACClient, when set, is used instead of connecting to the cluster
+	RBACClient client.RBACClient
+
+	request rbac.PermissionRequest
+
+	genericclioptions.IOStreams
+}
+
+// NewWhoCanOptions creates new WhoCanOptions with defaults
+func NewWhoCanOptions(streams genericclioptions.IOStreams) *WhoCanOptions {
+	return &WhoCanOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Listing:     NewListingOptions(),
+		Timeout:     DefaultTimeout,
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdWhoCan creates the who-can command
+func NewCmdWhoCan(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewWhoCanOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "who-can VERB RESOURCE [flags]",
+		Short:   "List every subject granted a permission",
+		Long:    whoCanLong,
+		Example: whoCanExamples,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			ctx, progress, cancel := withTimeout(cmd.Context(), o.Timeout)
+			defer cancel()
+			return timeoutError(ctx, o.Run(ctx), o.Timeout, progress)
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	o.Listing.AddFlags(cmd.Flags(), o.ConfigFlags)
+	addTimeoutFlag(cmd.Flags(), &o.Timeout)
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", false, "Include RoleBindings in all namespaces")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, wide, json, yaml")
+	cmd.Flags().BoolVar(&o.KeepGoing, "keep-going", false, "Skip namespaces whose RoleBindings can't be listed, with a warning, and mark the results as partial (-A only)")
+	cmd.Flags().BoolVar(&o.Strict, "strict", false, "With --keep-going, exit with code 3 when the results are partial")
+
+	return cmd
+}
+
+// Complete parses VERB RESOURCE and fills in the namespace
+func (o *WhoCanOptions) Complete(args []string) error {
+	parsed, err := parseResourceArg(args[1])
+	if err != nil {
+		return err
+	}
+	o.request = rbac.PermissionRequest{
+		Verb:           args[0],
+		APIGroup:       parsed.APIGroup,
+		Resource:       parsed.Resource,
+		Subresource:    parsed.Subresource,
+		ResourceName:   parsed.ResourceName,
+		NonResourceURL: parsed.NonResourceURL,
+	}
+
+	if o.AllNamespaces || o.request.NonResourceURL != "" {
+		return nil
+	}
+	if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+		o.request.Namespace = *o.ConfigFlags.Namespace
+		return nil
+	}
+	ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("failed to determine namespace: %w", err)
+	}
+	o.request.Namespace = ns
+	return nil
+}
+
+// Validate checks the who-can options
+func (o *WhoCanOptions) Validate() error {
+	if o.request.Verb == "" || (o.request.Resource == "" && o.request.NonResourceURL == "") {
+		return fmt.Errorf("VERB and RESOURCE are required")
+	}
+	if o.Output != "text" && o.Output != "wide" && o.Output != "json" && o.Output != "yaml" {
+		return fmt.Errorf("invalid output format: %s (valid: text, wide, json, yaml)", o.Output)
+	}
+	if err := validateTimeout(o.Timeout); err != nil {
+		return err
+	}
+	if o.KeepGoing && !o.AllNamespaces {
+		return fmt.Errorf("--keep-going is only supported with -A")
+	}
+	if o.Strict && !o.KeepGoing {
+		return fmt.Errorf("--strict requires --keep-going")
+	}
+	return o.Listing.Validate()
+}
+
+// Run finds and prints the subjects granted the request
+func (o *WhoCanOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		rbacClient, err = o.Listing.newClient(restConfig, o.ErrOut)
+		if err != nil {
+			return err
+		}
+		defer o.Listing.printAge(o.ErrOut)
+	}
+
+	resolver := rbac.NewResolver(rbacClient)
+	var subjects []rbac.SubjectGrants
+	var skipped []rbac.SkippedNamespace
+	var err error
+	if o.KeepGoing {
+		subjects, skipped, err = resolver.WhoCanKeepGoing(ctx, o.request)
+	} else {
+		subjects, err = resolver.WhoCan(ctx, o.request, o.AllNamespaces)
+	}
+	if err != nil {
+		return err
+	}
+
+	var skippedOutput []output.SkippedScopeOutput
+	for _, s := range skipped {
+		_, _ = fmt.Fprintf(o.ErrOut, "Warning: skipping namespace %s: %v\n", s.Namespace, s.Err)
+		skippedOutput = append(skippedOutput, output.SkippedScopeOutput{Scope: "namespace " + s.Namespace, Error: s.Err.Error()})
+	}
+
+	switch o.Output {
+	case "json":
+		err = output.PrintWhoCanJSON(o.Out, o.request, subjects, skippedOutput)
+	case "yaml":
+		err = output.PrintWhoCanYAML(o.Out, o.request, subjects, skippedOutput)
+	case "wide":
+		err = output.PrintWhoCanWide(o.Out, o.request, o.AllNamespaces, subjects)
+		output.PrintSkippedScopes(o.Out, skippedOutput)
+	default:
+		output.PrintWhoCan(o.Out, o.request, o.AllNamespaces, subjects)
+		output.PrintSkippedScopes(o.Out, skippedOutput)
+	}
+	if err != nil {
+		return err
+	}
+
+	if o.Strict && len(skipped) > 0 {
+		return exitcode.Partial(len(skipped))
+	}
+	return nil
+}
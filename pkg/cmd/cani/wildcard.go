@@ -0,0 +1,63 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// wildcardAudit reports whether the request asks about all resources ("*" or
+// "*.GROUP") rather than a resource literally named "*"
+func (o *RbacWhyOptions) wildcardAudit() bool {
+	if !rbac.IsWildcardResource(o.Resource) || o.Subresource != "" || o.Verb == "" {
+		return false
+	}
+	return (o.needsPermissionArgs() || o.ShowRisky) && o.Filename == "" && o.RequestPath == ""
+}
+
+// runWildcardAudit lists the rules that grant the verb on every resource.
+// With --show-risky, each rule is annotated with the risky categories it
+// covers, and --fail-on applies to those.
+func (o *RbacWhyOptions) runWildcardAudit(ctx context.Context, resolver *rbac.Resolver, subject rbac.Subject) error {
+	grants, err := resolver.WildcardGrants(ctx, subject, o.ToPermissionRequest())
+	if err != nil {
+		return fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+
+	audit := output.WildcardAudit{
+		Subject:   subject,
+		Verb:      o.Verb,
+		APIGroup:  o.APIGroup,
+		Namespace: o.Namespace,
+		Grants:    grants,
+	}
+	failing := 0
+	if o.ShowRisky {
+		threshold := rbac.SeverityRank(o.FailOn)
+		for _, g := range grants {
+			risks := output.AnalyzeRiskyPermissionsWithOverrides([]rbac.PermissionGrant{g.PermissionGrant}, o.SeverityOverrides)
+			audit.Risks = append(audit.Risks, risks)
+			for _, risk := range risks {
+				if o.FailOn != "" && rbac.SeverityRank(risk.Severity) >= threshold {
+					failing++
+				}
+			}
+		}
+	}
+
+	if o.Output == "json" {
+		if err := output.PrintWildcardAuditJSON(o.Out, audit); err != nil {
+			return err
+		}
+	} else {
+		output.PrintWildcardAudit(o.Out, audit)
+	}
+
+	if failing > 0 {
+		return exitcode.Failure(fmt.Errorf("%d risky permission pattern(s) at or above %s severity", failing, o.FailOn))
+	}
+	return nil
+}
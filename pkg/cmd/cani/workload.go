@@ -0,0 +1,197 @@
+package cani
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+// workloadKinds maps the kinds --workload accepts, by kind, plural, or short
+// name, to the resource read for them
+var workloadKinds = map[string]string{
+	"deployment":  "deployments.apps",
+	"statefulset": "statefulsets.apps",
+	"daemonset":   "daemonsets.apps",
+	"job":         "jobs.batch",
+	"cronjob":     "cronjobs.batch",
+}
+
+var workloadAliases = map[string]string{
+	"deployments": "deployment", "deploy": "deployment",
+	"statefulsets": "statefulset", "sts": "statefulset",
+	"daemonsets": "daemonset", "ds": "daemonset",
+	"jobs":     "job",
+	"cronjobs": "cronjob", "cj": "cronjob",
+}
+
+// workloadRef is the Pod or workload whose ServiceAccount is the subject
+type workloadRef struct {
+	kind      string // "pod" or a key of workloadKinds
+	namespace string
+	name      string
+}
+
+func (r workloadRef) String() string {
+	return r.kind + " " + r.namespace + "/" + r.name
+}
+
+// flag is the flag the object was named with
+func (r workloadRef) flag() string {
+	if r.kind == "pod" {
+		return "--pod"
+	}
+	return "--workload"
+}
+
+func (r workloadRef) resource() string {
+	if r.kind == "pod" {
+		return "pods"
+	}
+	return workloadKinds[r.kind]
+}
+
+// completePod parses --pod. The subject is filled in by
+// resolveWorkloadSubject, which needs a cluster connection.
+func (o *RbacWhyOptions) completePod() error {
+	if o.AsProvided {
+		return fmt.Errorf("--pod cannot be combined with --as or --sa")
+	}
+	namespace, name, ok := splitNamespacedName(o.Pod)
+	if !ok {
+		return fmt.Errorf("invalid --pod value %q (expected NAMESPACE/NAME or NAME)", o.Pod)
+	}
+	return o.setWorkload(workloadRef{kind: "pod", namespace: namespace, name: name})
+}
+
+// completeWorkload parses --workload like completePod
+func (o *RbacWhyOptions) completeWorkload() error {
+	if o.AsProvided {
+		return fmt.Errorf("--workload cannot be combined with --as, --sa, or --pod")
+	}
+	kind, ref, found := strings.Cut(o.Workload, "/")
+	if !found {
+		return fmt.Errorf("invalid --workload value %q (expected KIND/NAMESPACE/NAME or KIND/NAME)", o.Workload)
+	}
+	kind = strings.ToLower(kind)
+	if alias, ok := workloadAliases[kind]; ok {
+		kind = alias
+	}
+	if _, ok := workloadKinds[kind]; !ok {
+		return fmt.Errorf("unsupported --workload kind %q (expected deployment, statefulset, daemonset, job, or cronjob)", kind)
+	}
+	namespace, name, ok := splitNamespacedName(ref)
+	if !ok {
+		return fmt.Errorf("invalid --workload value %q (expected KIND/NAMESPACE/NAME or KIND/NAME)", o.Workload)
+	}
+	return o.setWorkload(workloadRef{kind: kind, namespace: namespace, name: name})
+}
+
+// setWorkload records ref, defaulting its namespace to -n, then the context
+// namespace, then "default" (like kubectl)
+func (o *RbacWhyOptions) setWorkload(ref workloadRef) error {
+	if ref.namespace == "" {
+		ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return fmt.Errorf("failed to determine namespace for %s: %w", ref.flag(), err)
+		}
+		ref.namespace = ns
+	}
+	o.workload = ref
+	o.AsProvided = true
+	o.SubjectOrigin = ref.String()
+	return nil
+}
+
+// splitNamespacedName splits NAMESPACE/NAME or NAME
+func splitNamespacedName(s string) (namespace, name string, ok bool) {
+	namespace, name, found := strings.Cut(s, "/")
+	if !found {
+		namespace, name = "", s
+	}
+	if (found && namespace == "") || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return namespace, name, true
+}
+
+// resolveWorkloadSubject reads the --pod or --workload object and sets the
+// subject to the ServiceAccount its Pods run as
+func (o *RbacWhyOptions) resolveWorkloadSubject(ctx context.Context, rbacClient client.RBACClient) error {
+	ref := o.workload
+	spec, err := podSpec(ctx, rbacClient, ref)
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("%s not found", ref)
+	case apierrors.IsForbidden(err):
+		return fmt.Errorf("cannot read %s: %s needs get on %s in namespace %s: %w", ref, ref.flag(), ref.resource(), ref.namespace, err)
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", ref, err)
+	}
+
+	// Like the API server, a Pod without a ServiceAccount runs as "default"
+	sa := spec.ServiceAccountName
+	if sa == "" {
+		sa = "default"
+	}
+	o.As = "system:serviceaccount:" + ref.namespace + ":" + sa
+	return nil
+}
+
+// podSpec reads the Pod, or the Pod template of the workload, that ref names
+func podSpec(ctx context.Context, rbacClient client.RBACClient, ref workloadRef) (*corev1.PodSpec, error) {
+	if ref.kind == "pod" {
+		pc, ok := rbacClient.(client.PodClient)
+		if !ok {
+			return nil, fmt.Errorf("--pod needs a cluster connection to read the Pod")
+		}
+		pod, err := pc.GetPod(ctx, ref.namespace, ref.name)
+		if err != nil {
+			return nil, err
+		}
+		return &pod.Spec, nil
+	}
+
+	wc, ok := rbacClient.(client.WorkloadClient)
+	if !ok {
+		return nil, fmt.Errorf("--workload needs a cluster connection to read the %s", ref.kind)
+	}
+	switch ref.kind {
+	case "deployment":
+		d, err := wc.GetDeployment(ctx, ref.namespace, ref.name)
+		if err != nil {
+			return nil, err
+		}
+		return &d.Spec.Template.Spec, nil
+	case "statefulset":
+		s, err := wc.GetStatefulSet(ctx, ref.namespace, ref.name)
+		if err != nil {
+			return nil, err
+		}
+		return &s.Spec.Template.Spec, nil
+	case "daemonset":
+		d, err := wc.GetDaemonSet(ctx, ref.namespace, ref.name)
+		if err != nil {
+			return nil, err
+		}
+		return &d.Spec.Template.Spec, nil
+	case "job":
+		j, err := wc.GetJob(ctx, ref.namespace, ref.name)
+		if err != nil {
+			return nil, err
+		}
+		return &j.Spec.Template.Spec, nil
+	case "cronjob":
+		// A CronJob's Pod template is nested in its Job template
+		c, err := wc.GetCronJob(ctx, ref.namespace, ref.name)
+		if err != nil {
+			return nil, err
+		}
+		return &c.Spec.JobTemplate.Spec.Template.Spec, nil
+	}
+	return nil, fmt.Errorf("unsupported kind %q", ref.kind)
+}
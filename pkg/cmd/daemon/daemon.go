@@ -0,0 +1,303 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/audit"
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/config"
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	longDesc = `Runs the risky permission audit on a schedule.
+
+Every subject referenced by a ClusterRoleBinding or RoleBinding is
+analyzed for risky permissions. Only the delta from the previous scan
+is emitted, as newline-delimited JSON events on stdout:
+
+  scan.baseline     the first scan, when there is no previous result set
+  finding.new       a risky permission that was not present before
+  finding.resolved  a risky permission that has gone away
+  scan.complete     summary counts for the scan
+  scan.error        the scan failed; the previous result set is kept
+
+The first scan only records the findings that already exist; they are
+counted in scan.baseline but not emitted or notified one by one. The
+previous result set is kept in memory and, with --state-file, on disk
+so that restarts do not re-report existing findings. Notifications
+configured in --config are sent for new findings only.
+
+Each interval is randomly jittered so that many replicas do not scan
+the API server at the same moment.`
+
+	examples = `  # Scan all namespaces every hour
+  kubectl rbac-why daemon --interval 1h --all-namespaces
+
+  # Persist findings across restarts and send notifications
+  kubectl rbac-why daemon -A --state-file /var/lib/rbac-why/state.json --config rbac-why.yaml
+
+  # Print notification payloads instead of sending them
+  kubectl rbac-why daemon -A --config rbac-why.yaml --notify-dry-run`
+)
+
+// Event types emitted by the daemon
+const (
+	EventScanBaseline    = "scan.baseline"
+	EventFindingNew      = "finding.new"
+	EventFindingResolved = "finding.resolved"
+	EventScanComplete    = "scan.complete"
+	EventScanError       = "scan.error"
+	EventNotifyError     = "notify.error"
+)
+
+// Event is a single ndjson line emitted by the daemon
+type Event struct {
+	Time     time.Time       `json:"time"`
+	Type     string          `json:"type"`
+	Finding  *notify.Finding `json:"finding,omitempty"`
+	Total    int             `json:"total,omitempty"`
+	New      int             `json:"new,omitempty"`
+	Resolved int             `json:"resolved,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// DaemonOptions contains the options for the daemon command
+type DaemonOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Interval      time.Duration
+	Jitter        float64 // Fraction of the interval to randomize by
+	AllNamespaces bool
+	Namespace     string
+	StateFile     string
+	ConfigFile    string
+	NotifyDryRun  bool
+	Once          bool // Run a single scan and exit
+
+	// RBACClient, when set, is used instead of connecting to a cluster
+	RBACClient client.RBACClient
+
+	notifiers   []notify.Notifier
+	scanOptions audit.ScanOptions
+
+	genericclioptions.IOStreams
+}
+
+// NewDaemonOptions creates new DaemonOptions with defaults
+func NewDaemonOptions(streams genericclioptions.IOStreams) *DaemonOptions {
+	return &DaemonOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Interval:    time.Hour,
+		Jitter:      0.1,
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdDaemon creates the daemon command
+func NewCmdDaemon(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewDaemonOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "daemon [flags]",
+		Short:   "Periodically scan for risky permissions and report changes",
+		Long:    longDesc,
+		Example: examples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+
+	cmd.Flags().DurationVar(&o.Interval, "interval", o.Interval, "Time between scans")
+	cmd.Flags().Float64Var(&o.Jitter, "jitter", o.Jitter, "Randomize each interval by up to this fraction of it (0 to disable)")
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", false, "Scan RoleBindings in all namespaces")
+	cmd.Flags().StringVar(&o.StateFile, "state-file", "", "File to persist findings in between restarts")
+	cmd.Flags().StringVar(&o.ConfigFile, "config", "", "Configuration file with notification and severity override settings")
+	cmd.Flags().BoolVar(&o.NotifyDryRun, "notify-dry-run", false, "Print notification payloads to stderr instead of sending them")
+	cmd.Flags().BoolVar(&o.Once, "once", false, "Run a single scan and exit")
+
+	return cmd
+}
+
+// Complete fills in the namespace and notifiers
+func (o *DaemonOptions) Complete() error {
+	if !o.AllNamespaces {
+		if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+			o.Namespace = *o.ConfigFlags.Namespace
+		} else {
+			ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return fmt.Errorf("failed to determine namespace: %w", err)
+			}
+			o.Namespace = ns
+		}
+	}
+
+	if o.ConfigFile != "" {
+		cfg, err := config.Load(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		o.scanOptions = audit.ScanOptions{
+			SeverityOverrides:   cfg.SeverityOverrides,
+			SensitiveNamespaces: cfg.SensitiveNamespaces,
+		}
+		for _, nc := range cfg.Notifications {
+			n, err := notify.New(nc, o.NotifyDryRun, o.ErrOut)
+			if err != nil {
+				return err
+			}
+			o.notifiers = append(o.notifiers, n)
+		}
+	}
+	return nil
+}
+
+// Validate checks the daemon options
+func (o *DaemonOptions) Validate() error {
+	if o.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if o.Jitter < 0 || o.Jitter >= 1 {
+		return fmt.Errorf("--jitter must be in [0, 1)")
+	}
+	if o.NotifyDryRun && o.ConfigFile == "" {
+		return fmt.Errorf("--notify-dry-run requires --config")
+	}
+	return nil
+}
+
+// Run scans until the context is cancelled or a signal is received
+func (o *DaemonOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var prev audit.FindingSet
+	if o.StateFile != "" {
+		var err error
+		if prev, err = audit.LoadState(o.StateFile); err != nil {
+			return err
+		}
+	}
+
+	// Spread out replicas that start together
+	if !o.Once && !sleep(ctx, time.Duration(rand.Float64()*o.Jitter*float64(o.Interval))) {
+		return nil
+	}
+
+	// Each scan re-indexes only the bindings that changed since the last
+	o.scanOptions.Index = &rbac.BindingIndex{}
+	for {
+		curr, _, err := audit.Scan(ctx, rbacClient, o.Namespace, o.scanOptions)
+		if err != nil {
+			emit(o.Out, Event{Type: EventScanError, Error: err.Error()})
+			if o.Once {
+				return err
+			}
+		} else {
+			if prev == nil {
+				// Existing findings are the baseline, not news
+				emit(o.Out, Event{Type: EventScanBaseline, Total: len(curr)})
+			} else {
+				o.report(ctx, prev, curr)
+			}
+			// Only the latest result set is retained
+			prev = curr
+			if o.StateFile != "" {
+				if err := audit.SaveState(o.StateFile, curr); err != nil {
+					emit(o.Out, Event{Type: EventScanError, Error: err.Error()})
+				}
+			}
+		}
+
+		if o.Once || !sleep(ctx, o.nextInterval()) {
+			return nil
+		}
+	}
+}
+
+// report emits the delta between two scans and notifies about new findings
+func (o *DaemonOptions) report(ctx context.Context, prev, curr audit.FindingSet) {
+	added, resolved := audit.Diff(prev, curr)
+	for i := range added {
+		emit(o.Out, Event{Type: EventFindingNew, Finding: &added[i]})
+	}
+	for i := range resolved {
+		emit(o.Out, Event{Type: EventFindingResolved, Finding: &resolved[i]})
+	}
+	emit(o.Out, Event{Type: EventScanComplete, Total: len(curr), New: len(added), Resolved: len(resolved)})
+
+	if len(added) == 0 {
+		return
+	}
+	for _, n := range o.notifiers {
+		if err := n.Notify(ctx, added); err != nil {
+			emit(o.Out, Event{Type: EventNotifyError, Error: err.Error()})
+		}
+	}
+}
+
+// nextInterval returns the interval randomized by ±Jitter
+func (o *DaemonOptions) nextInterval() time.Duration {
+	offset := (rand.Float64()*2 - 1) * o.Jitter * float64(o.Interval)
+	return o.Interval + time.Duration(offset)
+}
+
+// sleep waits for d, returning false if the context was cancelled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// emit writes an event as a single JSON line
+func emit(w io.Writer, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%s\n", data)
+}
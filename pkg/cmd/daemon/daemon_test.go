@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+)
+
+func readEvents(t *testing.T, out *bytes.Buffer) []Event {
+	t.Helper()
+	var events []Event
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	out.Reset()
+	return events
+}
+
+func TestRun_EmitsOnlyDelta(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+		},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops-exec"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "ops"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+
+	out := &bytes.Buffer{}
+	o := NewDaemonOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	o.AllNamespaces = true
+	o.Once = true
+	o.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	events := readEvents(t, out)
+	if len(events) != 1 || events[0].Type != EventScanBaseline || events[0].Total == 0 {
+		t.Fatalf("expected a single baseline event on the first scan, got %+v", events)
+	}
+	baseline := events[0]
+
+	// A second run with the persisted state reports nothing new
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	events = readEvents(t, out)
+	if len(events) != 1 || events[0].Type != EventScanComplete || events[0].Total != baseline.Total {
+		t.Fatalf("expected only a summary, got %+v", events)
+	}
+
+	// A new binding is reported and only its findings are new
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-exec"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "dev"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	events = readEvents(t, out)
+	summary := events[len(events)-1]
+	if summary.Type != EventScanComplete || summary.New != baseline.Total || summary.Total != 2*baseline.Total {
+		t.Fatalf("expected %d new findings, got %+v", baseline.Total, summary)
+	}
+	for _, e := range events[:len(events)-1] {
+		if e.Type != EventFindingNew || e.Finding.Subject != "dev" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+
+	// Removing the binding resolves the findings
+	mock.ClusterRoleBindings.Items = nil
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	events = readEvents(t, out)
+	if len(events) != summary.Total+1 || events[0].Type != EventFindingResolved {
+		t.Fatalf("expected %d resolved findings, got %+v", summary.Total, events)
+	}
+}
+
+type recordingNotifier struct {
+	calls int
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, findings []notify.Finding) error {
+	n.calls++
+	return nil
+}
+
+func TestRun_FirstScanIsBaseline(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops-exec"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "ops"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "exec"},
+	})
+
+	out := &bytes.Buffer{}
+	notifier := &recordingNotifier{}
+	o := NewDaemonOptions(genericclioptions.IOStreams{Out: out, ErrOut: &bytes.Buffer{}})
+	o.RBACClient = mock
+	o.AllNamespaces = true
+	o.Once = true
+	o.notifiers = []notify.Notifier{notifier}
+
+	if err := o.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	events := readEvents(t, out)
+	if len(events) != 1 || events[0].Type != EventScanBaseline || events[0].Total == 0 {
+		t.Errorf("expected a single baseline event, got %+v", events)
+	}
+	if notifier.calls != 0 {
+		t.Errorf("notifier called %d time(s) for the baseline scan, want 0", notifier.calls)
+	}
+}
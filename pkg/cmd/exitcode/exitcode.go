@@ -0,0 +1,62 @@
+// Package exitcode maps a command's outcome to its process exit code, as
+// kubectl auth can-i does: 0 when allowed, 1 when denied, and 2 when the
+// check couldn't be evaluated. 3 is for --keep-going --strict runs that had
+// to skip part of what they were asked to evaluate.
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Exit codes
+const (
+	OK         = 0
+	Denied     = 1
+	Error      = 2
+	Incomplete = 3
+)
+
+// failure is a verdict that fails the command, as opposed to an error
+type failure struct{ error }
+
+func (f failure) Unwrap() error { return f.error }
+
+// Failure marks err as a failing verdict, such as a failed batch check or a
+// lint finding, rather than an error evaluating it. The command exits with
+// Denied for it.
+func Failure(err error) error {
+	return failure{err}
+}
+
+// incomplete is a run whose results left out scopes that failed
+type incomplete struct{ error }
+
+func (i incomplete) Unwrap() error { return i.error }
+
+// Partial returns the error a --strict run fails with when --keep-going
+// skipped scopes that couldn't be evaluated. The command exits with
+// Incomplete for it.
+func Partial(skipped int) error {
+	return incomplete{fmt.Errorf("results are incomplete: %d scope(s) skipped", skipped)}
+}
+
+// ErrDenied is returned once a denied result has been printed. It carries
+// no message of its own.
+var ErrDenied = Failure(errors.New("denied"))
+
+// For returns the exit code for the error a command returned
+func For(err error) int {
+	var f failure
+	var i incomplete
+	switch {
+	case err == nil:
+		return OK
+	case errors.As(err, &f):
+		return Denied
+	case errors.As(err, &i):
+		return Incomplete
+	default:
+		return Error
+	}
+}
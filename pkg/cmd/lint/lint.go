@@ -0,0 +1,167 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/cmd/exitcode"
+	rbaclint "github.com/hardik/kubectl-rbac-why/pkg/lint"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+var (
+	longDesc = `Checks RBAC objects for problems that make them harder to review.
+
+Checks:
+  subset-role  a Role or ClusterRole whose rules are all covered by another
+               role (wildcard-aware). The covering rules are listed so they
+               can be verified, along with bindings to the subset role whose
+               subjects are already bound to the covering role.
+  empty-subjects             a binding with no subjects
+  missing-subject-namespace  a ClusterRoleBinding ServiceAccount subject
+                             without the required namespace
+  missing-serviceaccount     a ServiceAccount subject that does not exist
+  missing-namespace          a ServiceAccount subject in a namespace that
+                             does not exist
+  deprecated-rules           a role whose rules all target API groups the
+                             cluster no longer serves, e.g. extensions
+                             deployments, so it grants nothing
+
+Findings about bindings include a kubectl command that deletes or patches
+the binding. ServiceAccounts and namespaces are listed cluster-wide, even
+without -A, since subjects can live in any namespace.
+
+Roles named "system:*" are skipped unless --include-system is set. Roles
+that grant everything, such as cluster-admin, are never reported as the
+covering role.
+
+Exits non-zero when there are findings.`
+
+	examples = `  # Lint RBAC objects in the current namespace and all ClusterRoles
+  kubectl rbac-why lint
+
+  # Lint the whole cluster
+  kubectl rbac-why lint -A
+
+  # Machine-readable findings
+  kubectl rbac-why lint -A -o json`
+)
+
+// LintOptions contains the options for the lint command
+type LintOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	AllNamespaces bool
+	Namespace     string
+	IncludeSystem bool
+	Output        string
+
+	// RBACClient, when set, is used instead of connecting to a cluster
+	RBACClient client.RBACClient
+
+	genericclioptions.IOStreams
+}
+
+// NewLintOptions creates new LintOptions with defaults
+func NewLintOptions(streams genericclioptions.IOStreams) *LintOptions {
+	return &LintOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdLint creates the lint command
+func NewCmdLint(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewLintOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "lint [flags]",
+		Short:   "Report redundant and problematic RBAC objects",
+		Long:    longDesc,
+		Example: examples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", false, "Lint Roles and RoleBindings in all namespaces")
+	cmd.Flags().BoolVar(&o.IncludeSystem, "include-system", false, "Also lint roles whose names start with \"system:\"")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json")
+
+	return cmd
+}
+
+// Complete fills in the namespace
+func (o *LintOptions) Complete() error {
+	if o.AllNamespaces {
+		return nil
+	}
+	if o.ConfigFlags.Namespace != nil && *o.ConfigFlags.Namespace != "" {
+		o.Namespace = *o.ConfigFlags.Namespace
+		return nil
+	}
+	ns, _, err := o.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return fmt.Errorf("failed to determine namespace: %w", err)
+	}
+	o.Namespace = ns
+	return nil
+}
+
+// Validate checks the lint options
+func (o *LintOptions) Validate() error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", o.Output)
+	}
+	return nil
+}
+
+// Run lints the RBAC objects and prints the findings
+func (o *LintOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	inv, err := rbaclint.Load(ctx, rbacClient, o.Namespace)
+	if err != nil {
+		return err
+	}
+	findings := rbaclint.Run(inv, rbaclint.Options{IncludeSystem: o.IncludeSystem})
+
+	if o.Output == "json" {
+		if err := output.PrintLintFindingsJSON(o.Out, findings); err != nil {
+			return err
+		}
+	} else {
+		output.PrintLintFindings(o.Out, findings)
+	}
+
+	if len(findings) > 0 {
+		return exitcode.Failure(fmt.Errorf("%d lint finding(s)", len(findings)))
+	}
+	return nil
+}
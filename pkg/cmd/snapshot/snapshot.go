@@ -0,0 +1,171 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+var (
+	longDesc = `Saves the cluster's RBAC objects to a file for offline analysis.
+
+Writes every Role, ClusterRole, RoleBinding, and ClusterRoleBinding to a
+single versioned JSON file, along with the cluster name, server version,
+and capture time. With --include-aws-auth, the kube-system/aws-auth
+ConfigMap of an EKS cluster is saved too, so IAM identities can be mapped
+offline.
+
+Run checks against the file with can-i --from-snapshot, without touching
+the API server again.`
+
+	examples = `  # Capture the current cluster
+  kubectl rbac-why snapshot -o rbac-snapshot.json
+
+  # Capture an EKS cluster, including its IAM mappings
+  kubectl rbac-why snapshot --context prod -o prod.json --include-aws-auth
+
+  # Query it later
+  kubectl rbac-why can-i --sa prod/api get secrets -n prod --from-snapshot prod.json`
+)
+
+// SnapshotOptions contains the options for the snapshot command
+type SnapshotOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	// OutputFile is where the snapshot is written, "-" for stdout
+	OutputFile     string
+	IncludeAWSAuth bool
+
+	// ClusterName is recorded in the snapshot; Complete reads it from the
+	// kubeconfig context
+	ClusterName string
+
+	// RBACClient, when set, is used instead of connecting to the cluster
+	RBACClient client.RBACClient
+
+	genericclioptions.IOStreams
+}
+
+// NewSnapshotOptions creates new SnapshotOptions with defaults
+func NewSnapshotOptions(streams genericclioptions.IOStreams) *SnapshotOptions {
+	return &SnapshotOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		OutputFile:  "-",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdSnapshot creates the snapshot command
+func NewCmdSnapshot(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewSnapshotOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "snapshot [-o FILE] [flags]",
+		Short:   "Export the cluster's RBAC objects for offline analysis",
+		Long:    longDesc,
+		Example: examples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&o.OutputFile, "output", "o", o.OutputFile, "File to write the snapshot to, - for stdout")
+	cmd.Flags().BoolVar(&o.IncludeAWSAuth, "include-aws-auth", false, "Also save the kube-system/aws-auth ConfigMap (EKS)")
+
+	return cmd
+}
+
+// Complete reads the cluster name from the kubeconfig context
+func (o *SnapshotOptions) Complete() error {
+	if o.ClusterName != "" {
+		return nil
+	}
+	raw, err := o.ConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	contextName := raw.CurrentContext
+	if o.ConfigFlags.Context != nil && *o.ConfigFlags.Context != "" {
+		contextName = *o.ConfigFlags.Context
+	}
+	if ctx, ok := raw.Contexts[contextName]; ok {
+		o.ClusterName = ctx.Cluster
+	}
+	if o.ConfigFlags.ClusterName != nil && *o.ConfigFlags.ClusterName != "" {
+		o.ClusterName = *o.ConfigFlags.ClusterName
+	}
+	return nil
+}
+
+// Run captures the snapshot and writes it
+func (o *SnapshotOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	snapshot, err := client.TakeSnapshot(ctx, rbacClient)
+	if err != nil {
+		return err
+	}
+	file := client.NewSnapshotFile(snapshot)
+	file.Cluster = o.ClusterName
+
+	if info, ok := rbacClient.(client.ClusterInfoClient); ok {
+		if file.ServerVersion, err = info.ServerVersion(ctx); err != nil {
+			_, _ = fmt.Fprintf(o.ErrOut, "Warning: failed to read the server version: %v\n", err)
+		}
+		if o.IncludeAWSAuth {
+			cm, err := info.GetConfigMap(ctx, "kube-system", "aws-auth")
+			switch {
+			case apierrors.IsNotFound(err):
+				_, _ = fmt.Fprintf(o.ErrOut, "Warning: kube-system/aws-auth not found; the snapshot has no IAM mappings\n")
+			case err != nil:
+				return fmt.Errorf("failed to get aws-auth ConfigMap: %w", err)
+			default:
+				file.AWSAuth = cm
+			}
+		}
+	} else if o.IncludeAWSAuth {
+		return fmt.Errorf("--include-aws-auth needs a client that can read ConfigMaps")
+	}
+
+	var w io.Writer = o.Out
+	if o.OutputFile != "-" {
+		f, err := os.Create(o.OutputFile)
+		if err != nil {
+			return fmt.Errorf("failed to create snapshot file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+	if err := file.Write(w); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if o.OutputFile != "-" {
+		_, _ = fmt.Fprintf(o.ErrOut, "Saved %d Roles, %d ClusterRoles, %d RoleBindings, and %d ClusterRoleBindings from %s to %s\n",
+			len(file.Roles), len(file.ClusterRoles), len(file.RoleBindings), len(file.ClusterRoleBindings), file.Describe(), o.OutputFile)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package teams
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	rbacaudit "github.com/hardik/kubectl-rbac-why/pkg/audit"
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/config"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+var (
+	longDesc = `Reports permission posture per team.
+
+Enumerates every ServiceAccount in the cluster and assigns it to a team
+read from a label or annotation (--key, default "team") on the
+ServiceAccount, or failing that on its namespace. The risky permission
+analysis is run for each subject against one snapshot of the cluster's
+RBAC objects, and rolled up per team: subjects, worst severity, and risky
+grants that come from ClusterRoleBindings.
+
+Subjects with no team are collected under "unassigned", which is listed
+first.`
+
+	examples = `  # Markdown report grouped by the "team" label
+  kubectl rbac-why teams > posture.md
+
+  # Use a different key and include Users referenced by bindings
+  kubectl rbac-why teams --key example.com/owner-team --include-users
+
+  # Standalone HTML page
+  kubectl rbac-why teams -o html > posture.html`
+)
+
+// TeamsOptions contains the options for the teams command
+type TeamsOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Key          string
+	IncludeUsers bool
+	Output       string
+	ConfigFile   string
+
+	// RBACClient, when set, is used instead of connecting to a cluster. It
+	// must implement client.SubjectClient.
+	RBACClient client.RBACClient
+
+	teamOptions rbacaudit.TeamOptions
+
+	genericclioptions.IOStreams
+}
+
+// NewTeamsOptions creates new TeamsOptions with defaults
+func NewTeamsOptions(streams genericclioptions.IOStreams) *TeamsOptions {
+	return &TeamsOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Key:         "team",
+		Output:      "markdown",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdTeams creates the teams command
+func NewCmdTeams(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewTeamsOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:     "teams [flags]",
+		Short:   "Report risky permissions per team label",
+		Long:    longDesc,
+		Example: examples,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+
+	cmd.Flags().StringVar(&o.Key, "key", o.Key, "Label or annotation key holding the team name")
+	cmd.Flags().BoolVar(&o.IncludeUsers, "include-users", false, "Also report Users referenced by bindings")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: markdown, html, json")
+	cmd.Flags().StringVar(&o.ConfigFile, "config", "", "Configuration file with severity override settings")
+
+	return cmd
+}
+
+// Complete loads severity overrides
+func (o *TeamsOptions) Complete() error {
+	o.teamOptions = rbacaudit.TeamOptions{Key: o.Key, IncludeUsers: o.IncludeUsers}
+	if o.ConfigFile != "" {
+		cfg, err := config.Load(o.ConfigFile)
+		if err != nil {
+			return err
+		}
+		o.teamOptions.SeverityOverrides = cfg.SeverityOverrides
+	}
+	return nil
+}
+
+// Validate checks the teams options
+func (o *TeamsOptions) Validate() error {
+	if o.Key == "" {
+		return fmt.Errorf("--key must not be empty")
+	}
+	switch o.Output {
+	case "markdown", "html", "json":
+	default:
+		return fmt.Errorf("invalid output format: %s (valid: markdown, html, json)", o.Output)
+	}
+	return nil
+}
+
+// Run builds and prints the team report
+func (o *TeamsOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	report, err := rbacaudit.TeamReport(ctx, rbacClient, o.teamOptions)
+	if err != nil {
+		return err
+	}
+	switch o.Output {
+	case "html":
+		return output.PrintTeamReportHTML(o.Out, report)
+	case "json":
+		return output.PrintTeamReportJSON(o.Out, report)
+	}
+	output.PrintTeamReportMarkdown(o.Out, report)
+	return nil
+}
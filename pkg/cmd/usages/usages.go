@@ -0,0 +1,151 @@
+package usages
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var (
+	usagesLong = `Lists every binding that refers to a ClusterRole or Role.
+
+For a ClusterRole, searches ClusterRoleBindings and RoleBindings in all
+namespaces; for a Role, RoleBindings in its namespace. Prints the subjects
+of each binding, the namespaces the role reaches, and the Helm release or
+Argo CD application managing each binding. Bindings to built-in groups
+that cover many identities, such as system:authenticated, are flagged.
+
+Use this before editing or deleting a shared role to see who depends on it.`
+
+	usagesExamples = `  # Where is this ClusterRole bound?
+  kubectl rbac-why usages clusterrole pod-reader
+
+  # Who uses a namespaced Role?
+  kubectl rbac-why usages role dev/deployer
+
+  # The same, as JSON
+  kubectl rbac-why usages clusterrole edit -o json`
+)
+
+// UsagesOptions contains the options for the usages command
+type UsagesOptions struct {
+	ConfigFlags *genericclioptions.ConfigFlags
+
+	Output string
+
+	// RBACClient, when set, is used instead of connecting to the cluster
+	RBACClient client.RBACClient
+
+	role rbac.RoleInfo
+
+	genericclioptions.IOStreams
+}
+
+// NewUsagesOptions creates new UsagesOptions with defaults
+func NewUsagesOptions(streams genericclioptions.IOStreams) *UsagesOptions {
+	return &UsagesOptions{
+		ConfigFlags: genericclioptions.NewConfigFlags(true),
+		Output:      "text",
+		IOStreams:   streams,
+	}
+}
+
+// NewCmdUsages creates the usages command
+func NewCmdUsages(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewUsagesOptions(streams)
+
+	cmd := &cobra.Command{
+		Use:       "usages (clusterrole NAME | role NAMESPACE/NAME) [flags]",
+		Short:     "Show every binding that refers to a role",
+		Long:      usagesLong,
+		Example:   usagesExamples,
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"clusterrole", "role"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return o.Run(cmd.Context())
+		},
+	}
+
+	o.ConfigFlags.AddFlags(cmd.Flags())
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format: text, json")
+
+	return cmd
+}
+
+// Complete parses the role kind and name. A Role may be given as
+// NAMESPACE/NAME or as NAME with --namespace.
+func (o *UsagesOptions) Complete(args []string) error {
+	kind, name := strings.ToLower(args[0]), args[1]
+	switch kind {
+	case "clusterrole", "clusterroles":
+		if strings.Contains(name, "/") {
+			return fmt.Errorf("ClusterRoles are not namespaced: %q", name)
+		}
+		o.role = rbac.RoleInfo{Kind: "ClusterRole", Name: name}
+	case "role", "roles":
+		namespace := ""
+		if ns, n, ok := strings.Cut(name, "/"); ok {
+			namespace, name = ns, n
+		} else if o.ConfigFlags.Namespace != nil {
+			namespace = *o.ConfigFlags.Namespace
+		}
+		if namespace == "" {
+			return fmt.Errorf("a Role must be given as NAMESPACE/NAME or with --namespace")
+		}
+		o.role = rbac.RoleInfo{Kind: "Role", Name: name, Namespace: namespace}
+	default:
+		return fmt.Errorf("invalid role kind %q (valid: clusterrole, role)", args[0])
+	}
+	if o.role.Name == "" {
+		return fmt.Errorf("role name is required")
+	}
+	return nil
+}
+
+// Validate checks the usages options
+func (o *UsagesOptions) Validate() error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", o.Output)
+	}
+	return nil
+}
+
+// Run finds and prints the bindings that refer to the role
+func (o *UsagesOptions) Run(ctx context.Context) error {
+	rbacClient := o.RBACClient
+	if rbacClient == nil {
+		restConfig, err := o.ConfigFlags.ToRESTConfig()
+		if err != nil {
+			return fmt.Errorf("failed to create REST config: %w", err)
+		}
+		k8sClient, err := client.NewK8sRBACClient(restConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create RBAC client: %w", err)
+		}
+		rbacClient = k8sClient
+	}
+
+	report, err := rbac.NewResolver(rbacClient).RoleUsages(ctx, o.role)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return output.PrintUsagesJSON(o.Out, report)
+	}
+	output.PrintUsages(o.Out, report)
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+	"github.com/hardik/kubectl-rbac-why/pkg/output"
+)
+
+// Config is the rbac-why configuration file
+type Config struct {
+	// Notifications are sent for new risky findings in daemon mode
+	Notifications []notify.Config `yaml:"notifications,omitempty"`
+
+	// SeverityOverrides changes the severity of built-in risky categories,
+	// e.g. {"pod-create": "critical"}
+	SeverityOverrides output.SeverityOverrides `yaml:"severityOverrides,omitempty"`
+
+	// SensitiveNamespaces are where an automounted ServiceAccount token raises
+	// the severity of its risky permissions. Defaults to kube-system.
+	SensitiveNamespaces []string `yaml:"sensitiveNamespaces,omitempty"`
+}
+
+// Load reads and validates a configuration file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	if err := cfg.SeverityOverrides.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: severityOverrides: %w", path, err)
+	}
+	for i, n := range cfg.Notifications {
+		if err := n.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: notifications[%d]: %w", path, i, err)
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: `notifications:
+  - type: slack
+    url: https://hooks.slack.invalid/x
+    minSeverity: low
+severityOverrides:
+  pod-create: critical
+  secrets-access: medium
+`,
+		},
+		{name: "unknown category", data: "severityOverrides:\n  made-up: high\n", wantErr: true},
+		{name: "invalid severity", data: "severityOverrides:\n  pod-create: severe\n", wantErr: true},
+		{name: "invalid notifier", data: "notifications:\n  - type: email\n    url: x\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.SeverityOverrides["pod-create"] != "critical" {
+				t.Errorf("SeverityOverrides = %v", cfg.SeverityOverrides)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Deprecation is a group/resource pair that current clusters no longer serve
+type Deprecation struct {
+	Group    string
+	Resource string // "*" covers every resource of the group
+	// Replacement is the group that serves the resource now, or "" when the
+	// resource was removed outright
+	Replacement string
+	RemovedIn   string // Kubernetes minor version, e.g. "1.16"
+}
+
+// Deprecations lists renamed and removed group/resource pairs. More specific
+// entries come before the group-wide ones.
+var Deprecations = []Deprecation{
+	{Group: "extensions", Resource: "deployments", Replacement: "apps", RemovedIn: "1.16"},
+	{Group: "extensions", Resource: "daemonsets", Replacement: "apps", RemovedIn: "1.16"},
+	{Group: "extensions", Resource: "replicasets", Replacement: "apps", RemovedIn: "1.16"},
+	{Group: "extensions", Resource: "networkpolicies", Replacement: "networking.k8s.io", RemovedIn: "1.16"},
+	{Group: "extensions", Resource: "podsecuritypolicies", RemovedIn: "1.16"},
+	{Group: "extensions", Resource: "ingresses", Replacement: "networking.k8s.io", RemovedIn: "1.22"},
+	{Group: "extensions", Resource: "*", RemovedIn: "1.22"},
+	{Group: "policy", Resource: "podsecuritypolicies", RemovedIn: "1.25"},
+}
+
+// LookupDeprecation finds the deprecation entry for a group and resource. A
+// subresource such as "deployments/scale" is looked up by its resource.
+func LookupDeprecation(group, resource string) (Deprecation, bool) {
+	resource, _, _ = strings.Cut(resource, "/")
+	for _, d := range Deprecations {
+		if d.Group == group && (d.Resource == resource || d.Resource == "*") {
+			return d, true
+		}
+	}
+	return Deprecation{}, false
+}
+
+// DeadReference explains why a rule's reference to resource in group matches
+// nothing, or returns "" when it is live. With discovery lists, the answer is
+// specific to the cluster: a group the cluster still serves is live. Without
+// them (nil), the deprecation table alone decides.
+func DeadReference(lists []*metav1.APIResourceList, group, resource string) string {
+	if group == "*" || resource == "*" {
+		return ""
+	}
+	d, ok := LookupDeprecation(group, resource)
+	if !ok {
+		return ""
+	}
+	base, _, _ := strings.Cut(resource, "/")
+	if lists != nil {
+		if _, served := Subresources(lists, group, base); served {
+			return ""
+		}
+	}
+
+	msg := fmt.Sprintf("rule targets deprecated group '%s'", group)
+	switch {
+	case d.Replacement != "" && lists != nil:
+		if _, served := Subresources(lists, d.Replacement, base); served {
+			return msg + fmt.Sprintf("; on this cluster %s are served from '%s'", base, d.Replacement)
+		}
+		return msg + fmt.Sprintf("; %s moved to '%s' and this cluster does not serve it from '%s'", base, d.Replacement, group)
+	case d.Replacement != "":
+		return msg + fmt.Sprintf("; %s moved to '%s' (removed from '%s' in Kubernetes %s)", base, d.Replacement, group, d.RemovedIn)
+	case d.Resource == "*":
+		return msg + fmt.Sprintf("; the group is not served since Kubernetes %s", d.RemovedIn)
+	}
+	return fmt.Sprintf("rule targets %s in group '%s', which were removed in Kubernetes %s", base, group, d.RemovedIn)
+}
@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeadReference(t *testing.T) {
+	modern := []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments"}}},
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+	}
+	legacy := []*metav1.APIResourceList{
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+	}
+
+	tests := []struct {
+		name     string
+		lists    []*metav1.APIResourceList
+		group    string
+		resource string
+		want     string
+	}{
+		{name: "live group", lists: modern, group: "apps", resource: "deployments"},
+		{name: "wildcard group", lists: modern, group: "*", resource: "deployments"},
+		{name: "wildcard resource", lists: modern, group: "extensions", resource: "*"},
+		{name: "still served by cluster", lists: legacy, group: "extensions", resource: "ingresses"},
+		{
+			name: "served from replacement", lists: modern, group: "extensions", resource: "deployments",
+			want: "rule targets deprecated group 'extensions'; on this cluster deployments are served from 'apps'",
+		},
+		{
+			name: "subresource", lists: modern, group: "extensions", resource: "deployments/scale",
+			want: "rule targets deprecated group 'extensions'; on this cluster deployments are served from 'apps'",
+		},
+		{
+			name: "no discovery", group: "extensions", resource: "ingresses",
+			want: "rule targets deprecated group 'extensions'; ingresses moved to 'networking.k8s.io' (removed from 'extensions' in Kubernetes 1.22)",
+		},
+		{
+			name: "removed resource", lists: modern, group: "policy", resource: "podsecuritypolicies",
+			want: "rule targets podsecuritypolicies in group 'policy', which were removed in Kubernetes 1.25",
+		},
+		{
+			name: "removed group", lists: modern, group: "extensions", resource: "thirdpartyresources",
+			want: "rule targets deprecated group 'extensions'; the group is not served since Kubernetes 1.22",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeadReference(tt.lists, tt.group, tt.resource); got != tt.want {
+				t.Errorf("DeadReference() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Package discovery answers questions about the resources an API server
+// serves, from its discovery document.
+package discovery
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Subresources returns the subresources of resource in group, sorted, and
+// whether the resource was found in any version of the group
+func Subresources(lists []*metav1.APIResourceList, group, resource string) ([]string, bool) {
+	found := false
+	seen := make(map[string]bool)
+	var subresources []string
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || gv.Group != group {
+			continue
+		}
+		for _, r := range list.APIResources {
+			name, sub, ok := strings.Cut(r.Name, "/")
+			if name != resource {
+				continue
+			}
+			found = true
+			if ok && !seen[sub] {
+				seen[sub] = true
+				subresources = append(subresources, sub)
+			}
+		}
+	}
+	sort.Strings(subresources)
+	return subresources, found
+}
+
+// Normalize maps a resource as users type it, a short name such as "deploy",
+// a singular such as "pod", or a Kind such as "Deployment", to the plural
+// resource discovery serves and its group. An empty group matches any group,
+// preferring the core group. ok is false when resource already is a served
+// plural in group, or discovery has nothing that matches.
+func Normalize(lists []*metav1.APIResourceList, group, resource string) (schema.GroupResource, bool) {
+	if _, found := Subresources(lists, group, resource); found {
+		return schema.GroupResource{}, false
+	}
+	var match schema.GroupResource
+	found := false
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || (group != "" && gv.Group != group) {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !matchesName(r, resource) {
+				continue
+			}
+			if !found || (match.Group != "" && gv.Group == "") {
+				match, found = schema.GroupResource{Group: gv.Group, Resource: r.Name}, true
+			}
+		}
+	}
+	return match, found
+}
+
+// Namespaced reports whether resource in group is namespaced, and whether
+// it was found in any version of the group
+func Namespaced(lists []*metav1.APIResourceList, group, resource string) (namespaced, found bool) {
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || gv.Group != group {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if r.Name == resource {
+				return r.Namespaced, true
+			}
+		}
+	}
+	return false, false
+}
+
+// Groups returns the groups that serve resource, a plural name, with the
+// core group first and the rest sorted
+func Groups(lists []*metav1.APIResourceList, resource string) []string {
+	seen := make(map[string]bool)
+	var groups []string
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || seen[gv.Group] {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if r.Name == resource {
+				seen[gv.Group] = true
+				groups = append(groups, gv.Group)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Resources returns every resource the server serves, subresources left out
+func Resources(lists []*metav1.APIResourceList) []schema.GroupResource {
+	seen := make(map[schema.GroupResource]bool)
+	var resources []schema.GroupResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			gr := schema.GroupResource{Group: gv.Group, Resource: r.Name}
+			if strings.Contains(r.Name, "/") || seen[gr] {
+				continue
+			}
+			seen[gr] = true
+			resources = append(resources, gr)
+		}
+	}
+	return resources
+}
+
+// ServedGroups returns the API groups the server serves, sorted, with ""
+// for the core group
+func ServedGroups(lists []*metav1.APIResourceList) []string {
+	seen := make(map[string]bool)
+	var groups []string
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || seen[gv.Group] {
+			continue
+		}
+		seen[gv.Group] = true
+		groups = append(groups, gv.Group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// matchesName reports whether name is the resource's plural, singular, Kind,
+// or one of its short names, ignoring case
+func matchesName(r metav1.APIResource, name string) bool {
+	if strings.EqualFold(r.Name, name) || strings.EqualFold(r.SingularName, name) || strings.EqualFold(r.Kind, name) {
+		return true
+	}
+	for _, short := range r.ShortNames {
+		if strings.EqualFold(short, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinSubresources are the subresources served by built-in resources
+var builtinSubresources = map[string]bool{
+	"approval": true, "attach": true, "binding": true, "eviction": true,
+	"ephemeralcontainers": true, "exec": true, "finalize": true, "log": true,
+	"portforward": true, "proxy": true, "resize": true, "scale": true,
+	"status": true, "token": true,
+}
+
+// BuiltinSubresources returns the subresources of built-in resources, sorted
+func BuiltinSubresources() []string {
+	names := make([]string, 0, len(builtinSubresources))
+	for name := range builtinSubresources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsBuiltinSubresource reports whether name is a subresource of a built-in
+// resource, such as "exec" or "status"
+func IsBuiltinSubresource(name string) bool {
+	return builtinSubresources[name]
+}
+
+// Closest returns the candidate nearest to s by edit distance, or "" when
+// none is close enough to be a plausible typo
+func Closest(s string, candidates []string) string {
+	best, bestDistance := "", len(s)/2+1
+	for _, c := range candidates {
+		if d := distance(s, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// Similar returns up to n candidates close enough to s to be plausible
+// typos, nearest first
+func Similar(s string, candidates []string, n int) []string {
+	type scored struct {
+		candidate string
+		distance  int
+	}
+	var near []scored
+	for _, c := range candidates {
+		if d := distance(s, c); d < len(s)/2+1 {
+			near = append(near, scored{c, d})
+		}
+	}
+	sort.SliceStable(near, func(i, j int) bool {
+		if near[i].distance != near[j].distance {
+			return near[i].distance < near[j].distance
+		}
+		return near[i].candidate < near[j].candidate
+	})
+	var similar []string
+	for _, c := range near[:min(n, len(near))] {
+		similar = append(similar, c.candidate)
+	}
+	return similar
+}
+
+// distance is the Levenshtein distance between a and b
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
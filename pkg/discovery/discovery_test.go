@@ -0,0 +1,179 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSubresources(t *testing.T) {
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods"}, {Name: "pods/log"}, {Name: "pods/exec"}, {Name: "pods/status"},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments"}, {Name: "deployments/scale"},
+		}},
+		{GroupVersion: "apps/v1beta1", APIResources: []metav1.APIResource{
+			{Name: "deployments/rollback"},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		group     string
+		resource  string
+		want      []string
+		wantFound bool
+	}{
+		{name: "core", group: "", resource: "pods", want: []string{"exec", "log", "status"}, wantFound: true},
+		{name: "merged across versions", group: "apps", resource: "deployments", want: []string{"rollback", "scale"}, wantFound: true},
+		{name: "wrong group", group: "apps", resource: "pods"},
+		{name: "unknown resource", group: "", resource: "widgets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := Subresources(lists, tt.group, tt.resource)
+			if found != tt.wantFound || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subresources() = %v, %v, want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", SingularName: "pod", Kind: "Pod", ShortNames: []string{"po"}},
+			{Name: "pods/log", Kind: "Pod"},
+			{Name: "events", SingularName: "event", Kind: "Event", ShortNames: []string{"ev"}},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Kind: "Deployment", ShortNames: []string{"deploy"}},
+		}},
+		{GroupVersion: "events.k8s.io/v1", APIResources: []metav1.APIResource{
+			{Name: "events", SingularName: "event", Kind: "Event", ShortNames: []string{"ev"}},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		group    string
+		resource string
+		want     schema.GroupResource
+		wantOK   bool
+	}{
+		{name: "short name", resource: "po", want: schema.GroupResource{Resource: "pods"}, wantOK: true},
+		{name: "short name in a group", resource: "deploy", want: schema.GroupResource{Group: "apps", Resource: "deployments"}, wantOK: true},
+		{name: "singular", resource: "deployment", want: schema.GroupResource{Group: "apps", Resource: "deployments"}, wantOK: true},
+		{name: "kind", resource: "Deployment", want: schema.GroupResource{Group: "apps", Resource: "deployments"}, wantOK: true},
+		{name: "core group preferred", resource: "ev", want: schema.GroupResource{Resource: "events"}, wantOK: true},
+		{name: "explicit group", group: "events.k8s.io", resource: "ev", want: schema.GroupResource{Group: "events.k8s.io", Resource: "events"}, wantOK: true},
+		{name: "already canonical", resource: "pods"},
+		{name: "wrong group", group: "apps", resource: "po"},
+		{name: "unknown", resource: "widgets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Normalize(lists, tt.group, tt.resource)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Normalize() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNamespaced(t *testing.T) {
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "pods", Namespaced: true}, {Name: "nodes"},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Namespaced: true},
+		}},
+	}
+
+	tests := []struct {
+		group, resource string
+		wantNamespaced  bool
+		wantFound       bool
+	}{
+		{group: "", resource: "pods", wantNamespaced: true, wantFound: true},
+		{group: "", resource: "nodes", wantFound: true},
+		{group: "apps", resource: "deployments", wantNamespaced: true, wantFound: true},
+		{group: "", resource: "deployments"},
+		{group: "", resource: "widgets"},
+	}
+
+	for _, tt := range tests {
+		namespaced, found := Namespaced(lists, tt.group, tt.resource)
+		if namespaced != tt.wantNamespaced || found != tt.wantFound {
+			t.Errorf("Namespaced(%q, %q) = %v, %v, want %v, %v", tt.group, tt.resource, namespaced, found, tt.wantNamespaced, tt.wantFound)
+		}
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	candidates := []string{"pods", "podtemplates", "nodes", "crontabs.stable.example.com"}
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "pdos", want: []string{"pods"}},
+		{input: "nods", want: []string{"nodes", "pods"}},
+		{input: "crontab.stable.example.com", want: []string{"crontabs.stable.example.com"}},
+		{input: "secrets"},
+	}
+
+	for _, tt := range tests {
+		if got := Similar(tt.input, candidates, 3); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Similar(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	lists := []*metav1.APIResourceList{
+		{GroupVersion: "events.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "events"}}},
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "events"}, {Name: "pods"}, {Name: "pods/log"}}},
+		{GroupVersion: "events.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "events"}}},
+	}
+
+	tests := []struct {
+		resource string
+		want     []string
+	}{
+		{resource: "events", want: []string{"", "events.k8s.io"}},
+		{resource: "pods", want: []string{""}},
+		{resource: "log"},
+	}
+
+	for _, tt := range tests {
+		if got := Groups(lists, tt.resource); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Groups(%q) = %q, want %q", tt.resource, got, tt.want)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"exec", "log", "status", "portforward"}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "logz", want: "log"},
+		{input: "stauts", want: "status"},
+		{input: "port-forward", want: "portforward"},
+		{input: "ephemeralcontainers", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Closest(tt.input, candidates); got != tt.want {
+			t.Errorf("Closest(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package groups loads static group memberships for clusters whose group
+// claims come from an identity provider that cannot be queried.
+package groups
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// Entry maps usernames matching Pattern to Groups
+type Entry struct {
+	// Pattern is a username, ServiceAccount identity, or glob such as
+	// "*@platform.example.com"
+	Pattern string
+	Groups  []string
+	// Source is the file and line the entry was read from, e.g. "groups.yaml:3"
+	Source string
+}
+
+// Mapping is an ordered list of group membership entries
+type Mapping struct {
+	Entries []Entry
+}
+
+// Load reads and validates a groups file. The file is a YAML mapping of
+// username patterns to lists of groups:
+//
+//	alice@example.com: [developers]
+//	"*@platform.example.com": [platform-admins]
+//	system:serviceaccount:ci:deployer: [deployers]
+func Load(file string) (*Mapping, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read groups file: %w", err)
+	}
+	return Parse(data, filepath.Base(file))
+}
+
+// Parse validates a groups file read from source, reporting errors with the
+// line they occur on
+func Parse(data []byte, source string) (*Mapping, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	m := &Mapping{}
+	if len(doc.Content) == 0 {
+		return m, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected a mapping of usernames to lists of groups", source, root.Line)
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Kind != yaml.ScalarNode || key.Value == "" {
+			return nil, fmt.Errorf("%s:%d: username pattern must be a non-empty string", source, key.Line)
+		}
+		if line, ok := seen[key.Value]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate entry %q (first defined on line %d)", source, key.Line, key.Value, line)
+		}
+		seen[key.Value] = key.Line
+		if _, err := path.Match(key.Value, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %w", source, key.Line, key.Value, err)
+		}
+
+		if value.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("%s:%d: groups for %q must be a list", source, value.Line, key.Value)
+		}
+		entry := Entry{Pattern: key.Value, Source: fmt.Sprintf("%s:%d", source, key.Line)}
+		for _, g := range value.Content {
+			if g.Kind != yaml.ScalarNode || g.Value == "" {
+				return nil, fmt.Errorf("%s:%d: group for %q must be a non-empty string", source, g.Line, key.Value)
+			}
+			entry.Groups = append(entry.Groups, g.Value)
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return m, nil
+}
+
+// Match returns the entries whose pattern matches the identity, in file order
+func (m *Mapping) Match(identity string) []Entry {
+	var matched []Entry
+	for _, e := range m.Entries {
+		// Patterns were validated when the file was parsed
+		if ok, _ := path.Match(e.Pattern, identity); ok {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// Apply adds the groups of every matching entry to subject and records which
+// entries matched. Group subjects and subjects with an exact group list (e.g.
+// from a SubjectAccessReview) are left unchanged.
+func (m *Mapping) Apply(subject *rbac.Subject) {
+	if subject.Kind == "Group" || subject.ExactGroups {
+		return
+	}
+	have := make(map[string]bool)
+	for _, g := range subject.Groups {
+		have[g] = true
+	}
+	for _, e := range m.Match(subject.Canonical()) {
+		for _, g := range e.Groups {
+			if !have[g] {
+				have[g] = true
+				subject.Groups = append(subject.Groups, g)
+			}
+		}
+		subject.GroupMappings = append(subject.GroupMappings, rbac.GroupMapping{Source: e.Source, Pattern: e.Pattern, Groups: e.Groups})
+	}
+}
@@ -0,0 +1,96 @@
+package groups
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "alice: [dev]\n\"*@platform.example.com\":\n  - platform-admins\n"},
+		{name: "empty", data: ""},
+		{name: "not a mapping", data: "- alice\n", wantErr: "groups.yaml:1: expected a mapping"},
+		{name: "groups not a list", data: "alice: [dev]\nbob: dev\n", wantErr: "groups.yaml:2: groups for \"bob\" must be a list"},
+		{name: "nested group", data: "alice:\n  - dev\n  - {name: ops}\n", wantErr: "groups.yaml:3: group for \"alice\""},
+		{name: "duplicate", data: "alice: [dev]\nalice: [ops]\n", wantErr: "groups.yaml:2: duplicate entry \"alice\" (first defined on line 1)"},
+		{name: "bad pattern", data: "\"[alice\": [dev]\n", wantErr: "groups.yaml:1: invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), "groups.yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	m, err := Parse([]byte(`"*@platform.example.com": [platform-admins, dev]
+jane@platform.example.com: [dev, oncall]
+system:serviceaccount:ci:*: [ci]
+`), "groups.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		subject     rbac.Subject
+		wantGroups  []string
+		wantSources []string
+	}{
+		{
+			name:        "glob and exact entries merge",
+			subject:     rbac.Subject{Kind: "User", Name: "jane@platform.example.com"},
+			wantGroups:  []string{"platform-admins", "dev", "oncall"},
+			wantSources: []string{"groups.yaml:1", "groups.yaml:2"},
+		},
+		{
+			name:        "service account identity",
+			subject:     rbac.Subject{Kind: "ServiceAccount", Namespace: "ci", Name: "deployer"},
+			wantGroups:  []string{"ci"},
+			wantSources: []string{"groups.yaml:3"},
+		},
+		{
+			name:    "no match",
+			subject: rbac.Subject{Kind: "User", Name: "bob@example.com"},
+		},
+		{
+			name:       "exact groups untouched",
+			subject:    rbac.Subject{Kind: "User", Name: "jane@platform.example.com", Groups: []string{"x"}, ExactGroups: true},
+			wantGroups: []string{"x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := tt.subject
+			m.Apply(&subject)
+			if !reflect.DeepEqual(subject.Groups, tt.wantGroups) {
+				t.Errorf("Groups = %v, want %v", subject.Groups, tt.wantGroups)
+			}
+			var sources []string
+			for _, gm := range subject.GroupMappings {
+				sources = append(sources, gm.Source)
+			}
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Errorf("sources = %v, want %v", sources, tt.wantSources)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package lint
+
+import (
+	"context"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// DanglingBindings reports bindings whose roleRef names a Role or
+// ClusterRole that isn't in the inventory, using the same detection as
+// permission checks
+func DanglingBindings(inv *Inventory) []Finding {
+	snapshot := &client.Snapshot{
+		Roles:               inv.Roles,
+		ClusterRoles:        inv.ClusterRoles,
+		RoleBindings:        inv.RoleBindings,
+		ClusterRoleBindings: inv.ClusterRoleBindings,
+	}
+	// Reading from memory can't fail
+	dangling, _ := rbac.NewResolver(snapshot).DanglingBindings(context.Background(), nil, "")
+
+	var findings []Finding
+	for _, d := range dangling {
+		b := binding{kind: d.Binding.Kind, name: d.Binding.Name, namespace: d.Binding.Namespace}
+		findings = append(findings, Finding{
+			Check:   CheckDanglingBinding,
+			Object:  b.String(),
+			Message: "roleRef " + d.MissingRole() + " doesn't exist, so the binding grants nothing",
+			Fix:     "kubectl delete " + b.kubectl(),
+		})
+	}
+	return findings
+}
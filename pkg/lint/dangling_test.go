@@ -0,0 +1,49 @@
+package lint
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDanglingBindings(t *testing.T) {
+	subjects := []rbacv1.Subject{{Kind: "User", Name: "alice"}}
+	inv := &Inventory{
+		Roles:        []rbacv1.Role{{ObjectMeta: metav1.ObjectMeta{Name: "reader", Namespace: "prod"}}},
+		ClusterRoles: []rbacv1.ClusterRole{{ObjectMeta: metav1.ObjectMeta{Name: "view"}}},
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{
+			{ObjectMeta: metav1.ObjectMeta{Name: "viewers"}, Subjects: subjects, RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "old-admins"}, Subjects: subjects, RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "legacy-admin"}},
+		},
+		RoleBindings: []rbacv1.RoleBinding{
+			{ObjectMeta: metav1.ObjectMeta{Name: "readers", Namespace: "prod"}, Subjects: subjects, RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "reader"}},
+			// The Role of that name is in another namespace
+			{ObjectMeta: metav1.ObjectMeta{Name: "readers", Namespace: "dev"}, Subjects: subjects, RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "reader"}},
+		},
+	}
+
+	want := []Finding{
+		{
+			Check:   CheckDanglingBinding,
+			Object:  "ClusterRoleBinding/old-admins",
+			Message: "roleRef ClusterRole legacy-admin doesn't exist, so the binding grants nothing",
+			Fix:     "kubectl delete clusterrolebinding old-admins",
+		},
+		{
+			Check:   CheckDanglingBinding,
+			Object:  "RoleBinding/dev/readers",
+			Message: "roleRef Role dev/reader doesn't exist, so the binding grants nothing",
+			Fix:     "kubectl delete rolebinding readers -n dev",
+		},
+	}
+	got := DanglingBindings(inv)
+	if len(got) != len(want) {
+		t.Fatalf("DanglingBindings() = %+v, want %d findings", got, len(want))
+	}
+	for i := range want {
+		if got[i].Check != want[i].Check || got[i].Object != want[i].Object || got[i].Message != want[i].Message || got[i].Fix != want[i].Fix {
+			t.Errorf("finding %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
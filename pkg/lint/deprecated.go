@@ -0,0 +1,74 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/discovery"
+)
+
+// DeprecatedRules reports roles whose every rule targets only API groups the
+// cluster no longer serves for the resources named, such as
+// extensions/deployments. Such a role grants nothing, so bindings to it are
+// dead too. When inv has discovery data the check is specific to the cluster.
+func DeprecatedRules(inv *Inventory, opts Options) []Finding {
+	var roles []roleRef
+	for _, cr := range inv.ClusterRoles {
+		roles = append(roles, roleRef{kind: "ClusterRole", name: cr.Name, rules: cr.Rules})
+	}
+	for _, r := range inv.Roles {
+		roles = append(roles, roleRef{kind: "Role", name: r.Name, namespace: r.Namespace, rules: r.Rules})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].String() < roles[j].String() })
+
+	var findings []Finding
+	for _, role := range roles {
+		if len(role.rules) == 0 || (!opts.IncludeSystem && isSystem(role.name)) {
+			continue
+		}
+		var reason string
+		dead := true
+		for _, rule := range role.rules {
+			why := deadRule(inv.Resources, rule)
+			if why == "" {
+				dead = false
+				break
+			}
+			if reason == "" {
+				reason = why
+			}
+		}
+		if !dead {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:   CheckDeprecatedRules,
+			Object:  role.String(),
+			Message: fmt.Sprintf("all %d rule(s) target API groups that are no longer served and grant nothing (e.g. %s)", len(role.rules), reason),
+		})
+	}
+	return findings
+}
+
+// deadRule returns why every group/resource pair of rule is dead, or "" when
+// any of them, or a non-resource URL, is live
+func deadRule(lists discoveryLists, rule rbacv1.PolicyRule) string {
+	if len(rule.NonResourceURLs) > 0 {
+		return ""
+	}
+	var reason string
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			why := discovery.DeadReference(lists, group, resource)
+			if why == "" {
+				return ""
+			}
+			if reason == "" {
+				reason = why
+			}
+		}
+	}
+	return reason
+}
@@ -0,0 +1,58 @@
+package lint
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeprecatedRules(t *testing.T) {
+	legacy := rbacv1.PolicyRule{APIGroups: []string{"extensions"}, Resources: []string{"deployments", "ingresses"}, Verbs: []string{"get"}}
+	current := rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}
+	mixed := rbacv1.PolicyRule{APIGroups: []string{"extensions", "apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}
+
+	inv := &Inventory{
+		ClusterRoles: []rbacv1.ClusterRole{
+			{ObjectMeta: metav1.ObjectMeta{Name: "old-deployer"}, Rules: []rbacv1.PolicyRule{legacy}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "half-migrated"}, Rules: []rbacv1.PolicyRule{legacy, current}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "both-groups"}, Rules: []rbacv1.PolicyRule{mixed}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "system:old"}, Rules: []rbacv1.PolicyRule{legacy}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "aggregated"}},
+		},
+		Roles: []rbacv1.Role{
+			{ObjectMeta: metav1.ObjectMeta{Name: "psp", Namespace: "prod"}, Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}, Verbs: []string{"use"}},
+			}},
+		},
+	}
+
+	findings := DeprecatedRules(inv, Options{})
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.Object)
+	}
+	want := []string{"ClusterRole/old-deployer", "Role/prod/psp"}
+	if len(got) != len(want) {
+		t.Fatalf("DeprecatedRules() objects = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("DeprecatedRules() objects = %v, want %v", got, want)
+		}
+	}
+
+	if n := len(DeprecatedRules(inv, Options{IncludeSystem: true})); n != 3 {
+		t.Errorf("with IncludeSystem, got %d findings, want 3", n)
+	}
+
+	// A cluster that still serves extensions/ingresses keeps the rule live
+	inv.Resources = discoveryLists{
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses"}}},
+	}
+	for _, f := range DeprecatedRules(inv, Options{}) {
+		if f.Object == "ClusterRole/old-deployer" {
+			t.Errorf("old-deployer reported although the cluster serves extensions/ingresses")
+		}
+	}
+}
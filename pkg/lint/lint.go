@@ -0,0 +1,143 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// Checks run by lint
+const (
+	CheckSubsetRole            = "subset-role"
+	CheckEmptySubjects         = "empty-subjects"
+	CheckMissingSubjectNS      = "missing-subject-namespace"
+	CheckMissingServiceAccount = "missing-serviceaccount"
+	CheckMissingNamespace      = "missing-namespace"
+	CheckDeprecatedRules       = "deprecated-rules"
+	CheckDanglingBinding       = "dangling-binding"
+)
+
+// Finding is a single problem reported by a lint check
+type Finding struct {
+	Check   string `json:"check"`
+	Object  string `json:"object"` // e.g. "ClusterRole/pod-reader" or "Role/prod/reader"
+	Message string `json:"message"`
+
+	// Superset is the role that covers Object (subset-role)
+	Superset string `json:"superset,omitempty"`
+	// Coverage lists the rules of Object and the rules of Superset covering them
+	Coverage []rbac.RuleCoverage `json:"coverage,omitempty"`
+	// RedundantBindings are bindings to Object whose subjects are all bound
+	// to Superset in the same or a wider scope already
+	RedundantBindings []string `json:"redundantBindings,omitempty"`
+
+	// Fix is a command that deletes or patches Object to resolve the finding
+	Fix string `json:"fix,omitempty"`
+}
+
+// Inventory is the set of RBAC objects lint checks run against
+type Inventory struct {
+	Roles               []rbacv1.Role
+	ClusterRoles        []rbacv1.ClusterRole
+	RoleBindings        []rbacv1.RoleBinding
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+
+	// ServiceAccounts and Namespaces are only loaded when HasSubjects is set
+	HasSubjects     bool
+	ServiceAccounts []corev1.ServiceAccount
+	Namespaces      []corev1.Namespace
+
+	// Resources is the discovery document, when the client provides one;
+	// otherwise deprecated-rules relies on its built-in table
+	Resources discoveryLists
+}
+
+// discoveryLists is the API server's discovery document
+type discoveryLists = []*metav1.APIResourceList
+
+// Load lists the RBAC objects in namespace, or in all namespaces when it is empty.
+// Cluster-scoped objects are always listed.
+func Load(ctx context.Context, c client.RBACClient, namespace string) (*Inventory, error) {
+	roles, err := c.ListRoles(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	clusterRoles, err := c.ListClusterRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster roles: %w", err)
+	}
+	rbs, err := c.ListRoleBindings(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	crbs, err := c.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	inv := &Inventory{
+		Roles:               roles.Items,
+		ClusterRoles:        clusterRoles.Items,
+		RoleBindings:        rbs.Items,
+		ClusterRoleBindings: crbs.Items,
+	}
+
+	// Subjects may live in any namespace, so these are always listed cluster-wide
+	if sc, ok := c.(client.SubjectClient); ok {
+		sas, err := sc.ListServiceAccounts(ctx, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list service accounts: %w", err)
+		}
+		namespaces, err := sc.ListNamespaces(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		inv.HasSubjects = true
+		inv.ServiceAccounts = sas.Items
+		inv.Namespaces = namespaces.Items
+	}
+
+	// Discovery only sharpens deprecated-rules, so failing to read it is not fatal
+	if dc, ok := c.(client.DiscoveryClient); ok {
+		if lists, err := dc.ServerResources(ctx); err == nil {
+			inv.Resources = lists
+		}
+	}
+	return inv, nil
+}
+
+// Options controls which objects the checks consider
+type Options struct {
+	// IncludeSystem also checks roles whose names start with "system:"
+	IncludeSystem bool
+}
+
+// Run executes every check against inv and returns the findings sorted by
+// check, then object
+func Run(inv *Inventory, opts Options) []Finding {
+	findings := SubsetRoles(inv, opts)
+	findings = append(findings, BindingSubjects(inv)...)
+	findings = append(findings, DeprecatedRules(inv, opts)...)
+	findings = append(findings, DanglingBindings(inv)...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Check != findings[j].Check {
+			return findings[i].Check < findings[j].Check
+		}
+		return findings[i].Object < findings[j].Object
+	})
+	return findings
+}
+
+// qualifiedName joins a namespace and name, omitting the namespace when empty
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,140 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// binding is a RoleBinding or ClusterRoleBinding reduced to what the subject
+// checks need
+type binding struct {
+	kind      string
+	name      string
+	namespace string
+	subjects  []rbacv1.Subject
+}
+
+func (b binding) String() string {
+	return b.kind + "/" + qualifiedName(b.namespace, b.name)
+}
+
+// kubectl returns the kubectl arguments naming the binding
+func (b binding) kubectl() string {
+	if b.namespace == "" {
+		return strings.ToLower(b.kind) + " " + b.name
+	}
+	return strings.ToLower(b.kind) + " " + b.name + " -n " + b.namespace
+}
+
+// BindingSubjects reports bindings with no subjects, ClusterRoleBinding
+// ServiceAccount subjects without a namespace, and ServiceAccount subjects
+// whose account or namespace no longer exists. The last two are only checked
+// when the inventory has service accounts and namespaces loaded.
+func BindingSubjects(inv *Inventory) []Finding {
+	var bindings []binding
+	for _, crb := range inv.ClusterRoleBindings {
+		bindings = append(bindings, binding{kind: "ClusterRoleBinding", name: crb.Name, subjects: crb.Subjects})
+	}
+	for _, rb := range inv.RoleBindings {
+		bindings = append(bindings, binding{kind: "RoleBinding", name: rb.Name, namespace: rb.Namespace, subjects: rb.Subjects})
+	}
+
+	namespaces := make(map[string]bool)
+	for _, ns := range inv.Namespaces {
+		namespaces[ns.Name] = true
+	}
+	serviceAccounts := make(map[string]bool)
+	for _, sa := range inv.ServiceAccounts {
+		serviceAccounts[sa.Namespace+"/"+sa.Name] = true
+	}
+
+	var findings []Finding
+	for _, b := range bindings {
+		if len(b.subjects) == 0 {
+			findings = append(findings, Finding{
+				Check:   CheckEmptySubjects,
+				Object:  b.String(),
+				Message: "binding has no subjects and grants nothing",
+				Fix:     "kubectl delete " + b.kubectl(),
+			})
+			continue
+		}
+
+		// Each dangling subject gets its own finding, but they share a fix
+		// so that removing several subjects doesn't shift indices under it
+		var dangling []int
+		var pending []Finding
+		for i, s := range b.subjects {
+			if s.Kind != rbacv1.ServiceAccountKind {
+				continue
+			}
+			ns := s.Namespace
+			if ns == "" {
+				if b.kind == "ClusterRoleBinding" {
+					findings = append(findings, Finding{
+						Check:   CheckMissingSubjectNS,
+						Object:  b.String(),
+						Message: fmt.Sprintf("ServiceAccount subject %q has no namespace, which ClusterRoleBindings require", s.Name),
+						Fix: fmt.Sprintf(`kubectl patch %s --type=json -p '[{"op":"add","path":"/subjects/%d/namespace","value":"<namespace>"}]'`,
+							b.kubectl(), i),
+					})
+					continue
+				}
+				// RoleBinding subjects default to the binding's namespace
+				ns = b.namespace
+			}
+			if !inv.HasSubjects {
+				continue
+			}
+
+			switch {
+			case !namespaces[ns]:
+				pending = append(pending, Finding{
+					Check:   CheckMissingNamespace,
+					Object:  b.String(),
+					Message: fmt.Sprintf("subject ServiceAccount %s/%s is in namespace %q, which does not exist", ns, s.Name, ns),
+				})
+			case !serviceAccounts[ns+"/"+s.Name]:
+				pending = append(pending, Finding{
+					Check:   CheckMissingServiceAccount,
+					Object:  b.String(),
+					Message: fmt.Sprintf("subject ServiceAccount %s/%s does not exist", ns, s.Name),
+				})
+			default:
+				continue
+			}
+			dangling = append(dangling, i)
+		}
+
+		fix := removeSubjectsFix(b, dangling)
+		for _, f := range pending {
+			f.Fix = fix
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// removeSubjectsFix returns a command that removes the subjects at the given
+// indices, or deletes the binding when no subjects would remain
+func removeSubjectsFix(b binding, indices []int) string {
+	if len(indices) == 0 {
+		return ""
+	}
+	if len(indices) == len(b.subjects) {
+		return "kubectl delete " + b.kubectl()
+	}
+
+	// Remove from the end so earlier indices stay valid, testing each name
+	// first so the patch fails rather than removing the wrong subject
+	var ops []string
+	for i := len(indices) - 1; i >= 0; i-- {
+		idx := indices[i]
+		ops = append(ops,
+			fmt.Sprintf(`{"op":"test","path":"/subjects/%d/name","value":%q}`, idx, b.subjects[idx].Name),
+			fmt.Sprintf(`{"op":"remove","path":"/subjects/%d"}`, idx))
+	}
+	return fmt.Sprintf("kubectl patch %s --type=json -p '[%s]'", b.kubectl(), strings.Join(ops, ","))
+}
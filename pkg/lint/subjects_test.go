@@ -0,0 +1,79 @@
+package lint
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBindingSubjects(t *testing.T) {
+	ref := rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}
+	inv := &Inventory{
+		HasSubjects:     true,
+		Namespaces:      []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}},
+		ServiceAccounts: []corev1.ServiceAccount{{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "prod"}}},
+		ClusterRoleBindings: []rbacv1.ClusterRoleBinding{
+			{ObjectMeta: metav1.ObjectMeta{Name: "empty"}, RoleRef: ref},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "no-ns"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "api"}},
+				RoleRef:    ref,
+			},
+		},
+		RoleBindings: []rbacv1.RoleBinding{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "mixed", Namespace: "prod"},
+				Subjects: []rbacv1.Subject{
+					{Kind: "ServiceAccount", Name: "api"},
+					{Kind: "ServiceAccount", Name: "deleted", Namespace: "prod"},
+					{Kind: "ServiceAccount", Name: "worker", Namespace: "gone"},
+					{Kind: "User", Name: "alice"},
+				},
+				RoleRef: ref,
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "all-gone", Namespace: "prod"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "deleted"}},
+				RoleRef:    ref,
+			},
+		},
+	}
+
+	mixedFix := `kubectl patch rolebinding mixed -n prod --type=json -p '[` +
+		`{"op":"test","path":"/subjects/2/name","value":"worker"},{"op":"remove","path":"/subjects/2"},` +
+		`{"op":"test","path":"/subjects/1/name","value":"deleted"},{"op":"remove","path":"/subjects/1"}]'`
+
+	tests := []struct {
+		check  string
+		object string
+		fix    string
+	}{
+		{CheckEmptySubjects, "ClusterRoleBinding/empty", "kubectl delete clusterrolebinding empty"},
+		{CheckMissingSubjectNS, "ClusterRoleBinding/no-ns",
+			`kubectl patch clusterrolebinding no-ns --type=json -p '[{"op":"add","path":"/subjects/0/namespace","value":"<namespace>"}]'`},
+		{CheckMissingServiceAccount, "RoleBinding/prod/mixed", mixedFix},
+		{CheckMissingNamespace, "RoleBinding/prod/mixed", mixedFix},
+		{CheckMissingServiceAccount, "RoleBinding/prod/all-gone", "kubectl delete rolebinding all-gone -n prod"},
+	}
+
+	findings := BindingSubjects(inv)
+	if len(findings) != len(tests) {
+		t.Fatalf("got %d findings, want %d: %+v", len(findings), len(tests), findings)
+	}
+	for _, tt := range tests {
+		found := false
+		for _, f := range findings {
+			if f.Check == tt.check && f.Object == tt.object {
+				found = true
+				if f.Fix != tt.fix {
+					t.Errorf("%s %s: fix = %s, want %s", tt.check, tt.object, f.Fix, tt.fix)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("missing %s finding for %s", tt.check, tt.object)
+		}
+	}
+}
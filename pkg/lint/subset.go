@@ -0,0 +1,187 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// roleRef is a Role or ClusterRole reduced to what the subset check needs
+type roleRef struct {
+	kind      string
+	name      string
+	namespace string
+	rules     []rbacv1.PolicyRule
+}
+
+func (r roleRef) String() string {
+	return r.kind + "/" + qualifiedName(r.namespace, r.name)
+}
+
+// SubsetRoles reports roles whose rules are entirely covered by another role,
+// along with the bindings to them that are made redundant by existing
+// bindings to the covering role. Only the smallest covering roles are
+// reported, so a role within view is not also reported against edit and admin.
+// Roles that grant everything (e.g. cluster-admin) are never used as supersets.
+func SubsetRoles(inv *Inventory, opts Options) []Finding {
+	var roles []roleRef
+	for _, cr := range inv.ClusterRoles {
+		roles = append(roles, roleRef{kind: "ClusterRole", name: cr.Name, rules: cr.Rules})
+	}
+	for _, r := range inv.Roles {
+		roles = append(roles, roleRef{kind: "Role", name: r.Name, namespace: r.Namespace, rules: r.Rules})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].String() < roles[j].String() })
+
+	superuser := []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}}
+
+	var findings []Finding
+	for _, a := range roles {
+		if len(rbac.NormalizeRules(a.rules)) == 0 || (!opts.IncludeSystem && isSystem(a.name)) {
+			continue
+		}
+
+		var supersets []roleRef
+		coverage := make(map[string][]rbac.RuleCoverage)
+		for _, b := range roles {
+			if b.String() == a.String() || !canReplace(a, b) || (!opts.IncludeSystem && isSystem(b.name)) {
+				continue
+			}
+			if _, all := rbac.Subsumes(b.rules, superuser); all {
+				continue
+			}
+			if c, ok := rbac.Subsumes(b.rules, a.rules); ok {
+				supersets = append(supersets, b)
+				coverage[b.String()] = c
+			}
+		}
+
+		for _, b := range minimal(supersets) {
+			_, equivalent := rbac.Subsumes(a.rules, b.rules)
+			msg := fmt.Sprintf("every rule is covered by %s", b)
+			if equivalent {
+				// Report an equivalent pair once
+				if a.String() < b.String() {
+					continue
+				}
+				msg = fmt.Sprintf("grants the same permissions as %s", b)
+			}
+			findings = append(findings, Finding{
+				Check:             CheckSubsetRole,
+				Object:            a.String(),
+				Message:           msg,
+				Superset:          b.String(),
+				Coverage:          coverage[b.String()],
+				RedundantBindings: redundantBindings(inv, a, b),
+			})
+		}
+	}
+	return findings
+}
+
+// canReplace reports whether b can stand in for a wherever a is bound: a
+// ClusterRole may be bound cluster-wide, so only another ClusterRole can
+// replace it, while a Role can be replaced by a ClusterRole or a Role in the
+// same namespace
+func canReplace(a, b roleRef) bool {
+	if a.kind == "ClusterRole" {
+		return b.kind == "ClusterRole"
+	}
+	return b.kind == "ClusterRole" || b.namespace == a.namespace
+}
+
+// minimal drops supersets that strictly contain another superset
+func minimal(supersets []roleRef) []roleRef {
+	var result []roleRef
+	for _, b := range supersets {
+		smallest := true
+		for _, c := range supersets {
+			if c.String() == b.String() {
+				continue
+			}
+			_, cInB := rbac.Subsumes(b.rules, c.rules)
+			_, bInC := rbac.Subsumes(c.rules, b.rules)
+			if cInB && !bInC {
+				smallest = false
+				break
+			}
+		}
+		if smallest {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// redundantBindings returns the bindings to a whose subjects are all bound to
+// b already, in a scope at least as wide
+func redundantBindings(inv *Inventory, a, b roleRef) []string {
+	// Subjects holding b cluster-wide, and per namespace
+	clusterWide := make(map[rbacv1.Subject]bool)
+	perNamespace := make(map[string]map[rbacv1.Subject]bool)
+	for _, crb := range inv.ClusterRoleBindings {
+		if refersTo(crb.RoleRef, "", b) {
+			for _, s := range crb.Subjects {
+				clusterWide[subjectKey(s)] = true
+			}
+		}
+	}
+	for _, rb := range inv.RoleBindings {
+		if refersTo(rb.RoleRef, rb.Namespace, b) {
+			if perNamespace[rb.Namespace] == nil {
+				perNamespace[rb.Namespace] = make(map[rbacv1.Subject]bool)
+			}
+			for _, s := range rb.Subjects {
+				perNamespace[rb.Namespace][subjectKey(s)] = true
+			}
+		}
+	}
+
+	covered := func(subjects []rbacv1.Subject, namespace string) bool {
+		if len(subjects) == 0 {
+			return false
+		}
+		for _, s := range subjects {
+			k := subjectKey(s)
+			if !clusterWide[k] && (namespace == "" || !perNamespace[namespace][k]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var redundant []string
+	for _, crb := range inv.ClusterRoleBindings {
+		if refersTo(crb.RoleRef, "", a) && covered(crb.Subjects, "") {
+			redundant = append(redundant, "ClusterRoleBinding/"+crb.Name)
+		}
+	}
+	for _, rb := range inv.RoleBindings {
+		if refersTo(rb.RoleRef, rb.Namespace, a) && covered(rb.Subjects, rb.Namespace) {
+			redundant = append(redundant, "RoleBinding/"+rb.Namespace+"/"+rb.Name)
+		}
+	}
+	sort.Strings(redundant)
+	return redundant
+}
+
+// refersTo reports whether a roleRef in a binding in namespace points at r
+func refersTo(ref rbacv1.RoleRef, namespace string, r roleRef) bool {
+	if ref.Kind != r.kind || ref.Name != r.name {
+		return false
+	}
+	return r.kind == "ClusterRole" || namespace == r.namespace
+}
+
+// subjectKey drops the APIGroup so equivalent subjects compare equal
+func subjectKey(s rbacv1.Subject) rbacv1.Subject {
+	return rbacv1.Subject{Kind: s.Kind, Name: s.Name, Namespace: s.Namespace}
+}
+
+func isSystem(name string) bool {
+	return strings.HasPrefix(name, "system:")
+}
@@ -0,0 +1,76 @@
+package lint
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSubsetRoles(t *testing.T) {
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	inv := &Inventory{
+		ClusterRoles: []rbacv1.ClusterRole{
+			{ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"}, Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+			}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "view"}, Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{""}, Resources: []string{"pods", "services"}},
+			}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "edit"}, Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods", "services"}},
+			}},
+		},
+		Roles: []rbacv1.Role{
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-reader", Namespace: "dev"}, Rules: []rbacv1.PolicyRule{readPods}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "pod-deleter", Namespace: "dev"}, Rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"delete"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			}},
+		},
+		RoleBindings: []rbacv1.RoleBinding{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "alice-pods", Namespace: "dev"},
+				Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "bob-pods", Namespace: "dev"},
+				Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "alice-view", Namespace: "dev"},
+				Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+			},
+		},
+	}
+
+	findings := Run(inv, Options{})
+
+	got := make(map[string]Finding)
+	for _, f := range findings {
+		got[f.Object+" <= "+f.Superset] = f
+	}
+	want := []string{
+		"ClusterRole/view <= ClusterRole/edit",
+		"Role/dev/pod-deleter <= ClusterRole/edit",
+		"Role/dev/pod-reader <= ClusterRole/view",
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d: %v", len(findings), len(want), got)
+	}
+	for _, k := range want {
+		if _, ok := got[k]; !ok {
+			t.Errorf("missing finding %q", k)
+		}
+	}
+
+	reader := got["Role/dev/pod-reader <= ClusterRole/view"]
+	if len(reader.RedundantBindings) != 1 || reader.RedundantBindings[0] != "RoleBinding/dev/alice-pods" {
+		t.Errorf("RedundantBindings = %v, want [RoleBinding/dev/alice-pods]", reader.RedundantBindings)
+	}
+	if len(reader.Coverage) != 1 || len(reader.Coverage[0].CoveredBy) != 1 {
+		t.Errorf("Coverage = %+v, want the pod rule covered by one view rule", reader.Coverage)
+	}
+}
@@ -0,0 +1,264 @@
+// Package manifest loads RBAC objects from local YAML files, so permissions
+// can be resolved without a cluster.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// extensions are the file types read from a directory
+var extensions = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+
+// DeleteMarker is a comment line that marks a document as an object to
+// remove rather than apply, for LoadChanges
+const DeleteMarker = "# delete"
+
+// loader accumulates the objects read from every file
+type loader struct {
+	snapshot         *client.Snapshot
+	warnings         []string
+	defaultNamespace string
+
+	// deletions, when set, collects the documents marked with DeleteMarker
+	deletions *client.Snapshot
+	// current is where the document being read is added
+	current *client.Snapshot
+	// line is the line of its file the document being read starts on
+	line int
+}
+
+// Changes are the objects a set of manifests applies and deletes
+type Changes struct {
+	Apply  *client.Snapshot
+	Delete *client.Snapshot
+}
+
+// Load reads the Roles, ClusterRoles, RoleBindings, and ClusterRoleBindings
+// in paths. A directory is walked recursively for .yaml, .yml, and .json
+// files. A file may hold several YAML documents, and a document may be a List.
+// Namespaced objects without a namespace are placed in defaultNamespace. Each
+// object is annotated with the file and document it came from. Documents of
+// other kinds are skipped and reported in the returned warnings.
+func Load(paths []string, defaultNamespace string) (*client.Snapshot, []string, error) {
+	l := &loader{snapshot: &client.Snapshot{}, defaultNamespace: defaultNamespace}
+	if err := l.load(paths); err != nil {
+		return nil, nil, err
+	}
+	return l.snapshot, l.warnings, nil
+}
+
+// LoadChanges reads paths like Load, but puts the documents that carry a
+// "# delete" comment line in Delete. With deleteAll, every object is a deletion.
+func LoadChanges(paths []string, defaultNamespace string, deleteAll bool) (*Changes, []string, error) {
+	apply, remove := &client.Snapshot{}, &client.Snapshot{}
+	l := &loader{snapshot: apply, deletions: remove, defaultNamespace: defaultNamespace}
+	if deleteAll {
+		l.snapshot = remove
+	}
+	if err := l.load(paths); err != nil {
+		return nil, nil, err
+	}
+	return &Changes{Apply: apply, Delete: remove}, l.warnings, nil
+}
+
+func (l *loader) load(paths []string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			if err := l.loadFile(path); err != nil {
+				return err
+			}
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !extensions[strings.ToLower(filepath.Ext(p))] {
+				return nil
+			}
+			return l.loadFile(p)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *loader) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	lines := &lineCounter{lines: bytes.Split(content, []byte("\n"))}
+	for doc := 1; ; doc++ {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: failed to read document %d: %w", path, doc, err)
+		}
+		l.line = lines.start(data)
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		l.current = l.snapshot
+		if l.deletions != nil && hasDeleteMarker(data) {
+			l.current = l.deletions
+		}
+		if err := l.loadDocument(data, path, doc); err != nil {
+			return fmt.Errorf("%s#doc%d: %w", path, doc, err)
+		}
+	}
+}
+
+// loadDocument adds the object in data, or each item of a List
+func (l *loader) loadDocument(data []byte, path string, doc int) error {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	if meta.Kind == "" {
+		// Comment-only documents decode to nothing
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err == nil && v == nil {
+			return nil
+		}
+		l.warnf("%s#doc%d: skipping document without a kind", path, doc)
+		return nil
+	}
+
+	if strings.HasSuffix(meta.Kind, "List") {
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := yaml.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("invalid %s: %w", meta.Kind, err)
+		}
+		// Typed lists such as RoleList leave out the items' kind
+		itemKind := strings.TrimSuffix(meta.Kind, "List")
+		for i, item := range list.Items {
+			if err := l.loadObject(item, itemKind, meta.APIVersion, path, doc); err != nil {
+				return fmt.Errorf("items[%d]: %w", i, err)
+			}
+		}
+		return nil
+	}
+	return l.loadObject(data, "", "", path, doc)
+}
+
+// loadObject decodes one object, falling back to the kind and apiVersion of
+// its typed List when it doesn't set them itself
+func (l *loader) loadObject(data []byte, kind, apiVersion, path string, doc int) error {
+	var meta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("invalid manifest: %w", err)
+	}
+	if meta.Kind == "" {
+		meta.Kind, meta.APIVersion = kind, apiVersion
+	}
+	gv, err := schema.ParseGroupVersion(meta.APIVersion)
+	if err != nil || gv.Group != rbacv1.GroupName {
+		l.warnf("%s#doc%d: skipping %s %s, not an RBAC object", path, doc, meta.APIVersion, meta.Kind)
+		return nil
+	}
+
+	switch meta.Kind {
+	case "Role":
+		var role rbacv1.Role
+		if err := yaml.Unmarshal(data, &role); err != nil {
+			return fmt.Errorf("invalid Role: %w", err)
+		}
+		l.prepare(&role.ObjectMeta, path, doc, true)
+		l.current.Roles = append(l.current.Roles, role)
+	case "ClusterRole":
+		var role rbacv1.ClusterRole
+		if err := yaml.Unmarshal(data, &role); err != nil {
+			return fmt.Errorf("invalid ClusterRole: %w", err)
+		}
+		l.prepare(&role.ObjectMeta, path, doc, false)
+		l.current.ClusterRoles = append(l.current.ClusterRoles, role)
+	case "RoleBinding":
+		var rb rbacv1.RoleBinding
+		if err := yaml.Unmarshal(data, &rb); err != nil {
+			return fmt.Errorf("invalid RoleBinding: %w", err)
+		}
+		l.prepare(&rb.ObjectMeta, path, doc, true)
+		l.current.RoleBindings = append(l.current.RoleBindings, rb)
+	case "ClusterRoleBinding":
+		var crb rbacv1.ClusterRoleBinding
+		if err := yaml.Unmarshal(data, &crb); err != nil {
+			return fmt.Errorf("invalid ClusterRoleBinding: %w", err)
+		}
+		l.prepare(&crb.ObjectMeta, path, doc, false)
+		l.current.ClusterRoleBindings = append(l.current.ClusterRoleBindings, crb)
+	default:
+		l.warnf("%s#doc%d: skipping unsupported kind %s", path, doc, meta.Kind)
+	}
+	return nil
+}
+
+// prepare defaults the namespace of a namespaced object and records its source
+func (l *loader) prepare(meta *metav1.ObjectMeta, path string, doc int, namespaced bool) {
+	if namespaced && meta.Namespace == "" {
+		meta.Namespace = l.defaultNamespace
+	}
+	rbac.SetSource(meta, path, doc, l.line)
+}
+
+// lineCounter follows the documents a YAMLReader returns through the lines of
+// their file, to tell which line each starts on
+type lineCounter struct {
+	lines [][]byte
+	next  int
+}
+
+// start returns the 1-based line the document data starts on. The reader
+// leaves out the "---" separators before it, so they are skipped.
+func (c *lineCounter) start(data []byte) int {
+	for c.next < len(c.lines) && bytes.HasPrefix(c.lines[c.next], []byte("---")) {
+		c.next++
+	}
+	line := c.next + 1
+	c.next += bytes.Count(data, []byte("\n"))
+	return line
+}
+
+// hasDeleteMarker reports whether a line of the document is DeleteMarker
+func hasDeleteMarker(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), DeleteMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *loader) warnf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
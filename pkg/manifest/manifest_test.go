@@ -0,0 +1,160 @@
+package manifest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+const roleAndBinding = `# Pod reader for the CI service account
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: pod-reader
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: ci-read-pods
+  namespace: prod
+subjects:
+- kind: ServiceAccount
+  name: ci
+  namespace: prod
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: pod-reader
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+---
+# comment-only document
+`
+
+const list = `apiVersion: v1
+kind: List
+items:
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: node-reader
+  rules:
+  - apiGroups: [""]
+    resources: ["nodes"]
+    verbs: ["get"]
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRoleBinding
+  metadata:
+    name: ci-read-nodes
+  subjects:
+  - kind: ServiceAccount
+    name: ci
+    namespace: prod
+  roleRef:
+    apiGroup: rbac.authorization.k8s.io
+    kind: ClusterRole
+    name: node-reader
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	rolesFile := writeFile(t, dir, "roles.yaml", roleAndBinding)
+	writeFile(t, dir, "cluster/list.yml", list)
+	writeFile(t, dir, "README.md", "not a manifest")
+
+	snapshot, warnings, err := Load([]string{dir}, "prod")
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(snapshot.Roles) != 1 || len(snapshot.RoleBindings) != 1 || len(snapshot.ClusterRoles) != 1 || len(snapshot.ClusterRoleBindings) != 1 {
+		t.Fatalf("snapshot = %+v, want one object of each kind", snapshot)
+	}
+	if ns := snapshot.Roles[0].Namespace; ns != "prod" {
+		t.Errorf("Role namespace = %q, want the default namespace prod", ns)
+	}
+	if got := rbac.SourceFromMeta(snapshot.RoleBindings[0].ObjectMeta); got == nil || got.File != rolesFile || got.Document != 2 || got.Line != 11 {
+		t.Errorf("RoleBinding source = %+v, want %s#doc2 on line 11", got, rolesFile)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "v1 ConfigMap") {
+		t.Errorf("warnings = %q, want one for the ConfigMap", warnings)
+	}
+
+	// The loaded objects resolve like a cluster's
+	subject := rbac.Subject{Kind: "ServiceAccount", Name: "ci", Namespace: "prod"}
+	result, err := rbac.NewResolver(snapshot).ResolvePermission(context.Background(), subject,
+		rbac.PermissionRequest{Verb: "list", Resource: "pods", Namespace: "prod"})
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+	if !result.Allowed || result.Grants[0].RuleSource() != rolesFile+"#doc1 rules[0]" {
+		t.Errorf("result = %+v, want allowed by the Role in %s#doc1", result, rolesFile)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, _, err := Load([]string{filepath.Join(dir, "missing.yaml")}, "default"); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+	bad := writeFile(t, dir, "bad.yaml", "apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nrules: {}\n")
+	if _, _, err := Load([]string{bad}, "default"); err == nil || !strings.Contains(err.Error(), "bad.yaml#doc1") {
+		t.Errorf("Load() error = %v, want one locating bad.yaml#doc1", err)
+	}
+}
+
+func TestLoadChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "changes.yaml", roleAndBinding+`---
+# delete
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: legacy-admins
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+`)
+
+	changes, _, err := LoadChanges([]string{path}, "prod", false)
+	if err != nil {
+		t.Fatalf("LoadChanges() error: %v", err)
+	}
+	if len(changes.Apply.Roles) != 1 || len(changes.Apply.RoleBindings) != 1 || len(changes.Apply.ClusterRoleBindings) != 0 {
+		t.Errorf("Apply = %+v, want the Role and RoleBinding", changes.Apply)
+	}
+	if len(changes.Delete.ClusterRoleBindings) != 1 || changes.Delete.ClusterRoleBindings[0].Name != "legacy-admins" {
+		t.Errorf("Delete = %+v, want the marked ClusterRoleBinding", changes.Delete)
+	}
+
+	changes, _, err = LoadChanges([]string{path}, "prod", true)
+	if err != nil {
+		t.Fatalf("LoadChanges() error: %v", err)
+	}
+	if len(changes.Apply.Roles) != 0 || len(changes.Delete.Roles) != 1 || len(changes.Delete.ClusterRoleBindings) != 1 {
+		t.Errorf("changes = %+v, want every object deleted", changes)
+	}
+}
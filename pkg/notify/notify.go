@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// Notifier types accepted in the configuration file
+const (
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+)
+
+// Finding is a single risky permission reported for a subject
+type Finding struct {
+	Subject     string `json:"subject"`
+	SubjectKind string `json:"subjectKind,omitempty"` // User, Group, or ServiceAccount
+	Category    string `json:"category"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Binding     string `json:"binding"`
+	Role        string `json:"role"`
+	Owner       string `json:"owner,omitempty"`    // Release or application managing the grant
+	Exposure    string `json:"exposure,omitempty"` // Why the subject's token is easy to steal
+}
+
+// Config configures a single notifier
+type Config struct {
+	Type        string `yaml:"type"`                  // webhook or slack
+	URL         string `yaml:"url"`                   // Endpoint to POST to
+	MinSeverity string `yaml:"minSeverity,omitempty"` // Only send findings at or above this severity
+	Mention     string `yaml:"mention,omitempty"`     // Slack only, e.g. "@here" or "<@U123>"
+}
+
+// Validate checks that the notifier configuration is usable
+func (c Config) Validate() error {
+	if c.Type != TypeWebhook && c.Type != TypeSlack {
+		return fmt.Errorf("invalid notifier type %q (valid: webhook, slack)", c.Type)
+	}
+	if c.URL == "" {
+		return fmt.Errorf("%s notifier: url is required", c.Type)
+	}
+	if c.MinSeverity != "" && rbac.SeverityRank(c.MinSeverity) == 0 {
+		return fmt.Errorf("%s notifier: invalid minSeverity %q", c.Type, c.MinSeverity)
+	}
+	if c.Mention != "" && c.Type != TypeSlack {
+		return fmt.Errorf("%s notifier: mention is only supported for slack", c.Type)
+	}
+	return nil
+}
+
+// Notifier delivers new findings somewhere
+type Notifier interface {
+	Notify(ctx context.Context, findings []Finding) error
+}
+
+// Retry controls how failed deliveries are retried
+type Retry struct {
+	Attempts  int
+	BaseDelay time.Duration
+}
+
+// DefaultRetry retries a delivery three times with exponential backoff
+var DefaultRetry = Retry{Attempts: 3, BaseDelay: time.Second}
+
+// New builds a notifier from its configuration. With dryRun, the payload that
+// would be sent is written to w instead of being delivered.
+func New(cfg Config, dryRun bool, w io.Writer) (Notifier, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var payload func([]Finding) ([]byte, error)
+	switch cfg.Type {
+	case TypeSlack:
+		payload = func(findings []Finding) ([]byte, error) { return slackPayload(findings, cfg.Mention) }
+	default:
+		payload = webhookPayload
+	}
+
+	return &httpNotifier{
+		url:         cfg.URL,
+		minSeverity: cfg.MinSeverity,
+		payload:     payload,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		retry:       DefaultRetry,
+		dryRun:      dryRun,
+		out:         w,
+	}, nil
+}
+
+// httpNotifier POSTs a JSON payload of findings to a URL
+type httpNotifier struct {
+	url         string
+	minSeverity string
+	payload     func([]Finding) ([]byte, error)
+	client      *http.Client
+	retry       Retry
+	dryRun      bool
+	out         io.Writer
+}
+
+func (n *httpNotifier) Notify(ctx context.Context, findings []Finding) error {
+	findings = FilterBySeverity(findings, n.minSeverity)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	body, err := n.payload(findings)
+	if err != nil {
+		return fmt.Errorf("failed to build notification payload: %w", err)
+	}
+
+	if n.dryRun {
+		_, _ = fmt.Fprintf(n.out, "[dry-run] POST %s\n%s\n", redactURL(n.url), body)
+		return nil
+	}
+
+	return withRetry(ctx, n.retry, func() error {
+		return n.post(ctx, body)
+	})
+}
+
+func (n *httpNotifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// The client's error repeats the full URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("notification to %s failed: %w", redactURL(n.url), err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("notification to %s failed: %s", redactURL(n.url), resp.Status)
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// A rejected request, or a missing webhook, fails the same way again
+			return permanentError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// permanentError is a failure that retrying won't fix
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// redactURL keeps only the scheme and host of a webhook URL, since the path
+// of a Slack incoming webhook is its secret
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "(redacted URL)"
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
+
+// withRetry calls fn until it succeeds, doubling the delay between attempts.
+// A permanentError is returned at once.
+func withRetry(ctx context.Context, retry Retry, fn func() error) error {
+	attempts := retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	delay := retry.BaseDelay
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.error
+		}
+		if i == attempts-1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return fmt.Errorf("giving up after %d attempt(s): %w", attempts, err)
+}
+
+// FilterBySeverity returns the findings at or above minSeverity
+func FilterBySeverity(findings []Finding, minSeverity string) []Finding {
+	if minSeverity == "" {
+		return findings
+	}
+	threshold := rbac.SeverityRank(minSeverity)
+	var filtered []Finding
+	for _, f := range findings {
+		if rbac.SeverityRank(f.Severity) >= threshold {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// webhookPayload is the generic JSON body: the list of new findings
+func webhookPayload(findings []Finding) ([]byte, error) {
+	return json.Marshal(struct {
+		Count    int       `json:"count"`
+		Findings []Finding `json:"findings"`
+	}{
+		Count:    len(findings),
+		Findings: findings,
+	})
+}
+
+// slackPayload formats findings for a Slack incoming webhook
+func slackPayload(findings []Finding, mention string) ([]byte, error) {
+	var b strings.Builder
+	if mention != "" {
+		b.WriteString(mention + " ")
+	}
+	_, _ = fmt.Fprintf(&b, "*rbac-why: %d new risky permission finding(s)*\n", len(findings))
+	for _, f := range findings {
+		_, _ = fmt.Fprintf(&b, "• *%s* `%s` for %s via %s -> %s",
+			strings.ToUpper(f.Severity), slackEscape(f.Category), slackEscape(f.Subject), slackEscape(f.Binding), slackEscape(f.Role))
+		if f.Owner != "" {
+			_, _ = fmt.Fprintf(&b, " (owned by %s)", slackEscape(f.Owner))
+		}
+		b.WriteString("\n")
+	}
+	return json.Marshal(map[string]string{"text": b.String()})
+}
+
+// slackEscaper escapes the characters Slack's mrkdwn reads as markup, so a
+// name like "<!channel>" can't mention anyone or form a link
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testFindings = []Finding{
+	{Subject: "system:serviceaccount:default:ci", Category: "secrets-access", Severity: "critical", Binding: "ClusterRoleBinding/ci", Role: "ClusterRole/admin"},
+	{Subject: "system:serviceaccount:default:ci", Category: "pod-exec", Severity: "medium", Binding: "RoleBinding/default/ci", Role: "Role/default/exec"},
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "webhook", cfg: Config{Type: TypeWebhook, URL: "http://example"}},
+		{name: "slack with mention", cfg: Config{Type: TypeSlack, URL: "http://example", Mention: "@here", MinSeverity: "high"}},
+		{name: "unknown type", cfg: Config{Type: "email", URL: "http://example"}, wantErr: true},
+		{name: "missing url", cfg: Config{Type: TypeWebhook}, wantErr: true},
+		{name: "bad severity", cfg: Config{Type: TypeWebhook, URL: "http://example", MinSeverity: "extreme"}, wantErr: true},
+		{name: "mention on webhook", cfg: Config{Type: TypeWebhook, URL: "http://example", Mention: "@here"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotify_WebhookFiltersAndRetries(t *testing.T) {
+	var calls int32
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: TypeWebhook, URL: srv.URL, MinSeverity: "high"}, false, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	n.(*httpNotifier).retry = Retry{Attempts: 3, BaseDelay: time.Millisecond}
+
+	if err := n.Notify(context.Background(), testFindings); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 attempts, got %d", calls)
+	}
+
+	var payload struct {
+		Count    int       `json:"count"`
+		Findings []Finding `json:"findings"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.Count != 1 || payload.Findings[0].Category != "secrets-access" {
+		t.Errorf("expected only the critical finding, got %+v", payload)
+	}
+}
+
+func TestNotify_GivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	n, _ := New(Config{Type: TypeWebhook, URL: srv.URL}, false, nil)
+	n.(*httpNotifier).retry = Retry{Attempts: 2, BaseDelay: time.Millisecond}
+
+	if err := n.Notify(context.Background(), testFindings); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+}
+
+func TestNotify_RetriesOnlyTransientFailures(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantCalls int32
+	}{
+		{status: http.StatusBadRequest, wantCalls: 1},
+		{status: http.StatusNotFound, wantCalls: 1},
+		{status: http.StatusTooManyRequests, wantCalls: 3},
+		{status: http.StatusBadGateway, wantCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			n, _ := New(Config{Type: TypeWebhook, URL: srv.URL}, false, nil)
+			n.(*httpNotifier).retry = Retry{Attempts: 3, BaseDelay: time.Millisecond}
+
+			if err := n.Notify(context.Background(), testFindings); err == nil {
+				t.Fatal("Notify() succeeded, want an error")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("attempts = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSlackPayload_Escapes(t *testing.T) {
+	findings := []Finding{{Subject: "<!channel>", Category: "pod-exec", Severity: "high", Binding: "RoleBinding/a&b", Role: "Role/<http://evil|x>"}}
+	body, err := slackPayload(findings, "<!here>")
+	if err != nil {
+		t.Fatalf("slackPayload() error = %v", err)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	text := payload["text"]
+	for _, want := range []string{"<!here> ", "for &lt;!channel&gt; via RoleBinding/a&amp;b -> Role/&lt;http://evil|x&gt;"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text = %q, want it to contain %q", text, want)
+		}
+	}
+}
+
+func TestNotify_SlackDryRun(t *testing.T) {
+	var out bytes.Buffer
+	n, err := New(Config{Type: TypeSlack, URL: "https://hooks.slack.invalid/services/T000/B000/XXXX", Mention: "@here"}, true, &out)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := n.Notify(context.Background(), testFindings); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"[dry-run] POST https://hooks.slack.invalid/…\n", `"text"`, "@here", "2 new risky permission"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dry-run output missing %q:\n%s", want, got)
+		}
+	}
+	// The webhook path is a credential
+	if strings.Contains(got, "/services/") || strings.Contains(got, "XXXX") {
+		t.Errorf("dry-run output contains the webhook path:\n%s", got)
+	}
+}
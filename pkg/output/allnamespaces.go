@@ -0,0 +1,170 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// NamespaceResultOutput is the outcome of a check in one namespace
+type NamespaceResultOutput struct {
+	Allowed bool `json:"allowed"`
+	// PartiallyAllowed is set, with allowed false, when the request is
+	// allowed only for the objects in AllowedNames
+	PartiallyAllowed bool          `json:"partiallyAllowed,omitempty"`
+	AllowedNames     []string      `json:"allowedNames,omitempty"`
+	Grants           []GrantOutput `json:"grants,omitempty"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// AllNamespacesOutput is the JSON/YAML structure for a check evaluated in
+// every namespace
+type AllNamespacesOutput struct {
+	Subject     SubjectOutput                    `json:"subject"`
+	Request     RequestOutput                    `json:"request"`
+	Selector    string                           `json:"namespaceSelector,omitempty"`
+	BypassedVia string                           `json:"bypassedVia,omitempty"`
+	ClusterWide []GrantOutput                    `json:"clusterWide"`
+	Namespaces  map[string]NamespaceResultOutput `json:"namespaces"`
+	Diagnostics []DiagnosticOutput               `json:"diagnostics,omitempty"`
+	// Deprecated: use Diagnostics
+	Errors []string `json:"errors,omitempty"`
+
+	DanglingBindings []DanglingBindingOutput `json:"danglingBindings,omitempty"`
+}
+
+// BuildAllNamespacesOutput converts an all-namespaces check into its JSON structure
+func BuildAllNamespacesOutput(result *rbac.AllNamespacesResult) AllNamespacesOutput {
+	out := AllNamespacesOutput{
+		Subject: SubjectOutput{
+			Kind:      result.Subject.Kind,
+			Name:      result.Subject.Name,
+			Namespace: result.Subject.Namespace,
+			Origin:    result.Subject.Origin,
+		},
+		Request:     buildRequestOutput(result.Request),
+		Selector:    result.Selector,
+		BypassedVia: result.BypassedVia,
+		ClusterWide: []GrantOutput{},
+		Namespaces:  make(map[string]NamespaceResultOutput, len(result.Namespaces)),
+	}
+	for _, g := range result.ClusterWide {
+		grant := buildGrantOutput(g)
+		grant.NameMatch = g.NameMatch(result.Request)
+		out.ClusterWide = append(out.ClusterWide, grant)
+	}
+	for _, ns := range result.Namespaces {
+		nsOut := NamespaceResultOutput{Allowed: ns.Allowed, PartiallyAllowed: ns.PartiallyAllowed}
+		if ns.PartiallyAllowed {
+			nsOut.AllowedNames = result.AllowedNames(ns)
+		}
+		for _, g := range ns.Grants {
+			grant := buildGrantOutput(g)
+			grant.NameMatch = g.NameMatch(result.Request)
+			nsOut.Grants = append(nsOut.Grants, grant)
+		}
+		if ns.Err != nil {
+			nsOut.Error = ns.Err.Error()
+		}
+		out.Namespaces[ns.Namespace] = nsOut
+	}
+	out.Diagnostics, out.Errors = buildDiagnosticsOutput(result.Diagnostics)
+	out.DanglingBindings = buildDanglingBindingsOutput(result.DanglingBindings)
+	return out
+}
+
+// PrintAllNamespaces outputs the cluster-wide grants once, then a table with
+// one row per namespace
+func PrintAllNamespaces(w io.Writer, result *rbac.AllNamespacesResult) {
+	request := result.Request
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "ALLOWED: %s can %s %s in every namespace\n", result.Subject, request.Verb, formatResource(request))
+		_, _ = fmt.Fprintf(w, "Authorization bypassed via %s; RBAC is not consulted.\n", result.BypassedVia)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Checking whether %s can %s %s in %d namespace(s)", result.Subject, request.Verb, formatResource(request), len(result.Namespaces))
+	if result.Selector != "" {
+		_, _ = fmt.Fprintf(w, " matching %s", result.Selector)
+	}
+	_, _ = fmt.Fprintf(w, "\n\n")
+
+	if len(result.ClusterWide) == 0 {
+		_, _ = fmt.Fprintf(w, "Cluster-wide: no ClusterRoleBinding grants it\n")
+	} else {
+		allowed := "allowed"
+		if !result.ClusterWideAllowed() {
+			allowed += " only for " + strings.Join(result.AllowedNames(rbac.NamespaceResult{}), ", ")
+		}
+		_, _ = fmt.Fprintf(w, "Cluster-wide: %s in every namespace through %d path(s):\n", allowed, len(result.ClusterWide))
+		for _, g := range result.ClusterWide {
+			_, _ = fmt.Fprintf(w, "  %s -> %s: %s\n", formatTraceBinding(g.Binding), formatRole(g.Role), FormatRule(g.MatchingRule))
+		}
+	}
+
+	if len(result.Namespaces) == 0 {
+		return
+	}
+	width := len("NAMESPACE")
+	for _, ns := range result.Namespaces {
+		width = max(width, len(ns.Namespace))
+	}
+	_, _ = fmt.Fprintf(w, "\n%-*s  %-7s  %s\n", width, "NAMESPACE", "RESULT", "GRANTED BY")
+	for _, ns := range result.Namespaces {
+		verdict, via := "DENIED", ""
+		switch {
+		case ns.Allowed:
+			verdict, via = "ALLOWED", "(cluster-wide)"
+		case ns.PartiallyAllowed:
+			verdict, via = "PARTIAL", "(cluster-wide)"
+		}
+		if len(ns.Grants) > 0 {
+			g := ns.Grants[0]
+			via = formatTraceBinding(g.Binding) + " -> " + formatRole(g.Role)
+			if more := len(ns.Grants) - 1; more > 0 {
+				via += fmt.Sprintf(" (+%d more)", more)
+			}
+		}
+		if ns.PartiallyAllowed {
+			via += " only for " + strings.Join(result.AllowedNames(ns), ", ")
+		}
+		if ns.Err != nil {
+			verdict, via = "ERROR", ns.Err.Error()
+		}
+		_, _ = fmt.Fprintf(w, "%-*s  %-7s  %s\n", width, ns.Namespace, verdict, via)
+	}
+
+	// Namespaces that couldn't be read already show as ERROR rows
+	var errs []rbac.Diagnostic
+	for _, d := range rbac.ErrorDiagnostics(result.Diagnostics) {
+		if d.Code != rbac.DiagnosticNamespaceListForbidden && d.Code != rbac.DiagnosticNamespaceListFailed {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		_, _ = fmt.Fprintln(w)
+	}
+	for _, d := range errs {
+		printDiagnostic(w, "Warning: ", d)
+	}
+	PrintDanglingBindings(w, result.DanglingBindings)
+}
+
+// PrintAllNamespacesJSON outputs an all-namespaces check as JSON
+func PrintAllNamespacesJSON(w io.Writer, result *rbac.AllNamespacesResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildAllNamespacesOutput(result))
+}
+
+// PrintAllNamespacesYAML outputs an all-namespaces check as YAML
+func PrintAllNamespacesYAML(w io.Writer, result *rbac.AllNamespacesResult) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(BuildAllNamespacesOutput(result))
+}
@@ -0,0 +1,211 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// namespaceVerdict is the outcome of a check in one namespace as shown in
+// the graphs
+func namespaceVerdict(ns rbac.NamespaceResult) string {
+	switch {
+	case ns.Err != nil:
+		return "ERROR"
+	case ns.Allowed:
+		return "ALLOWED"
+	case ns.PartiallyAllowed:
+		return "PARTIAL"
+	default:
+		return "DENIED"
+	}
+}
+
+// namespaceResultLabel describes a namespace with no grants of its own
+func namespaceResultLabel(ns rbac.NamespaceResult) string {
+	switch {
+	case ns.Err != nil:
+		return "ERROR: " + ns.Err.Error()
+	case ns.Allowed:
+		return "ALLOWED (cluster-wide)"
+	case ns.PartiallyAllowed:
+		return "PARTIAL (cluster-wide, named objects only)"
+	default:
+		return "DENIED"
+	}
+}
+
+// PrintAllNamespacesDot outputs a check evaluated in several namespaces as a
+// GraphViz DOT graph. Cluster-wide grants are drawn once, and each
+// namespace is a cluster holding its own RoleBindings and Roles.
+func PrintAllNamespacesDot(w io.Writer, result *rbac.AllNamespacesResult) error {
+	_, _ = fmt.Fprintln(w, "digraph rbac {")
+	_, _ = fmt.Fprintln(w, "  rankdir=LR;")
+	_, _ = fmt.Fprintln(w, "  node [shape=box fontname=\"Helvetica\"];")
+	_, _ = fmt.Fprintln(w, "  edge [fontname=\"Helvetica\" fontsize=10];")
+	_, _ = fmt.Fprintln(w)
+
+	permLabel := fmt.Sprintf("%s %s", result.Request.Verb, result.Request.FullResource())
+	_, _ = fmt.Fprintf(w, "  subject [label=\"%s\" shape=ellipse style=filled fillcolor=lightblue];\n", escapeLabel(result.Subject.String()))
+	_, _ = fmt.Fprintf(w, "  permission [label=\"%s\" shape=diamond style=filled fillcolor=lightgreen];\n", escapeLabel(permLabel))
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "  bypass [label=\"%s\\n(authorization bypassed)\" shape=octagon style=filled fillcolor=orange];\n",
+			escapeLabel(result.BypassedVia))
+		_, _ = fmt.Fprintln(w, "  subject -> bypass [label=\"member of\"];")
+		_, _ = fmt.Fprintln(w, "  bypass -> permission [label=\"allows in every namespace\"];")
+		_, _ = fmt.Fprintln(w, "}")
+		return nil
+	}
+
+	// Nodes belong to the cluster they are first declared in, so the
+	// ClusterRoles, which several namespaces can share, are declared first
+	// and every edge is drawn at the end
+	nodes := newGraphNodes()
+	var edges []string
+	addGrant := func(indent string, grant rbac.PermissionGrant) {
+		bindingID, newBinding := nodes.node("binding_", bindingKey(grant.Binding))
+		roleID, newRole := nodes.node("role_", roleKey(grant.Role))
+		if newBinding {
+			_, _ = fmt.Fprintf(w, "%s%s [label=\"%s\\n%s\" style=filled fillcolor=lightyellow];\n",
+				indent, bindingID, grant.Binding.Kind, escapeLabel(grant.Binding.Name))
+		}
+		if newRole {
+			_, _ = fmt.Fprintf(w, "%s%s [label=\"%s\\n%s\" style=filled fillcolor=wheat];\n",
+				indent, roleID, grant.Role.Kind, escapeLabel(grant.Role.Name))
+		}
+		if nodes.edge("subject", bindingID) {
+			edges = append(edges, fmt.Sprintf("  subject -> %s [label=\"binds\"];", bindingID))
+		}
+		if nodes.edge(bindingID, roleID) {
+			edges = append(edges, fmt.Sprintf("  %s -> %s [label=\"refs\"];", bindingID, roleID))
+		}
+		if nodes.edge(roleID, "permission") {
+			edges = append(edges, fmt.Sprintf("  %s -> permission [label=\"grants\"];", roleID))
+		}
+	}
+
+	for _, ns := range result.Namespaces {
+		for _, g := range ns.Grants {
+			if g.Role.Kind == "ClusterRole" {
+				if roleID, ok := nodes.node("role_", roleKey(g.Role)); ok {
+					_, _ = fmt.Fprintf(w, "  %s [label=\"ClusterRole\\n%s\" style=filled fillcolor=wheat];\n", roleID, escapeLabel(g.Role.Name))
+				}
+			}
+		}
+	}
+	for _, g := range result.ClusterWide {
+		addGrant("  ", g)
+	}
+
+	fills := map[string]string{"ALLOWED": "honeydew", "PARTIAL": "lightyellow", "DENIED": "mistyrose", "ERROR": "lightgrey"}
+	for i, ns := range result.Namespaces {
+		verdict := namespaceVerdict(ns)
+		_, _ = fmt.Fprintf(w, "\n  subgraph cluster_ns%d {\n", i)
+		_, _ = fmt.Fprintf(w, "    label=\"namespace %s (%s)\";\n", escapeLabel(ns.Namespace), verdict)
+		_, _ = fmt.Fprintf(w, "    style=filled; fillcolor=%s;\n", fills[verdict])
+		if len(ns.Grants) == 0 {
+			_, _ = fmt.Fprintf(w, "    ns%d_result [label=\"%s\" shape=plaintext];\n", i, escapeLabel(namespaceResultLabel(ns)))
+		}
+		for _, g := range ns.Grants {
+			addGrant("    ", g)
+		}
+		_, _ = fmt.Fprintln(w, "  }")
+	}
+
+	_, _ = fmt.Fprintln(w)
+	for _, e := range edges {
+		_, _ = fmt.Fprintln(w, e)
+	}
+	_, _ = fmt.Fprintln(w, "}")
+	return nil
+}
+
+// PrintAllNamespacesMermaid outputs a check evaluated in several namespaces
+// as a Mermaid diagram with one subgraph per namespace, like
+// PrintAllNamespacesDot
+func PrintAllNamespacesMermaid(w io.Writer, result *rbac.AllNamespacesResult) error {
+	_, _ = fmt.Fprintln(w, "graph LR")
+
+	permLabel := fmt.Sprintf("%s %s", result.Request.Verb, result.Request.FullResource())
+	_, _ = fmt.Fprintf(w, "  subject([%s])\n", escapeMermaid(result.Subject.String()))
+	_, _ = fmt.Fprintf(w, "  permission{{%s}}\n", escapeMermaid(permLabel))
+	styles := []string{
+		"  style subject fill:#add8e6,stroke:#333",
+		"  style permission fill:#90ee90,stroke:#333",
+	}
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "  bypass{{%s - authorization bypassed}}\n", escapeMermaid(result.BypassedVia))
+		_, _ = fmt.Fprintln(w, "  subject -->|member of| bypass")
+		_, _ = fmt.Fprintln(w, "  bypass -->|allows in every namespace| permission")
+		styles = append(styles, "  style bypass fill:#ffa500,stroke:#333")
+		_, _ = fmt.Fprintln(w)
+		for _, style := range styles {
+			_, _ = fmt.Fprintln(w, style)
+		}
+		return nil
+	}
+
+	// As in the DOT graph, shared ClusterRoles are declared outside the
+	// subgraphs and edges are drawn after them
+	nodes := newGraphNodes()
+	var edges []string
+	addGrant := func(indent string, grant rbac.PermissionGrant) {
+		bindingID, newBinding := nodes.node("binding", bindingKey(grant.Binding))
+		roleID, newRole := nodes.node("role", roleKey(grant.Role))
+		if newBinding {
+			_, _ = fmt.Fprintf(w, "%s%s[%s]\n", indent, bindingID, escapeMermaid(grant.Binding.Kind+": "+grant.Binding.Name))
+			styles = append(styles, fmt.Sprintf("  style %s fill:#fffacd,stroke:#333", bindingID))
+		}
+		if newRole {
+			_, _ = fmt.Fprintf(w, "%s%s[%s]\n", indent, roleID, escapeMermaid(grant.Role.Kind+": "+grant.Role.Name))
+			styles = append(styles, fmt.Sprintf("  style %s fill:#f5deb3,stroke:#333", roleID))
+		}
+		if nodes.edge("subject", bindingID) {
+			edges = append(edges, fmt.Sprintf("  subject -->|binds| %s", bindingID))
+		}
+		if nodes.edge(bindingID, roleID) {
+			edges = append(edges, fmt.Sprintf("  %s -->|refs| %s", bindingID, roleID))
+		}
+		if nodes.edge(roleID, "permission") {
+			edges = append(edges, fmt.Sprintf("  %s -->|grants| permission", roleID))
+		}
+	}
+
+	for _, ns := range result.Namespaces {
+		for _, g := range ns.Grants {
+			if g.Role.Kind == "ClusterRole" {
+				if roleID, ok := nodes.node("role", roleKey(g.Role)); ok {
+					_, _ = fmt.Fprintf(w, "  %s[%s]\n", roleID, escapeMermaid("ClusterRole: "+g.Role.Name))
+					styles = append(styles, fmt.Sprintf("  style %s fill:#f5deb3,stroke:#333", roleID))
+				}
+			}
+		}
+	}
+	for _, g := range result.ClusterWide {
+		addGrant("  ", g)
+	}
+
+	fills := map[string]string{"ALLOWED": "#f0fff0", "PARTIAL": "#ffffe0", "DENIED": "#ffe4e1", "ERROR": "#d3d3d3"}
+	for i, ns := range result.Namespaces {
+		verdict := namespaceVerdict(ns)
+		_, _ = fmt.Fprintf(w, "  subgraph ns%d[\"namespace %s (%s)\"]\n", i, escapeMermaid(ns.Namespace), verdict)
+		if len(ns.Grants) == 0 {
+			_, _ = fmt.Fprintf(w, "    ns%d_result[%s]\n", i, escapeMermaid(namespaceResultLabel(ns)))
+		}
+		for _, g := range ns.Grants {
+			addGrant("    ", g)
+		}
+		_, _ = fmt.Fprintln(w, "  end")
+		styles = append(styles, fmt.Sprintf("  style ns%d fill:%s,stroke:#333", i, fills[verdict]))
+	}
+
+	for _, e := range edges {
+		_, _ = fmt.Fprintln(w, e)
+	}
+	_, _ = fmt.Fprintln(w)
+	for _, style := range styles {
+		_, _ = fmt.Fprintln(w, style)
+	}
+	return nil
+}
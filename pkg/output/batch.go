@@ -0,0 +1,114 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/batch"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// PrintBatchResults outputs one PASS/FAIL line per check plus a summary
+func PrintBatchResults(w io.Writer, results []batch.Result) {
+	for _, r := range results {
+		PrintBatchResult(w, r)
+	}
+	PrintBatchSummary(w, results)
+}
+
+// PrintBatchResult outputs the PASS/FAIL line for a single check
+func PrintBatchResult(w io.Writer, r batch.Result) {
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+
+	_, _ = fmt.Fprintf(w, "%s  %s\n", status, r.Check.DisplayName())
+	if r.Check.Expect != "" {
+		_, _ = fmt.Fprintf(w, "      expected: %s\n", r.Check.Expect)
+	}
+	_, _ = fmt.Fprintf(w, "      %s\n", explainResult(r))
+}
+
+// PrintBatchSummary outputs the pass/fail counts
+func PrintBatchSummary(w io.Writer, results []batch.Result) {
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\n%d check(s), %d passed, %d failed\n", len(results), len(results)-failed, failed)
+}
+
+// BatchResultOutput is a single check outcome in -o ndjson output
+type BatchResultOutput struct {
+	Source string      `json:"source"`
+	Line   int         `json:"line,omitempty"`
+	Name   string      `json:"name"`
+	Expect string      `json:"expect,omitempty"`
+	Passed bool        `json:"passed"`
+	Error  string      `json:"error,omitempty"`
+	Result *JSONOutput `json:"result,omitempty"`
+}
+
+// PrintBatchResultNDJSON outputs a single check outcome as one JSON line
+func PrintBatchResultNDJSON(w io.Writer, r batch.Result) error {
+	out := BatchResultOutput{
+		Source: r.Source,
+		Line:   r.Line,
+		Name:   r.Check.DisplayName(),
+		Expect: r.Check.Expect,
+		Passed: r.Passed(),
+	}
+	if r.Err != nil {
+		out.Error = r.Err.Error()
+	}
+	if r.Result != nil {
+		result := BuildJSONOutput(r.Result, nil)
+		out.Result = &result
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// explainResult returns a one-line explanation of a check outcome: the verdict
+// plus the primary grant path, or the reason it was denied
+func explainResult(r batch.Result) string {
+	if r.Err != nil {
+		return "ERROR: " + r.Err.Error()
+	}
+	return summarizeResult(r.Result)
+}
+
+// summarizeResult returns a one-line verdict with the primary grant path
+func summarizeResult(result *rbac.PermissionResult) string {
+	if result.BypassedVia != "" {
+		return fmt.Sprintf("ALLOWED: authorization bypassed via %s", result.BypassedVia)
+	}
+
+	if !result.Allowed {
+		msg := fmt.Sprintf("DENIED: No RBAC rules grant %s %s to %s",
+			result.Request.Verb, formatResource(result.Request), result.Subject.String())
+		if result.Request.Namespace != "" {
+			msg += " in namespace " + result.Request.Namespace
+		}
+		return msg
+	}
+
+	grant := result.Grants[0]
+	msg := fmt.Sprintf("ALLOWED via %s %s -> %s %s (%s)",
+		grant.Binding.Kind, qualifiedName(grant.Binding.Namespace, grant.Binding.Name),
+		grant.Role.Kind, qualifiedName(grant.Role.Namespace, grant.Role.Name),
+		FormatRule(grant.MatchingRule))
+	if len(result.Grants) > 1 {
+		msg += fmt.Sprintf(" and %d other path(s)", len(result.Grants)-1)
+	}
+	return msg
+}
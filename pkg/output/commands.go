@@ -0,0 +1,31 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// PrintCommands lists the kubectl commands that reproduce the check and
+// show each path's binding and role, under shell comments so the block can
+// be pasted as is
+func PrintCommands(w io.Writer, result *rbac.PermissionResult) {
+	if len(result.Commands) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nCommands:\n")
+	_, _ = fmt.Fprintf(w, "  # Ask the API server the same question\n")
+	for _, command := range result.Commands {
+		_, _ = fmt.Fprintf(w, "  %s\n", command)
+	}
+	for i, grant := range result.Grants {
+		if len(grant.Commands) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  # Path %d: %s %s -> %s %s\n", i+1, grant.Binding.Kind, grant.Binding.Name, grant.Role.Kind, grant.Role.Name)
+		for _, command := range grant.Commands {
+			_, _ = fmt.Fprintf(w, "  %s\n", command)
+		}
+	}
+}
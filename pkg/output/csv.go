@@ -0,0 +1,111 @@
+package output
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func init() {
+	Register("csv", func(opts PrinterOptions) Printer { return &CSVPrinter{NoHeaders: opts.NoHeaders} })
+}
+
+// grantCSVHeader names the columns of a grant row
+var grantCSVHeader = []string{
+	"subject", "verb", "resource", "namespace",
+	"binding_kind", "binding_name", "binding_namespace",
+	"role_kind", "role_name", "role_namespace",
+	"rule_verbs", "rule_resources", "scope",
+}
+
+// riskCSVHeader names the columns of a risky finding row
+var riskCSVHeader = []string{"category", "severity", "binding", "role", "rule"}
+
+// CSVPrinter outputs one row per grant, for spreadsheets
+type CSVPrinter struct {
+	// NoHeaders leaves out the header row, so outputs can be concatenated
+	NoHeaders bool
+}
+
+func (p *CSVPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	var records [][]string
+	resource := formatResource(result.Request)
+	for _, grant := range result.Grants {
+		records = append(records, grantRecord(result.Subject, result.Request.Verb, resource, result.Request.Namespace, grant))
+	}
+	return writeCSV(w, grantCSVHeader, records, p.NoHeaders)
+}
+
+// PrintListCSV outputs one row per verb, resource, and grant the subject
+// may use it through
+func PrintListCSV(w io.Writer, subject rbac.Subject, namespace string, rows []rbac.PermissionRow, noHeaders bool) error {
+	var records [][]string
+	for _, row := range rows {
+		resource := row.NonResourceURL
+		if resource == "" {
+			resource = row.Resource
+			if row.APIGroup != "" {
+				resource += "." + row.APIGroup
+			}
+		}
+		for _, grant := range row.Grants {
+			records = append(records, grantRecord(subject, row.Verb, resource, namespace, grant))
+		}
+	}
+	return writeCSV(w, grantCSVHeader, records, noHeaders)
+}
+
+// PrintRiskyCSV outputs one row per risky finding and grant behind it
+func PrintRiskyCSV(w io.Writer, risks []rbac.RiskyPermission, noHeaders bool) error {
+	var records [][]string
+	for _, risk := range risks {
+		if risk.BypassedVia != "" {
+			records = append(records, []string{risk.Category, risk.Severity, "membership in " + risk.BypassedVia, "", ""})
+			continue
+		}
+		for _, grant := range risk.Grants {
+			binding, role := formatTraceBinding(grant.Binding), formatRole(grant.Role)
+			if grant.ChainUnknown() {
+				binding, role = "", ""
+			}
+			records = append(records, []string{risk.Category, risk.Severity, binding, role, FormatRule(grant.MatchingRule)})
+		}
+	}
+	return writeCSV(w, riskCSVHeader, records, noHeaders)
+}
+
+// grantRecord returns the grant row for a subject allowed verb on resource
+func grantRecord(subject rbac.Subject, verb, resource, namespace string, grant rbac.PermissionGrant) []string {
+	return []string{
+		subject.AsString(), verb, resource, namespace,
+		grant.Binding.Kind, grant.Binding.Name, grant.Binding.Namespace,
+		grant.Role.Kind, grant.Role.Name, grant.Role.Namespace,
+		strings.Join(grant.MatchingRule.Verbs, ","), strings.Join(ruleResources(grant.MatchingRule), ","), string(grant.Scope),
+	}
+}
+
+// ruleResources returns the resources a rule names, or its non-resource URLs
+func ruleResources(rule rbacv1.PolicyRule) []string {
+	if len(rule.NonResourceURLs) > 0 {
+		return rule.NonResourceURLs
+	}
+	return rule.Resources
+}
+
+// writeCSV writes records under header, quoting fields as RFC 4180 requires
+func writeCSV(w io.Writer, header []string, records [][]string, noHeaders bool) error {
+	cw := csv.NewWriter(w)
+	if !noHeaders {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,173 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// DiffSide describes one side of a permission comparison
+type DiffSide struct {
+	Label     string // e.g., the kubeconfig context name
+	Cluster   string
+	Namespace string
+	Subject   rbac.Subject
+}
+
+// DiffSideOutput is a DiffSide in JSON output
+type DiffSideOutput struct {
+	Label     string        `json:"label"`
+	Cluster   string        `json:"cluster,omitempty"`
+	Namespace string        `json:"namespace,omitempty"`
+	Subject   SubjectOutput `json:"subject"`
+}
+
+// PermissionDeltaOutput is a permission held on only one side
+type PermissionDeltaOutput struct {
+	Permission     string           `json:"permission"`
+	Verb           string           `json:"verb"`
+	APIGroup       string           `json:"apiGroup"`
+	Resource       string           `json:"resource,omitempty"`
+	ResourceName   string           `json:"resourceName,omitempty"`
+	NonResourceURL string           `json:"nonResourceURL,omitempty"`
+	GrantedVia     []GrantRefOutput `json:"grantedVia"`
+}
+
+// GrantRefOutput names the binding and role behind a permission
+type GrantRefOutput struct {
+	Binding BindingOutput `json:"binding"`
+	Role    RoleOutput    `json:"role"`
+	// MatchedVia is the binding subject that matched, e.g. "Group dev"
+	MatchedVia string `json:"matchedVia,omitempty"`
+}
+
+// DiffOutput is the JSON structure for a permission comparison. OnlyLeft and
+// OnlyRight hold permissions present on that side alone; Shared, when
+// computed, holds those on both, with the left side's grants.
+type DiffOutput struct {
+	Left      DiffSideOutput          `json:"left"`
+	Right     DiffSideOutput          `json:"right"`
+	OnlyLeft  []PermissionDeltaOutput `json:"onlyLeft"`
+	OnlyRight []PermissionDeltaOutput `json:"onlyRight"`
+	Shared    []PermissionDeltaOutput `json:"shared,omitempty"`
+}
+
+// BuildDiffOutput converts a comparison into its JSON structure. shared may
+// be nil when it was not computed.
+func BuildDiffOutput(left, right DiffSide, onlyLeft, onlyRight, shared []rbac.PermissionDelta) DiffOutput {
+	out := DiffOutput{
+		Left:      buildDiffSide(left),
+		Right:     buildDiffSide(right),
+		OnlyLeft:  buildDeltas(onlyLeft),
+		OnlyRight: buildDeltas(onlyRight),
+	}
+	if shared != nil {
+		out.Shared = buildDeltas(shared)
+	}
+	return out
+}
+
+func buildDiffSide(side DiffSide) DiffSideOutput {
+	return DiffSideOutput{
+		Label:     side.Label,
+		Cluster:   side.Cluster,
+		Namespace: side.Namespace,
+		Subject: SubjectOutput{
+			Kind:      side.Subject.Kind,
+			Name:      side.Subject.Name,
+			Namespace: side.Subject.Namespace,
+			Origin:    side.Subject.Origin,
+		},
+	}
+}
+
+func buildDeltas(deltas []rbac.PermissionDelta) []PermissionDeltaOutput {
+	out := []PermissionDeltaOutput{}
+	for _, d := range deltas {
+		o := PermissionDeltaOutput{
+			Permission:     d.Permission.String(),
+			Verb:           d.Permission.Verb,
+			APIGroup:       d.Permission.APIGroup,
+			Resource:       d.Permission.Resource,
+			ResourceName:   d.Permission.ResourceName,
+			NonResourceURL: d.Permission.NonResourceURL,
+		}
+		for _, g := range d.Grants {
+			o.GrantedVia = append(o.GrantedVia, GrantRefOutput{
+				Binding: BindingOutput{Kind: g.Binding.Kind, Name: g.Binding.Name, Namespace: g.Binding.Namespace},
+				Role:    RoleOutput{Kind: g.Role.Kind, Name: g.Role.Name, Namespace: g.Role.Namespace},
+
+				MatchedVia: g.MatchedVia(),
+			})
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// PrintDiff outputs a permission comparison in human-readable form. Shared
+// permissions are listed when shared is not nil.
+func PrintDiff(w io.Writer, left, right DiffSide, onlyLeft, onlyRight, shared []rbac.PermissionDelta) {
+	_, _ = fmt.Fprintf(w, "Comparing %s with %s\n\n", describeDiffSide(left), describeDiffSide(right))
+
+	if len(shared) > 0 {
+		_, _ = fmt.Fprintf(w, "Shared:\n")
+		for _, d := range shared {
+			_, _ = fmt.Fprintf(w, "  %s\n", d.Permission)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(onlyLeft) == 0 && len(onlyRight) == 0 {
+		_, _ = fmt.Fprintln(w, "No differences.")
+		return
+	}
+	printDeltas(w, "Only in "+left.Label, onlyLeft)
+	printDeltas(w, "Only in "+right.Label, onlyRight)
+	_, _ = fmt.Fprintf(w, "%d permission(s) differ\n", len(onlyLeft)+len(onlyRight))
+}
+
+// PrintDiffJSON outputs a permission comparison as JSON
+func PrintDiffJSON(w io.Writer, left, right DiffSide, onlyLeft, onlyRight, shared []rbac.PermissionDelta) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildDiffOutput(left, right, onlyLeft, onlyRight, shared))
+}
+
+func describeDiffSide(side DiffSide) string {
+	// Sides labelled by their subject don't repeat it
+	var details []string
+	if side.Label != side.Subject.String() {
+		details = append(details, side.Subject.String())
+	}
+	if side.Namespace != "" {
+		details = append(details, "namespace "+side.Namespace)
+	}
+	if len(details) == 0 {
+		return side.Label
+	}
+	return side.Label + " (" + strings.Join(details, ", ") + ")"
+}
+
+func printDeltas(w io.Writer, heading string, deltas []rbac.PermissionDelta) {
+	if len(deltas) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%s:\n", heading)
+	for _, d := range deltas {
+		_, _ = fmt.Fprintf(w, "  %s\n", d.Permission)
+		for _, g := range d.Grants {
+			_, _ = fmt.Fprintf(w, "    via %s/%s -> %s/%s",
+				g.Binding.Kind, qualifiedName(g.Binding.Namespace, g.Binding.Name),
+				g.Role.Kind, qualifiedName(g.Role.Namespace, g.Role.Name))
+			if g.MatchedSubject != nil && g.MatchedSubject.Kind == "Group" {
+				_, _ = fmt.Fprintf(w, " (as member of %s)", g.MatchedVia())
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+}
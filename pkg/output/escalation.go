@@ -0,0 +1,189 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// EscalationOutput is the JSON structure for an escalation check
+type EscalationOutput struct {
+	Allowed   bool          `json:"allowed"`
+	Subject   SubjectOutput `json:"subject"`
+	Object    string        `json:"object"`
+	RoleRef   string        `json:"roleRef"`
+	Write     CheckOutput   `json:"write"`
+	Bypass    CheckOutput   `json:"bypass"`
+	Uncovered []string      `json:"uncovered"`
+}
+
+// CheckOutput summarizes a single permission check within a larger report
+type CheckOutput struct {
+	Request RequestOutput `json:"request"`
+	Allowed bool          `json:"allowed"`
+	Grants  []GrantOutput `json:"grants,omitempty"`
+}
+
+// BuildEscalationOutput converts an escalation check into its JSON structure
+func BuildEscalationOutput(check *rbac.EscalationCheck) EscalationOutput {
+	out := EscalationOutput{
+		Allowed:   check.Allowed,
+		Subject:   BuildJSONOutput(check.Write, nil).Subject,
+		Object:    check.Object,
+		RoleRef:   check.RoleRef,
+		Write:     buildCheckOutput(check.Write),
+		Bypass:    buildCheckOutput(check.Bypass),
+		Uncovered: []string{},
+	}
+	for _, k := range check.Uncovered {
+		out.Uncovered = append(out.Uncovered, k.String())
+	}
+	return out
+}
+
+func buildCheckOutput(result *rbac.PermissionResult) CheckOutput {
+	full := BuildJSONOutput(result, nil)
+	return CheckOutput{Request: full.Request, Allowed: full.Allowed, Grants: full.Grants}
+}
+
+// PrintEscalationCheck outputs an escalation check in human-readable form
+func PrintEscalationCheck(w io.Writer, check *rbac.EscalationCheck) {
+	verdict := "DENIED"
+	if check.Allowed {
+		verdict = "ALLOWED"
+	}
+	_, _ = fmt.Fprintf(w, "%s: %s may create %s\n\n", verdict, check.Write.Subject, check.Object)
+
+	printCheckLine(w, check.Write)
+	printCheckLine(w, check.Bypass)
+
+	if check.Write.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "\nMember of %s, which bypasses escalation checks\n", check.Write.BypassedVia)
+		return
+	}
+	if len(check.Uncovered) == 0 {
+		_, _ = fmt.Fprintf(w, "\nThe subject already holds every permission in %s\n", check.RoleRef)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nPermissions in %s the subject does not hold:\n", check.RoleRef)
+	for _, k := range check.Uncovered {
+		_, _ = fmt.Fprintf(w, "  - %s\n", k)
+	}
+	if check.Bypass.Allowed {
+		_, _ = fmt.Fprintf(w, "These are allowed anyway because the subject has %s\n", check.Bypass.Request.Verb)
+	}
+}
+
+// PrintEscalationCheckJSON outputs an escalation check as JSON
+func PrintEscalationCheckJSON(w io.Writer, check *rbac.EscalationCheck) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildEscalationOutput(check))
+}
+
+func printCheckLine(w io.Writer, result *rbac.PermissionResult) {
+	status := "no"
+	if result.Allowed {
+		status = "yes"
+	}
+	resource := formatResource(result.Request)
+	if result.Request.Namespace != "" {
+		resource += " in " + result.Request.Namespace
+	}
+	_, _ = fmt.Fprintf(w, "  %-8s %-50s %s", result.Request.Verb, resource, status)
+	if len(result.Grants) > 0 {
+		g := result.Grants[0]
+		_, _ = fmt.Fprintf(w, " (via %s/%s -> %s/%s)", g.Binding.Kind, qualifiedName(g.Binding.Namespace, g.Binding.Name), g.Role.Kind, qualifiedName(g.Role.Namespace, g.Role.Name))
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// EscalationVerbsOutput is the JSON structure for --escalation-check
+type EscalationVerbsOutput struct {
+	Subject     SubjectOutput `json:"subject"`
+	Namespace   string        `json:"namespace,omitempty"`
+	CanEscalate bool          `json:"canEscalate"`
+	CanBind     bool          `json:"canBind"`
+	Checks      []CheckOutput `json:"checks"`
+}
+
+// BuildEscalationVerbsOutput converts the escalate and bind checks into
+// their JSON structure
+func BuildEscalationVerbsOutput(e *rbac.EscalationVerbs) EscalationVerbsOutput {
+	out := EscalationVerbsOutput{
+		Subject:     BuildJSONOutput(e.CreateRoles, nil).Subject,
+		Namespace:   e.Namespace,
+		CanEscalate: e.CanEscalate(),
+		CanBind:     e.CanBind(),
+	}
+	for _, result := range e.Results() {
+		out.Checks = append(out.Checks, buildCheckOutput(result))
+	}
+	return out
+}
+
+// PrintEscalationVerbs outputs the escalate and bind checks, whether they
+// let the subject get around escalation prevention, and the path that
+// grants each allowed check
+func PrintEscalationVerbs(w io.Writer, e *rbac.EscalationVerbs) {
+	_, _ = fmt.Fprintf(w, "Escalation check for %s", e.CreateRoles.Subject)
+	if e.Namespace != "" {
+		_, _ = fmt.Fprintf(w, " in namespace %s", e.Namespace)
+	}
+	_, _ = fmt.Fprintf(w, "\n\n")
+	for _, result := range e.Results() {
+		printCheckLine(w, result)
+	}
+
+	roles, bindings := e.CreateRoles.Request.Resource, e.CreateBindings.Request.Resource
+	bound := roles
+	if e.BindClusterRoles != nil {
+		bound += " or clusterroles"
+	}
+	_, _ = fmt.Fprintf(w, "\nCreate %s beyond its own permissions: %s (create and escalate on %s)\n", roles, yesNo(e.CanEscalate()), roles)
+	_, _ = fmt.Fprintf(w, "Bind roles beyond its own permissions: %s (create on %s and bind on %s)\n", yesNo(e.CanBind()), bindings, bound)
+	if e.CanEscalate() || e.CanBind() {
+		_, _ = fmt.Fprintf(w, "Either lets it grant itself permissions it doesn't hold.\n")
+	}
+
+	if e.CreateRoles.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "\nMember of %s, which bypasses escalation checks\n", e.CreateRoles.BypassedVia)
+		return
+	}
+	printed := false
+	for _, result := range e.Results() {
+		for _, g := range result.Grants {
+			if !printed {
+				_, _ = fmt.Fprintf(w, "\nGrant paths:\n")
+				printed = true
+			}
+			_, _ = fmt.Fprintf(w, "  %s %s: %s -> %s: rules[%d] %s\n", result.Request.Verb, result.Request.Resource,
+				formatTraceBinding(g.Binding), formatRole(g.Role), g.RuleIndex, FormatRule(g.MatchingRule))
+		}
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "no"
+}
+
+// PrintEscalationVerbsJSON outputs the escalate and bind checks as JSON
+func PrintEscalationVerbsJSON(w io.Writer, e *rbac.EscalationVerbs) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildEscalationVerbsOutput(e))
+}
+
+// PrintEscalationVerbsYAML outputs the escalate and bind checks as YAML
+func PrintEscalationVerbsYAML(w io.Writer, e *rbac.EscalationVerbs) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(BuildEscalationVerbsOutput(e))
+}
@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+)
+
+// FindingGroup is a set of risky findings sharing an owner, severity, or subject
+type FindingGroup struct {
+	Name     string           `json:"name"`
+	Findings []notify.Finding `json:"findings"`
+}
+
+// PrintFindingGroups outputs audit findings, one section per group
+func PrintFindingGroups(w io.Writer, groups []FindingGroup) {
+	total := 0
+	for _, g := range groups {
+		total += len(g.Findings)
+	}
+	if total == 0 {
+		_, _ = fmt.Fprintln(w, "No risky permissions detected.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Found %d risky finding(s) in %d group(s):\n\n", total, len(groups))
+	for _, g := range groups {
+		_, _ = fmt.Fprintf(w, "%s (%d):\n", g.Name, len(g.Findings))
+		for _, f := range g.Findings {
+			_, _ = fmt.Fprintf(w, "  - [%s] %s: %s\n", strings.ToUpper(f.Severity), f.Category, f.Subject)
+			_, _ = fmt.Fprintf(w, "      via %s -> %s", f.Binding, f.Role)
+			if f.Owner != "" {
+				_, _ = fmt.Fprintf(w, " (owned by %s)", f.Owner)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// SkippedScopeOutput is a namespace or subject left out of partial results
+type SkippedScopeOutput struct {
+	Scope string `json:"scope"`
+	Error string `json:"error"`
+}
+
+// FindingGroupsOutput is the JSON structure for audit findings. Partial is
+// true when --keep-going skipped any scope.
+type FindingGroupsOutput struct {
+	Partial bool                 `json:"partial"`
+	Skipped []SkippedScopeOutput `json:"skipped"`
+	Groups  []FindingGroup       `json:"groups"`
+}
+
+// PrintFindingGroupsJSON outputs audit findings along with the scopes that
+// were skipped
+func PrintFindingGroupsJSON(w io.Writer, groups []FindingGroup, skipped []SkippedScopeOutput) error {
+	out := FindingGroupsOutput{Partial: len(skipped) > 0, Skipped: skipped, Groups: groups}
+	if out.Skipped == nil {
+		out.Skipped = []SkippedScopeOutput{}
+	}
+	if out.Groups == nil {
+		out.Groups = []FindingGroup{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// PrintSkippedScopes marks text output as partial and lists what was skipped
+func PrintSkippedScopes(w io.Writer, skipped []SkippedScopeOutput) {
+	if len(skipped) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "PARTIAL RESULTS: %d scope(s) could not be evaluated and were skipped:\n", len(skipped))
+	for _, s := range skipped {
+		_, _ = fmt.Fprintf(w, "  - %s: %s\n", s.Scope, s.Error)
+	}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// ghaLevels maps risky severities to GitHub Actions workflow command levels
+var ghaLevels = map[string]string{
+	"critical": "error",
+	"high":     "warning",
+	"medium":   "notice",
+	"low":      "notice",
+}
+
+// PrintRiskyGHA outputs risky permissions as GitHub Actions workflow commands
+// (one ::error/::warning/::notice annotation per finding and grant) so they
+// show up inline on pull requests
+func PrintRiskyGHA(w io.Writer, subject rbac.Subject, risks []rbac.RiskyPermission) {
+	for _, risk := range risks {
+		level, ok := ghaLevels[risk.Severity]
+		if !ok {
+			level = "notice"
+		}
+		title := "RBAC risky permission: " + risk.Category
+
+		if risk.BypassedVia != "" {
+			message := fmt.Sprintf("[%s] %s: %s via membership in %s. %s",
+				risk.Severity, risk.Category, subject.String(), risk.BypassedVia, risk.Description)
+			_, _ = fmt.Fprintf(w, "::%s title=%s::%s\n", level, escapeGHAProperty(title), escapeGHAData(message))
+			continue
+		}
+		for _, grant := range risk.Grants {
+			via := fmt.Sprintf("%s/%s -> %s/%s",
+				grant.Binding.Kind, qualifiedName(grant.Binding.Namespace, grant.Binding.Name),
+				grant.Role.Kind, qualifiedName(grant.Role.Namespace, grant.Role.Name))
+			if grant.ChainUnknown() {
+				via = FormatRule(grant.MatchingRule) + " (binding unknown)"
+			}
+			if grant.Superuser() {
+				via += " (superuser)"
+			}
+			if len(grant.Wildcards) > 0 {
+				via += " (" + formatWildcards(grant.Wildcards) + ")"
+			}
+			message := fmt.Sprintf("[%s] %s: %s via %s. %s",
+				risk.Severity, risk.Category, subject.String(), via, risk.Description)
+			// Roles read from local manifests are annotated on their file
+			file := ""
+			if src, _ := grant.RuleLocation(); src != nil {
+				file = "file=" + escapeGHAProperty(src.File) + ","
+				if src.Line > 0 {
+					file += fmt.Sprintf("line=%d,", src.Line)
+				}
+				message += " Rule defined at " + grant.RuleSource() + "."
+			}
+			_, _ = fmt.Fprintf(w, "::%s %stitle=%s::%s\n", level, file, escapeGHAProperty(title), escapeGHAData(message))
+		}
+	}
+}
+
+// PrintDanglingBindingsGHA outputs a warning annotation for each binding
+// whose role is missing
+func PrintDanglingBindingsGHA(w io.Writer, dangling []rbac.DanglingBinding) {
+	for _, d := range dangling {
+		_, _ = fmt.Fprintf(w, "::warning title=%s::%s\n", escapeGHAProperty("RBAC dangling binding"), escapeGHAData(d.String()))
+	}
+}
+
+// qualifiedName returns namespace/name, or just name for cluster-scoped objects
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// escapeGHAData escapes the message part of a workflow command
+func escapeGHAData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	s = strings.ReplaceAll(s, "\n", "%0A")
+	return s
+}
+
+// escapeGHAProperty escapes a property value of a workflow command
+func escapeGHAProperty(s string) string {
+	s = escapeGHAData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	s = strings.ReplaceAll(s, ",", "%2C")
+	return s
+}
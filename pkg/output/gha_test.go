@@ -0,0 +1,102 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func TestPrintRiskyGHA(t *testing.T) {
+	subject := rbac.Subject{Kind: "User", Name: "alice"}
+	secretsGrant := func(source *rbac.ManifestSource) rbac.PermissionGrant {
+		return rbac.PermissionGrant{
+			Binding:      rbac.BindingInfo{Kind: "RoleBinding", Name: "read-secrets", Namespace: "prod"},
+			Role:         rbac.RoleInfo{Kind: "Role", Name: "secret-reader", Namespace: "prod", Source: source},
+			MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		risk rbac.RiskyPermission
+		want string
+	}{
+		{
+			name: "critical is an error",
+			risk: rbac.RiskyPermission{Category: "superuser-group", Severity: "critical", BypassedVia: "system:masters", Description: "Bypasses RBAC."},
+			want: "::error title=RBAC risky permission%3A superuser-group::[critical] superuser-group: User alice via membership in system:masters. Bypasses RBAC.\n",
+		},
+		{
+			name: "high is a warning",
+			risk: rbac.RiskyPermission{Category: "secrets-access", Severity: "high", Description: "Can read secrets.", Grants: []rbac.PermissionGrant{secretsGrant(nil)}},
+			want: "::warning title=RBAC risky permission%3A secrets-access::[high] secrets-access: User alice via RoleBinding/prod/read-secrets -> Role/prod/secret-reader. Can read secrets.\n",
+		},
+		{
+			name: "medium is a notice",
+			risk: rbac.RiskyPermission{Category: "pod-exec", Severity: "medium", BypassedVia: "system:masters"},
+			want: "::notice title=",
+		},
+		{
+			name: "low is a notice",
+			risk: rbac.RiskyPermission{Category: "pod-exec", Severity: "low", BypassedVia: "system:masters"},
+			want: "::notice title=",
+		},
+		{
+			name: "unknown severity is a notice",
+			risk: rbac.RiskyPermission{Category: "pod-exec", Severity: "severe", BypassedVia: "system:masters"},
+			want: "::notice title=",
+		},
+		{
+			name: "file and line of a manifest",
+			risk: rbac.RiskyPermission{Category: "secrets-access", Severity: "high", Description: "Can read secrets.", Grants: []rbac.PermissionGrant{
+				secretsGrant(&rbac.ManifestSource{File: "rbac/roles.yaml", Document: 2, Line: 11}),
+			}},
+			want: "::warning file=rbac/roles.yaml,line=11,title=RBAC risky permission%3A secrets-access::[high] secrets-access: User alice via RoleBinding/prod/read-secrets -> Role/prod/secret-reader. Can read secrets. Rule defined at rbac/roles.yaml#doc2 rules[0].\n",
+		},
+		{
+			name: "file without a line",
+			risk: rbac.RiskyPermission{Category: "secrets-access", Severity: "high", Description: "Can read secrets.", Grants: []rbac.PermissionGrant{
+				secretsGrant(&rbac.ManifestSource{File: "a,b:c.yaml", Document: 1}),
+			}},
+			want: "::warning file=a%2Cb%3Ac.yaml,title=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintRiskyGHA(&buf, subject, []rbac.RiskyPermission{tt.risk})
+			if !strings.HasPrefix(buf.String(), tt.want) {
+				t.Errorf("PrintRiskyGHA() = %q, want it to start with %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeGHA(t *testing.T) {
+	tests := []struct {
+		in       string
+		data     string
+		property string
+	}{
+		{in: "plain text", data: "plain text", property: "plain text"},
+		{in: "100%", data: "100%25", property: "100%25"},
+		{in: "a\r\nb", data: "a%0D%0Ab", property: "a%0D%0Ab"},
+		{in: "kind: Role, name: x", data: "kind: Role, name: x", property: "kind%3A Role%2C name%3A x"},
+		// An escape in the input isn't read as one
+		{in: "%3A", data: "%253A", property: "%253A"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeGHAData(tt.in); got != tt.data {
+			t.Errorf("escapeGHAData(%q) = %q, want %q", tt.in, got, tt.data)
+		}
+		if got := escapeGHAProperty(tt.in); got != tt.property {
+			t.Errorf("escapeGHAProperty(%q) = %q, want %q", tt.in, got, tt.property)
+		}
+	}
+}
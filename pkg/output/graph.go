@@ -9,6 +9,11 @@ import (
 	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
 )
 
+func init() {
+	Register("dot", func(PrinterOptions) Printer { return &DotPrinter{} })
+	Register("mermaid", func(PrinterOptions) Printer { return &MermaidPrinter{} })
+}
+
 // DotPrinter outputs GraphViz DOT format
 type DotPrinter struct{}
 
@@ -28,7 +33,7 @@ func (p *DotPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *Cont
 		_, _ = fmt.Fprintf(w, "  denied [label=\"DENIED\\n%s cannot %s %s\" shape=octagon style=filled fillcolor=red fontcolor=white];\n",
 			escapeLabel(result.Subject.String()),
 			result.Request.Verb,
-			result.Request.Resource)
+			result.Request.FullResource())
 		_, _ = fmt.Fprintln(w, "}")
 		return nil
 	}
@@ -44,30 +49,66 @@ func (p *DotPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *Cont
 	_, _ = fmt.Fprintf(w, "  %s [label=\"%s\" shape=diamond style=filled fillcolor=lightgreen];\n",
 		permID, escapeLabel(permLabel))
 
-	for i, grant := range result.Grants {
-		bindingID := fmt.Sprintf("binding_%d", i)
-		roleID := fmt.Sprintf("role_%d", i)
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "  bypass [label=\"%s\\n(authorization bypassed)\" shape=octagon style=filled fillcolor=orange];\n",
+			escapeLabel(result.BypassedVia))
+		_, _ = fmt.Fprintf(w, "  %s -> bypass [label=\"member of\"];\n", subjectID)
+		_, _ = fmt.Fprintf(w, "  bypass -> %s [label=\"allows\"];\n", permID)
+	}
+
+	nodes := newGraphNodes()
+	for _, grant := range result.Grants {
+		bindingID, newBinding := nodes.node("binding_", bindingKey(grant.Binding))
+		roleID, newRole := nodes.node("role_", roleKey(grant.Role))
 
 		// Binding node
-		bindingLabel := fmt.Sprintf("%s\\n%s", grant.Binding.Kind, grant.Binding.Name)
-		if grant.Binding.Namespace != "" {
-			bindingLabel += fmt.Sprintf("\\n(ns: %s)", grant.Binding.Namespace)
+		if newBinding {
+			bindingLabel := fmt.Sprintf("%s\\n%s", grant.Binding.Kind, grant.Binding.Name)
+			if grant.Binding.Namespace != "" {
+				bindingLabel += fmt.Sprintf("\\n(ns: %s)", grant.Binding.Namespace)
+			}
+			_, _ = fmt.Fprintf(w, "  %s [label=\"%s\" style=filled fillcolor=lightyellow];\n",
+				bindingID, bindingLabel)
 		}
-		_, _ = fmt.Fprintf(w, "  %s [label=\"%s\" style=filled fillcolor=lightyellow];\n",
-			bindingID, bindingLabel)
 
 		// Role node
-		roleLabel := fmt.Sprintf("%s\\n%s", grant.Role.Kind, grant.Role.Name)
-		if grant.Role.Namespace != "" {
-			roleLabel += fmt.Sprintf("\\n(ns: %s)", grant.Role.Namespace)
+		if newRole {
+			roleLabel := fmt.Sprintf("%s\\n%s", grant.Role.Kind, grant.Role.Name)
+			if grant.Role.Namespace != "" {
+				roleLabel += fmt.Sprintf("\\n(ns: %s)", grant.Role.Namespace)
+			}
+			_, _ = fmt.Fprintf(w, "  %s [label=\"%s\" style=filled fillcolor=wheat];\n",
+				roleID, roleLabel)
+		}
+
+		// Aggregated rules get a hop through the ClusterRole they came from
+		grantingID := roleID
+		if src := grant.AggregatedFrom; src != nil {
+			var newSource bool
+			grantingID, newSource = nodes.node("source_", roleKey(grant.Role)+"/"+src.Name)
+			if newSource {
+				_, _ = fmt.Fprintf(w, "  %s [label=\"ClusterRole\\n%s%s\" style=filled fillcolor=wheat];\n",
+					grantingID, escapeLabel(src.Name), escapeLabel(formatMatchedLabels(src.MatchedLabels)))
+			}
 		}
-		_, _ = fmt.Fprintf(w, "  %s [label=\"%s\" style=filled fillcolor=wheat];\n",
-			roleID, roleLabel)
 
 		// Edges
-		_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"binds\"];\n", subjectID, bindingID)
-		_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"refs\"];\n", bindingID, roleID)
-		_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"grants\"];\n", roleID, permID)
+		if nodes.edge(subjectID, bindingID) {
+			_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"binds\"];\n", subjectID, bindingID)
+		}
+		if nodes.edge(bindingID, roleID) {
+			label := "refs"
+			if grant.ScopeNote() != "" {
+				label += "\\n(only in ns: " + grant.EffectiveNamespace + ")"
+			}
+			_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"%s\"];\n", bindingID, roleID, label)
+		}
+		if grantingID != roleID && nodes.edge(roleID, grantingID) {
+			_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"aggregates\"];\n", roleID, grantingID)
+		}
+		if nodes.edge(grantingID, permID) {
+			_, _ = fmt.Fprintf(w, "  %s -> %s [label=\"grants\"];\n", grantingID, permID)
+		}
 	}
 
 	_, _ = fmt.Fprintln(w, "}")
@@ -88,7 +129,7 @@ func (p *MermaidPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *
 		_, _ = fmt.Fprintf(w, "  denied{{DENIED: %s cannot %s %s}}\n",
 			escapeMermaid(result.Subject.String()),
 			result.Request.Verb,
-			result.Request.Resource)
+			result.Request.FullResource())
 		_, _ = fmt.Fprintln(w, "  style denied fill:#f66,stroke:#333,color:#fff")
 		return nil
 	}
@@ -102,42 +143,122 @@ func (p *MermaidPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *
 	permLabel := fmt.Sprintf("%s %s", result.Request.Verb, result.Request.FullResource())
 	_, _ = fmt.Fprintf(w, "  %s{{%s}}\n", permID, escapeMermaid(permLabel))
 
-	for i, grant := range result.Grants {
-		bindingID := fmt.Sprintf("binding%d", i)
-		roleID := fmt.Sprintf("role%d", i)
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "  bypass{{%s - authorization bypassed}}\n", escapeMermaid(result.BypassedVia))
+		_, _ = fmt.Fprintf(w, "  %s -->|member of| bypass\n", subjectID)
+		_, _ = fmt.Fprintf(w, "  bypass -->|allows| %s\n", permID)
+	}
+
+	var styles []string
+	nodes := newGraphNodes()
+	for _, grant := range result.Grants {
+		bindingID, newBinding := nodes.node("binding", bindingKey(grant.Binding))
+		roleID, newRole := nodes.node("role", roleKey(grant.Role))
 
 		// Binding node
-		bindingLabel := fmt.Sprintf("%s: %s", grant.Binding.Kind, grant.Binding.Name)
-		if grant.Binding.Namespace != "" {
-			bindingLabel += fmt.Sprintf(" ns:%s", grant.Binding.Namespace)
+		if newBinding {
+			bindingLabel := fmt.Sprintf("%s: %s", grant.Binding.Kind, grant.Binding.Name)
+			if grant.Binding.Namespace != "" {
+				bindingLabel += fmt.Sprintf(" ns:%s", grant.Binding.Namespace)
+			}
+			_, _ = fmt.Fprintf(w, "  %s[%s]\n", bindingID, escapeMermaid(bindingLabel))
+			styles = append(styles, fmt.Sprintf("  style %s fill:#fffacd,stroke:#333", bindingID))
 		}
-		_, _ = fmt.Fprintf(w, "  %s[%s]\n", bindingID, escapeMermaid(bindingLabel))
 
 		// Role node
-		roleLabel := fmt.Sprintf("%s: %s", grant.Role.Kind, grant.Role.Name)
-		if grant.Role.Namespace != "" {
-			roleLabel += fmt.Sprintf(" ns:%s", grant.Role.Namespace)
+		if newRole {
+			roleLabel := fmt.Sprintf("%s: %s", grant.Role.Kind, grant.Role.Name)
+			if grant.Role.Namespace != "" {
+				roleLabel += fmt.Sprintf(" ns:%s", grant.Role.Namespace)
+			}
+			_, _ = fmt.Fprintf(w, "  %s[%s]\n", roleID, escapeMermaid(roleLabel))
+			styles = append(styles, fmt.Sprintf("  style %s fill:#f5deb3,stroke:#333", roleID))
+		}
+
+		// Aggregated rules get a hop through the ClusterRole they came from
+		grantingID := roleID
+		if src := grant.AggregatedFrom; src != nil {
+			var newSource bool
+			grantingID, newSource = nodes.node("source", roleKey(grant.Role)+"/"+src.Name)
+			if newSource {
+				_, _ = fmt.Fprintf(w, "  %s[%s]\n", grantingID, escapeMermaid("ClusterRole: "+src.Name+formatMatchedLabels(src.MatchedLabels)))
+				styles = append(styles, fmt.Sprintf("  style %s fill:#f5deb3,stroke:#333", grantingID))
+			}
 		}
-		_, _ = fmt.Fprintf(w, "  %s[%s]\n", roleID, escapeMermaid(roleLabel))
 
 		// Edges
-		_, _ = fmt.Fprintf(w, "  %s -->|binds| %s\n", subjectID, bindingID)
-		_, _ = fmt.Fprintf(w, "  %s -->|refs| %s\n", bindingID, roleID)
-		_, _ = fmt.Fprintf(w, "  %s -->|grants| %s\n", roleID, permID)
+		if nodes.edge(subjectID, bindingID) {
+			_, _ = fmt.Fprintf(w, "  %s -->|binds| %s\n", subjectID, bindingID)
+		}
+		if nodes.edge(bindingID, roleID) {
+			label := "refs"
+			if grant.ScopeNote() != "" {
+				label += " only in ns " + grant.EffectiveNamespace
+			}
+			_, _ = fmt.Fprintf(w, "  %s -->|%s| %s\n", bindingID, escapeMermaid(label), roleID)
+		}
+		if grantingID != roleID && nodes.edge(roleID, grantingID) {
+			_, _ = fmt.Fprintf(w, "  %s -->|aggregates| %s\n", roleID, grantingID)
+		}
+		if nodes.edge(grantingID, permID) {
+			_, _ = fmt.Fprintf(w, "  %s -->|grants| %s\n", grantingID, permID)
+		}
 	}
 
 	// Styling
 	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintln(w, "  style subject fill:#add8e6,stroke:#333")
 	_, _ = fmt.Fprintln(w, "  style permission fill:#90ee90,stroke:#333")
-	for i := range result.Grants {
-		_, _ = fmt.Fprintf(w, "  style binding%d fill:#fffacd,stroke:#333\n", i)
-		_, _ = fmt.Fprintf(w, "  style role%d fill:#f5deb3,stroke:#333\n", i)
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintln(w, "  style bypass fill:#ffa500,stroke:#333")
+	}
+	for _, style := range styles {
+		_, _ = fmt.Fprintln(w, style)
 	}
 
 	return nil
 }
 
+// graphNodes numbers the distinct bindings, roles, and aggregation sources
+// of a result, so each is drawn once however many grants pass through it
+type graphNodes struct {
+	ids   map[string]string
+	count map[string]int
+	edges map[[2]string]bool
+}
+
+func newGraphNodes() *graphNodes {
+	return &graphNodes{ids: make(map[string]string), count: make(map[string]int), edges: make(map[[2]string]bool)}
+}
+
+// node returns the ID for key, and whether this is its first use
+func (n *graphNodes) node(prefix, key string) (string, bool) {
+	if id, ok := n.ids[prefix+key]; ok {
+		return id, false
+	}
+	id := fmt.Sprintf("%s%d", prefix, n.count[prefix])
+	n.count[prefix]++
+	n.ids[prefix+key] = id
+	return id, true
+}
+
+// edge reports whether the edge from -> to hasn't been drawn yet
+func (n *graphNodes) edge(from, to string) bool {
+	if n.edges[[2]string{from, to}] {
+		return false
+	}
+	n.edges[[2]string{from, to}] = true
+	return true
+}
+
+func bindingKey(b rbac.BindingInfo) string {
+	return b.Kind + "/" + b.Namespace + "/" + b.Name
+}
+
+func roleKey(r rbac.RoleInfo) string {
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
 // sanitizeID makes a string safe for use as a DOT node ID
 func sanitizeID(s string) string {
 	reg := regexp.MustCompile(`[^a-zA-Z0-9_]`)
@@ -0,0 +1,97 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// ImpersonationOutput is the JSON structure for --show-impersonation
+type ImpersonationOutput struct {
+	Subject        SubjectOutput               `json:"subject"`
+	CanImpersonate bool                        `json:"canImpersonate"`
+	BypassedVia    string                      `json:"bypassedVia,omitempty"`
+	Targets        []ImpersonationTargetOutput `json:"targets"`
+}
+
+// ImpersonationTargetOutput is one set of identities the subject can assume
+type ImpersonationTargetOutput struct {
+	Kind        string      `json:"kind"`
+	Description string      `json:"description"`
+	Any         bool        `json:"any"`
+	Names       []string    `json:"names,omitempty"`
+	Namespace   string      `json:"namespace,omitempty"`
+	ExtraKey    string      `json:"extraKey,omitempty"`
+	Grant       GrantOutput `json:"grant"`
+}
+
+// BuildImpersonationOutput converts an impersonation analysis into its JSON
+// structure
+func BuildImpersonationOutput(i *rbac.Impersonation) ImpersonationOutput {
+	out := ImpersonationOutput{
+		Subject: SubjectOutput{
+			Kind:      i.Subject.Kind,
+			Name:      i.Subject.Name,
+			Namespace: i.Subject.Namespace,
+			Origin:    i.Subject.Origin,
+		},
+		CanImpersonate: i.CanImpersonate(),
+		BypassedVia:    i.BypassedVia,
+		Targets:        []ImpersonationTargetOutput{},
+	}
+	for _, t := range i.Targets {
+		out.Targets = append(out.Targets, ImpersonationTargetOutput{
+			Kind:        t.Kind,
+			Description: t.String(),
+			Any:         t.Any(),
+			Names:       t.Names,
+			Namespace:   t.Namespace,
+			ExtraKey:    t.ExtraKey,
+			Grant:       buildGrantOutput(t.Grant),
+		})
+	}
+	return out
+}
+
+// PrintImpersonation outputs the identities the subject can impersonate,
+// one line per grant with the path that grants it
+func PrintImpersonation(w io.Writer, i *rbac.Impersonation) {
+	_, _ = fmt.Fprintf(w, "Impersonation for %s\n\n", i.Subject)
+	if i.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "Member of %s, which bypasses authorization: it can impersonate anyone\n", i.BypassedVia)
+		return
+	}
+	if len(i.Targets) == 0 {
+		_, _ = fmt.Fprintf(w, "%s cannot impersonate anyone\n", i.Subject)
+		return
+	}
+
+	width := 0
+	for _, t := range i.Targets {
+		width = max(width, len(t.String()))
+	}
+	_, _ = fmt.Fprintf(w, "Can become:\n")
+	for _, t := range i.Targets {
+		g := t.Grant
+		_, _ = fmt.Fprintf(w, "  %-*s  via %s -> %s: rules[%d] %s\n", width, t.String(),
+			formatTraceBinding(g.Binding), formatRole(g.Role), g.RuleIndex, FormatRule(g.MatchingRule))
+	}
+}
+
+// PrintImpersonationJSON outputs an impersonation analysis as JSON
+func PrintImpersonationJSON(w io.Writer, i *rbac.Impersonation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildImpersonationOutput(i))
+}
+
+// PrintImpersonationYAML outputs an impersonation analysis as YAML
+func PrintImpersonationYAML(w io.Writer, i *rbac.Impersonation) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(BuildImpersonationOutput(i))
+}
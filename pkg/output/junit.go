@@ -0,0 +1,148 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/batch"
+)
+
+// JUnitTestSuites is the root element of a JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite groups the checks from one checks file
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is a single check
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Error     *JUnitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitMessage is the body of a failure or error element
+type JUnitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// PrintJUnit outputs batch results as a JUnit XML report with one testsuite per checks file
+func PrintJUnit(w io.Writer, results []batch.Result) error {
+	report := BuildJUnitReport(results, time.Now())
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// BuildJUnitReport converts batch results into the JUnit document structure
+func BuildJUnitReport(results []batch.Result, timestamp time.Time) JUnitTestSuites {
+	report := JUnitTestSuites{}
+	suiteIndex := make(map[string]int)
+	suiteTimes := make(map[string]time.Duration)
+	var total time.Duration
+
+	for _, r := range results {
+		idx, ok := suiteIndex[r.Source]
+		if !ok {
+			idx = len(report.Suites)
+			suiteIndex[r.Source] = idx
+			report.Suites = append(report.Suites, JUnitTestSuite{
+				Name:      r.Source,
+				Timestamp: timestamp.UTC().Format(time.RFC3339),
+			})
+		}
+		suite := &report.Suites[idx]
+
+		tc := JUnitTestCase{
+			Name:      testName(r),
+			ClassName: "rbac-why." + r.Source,
+			Time:      formatSeconds(r.Duration),
+		}
+
+		explanation := explainResult(r)
+		switch {
+		case r.Err != nil:
+			tc.Error = &JUnitMessage{
+				Message: r.Err.Error(),
+				Type:    "EvaluationError",
+				Body:    explanation,
+			}
+			suite.Errors++
+		case !r.Passed():
+			failureType := "UnexpectedDeny"
+			if r.Result.Allowed {
+				failureType = "UnexpectedAllow"
+			}
+			tc.Failure = &JUnitMessage{
+				Message: fmt.Sprintf("expected %s", r.Check.Expect),
+				Type:    failureType,
+				Body:    explanation,
+			}
+			suite.Failures++
+		default:
+			tc.SystemOut = explanation
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, tc)
+		suiteTimes[r.Source] += r.Duration
+		total += r.Duration
+	}
+
+	for i := range report.Suites {
+		suite := &report.Suites[i]
+		suite.Time = formatSeconds(suiteTimes[suite.Name])
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+	}
+	report.Time = formatSeconds(total)
+
+	return report
+}
+
+// testName names a testcase after its check. A check that runs as the
+// invocation's subject is named with that subject too, so every name says
+// who was checked.
+func testName(r batch.Result) string {
+	name := r.Check.DisplayName()
+	if r.Check.Name != "" || r.Check.As != "" || r.Result == nil {
+		return name
+	}
+	return r.Result.Subject.AsString() + " " + name
+}
+
+// formatSeconds renders a duration the way JUnit consumers expect (seconds, 3 decimals)
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,121 @@
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/batch"
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// Generic element tree used to check the report against the JUnit schema
+// (attribute names and nesting) independently of the writer's own types
+type xmlElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr   `xml:",any,attr"`
+	Children []xmlElement `xml:",any"`
+}
+
+func (e xmlElement) attr(name string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func TestPrintJUnit(t *testing.T) {
+	allowed := &rbac.PermissionResult{
+		Allowed: true,
+		Subject: rbac.Subject{Kind: "ServiceAccount", Name: "api", Namespace: "prod"},
+		Request: rbac.PermissionRequest{Verb: "get", Resource: "pods", Namespace: "prod"},
+		Grants: []rbac.PermissionGrant{{
+			Binding: rbac.BindingInfo{Kind: "RoleBinding", Name: "api-view", Namespace: "prod"},
+			Role:    rbac.RoleInfo{Kind: "ClusterRole", Name: "view"},
+			Scope:   rbac.ScopeNamespace,
+		}},
+	}
+	denied := &rbac.PermissionResult{
+		Subject: rbac.Subject{Kind: "ServiceAccount", Name: "api", Namespace: "prod"},
+		Request: rbac.PermissionRequest{Verb: "delete", Resource: "pods", Namespace: "prod"},
+	}
+
+	results := []batch.Result{
+		{Source: "a.yaml", Check: batch.Check{Verb: "get", Resource: "pods", Expect: batch.ExpectAllowed}, Result: allowed, Duration: 5 * time.Millisecond},
+		{Source: "a.yaml", Check: batch.Check{Verb: "get", Resource: "pods", Expect: batch.ExpectDenied}, Result: allowed, Duration: time.Millisecond},
+		{Source: "b.yaml", Check: batch.Check{Verb: "delete", Resource: "pods", Expect: batch.ExpectAllowed}, Result: denied},
+		{Source: "b.yaml", Check: batch.Check{Verb: "get", Resource: "pods"}, Err: errors.New("forbidden")},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintJUnit(&buf, results); err != nil {
+		t.Fatalf("PrintJUnit() error: %v", err)
+	}
+
+	var root xmlElement
+	if err := xml.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatalf("PrintJUnit() produced invalid XML: %v\n%s", err, buf.String())
+	}
+	if root.XMLName.Local != "testsuites" {
+		t.Fatalf("root element = %s, expected testsuites", root.XMLName.Local)
+	}
+	if len(root.Children) != 2 {
+		t.Fatalf("got %d testsuites, expected one per checks file (2)", len(root.Children))
+	}
+
+	expected := map[string]struct{ tests, failures, errors int }{
+		"a.yaml": {tests: 2, failures: 1},
+		"b.yaml": {tests: 2, failures: 1, errors: 1},
+	}
+
+	for _, suite := range root.Children {
+		if suite.XMLName.Local != "testsuite" {
+			t.Fatalf("unexpected element %s under testsuites", suite.XMLName.Local)
+		}
+		for _, required := range []string{"name", "tests", "failures", "errors", "time", "timestamp"} {
+			if _, ok := suite.attr(required); !ok {
+				t.Errorf("testsuite is missing required attribute %q", required)
+			}
+		}
+
+		name, _ := suite.attr("name")
+		want := expected[name]
+		for attr, value := range map[string]int{"tests": want.tests, "failures": want.failures, "errors": want.errors} {
+			got, _ := suite.attr(attr)
+			if got != strconv.Itoa(value) {
+				t.Errorf("testsuite %s %s = %s, expected %d", name, attr, got, value)
+			}
+		}
+
+		for _, tc := range suite.Children {
+			if tc.XMLName.Local != "testcase" {
+				t.Fatalf("unexpected element %s under testsuite", tc.XMLName.Local)
+			}
+			for _, required := range []string{"name", "classname", "time"} {
+				if _, ok := tc.attr(required); !ok {
+					t.Errorf("testcase is missing required attribute %q", required)
+				}
+			}
+			timeAttr, _ := tc.attr("time")
+			if _, err := strconv.ParseFloat(timeAttr, 64); err != nil {
+				t.Errorf("testcase time %q is not a decimal number of seconds", timeAttr)
+			}
+			for _, child := range tc.Children {
+				switch child.XMLName.Local {
+				case "failure", "error":
+					if _, ok := child.attr("message"); !ok {
+						t.Errorf("%s element is missing the message attribute", child.XMLName.Local)
+					}
+				case "system-out":
+				default:
+					t.Errorf("unexpected element %s under testcase", child.XMLName.Local)
+				}
+			}
+		}
+	}
+}
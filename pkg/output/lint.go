@@ -0,0 +1,48 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/lint"
+)
+
+// PrintLintFindings outputs lint findings in human-readable form
+func PrintLintFindings(w io.Writer, findings []lint.Finding) {
+	if len(findings) == 0 {
+		_, _ = fmt.Fprintln(w, "No lint findings.")
+		return
+	}
+
+	for _, f := range findings {
+		_, _ = fmt.Fprintf(w, "[%s] %s: %s\n", f.Check, f.Object, f.Message)
+		for _, c := range f.Coverage {
+			_, _ = fmt.Fprintf(w, "  Rule: %s\n", FormatRule(c.Rule))
+			for _, by := range c.CoveredBy {
+				_, _ = fmt.Fprintf(w, "    covered by: %s\n", FormatRule(by))
+			}
+		}
+		if len(f.RedundantBindings) > 0 {
+			_, _ = fmt.Fprintf(w, "  Redundant bindings (subjects already bound to %s):\n", f.Superset)
+			for _, b := range f.RedundantBindings {
+				_, _ = fmt.Fprintf(w, "    - %s\n", b)
+			}
+		}
+		if f.Fix != "" {
+			_, _ = fmt.Fprintf(w, "  Fix: %s\n", f.Fix)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	_, _ = fmt.Fprintf(w, "%d finding(s)\n", len(findings))
+}
+
+// PrintLintFindingsJSON outputs lint findings as a JSON array
+func PrintLintFindingsJSON(w io.Writer, findings []lint.Finding) error {
+	if findings == nil {
+		findings = []lint.Finding{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(findings)
+}
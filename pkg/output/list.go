@@ -0,0 +1,145 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// ListRuleOutput is one rule of a grant source, for the list command
+type ListRuleOutput struct {
+	Rule      RuleOutput        `json:"rule"`
+	RuleIndex int               `json:"ruleIndex"`
+	Wildcard  bool              `json:"wildcard,omitempty"`
+	Source    *RuleSourceOutput `json:"source,omitempty"`
+}
+
+// ListSourceOutput is a binding and role, with the rules the subject gets from it
+type ListSourceOutput struct {
+	Binding BindingOutput    `json:"binding"`
+	Role    RoleOutput       `json:"role"`
+	Scope   string           `json:"scope"`
+	Rules   []ListRuleOutput `json:"rules"`
+}
+
+// ListOutput is the JSON/YAML structure for the list command. Rules are
+// grouped by the binding and role they come from, so each can be traced back
+// to the object to edit.
+type ListOutput struct {
+	Subject   SubjectOutput      `json:"subject"`
+	Namespace string             `json:"namespace,omitempty"`
+	Sources   []ListSourceOutput `json:"sources"`
+}
+
+// BuildListOutput groups grants by binding and role
+func BuildListOutput(subject rbac.Subject, namespace string, grants []rbac.PermissionGrant) ListOutput {
+	out := ListOutput{
+		Subject:   SubjectOutput{Kind: subject.Kind, Name: subject.Name, Namespace: subject.Namespace},
+		Namespace: namespace,
+		Sources:   []ListSourceOutput{},
+	}
+	index := make(map[string]int)
+	for _, g := range grants {
+		grant := buildGrantOutput(g)
+		key := formatTraceBinding(g.Binding) + " " + formatRole(g.Role)
+		i, ok := index[key]
+		if !ok {
+			i = len(out.Sources)
+			index[key] = i
+			out.Sources = append(out.Sources, ListSourceOutput{Binding: grant.Binding, Role: grant.Role, Scope: grant.Scope})
+		}
+		out.Sources[i].Rules = append(out.Sources[i].Rules, ListRuleOutput{
+			Rule:      grant.MatchingRule,
+			RuleIndex: g.RuleIndex,
+			Wildcard:  rbac.RuleHasWildcard(g.MatchingRule),
+			Source:    grant.Source,
+		})
+	}
+	return out
+}
+
+// PrintList outputs one row per verb and resource the subject may use, with
+// the binding and role that grant it
+func PrintList(w io.Writer, subject rbac.Subject, namespace string, rows []rbac.PermissionRow) {
+	where := listScope(namespace)
+	if len(rows) == 0 {
+		_, _ = fmt.Fprintf(w, "%s has no permissions (%s)\n", subject, where)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "Permissions of %s (%s):\n\n", subject, where)
+
+	table := NewTable("VERB", "APIGROUP", "RESOURCE", "NAMES", "WILDCARD", "GRANTED BY")
+	for _, row := range rows {
+		group, resource, names, wildcard := listRowCells(row)
+		g := row.Grants[0]
+		via := formatTraceBinding(g.Binding) + " -> " + formatRole(g.Role)
+		if more := len(row.Grants) - 1; more > 0 {
+			via += fmt.Sprintf(" (+%d more)", more)
+		}
+		table.AddRow(row.Verb, group, resource, names, wildcard, via)
+	}
+	_ = table.Print(w)
+}
+
+// PrintListWide outputs one row per verb, resource, and grant, with the
+// binding, role, and rule of each grant in their own columns
+func PrintListWide(w io.Writer, subject rbac.Subject, namespace string, rows []rbac.PermissionRow) error {
+	if len(rows) == 0 {
+		PrintList(w, subject, namespace, rows)
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "Permissions of %s (%s):\n\n", subject, listScope(namespace))
+	table := NewTable("VERB", "APIGROUP", "RESOURCE", "NAMES", "WILDCARD", "BINDING", "ROLE", "RULE")
+	for _, row := range rows {
+		group, resource, names, wildcard := listRowCells(row)
+		for _, g := range row.Grants {
+			table.AddRow(row.Verb, group, resource, names, wildcard, formatTraceBinding(g.Binding), formatRole(g.Role), formatCompactRule(g.MatchingRule))
+		}
+	}
+	return table.Print(w)
+}
+
+// listScope describes where the listed permissions apply
+func listScope(namespace string) string {
+	if namespace == "" {
+		return "cluster-wide only"
+	}
+	return "in namespace " + namespace
+}
+
+// listRowCells returns the API group, resource, names, and wildcard cells
+// of a list row
+func listRowCells(row rbac.PermissionRow) (group, resource, names, wildcard string) {
+	group, resource, names = row.APIGroup, row.Resource, "*"
+	if group == "" {
+		group = "(core)"
+	}
+	if row.NonResourceURL != "" {
+		group, resource, names = "-", row.NonResourceURL, "-"
+	} else if len(row.ResourceNames) > 0 {
+		names = strings.Join(row.ResourceNames, ",")
+	}
+	if row.Wildcard() {
+		wildcard = "yes"
+	}
+	return group, resource, names, wildcard
+}
+
+// PrintListJSON outputs the subject's rules grouped by source as JSON
+func PrintListJSON(w io.Writer, subject rbac.Subject, namespace string, grants []rbac.PermissionGrant) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildListOutput(subject, namespace, grants))
+}
+
+// PrintListYAML outputs the subject's rules grouped by source as YAML
+func PrintListYAML(w io.Writer, subject rbac.Subject, namespace string, grants []rbac.PermissionGrant) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(BuildListOutput(subject, namespace, grants))
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func init() {
+	Register("markdown", func(PrinterOptions) Printer { return &MarkdownPrinter{} })
+}
+
+// MarkdownPrinter outputs a summary line and a table of grants that render
+// the same on GitHub and GitLab, for pasting into tickets and pull requests
+type MarkdownPrinter struct{}
+
+func (p *MarkdownPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	if ctx != nil {
+		printMarkdownContext(w, ctx)
+	}
+
+	where := ""
+	if result.Request.Namespace != "" {
+		where = " in namespace " + markdownCode(result.Request.Namespace)
+	}
+	subject, verb, resource := markdownCode(result.Subject.String()), markdownCode(result.Request.Verb), markdownCode(formatResource(result.Request))
+
+	switch {
+	case result.Mode == rbac.ModeSelfAccessReview:
+		verdict, can := "DENIED", "cannot"
+		if result.Allowed {
+			verdict, can = "ALLOWED", "can"
+		}
+		_, _ = fmt.Fprintf(w, "**%s**: %s %s %s %s%s (according to the API server)\n\n", verdict, subject, can, verb, resource, where)
+		_, _ = fmt.Fprintf(w, "Why chain unavailable: %s.\n", result.Limitation)
+		return nil
+	case !result.Allowed && !result.PartiallyAllowed:
+		_, _ = fmt.Fprintf(w, "**DENIED**: No RBAC rules grant %s %s to %s%s.\n", verb, resource, subject, where)
+		return nil
+	case result.PartiallyAllowed:
+		names := make([]string, 0, len(result.AllowedNames()))
+		for _, name := range result.AllowedNames() {
+			names = append(names, markdownCode(name))
+		}
+		_, _ = fmt.Fprintf(w, "**ALLOWED only for %s**: %s can %s %s%s only by those names.\n", strings.Join(names, ", "), subject, verb, resource, where)
+	default:
+		_, _ = fmt.Fprintf(w, "**ALLOWED**: %s can %s %s%s.\n", subject, verb, resource, where)
+	}
+
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "\nAuthorization bypassed via %s, whose members are allowed every request without RBAC, so no binding is involved.\n", markdownCode(result.BypassedVia))
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(w, "\n| Binding | Role | Matching rule | Scope |\n|---|---|---|---|\n")
+	for _, grant := range result.Grants {
+		role := markdownCell(formatRole(grant.Role))
+		if grant.Superuser() {
+			role += " (superuser)"
+		}
+		_, _ = fmt.Fprintf(w, "| %s | %s | %s | %s |\n",
+			markdownCell(formatTraceBinding(grant.Binding)), role, markdownCell(FormatRule(grant.MatchingRule)), grant.Scope)
+	}
+	return nil
+}
+
+// PrintRiskyMarkdown outputs risky permissions as one table per severity
+func PrintRiskyMarkdown(w io.Writer, risks []rbac.RiskyPermission) {
+	if len(risks) == 0 {
+		_, _ = fmt.Fprintln(w, "No risky permissions detected.")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "**Found %d risky permission pattern(s)**\n", len(risks))
+	if exposure := risks[0].Exposure; exposure != nil {
+		_, _ = fmt.Fprintf(w, "\nToken exposure: %s\n", exposure)
+	}
+
+	for _, severity := range rbac.Severities {
+		group := filterBySeverity(risks, severity)
+		if len(group) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\n### %s\n\n| Category | Binding | Role | Rule |\n|---|---|---|---|\n", strings.ToUpper(severity[:1])+severity[1:])
+		for _, risk := range group {
+			category := markdownCell(risk.Category)
+			if risk.DefaultSeverity != "" {
+				category += " (severity overridden from " + risk.DefaultSeverity + ")"
+			}
+			if risk.UnexposedSeverity != "" {
+				category += " (raised from " + risk.UnexposedSeverity + ": token exposed)"
+			}
+			if risk.BypassedVia != "" {
+				_, _ = fmt.Fprintf(w, "| %s | membership in %s |  |  |\n", category, markdownCell(risk.BypassedVia))
+				continue
+			}
+			for _, grant := range risk.Grants {
+				binding, role := "unknown", ""
+				if !grant.ChainUnknown() {
+					binding, role = markdownCell(formatTraceBinding(grant.Binding)), markdownCell(formatRole(grant.Role))
+				}
+				_, _ = fmt.Fprintf(w, "| %s | %s | %s | %s |\n", category, binding, role, markdownCell(FormatRule(grant.MatchingRule)))
+			}
+		}
+	}
+}
+
+// printMarkdownContext outputs the current context as a collapsed section
+func printMarkdownContext(w io.Writer, ctx *ContextInfo) {
+	// Markdown isn't rendered inside <summary> everywhere, so it's plain HTML
+	_, _ = fmt.Fprintf(w, "<details>\n<summary>Current context: %s</summary>\n\n| Field | Value |\n|---|---|\n", html.EscapeString(ctx.ContextName))
+	rows := [][2]string{
+		{"Context", ctx.ContextName},
+		{"Cluster", ctx.ClusterName},
+		{"AuthInfo", ctx.AuthInfo},
+		{"User", ctx.UserName},
+		{"Groups", strings.Join(ctx.Groups, ", ")},
+		{"AuthMethod", ctx.AuthMethod},
+		{"Namespace", ctx.Namespace},
+	}
+	for _, row := range rows {
+		if row[1] != "" {
+			_, _ = fmt.Fprintf(w, "| %s | %s |\n", row[0], markdownCell(row[1]))
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\n</details>\n\n")
+}
+
+// markdownCode wraps s in a code span, so "*" and "_" in names and rules
+// aren't read as emphasis
+func markdownCode(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// markdownCell is markdownCode for a table cell, where a pipe would
+// otherwise end the cell, even inside a code span
+func markdownCell(s string) string {
+	return markdownCode(strings.ReplaceAll(s, "|", `\|`))
+}
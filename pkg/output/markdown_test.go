@@ -0,0 +1,132 @@
+package output
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, or rewrites it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s", path, got)
+	}
+}
+
+func TestMarkdownPrinter(t *testing.T) {
+	request := rbac.PermissionRequest{Verb: "get", Resource: "pods", Namespace: "prod"}
+	tests := []struct {
+		name   string
+		result *rbac.PermissionResult
+		ctx    *ContextInfo
+	}{
+		{
+			name: "allowed",
+			result: &rbac.PermissionResult{
+				Allowed: true,
+				Subject: rbac.Subject{Kind: "ServiceAccount", Name: "api", Namespace: "prod"},
+				Request: request,
+				Grants: []rbac.PermissionGrant{
+					{
+						Binding:      rbac.BindingInfo{Kind: "RoleBinding", Name: "api-view", Namespace: "prod"},
+						Role:         rbac.RoleInfo{Kind: "ClusterRole", Name: "view"},
+						MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+						Scope:        rbac.ScopeNamespace,
+					},
+					{
+						Binding:      rbac.BindingInfo{Kind: "ClusterRoleBinding", Name: "ops-admin"},
+						Role:         rbac.RoleInfo{Kind: "ClusterRole", Name: "cluster-admin"},
+						MatchingRule: rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+						Scope:        rbac.ScopeClusterWide,
+					},
+				},
+			},
+		},
+		{
+			name: "context",
+			result: &rbac.PermissionResult{
+				Allowed: true,
+				Subject: rbac.Subject{Kind: "User", Name: "jane", Groups: []string{"team|ops"}},
+				Request: request,
+				Grants: []rbac.PermissionGrant{{
+					Binding:      rbac.BindingInfo{Kind: "ClusterRoleBinding", Name: "ops-pods"},
+					Role:         rbac.RoleInfo{Kind: "ClusterRole", Name: "pod-reader"},
+					MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"a|b"}},
+					Scope:        rbac.ScopeClusterWide,
+				}},
+			},
+			ctx: &ContextInfo{
+				ContextName: "kind-dev",
+				ClusterName: "kind-dev",
+				AuthInfo:    "kind-dev",
+				UserName:    "jane",
+				Groups:      []string{"team|ops"},
+				AuthMethod:  "client-certificate",
+				Namespace:   "prod",
+			},
+		},
+		{
+			name: "denied",
+			result: &rbac.PermissionResult{
+				Subject: rbac.Subject{Kind: "User", Name: "jane"},
+				Request: rbac.PermissionRequest{Verb: "delete", Resource: "secrets", Namespace: "prod"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (&MarkdownPrinter{}).Print(&buf, tt.result, tt.ctx); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "markdown-"+tt.name+".md", buf.Bytes())
+		})
+	}
+}
+
+func TestPrintRiskyMarkdown(t *testing.T) {
+	risks := []rbac.RiskyPermission{
+		{Category: "superuser-group", Severity: "critical", BypassedVia: "system:masters"},
+		{
+			Category:        "secrets-access",
+			Severity:        "high",
+			DefaultSeverity: "critical",
+			Grants: []rbac.PermissionGrant{{
+				Binding:      rbac.BindingInfo{Kind: "RoleBinding", Name: "read-secrets", Namespace: "prod"},
+				Role:         rbac.RoleInfo{Kind: "Role", Name: "secret-reader", Namespace: "prod"},
+				MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+			}},
+		},
+		{
+			Category: "pod-exec",
+			Severity: "high",
+			Grants: []rbac.PermissionGrant{{
+				Binding:      rbac.BindingInfo{Kind: rbac.SelfSubjectRulesReviewKind},
+				MatchingRule: rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{""}, Resources: []string{"pods/exec"}},
+			}},
+		},
+	}
+	var buf bytes.Buffer
+	PrintRiskyMarkdown(&buf, risks)
+	checkGolden(t, "markdown-risky.md", buf.Bytes())
+}
@@ -0,0 +1,86 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// NamespaceAccessOutput is the strongest access a subject has in one scope
+type NamespaceAccessOutput struct {
+	Namespace string        `json:"namespace,omitempty"`
+	Level     string        `json:"level"`
+	Summary   string        `json:"summary"`
+	Binding   BindingOutput `json:"binding"`
+	Role      RoleOutput    `json:"role"`
+	Bindings  int           `json:"bindings"`
+}
+
+// NamespacesOutput is the JSON structure for where a subject has access.
+// ClusterWide is set when a ClusterRoleBinding applies to all namespaces.
+type NamespacesOutput struct {
+	Subject     SubjectOutput           `json:"subject"`
+	ClusterWide *NamespaceAccessOutput  `json:"clusterWide,omitempty"`
+	Namespaces  []NamespaceAccessOutput `json:"namespaces"`
+}
+
+// BuildNamespacesOutput converts a namespace access report into its JSON structure
+func BuildNamespacesOutput(subject rbac.Subject, cluster *rbac.NamespaceAccess, namespaces []rbac.NamespaceAccess) NamespacesOutput {
+	out := NamespacesOutput{
+		Subject: SubjectOutput{
+			Kind:      subject.Kind,
+			Name:      subject.Name,
+			Namespace: subject.Namespace,
+			Origin:    subject.Origin,
+		},
+		Namespaces: []NamespaceAccessOutput{},
+	}
+	if cluster != nil {
+		c := buildNamespaceAccess(*cluster)
+		out.ClusterWide = &c
+	}
+	for _, a := range namespaces {
+		out.Namespaces = append(out.Namespaces, buildNamespaceAccess(a))
+	}
+	return out
+}
+
+func buildNamespaceAccess(a rbac.NamespaceAccess) NamespaceAccessOutput {
+	return NamespaceAccessOutput{
+		Namespace: a.Namespace,
+		Level:     a.Level,
+		Summary:   a.Summary(),
+		Binding:   BindingOutput{Kind: a.Binding.Kind, Name: a.Binding.Name, Namespace: a.Binding.Namespace},
+		Role:      RoleOutput{Kind: a.Role.Kind, Name: a.Role.Name, Namespace: a.Role.Namespace},
+		Bindings:  a.Bindings,
+	}
+}
+
+// PrintNamespaces outputs where a subject has access in human-readable form
+func PrintNamespaces(w io.Writer, subject rbac.Subject, cluster *rbac.NamespaceAccess, namespaces []rbac.NamespaceAccess) {
+	if cluster == nil && len(namespaces) == 0 {
+		_, _ = fmt.Fprintf(w, "%s has no access in any namespace\n", subject)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Namespaces where %s has access:\n\n", subject)
+	width := len("(all namespaces)")
+	for _, a := range namespaces {
+		width = max(width, len(a.Namespace))
+	}
+	if cluster != nil {
+		_, _ = fmt.Fprintf(w, "  %-*s  %s -> %s/%s\n", width, "(all namespaces)", cluster.Summary(), cluster.Role.Kind, cluster.Role.Name)
+	}
+	for _, a := range namespaces {
+		_, _ = fmt.Fprintf(w, "  %-*s  %s -> %s/%s\n", width, a.Namespace, a.Summary(), a.Role.Kind, a.Role.Name)
+	}
+}
+
+// PrintNamespacesJSON outputs where a subject has access as JSON
+func PrintNamespacesJSON(w io.Writer, subject rbac.Subject, cluster *rbac.NamespaceAccess, namespaces []rbac.NamespaceAccess) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildNamespacesOutput(subject, cluster, namespaces))
+}
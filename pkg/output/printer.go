@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
 )
@@ -31,20 +34,13 @@ type Printer interface {
 
 // NewPrinter creates a printer based on the output format
 func NewPrinter(format string) (Printer, error) {
-	switch format {
-	case "text", "":
-		return &TextPrinter{}, nil
-	case "json":
-		return &JSONPrinter{}, nil
-	case "yaml":
-		return &YAMLPrinter{}, nil
-	case "dot":
-		return &DotPrinter{}, nil
-	case "mermaid":
-		return &MermaidPrinter{}, nil
-	default:
-		return nil, fmt.Errorf("unknown output format: %s", format)
-	}
+	return NewPrinterWithOptions(format, PrinterOptions{})
+}
+
+func init() {
+	Register("text", func(PrinterOptions) Printer { return &TextPrinter{} })
+	Register("json", func(PrinterOptions) Printer { return &JSONPrinter{} })
+	Register("yaml", func(PrinterOptions) Printer { return &YAMLPrinter{} })
 }
 
 // TextPrinter outputs human-readable text
@@ -70,7 +66,39 @@ func (p *TextPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *Con
 		_, _ = fmt.Fprintln(w)
 	}
 
-	if !result.Allowed {
+	// Show the expanded subject when it was derived from a shorthand
+	if result.Subject.Origin != "" {
+		_, _ = fmt.Fprintf(w, "Subject: %s (from %s)\n\n", result.Subject.Canonical(), result.Subject.Origin)
+	}
+
+	// Explicit groups, e.g. from --as-group, change what the subject can do;
+	// the context header already lists them when there is one
+	if showEffectiveGroups(result.Subject, ctx) {
+		_, _ = fmt.Fprintf(w, "Effective groups: %s\n\n", formatEffectiveGroups(result.Subject))
+	}
+
+	// RBAC ignores the UID, but --verify passes it on
+	if result.Subject.UID != "" {
+		_, _ = fmt.Fprintf(w, "UID: %s (not used by RBAC; sent with --verify)\n\n", result.Subject.UID)
+	}
+	if len(result.Subject.Extra) > 0 {
+		_, _ = fmt.Fprintf(w, "User extra: %s (not used by RBAC; sent with --verify)\n\n", formatExtra(result.Subject.Extra))
+	}
+
+	if len(result.Subject.GroupMappings) > 0 {
+		_, _ = fmt.Fprintf(w, "Groups from --groups-file:\n")
+		for _, m := range result.Subject.GroupMappings {
+			_, _ = fmt.Fprintf(w, "  %s (%s): %s\n", m.Source, m.Pattern, strings.Join(m.Groups, ", "))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if result.Mode == rbac.ModeSelfAccessReview {
+		printSelfAccessReview(w, result)
+		return nil
+	}
+
+	if !result.Allowed && !result.PartiallyAllowed {
 		_, _ = fmt.Fprintf(w, "DENIED: No RBAC rules grant %s %s to %s\n",
 			result.Request.Verb,
 			formatResource(result.Request),
@@ -78,30 +106,71 @@ func (p *TextPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *Con
 		if result.Request.Namespace != "" {
 			_, _ = fmt.Fprintf(w, "Namespace: %s\n", result.Request.Namespace)
 		}
+		PrintDanglingBindings(w, result.DanglingBindings)
+		PrintDiagnostics(w, result.Diagnostics)
 		return nil
 	}
 
-	_, _ = fmt.Fprintf(w, "ALLOWED: %s can %s %s",
-		result.Subject.String(),
-		result.Request.Verb,
-		formatResource(result.Request))
-	if result.Request.Namespace != "" {
-		_, _ = fmt.Fprintf(w, " in namespace %s", result.Request.Namespace)
+	if result.PartiallyAllowed {
+		_, _ = fmt.Fprintf(w, "ALLOWED only for: %s\n", strings.Join(result.AllowedNames(), ", "))
+		_, _ = fmt.Fprintf(w, "%s can %s %s", result.Subject.String(), result.Request.Verb, formatResource(result.Request))
+		if result.Request.Namespace != "" {
+			_, _ = fmt.Fprintf(w, " in namespace %s", result.Request.Namespace)
+		}
+		_, _ = fmt.Fprintf(w, " only by those names; every rule is restricted to resourceNames, so a request for any other object, or for all of them, is denied.\n\n")
+	} else {
+		_, _ = fmt.Fprintf(w, "ALLOWED: %s can %s %s",
+			result.Subject.String(),
+			result.Request.Verb,
+			formatResource(result.Request))
+		if result.Request.Namespace != "" {
+			_, _ = fmt.Fprintf(w, " in namespace %s", result.Request.Namespace)
+		}
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(w)
+	}
+
+	// system:masters never reaches RBAC, so there is no chain to show
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "Authorization bypassed via %s:\n", result.BypassedVia)
+		_, _ = fmt.Fprintf(w, "  Members of this group are allowed every request by the API server\n")
+		_, _ = fmt.Fprintf(w, "  without consulting RBAC, so no binding chain is involved.\n")
+		return nil
+	}
+
+	// Lead with a one-line summary when the subject is simply a superuser
+	if result.OnlyViaSuperuser() {
+		for _, grant := range result.SuperuserGrants() {
+			_, _ = fmt.Fprintf(w, "Superuser via %s %s -> %s", grant.Binding.Kind, grant.Binding.Name, grant.Role.Name)
+			if grant.Binding.Namespace != "" {
+				_, _ = fmt.Fprintf(w, " (namespace %s only)", grant.Binding.Namespace)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintln(w)
 	}
-	_, _ = fmt.Fprintln(w)
-	_, _ = fmt.Fprintln(w)
 
 	_, _ = fmt.Fprintf(w, "Permission granted through %d path(s):\n\n", len(result.Grants))
+	if result.OnlyViaWildcards() && !result.OnlyViaSuperuser() {
+		_, _ = fmt.Fprintf(w, "Every path matches only through a wildcard rule; a narrower rule would do.\n\n")
+	}
 
 	for i, grant := range result.Grants {
 		_, _ = fmt.Fprintf(w, "Path %d:\n", i+1)
 		_, _ = fmt.Fprintf(w, "  Subject: %s\n", result.Subject.String())
 		_, _ = fmt.Fprintf(w, "      |\n")
-		_, _ = fmt.Fprintf(w, "      v\n")
+		if grant.ViaGroup(result.Subject) {
+			_, _ = fmt.Fprintf(w, "      v  (matched via %s)\n", grant.MatchedVia())
+		} else {
+			_, _ = fmt.Fprintf(w, "      v\n")
+		}
 		_, _ = fmt.Fprintf(w, "  %s: %s", grant.Binding.Kind, grant.Binding.Name)
 		if grant.Binding.Namespace != "" {
 			_, _ = fmt.Fprintf(w, " (namespace: %s)", grant.Binding.Namespace)
 		}
+		if grant.MatchedSubject != nil {
+			_, _ = fmt.Fprintf(w, " subjects[%d]", grant.SubjectIndex)
+		}
 		_, _ = fmt.Fprintf(w, "\n")
 		_, _ = fmt.Fprintf(w, "      |\n")
 		_, _ = fmt.Fprintf(w, "      v\n")
@@ -109,17 +178,158 @@ func (p *TextPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *Con
 		if grant.Role.Namespace != "" {
 			_, _ = fmt.Fprintf(w, " (namespace: %s)", grant.Role.Namespace)
 		}
+		if grant.Superuser() {
+			_, _ = fmt.Fprintf(w, " (superuser)")
+		}
 		_, _ = fmt.Fprintf(w, "\n")
+		if src := grant.AggregatedFrom; src != nil {
+			_, _ = fmt.Fprintf(w, "      |\n")
+			if src.Computed {
+				_, _ = fmt.Fprintf(w, "      v  (aggregated from, computed locally)\n")
+			} else {
+				_, _ = fmt.Fprintf(w, "      v  (aggregated from)\n")
+			}
+			_, _ = fmt.Fprintf(w, "  ClusterRole: %s%s\n", src.Name, formatMatchedLabels(src.MatchedLabels))
+		}
 		_, _ = fmt.Fprintf(w, "      |\n")
 		_, _ = fmt.Fprintf(w, "      v\n")
-		_, _ = fmt.Fprintf(w, "  Rule: %s\n", formatRule(grant.MatchingRule))
-		_, _ = fmt.Fprintf(w, "  Scope: %s\n\n", grant.Scope)
+		_, _ = fmt.Fprintf(w, "  Rule: rules[%d] %s\n", grant.RuleIndex, FormatRule(grant.MatchingRule))
+		for _, also := range grant.AlsoMatchedRules {
+			_, _ = fmt.Fprintf(w, "  Also matched: rules[%d] %s\n", also.Index, FormatRule(also.Rule))
+		}
+		if len(grant.Wildcards) > 0 {
+			_, _ = fmt.Fprintf(w, "  Note: %s\n", formatWildcards(grant.Wildcards))
+		}
+		if grant.NarrowedRule != nil {
+			_, _ = fmt.Fprintf(w, "  Least-privilege rule: %s\n", FormatRule(*grant.NarrowedRule))
+		}
+		if grant.Conditional {
+			_, _ = fmt.Fprintf(w, "  Condition: only for resourceNames [%s]\n", strings.Join(grant.MatchingRule.ResourceNames, ", "))
+		}
+		switch grant.NameMatch(result.Request) {
+		case rbac.NameMatchExplicit:
+			_, _ = fmt.Fprintf(w, "  Name: %s is listed in resourceNames\n", result.Request.ResourceName)
+		case rbac.NameMatchAny:
+			_, _ = fmt.Fprintf(w, "  Name: rule has no resourceNames, so it covers every %s, not only %s\n", result.Request.FullResource(), result.Request.ResourceName)
+		}
+		if source := grant.RuleSource(); source != "" {
+			_, _ = fmt.Fprintf(w, "  Rule defined at %s\n", source)
+		}
+		if grant.EffectiveNamespace != "" {
+			_, _ = fmt.Fprintf(w, "  Scope: %s (effective only in namespace %s)\n", grant.Scope, grant.EffectiveNamespace)
+		} else {
+			_, _ = fmt.Fprintf(w, "  Scope: %s\n", grant.Scope)
+		}
+		if note := grant.ScopeNote(); note != "" {
+			_, _ = fmt.Fprintf(w, "  Note: %s\n", note)
+		}
+		if owner := grant.Owner(); owner != nil {
+			_, _ = fmt.Fprintf(w, "  Owned by: %s\n", owner)
+		}
+		_, _ = fmt.Fprintln(w)
 	}
 
+	PrintDanglingBindings(w, result.DanglingBindings)
+	PrintDiagnostics(w, result.Diagnostics)
 	return nil
 }
 
+// PrintDanglingBindings lists the bindings that grant nothing because their
+// role is missing, which may be why an expected permission isn't there
+func PrintDanglingBindings(w io.Writer, dangling []rbac.DanglingBinding) {
+	if len(dangling) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nWarnings:\n")
+	for _, d := range dangling {
+		_, _ = fmt.Fprintf(w, "  %s\n", d)
+		_, _ = fmt.Fprintf(w, "    Hint: %s\n", d.Diagnostic().Advice())
+	}
+}
+
+// PrintDiagnostics lists the errors met while resolving a result, which may
+// have left it incomplete, with advice on fixing each
+func PrintDiagnostics(w io.Writer, diagnostics []rbac.Diagnostic) {
+	errs := rbac.ErrorDiagnostics(diagnostics)
+	if len(errs) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nErrors (the result may be incomplete):\n")
+	for _, d := range errs {
+		printDiagnostic(w, "  ", d)
+	}
+}
+
+// printDiagnostic prints a diagnostic's message after prefix, and its advice
+// indented below it
+func printDiagnostic(w io.Writer, prefix string, d rbac.Diagnostic) {
+	_, _ = fmt.Fprintf(w, "%s%s\n", prefix, d.Message)
+	if advice := d.Advice(); advice != "" {
+		_, _ = fmt.Fprintf(w, "%s  Hint: %s\n", strings.Repeat(" ", len(prefix)), advice)
+	}
+}
+
+// showEffectiveGroups reports whether the groups in effect need listing:
+// they include groups the context header doesn't show, or the implicit
+// groups were left out
+func showEffectiveGroups(subject rbac.Subject, ctx *ContextInfo) bool {
+	if subject.ExactGroups {
+		return true
+	}
+	if ctx == nil {
+		return len(subject.Groups) > 0
+	}
+	return !slices.Equal(subject.Groups, ctx.Groups)
+}
+
+func formatEffectiveGroups(subject rbac.Subject) string {
+	groups := "none"
+	if effective := rbac.GetImplicitGroups(subject); len(effective) > 0 {
+		groups = strings.Join(effective, ", ")
+	}
+	if subject.ExactGroups {
+		groups += " (no implicit groups)"
+	}
+	return groups
+}
+
+// formatExtra lists user extra attributes sorted by key, e.g.
+// "reason=incident, scopes=read,write"
+func formatExtra(extra map[string][]string) string {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + strings.Join(extra[key], ",")
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// printSelfAccessReview outputs a result the API server decided without the
+// grant chain, saying why the chain is missing
+func printSelfAccessReview(w io.Writer, result *rbac.PermissionResult) {
+	verdict, verb := "DENIED", "cannot"
+	if result.Allowed {
+		verdict, verb = "ALLOWED", "can"
+	}
+	_, _ = fmt.Fprintf(w, "%s: %s %s %s %s", verdict, result.Subject.String(), verb, result.Request.Verb, formatResource(result.Request))
+	if result.Request.Namespace != "" {
+		_, _ = fmt.Fprintf(w, " in namespace %s", result.Request.Namespace)
+	}
+	_, _ = fmt.Fprintf(w, " (according to the API server)\n\n")
+	_, _ = fmt.Fprintf(w, "Why chain unavailable: %s.\n", result.Limitation)
+	if result.ServerReason != "" {
+		_, _ = fmt.Fprintf(w, "API server reason: %s\n", result.ServerReason)
+	}
+}
+
 func formatResource(request rbac.PermissionRequest) string {
+	if request.NonResourceURL != "" {
+		return request.NonResourceURL
+	}
 	resource := request.Resource
 	if request.Subresource != "" {
 		resource = resource + "/" + request.Subresource
@@ -133,7 +343,22 @@ func formatResource(request rbac.PermissionRequest) string {
 	return resource
 }
 
-func formatRule(rule rbacv1.PolicyRule) string {
+// formatMatchedLabels lists the labels an aggregation selector matched, e.g.
+// " (selected by rbac.authorization.k8s.io/aggregate-to-admin=true)"
+func formatMatchedLabels(matched map[string]string) string {
+	if len(matched) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(matched))
+	for k, v := range matched {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return " (selected by " + strings.Join(pairs, ", ") + ")"
+}
+
+// FormatRule returns a one-line summary of a PolicyRule
+func FormatRule(rule rbacv1.PolicyRule) string {
 	var parts []string
 
 	if len(rule.APIGroups) > 0 {
@@ -152,6 +377,9 @@ func formatRule(rule rbacv1.PolicyRule) string {
 	if len(rule.ResourceNames) > 0 {
 		parts = append(parts, fmt.Sprintf("resourceNames=%v", rule.ResourceNames))
 	}
+	if len(rule.NonResourceURLs) > 0 {
+		parts = append(parts, fmt.Sprintf("nonResourceURLs=%v", rule.NonResourceURLs))
+	}
 
 	return strings.Join(parts, ", ")
 }
@@ -174,13 +402,112 @@ type JSONOutput struct {
 	Subject SubjectOutput  `json:"subject"`
 	Request RequestOutput  `json:"request"`
 	Grants  []GrantOutput  `json:"grants,omitempty"`
-	Errors  []string       `json:"errors,omitempty"`
+
+	// Diagnostics are the problems met while resolving the result
+	Diagnostics []DiagnosticOutput `json:"diagnostics,omitempty"`
+	// Errors are the messages of the error diagnostics.
+	// Deprecated: use Diagnostics; errors will be removed in the next release.
+	Errors []string `json:"errors,omitempty"`
+
+	BypassedVia string `json:"bypassedVia,omitempty"`
+
+	// PartiallyAllowed is set, with allowed false, when the request is
+	// allowed only for the objects in AllowedNames
+	PartiallyAllowed bool     `json:"partiallyAllowed,omitempty"`
+	AllowedNames     []string `json:"allowedNames,omitempty"`
+
+	// DenialReasons explains a denied result with stable, machine-readable codes
+	DenialReasons []DenialReasonOutput `json:"denialReasons,omitempty"`
+
+	// DanglingBindings are bindings that apply to the subject but refer to
+	// a missing role
+	DanglingBindings []DanglingBindingOutput `json:"danglingBindings,omitempty"`
+
+	// Trace is every binding examined, with --trace
+	Trace []TraceBindingOutput `json:"trace,omitempty"`
+
+	// SubjectAccessReview echoes the input review with its status filled in
+	SubjectAccessReview map[string]interface{} `json:"subjectAccessReview,omitempty"`
+
+	// Verification is the API server's decision for the same request, with --verify
+	Verification *VerificationOutput `json:"verification,omitempty"`
+
+	// Suggestion is the Role and binding that would grant a denied request,
+	// with --suggest
+	Suggestion []map[string]interface{} `json:"suggestion,omitempty"`
+
+	// Commands are the kubectl commands that reproduce the check, with
+	// --show-commands
+	Commands []string `json:"commands,omitempty"`
+
+	// Warnings are caveats about the result, such as an unknown resource
+	Warnings []WarningOutput `json:"warnings,omitempty"`
+
+	// Mode is "resolved" when the grant chain was read from the RBAC objects,
+	// or "self-access-review" when only the API server's answer is known
+	Mode         string `json:"mode"`
+	Limitation   string `json:"limitation,omitempty"`
+	ServerReason string `json:"serverReason,omitempty"`
+}
+
+// DiagnosticOutput is a problem met while resolving a result; Code is one of
+// the rbac Diagnostic codes, e.g. RoleForbidden, and Category one of the rbac
+// error categories, e.g. forbidden or not-found
+type DiagnosticOutput struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Category string `json:"category,omitempty"`
+	Object   string `json:"object,omitempty"`
+	Message  string `json:"message"`
+}
+
+// buildDiagnosticsOutput converts diagnostics into their JSON structure, and
+// the messages of the errors among them for the deprecated errors field
+func buildDiagnosticsOutput(diagnostics []rbac.Diagnostic) ([]DiagnosticOutput, []string) {
+	var out []DiagnosticOutput
+	var errs []string
+	for _, d := range diagnostics {
+		out = append(out, DiagnosticOutput{Code: d.Code, Severity: d.Severity, Category: d.Category, Object: d.Object, Message: d.Message})
+		if d.Severity == rbac.DiagnosticError {
+			errs = append(errs, d.Message)
+		}
+	}
+	return out, errs
+}
+
+// WarningOutput is a caveat about the result; Code is one of the rbac
+// Warning codes, e.g. UnknownResource or NodeAuthorizer
+type WarningOutput struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 type SubjectOutput struct {
 	Kind      string `json:"kind"`
 	Name      string `json:"name"`
 	Namespace string `json:"namespace,omitempty"`
+	Origin    string `json:"origin,omitempty"`
+	// UID is the --as-uid value, which RBAC matching ignores
+	UID string `json:"uid,omitempty"`
+	// Extra holds the --as-user-extra values, which RBAC matching ignores
+	Extra map[string][]string `json:"extra,omitempty"`
+
+	// Groups are the effective groups, implicit ones included, when the
+	// subject has explicit groups
+	Groups []string `json:"groups,omitempty"`
+	// ExactGroups means Groups is the complete list, without implicit groups;
+	// no groups were in effect when it's empty
+	ExactGroups bool `json:"exactGroups,omitempty"`
+
+	// GroupMappings are the --groups-file entries that matched the subject
+	GroupMappings []GroupMappingOutput `json:"groupMappings,omitempty"`
+}
+
+// GroupMappingOutput is a --groups-file entry that added groups to the subject
+type GroupMappingOutput struct {
+	Source  string   `json:"source"`
+	Pattern string   `json:"pattern"`
+	Groups  []string `json:"groups"`
 }
 
 type RequestOutput struct {
@@ -190,6 +517,8 @@ type RequestOutput struct {
 	Subresource  string `json:"subresource,omitempty"`
 	ResourceName string `json:"resourceName,omitempty"`
 	Namespace    string `json:"namespace,omitempty"`
+
+	NonResourceURL string `json:"nonResourceURL,omitempty"`
 }
 
 type GrantOutput struct {
@@ -197,6 +526,113 @@ type GrantOutput struct {
 	Role         RoleOutput    `json:"role"`
 	MatchingRule RuleOutput    `json:"matchingRule"`
 	Scope        string        `json:"scope"`
+	OwnedBy      *OwnerOutput  `json:"ownedBy,omitempty"`
+
+	// EffectiveNamespace is the namespace a RoleBinding grant is confined to
+	EffectiveNamespace string `json:"effectiveNamespace,omitempty"`
+	// ScopeNote explains that a RoleBinding to a ClusterRole confers only
+	// its rules for namespaced resources
+	ScopeNote string `json:"scopeNote,omitempty"`
+
+	// Source is where the matching rule is defined, for roles read from local manifests
+	Source *RuleSourceOutput `json:"source,omitempty"`
+
+	// AggregatedFrom is the ClusterRole the rule was aggregated from
+	AggregatedFrom *AggregationSourceOutput `json:"aggregatedFrom,omitempty"`
+
+	// Wildcards are the parts of the rule that match only through a wildcard:
+	// verb, apiGroup, resource, or nonResourceURL
+	Wildcards []string `json:"wildcards,omitempty"`
+	// NarrowedRule is the least-privilege rule that would still grant the
+	// request, with --suggest-least-privilege
+	NarrowedRule *RuleOutput `json:"narrowedRule,omitempty"`
+	// Commands are the kubectl commands that show the binding and role,
+	// with --show-commands
+	Commands []string `json:"commands,omitempty"`
+
+	// NameMatch is "explicit" when the rule lists the requested object name
+	// in resourceNames and "any" when it covers every name
+	NameMatch string `json:"nameMatch,omitempty"`
+
+	// Superuser is set when the grant comes from the cluster-admin ClusterRole
+	Superuser bool `json:"superuser,omitempty"`
+
+	// AlsoMatchedRules are the role's other rules that match the request
+	AlsoMatchedRules []IndexedRuleOutput `json:"alsoMatchedRules,omitempty"`
+
+	// Conditional is set when the rule covers the request only for the
+	// names in its resourceNames
+	Conditional bool `json:"conditional,omitempty"`
+
+	// MatchedSubject is the binding's subject entry that applies, e.g. a
+	// Group the subject is in rather than the subject itself
+	MatchedSubject *MatchedSubjectOutput `json:"matchedSubject,omitempty"`
+
+	// RuleIndex is the position of the matching rule in the role's rules,
+	// and SubjectIndex that of the matched subject in the binding's subjects
+	RuleIndex    int  `json:"ruleIndex"`
+	SubjectIndex *int `json:"subjectIndex,omitempty"`
+}
+
+// IndexedRuleOutput is a rule of a role with its position in the role's rules
+type IndexedRuleOutput struct {
+	Index int        `json:"index"`
+	Rule  RuleOutput `json:"rule"`
+}
+
+// DanglingBindingOutput is a binding whose roleRef names a missing role
+type DanglingBindingOutput struct {
+	Binding BindingOutput `json:"binding"`
+	RoleRef RoleRefOutput `json:"roleRef"`
+}
+
+// RoleRefOutput is the roleRef of a binding
+type RoleRefOutput struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// buildDanglingBindingsOutput converts dangling bindings into their JSON structure
+func buildDanglingBindingsOutput(dangling []rbac.DanglingBinding) []DanglingBindingOutput {
+	var out []DanglingBindingOutput
+	for _, d := range dangling {
+		out = append(out, DanglingBindingOutput{
+			Binding: BindingOutput{Kind: d.Binding.Kind, Name: d.Binding.Name, Namespace: d.Binding.Namespace},
+			RoleRef: RoleRefOutput{Kind: d.RoleRef.Kind, Name: d.RoleRef.Name},
+		})
+	}
+	return out
+}
+
+// MatchedSubjectOutput is a subject entry of a binding
+type MatchedSubjectOutput struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// AggregationSourceOutput is the ClusterRole that contributed a rule to an
+// aggregated ClusterRole
+type AggregationSourceOutput struct {
+	Name          string            `json:"name"`
+	MatchedLabels map[string]string `json:"matchedLabels,omitempty"`
+	// Computed is set when the aggregation was computed from local manifests
+	// or a snapshot rather than by the cluster
+	Computed bool `json:"computed,omitempty"`
+}
+
+// RuleSourceOutput locates a rule within a local manifest file
+type RuleSourceOutput struct {
+	File     string `json:"file"`
+	Document int    `json:"document"`
+	Rule     int    `json:"rule"`
+}
+
+// OwnerOutput is the release or application that manages a grant
+type OwnerOutput struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type BindingOutput struct {
@@ -211,33 +647,56 @@ type RoleOutput struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// DenialReasonOutput is one reason a request was denied, with the closest
+// related binding and role when there is one
+type DenialReasonOutput struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Binding *BindingOutput `json:"binding,omitempty"`
+	Role    *RoleOutput    `json:"role,omitempty"`
+}
+
 type RuleOutput struct {
 	Verbs         []string `json:"verbs"`
 	APIGroups     []string `json:"apiGroups"`
 	Resources     []string `json:"resources"`
 	ResourceNames []string `json:"resourceNames,omitempty"`
-}
 
-// JSONPrinter outputs JSON format
-type JSONPrinter struct{}
+	NonResourceURLs []string `json:"nonResourceURLs,omitempty"`
+}
 
-func (p *JSONPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+// BuildJSONOutput converts a result into the structure shared by the JSON
+// and YAML printers
+func BuildJSONOutput(result *rbac.PermissionResult, ctx *ContextInfo) JSONOutput {
 	output := JSONOutput{
 		Allowed: result.Allowed,
 		Subject: SubjectOutput{
 			Kind:      result.Subject.Kind,
 			Name:      result.Subject.Name,
 			Namespace: result.Subject.Namespace,
+			Origin:    result.Subject.Origin,
+			UID:       result.Subject.UID,
+			Extra:     result.Subject.Extra,
 		},
-		Request: RequestOutput{
-			Verb:         result.Request.Verb,
-			APIGroup:     result.Request.APIGroup,
-			Resource:     result.Request.Resource,
-			Subresource:  result.Request.Subresource,
-			ResourceName: result.Request.ResourceName,
-			Namespace:    result.Request.Namespace,
-		},
-		Grants: make([]GrantOutput, 0, len(result.Grants)),
+		Request:     buildRequestOutput(result.Request),
+		Grants:      make([]GrantOutput, 0, len(result.Grants)),
+		BypassedVia: result.BypassedVia,
+
+		PartiallyAllowed: result.PartiallyAllowed,
+
+		Mode:         result.Mode,
+		Limitation:   result.Limitation,
+		ServerReason: result.ServerReason,
+	}
+	if output.Mode == "" {
+		output.Mode = rbac.ModeResolved
+	}
+
+	if result.Review != nil {
+		// Unstructured keeps the API field names in both JSON and YAML output
+		if review, err := runtime.DefaultUnstructuredConverter.ToUnstructured(result.Review); err == nil {
+			output.SubjectAccessReview = review
+		}
 	}
 
 	// Include context info if --as was not provided
@@ -253,99 +712,138 @@ func (p *JSONPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *Con
 		}
 	}
 
+	if result.PartiallyAllowed {
+		output.AllowedNames = result.AllowedNames()
+	}
 	for _, grant := range result.Grants {
-		grantOutput := GrantOutput{
-			Binding: BindingOutput{
-				Kind:      grant.Binding.Kind,
-				Name:      grant.Binding.Name,
-				Namespace: grant.Binding.Namespace,
-			},
-			Role: RoleOutput{
-				Kind:      grant.Role.Kind,
-				Name:      grant.Role.Name,
-				Namespace: grant.Role.Namespace,
-			},
-			MatchingRule: RuleOutput{
-				Verbs:         grant.MatchingRule.Verbs,
-				APIGroups:     grant.MatchingRule.APIGroups,
-				Resources:     grant.MatchingRule.Resources,
-				ResourceNames: grant.MatchingRule.ResourceNames,
-			},
-			Scope: string(grant.Scope),
-		}
+		grantOutput := buildGrantOutput(grant)
+		grantOutput.NameMatch = grant.NameMatch(result.Request)
 		output.Grants = append(output.Grants, grantOutput)
 	}
 
-	for _, err := range result.Errors {
-		output.Errors = append(output.Errors, err.Error())
+	if len(result.Subject.Groups) > 0 {
+		output.Subject.Groups = rbac.GetImplicitGroups(result.Subject)
 	}
+	output.Subject.ExactGroups = result.Subject.ExactGroups
 
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(output)
+	for _, m := range result.Subject.GroupMappings {
+		output.Subject.GroupMappings = append(output.Subject.GroupMappings, GroupMappingOutput{Source: m.Source, Pattern: m.Pattern, Groups: m.Groups})
+	}
+
+	output.Diagnostics, output.Errors = buildDiagnosticsOutput(result.Diagnostics)
+
+	for _, reason := range result.DenialReasons {
+		reasonOutput := DenialReasonOutput{Code: reason.Code, Message: reason.Message}
+		if reason.Binding != nil {
+			reasonOutput.Binding = &BindingOutput{Kind: reason.Binding.Kind, Name: reason.Binding.Name, Namespace: reason.Binding.Namespace}
+		}
+		if reason.Role != nil {
+			reasonOutput.Role = &RoleOutput{Kind: reason.Role.Kind, Name: reason.Role.Name, Namespace: reason.Role.Namespace}
+		}
+		output.DenialReasons = append(output.DenialReasons, reasonOutput)
+	}
+
+	output.DanglingBindings = buildDanglingBindingsOutput(result.DanglingBindings)
+	output.Trace = BuildTraceOutput(result.Trace)
+	output.Verification = BuildVerificationOutput(result)
+	output.Suggestion = BuildSuggestionOutput(result.Suggestion)
+	output.Commands = result.Commands
+	for _, w := range result.Warnings {
+		output.Warnings = append(output.Warnings, WarningOutput{Code: w.Code, Message: w.Message})
+	}
+
+	return output
 }
 
-// YAMLPrinter outputs YAML format
-type YAMLPrinter struct{}
+// buildRequestOutput converts a permission request into its JSON structure
+func buildRequestOutput(request rbac.PermissionRequest) RequestOutput {
+	return RequestOutput{
+		Verb:         request.Verb,
+		APIGroup:     request.APIGroup,
+		Resource:     request.Resource,
+		Subresource:  request.Subresource,
+		ResourceName: request.ResourceName,
+		Namespace:    request.Namespace,
+
+		NonResourceURL: request.NonResourceURL,
+	}
+}
 
-func (p *YAMLPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
-	output := JSONOutput{
-		Allowed: result.Allowed,
-		Subject: SubjectOutput{
-			Kind:      result.Subject.Kind,
-			Name:      result.Subject.Name,
-			Namespace: result.Subject.Namespace,
+// buildGrantOutput converts one grant into its JSON structure
+func buildGrantOutput(grant rbac.PermissionGrant) GrantOutput {
+	grantOutput := GrantOutput{
+		Binding: BindingOutput{
+			Kind:      grant.Binding.Kind,
+			Name:      grant.Binding.Name,
+			Namespace: grant.Binding.Namespace,
 		},
-		Request: RequestOutput{
-			Verb:         result.Request.Verb,
-			APIGroup:     result.Request.APIGroup,
-			Resource:     result.Request.Resource,
-			Subresource:  result.Request.Subresource,
-			ResourceName: result.Request.ResourceName,
-			Namespace:    result.Request.Namespace,
+		Role: RoleOutput{
+			Kind:      grant.Role.Kind,
+			Name:      grant.Role.Name,
+			Namespace: grant.Role.Namespace,
 		},
-		Grants: make([]GrantOutput, 0, len(result.Grants)),
+		MatchingRule: buildRuleOutput(grant.MatchingRule),
+		Scope:        string(grant.Scope),
+		Superuser:    grant.Superuser(),
+		Conditional:  grant.Conditional,
+		RuleIndex:    grant.RuleIndex,
+
+		EffectiveNamespace: grant.EffectiveNamespace,
+		ScopeNote:          grant.ScopeNote(),
 	}
-
-	// Include context info if --as was not provided
-	if ctx != nil {
-		output.Context = &ContextOutput{
-			ContextName: ctx.ContextName,
-			ClusterName: ctx.ClusterName,
-			AuthInfo:    ctx.AuthInfo,
-			UserName:    ctx.UserName,
-			Groups:      ctx.Groups,
-			AuthMethod:  ctx.AuthMethod,
-			Namespace:   ctx.Namespace,
-		}
+	if owner := grant.Owner(); owner != nil {
+		grantOutput.OwnedBy = &OwnerOutput{Kind: owner.Kind, Name: owner.Name, Namespace: owner.Namespace}
 	}
-
-	for _, grant := range result.Grants {
-		grantOutput := GrantOutput{
-			Binding: BindingOutput{
-				Kind:      grant.Binding.Kind,
-				Name:      grant.Binding.Name,
-				Namespace: grant.Binding.Namespace,
-			},
-			Role: RoleOutput{
-				Kind:      grant.Role.Kind,
-				Name:      grant.Role.Name,
-				Namespace: grant.Role.Namespace,
-			},
-			MatchingRule: RuleOutput{
-				Verbs:         grant.MatchingRule.Verbs,
-				APIGroups:     grant.MatchingRule.APIGroups,
-				Resources:     grant.MatchingRule.Resources,
-				ResourceNames: grant.MatchingRule.ResourceNames,
-			},
-			Scope: string(grant.Scope),
-		}
-		output.Grants = append(output.Grants, grantOutput)
+	if m := grant.MatchedSubject; m != nil {
+		grantOutput.MatchedSubject = &MatchedSubjectOutput{Kind: m.Kind, Name: m.Name, Namespace: m.Namespace}
+		grantOutput.SubjectIndex = &grant.SubjectIndex
+	}
+	if source, rule := grant.RuleLocation(); source != nil {
+		grantOutput.Source = &RuleSourceOutput{File: source.File, Document: source.Document, Rule: rule}
+	}
+	if src := grant.AggregatedFrom; src != nil {
+		grantOutput.AggregatedFrom = &AggregationSourceOutput{Name: src.Name, MatchedLabels: src.MatchedLabels, Computed: src.Computed}
+	}
+	grantOutput.Wildcards = grant.Wildcards
+	if rule := grant.NarrowedRule; rule != nil {
+		narrowed := buildRuleOutput(*rule)
+		grantOutput.NarrowedRule = &narrowed
 	}
+	grantOutput.Commands = grant.Commands
+	for _, also := range grant.AlsoMatchedRules {
+		grantOutput.AlsoMatchedRules = append(grantOutput.AlsoMatchedRules, IndexedRuleOutput{Index: also.Index, Rule: buildRuleOutput(also.Rule)})
+	}
+	return grantOutput
+}
+
+// buildRuleOutput converts a policy rule into its JSON structure
+func buildRuleOutput(rule rbacv1.PolicyRule) RuleOutput {
+	return RuleOutput{
+		Verbs:         rule.Verbs,
+		APIGroups:     rule.APIGroups,
+		Resources:     rule.Resources,
+		ResourceNames: rule.ResourceNames,
 
-	for _, err := range result.Errors {
-		output.Errors = append(output.Errors, err.Error())
+		NonResourceURLs: rule.NonResourceURLs,
 	}
+}
+
+// JSONPrinter outputs JSON format
+type JSONPrinter struct{}
+
+func (p *JSONPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	output := BuildJSONOutput(result, ctx)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// YAMLPrinter outputs YAML format
+type YAMLPrinter struct{}
+
+func (p *YAMLPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	output := BuildJSONOutput(result, ctx)
 
 	encoder := yaml.NewEncoder(w)
 	encoder.SetIndent(2)
@@ -0,0 +1,73 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PrinterOptions configures a Printer created from the registry
+type PrinterOptions struct {
+	// Format is the name the printer was requested under
+	Format string
+	// NoHeaders leaves the header row out of tabular formats such as csv
+	NoHeaders bool
+	// Template is the template text for go-template and go-template-file
+	Template string
+}
+
+// PrinterFactory creates a Printer for a registered output format
+type PrinterFactory func(opts PrinterOptions) Printer
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]PrinterFactory)
+)
+
+// Register makes an output format available to NewPrinter and the -o flag.
+// Built-in printers register themselves from init functions; callers embedding
+// the tool can register bespoke formats the same way before building the command.
+// Registering an existing name replaces its factory.
+func Register(name string, factory PrinterFactory) {
+	if name == "" || factory == nil {
+		panic("output: Register requires a name and a factory")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// IsRegistered reports whether an output format has a registered printer
+func IsRegistered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// Formats returns the registered output format names in sorted order
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPrinterWithOptions creates a printer for a registered output format
+func NewPrinterWithOptions(format string, opts PrinterOptions) (Printer, error) {
+	if format == "" {
+		format = "text"
+	}
+	registryMu.RLock()
+	factory, ok := registry[format]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+	opts.Format = format
+	return factory(opts), nil
+}
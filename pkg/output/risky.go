@@ -3,6 +3,7 @@ package output
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	rbacv1 "k8s.io/api/rbac/v1"
 
@@ -12,7 +13,7 @@ import (
 // RiskyPattern defines a dangerous permission pattern
 type RiskyPattern struct {
 	Category    string
-	Severity    string // critical, high, medium
+	Severity    string // critical, high, medium, low
 	Description string
 	Verbs       []string
 	APIGroups   []string
@@ -101,6 +102,22 @@ var RiskyPatterns = []RiskyPattern{
 		APIGroups:   []string{"rbac.authorization.k8s.io", "*"},
 		Resources:   []string{"rolebindings", "clusterrolebindings", "*"},
 	},
+	{
+		Category:    "escalate-verb",
+		Severity:    "critical",
+		Description: "The escalate verb lifts escalation prevention, so roles can be given permissions the subject doesn't hold",
+		Verbs:       []string{"escalate"},
+		APIGroups:   []string{"rbac.authorization.k8s.io"},
+		Resources:   []string{"roles", "clusterroles"},
+	},
+	{
+		Category:    "bind-verb",
+		Severity:    "critical",
+		Description: "The bind verb lifts escalation prevention, so roles the subject doesn't hold can be bound, including to itself",
+		Verbs:       []string{"bind"},
+		APIGroups:   []string{"rbac.authorization.k8s.io"},
+		Resources:   []string{"roles", "clusterroles"},
+	},
 	{
 		Category:    "csr-approve",
 		Severity:    "high",
@@ -119,22 +136,72 @@ var RiskyPatterns = []RiskyPattern{
 	},
 }
 
+// SeverityOverrides maps built-in risky categories to a replacement severity
+type SeverityOverrides map[string]string
+
+// Validate checks that every override names a known category and severity
+func (o SeverityOverrides) Validate() error {
+	for category, severity := range o {
+		known := false
+		for _, pattern := range RiskyPatterns {
+			if pattern.Category == category {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown risky category %q", category)
+		}
+		if rbac.SeverityRank(severity) == 0 {
+			return fmt.Errorf("invalid severity %q for category %s (valid: %s)", severity, category, strings.Join(rbac.Severities, ", "))
+		}
+	}
+	return nil
+}
+
+// SuperuserRisk is the finding for a subject that bypasses authorization
+// through group, such as system:masters. No RBAC object grants or can
+// restrict that access.
+func SuperuserRisk(group string) rbac.RiskyPermission {
+	return rbac.RiskyPermission{
+		Category:    "superuser-group",
+		Severity:    rbac.SeverityCritical,
+		Description: "Members of " + group + " are allowed every request without RBAC being consulted; removing bindings cannot restrict them",
+		BypassedVia: group,
+	}
+}
+
 // AnalyzeRiskyPermissions checks grants for risky permission patterns
 func AnalyzeRiskyPermissions(grants []rbac.PermissionGrant) []rbac.RiskyPermission {
+	return AnalyzeRiskyPermissionsWithOverrides(grants, nil)
+}
+
+// AnalyzeRiskyPermissionsWithOverrides checks grants for risky permission
+// patterns, replacing the built-in severity of overridden categories
+func AnalyzeRiskyPermissionsWithOverrides(grants []rbac.PermissionGrant, overrides SeverityOverrides) []rbac.RiskyPermission {
 	var risks []rbac.RiskyPermission
 	seenCategories := make(map[string]bool)
 
 	for _, grant := range grants {
+		// Grants resolved without a request carry no wildcard annotation
+		if grant.Wildcards == nil {
+			grant.Wildcards = rbac.RuleWildcards(grant.MatchingRule)
+		}
 		for _, pattern := range RiskyPatterns {
 			if matchesRiskyPattern(grant.MatchingRule, pattern) {
 				if !seenCategories[pattern.Category] {
 					seenCategories[pattern.Category] = true
-					risks = append(risks, rbac.RiskyPermission{
+					risk := rbac.RiskyPermission{
 						Category:    pattern.Category,
 						Description: pattern.Description,
 						Severity:    pattern.Severity,
 						Grants:      []rbac.PermissionGrant{grant},
-					})
+					}
+					if severity, ok := overrides[pattern.Category]; ok && severity != pattern.Severity {
+						risk.Severity = severity
+						risk.DefaultSeverity = pattern.Severity
+					}
+					risks = append(risks, risk)
 				} else {
 					// Add to existing risk
 					for i := range risks {
@@ -151,6 +218,22 @@ func AnalyzeRiskyPermissions(grants []rbac.PermissionGrant) []rbac.RiskyPermissi
 	return risks
 }
 
+// RaiseForExposure attaches a token exposure note to each risk and raises its
+// severity one level, since a stolen token makes the permission easy to abuse
+func RaiseForExposure(risks []rbac.RiskyPermission, exposure *rbac.TokenExposure) []rbac.RiskyPermission {
+	if !exposure.Exposed() {
+		return risks
+	}
+	for i := range risks {
+		risks[i].Exposure = exposure
+		if raised := rbac.RaiseSeverity(risks[i].Severity); raised != risks[i].Severity {
+			risks[i].UnexposedSeverity = risks[i].Severity
+			risks[i].Severity = raised
+		}
+	}
+	return risks
+}
+
 func matchesRiskyPattern(rule rbacv1.PolicyRule, pattern RiskyPattern) bool {
 	verbMatch := false
 	for _, pv := range pattern.Verbs {
@@ -207,30 +290,24 @@ func PrintRiskyPermissions(w io.Writer, risks []rbac.RiskyPermission) {
 		return
 	}
 
-	_, _ = fmt.Fprintf(w, "Found %d risky permission pattern(s):\n\n", len(risks))
-
-	// Group by severity
-	critical := filterBySeverity(risks, "critical")
-	high := filterBySeverity(risks, "high")
-	medium := filterBySeverity(risks, "medium")
-
-	if len(critical) > 0 {
-		_, _ = fmt.Fprintln(w, "CRITICAL:")
-		for _, risk := range critical {
-			printRisk(w, risk)
+	for _, risk := range risks {
+		if risk.BypassedVia != "" {
+			_, _ = fmt.Fprintf(w, "SUPERUSER: member of %s, which bypasses RBAC; every request is allowed.\n\n", risk.BypassedVia)
 		}
 	}
-
-	if len(high) > 0 {
-		_, _ = fmt.Fprintln(w, "HIGH:")
-		for _, risk := range high {
-			printRisk(w, risk)
-		}
+	_, _ = fmt.Fprintf(w, "Found %d risky permission pattern(s):\n\n", len(risks))
+	if exposure := risks[0].Exposure; exposure != nil {
+		_, _ = fmt.Fprintf(w, "Token exposure: %s\n\n", exposure)
 	}
 
-	if len(medium) > 0 {
-		_, _ = fmt.Fprintln(w, "MEDIUM:")
-		for _, risk := range medium {
+	// Group by severity
+	for _, severity := range rbac.Severities {
+		group := filterBySeverity(risks, severity)
+		if len(group) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s:\n", strings.ToUpper(severity))
+		for _, risk := range group {
 			printRisk(w, risk)
 		}
 	}
@@ -247,13 +324,69 @@ func filterBySeverity(risks []rbac.RiskyPermission, severity string) []rbac.Risk
 }
 
 func printRisk(w io.Writer, risk rbac.RiskyPermission) {
-	_, _ = fmt.Fprintf(w, "  - %s\n", risk.Category)
+	_, _ = fmt.Fprintf(w, "  - %s", risk.Category)
+	if risk.DefaultSeverity != "" {
+		_, _ = fmt.Fprintf(w, " (severity overridden from %s)", risk.DefaultSeverity)
+	}
+	if risk.UnexposedSeverity != "" {
+		_, _ = fmt.Fprintf(w, " (raised from %s: token exposed)", risk.UnexposedSeverity)
+	}
+	_, _ = fmt.Fprintln(w)
 	_, _ = fmt.Fprintf(w, "    %s\n", risk.Description)
+	if risk.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "    Granted via: membership in %s, hard-coded in the API server\n\n", risk.BypassedVia)
+		return
+	}
 	_, _ = fmt.Fprintf(w, "    Granted via:\n")
 	for _, grant := range risk.Grants {
-		_, _ = fmt.Fprintf(w, "      - %s/%s -> %s/%s\n",
+		if grant.ChainUnknown() {
+			_, _ = fmt.Fprintf(w, "      - %s (binding unknown)\n", FormatRule(grant.MatchingRule))
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "      - %s/%s -> %s/%s",
 			grant.Binding.Kind, grant.Binding.Name,
 			grant.Role.Kind, grant.Role.Name)
+		if grant.Superuser() {
+			_, _ = fmt.Fprintf(w, " (superuser)")
+		}
+		if owner := grant.Owner(); owner != nil {
+			_, _ = fmt.Fprintf(w, " (owned by %s)", owner)
+		}
+		if len(grant.Wildcards) > 0 {
+			_, _ = fmt.Fprintf(w, " (%s)", formatWildcards(grant.Wildcards))
+		}
+		_, _ = fmt.Fprintln(w)
 	}
 	_, _ = fmt.Fprintln(w)
 }
+
+// formatWildcards describes the wildcard parts of a grant, e.g.
+// "matched via wildcard verb, resource"
+func formatWildcards(wildcards []string) string {
+	return "matched via wildcard " + strings.Join(wildcards, ", ")
+}
+
+// NamespaceRisks are the risky permissions a subject has in one namespace
+// through the RoleBindings there
+type NamespaceRisks struct {
+	Namespace string
+	Risks     []rbac.RiskyPermission
+	// Err is set when the namespace's RoleBindings could not be read
+	Err error
+}
+
+// PrintNamespaceRisks outputs risky permissions grouped by where they apply:
+// those granted cluster-wide once, then each namespace's own
+func PrintNamespaceRisks(w io.Writer, selector string, clusterWide []rbac.RiskyPermission, namespaces []NamespaceRisks) {
+	_, _ = fmt.Fprintf(w, "Risky permissions in %d namespace(s) matching %s\n\n", len(namespaces), selector)
+	_, _ = fmt.Fprintf(w, "Cluster-wide, in every namespace:\n")
+	PrintRiskyPermissions(w, clusterWide)
+	for _, ns := range namespaces {
+		_, _ = fmt.Fprintf(w, "\nNamespace %s, through its RoleBindings:\n", ns.Namespace)
+		if ns.Err != nil {
+			_, _ = fmt.Fprintf(w, "Error: %v\n", ns.Err)
+			continue
+		}
+		PrintRiskyPermissions(w, ns.Risks)
+	}
+}
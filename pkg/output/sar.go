@@ -0,0 +1,62 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func init() {
+	Register("sar", func(PrinterOptions) Printer { return &SARPrinter{} })
+}
+
+// SARPrinter outputs the check as a SubjectAccessReview manifest, ready for
+// kubectl create -f -, so the API server can be asked the same question
+type SARPrinter struct{}
+
+func (p *SARPrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	data, err := subjectAccessReviewYAML(result)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// PrintSubjectAccessReviews outputs one SubjectAccessReview per result as a
+// multi-document YAML manifest
+func PrintSubjectAccessReviews(w io.Writer, results []*rbac.PermissionResult) error {
+	for _, result := range results {
+		data, err := subjectAccessReviewYAML(result)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "---\n%s", data)
+	}
+	return nil
+}
+
+// subjectAccessReviewYAML encodes the review for the result's subject and
+// request, leaving out the status and other fields the server fills in
+func subjectAccessReviewYAML(result *rbac.PermissionResult) ([]byte, error) {
+	review := rbac.NewSubjectAccessReview(result.Subject, result.Request)
+	review.APIVersion = authorizationv1.SchemeGroupVersion.String()
+	review.Kind = "SubjectAccessReview"
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SubjectAccessReview: %w", err)
+	}
+	unstructured.RemoveNestedField(m, "metadata")
+	unstructured.RemoveNestedField(m, "status")
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SubjectAccessReview: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// RulesComparison holds the difference between the rules the API server
+// enumerates for the caller and the rules resolved client-side
+type RulesComparison struct {
+	Namespace       string
+	OnlyServer      []rbac.RuleKey
+	OnlyClient      []rbac.RuleKey
+	Incomplete      bool
+	EvaluationError string
+}
+
+// PrintRulesComparison outputs a --server-rules comparison
+func PrintRulesComparison(w io.Writer, cmp RulesComparison) {
+	_, _ = fmt.Fprintf(w, "Comparing SelfSubjectRulesReview with client-side resolution in namespace %s\n\n", cmp.Namespace)
+
+	if cmp.Incomplete {
+		_, _ = fmt.Fprintln(w, "WARNING: the API server reported an incomplete rule list; some authorizers")
+		_, _ = fmt.Fprintln(w, "cannot enumerate rules, so server-only differences may be missing.")
+		if cmp.EvaluationError != "" {
+			_, _ = fmt.Fprintf(w, "  Evaluation error: %s\n", cmp.EvaluationError)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(cmp.OnlyServer) == 0 && len(cmp.OnlyClient) == 0 {
+		_, _ = fmt.Fprintln(w, "Rule sets match.")
+		return
+	}
+
+	if len(cmp.OnlyServer) > 0 {
+		_, _ = fmt.Fprintf(w, "Only on server (%d):\n", len(cmp.OnlyServer))
+		_, _ = fmt.Fprintln(w, "  (granted by a non-RBAC authorizer or missed by the client-side resolver)")
+		for _, k := range cmp.OnlyServer {
+			_, _ = fmt.Fprintf(w, "  - %s\n", k)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if len(cmp.OnlyClient) > 0 {
+		_, _ = fmt.Fprintf(w, "Only client-side (%d):\n", len(cmp.OnlyClient))
+		_, _ = fmt.Fprintln(w, "  (resolved locally but not reported by the API server)")
+		for _, k := range cmp.OnlyClient {
+			_, _ = fmt.Fprintf(w, "  - %s\n", k)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+}
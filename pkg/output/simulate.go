@@ -0,0 +1,122 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// Simulation is the outcome of resolving a subject's permissions before and
+// after a set of changes. Either Before and After are set, for a single
+// check, or Gained and Lost, for the full grant set.
+type Simulation struct {
+	Subject   rbac.Subject
+	Namespace string
+
+	Request       *rbac.PermissionRequest
+	Before, After *rbac.PermissionResult
+
+	Gained, Lost []rbac.PermissionDelta
+	// StillGranted are the permissions whose grant was removed but that other
+	// grants still cover, with those grants
+	StillGranted []rbac.PermissionDelta
+}
+
+// SimulatedCheckOutput is the result of a check on one side of a simulation
+type SimulatedCheckOutput struct {
+	Allowed bool          `json:"allowed"`
+	Grants  []GrantOutput `json:"grants"`
+}
+
+// SimulationOutput is the JSON structure for a simulation
+type SimulationOutput struct {
+	Subject   SubjectOutput `json:"subject"`
+	Namespace string        `json:"namespace,omitempty"`
+
+	Request *RequestOutput        `json:"request,omitempty"`
+	Before  *SimulatedCheckOutput `json:"before,omitempty"`
+	After   *SimulatedCheckOutput `json:"after,omitempty"`
+
+	Gained       []PermissionDeltaOutput `json:"gained,omitempty"`
+	Lost         []PermissionDeltaOutput `json:"lost,omitempty"`
+	StillGranted []PermissionDeltaOutput `json:"stillGranted,omitempty"`
+}
+
+// BuildSimulationOutput converts a simulation into its JSON structure
+func BuildSimulationOutput(sim Simulation) SimulationOutput {
+	out := SimulationOutput{
+		Subject:   SubjectOutput{Kind: sim.Subject.Kind, Name: sim.Subject.Name, Namespace: sim.Subject.Namespace},
+		Namespace: sim.Namespace,
+		Gained:    buildDeltas(sim.Gained),
+		Lost:      buildDeltas(sim.Lost),
+
+		StillGranted: buildDeltas(sim.StillGranted),
+	}
+	if sim.Request != nil {
+		request := buildRequestOutput(*sim.Request)
+		out.Request = &request
+		out.Before = buildSimulatedCheck(sim.Before)
+		out.After = buildSimulatedCheck(sim.After)
+	}
+	return out
+}
+
+func buildSimulatedCheck(result *rbac.PermissionResult) *SimulatedCheckOutput {
+	out := &SimulatedCheckOutput{Allowed: result.Allowed, Grants: []GrantOutput{}}
+	for _, g := range result.Grants {
+		out.Grants = append(out.Grants, buildGrantOutput(g))
+	}
+	return out
+}
+
+// PrintSimulation outputs a simulation in human-readable form
+func PrintSimulation(w io.Writer, sim Simulation) {
+	scope := ""
+	if sim.Namespace != "" {
+		scope = " (namespace " + sim.Namespace + ")"
+	}
+	_, _ = fmt.Fprintf(w, "Simulating the changes for %s%s\n\n", sim.Subject, scope)
+
+	if sim.Request != nil {
+		_, _ = fmt.Fprintf(w, "%s %s\n", sim.Request.Verb, formatResource(*sim.Request))
+		printSimulatedCheck(w, "Now:  ", sim.Before)
+		printSimulatedCheck(w, "After:", sim.After)
+		return
+	}
+
+	printDeltas(w, "Gained", sim.Gained)
+	printDeltas(w, "Lost", sim.Lost)
+	printDeltas(w, "Still granted through other paths", sim.StillGranted)
+	if len(sim.Gained) == 0 && len(sim.Lost) == 0 {
+		_, _ = fmt.Fprintln(w, "No change in permissions.")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%d permission(s) change\n", len(sim.Gained)+len(sim.Lost))
+}
+
+func printSimulatedCheck(w io.Writer, label string, result *rbac.PermissionResult) {
+	verdict := "DENIED"
+	if result.Allowed {
+		verdict = "ALLOWED"
+	}
+	if result.BypassedVia != "" {
+		verdict += " (bypassed via " + result.BypassedVia + ")"
+	}
+	_, _ = fmt.Fprintf(w, "  %s %s\n", label, verdict)
+	for _, g := range result.Grants {
+		_, _ = fmt.Fprintf(w, "    via %s -> %s: %s", formatTraceBinding(g.Binding), formatRole(g.Role), FormatRule(g.MatchingRule))
+		if g.Role.Source != nil {
+			_, _ = fmt.Fprintf(w, " (%s)", g.RuleSource())
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// PrintSimulationJSON outputs a simulation as JSON
+func PrintSimulationJSON(w io.Writer, sim Simulation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildSimulationOutput(sim))
+}
@@ -0,0 +1,42 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// BuildSuggestionOutput converts a suggestion into its manifests, for the
+// suggestion field of JSON and YAML output
+func BuildSuggestionOutput(s *rbac.Suggestion) []map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	var objects []map[string]interface{}
+	for _, obj := range s.Objects() {
+		if m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err == nil {
+			// Unset, so left out of the manifest rather than null
+			unstructured.RemoveNestedField(m, "metadata", "creationTimestamp")
+			objects = append(objects, m)
+		}
+	}
+	return objects
+}
+
+// PrintSuggestion outputs a suggestion as a multi-document YAML manifest that
+// can be piped to kubectl apply -f -
+func PrintSuggestion(w io.Writer, s *rbac.Suggestion) error {
+	for _, obj := range BuildSuggestionOutput(s) {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to encode suggestion: %w", err)
+		}
+		_, _ = fmt.Fprintf(w, "---\n%s", data)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+)
+
+// topRolesLimit caps how many roles a summary lists
+const topRolesLimit = 10
+
+// Count is a name and how many results it accounts for
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// FindingsSummary aggregates a large set of findings instead of listing each
+type FindingsSummary struct {
+	Total         int     `json:"total"`
+	Subjects      int     `json:"subjects"`
+	BySeverity    []Count `json:"bySeverity"`
+	BySubjectKind []Count `json:"bySubjectKind"`
+	TopRoles      []Count `json:"topRoles"`
+	Namespaces    []Count `json:"namespaces"`
+
+	// Partial is set when scopes were skipped, which are listed in Skipped
+	Partial bool                 `json:"partial"`
+	Skipped []SkippedScopeOutput `json:"skipped"`
+}
+
+// SummarizeFindings counts findings by severity, subject kind, role, and
+// namespace. Each count is sorted from most to least, then by name. Findings
+// from ClusterRoleBindings are counted under "(cluster-wide)".
+func SummarizeFindings(findings []notify.Finding) FindingsSummary {
+	subjects := make(map[string]bool)
+	severities := make(map[string]int)
+	kinds := make(map[string]int)
+	roles := make(map[string]int)
+	namespaces := make(map[string]int)
+	for _, f := range findings {
+		subjects[f.Subject] = true
+		severities[f.Severity]++
+		kind := f.SubjectKind
+		if kind == "" {
+			kind = "unknown"
+		}
+		kinds[kind]++
+		roles[f.Role]++
+		namespaces[bindingNamespace(f.Binding)]++
+	}
+
+	summary := FindingsSummary{
+		Total:         len(findings),
+		Subjects:      len(subjects),
+		BySeverity:    sortedCounts(severities),
+		BySubjectKind: sortedCounts(kinds),
+		TopRoles:      sortedCounts(roles),
+		Namespaces:    sortedCounts(namespaces),
+	}
+	if len(summary.TopRoles) > topRolesLimit {
+		summary.TopRoles = summary.TopRoles[:topRolesLimit]
+	}
+	return summary
+}
+
+// bindingNamespace extracts the namespace from "RoleBinding/NAMESPACE/NAME"
+func bindingNamespace(binding string) string {
+	parts := strings.Split(binding, "/")
+	if len(parts) == 3 {
+		return parts[1]
+	}
+	return "(cluster-wide)"
+}
+
+func sortedCounts(m map[string]int) []Count {
+	counts := make([]Count, 0, len(m))
+	for name, n := range m {
+		counts = append(counts, Count{Name: name, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// PrintFindingsSummary outputs aggregate counts in human-readable form
+func PrintFindingsSummary(w io.Writer, summary FindingsSummary) {
+	if summary.Total == 0 {
+		_, _ = fmt.Fprintln(w, "No risky permissions detected.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Found %d risky finding(s) for %d subject(s)\n\n", summary.Total, summary.Subjects)
+	printCounts(w, "By severity", summary.BySeverity)
+	printCounts(w, "By subject kind", summary.BySubjectKind)
+	printCounts(w, "Top roles", summary.TopRoles)
+	printCounts(w, "Namespaces", summary.Namespaces)
+	_, _ = fmt.Fprintln(w, "To see one subject's findings in full:")
+	_, _ = fmt.Fprintln(w, "  kubectl rbac-why can-i --show-risky --as SUBJECT")
+}
+
+func printCounts(w io.Writer, title string, counts []Count) {
+	_, _ = fmt.Fprintf(w, "%s:\n", title)
+	for _, c := range counts {
+		_, _ = fmt.Fprintf(w, "  %6d  %s\n", c.Count, c.Name)
+	}
+	_, _ = fmt.Fprintln(w)
+}
+
+// PrintFindingsSummaryJSON outputs aggregate counts as JSON
+func PrintFindingsSummaryJSON(w io.Writer, summary FindingsSummary) error {
+	if summary.Skipped == nil {
+		summary.Skipped = []SkippedScopeOutput{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
@@ -0,0 +1,35 @@
+package output
+
+import (
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Table is rows of cells printed in columns aligned under a header. The
+// last column isn't padded, so lines don't end in spaces.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// NewTable returns an empty table with the given column headers
+func NewTable(header ...string) *Table {
+	return &Table{Header: header}
+}
+
+// AddRow appends a row with one cell per column
+func (t *Table) AddRow(cells ...string) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// Print writes the header and rows, separating columns by two spaces
+func (t *Table) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cells := range append([][]string{t.Header}, t.Rows...) {
+		if _, err := io.WriteString(tw, strings.Join(cells, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
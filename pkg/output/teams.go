@@ -0,0 +1,135 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/notify"
+)
+
+// UnassignedTeam collects subjects with no team label or annotation
+const UnassignedTeam = "unassigned"
+
+// TeamSubject is one subject in a team rollup
+type TeamSubject struct {
+	Subject string `json:"subject"`
+	Kind    string `json:"kind"`
+	// Source says where the team was read from, e.g. "label on namespace prod"
+	Source        string           `json:"source,omitempty"`
+	WorstSeverity string           `json:"worstSeverity,omitempty"`
+	Findings      []notify.Finding `json:"findings"`
+}
+
+// TeamRollup is the permission posture of one team
+type TeamRollup struct {
+	Team          string        `json:"team"`
+	Subjects      []TeamSubject `json:"subjects"`
+	WorstSeverity string        `json:"worstSeverity,omitempty"`
+	Findings      int           `json:"findings"`
+	// ClusterWide lists risky grants that come from ClusterRoleBindings, as
+	// "ClusterRoleBinding/name -> ClusterRole/name"
+	ClusterWide []string `json:"clusterWideGrants"`
+}
+
+// TeamReport is a permission inventory grouped by team. The unassigned team,
+// when present, is always first.
+type TeamReport struct {
+	Key        string       `json:"key"`
+	Unassigned int          `json:"unassigned"`
+	Teams      []TeamRollup `json:"teams"`
+}
+
+func severityOrNone(severity string) string {
+	if severity == "" {
+		return "none"
+	}
+	return severity
+}
+
+// PrintTeamReportMarkdown outputs a team report as a Markdown document
+func PrintTeamReportMarkdown(w io.Writer, report *TeamReport) {
+	_, _ = fmt.Fprintf(w, "# Permission posture by team (`%s`)\n\n", report.Key)
+	if report.Unassigned > 0 {
+		_, _ = fmt.Fprintf(w, "> **%d subject(s) have no `%s` label or annotation** and are listed under %q.\n\n", report.Unassigned, report.Key, UnassignedTeam)
+	}
+	if len(report.Teams) == 0 {
+		_, _ = fmt.Fprintln(w, "No subjects found.")
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "| Team | Subjects | Worst severity | Findings | Cluster-wide risky grants |")
+	_, _ = fmt.Fprintln(w, "|---|---|---|---|---|")
+	for _, t := range report.Teams {
+		team := markdownEscape(t.Team)
+		if t.Team == UnassignedTeam {
+			team = "**" + team + "**"
+		}
+		_, _ = fmt.Fprintf(w, "| %s | %d | %s | %d | %s |\n", team, len(t.Subjects), severityOrNone(t.WorstSeverity), t.Findings, markdownEscape(strings.Join(t.ClusterWide, "<br>")))
+	}
+
+	for _, t := range report.Teams {
+		_, _ = fmt.Fprintf(w, "\n## %s\n\n", markdownEscape(t.Team))
+		_, _ = fmt.Fprintln(w, "| Subject | Team from | Worst severity | Findings |")
+		_, _ = fmt.Fprintln(w, "|---|---|---|---|")
+		for _, s := range t.Subjects {
+			source := s.Source
+			if source == "" {
+				source = "-"
+			}
+			_, _ = fmt.Fprintf(w, "| %s | %s | %s | %d |\n", markdownEscape(s.Subject), source, severityOrNone(s.WorstSeverity), len(s.Findings))
+		}
+	}
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+var teamReportHTML = template.Must(template.New("teams").Funcs(template.FuncMap{
+	"severity": severityOrNone,
+	"unassigned": func(team string) bool {
+		return team == UnassignedTeam
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Permission posture by team</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.unassigned { background: #fdecea; }
+.banner { background: #fdecea; border-left: 4px solid #d93025; padding: 8px 12px; }
+</style>
+</head>
+<body>
+<h1>Permission posture by team (<code>{{.Key}}</code>)</h1>
+{{if .Unassigned}}<p class="banner"><strong>{{.Unassigned}} subject(s) have no <code>{{.Key}}</code> label or annotation</strong> and are listed under "unassigned".</p>
+{{end}}<table>
+<tr><th>Team</th><th>Subjects</th><th>Worst severity</th><th>Findings</th><th>Cluster-wide risky grants</th></tr>
+{{range .Teams}}<tr{{if unassigned .Team}} class="unassigned"{{end}}><td>{{.Team}}</td><td>{{len .Subjects}}</td><td>{{severity .WorstSeverity}}</td><td>{{.Findings}}</td><td>{{range .ClusterWide}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+{{range .Teams}}<h2{{if unassigned .Team}} class="unassigned"{{end}}>{{.Team}}</h2>
+<table>
+<tr><th>Subject</th><th>Team from</th><th>Worst severity</th><th>Findings</th></tr>
+{{range .Subjects}}<tr><td>{{.Subject}}</td><td>{{.Source}}</td><td>{{severity .WorstSeverity}}</td><td>{{len .Findings}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// PrintTeamReportHTML outputs a team report as a standalone HTML page
+func PrintTeamReportHTML(w io.Writer, report *TeamReport) error {
+	return teamReportHTML.Execute(w, report)
+}
+
+// PrintTeamReportJSON outputs a team report as JSON
+func PrintTeamReportJSON(w io.Writer, report *TeamReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,121 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func init() {
+	factory := func(opts PrinterOptions) Printer { return &TemplatePrinter{Template: opts.Template} }
+	Register("go-template", factory)
+	Register("go-template-file", factory)
+}
+
+// templateFuncs are added to text/template's built-in functions
+var templateFuncs = template.FuncMap{
+	"join":  templateJoin,
+	"lower": strings.ToLower,
+}
+
+// TemplatePrinter executes a Go template, as with kubectl -o go-template,
+// against the structure the JSON printer outputs, so fields are named as in
+// -o json, e.g. {{.allowed}}
+type TemplatePrinter struct {
+	Template string
+}
+
+func (p *TemplatePrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	tmpl, err := ParseTemplate(p.Template)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(BuildJSONOutput(result, ctx))
+	if err != nil {
+		return err
+	}
+	var obj any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	// Nothing is written when the template fails part way
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, obj); err != nil {
+		return fmt.Errorf("failed to execute go-template%s", templateErrorPosition(err, p.Template))
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// ParseTemplate parses a go-template, reporting the line and column of a
+// syntax error
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("go-template").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid go-template%s", templateErrorPosition(err, text))
+	}
+	return tmpl, nil
+}
+
+// templateErrorRe splits a text/template error into its line, its column
+// when there is one, and the message
+var templateErrorRe = regexp.MustCompile(`^template: go-template:(\d+)(?::(\d+))?: (.*)$`)
+
+// templateQuotedRe finds the token a parse error quotes, e.g. "foo" in
+// function "foo" not defined
+var templateQuotedRe = regexp.MustCompile(`"([^"]+)"|'([^']+)'|<([^>]+)>`)
+
+// templateErrorPosition formats err as " at line L, column C: message",
+// counting columns from 1. Parse errors only give the line, so the column
+// is where the token they quote first appears on it, and left out when that
+// can't be told.
+func templateErrorPosition(err error, text string) string {
+	m := templateErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return ": " + err.Error()
+	}
+	line, column, msg := m[1], m[2], m[3]
+	// Execution errors name the template they were executing; it's always
+	// this one
+	msg = strings.TrimPrefix(msg, `executing "go-template" at `)
+	if column != "" {
+		// Execution errors count columns from 0
+		n, _ := strconv.Atoi(column)
+		column = strconv.Itoa(n + 1)
+	} else {
+		n, _ := strconv.Atoi(line)
+		lines := strings.Split(text, "\n")
+		if q := templateQuotedRe.FindStringSubmatch(msg); q != nil && n >= 1 && n <= len(lines) {
+			if i := strings.Index(lines[n-1], q[1]+q[2]+q[3]); i >= 0 {
+				column = strconv.Itoa(i + 1)
+			}
+		}
+	}
+	if column == "" {
+		return fmt.Sprintf(" at line %s: %s", line, msg)
+	}
+	return fmt.Sprintf(" at line %s, column %s: %s", line, column, msg)
+}
+
+// templateJoin joins the elements of a list with sep, for {{join ", " .list}}
+func templateJoin(sep string, list any) (string, error) {
+	items, ok := list.([]any)
+	if !ok {
+		if list == nil {
+			return "", nil
+		}
+		return "", fmt.Errorf("join expects a list, got %T", list)
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = fmt.Sprint(item)
+	}
+	return strings.Join(parts, sep), nil
+}
@@ -0,0 +1,89 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// TraceBindingOutput is one binding the resolver examined, for --trace
+type TraceBindingOutput struct {
+	Binding    BindingOutput     `json:"binding"`
+	RoleRef    RoleOutput        `json:"roleRef"`
+	Matched    bool              `json:"matched"`
+	MatchedVia string            `json:"matchedVia,omitempty"`
+	RoleError  string            `json:"roleError,omitempty"`
+	Rules      []TraceRuleOutput `json:"rules,omitempty"`
+}
+
+// TraceRuleOutput is the outcome of testing one rule of a matched binding
+type TraceRuleOutput struct {
+	Index   int    `json:"index"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// BuildTraceOutput converts an evaluation trace into its JSON structure
+func BuildTraceOutput(trace []rbac.BindingTrace) []TraceBindingOutput {
+	var out []TraceBindingOutput
+	for _, t := range trace {
+		b := TraceBindingOutput{
+			Binding:    BindingOutput{Kind: t.Binding.Kind, Name: t.Binding.Name, Namespace: t.Binding.Namespace},
+			RoleRef:    RoleOutput{Kind: t.RoleRef.Kind, Name: t.RoleRef.Name},
+			Matched:    t.Matched(),
+			MatchedVia: t.MatchedVia,
+			RoleError:  t.RoleError,
+		}
+		if t.RoleRef.Kind == "Role" {
+			b.RoleRef.Namespace = t.Binding.Namespace
+		}
+		for _, r := range t.Rules {
+			b.Rules = append(b.Rules, TraceRuleOutput{Index: r.Index, Matched: r.Matched, Reason: r.Reason})
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// PrintTrace writes the evaluation trace as an indented tree, one binding per
+// branch, with the rules tested under each binding that applies
+func PrintTrace(w io.Writer, trace []rbac.BindingTrace) {
+	matched := 0
+	for _, t := range trace {
+		if t.Matched() {
+			matched++
+		}
+	}
+	_, _ = fmt.Fprintf(w, "Evaluation trace (%d binding(s) examined, %d matched the subject):\n", len(trace), matched)
+
+	for _, t := range trace {
+		_, _ = fmt.Fprintf(w, "  %s -> %s/%s\n", formatTraceBinding(t.Binding), t.RoleRef.Kind, t.RoleRef.Name)
+		if !t.Matched() {
+			_, _ = fmt.Fprintf(w, "    subject not bound\n")
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "    subject matched via %s\n", t.MatchedVia)
+		if t.RoleError != "" {
+			_, _ = fmt.Fprintf(w, "    roleRef not resolved: %s\n", t.RoleError)
+			continue
+		}
+		if len(t.Rules) == 0 {
+			_, _ = fmt.Fprintf(w, "    role has no rules\n")
+		}
+		for _, r := range t.Rules {
+			if r.Matched {
+				_, _ = fmt.Fprintf(w, "    rules[%d] MATCH\n", r.Index)
+			} else {
+				_, _ = fmt.Fprintf(w, "    rules[%d] no: %s\n", r.Index, r.Reason)
+			}
+		}
+	}
+}
+
+func formatTraceBinding(b rbac.BindingInfo) string {
+	if b.Namespace != "" {
+		return b.Kind + "/" + b.Namespace + "/" + b.Name
+	}
+	return b.Kind + "/" + b.Name
+}
@@ -0,0 +1,213 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+	"github.com/hardik/kubectl-rbac-why/pkg/usage"
+)
+
+// UsageReport compares a subject's grants with the requests it made
+type UsageReport struct {
+	Subject  rbac.Subject
+	Source   string // The audit log file or directory
+	Observed *usage.Observed
+	Grants   []usage.GrantUsage
+}
+
+// Unused counts the grants no observed request exercised
+func (r UsageReport) Unused() int {
+	n := 0
+	for _, g := range r.Grants {
+		if g.Requests == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// ProposedRole is a Role covering exactly the observed requests, or a
+// ClusterRole when they span namespaces or include cluster-scoped requests
+func (r UsageReport) ProposedRole() interface{} {
+	rules := usage.Propose(r.Observed)
+	name := strings.ReplaceAll(r.Subject.Name, ":", "-") + "-observed"
+	if namespaces := r.Observed.Namespaces(); len(namespaces) == 1 && namespaces[0] != "" {
+		return &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespaces[0]},
+			Rules:      rules,
+		}
+	}
+	return &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      rules,
+	}
+}
+
+// GrantUsageOutput is a grant and how often it was exercised
+type GrantUsageOutput struct {
+	GrantOutput
+	Requests    int      `json:"requests"`
+	Used        bool     `json:"used"`
+	UnusedVerbs []string `json:"unusedVerbs,omitempty"`
+	Caveat      string   `json:"caveat,omitempty"`
+}
+
+// ObservedRequestOutput is one kind of request seen in the audit log
+type ObservedRequestOutput struct {
+	Verb        string `json:"verb"`
+	APIGroup    string `json:"apiGroup"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	// NonResourceURL is set, with no resource, for a request to a URL such
+	// as /healthz
+	NonResourceURL string `json:"nonResourceURL,omitempty"`
+	Count          int    `json:"count"`
+}
+
+// UsageOutput is the JSON structure for a usage report. UnusedCount is meant
+// to be trended over time.
+type UsageOutput struct {
+	Subject         SubjectOutput           `json:"subject"`
+	Source          string                  `json:"source"`
+	From            *time.Time              `json:"from,omitempty"`
+	To              *time.Time              `json:"to,omitempty"`
+	Events          int                     `json:"events"`
+	UnusedCount     int                     `json:"unusedCount"`
+	ReadVerbsLogged bool                    `json:"readVerbsLogged"`
+	Grants          []GrantUsageOutput      `json:"grants"`
+	Observed        []ObservedRequestOutput `json:"observed"`
+	Proposal        interface{}             `json:"proposal"`
+}
+
+// BuildUsageOutput converts a usage report into its JSON structure
+func BuildUsageOutput(r UsageReport) UsageOutput {
+	out := UsageOutput{
+		Subject: SubjectOutput{
+			Kind:      r.Subject.Kind,
+			Name:      r.Subject.Name,
+			Namespace: r.Subject.Namespace,
+			Origin:    r.Subject.Origin,
+		},
+		Source:          r.Source,
+		Events:          r.Observed.Events,
+		UnusedCount:     r.Unused(),
+		ReadVerbsLogged: r.Observed.ReadVerbsLogged,
+		Grants:          []GrantUsageOutput{},
+		Observed:        []ObservedRequestOutput{},
+		Proposal:        r.ProposedRole(),
+	}
+	if !r.Observed.From.IsZero() {
+		from, to := r.Observed.From, r.Observed.To
+		out.From, out.To = &from, &to
+	}
+	for _, g := range r.Grants {
+		out.Grants = append(out.Grants, GrantUsageOutput{
+			GrantOutput: buildGrantOutput(g.PermissionGrant),
+			Requests:    g.Requests,
+			Used:        g.Requests > 0,
+			UnusedVerbs: g.UnusedVerbs,
+			Caveat:      g.Caveat,
+		})
+	}
+	for _, t := range sortedTuples(r.Observed) {
+		out.Observed = append(out.Observed, ObservedRequestOutput{
+			Verb:           t.Verb,
+			APIGroup:       t.APIGroup,
+			Resource:       t.Resource,
+			Subresource:    t.Subresource,
+			Namespace:      t.Namespace,
+			NonResourceURL: t.NonResourceURL,
+			Count:          r.Observed.Tuples[t],
+		})
+	}
+	return out
+}
+
+func sortedTuples(observed *usage.Observed) []usage.Tuple {
+	tuples := make([]usage.Tuple, 0, len(observed.Tuples))
+	for t := range observed.Tuples {
+		tuples = append(tuples, t)
+	}
+	sort.Slice(tuples, func(i, j int) bool { return tuples[i].String() < tuples[j].String() })
+	return tuples
+}
+
+// PrintUsageReport outputs a usage report in human-readable form
+func PrintUsageReport(w io.Writer, r UsageReport) error {
+	_, _ = fmt.Fprintf(w, "Permission usage of %s from %s\n", r.Subject, r.Source)
+	if r.Observed.From.IsZero() {
+		_, _ = fmt.Fprintf(w, "%d matching event(s)\n", r.Observed.Events)
+	} else {
+		_, _ = fmt.Fprintf(w, "Window: %s to %s, %d matching event(s)\n",
+			r.Observed.From.Format(time.RFC3339), r.Observed.To.Format(time.RFC3339), r.Observed.Events)
+	}
+	if !r.Observed.ReadVerbsLogged {
+		_, _ = fmt.Fprintf(w, "Warning: the log has no get/list/watch events; read access cannot be assessed\n")
+	}
+	_, _ = fmt.Fprintln(w)
+
+	if len(r.Grants) == 0 {
+		_, _ = fmt.Fprintf(w, "%s has no grants.\n", r.Subject)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(w, "%d of %d grant(s) never exercised:\n", r.Unused(), len(r.Grants))
+	for _, g := range r.Grants {
+		if g.Requests > 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  UNUSED  %s\n", describeUsageGrant(g.PermissionGrant))
+		if g.Caveat != "" {
+			_, _ = fmt.Fprintf(w, "          Caveat: %s\n", g.Caveat)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+	for _, g := range r.Grants {
+		if g.Requests == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  USED    %s: %d request(s)", describeUsageGrant(g.PermissionGrant), g.Requests)
+		if len(g.UnusedVerbs) > 0 {
+			_, _ = fmt.Fprintf(w, ", unused verbs: %s", strings.Join(g.UnusedVerbs, ", "))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+
+	if r.Observed.Events == 0 {
+		return nil
+	}
+	manifest, err := yaml.Marshal(r.ProposedRole())
+	if err != nil {
+		return fmt.Errorf("failed to build proposed role: %w", err)
+	}
+	_, _ = fmt.Fprintf(w, "\nProposed role covering only the observed requests:\n---\n%s", manifest)
+	return nil
+}
+
+func describeUsageGrant(g rbac.PermissionGrant) string {
+	binding := g.Binding.Kind + " " + g.Binding.Name
+	if g.Binding.Namespace != "" {
+		binding += " (namespace: " + g.Binding.Namespace + ")"
+	}
+	return fmt.Sprintf("%s -> %s %s: verbs=[%s] apiGroups=[%s] resources=[%s]", binding, g.Role.Kind, g.Role.Name,
+		strings.Join(g.MatchingRule.Verbs, ", "), formatGroups(g.MatchingRule.APIGroups), strings.Join(g.MatchingRule.Resources, ", "))
+}
+
+// PrintUsageReportJSON outputs a usage report as JSON
+func PrintUsageReportJSON(w io.Writer, r UsageReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildUsageOutput(r))
+}
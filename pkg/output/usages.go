@@ -0,0 +1,145 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// UsageSubjectOutput is one subject of a binding. Broad explains why a
+// built-in group reaches more identities than its name suggests.
+type UsageSubjectOutput struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Broad     string `json:"broad,omitempty"`
+}
+
+// RoleUsageOutput is one binding that refers to the role
+type RoleUsageOutput struct {
+	Binding  BindingOutput        `json:"binding"`
+	OwnedBy  *OwnerOutput         `json:"ownedBy,omitempty"`
+	Subjects []UsageSubjectOutput `json:"subjects"`
+}
+
+// UsagesOutput is the JSON structure for where a role is bound
+type UsagesOutput struct {
+	Role        RoleOutput        `json:"role"`
+	OwnedBy     *OwnerOutput      `json:"ownedBy,omitempty"`
+	Exists      bool              `json:"exists"`
+	ClusterWide bool              `json:"clusterWide"`
+	Namespaces  []string          `json:"namespaces"`
+	Subjects    int               `json:"subjects"`
+	Summary     string            `json:"summary"`
+	Bindings    []RoleUsageOutput `json:"bindings"`
+}
+
+// UsagesSummary describes the reach of a role in one line, e.g.
+// "bound in 14 namespaces to 3 distinct subjects"
+func UsagesSummary(report *rbac.RoleUsageReport) string {
+	if len(report.Usages) == 0 {
+		return "not bound anywhere"
+	}
+	where := fmt.Sprintf("bound in %d namespace(s)", len(report.Namespaces))
+	if report.ClusterWide {
+		where = "bound cluster-wide"
+		if len(report.Namespaces) > 0 {
+			where += fmt.Sprintf(" and in %d namespace(s)", len(report.Namespaces))
+		}
+	}
+	return fmt.Sprintf("%s to %d distinct subject(s)", where, report.Subjects)
+}
+
+// BuildUsagesOutput converts a role usage report into its JSON structure
+func BuildUsagesOutput(report *rbac.RoleUsageReport) UsagesOutput {
+	out := UsagesOutput{
+		Role:        RoleOutput{Kind: report.Role.Kind, Name: report.Role.Name, Namespace: report.Role.Namespace},
+		OwnedBy:     ownerOutput(report.Role.Owner),
+		Exists:      report.Exists,
+		ClusterWide: report.ClusterWide,
+		Namespaces:  append([]string{}, report.Namespaces...),
+		Subjects:    report.Subjects,
+		Summary:     UsagesSummary(report),
+		Bindings:    []RoleUsageOutput{},
+	}
+	for _, u := range report.Usages {
+		usage := RoleUsageOutput{
+			Binding:  BindingOutput{Kind: u.Binding.Kind, Name: u.Binding.Name, Namespace: u.Binding.Namespace},
+			OwnedBy:  ownerOutput(u.Binding.Owner),
+			Subjects: []UsageSubjectOutput{},
+		}
+		for _, s := range u.Subjects {
+			subject := UsageSubjectOutput{Kind: s.Kind, Name: s.Name, Namespace: s.Namespace}
+			if s.Kind == "Group" {
+				subject.Broad = rbac.BroadGroup(s.Name)
+			}
+			usage.Subjects = append(usage.Subjects, subject)
+		}
+		out.Bindings = append(out.Bindings, usage)
+	}
+	return out
+}
+
+func ownerOutput(owner *rbac.Owner) *OwnerOutput {
+	if owner == nil {
+		return nil
+	}
+	return &OwnerOutput{Kind: owner.Kind, Name: owner.Name, Namespace: owner.Namespace}
+}
+
+// PrintUsages outputs where a role is bound in human-readable form
+func PrintUsages(w io.Writer, report *rbac.RoleUsageReport) {
+	role := report.Role.Kind + "/" + report.Role.Name
+	if report.Role.Namespace != "" {
+		role = fmt.Sprintf("%s/%s/%s", report.Role.Kind, report.Role.Namespace, report.Role.Name)
+	}
+	_, _ = fmt.Fprintf(w, "%s: %s\n", role, UsagesSummary(report))
+	if report.Role.Owner != nil {
+		_, _ = fmt.Fprintf(w, "Managed by %s\n", report.Role.Owner)
+	}
+	if !report.Exists {
+		_, _ = fmt.Fprintf(w, "Warning: %s does not exist; the bindings below grant nothing until it is created\n", role)
+	}
+
+	for _, u := range report.Usages {
+		binding := u.Binding.Kind + " " + u.Binding.Name
+		if u.Binding.Namespace != "" {
+			binding = fmt.Sprintf("%s %s/%s", u.Binding.Kind, u.Binding.Namespace, u.Binding.Name)
+		}
+		_, _ = fmt.Fprintf(w, "\n  %s", binding)
+		if u.Binding.Owner != nil {
+			_, _ = fmt.Fprintf(w, " (managed by %s)", u.Binding.Owner)
+		}
+		_, _ = fmt.Fprintln(w)
+		if len(u.Subjects) == 0 {
+			_, _ = fmt.Fprintln(w, "    (no subjects)")
+		}
+		for _, s := range u.Subjects {
+			name := s.Name
+			if s.Namespace != "" {
+				name = s.Namespace + "/" + s.Name
+			}
+			line := fmt.Sprintf("    %s %s", s.Kind, name)
+			if s.Kind == "Group" {
+				if why := rbac.BroadGroup(s.Name); why != "" {
+					line += "  [BROAD: " + why + "]"
+				}
+			}
+			_, _ = fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(report.Namespaces) > 0 {
+		_, _ = fmt.Fprintf(w, "\nNamespaces: %s\n", strings.Join(report.Namespaces, ", "))
+	}
+}
+
+// PrintUsagesJSON outputs where a role is bound as JSON
+func PrintUsagesJSON(w io.Writer, report *rbac.RoleUsageReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildUsagesOutput(report))
+}
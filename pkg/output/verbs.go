@@ -0,0 +1,82 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// PrintVerbs outputs a check of several verbs on the same resource as a
+// table with one row per verb and the path that grants it
+func PrintVerbs(w io.Writer, results []*rbac.PermissionResult) {
+	if len(results) == 0 {
+		return
+	}
+	request := results[0].Request
+	verbs := make([]string, 0, len(results))
+	width := len("VERB")
+	for _, r := range results {
+		verbs = append(verbs, r.Request.Verb)
+		width = max(width, len(r.Request.Verb))
+	}
+
+	_, _ = fmt.Fprintf(w, "Checking whether %s can %s %s", results[0].Subject, strings.Join(verbs, ","), formatResource(request))
+	if request.Namespace != "" {
+		_, _ = fmt.Fprintf(w, " in namespace %s", request.Namespace)
+	}
+	_, _ = fmt.Fprintf(w, "\n\n%-*s  %-7s  %s\n", width, "VERB", "RESULT", "GRANTED BY")
+	allowed := 0
+	for _, r := range results {
+		verdict, via := "DENIED", ""
+		switch {
+		case r.BypassedVia != "":
+			verdict, via = "ALLOWED", "authorization bypassed via "+r.BypassedVia
+		case len(r.Grants) > 0:
+			g := r.Grants[0]
+			verdict, via = "ALLOWED", formatTraceBinding(g.Binding)+" -> "+formatRole(g.Role)+": "+FormatRule(g.MatchingRule)
+			if r.PartiallyAllowed {
+				verdict, via = "PARTIAL", "only for "+strings.Join(r.AllowedNames(), ",")+" via "+via
+			}
+			if g.Superuser() {
+				via += " (superuser)"
+			}
+			if more := len(r.Grants) - 1; more > 0 {
+				via += fmt.Sprintf(" (+%d more)", more)
+			}
+		}
+		if r.Allowed {
+			allowed++
+		}
+		_, _ = fmt.Fprintf(w, "%-*s  %-7s  %s\n", width, r.Request.Verb, verdict, via)
+	}
+	_, _ = fmt.Fprintf(w, "\n%d of %d verb(s) allowed\n", allowed, len(results))
+}
+
+// BuildVerbsOutput converts the results of a check of several verbs into an
+// array with one JSON result per verb
+func BuildVerbsOutput(results []*rbac.PermissionResult, ctx *ContextInfo) []JSONOutput {
+	out := make([]JSONOutput, 0, len(results))
+	for _, r := range results {
+		out = append(out, BuildJSONOutput(r, ctx))
+	}
+	return out
+}
+
+// PrintVerbsJSON outputs a check of several verbs as a JSON array
+func PrintVerbsJSON(w io.Writer, results []*rbac.PermissionResult, ctx *ContextInfo) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildVerbsOutput(results, ctx))
+}
+
+// PrintVerbsYAML outputs a check of several verbs as a YAML sequence
+func PrintVerbsYAML(w io.Writer, results []*rbac.PermissionResult, ctx *ContextInfo) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(BuildVerbsOutput(results, ctx))
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// VerificationOutput is the API server's decision, with --verify
+type VerificationOutput struct {
+	Allowed         bool   `json:"allowed"`
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+	// Agrees is false when the API server decided differently from the
+	// local resolution
+	Agrees bool `json:"agrees"`
+}
+
+// BuildVerificationOutput converts the verification of a result, or returns
+// nil when there is none
+func BuildVerificationOutput(result *rbac.PermissionResult) *VerificationOutput {
+	v := result.Verification
+	if v == nil {
+		return nil
+	}
+	return &VerificationOutput{
+		Allowed:         v.Allowed,
+		Denied:          v.Denied,
+		Reason:          v.Reason,
+		EvaluationError: v.EvaluationError,
+		Agrees:          v.Agrees(result.Allowed),
+	}
+}
+
+// VerificationMismatch explains the likely causes when the API server
+// disagrees with the local resolution, or returns "" when they agree
+func VerificationMismatch(result *rbac.PermissionResult) string {
+	v := result.Verification
+	if v == nil || v.Agrees(result.Allowed) {
+		return ""
+	}
+	if v.Allowed {
+		return "the API server allows a request that RBAC does not grant. Another authorizer, " +
+			"such as a webhook or the Node authorizer, likely allowed it; the reason above names it if the authorizer gave one."
+	}
+	return "the API server denies a request that RBAC grants. An authorizer that runs before RBAC, " +
+		"such as a webhook, may have denied it, or the RBAC objects changed since they were read. " +
+		"The review only carries the groups known here, so memberships your identity provider adds are not part of either answer."
+}
+
+// PrintVerification compares the local answer with the API server's
+func PrintVerification(w io.Writer, result *rbac.PermissionResult) {
+	v := result.Verification
+	if v == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "Verification (SubjectAccessReview):\n")
+	_, _ = fmt.Fprintf(w, "  Local resolution: %s\n", reviewVerdict(result.Allowed, false))
+	server := reviewVerdict(v.Allowed, v.Denied)
+	if v.Reason != "" {
+		server += " (" + v.Reason + ")"
+	}
+	_, _ = fmt.Fprintf(w, "  API server:       %s\n", server)
+	if v.EvaluationError != "" {
+		_, _ = fmt.Fprintf(w, "  Evaluation error: %s\n", v.EvaluationError)
+	}
+	if mismatch := VerificationMismatch(result); mismatch != "" {
+		_, _ = fmt.Fprintf(w, "Warning: %s\n", mismatch)
+	} else {
+		_, _ = fmt.Fprintf(w, "  The API server agrees.\n")
+	}
+}
+
+func reviewVerdict(allowed, denied bool) string {
+	switch {
+	case allowed:
+		return "ALLOWED"
+	case denied:
+		return "DENIED (explicitly)"
+	default:
+		return "DENIED"
+	}
+}
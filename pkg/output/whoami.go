@@ -0,0 +1,140 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// IdentityOutput is who the API server says the caller is
+type IdentityOutput struct {
+	Username string              `json:"username"`
+	UID      string              `json:"uid,omitempty"`
+	Groups   []string            `json:"groups,omitempty"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+}
+
+// WhoAmIOutput is the JSON/YAML structure for the whoami command
+type WhoAmIOutput struct {
+	// Kubeconfig is the identity derived from the current context, which
+	// can-i uses when --as is not given
+	Kubeconfig ContextOutput `json:"kubeconfig"`
+
+	// Server is the identity confirmed by a SelfSubjectReview, or
+	// ServerError says why there is none
+	Server      *IdentityOutput `json:"server,omitempty"`
+	ServerError string          `json:"serverError,omitempty"`
+
+	// Differs is true when the API server sees another username, or not all
+	// of the kubeconfig groups
+	Differs bool `json:"differs"`
+}
+
+// BuildWhoAmIOutput combines the kubeconfig identity with the API server's
+// view of it; user is nil when the server could not be asked
+func BuildWhoAmIOutput(ctx *ContextInfo, user *authenticationv1.UserInfo, serverErr error) WhoAmIOutput {
+	out := WhoAmIOutput{
+		Kubeconfig: ContextOutput{
+			ContextName: ctx.ContextName,
+			ClusterName: ctx.ClusterName,
+			AuthInfo:    ctx.AuthInfo,
+			UserName:    ctx.UserName,
+			Groups:      ctx.Groups,
+			AuthMethod:  ctx.AuthMethod,
+			Namespace:   ctx.Namespace,
+		},
+	}
+	if serverErr != nil {
+		out.ServerError = serverErr.Error()
+	}
+	if user == nil {
+		return out
+	}
+
+	out.Server = &IdentityOutput{Username: user.Username, UID: user.UID, Groups: user.Groups}
+	if len(user.Extra) > 0 {
+		out.Server.Extra = make(map[string][]string, len(user.Extra))
+		for k, v := range user.Extra {
+			out.Server.Extra[k] = v
+		}
+	}
+	out.Differs = user.Username != ctx.UserName
+	for _, group := range ctx.Groups {
+		if !slices.Contains(user.Groups, group) {
+			out.Differs = true
+		}
+	}
+	return out
+}
+
+// PrintWhoAmI outputs the kubeconfig identity and the API server's view of it
+func PrintWhoAmI(w io.Writer, out WhoAmIOutput) {
+	k := out.Kubeconfig
+	_, _ = fmt.Fprintf(w, "Context:    %s\n", k.ContextName)
+	_, _ = fmt.Fprintf(w, "Cluster:    %s\n", k.ClusterName)
+	_, _ = fmt.Fprintf(w, "AuthInfo:   %s\n", k.AuthInfo)
+	_, _ = fmt.Fprintf(w, "AuthMethod: %s\n", k.AuthMethod)
+	if k.Namespace != "" {
+		_, _ = fmt.Fprintf(w, "Namespace:  %s\n", k.Namespace)
+	}
+
+	_, _ = fmt.Fprintf(w, "\nFrom kubeconfig (used by can-i without --as):\n")
+	printIdentity(w, k.UserName, "", k.Groups)
+
+	if out.Server == nil {
+		_, _ = fmt.Fprintf(w, "\nNot confirmed by the API server: %s\n", out.ServerError)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\nConfirmed by the API server (SelfSubjectReview):\n")
+	printIdentity(w, out.Server.Username, out.Server.UID, out.Server.Groups)
+	keys := make([]string, 0, len(out.Server.Extra))
+	for key := range out.Server.Extra {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(w, "  Extra:    %s=%s\n", key, strings.Join(out.Server.Extra[key], ","))
+	}
+
+	if out.Differs {
+		_, _ = fmt.Fprintf(w, "\nWarning: the API server sees a different identity than the kubeconfig shows. "+
+			"To check the server's view, pass --as %s", out.Server.Username)
+		for _, group := range out.Server.Groups {
+			if group != "system:authenticated" {
+				_, _ = fmt.Fprintf(w, " --as-group %s", group)
+			}
+		}
+		_, _ = fmt.Fprintln(w, " to can-i.")
+	}
+}
+
+func printIdentity(w io.Writer, username, uid string, groups []string) {
+	_, _ = fmt.Fprintf(w, "  Username: %s\n", username)
+	if uid != "" {
+		_, _ = fmt.Fprintf(w, "  UID:      %s\n", uid)
+	}
+	if len(groups) > 0 {
+		_, _ = fmt.Fprintf(w, "  Groups:   %s\n", strings.Join(groups, ", "))
+	} else {
+		_, _ = fmt.Fprintf(w, "  Groups:   (none known)\n")
+	}
+}
+
+// PrintWhoAmIJSON outputs the whoami result as JSON
+func PrintWhoAmIJSON(w io.Writer, out WhoAmIOutput) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// PrintWhoAmIYAML outputs the whoami result as YAML
+func PrintWhoAmIYAML(w io.Writer, out WhoAmIOutput) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(out)
+}
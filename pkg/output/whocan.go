@@ -0,0 +1,120 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// WhoCanSubjectOutput is one subject granted the request, with every path
+type WhoCanSubjectOutput struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Restricted is set when every grant is limited to resourceNames, so
+	// the subject can only reach the objects in AllowedNames
+	Restricted   bool          `json:"restricted,omitempty"`
+	AllowedNames []string      `json:"allowedNames,omitempty"`
+	Grants       []GrantOutput `json:"grants"`
+}
+
+// WhoCanOutput is the JSON/YAML structure for a who-can lookup. Partial is
+// true when --keep-going skipped any namespace.
+type WhoCanOutput struct {
+	Request  RequestOutput         `json:"request"`
+	Subjects []WhoCanSubjectOutput `json:"subjects"`
+	Partial  bool                  `json:"partial"`
+	Skipped  []SkippedScopeOutput  `json:"skipped"`
+}
+
+// BuildWhoCanOutput converts a who-can lookup into its JSON structure
+func BuildWhoCanOutput(request rbac.PermissionRequest, subjects []rbac.SubjectGrants, skipped []SkippedScopeOutput) WhoCanOutput {
+	out := WhoCanOutput{
+		Request:  buildRequestOutput(request),
+		Subjects: []WhoCanSubjectOutput{},
+		Partial:  len(skipped) > 0,
+		Skipped:  skipped,
+	}
+	if out.Skipped == nil {
+		out.Skipped = []SkippedScopeOutput{}
+	}
+	for _, sg := range subjects {
+		s := WhoCanSubjectOutput{Kind: sg.Subject.Kind, Name: sg.Subject.Name, Namespace: sg.Subject.Namespace, Restricted: sg.Restricted()}
+		if s.Restricted {
+			s.AllowedNames = sg.AllowedNames()
+		}
+		for _, g := range sg.Grants {
+			grant := buildGrantOutput(g)
+			grant.NameMatch = g.NameMatch(request)
+			s.Grants = append(s.Grants, grant)
+		}
+		out.Subjects = append(out.Subjects, s)
+	}
+	return out
+}
+
+// whoCanScope describes where a who-can lookup looked
+func whoCanScope(request rbac.PermissionRequest, allNamespaces bool) string {
+	if allNamespaces {
+		return "in some namespace"
+	}
+	if request.Namespace != "" {
+		return "in namespace " + request.Namespace
+	}
+	return "cluster-wide"
+}
+
+// PrintWhoCan outputs the subjects granted a request, one path per line
+// under each subject
+func PrintWhoCan(w io.Writer, request rbac.PermissionRequest, allNamespaces bool, subjects []rbac.SubjectGrants) {
+	where := whoCanScope(request, allNamespaces)
+	if len(subjects) == 0 {
+		_, _ = fmt.Fprintf(w, "No bound subjects can %s %s %s\n", request.Verb, formatResource(request), where)
+	} else {
+		_, _ = fmt.Fprintf(w, "%d subject(s) can %s %s %s:\n", len(subjects), request.Verb, formatResource(request), where)
+	}
+
+	for _, sg := range subjects {
+		_, _ = fmt.Fprintf(w, "\n%s%s\n", sg, restrictedNote(sg))
+		for _, g := range sg.Grants {
+			_, _ = fmt.Fprintf(w, "  %s -> %s\n", formatTraceBinding(g.Binding), formatRole(g.Role))
+			_, _ = fmt.Fprintf(w, "    Rule: %s\n", FormatRule(g.MatchingRule))
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\nMembers of %s are also allowed; they bypass RBAC.\n", rbac.SystemMastersGroup)
+}
+
+// restrictedNote marks a subject that can only reach named objects
+func restrictedNote(sg rbac.SubjectGrants) string {
+	if !sg.Restricted() {
+		return ""
+	}
+	return " (only " + strings.Join(sg.AllowedNames(), ", ") + ")"
+}
+
+func formatRole(r rbac.RoleInfo) string {
+	if r.Namespace != "" {
+		return r.Kind + "/" + r.Namespace + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Name
+}
+
+// PrintWhoCanJSON outputs a who-can lookup as JSON
+func PrintWhoCanJSON(w io.Writer, request rbac.PermissionRequest, subjects []rbac.SubjectGrants, skipped []SkippedScopeOutput) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildWhoCanOutput(request, subjects, skipped))
+}
+
+// PrintWhoCanYAML outputs a who-can lookup as YAML
+func PrintWhoCanYAML(w io.Writer, request rbac.PermissionRequest, subjects []rbac.SubjectGrants, skipped []SkippedScopeOutput) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	return encoder.Encode(BuildWhoCanOutput(request, subjects, skipped))
+}
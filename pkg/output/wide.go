@@ -0,0 +1,122 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+func init() {
+	Register("wide", func(PrinterOptions) Printer { return &WidePrinter{} })
+}
+
+// WidePrinter outputs the verdict and then one table row per path, for
+// results with more grants than the text format shows comfortably
+type WidePrinter struct{}
+
+func (p *WidePrinter) Print(w io.Writer, result *rbac.PermissionResult, ctx *ContextInfo) error {
+	if result.Mode == rbac.ModeSelfAccessReview {
+		printSelfAccessReview(w, result)
+		return nil
+	}
+
+	where := ""
+	if result.Request.Namespace != "" {
+		where = " in namespace " + result.Request.Namespace
+	}
+	switch {
+	case !result.Allowed && !result.PartiallyAllowed:
+		_, _ = fmt.Fprintf(w, "DENIED: No RBAC rules grant %s %s to %s%s\n", result.Request.Verb, formatResource(result.Request), result.Subject.String(), where)
+		PrintDanglingBindings(w, result.DanglingBindings)
+		PrintDiagnostics(w, result.Diagnostics)
+		return nil
+	case result.PartiallyAllowed:
+		_, _ = fmt.Fprintf(w, "ALLOWED only for %s: %s can %s %s%s only by those names\n", strings.Join(result.AllowedNames(), ", "), result.Subject.String(), result.Request.Verb, formatResource(result.Request), where)
+	default:
+		_, _ = fmt.Fprintf(w, "ALLOWED: %s can %s %s%s\n", result.Subject.String(), result.Request.Verb, formatResource(result.Request), where)
+	}
+
+	if result.BypassedVia != "" {
+		_, _ = fmt.Fprintf(w, "Authorization bypassed via %s; no binding chain is involved.\n", result.BypassedVia)
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(w)
+	table := NewTable("PATH", "BINDING", "ROLE", "RULE", "SCOPE", "MATCHED-VIA")
+	for i, grant := range result.Grants {
+		role := formatRole(grant.Role)
+		if grant.Superuser() {
+			role += " (superuser)"
+		}
+		via := grant.MatchedVia()
+		if via == "" {
+			via = "-"
+		}
+		table.AddRow(fmt.Sprint(i+1), formatTraceBinding(grant.Binding), role, formatCompactRule(grant.MatchingRule), string(grant.Scope), via)
+	}
+	if err := table.Print(w); err != nil {
+		return err
+	}
+	PrintDanglingBindings(w, result.DanglingBindings)
+	PrintDiagnostics(w, result.Diagnostics)
+	return nil
+}
+
+// PrintWhoCanWide outputs the subjects granted a request as one table row
+// per subject and path
+func PrintWhoCanWide(w io.Writer, request rbac.PermissionRequest, allNamespaces bool, subjects []rbac.SubjectGrants) error {
+	if len(subjects) == 0 {
+		PrintWhoCan(w, request, allNamespaces, subjects)
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "%d subject(s) can %s %s %s:\n\n", len(subjects), request.Verb, formatResource(request), whoCanScope(request, allNamespaces))
+	table := NewTable("SUBJECT", "BINDING", "ROLE", "RULE", "SCOPE")
+	for _, sg := range subjects {
+		for _, g := range sg.Grants {
+			table.AddRow(sg.String()+restrictedNote(sg), formatTraceBinding(g.Binding), formatRole(g.Role), formatCompactRule(g.MatchingRule), string(g.Scope))
+		}
+	}
+	if err := table.Print(w); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "\nMembers of %s are also allowed; they bypass RBAC.\n", rbac.SystemMastersGroup)
+	return nil
+}
+
+// formatCompactRule formats a rule on one line the way kubectl names what it
+// grants, e.g. "get,list pods,deployments.apps" or "get /healthz". Resources
+// in every API group are named once and marked "(all API groups)".
+func formatCompactRule(rule rbacv1.PolicyRule) string {
+	verbs := strings.Join(rule.Verbs, ",")
+	if len(rule.NonResourceURLs) > 0 {
+		return verbs + " " + strings.Join(rule.NonResourceURLs, ",")
+	}
+	groups := rule.APIGroups
+	allGroups := slices.Contains(groups, rbacv1.APIGroupAll)
+	if len(groups) == 0 || allGroups {
+		// No group to qualify the resources with
+		groups = []string{""}
+	}
+	var resources []string
+	for _, group := range groups {
+		for _, resource := range rule.Resources {
+			if group != "" {
+				resource += "." + group
+			}
+			resources = append(resources, resource)
+		}
+	}
+	compact := verbs + " " + strings.Join(resources, ",")
+	if allGroups {
+		compact += " (all API groups)"
+	}
+	if len(rule.ResourceNames) > 0 {
+		compact += " (names: " + strings.Join(rule.ResourceNames, ",") + ")"
+	}
+	return compact
+}
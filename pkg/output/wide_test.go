@@ -0,0 +1,49 @@
+package output
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestFormatCompactRule(t *testing.T) {
+	tests := []struct {
+		name string
+		rule rbacv1.PolicyRule
+		want string
+	}{
+		{
+			name: "core and named groups",
+			rule: rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments"}},
+			want: "get,list pods,deployments,pods.apps,deployments.apps",
+		},
+		{
+			name: "no API groups",
+			rule: rbacv1.PolicyRule{Verbs: []string{"get"}, Resources: []string{"pods", "services"}},
+			want: "get pods,services",
+		},
+		{
+			name: "all API groups",
+			rule: rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"apps", "*"}, Resources: []string{"*"}},
+			want: "* * (all API groups)",
+		},
+		{
+			name: "resource names",
+			rule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"tls"}},
+			want: "get secrets (names: tls)",
+		},
+		{
+			name: "non-resource URLs",
+			rule: rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz", "/livez"}},
+			want: "get /healthz,/livez",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatCompactRule(tt.rule); got != tt.want {
+				t.Errorf("formatCompactRule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// WildcardAudit is the result of checking a verb against all resources: the
+// rules that grant it on "*", each with the risky categories it triggers
+type WildcardAudit struct {
+	Subject   rbac.Subject
+	Verb      string
+	APIGroup  string // Set when only one API group was asked about
+	Namespace string
+	Grants    []rbac.WildcardGrant
+	// Risks holds the risky categories of each grant, by index
+	Risks [][]rbac.RiskyPermission
+}
+
+// WildcardGrantOutput is one rule granting a verb on every resource
+type WildcardGrantOutput struct {
+	GrantOutput
+	// AllGroups is set for full wildcards (apiGroups: ["*"])
+	AllGroups bool         `json:"allGroups"`
+	Risks     []RiskOutput `json:"risks,omitempty"`
+}
+
+// RiskOutput is a risky category a grant falls into
+type RiskOutput struct {
+	Category string `json:"category"`
+	Severity string `json:"severity"`
+}
+
+// WildcardAuditOutput is the JSON structure for a wildcard audit
+type WildcardAuditOutput struct {
+	Subject        SubjectOutput         `json:"subject"`
+	Verb           string                `json:"verb"`
+	APIGroup       string                `json:"apiGroup,omitempty"`
+	Namespace      string                `json:"namespace,omitempty"`
+	FullWildcards  int                   `json:"fullWildcards"`
+	GroupWildcards int                   `json:"groupWildcards"`
+	Grants         []WildcardGrantOutput `json:"grants"`
+}
+
+// BuildWildcardAuditOutput converts a wildcard audit into its JSON structure
+func BuildWildcardAuditOutput(audit WildcardAudit) WildcardAuditOutput {
+	out := WildcardAuditOutput{
+		Subject: SubjectOutput{
+			Kind:      audit.Subject.Kind,
+			Name:      audit.Subject.Name,
+			Namespace: audit.Subject.Namespace,
+			Origin:    audit.Subject.Origin,
+		},
+		Verb:      audit.Verb,
+		APIGroup:  audit.APIGroup,
+		Namespace: audit.Namespace,
+		Grants:    []WildcardGrantOutput{},
+	}
+	for i, g := range audit.Grants {
+		if g.AllGroups {
+			out.FullWildcards++
+		} else {
+			out.GroupWildcards++
+		}
+		grant := WildcardGrantOutput{GrantOutput: buildGrantOutput(g.PermissionGrant), AllGroups: g.AllGroups}
+		for _, risk := range audit.risks(i) {
+			grant.Risks = append(grant.Risks, RiskOutput{Category: risk.Category, Severity: risk.Severity})
+		}
+		out.Grants = append(out.Grants, grant)
+	}
+	return out
+}
+
+func (a WildcardAudit) risks(i int) []rbac.RiskyPermission {
+	if i < len(a.Risks) {
+		return a.Risks[i]
+	}
+	return nil
+}
+
+// PrintWildcardAudit outputs a wildcard audit in human-readable form
+func PrintWildcardAudit(w io.Writer, audit WildcardAudit) {
+	scope := "all resources"
+	if audit.APIGroup != "" {
+		scope = fmt.Sprintf("all resources in API group %q", audit.APIGroup)
+	}
+	where := ""
+	if audit.Namespace != "" {
+		where = " in namespace " + audit.Namespace
+	}
+	_, _ = fmt.Fprintf(w, "Wildcard audit: rules granting %s on %s to %s%s\n\n", audit.Verb, scope, audit.Subject, where)
+
+	if len(audit.Grants) == 0 {
+		_, _ = fmt.Fprintf(w, "No rule grants %s on resources [\"*\"].\n", audit.Verb)
+		_, _ = fmt.Fprintf(w, "This does not cover grants on named resources; check those with a normal can-i.\n")
+		return
+	}
+
+	full := 0
+	for i, g := range audit.Grants {
+		if g.AllGroups {
+			full++
+			_, _ = fmt.Fprintf(w, "FULL WILDCARD: every resource in every API group\n")
+		} else {
+			_, _ = fmt.Fprintf(w, "GROUP WILDCARD: every resource in API group(s) %s\n", formatGroups(g.MatchingRule.APIGroups))
+		}
+		_, _ = fmt.Fprintf(w, "  %s %s", g.Binding.Kind, g.Binding.Name)
+		if g.Binding.Namespace != "" {
+			_, _ = fmt.Fprintf(w, " (namespace: %s)", g.Binding.Namespace)
+		}
+		_, _ = fmt.Fprintf(w, " -> %s %s\n", g.Role.Kind, g.Role.Name)
+		_, _ = fmt.Fprintf(w, "  Rule: verbs=[%s] apiGroups=[%s] resources=[%s]\n",
+			strings.Join(g.MatchingRule.Verbs, ", "), formatGroups(g.MatchingRule.APIGroups), strings.Join(g.MatchingRule.Resources, ", "))
+		if risks := audit.risks(i); len(risks) > 0 {
+			categories := make([]string, len(risks))
+			for j, risk := range risks {
+				categories[j] = fmt.Sprintf("%s (%s)", risk.Category, risk.Severity)
+			}
+			_, _ = fmt.Fprintf(w, "  Risky: %s\n", strings.Join(categories, ", "))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	_, _ = fmt.Fprintf(w, "%d full wildcard(s), %d per-group wildcard(s)\n", full, len(audit.Grants)-full)
+}
+
+// formatGroups quotes API groups so the core group ("") is visible
+func formatGroups(groups []string) string {
+	quoted := make([]string, len(groups))
+	for i, g := range groups {
+		quoted[i] = fmt.Sprintf("%q", g)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// PrintWildcardAuditJSON outputs a wildcard audit as JSON
+func PrintWildcardAuditJSON(w io.Writer, audit WildcardAudit) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(BuildWildcardAuditOutput(audit))
+}
@@ -0,0 +1,160 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Access levels, named after the default admin, edit, and view ClusterRoles
+// whose reach they approximate
+const (
+	AccessAdmin   = "admin"
+	AccessEdit    = "edit"
+	AccessView    = "view"
+	AccessLimited = "limited" // Only verbs such as use or impersonate
+)
+
+var accessRank = map[string]int{AccessLimited: 1, AccessView: 2, AccessEdit: 3, AccessAdmin: 4}
+
+var writeVerbs = []string{"create", "update", "patch", "delete", "deletecollection"}
+
+// RuleAccessLevel approximates how much a single rule grants. Full wildcards
+// and write access to roles or bindings are admin; any other write is edit;
+// get, list, or watch alone is view.
+func RuleAccessLevel(rule rbacv1.PolicyRule) string {
+	writes := false
+	for _, v := range writeVerbs {
+		if matchesVerb(rule.Verbs, v) {
+			writes = true
+		}
+	}
+	if writes || matchesVerb(rule.Verbs, "escalate") || matchesVerb(rule.Verbs, "bind") {
+		if matchesVerb(rule.Verbs, rbacv1.VerbAll) && matchesResource(rule.Resources, rbacv1.ResourceAll, "") {
+			return AccessAdmin
+		}
+		if matchesAPIGroup(rule.APIGroups, rbacv1.GroupName) {
+			for _, r := range []string{"roles", "rolebindings", "clusterroles", "clusterrolebindings"} {
+				if matchesResource(rule.Resources, r, "") {
+					return AccessAdmin
+				}
+			}
+		}
+		if writes {
+			return AccessEdit
+		}
+	}
+	for _, v := range []string{"get", "list", "watch"} {
+		if matchesVerb(rule.Verbs, v) {
+			return AccessView
+		}
+	}
+	return AccessLimited
+}
+
+// NamespaceAccess is the strongest access a subject has in one scope
+type NamespaceAccess struct {
+	Namespace string // Empty for cluster-wide access
+	Level     string
+	// Binding and Role grant the strongest access; Bindings counts every
+	// binding that gives the subject access in the scope
+	Binding  BindingInfo
+	Role     RoleInfo
+	Bindings int
+}
+
+// Summary returns e.g. "edit-equivalent via RoleBinding dev-edit"
+func (a NamespaceAccess) Summary() string {
+	s := fmt.Sprintf("%s-equivalent via %s %s", a.Level, a.Binding.Kind, a.Binding.Name)
+	if a.Bindings > 1 {
+		s += fmt.Sprintf(" (+%d more)", a.Bindings-1)
+	}
+	return s
+}
+
+// AllGrants resolves every rule bound to subject: cluster-wide grants from
+// ClusterRoleBindings and namespaced grants from RoleBindings in all
+// namespaces. Dangling bindings are skipped.
+func (r *Resolver) AllGrants(ctx context.Context, subject Subject) ([]PermissionGrant, error) {
+	grants, err := r.ResolveAllPermissions(ctx, subject, "")
+	if err != nil {
+		return nil, err
+	}
+
+	groups := GetImplicitGroups(subject)
+	rbs, err := r.roleBindingsFor(ctx, subject, groups, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	for _, rb := range rbs {
+		matched := bindingMatchesSubject(rb.Subjects, subject, groups)
+		if matched < 0 {
+			continue
+		}
+		rules, role, err := r.boundRules(ctx, rb.RoleRef, rb.Namespace)
+		if err != nil {
+			// A dangling binding gives no access
+			continue
+		}
+		binding := BindingInfo{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace, Owner: OwnerFromMeta(rb.ObjectMeta)}
+		for i, rule := range rules {
+			if r.clusterOnlyRule(ctx, rule) {
+				continue
+			}
+			grants = append(grants, PermissionGrant{
+				Binding: binding, Role: role, MatchedSubject: &rb.Subjects[matched], SubjectIndex: matched, MatchingRule: rule, RuleIndex: i,
+				Scope: ScopeNamespace, EffectiveNamespace: rb.Namespace,
+			})
+		}
+	}
+	return grants, nil
+}
+
+// AccessByNamespace finds every namespace where a RoleBinding gives subject
+// any access, and any cluster-wide access from ClusterRoleBindings. Namespaces
+// are sorted; cluster is nil when no ClusterRoleBinding applies.
+func (r *Resolver) AccessByNamespace(ctx context.Context, subject Subject) (cluster *NamespaceAccess, namespaces []NamespaceAccess, err error) {
+	grants, err := r.AllGrants(ctx, subject)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var clusterGrants []PermissionGrant
+	byNamespace := make(map[string][]PermissionGrant)
+	for _, g := range grants {
+		if g.Scope == ScopeClusterWide {
+			clusterGrants = append(clusterGrants, g)
+		} else {
+			byNamespace[g.Binding.Namespace] = append(byNamespace[g.Binding.Namespace], g)
+		}
+	}
+	if len(clusterGrants) > 0 {
+		cluster = strongestAccess(clusterGrants)
+	}
+
+	for ns, g := range byNamespace {
+		access := strongestAccess(g)
+		access.Namespace = ns
+		namespaces = append(namespaces, *access)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Namespace < namespaces[j].Namespace })
+	return cluster, namespaces, nil
+}
+
+// strongestAccess picks the grant with the highest access level, preferring
+// the first one found on ties
+func strongestAccess(grants []PermissionGrant) *NamespaceAccess {
+	var best *NamespaceAccess
+	bindings := make(map[string]bool)
+	for _, g := range grants {
+		bindings[g.Binding.Kind+"/"+g.Binding.Namespace+"/"+g.Binding.Name] = true
+		level := RuleAccessLevel(g.MatchingRule)
+		if best == nil || accessRank[level] > accessRank[best.Level] {
+			best = &NamespaceAccess{Level: level, Binding: g.Binding, Role: g.Role}
+		}
+	}
+	best.Bindings = len(bindings)
+	return best
+}
@@ -0,0 +1,93 @@
+package rbac
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestRuleAccessLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		rule rbacv1.PolicyRule
+		want string
+	}{
+		{name: "full wildcard", rule: rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}, want: AccessAdmin},
+		{name: "write rolebindings", rule: rbacv1.PolicyRule{Verbs: []string{"create"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"rolebindings"}}, want: AccessAdmin},
+		{name: "bind roles", rule: rbacv1.PolicyRule{Verbs: []string{"bind"}, APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}}, want: AccessAdmin},
+		{name: "write pods", rule: rbacv1.PolicyRule{Verbs: []string{"get", "delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}, want: AccessEdit},
+		{name: "read only", rule: rbacv1.PolicyRule{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{""}, Resources: []string{"*"}}, want: AccessView},
+		{name: "use psp", rule: rbacv1.PolicyRule{Verbs: []string{"use"}, APIGroups: []string{"policy"}, Resources: []string{"podsecuritypolicies"}}, want: AccessLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RuleAccessLevel(tt.rule); got != tt.want {
+				t.Errorf("RuleAccessLevel() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllGrants_RoleBindingScope(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"nodes", "nodes/proxy", "persistentvolumes"}},
+			{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}},
+			{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+		},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "ops"},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "ops"},
+	})
+
+	tests := []struct {
+		name      string
+		subject   string
+		wantRules []int
+		wantNS    string
+		wantNote  bool
+	}{
+		// Rules for nodes, persistentvolumes, and /metrics confer nothing
+		// through a RoleBinding
+		{name: "RoleBinding", subject: "alice", wantRules: []int{0, 3}, wantNS: "dev", wantNote: true},
+		{name: "ClusterRoleBinding", subject: "bob", wantRules: []int{0, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grants, err := NewResolver(mock).AllGrants(context.Background(), Subject{Kind: "User", Name: tt.subject})
+			if err != nil {
+				t.Fatalf("AllGrants() error = %v", err)
+			}
+			var rules []int
+			for _, g := range grants {
+				rules = append(rules, g.RuleIndex)
+				if g.EffectiveNamespace != tt.wantNS {
+					t.Errorf("EffectiveNamespace = %q, want %q", g.EffectiveNamespace, tt.wantNS)
+				}
+				if (g.ScopeNote() != "") != tt.wantNote {
+					t.Errorf("ScopeNote() = %q", g.ScopeNote())
+				}
+			}
+			if !slices.Equal(rules, tt.wantRules) {
+				t.Errorf("rules = %v, want %v", rules, tt.wantRules)
+			}
+		})
+	}
+}
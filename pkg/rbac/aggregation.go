@@ -0,0 +1,198 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AggregationSource is the ClusterRole an aggregated ClusterRole's rule was
+// copied from by the aggregation controller
+type AggregationSource struct {
+	Name string
+	// MatchedLabels are the source role's labels that the aggregate's
+	// clusterRoleSelectors select on
+	MatchedLabels map[string]string
+	// Computed is set when the aggregate's rules were computed by
+	// AggregateClusterRoles rather than by the cluster
+	Computed bool
+	// Source and RuleIndex locate the rule in the source role, when it was
+	// read from a local manifest
+	Source    *ManifestSource
+	RuleIndex int
+}
+
+// AggregatedAnnotation marks an aggregated ClusterRole whose rules were
+// computed by AggregateClusterRoles
+const AggregatedAnnotation = "rbac-why.io/aggregated"
+
+// AggregateClusterRoles fills in the rules of aggregated ClusterRoles from
+// the ClusterRoles their aggregationRule selects, as the aggregation
+// controller does in a cluster. Manifests and snapshots need it, since an
+// aggregate such as admin or edit is usually written without rules. Roles
+// whose rules change are marked with AggregatedAnnotation, and their names
+// are returned sorted.
+func AggregateClusterRoles(roles []rbacv1.ClusterRole) []string {
+	changed := make(map[string]bool)
+	// An aggregate may select another aggregate, whose rules may only be
+	// filled in on this pass, so repeat until nothing changes
+	for range len(roles) {
+		progress := false
+		for i := range roles {
+			role := &roles[i]
+			if role.AggregationRule == nil {
+				continue
+			}
+			rules := aggregatedRules(roles, role)
+			if sameRules(rules, role.Rules) {
+				continue
+			}
+			role.Rules = rules
+			if role.Annotations == nil {
+				role.Annotations = make(map[string]string)
+			}
+			role.Annotations[AggregatedAnnotation] = "true"
+			changed[role.Name] = true
+			progress = true
+		}
+		if !progress {
+			break
+		}
+	}
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aggregatedRules returns the rules the aggregation controller would give
+// aggregate: the rules of each selected ClusterRole, by selector and then by
+// role name, without duplicates
+func aggregatedRules(roles []rbacv1.ClusterRole, aggregate *rbacv1.ClusterRole) []rbacv1.PolicyRule {
+	var rules []rbacv1.PolicyRule
+	for _, selector := range aggregate.AggregationRule.ClusterRoleSelectors {
+		sel, err := metav1.LabelSelectorAsSelector(&selector)
+		if err != nil {
+			continue
+		}
+		var selected []*rbacv1.ClusterRole
+		for i := range roles {
+			if roles[i].Name != aggregate.Name && sel.Matches(labels.Set(roles[i].Labels)) {
+				selected = append(selected, &roles[i])
+			}
+		}
+		sort.Slice(selected, func(i, j int) bool { return selected[i].Name < selected[j].Name })
+		for _, cr := range selected {
+			for _, rule := range cr.Rules {
+				if !containsRule(rules, rule) {
+					rules = append(rules, rule)
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// sameRules reports whether a and b hold the same rules, in any order
+func sameRules(a, b []rbacv1.PolicyRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, rule := range a {
+		if !containsRule(b, rule) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsRule(rules []rbacv1.PolicyRule, rule rbacv1.PolicyRule) bool {
+	for _, r := range rules {
+		if equality.Semantic.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregationLookup finds the ClusterRoles that contributed rules to
+// aggregated ClusterRoles. ClusterRoles are listed once, on first use, and
+// it is safe to share between the goroutines that evaluate namespaces.
+type aggregationLookup struct {
+	r     *Resolver
+	once  sync.Once
+	roles []rbacv1.ClusterRole
+	err   error
+}
+
+func (r *Resolver) newAggregationLookup() *aggregationLookup {
+	return &aggregationLookup{r: r}
+}
+
+// source returns the ClusterRole selected by aggregate's aggregationRule that
+// defines rule, or nil when aggregate isn't aggregated or the rule isn't one
+// of the aggregated ones (it was written into the aggregate directly)
+func (l *aggregationLookup) source(ctx context.Context, aggregate *rbacv1.ClusterRole, rule rbacv1.PolicyRule) *AggregationSource {
+	if aggregate.AggregationRule == nil || len(aggregate.AggregationRule.ClusterRoleSelectors) == 0 {
+		return nil
+	}
+	l.once.Do(func() {
+		list, err := l.r.client.ListClusterRoles(ctx)
+		if err != nil {
+			l.err = err
+		} else {
+			l.roles = list.Items
+		}
+	})
+	if l.err != nil {
+		return nil
+	}
+
+	for _, selector := range aggregate.AggregationRule.ClusterRoleSelectors {
+		sel, err := metav1.LabelSelectorAsSelector(&selector)
+		if err != nil || sel.Empty() {
+			continue
+		}
+		for _, cr := range l.roles {
+			if cr.Name == aggregate.Name || !sel.Matches(labels.Set(cr.Labels)) {
+				continue
+			}
+			for i, r := range cr.Rules {
+				if equality.Semantic.DeepEqual(r, rule) {
+					return &AggregationSource{
+						Name:          cr.Name,
+						MatchedLabels: selectedLabels(selector, cr.Labels),
+						Computed:      aggregate.Annotations[AggregatedAnnotation] != "",
+						Source:        SourceFromMeta(cr.ObjectMeta),
+						RuleIndex:     i,
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// selectedLabels returns the labels whose keys the selector refers to
+func selectedLabels(selector metav1.LabelSelector, set map[string]string) map[string]string {
+	matched := make(map[string]string)
+	for k := range selector.MatchLabels {
+		if v, ok := set[k]; ok {
+			matched[k] = v
+		}
+	}
+	for _, expr := range selector.MatchExpressions {
+		if v, ok := set[expr.Key]; ok {
+			matched[expr.Key] = v
+		}
+	}
+	return matched
+}
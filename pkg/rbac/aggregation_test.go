@@ -0,0 +1,157 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestResolvePermission_AggregatedFrom(t *testing.T) {
+	const aggregateLabel = "rbac.authorization.k8s.io/aggregate-to-admin"
+	podsRule := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}
+	metricsRule := rbacv1.PolicyRule{APIGroups: []string{"metrics.k8s.io"}, Resources: []string{"pods"}, Verbs: []string{"get"}}
+	directRule := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}
+
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+		AggregationRule: &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+			{MatchLabels: map[string]string{aggregateLabel: "true"}},
+		}},
+		Rules: []rbacv1.PolicyRule{podsRule, metricsRule, directRule},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-viewer", Labels: map[string]string{aggregateLabel: "true", "team": "core"}},
+		Rules:      []rbacv1.PolicyRule{podsRule},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-reader", Labels: map[string]string{aggregateLabel: "true"}},
+		Rules:      []rbacv1.PolicyRule{metricsRule},
+	})
+	// Same rule, but not selected by the aggregate
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "unlabeled"},
+		Rules:      []rbacv1.PolicyRule{directRule},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice-admin", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"},
+	})
+
+	tests := []struct {
+		name    string
+		request PermissionRequest
+		want    *AggregationSource
+	}{
+		{
+			name:    "aggregated rule",
+			request: PermissionRequest{Verb: "get", Resource: "pods", Namespace: "dev"},
+			want:    &AggregationSource{Name: "pod-viewer", MatchedLabels: map[string]string{aggregateLabel: "true"}},
+		},
+		{
+			name:    "aggregated rule in another group",
+			request: PermissionRequest{Verb: "get", APIGroup: "metrics.k8s.io", Resource: "pods", Namespace: "dev"},
+			want:    &AggregationSource{Name: "metrics-reader", MatchedLabels: map[string]string{aggregateLabel: "true"}},
+		},
+		{
+			name:    "rule written into the aggregate directly",
+			request: PermissionRequest{Verb: "get", Resource: "configmaps", Namespace: "dev"},
+		},
+	}
+
+	resolver := NewResolver(mock)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolver.ResolvePermission(context.Background(), Subject{Kind: "User", Name: "alice"}, tt.request)
+			if err != nil {
+				t.Fatalf("ResolvePermission() error = %v", err)
+			}
+			if len(result.Grants) != 1 {
+				t.Fatalf("got %d grants, want 1", len(result.Grants))
+			}
+			if got := result.Grants[0].AggregatedFrom; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AggregatedFrom = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateClusterRoles(t *testing.T) {
+	podsRule := rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}
+	deployRule := rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}
+	aggregateTo := func(name string) *rbacv1.AggregationRule {
+		return &rbacv1.AggregationRule{ClusterRoleSelectors: []metav1.LabelSelector{
+			{MatchLabels: map[string]string{"rbac.authorization.k8s.io/aggregate-to-" + name: "true"}},
+		}}
+	}
+	label := func(name string) map[string]string {
+		return map[string]string{"rbac.authorization.k8s.io/aggregate-to-" + name: "true"}
+	}
+
+	tests := []struct {
+		name        string
+		roles       []rbacv1.ClusterRole
+		wantChanged []string
+		wantRules   map[string][]rbacv1.PolicyRule
+	}{
+		{
+			name: "nested aggregates",
+			roles: []rbacv1.ClusterRole{
+				{ObjectMeta: metav1.ObjectMeta{Name: "admin"}, AggregationRule: aggregateTo("admin")},
+				{ObjectMeta: metav1.ObjectMeta{Name: "edit", Labels: label("admin")}, AggregationRule: aggregateTo("edit")},
+				{ObjectMeta: metav1.ObjectMeta{Name: "deploy-editor", Labels: label("edit")}, Rules: []rbacv1.PolicyRule{deployRule}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod-viewer", Labels: label("edit")}, Rules: []rbacv1.PolicyRule{podsRule, deployRule}},
+			},
+			wantChanged: []string{"admin", "edit"},
+			wantRules: map[string][]rbacv1.PolicyRule{
+				"admin": {deployRule, podsRule},
+				"edit":  {deployRule, podsRule},
+			},
+		},
+		{
+			name: "already aggregated by the cluster",
+			roles: []rbacv1.ClusterRole{
+				{ObjectMeta: metav1.ObjectMeta{Name: "edit"}, AggregationRule: aggregateTo("edit"), Rules: []rbacv1.PolicyRule{podsRule, deployRule}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "deploy-editor", Labels: label("edit")}, Rules: []rbacv1.PolicyRule{deployRule}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "pod-viewer", Labels: label("edit")}, Rules: []rbacv1.PolicyRule{podsRule}},
+			},
+			wantRules: map[string][]rbacv1.PolicyRule{"edit": {podsRule, deployRule}},
+		},
+		{
+			name: "nothing selected",
+			roles: []rbacv1.ClusterRole{
+				{ObjectMeta: metav1.ObjectMeta{Name: "view"}, AggregationRule: aggregateTo("view")},
+			},
+			wantRules: map[string][]rbacv1.PolicyRule{"view": nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := AggregateClusterRoles(tt.roles)
+			if !reflect.DeepEqual(changed, tt.wantChanged) && (len(changed) > 0 || len(tt.wantChanged) > 0) {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			for _, role := range tt.roles {
+				want, ok := tt.wantRules[role.Name]
+				if !ok {
+					continue
+				}
+				if !reflect.DeepEqual(role.Rules, want) {
+					t.Errorf("%s rules = %v, want %v", role.Name, role.Rules, want)
+				}
+				marked := role.Annotations[AggregatedAnnotation] != ""
+				if marked != slices.Contains(tt.wantChanged, role.Name) {
+					t.Errorf("%s marked = %v", role.Name, marked)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package rbac
+
+import (
+	"context"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultConcurrency is how many namespaces ResolveInNamespaces evaluates at
+// once unless WithConcurrency says otherwise
+const DefaultConcurrency = 8
+
+// WithConcurrency evaluates up to n namespaces at once in
+// ResolveInNamespaces; n below 1 evaluates them one at a time
+func WithConcurrency(n int) ResolverOption {
+	return func(c *resolverConfig) {
+		c.concurrency = max(n, 1)
+	}
+}
+
+// NamespaceResult is the outcome of a permission check in one namespace
+type NamespaceResult struct {
+	Namespace string
+	// Allowed is true when a RoleBinding in the namespace or a cluster-wide
+	// grant allows the request for every object
+	Allowed bool
+	// PartiallyAllowed is set, with Allowed false, when every grant that
+	// applies in the namespace is restricted to resourceNames
+	PartiallyAllowed bool
+	// Grants are the namespace's own grants, from its RoleBindings
+	Grants []PermissionGrant
+	// Err is set when the namespace's RoleBindings could not be read, in
+	// which case Allowed only reflects cluster-wide grants
+	Err error
+}
+
+// AllNamespacesResult holds a permission check evaluated in every namespace
+type AllNamespacesResult struct {
+	Request PermissionRequest // Namespace is empty
+	Subject Subject
+
+	// Selector is the label selector the namespaces were chosen by, empty
+	// when every namespace was checked
+	Selector string
+
+	// BypassedVia is set as in PermissionResult; nothing else is then filled in
+	BypassedVia string
+
+	// ClusterWide are the grants from ClusterRoleBindings, which apply in
+	// every namespace
+	ClusterWide []PermissionGrant
+	Namespaces  []NamespaceResult
+	Diagnostics []Diagnostic
+
+	// DanglingBindings are bindings that apply to the subject but refer to
+	// a role that doesn't exist
+	DanglingBindings []DanglingBinding
+}
+
+// ResolveInNamespaces evaluates request in each of namespaces, ignoring
+// request.Namespace. ClusterRoleBindings are read once; a namespace whose
+// RoleBindings can't be listed gets an error instead of failing the lookup.
+// Namespaces are evaluated concurrently, up to the resolver's concurrency,
+// and reported in the order given. Cancelling ctx stops the lookup.
+func (r *Resolver) ResolveInNamespaces(ctx context.Context, subject Subject, request PermissionRequest, namespaces []string) (*AllNamespacesResult, error) {
+	request.Namespace = ""
+	result := &AllNamespacesResult{Request: request, Subject: subject}
+
+	groups := GetImplicitGroups(subject)
+	if group := BypassingGroup(subject, groups); group != "" {
+		result.BypassedVia = group
+		return result, nil
+	}
+
+	aggregation := r.newAggregationLookup()
+	grants, dangling, diagnostics, err := r.clusterGrants(ctx, subject, groups, request, aggregation)
+	if err != nil {
+		return nil, err
+	}
+	result.ClusterWide = grants
+	result.addDangling(dangling)
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+
+	// RoleBindings don't grant cluster-scoped resources in any namespace
+	clusterScoped := r.clusterScoped(ctx, request)
+
+	// Each namespace fills in its own slot, so the results keep the order of
+	// namespaces however the goroutines are scheduled
+	type evaluation struct {
+		result      NamespaceResult
+		dangling    []DanglingBinding
+		diagnostics []Diagnostic
+	}
+	evaluations := make([]evaluation, len(namespaces))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(r.concurrency, 1))
+	for i, ns := range namespaces {
+		evaluations[i].result = NamespaceResult{Namespace: ns}
+		evaluations[i].result.setAllowed(result.ClusterWide, nil)
+		if clusterScoped {
+			continue
+		}
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			nsRequest := request
+			nsRequest.Namespace = ns
+			grants, dangling, diagnostics, err := r.namespaceGrants(gctx, subject, groups, nsRequest, aggregation)
+			if err != nil && gctx.Err() != nil {
+				// Cancelled, not a problem with this namespace
+				return gctx.Err()
+			}
+			e := &evaluations[i]
+			if err != nil {
+				e.result.Err = err
+				diagnostics = append([]Diagnostic{namespaceDiagnostic(ns, err)}, diagnostics...)
+			}
+			e.result.Grants = grants
+			e.result.setAllowed(result.ClusterWide, grants)
+			e.dangling, e.diagnostics = dangling, diagnostics
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for _, e := range evaluations {
+		result.Namespaces = append(result.Namespaces, e.result)
+		result.addDangling(e.dangling)
+		result.Diagnostics = append(result.Diagnostics, e.diagnostics...)
+	}
+	return result, nil
+}
+
+// setAllowed fills in the verdict from the cluster-wide grants and the
+// namespace's own, the way ResolvePermission does for one namespace
+func (ns *NamespaceResult) setAllowed(clusterWide, grants []PermissionGrant) {
+	unconditional := func(g PermissionGrant) bool { return !g.Conditional }
+	ns.Allowed = slices.ContainsFunc(clusterWide, unconditional) || slices.ContainsFunc(grants, unconditional)
+	ns.PartiallyAllowed = !ns.Allowed && len(clusterWide)+len(grants) > 0
+}
+
+// AllowedNames returns the object names a PartiallyAllowed namespace is
+// allowed for, through cluster-wide grants or its own, sorted. Given an
+// empty NamespaceResult, it returns those of the cluster-wide grants.
+func (r *AllNamespacesResult) AllowedNames(ns NamespaceResult) []string {
+	return conditionalNames(slices.Concat(r.ClusterWide, ns.Grants))
+}
+
+// ClusterWideAllowed reports whether a cluster-wide grant allows the
+// request for every object
+func (r *AllNamespacesResult) ClusterWideAllowed() bool {
+	return slices.ContainsFunc(r.ClusterWide, func(g PermissionGrant) bool { return !g.Conditional })
+}
+
+// addDangling records dangling bindings, with a diagnostic for each
+func (r *AllNamespacesResult) addDangling(dangling []DanglingBinding) {
+	for _, d := range dangling {
+		r.DanglingBindings = append(r.DanglingBindings, d)
+		r.Diagnostics = append(r.Diagnostics, d.Diagnostic())
+	}
+}
@@ -0,0 +1,142 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestResolveInNamespaces(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	sa := rbacv1.Subject{Kind: "ServiceAccount", Name: "deployer", Namespace: "ci"}
+	for _, ns := range []string{"prod", "staging"} {
+		mockClient.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "deployer-secrets", Namespace: ns},
+			Subjects:   []rbacv1.Subject{sa},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+		})
+	}
+	mockClient.ListRoleBindingsErrors = map[string]error{"restricted": fmt.Errorf("forbidden")}
+
+	resolver := NewResolver(mockClient)
+	subject := Subject{Kind: "ServiceAccount", Name: "deployer", Namespace: "ci"}
+	request := PermissionRequest{Verb: "get", Resource: "secrets", Namespace: "ignored"}
+
+	result, err := resolver.ResolveInNamespaces(context.Background(), subject, request, []string{"default", "prod", "restricted", "staging"})
+	if err != nil {
+		t.Fatalf("ResolveInNamespaces() error: %v", err)
+	}
+	if result.Request.Namespace != "" {
+		t.Errorf("Request.Namespace = %q, want empty", result.Request.Namespace)
+	}
+	if len(result.ClusterWide) != 0 {
+		t.Errorf("ClusterWide = %+v, want none", result.ClusterWide)
+	}
+
+	want := map[string]bool{"default": false, "prod": true, "restricted": false, "staging": true}
+	if len(result.Namespaces) != len(want) {
+		t.Fatalf("got %d namespaces, want %d", len(result.Namespaces), len(want))
+	}
+	for _, ns := range result.Namespaces {
+		if ns.Allowed != want[ns.Namespace] {
+			t.Errorf("%s: Allowed = %v, want %v", ns.Namespace, ns.Allowed, want[ns.Namespace])
+		}
+		if (ns.Err != nil) != (ns.Namespace == "restricted") {
+			t.Errorf("%s: Err = %v", ns.Namespace, ns.Err)
+		}
+	}
+
+	// A ClusterRoleBinding allows the request everywhere, and is reported once
+	mockClient.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer-secrets"},
+		Subjects:   []rbacv1.Subject{sa},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	result, err = resolver.ResolveInNamespaces(context.Background(), subject, request, []string{"default", "prod"})
+	if err != nil {
+		t.Fatalf("ResolveInNamespaces() error: %v", err)
+	}
+	if len(result.ClusterWide) != 1 {
+		t.Errorf("ClusterWide = %+v, want one grant", result.ClusterWide)
+	}
+	for _, ns := range result.Namespaces {
+		if !ns.Allowed {
+			t.Errorf("%s: Allowed = false, want true via the ClusterRoleBinding", ns.Namespace)
+		}
+		for _, g := range ns.Grants {
+			if g.Scope != ScopeNamespace {
+				t.Errorf("%s: grant %s has scope %s, want only namespace grants", ns.Namespace, g.Binding.Name, g.Scope)
+			}
+		}
+	}
+}
+
+func TestResolveInNamespaces_Concurrency(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	mockClient.ListRoleBindingsErrors = map[string]error{}
+	var namespaces []string
+	for i := range 50 {
+		ns := fmt.Sprintf("ns-%02d", i)
+		namespaces = append(namespaces, ns)
+		switch i % 3 {
+		case 0:
+			mockClient.AddRoleBinding(rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "alice-secrets", Namespace: ns},
+				Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+			})
+		case 1:
+			mockClient.ListRoleBindingsErrors[ns] = fmt.Errorf("forbidden")
+		}
+	}
+
+	subject := Subject{Kind: "User", Name: "alice"}
+	request := PermissionRequest{Verb: "get", Resource: "secrets"}
+	for _, concurrency := range []int{1, 4, 100} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			resolver := NewResolver(mockClient, WithConcurrency(concurrency))
+			result, err := resolver.ResolveInNamespaces(context.Background(), subject, request, namespaces)
+			if err != nil {
+				t.Fatalf("ResolveInNamespaces() error: %v", err)
+			}
+			if len(result.Namespaces) != len(namespaces) {
+				t.Fatalf("got %d namespaces, want %d", len(result.Namespaces), len(namespaces))
+			}
+			errs := 0
+			for i, ns := range result.Namespaces {
+				if ns.Namespace != namespaces[i] {
+					t.Fatalf("namespace %d = %s, want %s in the order given", i, ns.Namespace, namespaces[i])
+				}
+				if ns.Allowed != (i%3 == 0) || (ns.Err != nil) != (i%3 == 1) {
+					t.Errorf("%s: Allowed = %v, Err = %v", ns.Namespace, ns.Allowed, ns.Err)
+				}
+				if ns.Err != nil {
+					errs++
+				}
+			}
+			if got := len(ErrorDiagnostics(result.Diagnostics)); got != errs {
+				t.Errorf("got %d error diagnostics, want one per failed namespace (%d)", got, errs)
+			}
+		})
+	}
+
+	// Cancelling stops the lookup rather than reporting every namespace as failed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewResolver(mockClient).ResolveInNamespaces(ctx, subject, request, namespaces); err == nil {
+		t.Error("ResolveInNamespaces() with a cancelled context succeeded, want an error")
+	}
+}
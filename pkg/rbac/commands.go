@@ -0,0 +1,110 @@
+package rbac
+
+import (
+	"slices"
+	"strings"
+)
+
+// groupCheckUser is the user CanICommand impersonates for a Group subject,
+// since kubectl impersonates groups only along with a user
+const groupCheckUser = "rbac-why:group-member"
+
+// GrantCommands returns the kubectl commands that show the binding and role
+// behind grant, or nil for a grant the API server reported without them
+func GrantCommands(grant PermissionGrant) []string {
+	if grant.Binding.Kind == SelfSubjectRulesReviewKind {
+		return nil
+	}
+	binding := objectArgs(strings.ToLower(grant.Binding.Kind), grant.Binding.Name, grant.Binding.Namespace)
+	role := objectArgs(strings.ToLower(grant.Role.Kind), grant.Role.Name, grant.Role.Namespace)
+	return []string{
+		"kubectl get " + binding + " -o yaml",
+		"kubectl describe " + binding,
+		"kubectl get " + role + " -o yaml",
+		"kubectl describe " + role,
+	}
+}
+
+// objectArgs returns the kubectl arguments naming one object, e.g.
+// "rolebinding edit -n prod"
+func objectArgs(kind, name, namespace string) string {
+	args := kind + " " + ShellQuote(name)
+	if namespace != "" {
+		args += " -n " + ShellQuote(namespace)
+	}
+	return args
+}
+
+// CanICommand returns the kubectl auth can-i command that asks the API
+// server the same question. With impersonate, it names the subject with
+// --as and its explicit groups with --as-group; otherwise the check is run
+// as the caller, like a subject read from the current context.
+func CanICommand(subject Subject, request PermissionRequest, impersonate bool) string {
+	args := []string{"kubectl", "auth", "can-i", ShellQuote(request.Verb)}
+	if request.NonResourceURL != "" {
+		args = append(args, ShellQuote(request.NonResourceURL))
+	} else {
+		resource := request.Resource
+		if request.APIGroup != "" {
+			resource += "." + request.APIGroup
+		}
+		if request.ResourceName != "" {
+			resource += "/" + request.ResourceName
+		}
+		args = append(args, ShellQuote(resource))
+		if request.Subresource != "" {
+			args = append(args, "--subresource", ShellQuote(request.Subresource))
+		}
+		// An empty namespace is a cluster-wide check, which -A asks for
+		if request.Namespace != "" {
+			args = append(args, "-n", ShellQuote(request.Namespace))
+		} else {
+			args = append(args, "-A")
+		}
+	}
+	if !impersonate {
+		return strings.Join(args, " ")
+	}
+
+	groups := subject.Groups
+	if subject.Kind == "Group" {
+		args = append(args, "--as", groupCheckUser)
+		groups = append([]string{subject.Name}, groups...)
+	} else {
+		args = append(args, "--as", ShellQuote(subject.Canonical()))
+	}
+	for _, group := range groups {
+		args = append(args, "--as-group", ShellQuote(group))
+	}
+	if subject.UID != "" {
+		args = append(args, "--as-uid", ShellQuote(subject.UID))
+	}
+	keys := make([]string, 0, len(subject.Extra))
+	for key := range subject.Extra {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		for _, value := range subject.Extra[key] {
+			args = append(args, "--as-user-extra", ShellQuote(key+"="+value))
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// ShellQuote quotes s for a POSIX shell, leaving it bare when it has only
+// characters the shell doesn't interpret, as most Kubernetes names do
+func ShellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool { return !shellSafe(r) }) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellSafe(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("-_.:/@+,=", r)
+}
@@ -0,0 +1,90 @@
+package rbac
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCanICommand(t *testing.T) {
+	sa := Subject{Kind: "ServiceAccount", Namespace: "prod", Name: "api"}
+	tests := []struct {
+		name        string
+		subject     Subject
+		request     PermissionRequest
+		impersonate bool
+		want        string
+	}{
+		{
+			name:        "service account",
+			subject:     sa,
+			request:     PermissionRequest{Verb: "get", Resource: "secrets", Namespace: "prod"},
+			impersonate: true,
+			want:        "kubectl auth can-i get secrets -n prod --as system:serviceaccount:prod:api",
+		},
+		{
+			name:        "group, subresource, and name",
+			subject:     Subject{Kind: "Group", Name: "oidc:platform team", Groups: []string{"ops"}},
+			request:     PermissionRequest{Verb: "update", APIGroup: "apps", Resource: "deployments", Subresource: "scale", ResourceName: "web.v2", Namespace: "prod"},
+			impersonate: true,
+			want:        "kubectl auth can-i update deployments.apps/web.v2 --subresource scale -n prod --as rbac-why:group-member --as-group 'oidc:platform team' --as-group ops",
+		},
+		{
+			name:        "uid and extra",
+			subject:     Subject{Kind: "User", Name: "jane", UID: "42", Extra: map[string][]string{"scopes": {"read", "write"}, "reason": {"it's down"}}},
+			request:     PermissionRequest{Verb: "*", Resource: "*"},
+			impersonate: true,
+			want:        `kubectl auth can-i '*' '*' -A --as jane --as-uid 42 --as-user-extra 'reason=it'\''s down' --as-user-extra scopes=read --as-user-extra scopes=write`,
+		},
+		{
+			name:    "current context",
+			subject: Subject{Kind: "User", Name: "alice", Groups: []string{"dev"}},
+			request: PermissionRequest{Verb: "get", NonResourceURL: "/healthz"},
+			want:    "kubectl auth can-i get /healthz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanICommand(tt.subject, tt.request, tt.impersonate); got != tt.want {
+				t.Errorf("CanICommand() =\n  %s\nwant\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrantCommands(t *testing.T) {
+	grant := PermissionGrant{
+		Binding: BindingInfo{Kind: "RoleBinding", Name: "system:controller:bootstrap-signer", Namespace: "kube-system"},
+		Role:    RoleInfo{Kind: "ClusterRole", Name: "system:aggregate-to-view"},
+	}
+	want := []string{
+		"kubectl get rolebinding system:controller:bootstrap-signer -n kube-system -o yaml",
+		"kubectl describe rolebinding system:controller:bootstrap-signer -n kube-system",
+		"kubectl get clusterrole system:aggregate-to-view -o yaml",
+		"kubectl describe clusterrole system:aggregate-to-view",
+	}
+	if got := GrantCommands(grant); !slices.Equal(got, want) {
+		t.Errorf("GrantCommands() = %q, want %q", got, want)
+	}
+
+	grant.Binding = BindingInfo{Kind: SelfSubjectRulesReviewKind}
+	if got := GrantCommands(grant); got != nil {
+		t.Errorf("GrantCommands() = %q for a rules review grant, want none", got)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"edit":              "edit",
+		"system:masters":    "system:masters",
+		"eks.amazonaws.com": "eks.amazonaws.com",
+		"":                  "''",
+		"a b":               "'a b'",
+		"$(rm -rf /)":       "'$(rm -rf /)'",
+		"it's":              `'it'\''s'`,
+		"*":                 "'*'",
+	} {
+		if got := ShellQuote(in); got != want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
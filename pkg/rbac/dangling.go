@@ -0,0 +1,85 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DanglingBinding is a binding whose roleRef names a Role or ClusterRole
+// that doesn't exist, so it grants nothing
+type DanglingBinding struct {
+	Binding BindingInfo
+	RoleRef rbacv1.RoleRef
+}
+
+// MissingRole names the role the binding refers to, e.g. "Role prod/reader"
+func (d DanglingBinding) MissingRole() string {
+	if d.RoleRef.Kind == "Role" {
+		return "Role " + d.Binding.Namespace + "/" + d.RoleRef.Name
+	}
+	return d.RoleRef.Kind + " " + d.RoleRef.Name
+}
+
+func (d DanglingBinding) String() string {
+	binding := d.Binding.Name
+	if d.Binding.Namespace != "" {
+		binding = d.Binding.Namespace + "/" + binding
+	}
+	return fmt.Sprintf("%s %s refers to %s, which doesn't exist", d.Binding.Kind, binding, d.MissingRole())
+}
+
+// danglingBinding returns the DanglingBinding for a binding whose role
+// lookup failed with err, or nil when the role exists but couldn't be read
+func danglingBinding(binding BindingInfo, ref rbacv1.RoleRef, err error) *DanglingBinding {
+	if !apierrors.IsNotFound(err) {
+		return nil
+	}
+	return &DanglingBinding{Binding: binding, RoleRef: ref}
+}
+
+// DanglingBindings returns the bindings in namespace, and the
+// ClusterRoleBindings, whose roles are missing. With a subject, only the
+// bindings that apply to it are checked. An empty namespace checks
+// RoleBindings in every namespace.
+func (r *Resolver) DanglingBindings(ctx context.Context, subject *Subject, namespace string) ([]DanglingBinding, error) {
+	applies := func([]rbacv1.Subject) bool { return true }
+	if subject != nil {
+		groups := GetImplicitGroups(*subject)
+		applies = func(subjects []rbacv1.Subject) bool { return bindingMatchesSubject(subjects, *subject, groups) >= 0 }
+	}
+
+	var dangling []DanglingBinding
+	crbs, err := r.client.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	for _, crb := range crbs.Items {
+		if !applies(crb.Subjects) {
+			continue
+		}
+		if _, _, err := r.boundRules(ctx, crb.RoleRef, ""); err != nil {
+			if d := danglingBinding(BindingInfo{Kind: "ClusterRoleBinding", Name: crb.Name, Owner: OwnerFromMeta(crb.ObjectMeta)}, crb.RoleRef, err); d != nil {
+				dangling = append(dangling, *d)
+			}
+		}
+	}
+
+	rbs, err := r.client.ListRoleBindings(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	for _, rb := range rbs.Items {
+		if !applies(rb.Subjects) {
+			continue
+		}
+		if _, _, err := r.boundRules(ctx, rb.RoleRef, rb.Namespace); err != nil {
+			if d := danglingBinding(BindingInfo{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace, Owner: OwnerFromMeta(rb.ObjectMeta)}, rb.RoleRef, err); d != nil {
+				dangling = append(dangling, *d)
+			}
+		}
+	}
+	return dangling, nil
+}
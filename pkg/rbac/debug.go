@@ -0,0 +1,58 @@
+package rbac
+
+import (
+	"context"
+	"log/slog"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// WithLogger makes the resolver log to logger, at debug level, whether each
+// binding it examines applies to the subject and, for those that do, why
+// each rule of the role matches the request or not
+func WithLogger(logger *slog.Logger) ResolverOption {
+	return func(c *resolverConfig) {
+		c.logger = logger
+	}
+}
+
+// debugEnabled reports whether the resolver's logger records debug messages,
+// so the reasons it would log are only worked out when they are
+func (r *Resolver) debugEnabled(ctx context.Context) bool {
+	return r.logger.Enabled(ctx, slog.LevelDebug)
+}
+
+// logBinding logs whether a binding applies to the subject, and through
+// which of its subjects, given the index bindingMatchesSubject returned
+func (r *Resolver) logBinding(ctx context.Context, kind, namespace, name string, subjects []rbacv1.Subject, matched int) {
+	if !r.debugEnabled(ctx) {
+		return
+	}
+	attrs := []any{"kind", kind, "name", name}
+	if namespace != "" {
+		attrs = append(attrs, "namespace", namespace)
+	}
+	if matched < 0 {
+		r.logger.DebugContext(ctx, "binding skipped", append(attrs, "reason", "no subject matches")...)
+		return
+	}
+	r.logger.DebugContext(ctx, "binding applies", append(attrs, "via", describeBindingSubject(subjects[matched]))...)
+}
+
+// logRule logs whether rule i of the role a binding refers to matches
+// request and, if not, which part of it doesn't
+func (r *Resolver) logRule(ctx context.Context, binding BindingInfo, ref rbacv1.RoleRef, i int, rule rbacv1.PolicyRule, request PermissionRequest) {
+	if !r.debugEnabled(ctx) {
+		return
+	}
+	name := binding.Name
+	if binding.Namespace != "" {
+		name = binding.Namespace + "/" + name
+	}
+	attrs := []any{"binding", binding.Kind + " " + name, "role", ref.Kind + " " + ref.Name, "rule", i}
+	if reason := RuleMismatch(rule, request); reason != "" {
+		r.logger.DebugContext(ctx, "rule skipped", append(attrs, "reason", reason)...)
+		return
+	}
+	r.logger.DebugContext(ctx, "rule matches", attrs...)
+}
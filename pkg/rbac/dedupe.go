@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"cmp"
+	"slices"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// IndexedRule is a rule of a role with its position in the role's rules
+type IndexedRule struct {
+	Index int
+	Rule  rbacv1.PolicyRule
+}
+
+// WithoutDedupe makes ResolvePermission return a grant for every matching
+// rule instead of merging those through the same binding and role
+func WithoutDedupe() ResolverOption {
+	return func(c *resolverConfig) {
+		c.keepDuplicates = true
+	}
+}
+
+// DedupeGrants merges grants through the same binding and role into one,
+// keeping the most specific matching rule and recording the others in
+// AlsoMatchedRules. Grants stay in the order their binding and role were
+// first seen.
+func DedupeGrants(grants []PermissionGrant) []PermissionGrant {
+	merged := make([]PermissionGrant, 0, len(grants))
+	seen := make(map[[6]string]int)
+	for _, g := range grants {
+		key := [6]string{g.Binding.Kind, g.Binding.Namespace, g.Binding.Name, g.Role.Kind, g.Role.Namespace, g.Role.Name}
+		i, ok := seen[key]
+		if !ok {
+			seen[key] = len(merged)
+			merged = append(merged, g)
+			continue
+		}
+		kept := &merged[i]
+		if moreSpecific(g, *kept) {
+			g.AlsoMatchedRules = append(kept.AlsoMatchedRules, IndexedRule{Index: kept.RuleIndex, Rule: kept.MatchingRule})
+			*kept = g
+		} else {
+			kept.AlsoMatchedRules = append(kept.AlsoMatchedRules, IndexedRule{Index: g.RuleIndex, Rule: g.MatchingRule})
+		}
+	}
+	for i := range merged {
+		slices.SortFunc(merged[i].AlsoMatchedRules, func(a, b IndexedRule) int { return cmp.Compare(a.Index, b.Index) })
+	}
+	return merged
+}
+
+// moreSpecific reports whether a's rule is a better one to show than b's:
+// one that grants the request outright over one limited to resourceNames,
+// then one that matches through fewer wildcards
+func moreSpecific(a, b PermissionGrant) bool {
+	if a.Conditional != b.Conditional {
+		return !a.Conditional
+	}
+	return len(a.Wildcards) < len(b.Wildcards)
+}
@@ -0,0 +1,64 @@
+package rbac
+
+import (
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestDedupeGrants(t *testing.T) {
+	binding := BindingInfo{Kind: "ClusterRoleBinding", Name: "ops"}
+	role := RoleInfo{Kind: "ClusterRole", Name: "ops"}
+	wildcard := PermissionGrant{Binding: binding, Role: role, RuleIndex: 0, Wildcards: []string{WildcardResource},
+		MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"*"}}}
+	named := PermissionGrant{Binding: binding, Role: role, RuleIndex: 1, Conditional: true,
+		MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web"}}}
+	explicit := PermissionGrant{Binding: binding, Role: role, RuleIndex: 2,
+		MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	other := PermissionGrant{Binding: BindingInfo{Kind: "RoleBinding", Name: "ops", Namespace: "prod"}, Role: role, RuleIndex: 0,
+		MatchingRule: wildcard.MatchingRule, Wildcards: wildcard.Wildcards}
+
+	tests := []struct {
+		name      string
+		grants    []PermissionGrant
+		wantRules []int   // RuleIndex of each merged grant
+		wantAlso  [][]int // indexes of its AlsoMatchedRules
+	}{
+		{
+			name:      "explicit rule kept over wildcard and resourceNames",
+			grants:    []PermissionGrant{wildcard, named, explicit},
+			wantRules: []int{2},
+			wantAlso:  [][]int{{0, 1}},
+		},
+		{
+			name:      "wildcard kept over resourceNames",
+			grants:    []PermissionGrant{named, wildcard},
+			wantRules: []int{0},
+			wantAlso:  [][]int{{1}},
+		},
+		{
+			name:      "other binding kept apart",
+			grants:    []PermissionGrant{wildcard, other, explicit},
+			wantRules: []int{2, 0},
+			wantAlso:  [][]int{{0}, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DedupeGrants(tt.grants)
+			if len(got) != len(tt.wantRules) {
+				t.Fatalf("DedupeGrants() returned %d grants, expected %d", len(got), len(tt.wantRules))
+			}
+			for i, g := range got {
+				var also []int
+				for _, r := range g.AlsoMatchedRules {
+					also = append(also, r.Index)
+				}
+				if g.RuleIndex != tt.wantRules[i] || !slices.Equal(also, tt.wantAlso[i]) {
+					t.Errorf("grant %d: RuleIndex = %d, AlsoMatchedRules = %v; expected %d and %v", i, g.RuleIndex, also, tt.wantRules[i], tt.wantAlso[i])
+				}
+			}
+		})
+	}
+}
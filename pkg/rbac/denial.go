@@ -0,0 +1,233 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Denial reason codes reported for denied requests. The list is additive
+// only: codes are never renamed or removed, so scripts can match on them.
+const (
+	// DenialNoMatchingBinding means no binding in scope references the subject
+	DenialNoMatchingBinding = "NO_MATCHING_BINDING"
+	// DenialVerbNotGranted means a bound role covers the resource, but not the verb
+	DenialVerbNotGranted = "VERB_NOT_GRANTED"
+	// DenialResourceNotGranted means no bound role covers the resource with any verb
+	DenialResourceNotGranted = "RESOURCE_NOT_GRANTED"
+	// DenialWrongNamespace means a RoleBinding in another namespace would grant it
+	DenialWrongNamespace = "WRONG_NAMESPACE"
+	// DenialResourceNameRestricted means a bound role grants it only for other resource names
+	DenialResourceNameRestricted = "RESOURCE_NAME_RESTRICTED"
+	// DenialDanglingBinding means a binding applies to the subject, but the
+	// role it refers to doesn't exist
+	DenialDanglingBinding = "DANGLING_BINDING"
+	// DenialClusterScoped means a RoleBinding would grant it, but the
+	// resource is cluster-scoped, which only ClusterRoleBindings grant
+	DenialClusterScoped = "CLUSTER_SCOPED"
+	// DenialEvaluationIncomplete means a binding or role could not be read, so
+	// the result may be wrong
+	DenialEvaluationIncomplete = "EVALUATION_INCOMPLETE"
+)
+
+// DenialReason explains one way a denied request falls short
+type DenialReason struct {
+	Code    string
+	Message string
+
+	// Binding and Role are the closest related grant, when there is one
+	Binding *BindingInfo
+	Role    *RoleInfo
+}
+
+// explainDenial works out why request is denied for subject. Lookups that fail
+// are reported as DenialEvaluationIncomplete rather than returned. For a
+// cluster-scoped resource, only ClusterRoleBindings are considered, and
+// scopeMisses are the RoleBinding grants left out for that reason.
+func (r *Resolver) explainDenial(ctx context.Context, subject Subject, groups []string, request PermissionRequest, result *PermissionResult, clusterScoped bool, scopeMisses []PermissionGrant) []DenialReason {
+	var reasons []DenialReason
+	incomplete := func(err error) {
+		reasons = append(reasons, DenialReason{Code: DenialEvaluationIncomplete, Message: err.Error()})
+	}
+	for i := range scopeMisses {
+		reasons = append(reasons, scopeMissReason(&scopeMisses[i], request))
+	}
+
+	namespace := request.Namespace
+	if clusterScoped {
+		namespace = ""
+	}
+	grants, err := r.ResolveAllPermissions(ctx, subject, namespace)
+	if err != nil {
+		incomplete(err)
+		return reasons
+	}
+
+	if len(grants) == 0 && len(result.Diagnostics) == 0 && len(scopeMisses) == 0 {
+		scope := "cluster-wide"
+		if namespace != "" {
+			scope = "in namespace " + request.Namespace + " or cluster-wide"
+		}
+		reasons = append(reasons, DenialReason{
+			Code:    DenialNoMatchingBinding,
+			Message: fmt.Sprintf("no binding %s references %s or its groups", scope, subject),
+		})
+	}
+
+	var named, verb, resource *PermissionGrant
+	unnamed := request
+	unnamed.ResourceName = ""
+	for i := range grants {
+		g := &grants[i]
+		rule := g.MatchingRule
+		if !matchesTarget(rule, request) {
+			if resource == nil && matchesVerb(rule.Verbs, request.Verb) {
+				resource = g
+			}
+			continue
+		}
+		switch {
+		case !matchesVerb(rule.Verbs, request.Verb):
+			if verb == nil {
+				verb = g
+			}
+		case named == nil && request.ResourceName != "" && RuleMatches(rule, unnamed):
+			named = g
+		}
+	}
+
+	target := request.FullResource()
+	if named != nil {
+		reasons = append(reasons, grantReason(DenialResourceNameRestricted, named,
+			fmt.Sprintf("%s/%s grants %s %s only for resourceNames [%s], not %s",
+				named.Role.Kind, named.Role.Name, request.Verb, target, strings.Join(named.MatchingRule.ResourceNames, ", "), request.ResourceName)))
+	}
+	if verb != nil && named == nil {
+		reasons = append(reasons, grantReason(DenialVerbNotGranted, verb,
+			fmt.Sprintf("%s/%s grants [%s] on %s, but not %s",
+				verb.Role.Kind, verb.Role.Name, strings.Join(verb.MatchingRule.Verbs, ", "), target, request.Verb)))
+	}
+	if verb == nil && named == nil && len(grants) > 0 {
+		reason := DenialReason{
+			Code:    DenialResourceNotGranted,
+			Message: fmt.Sprintf("no role bound to %s grants any verb on %s", subject, target),
+		}
+		if resource != nil {
+			reason = grantReason(DenialResourceNotGranted, resource,
+				fmt.Sprintf("%s/%s grants %s, but not on %s", resource.Role.Kind, resource.Role.Name, request.Verb, target))
+		}
+		reasons = append(reasons, reason)
+	}
+
+	// RoleBindings never grant non-resource URLs or cluster-scoped
+	// resources, in any namespace
+	var elsewhere []PermissionGrant
+	if request.NonResourceURL == "" && !clusterScoped {
+		elsewhere, err = r.grantsInOtherNamespaces(ctx, subject, groups, request)
+		if err != nil {
+			incomplete(err)
+		}
+	}
+	for i := range elsewhere {
+		g := &elsewhere[i]
+		where := "cluster-wide"
+		if request.Namespace != "" {
+			where = "in namespace " + request.Namespace
+		}
+		reasons = append(reasons, grantReason(DenialWrongNamespace, g,
+			fmt.Sprintf("RoleBinding %s/%s grants this in namespace %s, not %s", g.Binding.Namespace, g.Binding.Name, g.Binding.Namespace, where)))
+	}
+
+	for _, d := range result.DanglingBindings {
+		binding := d.Binding
+		reasons = append(reasons, DenialReason{Code: DenialDanglingBinding, Message: d.String(), Binding: &binding})
+	}
+	for _, d := range ErrorDiagnostics(result.Diagnostics) {
+		reasons = append(reasons, DenialReason{Code: DenialEvaluationIncomplete, Message: d.Message})
+	}
+	return reasons
+}
+
+// grantsInOtherNamespaces finds RoleBindings outside the request's namespace
+// that would grant the request if it were made in their namespace
+func (r *Resolver) grantsInOtherNamespaces(ctx context.Context, subject Subject, groups []string, request PermissionRequest) ([]PermissionGrant, error) {
+	rbs, err := r.roleBindingsFor(ctx, subject, groups, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings in all namespaces: %w", err)
+	}
+
+	var grants []PermissionGrant
+	for _, rb := range rbs {
+		if rb.Namespace == request.Namespace {
+			continue
+		}
+		matched := bindingMatchesSubject(rb.Subjects, subject, groups)
+		if matched < 0 {
+			continue
+		}
+		rules, role, err := r.boundRules(ctx, rb.RoleRef, rb.Namespace)
+		if err != nil {
+			// A dangling binding elsewhere has no bearing on this request
+			continue
+		}
+		there := request
+		there.Namespace = rb.Namespace
+		for i, rule := range rules {
+			if RuleMatches(rule, there) {
+				grants = append(grants, PermissionGrant{
+					Binding:            BindingInfo{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace, Owner: OwnerFromMeta(rb.ObjectMeta)},
+					Role:               role,
+					MatchedSubject:     &rb.Subjects[matched],
+					SubjectIndex:       matched,
+					MatchingRule:       rule,
+					RuleIndex:          i,
+					Scope:              ScopeNamespace,
+					EffectiveNamespace: rb.Namespace,
+				})
+				break
+			}
+		}
+	}
+	return grants, nil
+}
+
+// boundRules fetches the rules of the role a RoleBinding in namespace refers to
+func (r *Resolver) boundRules(ctx context.Context, ref rbacv1.RoleRef, namespace string) ([]rbacv1.PolicyRule, RoleInfo, error) {
+	var meta metav1.ObjectMeta
+	var rules []rbacv1.PolicyRule
+	info := RoleInfo{Kind: ref.Kind, Name: ref.Name}
+	if ref.Kind == "ClusterRole" {
+		cr, err := r.client.GetClusterRole(ctx, ref.Name)
+		if err != nil {
+			return nil, info, err
+		}
+		meta, rules = cr.ObjectMeta, cr.Rules
+	} else {
+		role, err := r.client.GetRole(ctx, namespace, ref.Name)
+		if err != nil {
+			return nil, info, err
+		}
+		meta, rules = role.ObjectMeta, role.Rules
+		info.Namespace = namespace
+	}
+	info.Owner = OwnerFromMeta(meta)
+	info.Source = SourceFromMeta(meta)
+	return rules, info, nil
+}
+
+// matchesTarget reports whether rule covers the request's resource, or its
+// non-resource URL, regardless of verb
+func matchesTarget(rule rbacv1.PolicyRule, request PermissionRequest) bool {
+	if request.NonResourceURL != "" {
+		return coversNonResourceURL(rule.NonResourceURLs, request.NonResourceURL)
+	}
+	return matchesAPIGroup(rule.APIGroups, request.APIGroup) && matchesResource(rule.Resources, request.Resource, request.Subresource)
+}
+
+func grantReason(code string, g *PermissionGrant, message string) DenialReason {
+	binding, role := g.Binding, g.Role
+	return DenialReason{Code: code, Message: message, Binding: &binding, Role: &role}
+}
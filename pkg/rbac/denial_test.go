@@ -0,0 +1,144 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestResolvePermission_DenialReasons(t *testing.T) {
+	subject := Subject{Kind: "ServiceAccount", Namespace: "default", Name: "app"}
+	bindTo := func(mock *client.MockRBACClient, namespace, role string) {
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-" + role, Namespace: namespace},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "app", Namespace: "default"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: role},
+		})
+	}
+	addRole := func(mock *client.MockRBACClient, namespace, name string, rule rbacv1.PolicyRule) {
+		mock.AddRole(rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Rules:      []rbacv1.PolicyRule{rule},
+		})
+	}
+	getPods := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+
+	tests := []struct {
+		name      string
+		setup     func(*client.MockRBACClient)
+		request   PermissionRequest
+		wantCodes []string
+		wantRole  string
+	}{
+		{
+			name:      "no bindings",
+			setup:     func(*client.MockRBACClient) {},
+			request:   PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"},
+			wantCodes: []string{DenialNoMatchingBinding},
+		},
+		{
+			name: "verb not granted",
+			setup: func(m *client.MockRBACClient) {
+				addRole(m, "default", "reader", getPods)
+				bindTo(m, "default", "reader")
+			},
+			request:   PermissionRequest{Verb: "delete", Resource: "pods", Namespace: "default"},
+			wantCodes: []string{DenialVerbNotGranted},
+			wantRole:  "reader",
+		},
+		{
+			name: "resource not granted",
+			setup: func(m *client.MockRBACClient) {
+				addRole(m, "default", "reader", getPods)
+				bindTo(m, "default", "reader")
+			},
+			request:   PermissionRequest{Verb: "get", Resource: "secrets", Namespace: "default"},
+			wantCodes: []string{DenialResourceNotGranted},
+			wantRole:  "reader",
+		},
+		{
+			name: "resource name restricted",
+			setup: func(m *client.MockRBACClient) {
+				addRole(m, "default", "config", rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"app-config"}})
+				bindTo(m, "default", "config")
+			},
+			request:   PermissionRequest{Verb: "get", Resource: "configmaps", ResourceName: "other", Namespace: "default"},
+			wantCodes: []string{DenialResourceNameRestricted},
+			wantRole:  "config",
+		},
+		{
+			name: "granted in another namespace",
+			setup: func(m *client.MockRBACClient) {
+				addRole(m, "prod", "reader", getPods)
+				bindTo(m, "prod", "reader")
+			},
+			request:   PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"},
+			wantCodes: []string{DenialNoMatchingBinding, DenialWrongNamespace},
+		},
+		{
+			name: "dangling role reference",
+			setup: func(m *client.MockRBACClient) {
+				bindTo(m, "default", "missing")
+			},
+			request:   PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"},
+			wantCodes: []string{DenialDanglingBinding},
+		},
+		{
+			name: "unreadable role",
+			setup: func(m *client.MockRBACClient) {
+				bindTo(m, "default", "reader")
+				m.GetRoleError = errors.New("forbidden")
+			},
+			request:   PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"},
+			wantCodes: []string{DenialEvaluationIncomplete},
+		},
+		{
+			name: "other namespaces unreadable",
+			setup: func(m *client.MockRBACClient) {
+				addRole(m, "default", "reader", getPods)
+				bindTo(m, "default", "reader")
+				m.ListRoleBindingsError = errors.New("forbidden")
+			},
+			request:   PermissionRequest{Verb: "delete", Resource: "pods"},
+			wantCodes: []string{DenialNoMatchingBinding, DenialEvaluationIncomplete},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := client.NewMockRBACClient()
+			tt.setup(mock)
+
+			result, err := NewResolver(mock).ResolvePermission(context.Background(), subject, tt.request)
+			if err != nil {
+				t.Fatalf("ResolvePermission() error = %v", err)
+			}
+			if result.Allowed {
+				t.Fatal("expected the request to be denied")
+			}
+
+			var codes []string
+			for _, r := range result.DenialReasons {
+				codes = append(codes, r.Code)
+			}
+			if len(codes) != len(tt.wantCodes) {
+				t.Fatalf("codes = %v, want %v", codes, tt.wantCodes)
+			}
+			for i := range codes {
+				if codes[i] != tt.wantCodes[i] {
+					t.Fatalf("codes = %v, want %v", codes, tt.wantCodes)
+				}
+			}
+			if tt.wantRole != "" {
+				if role := result.DenialReasons[0].Role; role == nil || role.Name != tt.wantRole {
+					t.Errorf("closest role = %v, want %s", role, tt.wantRole)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Diagnostic is a problem met while resolving a result, with a code scripts
+// can match on
+type Diagnostic struct {
+	Code     string
+	Severity string
+	// Object is the object involved, e.g. "ClusterRole/view" or
+	// "Namespace/prod", when there is one
+	Object  string
+	Message string
+	// Category classifies the API error behind the diagnostic, e.g.
+	// forbidden or not-found, when there is one
+	Category string
+}
+
+// Diagnostic severities. Errors mean the result may be wrong; warnings and
+// info don't change it.
+const (
+	DiagnosticError   = "error"
+	DiagnosticWarning = "warning"
+	DiagnosticInfo    = "info"
+)
+
+// Diagnostic codes. Like denial reason codes, they are never renamed or removed.
+const (
+	// DiagnosticRoleNotFound means a binding refers to a role that doesn't
+	// exist; see DanglingBinding
+	DiagnosticRoleNotFound = "RoleNotFound"
+	// DiagnosticRoleForbidden means a bound role couldn't be read for lack of access
+	DiagnosticRoleForbidden = "RoleForbidden"
+	// DiagnosticRoleUnavailable means a bound role couldn't be read for another reason
+	DiagnosticRoleUnavailable = "RoleUnavailable"
+	// DiagnosticNamespaceListForbidden means the RoleBindings of a namespace
+	// couldn't be listed for lack of access
+	DiagnosticNamespaceListForbidden = "NamespaceListForbidden"
+	// DiagnosticNamespaceListFailed means the RoleBindings of a namespace
+	// couldn't be listed for another reason
+	DiagnosticNamespaceListFailed = "NamespaceListFailed"
+	// DiagnosticDiscoveryUnavailable means the discovery document couldn't be
+	// read, so the resource wasn't checked against the ones the cluster serves
+	DiagnosticDiscoveryUnavailable = "DiscoveryUnavailable"
+)
+
+// Error categories, from the apimachinery error behind a diagnostic, so
+// callers can treat a lack of access differently from a deleted object
+const (
+	ErrorCategoryForbidden    = "forbidden"
+	ErrorCategoryNotFound     = "not-found"
+	ErrorCategoryTimeout      = "timeout"
+	ErrorCategoryUnauthorized = "unauthorized"
+	ErrorCategoryUnavailable  = "unavailable"
+	ErrorCategoryOther        = "other"
+)
+
+// ClassifyError returns the ErrorCategory of err, or "" for a nil error
+func ClassifyError(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case apierrors.IsForbidden(err):
+		return ErrorCategoryForbidden
+	case apierrors.IsNotFound(err):
+		return ErrorCategoryNotFound
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), errors.Is(err, context.DeadlineExceeded):
+		return ErrorCategoryTimeout
+	case apierrors.IsUnauthorized(err):
+		return ErrorCategoryUnauthorized
+	case apierrors.IsServiceUnavailable(err), apierrors.IsTooManyRequests(err), apierrors.IsInternalError(err):
+		return ErrorCategoryUnavailable
+	default:
+		return ErrorCategoryOther
+	}
+}
+
+// Advice suggests how to resolve the diagnostic, based on its category and
+// the object involved, or returns "" when there is nothing specific to do
+func (d Diagnostic) Advice() string {
+	kind, namespace, name := splitObjectName(d.Object)
+	switch d.Category {
+	case ErrorCategoryForbidden:
+		switch kind {
+		case "ClusterRole":
+			return "grant yourself get on clusterroles to read it"
+		case "Role":
+			return fmt.Sprintf("grant yourself get on roles in namespace %s to read it", namespace)
+		case "Namespace":
+			return fmt.Sprintf("grant yourself list on rolebindings in namespace %s", name)
+		}
+		return "ask for read access to the API server's discovery and RBAC objects"
+	case ErrorCategoryNotFound:
+		if d.Code == DiagnosticRoleNotFound {
+			return "the binding refers to a deleted role; recreate the role or delete the binding"
+		}
+		return "it was deleted while the check ran; run the check again"
+	case ErrorCategoryTimeout:
+		return "the API server timed out; run the check again, or list fewer objects at a time with --page-size"
+	case ErrorCategoryUnauthorized:
+		return "your credentials were rejected; refresh them, e.g. by logging in again"
+	case ErrorCategoryUnavailable:
+		return "the API server is unavailable or throttling requests; run the check again later"
+	}
+	return ""
+}
+
+// splitObjectName splits an object formatted by objectName
+func splitObjectName(object string) (kind, namespace, name string) {
+	parts := strings.SplitN(object, "/", 3)
+	switch len(parts) {
+	case 3:
+		return parts[0], parts[1], parts[2]
+	case 2:
+		return parts[0], "", parts[1]
+	}
+	return "", "", object
+}
+
+// Diagnostic returns the RoleNotFound diagnostic for the binding
+func (d DanglingBinding) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:     DiagnosticRoleNotFound,
+		Severity: DiagnosticWarning,
+		Object:   objectName(d.Binding.Kind, d.Binding.Namespace, d.Binding.Name),
+		Message:  d.String(),
+		Category: ErrorCategoryNotFound,
+	}
+}
+
+// roleDiagnostic describes a failure to read the role kind namespace/name
+func roleDiagnostic(kind, namespace, name string, err error) Diagnostic {
+	code := DiagnosticRoleUnavailable
+	if apierrors.IsForbidden(err) {
+		code = DiagnosticRoleForbidden
+	}
+	message := fmt.Sprintf("failed to get cluster role %s: %v", name, err)
+	if kind == "Role" {
+		message = fmt.Sprintf("failed to get role %s in namespace %s: %v", name, namespace, err)
+	}
+	return Diagnostic{
+		Code:     code,
+		Severity: DiagnosticError,
+		Object:   objectName(kind, namespace, name),
+		Message:  message,
+		Category: ClassifyError(err),
+	}
+}
+
+// namespaceDiagnostic describes a failure to list the RoleBindings in namespace
+func namespaceDiagnostic(namespace string, err error) Diagnostic {
+	code := DiagnosticNamespaceListFailed
+	if apierrors.IsForbidden(err) {
+		code = DiagnosticNamespaceListForbidden
+	}
+	return Diagnostic{
+		Code:     code,
+		Severity: DiagnosticError,
+		Object:   objectName("Namespace", "", namespace),
+		Message:  err.Error(),
+		Category: ClassifyError(err),
+	}
+}
+
+// DiscoveryDiagnostic describes a failure to read the discovery document
+func DiscoveryDiagnostic(err error) Diagnostic {
+	return Diagnostic{
+		Code:     DiagnosticDiscoveryUnavailable,
+		Severity: DiagnosticInfo,
+		Message:  fmt.Sprintf("discovery unavailable, so the resource wasn't checked against the ones the cluster serves: %v", err),
+		Category: ClassifyError(err),
+	}
+}
+
+// objectName formats an object as Kind/namespace/name or Kind/name
+func objectName(kind, namespace, name string) string {
+	return kind + "/" + qualifiedName(namespace, name)
+}
+
+func qualifiedName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// ErrorDiagnostics returns the diagnostics of DiagnosticError severity
+func ErrorDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	var errs []Diagnostic
+	for _, d := range diagnostics {
+		if d.Severity == DiagnosticError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,161 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestResolvePermission_Diagnostics(t *testing.T) {
+	subject := Subject{Kind: "ServiceAccount", Namespace: "default", Name: "app"}
+	request := PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"}
+
+	tests := []struct {
+		name         string
+		roleErr      error
+		wantCode     string
+		wantSeverity string
+		wantCategory string
+	}{
+		{
+			name:         "missing role",
+			wantCode:     DiagnosticRoleNotFound,
+			wantSeverity: DiagnosticWarning,
+			wantCategory: ErrorCategoryNotFound,
+		},
+		{
+			name:         "forbidden role",
+			roleErr:      apierrors.NewForbidden(rbacv1.Resource("roles"), "reader", errors.New("no access")),
+			wantCode:     DiagnosticRoleForbidden,
+			wantSeverity: DiagnosticError,
+			wantCategory: ErrorCategoryForbidden,
+		},
+		{
+			name:         "unreadable role",
+			roleErr:      errors.New("connection refused"),
+			wantCode:     DiagnosticRoleUnavailable,
+			wantSeverity: DiagnosticError,
+			wantCategory: ErrorCategoryOther,
+		},
+		{
+			name:         "timed out role",
+			roleErr:      apierrors.NewTimeoutError("request timed out", 1),
+			wantCode:     DiagnosticRoleUnavailable,
+			wantSeverity: DiagnosticError,
+			wantCategory: ErrorCategoryTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := client.NewMockRBACClient()
+			mock.AddRoleBinding(rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-reader", Namespace: "default"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "app", Namespace: "default"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "reader"},
+			})
+			mock.GetRoleError = tt.roleErr
+
+			result, err := NewResolver(mock).ResolvePermission(context.Background(), subject, request)
+			if err != nil {
+				t.Fatalf("ResolvePermission() error = %v", err)
+			}
+			if len(result.Diagnostics) != 1 {
+				t.Fatalf("diagnostics = %+v, want one", result.Diagnostics)
+			}
+			d := result.Diagnostics[0]
+			if d.Code != tt.wantCode || d.Severity != tt.wantSeverity {
+				t.Errorf("diagnostic = %s/%s, want %s/%s", d.Code, d.Severity, tt.wantCode, tt.wantSeverity)
+			}
+			if d.Category != tt.wantCategory {
+				t.Errorf("category = %q, want %q", d.Category, tt.wantCategory)
+			}
+			wantObject := "Role/default/reader"
+			if tt.roleErr == nil {
+				wantObject = "RoleBinding/default/app-reader"
+			}
+			if d.Object != wantObject {
+				t.Errorf("object = %q, want %q", d.Object, wantObject)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "forbidden", err: apierrors.NewForbidden(rbacv1.Resource("roles"), "reader", errors.New("no access")), want: ErrorCategoryForbidden},
+		{name: "not found", err: apierrors.NewNotFound(rbacv1.Resource("roles"), "reader"), want: ErrorCategoryNotFound},
+		{name: "wrapped not found", err: fmt.Errorf("failed to get role: %w", apierrors.NewNotFound(rbacv1.Resource("roles"), "reader")), want: ErrorCategoryNotFound},
+		{name: "server timeout", err: apierrors.NewServerTimeout(rbacv1.Resource("roles"), "list", 1), want: ErrorCategoryTimeout},
+		{name: "deadline exceeded", err: fmt.Errorf("listing: %w", context.DeadlineExceeded), want: ErrorCategoryTimeout},
+		{name: "unauthorized", err: apierrors.NewUnauthorized("token expired"), want: ErrorCategoryUnauthorized},
+		{name: "throttled", err: apierrors.NewTooManyRequests("slow down", 1), want: ErrorCategoryUnavailable},
+		{name: "other", err: errors.New("connection refused"), want: ErrorCategoryOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiagnostic_Advice(t *testing.T) {
+	forbidden := apierrors.NewForbidden(rbacv1.Resource("roles"), "reader", errors.New("no access"))
+	tests := []struct {
+		name       string
+		diagnostic Diagnostic
+		want       string
+	}{
+		{
+			name:       "forbidden role",
+			diagnostic: roleDiagnostic("Role", "prod", "reader", forbidden),
+			want:       "grant yourself get on roles in namespace prod to read it",
+		},
+		{
+			name:       "forbidden cluster role",
+			diagnostic: roleDiagnostic("ClusterRole", "", "view", forbidden),
+			want:       "grant yourself get on clusterroles to read it",
+		},
+		{
+			name:       "forbidden namespace",
+			diagnostic: namespaceDiagnostic("prod", forbidden),
+			want:       "grant yourself list on rolebindings in namespace prod",
+		},
+		{
+			name: "deleted role",
+			diagnostic: DanglingBinding{
+				Binding: BindingInfo{Kind: "RoleBinding", Namespace: "prod", Name: "app-reader"},
+				RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "reader"},
+			}.Diagnostic(),
+			want: "the binding refers to a deleted role; recreate the role or delete the binding",
+		},
+		{
+			name:       "unclassified",
+			diagnostic: roleDiagnostic("Role", "prod", "reader", errors.New("connection refused")),
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.diagnostic.Advice(); got != tt.want {
+				t.Errorf("Advice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
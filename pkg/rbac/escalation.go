@@ -0,0 +1,217 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// EscalationCheck is the outcome of the API server's escalation-prevention
+// check for creating a Role, ClusterRole, or binding. Creating a role is
+// allowed only if the requester already holds every permission in it or has
+// the escalate verb; creating a binding likewise for the referenced role, or
+// with the bind verb.
+type EscalationCheck struct {
+	Object  string // e.g. "Role/prod/app-reader"
+	RoleRef string // The role whose rules are granted, e.g. "ClusterRole/view"
+
+	// Write is the check for creating the object at all
+	Write *PermissionResult
+	// Bypass is the escalate (roles) or bind (bindings) check that lifts the
+	// escalation restriction
+	Bypass *PermissionResult
+	// Uncovered are the permissions being granted that the subject does not hold
+	Uncovered []RuleKey
+
+	Allowed bool
+}
+
+// CheckEscalation simulates creating obj, which must be a *Role, *ClusterRole,
+// *RoleBinding, or *ClusterRoleBinding, as subject. Objects without a
+// namespace are placed in namespace.
+func (r *Resolver) CheckEscalation(ctx context.Context, subject Subject, obj interface{}, namespace string) (*EscalationCheck, error) {
+	var (
+		check    EscalationCheck
+		resource string
+		ns       string
+		rules    []rbacv1.PolicyRule
+		bypass   PermissionRequest
+	)
+
+	switch o := obj.(type) {
+	case *rbacv1.Role:
+		ns = o.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		resource, rules = "roles", o.Rules
+		check.Object = "Role/" + ns + "/" + o.Name
+		check.RoleRef = check.Object
+		bypass = PermissionRequest{Verb: "escalate", Resource: "roles", ResourceName: o.Name, Namespace: ns}
+	case *rbacv1.ClusterRole:
+		resource, rules = "clusterroles", o.Rules
+		check.Object = "ClusterRole/" + o.Name
+		check.RoleRef = check.Object
+		bypass = PermissionRequest{Verb: "escalate", Resource: "clusterroles", ResourceName: o.Name}
+	case *rbacv1.RoleBinding:
+		ns = o.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		resource = "rolebindings"
+		check.Object = "RoleBinding/" + ns + "/" + o.Name
+		var err error
+		if rules, check.RoleRef, err = r.referencedRules(ctx, o.RoleRef, ns); err != nil {
+			return nil, err
+		}
+		bypass = PermissionRequest{Verb: "bind", Resource: bindResource(o.RoleRef), ResourceName: o.RoleRef.Name, Namespace: ns}
+	case *rbacv1.ClusterRoleBinding:
+		if o.RoleRef.Kind != "ClusterRole" {
+			return nil, fmt.Errorf("ClusterRoleBinding %s must reference a ClusterRole, not %s", o.Name, o.RoleRef.Kind)
+		}
+		resource = "clusterrolebindings"
+		check.Object = "ClusterRoleBinding/" + o.Name
+		var err error
+		if rules, check.RoleRef, err = r.referencedRules(ctx, o.RoleRef, ""); err != nil {
+			return nil, err
+		}
+		bypass = PermissionRequest{Verb: "bind", Resource: "clusterroles", ResourceName: o.RoleRef.Name}
+	default:
+		return nil, fmt.Errorf("unsupported object %T (expected a Role, ClusterRole, RoleBinding, or ClusterRoleBinding)", obj)
+	}
+	if ns == "" && (resource == "roles" || resource == "rolebindings") {
+		return nil, fmt.Errorf("%s has no namespace", check.Object)
+	}
+	bypass.APIGroup = rbacv1.GroupName
+
+	var err error
+	check.Write, err = r.ResolvePermission(ctx, subject, PermissionRequest{Verb: "create", APIGroup: rbacv1.GroupName, Resource: resource, Namespace: ns})
+	if err != nil {
+		return nil, err
+	}
+	check.Bypass, err = r.ResolvePermission(ctx, subject, bypass)
+	if err != nil {
+		return nil, err
+	}
+
+	// Superusers are never evaluated against RBAC, so nothing is uncovered
+	if check.Write.BypassedVia == "" {
+		grants, err := r.ResolveAllPermissions(ctx, subject, ns)
+		if err != nil {
+			return nil, err
+		}
+		var held []rbacv1.PolicyRule
+		for _, g := range grants {
+			held = append(held, g.MatchingRule)
+		}
+		for _, k := range NormalizeRules(rules) {
+			if !anyRuleCovers(held, k) {
+				check.Uncovered = append(check.Uncovered, k)
+			}
+		}
+	}
+
+	check.Allowed = check.Write.Allowed && (check.Bypass.Allowed || len(check.Uncovered) == 0)
+	return &check, nil
+}
+
+// referencedRules fetches the rules of the role a binding in namespace refers to
+func (r *Resolver) referencedRules(ctx context.Context, ref rbacv1.RoleRef, namespace string) ([]rbacv1.PolicyRule, string, error) {
+	if ref.Kind == "ClusterRole" {
+		cr, err := r.client.GetClusterRole(ctx, ref.Name)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get referenced cluster role %s: %w", ref.Name, err)
+		}
+		return cr.Rules, "ClusterRole/" + ref.Name, nil
+	}
+	role, err := r.client.GetRole(ctx, namespace, ref.Name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get referenced role %s in namespace %s: %w", ref.Name, namespace, err)
+	}
+	return role.Rules, "Role/" + namespace + "/" + ref.Name, nil
+}
+
+func bindResource(ref rbacv1.RoleRef) string {
+	if ref.Kind == "ClusterRole" {
+		return "clusterroles"
+	}
+	return "roles"
+}
+
+// EscalationVerbs is whether a subject can get around escalation prevention
+// at all, rather than for one object: by creating roles with the escalate
+// verb, or bindings with the bind verb. Namespace is "" for cluster-wide.
+type EscalationVerbs struct {
+	Namespace string
+
+	CreateRoles    *PermissionResult
+	Escalate       *PermissionResult
+	CreateBindings *PermissionResult
+	Bind           *PermissionResult
+	// BindClusterRoles is bind on clusterroles in Namespace, since a
+	// RoleBinding can reference a ClusterRole; nil for cluster-wide, where
+	// Bind is already on clusterroles
+	BindClusterRoles *PermissionResult
+}
+
+// CanEscalate reports whether the subject can create roles holding
+// permissions it doesn't have
+func (e *EscalationVerbs) CanEscalate() bool {
+	return e.CreateRoles.Allowed && e.Escalate.Allowed
+}
+
+// CanBind reports whether the subject can bind roles it doesn't hold,
+// including to itself
+func (e *EscalationVerbs) CanBind() bool {
+	return e.CreateBindings.Allowed && (e.Bind.Allowed || e.BindClusterRoles != nil && e.BindClusterRoles.Allowed)
+}
+
+// Results returns the checks in order
+func (e *EscalationVerbs) Results() []*PermissionResult {
+	results := []*PermissionResult{e.CreateRoles, e.Escalate, e.CreateBindings, e.Bind}
+	if e.BindClusterRoles != nil {
+		results = append(results, e.BindClusterRoles)
+	}
+	return results
+}
+
+// CheckEscalationVerbs checks create and escalate on roles, and create on
+// bindings with bind on roles or clusterroles, for subject in namespace.
+// Without a namespace the cluster-wide kinds are checked instead.
+func (r *Resolver) CheckEscalationVerbs(ctx context.Context, subject Subject, namespace string) (*EscalationVerbs, error) {
+	roles, bindings := "roles", "rolebindings"
+	if namespace == "" {
+		roles, bindings = "clusterroles", "clusterrolebindings"
+	}
+	check := func(verb, resource string) (*PermissionResult, error) {
+		return r.ResolvePermission(ctx, subject, PermissionRequest{Verb: verb, APIGroup: rbacv1.GroupName, Resource: resource, Namespace: namespace})
+	}
+
+	e := &EscalationVerbs{Namespace: namespace}
+	var err error
+	if e.CreateRoles, err = check("create", roles); err != nil {
+		return nil, err
+	}
+	if e.Escalate, err = check("escalate", roles); err != nil {
+		return nil, err
+	}
+	if e.CreateBindings, err = check("create", bindings); err != nil {
+		return nil, err
+	}
+	if e.Bind, err = check("bind", roles); err != nil {
+		return nil, err
+	}
+	if namespace != "" {
+		if e.BindClusterRoles, err = check("bind", "clusterroles"); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// IsEscalationVerb reports whether verb is escalate or bind, which the API
+// server only checks on roles and clusterroles
+func IsEscalationVerb(verb string) bool {
+	return verb == "escalate" || verb == "bind"
+}
@@ -0,0 +1,95 @@
+package rbac
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ForbiddenError is the subject and request parsed from an API server
+// Forbidden message
+type ForbiddenError struct {
+	User            string
+	Request         PermissionRequest
+	NonResourcePath string // Set instead of Request for non-resource URLs
+}
+
+var (
+	// User "alice" cannot list resource "pods" in API group "" in the namespace "prod"
+	forbiddenUserRe = regexp.MustCompile(`(?i)\buser "([^"]*)" cannot (\S+) (.+)$`)
+
+	// resource "pods/exec" in API group "apps"
+	forbiddenResourceRe = regexp.MustCompile(`^resource "([^"]+)"(?: in API group "([^"]*)")?`)
+
+	// Older API servers: cannot list pods in the namespace "prod"
+	forbiddenBareResourceRe = regexp.MustCompile(`^([a-z0-9.\-/]+)\b`)
+
+	forbiddenPathRe      = regexp.MustCompile(`^path "([^"]+)"`)
+	forbiddenNamespaceRe = regexp.MustCompile(`in the namespace "([^"]+)"`)
+
+	// pods "web-0" is forbidden:
+	forbiddenNameRe = regexp.MustCompile(`(\S+) "([^"]+)" is forbidden:`)
+)
+
+// forbiddenShapes is shown when a message can't be parsed
+const forbiddenShapes = `expected an API server Forbidden message such as:
+  User "alice" cannot list resource "pods" in API group "" in the namespace "prod"
+  User "alice" cannot get resource "nodes" in API group "" at the cluster scope
+  pods "web-0" is forbidden: User "alice" cannot create resource "pods/exec" in API group "" in the namespace "prod"
+  User "system:serviceaccount:prod:api" cannot list deployments.apps in the namespace "prod"`
+
+// ParseForbiddenMessage extracts the subject and permission from a Forbidden
+// error as printed by kubectl or returned by the API server
+func ParseForbiddenMessage(msg string) (*ForbiddenError, error) {
+	// Tolerate messages copied from JSON and wrapped across lines
+	msg = strings.ReplaceAll(msg, `\"`, `"`)
+	msg = strings.Join(strings.Fields(msg), " ")
+
+	m := forbiddenUserRe.FindStringSubmatch(msg)
+	if m == nil {
+		return nil, fmt.Errorf("could not find `User \"...\" cannot VERB ...` in the message; %s", forbiddenShapes)
+	}
+
+	parsed := &ForbiddenError{User: m[1]}
+	verb, rest := m[2], m[3]
+
+	if pm := forbiddenPathRe.FindStringSubmatch(rest); pm != nil {
+		parsed.NonResourcePath = pm[1]
+		parsed.Request.Verb = verb
+		return parsed, nil
+	}
+
+	var resource, group string
+	if rm := forbiddenResourceRe.FindStringSubmatch(rest); rm != nil {
+		resource, group = rm[1], rm[2]
+	} else if bm := forbiddenBareResourceRe.FindStringSubmatch(rest); bm != nil {
+		resource = bm[1]
+		// The old format qualifies the resource with its group (deployments.apps)
+		if idx := strings.Index(resource, "."); idx != -1 {
+			resource, group = resource[:idx], resource[idx+1:]
+		}
+	} else {
+		return nil, fmt.Errorf("could not find the resource in the message; %s", forbiddenShapes)
+	}
+
+	parsed.Request = PermissionRequest{
+		Verb:     verb,
+		APIGroup: group,
+		Resource: resource,
+	}
+	if idx := strings.Index(resource, "/"); idx != -1 {
+		parsed.Request.Resource = resource[:idx]
+		parsed.Request.Subresource = resource[idx+1:]
+	}
+
+	if nm := forbiddenNamespaceRe.FindStringSubmatch(rest); nm != nil {
+		parsed.Request.Namespace = nm[1]
+	}
+
+	// The "<resource> "<name>" is forbidden" prefix carries the object name
+	if nm := forbiddenNameRe.FindStringSubmatch(msg); nm != nil {
+		parsed.Request.ResourceName = nm[2]
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,86 @@
+package rbac
+
+import (
+	"testing"
+)
+
+func TestParseForbiddenMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		wantUser string
+		want     PermissionRequest
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:     "namespaced kubectl error",
+			msg:      `Error from server (Forbidden): pods is forbidden: User "system:serviceaccount:prod:api" cannot list resource "pods" in API group "" in the namespace "prod"`,
+			wantUser: "system:serviceaccount:prod:api",
+			want:     PermissionRequest{Verb: "list", Resource: "pods", Namespace: "prod"},
+		},
+		{
+			name:     "cluster scope with group",
+			msg:      `clusterroles.rbac.authorization.k8s.io is forbidden: User "alice" cannot create resource "clusterroles" in API group "rbac.authorization.k8s.io" at the cluster scope`,
+			wantUser: "alice",
+			want:     PermissionRequest{Verb: "create", APIGroup: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+		},
+		{
+			name:     "subresource and resource name",
+			msg:      `Error from server (Forbidden): pods "web-0" is forbidden: User "bob" cannot create resource "pods/exec" in API group "" in the namespace "default"`,
+			wantUser: "bob",
+			want:     PermissionRequest{Verb: "create", Resource: "pods", Subresource: "exec", ResourceName: "web-0", Namespace: "default"},
+		},
+		{
+			name:     "trailing RBAC reason",
+			msg:      `secrets is forbidden: User "carol" cannot get resource "secrets" in API group "" in the namespace "kube-system": RBAC: clusterrole.rbac.authorization.k8s.io "view" not found`,
+			wantUser: "carol",
+			want:     PermissionRequest{Verb: "get", Resource: "secrets", Namespace: "kube-system"},
+		},
+		{
+			name:     "old phrasing with qualified resource",
+			msg:      `User "dave" cannot list deployments.apps in the namespace "prod"`,
+			wantUser: "dave",
+			want:     PermissionRequest{Verb: "list", APIGroup: "apps", Resource: "deployments", Namespace: "prod"},
+		},
+		{
+			name:     "escaped quotes wrapped across lines",
+			msg:      "nodes is forbidden: User \\\"eve\\\" cannot list resource \\\"nodes\\\"\n  in API group \\\"\\\" at the cluster scope",
+			wantUser: "eve",
+			want:     PermissionRequest{Verb: "list", Resource: "nodes"},
+		},
+		{
+			name:     "non-resource path",
+			msg:      `forbidden: User "system:anonymous" cannot get path "/metrics"`,
+			wantUser: "system:anonymous",
+			want:     PermissionRequest{Verb: "get"},
+			wantPath: "/metrics",
+		},
+		{
+			name:    "unrelated error",
+			msg:     `Error from server (NotFound): pods "web-0" not found`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseForbiddenMessage(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseForbiddenMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.User != tt.wantUser {
+				t.Errorf("User = %q, want %q", got.User, tt.wantUser)
+			}
+			if got.Request != tt.want {
+				t.Errorf("Request = %+v, want %+v", got.Request, tt.want)
+			}
+			if got.NonResourcePath != tt.wantPath {
+				t.Errorf("NonResourcePath = %q, want %q", got.NonResourcePath, tt.wantPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Kinds of identity the impersonate verb can assume
+const (
+	ImpersonateUser           = "User"
+	ImpersonateGroup          = "Group"
+	ImpersonateServiceAccount = "ServiceAccount"
+	ImpersonateUID            = "UID"
+	ImpersonateUserExtra      = "UserExtra"
+)
+
+// authenticationGroup is the API group of the uids and userextras resources
+const authenticationGroup = "authentication.k8s.io"
+
+// impersonationKinds is the order identities are reported in
+var impersonationKinds = []string{
+	ImpersonateUser, ImpersonateGroup, ImpersonateServiceAccount, ImpersonateUID, ImpersonateUserExtra,
+}
+
+// ImpersonationTarget is one set of identities a grant of the impersonate
+// verb lets the subject assume
+type ImpersonationTarget struct {
+	Kind string // One of the Impersonate* kinds
+
+	// Names are the identities the rule's resourceNames limit it to; empty
+	// means any. For UserExtra they are the values the key may take.
+	Names []string
+	// Namespace limits ServiceAccounts to one namespace when the grant comes
+	// from a RoleBinding
+	Namespace string
+	// ExtraKey is the userextras key, empty for any key
+	ExtraKey string
+
+	Grant PermissionGrant
+}
+
+// Any reports whether the target is not limited by resourceNames
+func (t ImpersonationTarget) Any() bool {
+	return len(t.Names) == 0
+}
+
+// String describes the identities, e.g. "any ServiceAccount in namespace prod"
+// or "user alice, bob"
+func (t ImpersonationTarget) String() string {
+	names := strings.Join(t.Names, ", ")
+	switch t.Kind {
+	case ImpersonateUser:
+		if t.Any() {
+			return "any user"
+		}
+		return "user " + names
+	case ImpersonateGroup:
+		if t.Any() {
+			return "any group"
+		}
+		return "group " + names
+	case ImpersonateServiceAccount:
+		switch {
+		case t.Any() && t.Namespace != "":
+			return "any ServiceAccount in namespace " + t.Namespace
+		case t.Any():
+			return "any ServiceAccount"
+		case t.Namespace != "":
+			return "ServiceAccount " + t.Namespace + "/" + strings.Join(t.Names, ", "+t.Namespace+"/")
+		default:
+			return "ServiceAccount " + names + " in any namespace"
+		}
+	case ImpersonateUID:
+		if t.Any() {
+			return "any UID"
+		}
+		return "UID " + names
+	case ImpersonateUserExtra:
+		switch {
+		case t.ExtraKey == "" && t.Any():
+			return "any user extra"
+		case t.ExtraKey == "":
+			return "any user extra = " + names
+		case t.Any():
+			return "user extra " + t.ExtraKey + " with any value"
+		default:
+			return "user extra " + t.ExtraKey + " = " + names
+		}
+	}
+	return t.Kind
+}
+
+// Impersonation is every identity a subject can assume with the impersonate
+// verb
+type Impersonation struct {
+	Subject Subject
+	// BypassedVia is set when the subject bypasses authorization and can
+	// impersonate anyone
+	BypassedVia string
+	Targets     []ImpersonationTarget
+}
+
+// CanImpersonate reports whether the subject can assume any identity
+func (i *Impersonation) CanImpersonate() bool {
+	return i.BypassedVia != "" || len(i.Targets) > 0
+}
+
+// ResolveImpersonation finds every impersonate grant bound to subject and
+// expands it into the identities it covers. A RoleBinding only grants
+// impersonating ServiceAccounts in its own namespace: users, groups, UIDs,
+// and user extras are cluster-scoped, so RoleBinding grants for them are
+// left out.
+func (r *Resolver) ResolveImpersonation(ctx context.Context, subject Subject) (*Impersonation, error) {
+	result := &Impersonation{Subject: subject}
+	if group := BypassingGroup(subject, GetImplicitGroups(subject)); group != "" {
+		result.BypassedVia = group
+		return result, nil
+	}
+
+	grants, err := r.AllGrants(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range grants {
+		if !matchesVerb(g.MatchingRule.Verbs, "impersonate") {
+			continue
+		}
+		for _, t := range impersonationTargets(g.MatchingRule) {
+			if g.Scope == ScopeNamespace {
+				if t.Kind != ImpersonateServiceAccount {
+					continue
+				}
+				t.Namespace = g.Binding.Namespace
+			}
+			t.Grant = g
+			result.Targets = append(result.Targets, t)
+		}
+	}
+
+	order := make(map[string]int, len(impersonationKinds))
+	for i, kind := range impersonationKinds {
+		order[kind] = i
+	}
+	sort.SliceStable(result.Targets, func(i, j int) bool {
+		a, b := result.Targets[i], result.Targets[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.Any() != b.Any() {
+			return a.Any()
+		}
+		return a.Namespace < b.Namespace
+	})
+	return result, nil
+}
+
+// impersonationTargets expands the resources of an impersonate rule into
+// the kinds of identity it covers, limited by its resourceNames
+func impersonationTargets(rule rbacv1.PolicyRule) []ImpersonationTarget {
+	core := matchesAPIGroup(rule.APIGroups, "")
+	auth := matchesAPIGroup(rule.APIGroups, authenticationGroup)
+
+	var targets []ImpersonationTarget
+	add := func(kind, key string) {
+		targets = append(targets, ImpersonationTarget{Kind: kind, Names: rule.ResourceNames, ExtraKey: key})
+	}
+	for _, res := range rule.Resources {
+		switch {
+		case res == rbacv1.ResourceAll:
+			if core {
+				add(ImpersonateUser, "")
+				add(ImpersonateGroup, "")
+				add(ImpersonateServiceAccount, "")
+			}
+			if auth {
+				add(ImpersonateUID, "")
+				add(ImpersonateUserExtra, "")
+			}
+		case core && res == "users":
+			add(ImpersonateUser, "")
+		case core && res == "groups":
+			add(ImpersonateGroup, "")
+		case core && res == "serviceaccounts":
+			add(ImpersonateServiceAccount, "")
+		case auth && res == "uids":
+			add(ImpersonateUID, "")
+		case auth && strings.HasPrefix(res, "userextras/"):
+			add(ImpersonateUserExtra, strings.TrimPrefix(res, "userextras/"))
+		case auth && strings.HasPrefix(res, "*/"):
+			// "*/KEY" matches the userextras/KEY subresource
+			add(ImpersonateUserExtra, strings.TrimPrefix(res, "*/"))
+		}
+	}
+	return targets
+}
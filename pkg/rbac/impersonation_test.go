@@ -0,0 +1,119 @@
+package rbac
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestResolveImpersonation(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"impersonate"}, APIGroups: []string{""}, Resources: []string{"users", "groups"}, ResourceNames: []string{"alice", "admins"}},
+			{Verbs: []string{"impersonate"}, APIGroups: []string{"authentication.k8s.io"}, Resources: []string{"userextras/scopes"}, ResourceNames: []string{"view"}},
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"users"}},
+		},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "impersonate-all"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"impersonate"}, APIGroups: []string{""}, Resources: []string{"*"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "impersonator"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "impersonator"},
+	})
+	// Through a RoleBinding only ServiceAccounts in its namespace count
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "impersonate-all", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "impersonate-all"},
+	})
+
+	tests := []struct {
+		name    string
+		subject Subject
+		want    []string
+		bypass  string
+	}{
+		{
+			name:    "resourceNames and namespaces",
+			subject: Subject{Kind: "User", Name: "bob"},
+			want:    []string{"user alice, admins", "group alice, admins", "any ServiceAccount in namespace prod", "user extra scopes = view"},
+		},
+		{
+			name:    "no grants",
+			subject: Subject{Kind: "User", Name: "carol"},
+		},
+		{
+			name:    "system:masters",
+			subject: Subject{Kind: "User", Name: "carol", Groups: []string{SystemMastersGroup}},
+			bypass:  SystemMastersGroup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewResolver(mock).ResolveImpersonation(context.Background(), tt.subject)
+			if err != nil {
+				t.Fatalf("ResolveImpersonation() error = %v", err)
+			}
+			var got []string
+			for _, target := range result.Targets {
+				got = append(got, target.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("targets = %q, want %q", got, tt.want)
+			}
+			if result.BypassedVia != tt.bypass {
+				t.Errorf("BypassedVia = %q, want %q", result.BypassedVia, tt.bypass)
+			}
+			if result.CanImpersonate() != (len(tt.want) > 0 || tt.bypass != "") {
+				t.Errorf("CanImpersonate() = %v", result.CanImpersonate())
+			}
+		})
+	}
+}
+
+func TestImpersonationTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		rule rbacv1.PolicyRule
+		want []string
+	}{
+		{
+			name: "wildcard resource in both groups",
+			rule: rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}},
+			want: []string{ImpersonateUser, ImpersonateGroup, ImpersonateServiceAccount, ImpersonateUID, ImpersonateUserExtra},
+		},
+		{
+			name: "core group only",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"serviceaccounts", "uids", "userextras/scopes"}},
+			want: []string{ImpersonateServiceAccount},
+		},
+		{
+			name: "subresource wildcard",
+			rule: rbacv1.PolicyRule{APIGroups: []string{"authentication.k8s.io"}, Resources: []string{"*/scopes", "uids"}},
+			want: []string{ImpersonateUserExtra, ImpersonateUID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, target := range impersonationTargets(tt.rule) {
+				got = append(got, target.Kind)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("impersonationTargets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
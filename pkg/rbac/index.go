@@ -0,0 +1,326 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+// BindingIndex maps every subject named by a ClusterRoleBinding or
+// RoleBinding to the bindings that name it. A Resolver given an index with
+// WithBindingIndex looks a subject's bindings up instead of scanning them
+// all, which pays off when many subjects or checks are resolved against the
+// same bindings.
+//
+// The zero value is an empty index. Refresh fills it from a client and keeps
+// it current by resourceVersion; watchers can apply single changes with the
+// Set and Delete methods instead. It is safe for concurrent use.
+type BindingIndex struct {
+	mu  sync.RWMutex
+	seq int
+
+	clusterRoleBindings map[string]indexed[rbacv1.ClusterRoleBinding]
+	roleBindings        map[string]indexed[rbacv1.RoleBinding] // by namespace/name
+
+	// The subject keys each binding is indexed under, by binding key
+	clusterBySubject    map[string]map[string]bool
+	namespacedBySubject map[string]map[string]bool
+}
+
+// indexed is a binding with its position in the listing, so lookups return
+// bindings in the order a scan would have found them
+type indexed[T any] struct {
+	seq     int
+	binding T
+}
+
+// NewBindingIndex lists every ClusterRoleBinding and RoleBinding from c and
+// indexes them
+func NewBindingIndex(ctx context.Context, c client.RBACClient) (*BindingIndex, error) {
+	x := &BindingIndex{}
+	if _, err := x.Refresh(ctx, c); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Refresh lists the bindings from c again and brings the index up to date:
+// bindings whose resourceVersion changed are re-indexed, and those no longer
+// listed are dropped. It reports whether anything changed. Bindings without
+// a resourceVersion, as read from manifests, are always re-indexed.
+func (x *BindingIndex) Refresh(ctx context.Context, c client.RBACClient) (bool, error) {
+	crbs, err := c.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	rbs, err := c.ListRoleBindings(ctx, "")
+	if err != nil {
+		return false, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+
+	changed := false
+	listed := make(map[string]bool, len(crbs.Items)+len(rbs.Items))
+	for i := range crbs.Items {
+		listed["/"+crbs.Items[i].Name] = true
+		changed = x.SetClusterRoleBinding(&crbs.Items[i]) || changed
+	}
+	for i := range rbs.Items {
+		listed[rbs.Items[i].Namespace+"/"+rbs.Items[i].Name] = true
+		changed = x.SetRoleBinding(&rbs.Items[i]) || changed
+	}
+
+	x.mu.RLock()
+	var deleted []string
+	for name := range x.clusterRoleBindings {
+		if !listed["/"+name] {
+			deleted = append(deleted, "/"+name)
+		}
+	}
+	for key := range x.roleBindings {
+		if !listed[key] {
+			deleted = append(deleted, key)
+		}
+	}
+	x.mu.RUnlock()
+	for _, key := range deleted {
+		x.mu.Lock()
+		if name, ok := cutClusterKey(key); ok {
+			x.deleteClusterRoleBinding(name)
+		} else {
+			x.deleteRoleBinding(key)
+		}
+		x.mu.Unlock()
+	}
+	return changed || len(deleted) > 0, nil
+}
+
+// cutClusterKey returns the name of a ClusterRoleBinding key, which has no
+// namespace before its slash
+func cutClusterKey(key string) (string, bool) {
+	if len(key) > 0 && key[0] == '/' {
+		return key[1:], true
+	}
+	return "", false
+}
+
+// SetClusterRoleBinding adds or updates crb. It reports false, leaving the
+// index alone, when the indexed copy has the same resourceVersion.
+func (x *BindingIndex) SetClusterRoleBinding(crb *rbacv1.ClusterRoleBinding) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.init()
+	old, ok := x.clusterRoleBindings[crb.Name]
+	if ok && crb.ResourceVersion != "" && old.binding.ResourceVersion == crb.ResourceVersion {
+		return false
+	}
+	seq := old.seq
+	if !ok {
+		seq = x.next()
+	}
+	x.deleteClusterRoleBinding(crb.Name)
+	x.clusterRoleBindings[crb.Name] = indexed[rbacv1.ClusterRoleBinding]{seq: seq, binding: *crb}
+	for _, s := range crb.Subjects {
+		addKey(x.clusterBySubject, bindingSubjectKey(s), crb.Name)
+	}
+	return true
+}
+
+// SetRoleBinding adds or updates rb, like SetClusterRoleBinding
+func (x *BindingIndex) SetRoleBinding(rb *rbacv1.RoleBinding) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.init()
+	key := rb.Namespace + "/" + rb.Name
+	old, ok := x.roleBindings[key]
+	if ok && rb.ResourceVersion != "" && old.binding.ResourceVersion == rb.ResourceVersion {
+		return false
+	}
+	seq := old.seq
+	if !ok {
+		seq = x.next()
+	}
+	x.deleteRoleBinding(key)
+	x.roleBindings[key] = indexed[rbacv1.RoleBinding]{seq: seq, binding: *rb}
+	for _, s := range rb.Subjects {
+		addKey(x.namespacedBySubject, bindingSubjectKey(s), key)
+	}
+	return true
+}
+
+// DeleteClusterRoleBinding removes the named ClusterRoleBinding
+func (x *BindingIndex) DeleteClusterRoleBinding(name string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.deleteClusterRoleBinding(name)
+}
+
+// DeleteRoleBinding removes the RoleBinding namespace/name
+func (x *BindingIndex) DeleteRoleBinding(namespace, name string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.deleteRoleBinding(namespace + "/" + name)
+}
+
+func (x *BindingIndex) deleteClusterRoleBinding(name string) {
+	old, ok := x.clusterRoleBindings[name]
+	if !ok {
+		return
+	}
+	for _, s := range old.binding.Subjects {
+		removeKey(x.clusterBySubject, bindingSubjectKey(s), name)
+	}
+	delete(x.clusterRoleBindings, name)
+}
+
+func (x *BindingIndex) deleteRoleBinding(key string) {
+	old, ok := x.roleBindings[key]
+	if !ok {
+		return
+	}
+	for _, s := range old.binding.Subjects {
+		removeKey(x.namespacedBySubject, bindingSubjectKey(s), key)
+	}
+	delete(x.roleBindings, key)
+}
+
+// ClusterRoleBindings returns the ClusterRoleBindings that name subject or
+// one of groups, in the order they were first indexed
+func (x *BindingIndex) ClusterRoleBindings(subject Subject, groups []string) []rbacv1.ClusterRoleBinding {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var found []indexed[rbacv1.ClusterRoleBinding]
+	for name := range x.candidates(x.clusterBySubject, subject, groups) {
+		found = append(found, x.clusterRoleBindings[name])
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+	bindings := make([]rbacv1.ClusterRoleBinding, 0, len(found))
+	for _, f := range found {
+		bindings = append(bindings, f.binding)
+	}
+	return bindings
+}
+
+// RoleBindings returns the RoleBindings in namespace, or in every namespace
+// when it is empty, that name subject or one of groups
+func (x *BindingIndex) RoleBindings(subject Subject, groups []string, namespace string) []rbacv1.RoleBinding {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	var found []indexed[rbacv1.RoleBinding]
+	for key := range x.candidates(x.namespacedBySubject, subject, groups) {
+		if rb := x.roleBindings[key]; namespace == "" || rb.binding.Namespace == namespace {
+			found = append(found, rb)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].seq < found[j].seq })
+	bindings := make([]rbacv1.RoleBinding, 0, len(found))
+	for _, f := range found {
+		bindings = append(bindings, f.binding)
+	}
+	return bindings
+}
+
+// candidates collects the keys of the bindings indexed under the subject or
+// any of its groups
+func (x *BindingIndex) candidates(bySubject map[string]map[string]bool, subject Subject, groups []string) map[string]bool {
+	keys := make(map[string]bool)
+	for key := range bySubject[subjectKey(subject.Kind, subject.Namespace, subject.Name)] {
+		keys[key] = true
+	}
+	for _, g := range groups {
+		for key := range bySubject[subjectKey("Group", "", g)] {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+func (x *BindingIndex) init() {
+	if x.clusterRoleBindings == nil {
+		x.clusterRoleBindings = make(map[string]indexed[rbacv1.ClusterRoleBinding])
+		x.roleBindings = make(map[string]indexed[rbacv1.RoleBinding])
+		x.clusterBySubject = make(map[string]map[string]bool)
+		x.namespacedBySubject = make(map[string]map[string]bool)
+	}
+}
+
+func (x *BindingIndex) next() int {
+	x.seq++
+	return x.seq
+}
+
+// subjectKey identifies a subject the way SubjectMatches compares them: by
+// kind and name, and by namespace only for ServiceAccounts
+func subjectKey(kind, namespace, name string) string {
+	if kind != "ServiceAccount" {
+		namespace = ""
+	}
+	return kind + "/" + namespace + "/" + name
+}
+
+func bindingSubjectKey(s rbacv1.Subject) string {
+	return subjectKey(s.Kind, s.Namespace, s.Name)
+}
+
+func addKey(m map[string]map[string]bool, subject, binding string) {
+	if m[subject] == nil {
+		m[subject] = make(map[string]bool)
+	}
+	m[subject][binding] = true
+}
+
+func removeKey(m map[string]map[string]bool, subject, binding string) {
+	delete(m[subject], binding)
+	if len(m[subject]) == 0 {
+		delete(m, subject)
+	}
+}
+
+// WithBindingIndex looks up the bindings that apply to a subject in idx
+// instead of listing every binding from the client. Traces still list them
+// all, since they show the bindings that don't apply too.
+func WithBindingIndex(idx *BindingIndex) ResolverOption {
+	return func(c *resolverConfig) {
+		c.index = idx
+	}
+}
+
+// clusterRoleBindingsFor returns the ClusterRoleBindings that apply to the
+// subject, from the index or else streamed from the client
+func (r *Resolver) clusterRoleBindingsFor(ctx context.Context, subject Subject, groups []string) ([]rbacv1.ClusterRoleBinding, error) {
+	if r.index != nil {
+		return r.index.ClusterRoleBindings(subject, groups), nil
+	}
+	var bindings []rbacv1.ClusterRoleBinding
+	err := r.bindings.EachClusterRoleBinding(ctx, func(crb *rbacv1.ClusterRoleBinding) error {
+		if bindingMatchesSubject(crb.Subjects, subject, groups) >= 0 {
+			bindings = append(bindings, *crb)
+		} else {
+			r.logBinding(ctx, "ClusterRoleBinding", "", crb.Name, crb.Subjects, -1)
+		}
+		return nil
+	})
+	return bindings, err
+}
+
+// roleBindingsFor returns the RoleBindings in namespace, or in every
+// namespace when it is empty, that apply to the subject
+func (r *Resolver) roleBindingsFor(ctx context.Context, subject Subject, groups []string, namespace string) ([]rbacv1.RoleBinding, error) {
+	if r.index != nil {
+		return r.index.RoleBindings(subject, groups, namespace), nil
+	}
+	var bindings []rbacv1.RoleBinding
+	err := r.bindings.EachRoleBinding(ctx, namespace, func(rb *rbacv1.RoleBinding) error {
+		if bindingMatchesSubject(rb.Subjects, subject, groups) >= 0 {
+			bindings = append(bindings, *rb)
+		} else {
+			r.logBinding(ctx, "RoleBinding", rb.Namespace, rb.Name, rb.Subjects, -1)
+		}
+		return nil
+	})
+	return bindings, err
+}
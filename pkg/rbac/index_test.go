@@ -0,0 +1,233 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestBindingIndex_MatchesScan(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "editor"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "authenticated-readers"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:authenticated"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice-editor"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}, {Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "editor"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "prod", Name: "deployer"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "editor"},
+	})
+	// Names the deployer in another namespace, so it doesn't apply to
+	// prod's deployer
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployer", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Namespace: "dev", Name: "deployer"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "editor"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "service-accounts", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:serviceaccounts:prod"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+	})
+
+	idx, err := NewBindingIndex(context.Background(), mock)
+	if err != nil {
+		t.Fatalf("NewBindingIndex() error = %v", err)
+	}
+	scan := NewResolver(mock)
+	indexed := NewResolver(mock, WithBindingIndex(idx))
+
+	subjects := []Subject{
+		{Kind: "User", Name: "alice"},
+		{Kind: "User", Name: "carol"},
+		{Kind: "ServiceAccount", Namespace: "prod", Name: "deployer"},
+		{Kind: "ServiceAccount", Namespace: "dev", Name: "deployer"},
+	}
+	for _, subject := range subjects {
+		for _, ns := range []string{"", "prod", "dev"} {
+			t.Run(subject.String()+"/"+ns, func(t *testing.T) {
+				want, err := scan.ResolveAllPermissions(context.Background(), subject, ns)
+				if err != nil {
+					t.Fatalf("ResolveAllPermissions() error = %v", err)
+				}
+				got, err := indexed.ResolveAllPermissions(context.Background(), subject, ns)
+				if err != nil {
+					t.Fatalf("ResolveAllPermissions() with index error = %v", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("grants with index = %+v, want %+v", got, want)
+				}
+
+				request := PermissionRequest{Verb: "delete", Resource: "secrets", Namespace: ns}
+				wantResult, err := scan.ResolvePermission(context.Background(), subject, request)
+				if err != nil {
+					t.Fatalf("ResolvePermission() error = %v", err)
+				}
+				gotResult, err := indexed.ResolvePermission(context.Background(), subject, request)
+				if err != nil {
+					t.Fatalf("ResolvePermission() with index error = %v", err)
+				}
+				if gotResult.Allowed != wantResult.Allowed || !reflect.DeepEqual(gotResult.Grants, wantResult.Grants) {
+					t.Errorf("result with index = %+v, want %+v", gotResult, wantResult)
+				}
+			})
+		}
+	}
+}
+
+func TestBindingIndex_Refresh(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "admins", ResourceVersion: "1"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: "prod", ResourceVersion: "2"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+	})
+
+	ctx := context.Background()
+	alice := Subject{Kind: "User", Name: "alice"}
+	bob := Subject{Kind: "User", Name: "bob"}
+	idx, err := NewBindingIndex(ctx, mock)
+	if err != nil {
+		t.Fatalf("NewBindingIndex() error = %v", err)
+	}
+	if got := len(idx.ClusterRoleBindings(alice, nil)); got != 1 {
+		t.Fatalf("alice has %d ClusterRoleBindings, want 1", got)
+	}
+
+	if changed, err := idx.Refresh(ctx, mock); err != nil || changed {
+		t.Errorf("Refresh() = %v, %v; want no change", changed, err)
+	}
+
+	// An update with a new resourceVersion is re-indexed
+	mock.ClusterRoleBindings.Items[0].ResourceVersion = "3"
+	mock.ClusterRoleBindings.Items[0].Subjects = []rbacv1.Subject{{Kind: "User", Name: "bob"}}
+	if changed, err := idx.Refresh(ctx, mock); err != nil || !changed {
+		t.Errorf("Refresh() = %v, %v; want a change", changed, err)
+	}
+	if got := len(idx.ClusterRoleBindings(alice, nil)); got != 0 {
+		t.Errorf("alice has %d ClusterRoleBindings after the update, want 0", got)
+	}
+	if got := len(idx.ClusterRoleBindings(bob, nil)); got != 1 {
+		t.Errorf("bob has %d ClusterRoleBindings after the update, want 1", got)
+	}
+
+	// A binding no longer listed is dropped
+	mock.RoleBindings["prod"].Items = nil
+	if changed, err := idx.Refresh(ctx, mock); err != nil || !changed {
+		t.Errorf("Refresh() = %v, %v; want a change", changed, err)
+	}
+	if got := len(idx.RoleBindings(alice, nil, "prod")); got != 0 {
+		t.Errorf("alice has %d RoleBindings after the delete, want 0", got)
+	}
+
+	// Watch events apply single changes
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "editors", Namespace: "dev", ResourceVersion: "4"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "devs"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+	}
+	if !idx.SetRoleBinding(rb) {
+		t.Error("SetRoleBinding() = false for a new binding")
+	}
+	if idx.SetRoleBinding(rb) {
+		t.Error("SetRoleBinding() = true for an unchanged resourceVersion")
+	}
+	if got := len(idx.RoleBindings(alice, []string{"devs"}, "")); got != 1 {
+		t.Errorf("group devs has %d RoleBindings, want 1", got)
+	}
+	idx.DeleteRoleBinding("dev", "editors")
+	if got := len(idx.RoleBindings(alice, []string{"devs"}, "")); got != 0 {
+		t.Errorf("group devs has %d RoleBindings after the delete, want 0", got)
+	}
+}
+
+// newSyntheticClient returns a client with 5,000 bindings: 2,500
+// ClusterRoleBindings and 2,500 RoleBindings across 50 namespaces, each
+// naming its own user
+func newSyntheticClient() *client.MockRBACClient {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+	})
+	for i := range 2500 {
+		mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("crb-%d", i), ResourceVersion: "1"},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: fmt.Sprintf("user-%d", i)}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+		})
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("rb-%d", i), Namespace: fmt.Sprintf("ns-%d", i%50), ResourceVersion: "1"},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: fmt.Sprintf("user-%d", i)}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+		})
+	}
+	return mock
+}
+
+func benchmarkResolveAllPermissions(b *testing.B, index bool) {
+	mock := newSyntheticClient()
+	var opts []ResolverOption
+	if index {
+		idx, err := NewBindingIndex(context.Background(), mock)
+		if err != nil {
+			b.Fatal(err)
+		}
+		opts = append(opts, WithBindingIndex(idx))
+	}
+	resolver := NewResolver(mock, opts...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		subject := Subject{Kind: "User", Name: fmt.Sprintf("user-%d", i%2500)}
+		if _, err := resolver.ResolveAllPermissions(context.Background(), subject, fmt.Sprintf("ns-%d", i%50)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkResolveAllPermissions_Scan(b *testing.B) {
+	benchmarkResolveAllPermissions(b, false)
+}
+
+func BenchmarkResolveAllPermissions_Index(b *testing.B) {
+	benchmarkResolveAllPermissions(b, true)
+}
+
+func BenchmarkBindingIndex_Refresh(b *testing.B) {
+	mock := newSyntheticClient()
+	idx, err := NewBindingIndex(context.Background(), mock)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := idx.Refresh(context.Background(), mock); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
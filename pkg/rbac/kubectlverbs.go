@@ -0,0 +1,72 @@
+package rbac
+
+import "fmt"
+
+// KubectlVerb maps a kubectl command that is often mistaken for an RBAC verb
+// to the check the API server actually performs
+type KubectlVerb struct {
+	Command     string // What people type, e.g. "exec"
+	Description string // e.g. "pod exec"
+	Verb        string
+	// Resource replaces the requested resource when set; otherwise the
+	// requested resource is kept (e.g. "scale deployments")
+	Resource    string
+	Subresource string
+}
+
+// kubectlVerbs is the single table of kubectl commands that are not RBAC verbs
+var kubectlVerbs = []KubectlVerb{
+	{Command: "exec", Description: "pod exec", Verb: "create", Resource: "pods", Subresource: "exec"},
+	{Command: "cp", Description: "kubectl cp (runs tar through exec)", Verb: "create", Resource: "pods", Subresource: "exec"},
+	{Command: "attach", Description: "pod attach", Verb: "create", Resource: "pods", Subresource: "attach"},
+	{Command: "logs", Description: "reading pod logs", Verb: "get", Resource: "pods", Subresource: "log"},
+	{Command: "log", Description: "reading pod logs", Verb: "get", Resource: "pods", Subresource: "log"},
+	{Command: "port-forward", Description: "pod port-forward", Verb: "create", Resource: "pods", Subresource: "portforward"},
+	{Command: "portforward", Description: "pod port-forward", Verb: "create", Resource: "pods", Subresource: "portforward"},
+	{Command: "scale", Description: "scaling", Verb: "update", Subresource: "scale"},
+	{Command: "edit", Description: "kubectl edit", Verb: "update"},
+	{Command: "apply", Description: "kubectl apply to an existing object", Verb: "patch"},
+	{Command: "describe", Description: "kubectl describe", Verb: "get"},
+}
+
+// LookupKubectlVerb returns the mapping for a kubectl command used as a verb
+func LookupKubectlVerb(verb string) (KubectlVerb, bool) {
+	for _, kv := range kubectlVerbs {
+		if kv.Command == verb {
+			return kv, true
+		}
+	}
+	return KubectlVerb{}, false
+}
+
+// Correct rewrites request into the check the kubectl command needs
+func (kv KubectlVerb) Correct(request PermissionRequest) PermissionRequest {
+	request.Verb = kv.Verb
+	if kv.Resource != "" {
+		request.Resource = kv.Resource
+		request.APIGroup = ""
+	}
+	if kv.Subresource != "" {
+		request.Subresource = kv.Subresource
+	}
+	return request
+}
+
+// Suggestion explains the corrected check, e.g. "pod exec requires: create pods/exec"
+func (kv KubectlVerb) Suggestion(request PermissionRequest) string {
+	corrected := kv.Correct(request)
+	return fmt.Sprintf("%s requires: %s %s", kv.Description, corrected.Verb, ResourceArg(corrected))
+}
+
+// ResourceArg formats the resource of a request the way can-i accepts it,
+// e.g. "deployments.apps/scale"
+func ResourceArg(request PermissionRequest) string {
+	arg := request.Resource
+	if request.APIGroup != "" {
+		arg += "." + request.APIGroup
+	}
+	if request.Subresource != "" {
+		arg += "/" + request.Subresource
+	}
+	return arg
+}
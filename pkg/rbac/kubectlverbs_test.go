@@ -0,0 +1,31 @@
+package rbac
+
+import "testing"
+
+func TestKubectlVerbSuggestion(t *testing.T) {
+	tests := []struct {
+		verb    string
+		request PermissionRequest
+		want    string
+	}{
+		{verb: "exec", request: PermissionRequest{Resource: "pods"}, want: "pod exec requires: create pods/exec"},
+		{verb: "logs", request: PermissionRequest{Resource: "pod"}, want: "reading pod logs requires: get pods/log"},
+		{verb: "port-forward", request: PermissionRequest{Resource: "pods"}, want: "pod port-forward requires: create pods/portforward"},
+		{verb: "scale", request: PermissionRequest{Resource: "deployments", APIGroup: "apps"}, want: "scaling requires: update deployments.apps/scale"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.verb, func(t *testing.T) {
+			kv, ok := LookupKubectlVerb(tt.verb)
+			if !ok {
+				t.Fatalf("LookupKubectlVerb(%q) found nothing", tt.verb)
+			}
+			if got := kv.Suggestion(tt.request); got != tt.want {
+				t.Errorf("Suggestion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := LookupKubectlVerb("get"); ok {
+		t.Error("get is an RBAC verb and must not be corrected")
+	}
+}
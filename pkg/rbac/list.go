@@ -0,0 +1,86 @@
+package rbac
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// PermissionRow is one verb on one resource, or non-resource URL, that a
+// subject may use, with every grant that allows it
+type PermissionRow struct {
+	Verb     string
+	APIGroup string
+	Resource string // May include a subresource, e.g. "pods/exec"
+	// ResourceNames limits the row to these objects; empty means all
+	ResourceNames  []string
+	NonResourceURL string
+
+	Grants []PermissionGrant
+}
+
+// Wildcard reports whether the row uses "*" for its verb, group, resource,
+// subresource, or URL
+func (p PermissionRow) Wildcard() bool {
+	return p.Verb == rbacv1.VerbAll || p.APIGroup == rbacv1.APIGroupAll ||
+		p.Resource == rbacv1.ResourceAll || strings.HasSuffix(p.Resource, "/*") ||
+		strings.HasSuffix(p.NonResourceURL, "*")
+}
+
+// RuleHasWildcard reports whether any field of rule uses "*"
+func RuleHasWildcard(rule rbacv1.PolicyRule) bool {
+	for _, row := range expandRule(rule) {
+		if row.Wildcard() {
+			return true
+		}
+	}
+	return false
+}
+
+// ListPermissions expands the rules of grants into one row per verb, API
+// group, and resource or URL. Rows granted by several rules are listed once
+// with all of their grants. Rows are sorted by API group, resource, and verb.
+func ListPermissions(grants []PermissionGrant) []PermissionRow {
+	var rows []PermissionRow
+	index := make(map[string]int)
+	for _, g := range grants {
+		for _, row := range expandRule(g.MatchingRule) {
+			key := strings.Join([]string{row.Verb, row.APIGroup, row.Resource, strings.Join(row.ResourceNames, ","), row.NonResourceURL}, "|")
+			i, ok := index[key]
+			if !ok {
+				i = len(rows)
+				index[key] = i
+				rows = append(rows, row)
+			}
+			rows[i].Grants = append(rows[i].Grants, g)
+		}
+	}
+
+	slices.SortStableFunc(rows, func(a, b PermissionRow) int {
+		return cmp.Or(
+			cmp.Compare(a.NonResourceURL, b.NonResourceURL),
+			cmp.Compare(a.APIGroup, b.APIGroup),
+			cmp.Compare(a.Resource, b.Resource),
+			cmp.Compare(a.Verb, b.Verb),
+		)
+	})
+	return rows
+}
+
+// expandRule returns the rows a single rule grants, without grants
+func expandRule(rule rbacv1.PolicyRule) []PermissionRow {
+	var rows []PermissionRow
+	for _, verb := range rule.Verbs {
+		for _, url := range rule.NonResourceURLs {
+			rows = append(rows, PermissionRow{Verb: verb, NonResourceURL: url})
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				rows = append(rows, PermissionRow{Verb: verb, APIGroup: group, Resource: resource, ResourceNames: rule.ResourceNames})
+			}
+		}
+	}
+	return rows
+}
@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestListPermissions(t *testing.T) {
+	grants := []PermissionGrant{
+		{Binding: BindingInfo{Kind: "ClusterRoleBinding", Name: "a"}, MatchingRule: rbacv1.PolicyRule{
+			Verbs: []string{"get", "list"}, APIGroups: []string{"", "apps"}, Resources: []string{"deployments"},
+		}},
+		{Binding: BindingInfo{Kind: "ClusterRoleBinding", Name: "b"}, MatchingRule: rbacv1.PolicyRule{
+			Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"},
+		}},
+		{Binding: BindingInfo{Kind: "ClusterRoleBinding", Name: "c"}, MatchingRule: rbacv1.PolicyRule{
+			Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}, ResourceNames: []string{"api"},
+		}},
+		{Binding: BindingInfo{Kind: "ClusterRoleBinding", Name: "d"}, MatchingRule: rbacv1.PolicyRule{
+			Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics", "/debug/*"},
+		}},
+	}
+
+	rows := ListPermissions(grants)
+	var got []string
+	for _, row := range rows {
+		key := row.Verb + " " + row.APIGroup + " " + row.Resource + row.NonResourceURL
+		if len(row.ResourceNames) > 0 {
+			key += "/" + row.ResourceNames[0]
+		}
+		if row.Wildcard() {
+			key += " (wildcard)"
+		}
+		for _, g := range row.Grants {
+			key += " " + g.Binding.Name
+		}
+		got = append(got, key)
+	}
+	want := []string{
+		"get  deployments a",
+		"list  deployments a",
+		"get apps deployments a b",
+		"get apps deployments/api c",
+		"list apps deployments a",
+		"get  /debug/* (wildcard) d",
+		"get  /metrics d",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package rbac
 
 import (
+	"fmt"
+	"slices"
+
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
@@ -11,6 +14,11 @@ func RuleMatches(rule rbacv1.PolicyRule, request PermissionRequest) bool {
 		return false
 	}
 
+	// Non-resource URLs are matched only against nonResourceURLs
+	if request.NonResourceURL != "" {
+		return coversNonResourceURL(rule.NonResourceURLs, request.NonResourceURL)
+	}
+
 	// Check API group match
 	if !matchesAPIGroup(rule.APIGroups, request.APIGroup) {
 		return false
@@ -28,13 +36,39 @@ func RuleMatches(rule rbacv1.PolicyRule, request PermissionRequest) bool {
 		}
 	}
 
-	// If rule has resourceNames but request doesn't specify one,
-	// the rule still applies (it grants access to specific resources)
-	// For a "can-i" check without resource name, we assume it could match
+	// If rule has resourceNames but request doesn't specify one, the rule
+	// still matches, but only for those names; see MatchesOnlyNamed
 
 	return true
 }
 
+// RuleMismatch is RuleMatches with a reason: it explains the first part of
+// rule that fails to match request, e.g. `verb "delete" not in ["get"]`, in
+// the order RuleMatches checks them, or returns "" when the rule matches
+func RuleMismatch(rule rbacv1.PolicyRule, request PermissionRequest) string {
+	switch {
+	case !matchesVerb(rule.Verbs, request.Verb):
+		return fmt.Sprintf("verb %q not in %s", request.Verb, boundedList(rule.Verbs))
+	case request.NonResourceURL != "":
+		if !coversNonResourceURL(rule.NonResourceURLs, request.NonResourceURL) {
+			return fmt.Sprintf("nonResourceURL %q not in %s", request.NonResourceURL, boundedList(rule.NonResourceURLs))
+		}
+	case !matchesAPIGroup(rule.APIGroups, request.APIGroup):
+		return fmt.Sprintf("apiGroup %q not in %s", request.APIGroup, boundedList(rule.APIGroups))
+	case !matchesResource(rule.Resources, request.Resource, request.Subresource):
+		return fmt.Sprintf("resource %q not in %s", request.FullResource(), boundedList(rule.Resources))
+	case len(rule.ResourceNames) > 0 && request.ResourceName != "" && !matchesResourceName(rule.ResourceNames, request.ResourceName):
+		return fmt.Sprintf("resourceName %q not in %s", request.ResourceName, boundedList(rule.ResourceNames))
+	}
+	return ""
+}
+
+// MatchesOnlyNamed reports whether a rule that matches request covers it
+// only for the objects in its resourceNames, because the request names none
+func MatchesOnlyNamed(rule rbacv1.PolicyRule, request PermissionRequest) bool {
+	return request.ResourceName == "" && request.NonResourceURL == "" && len(rule.ResourceNames) > 0
+}
+
 // matchesVerb checks if the requested verb matches any of the rule verbs
 func matchesVerb(ruleVerbs []string, requestVerb string) bool {
 	for _, v := range ruleVerbs {
@@ -144,16 +178,40 @@ func GetImplicitGroups(subject Subject) []string {
 	// Start with explicit groups from the subject (e.g., from client certificate)
 	groups := make([]string, 0, len(subject.Groups)+3)
 	groups = append(groups, subject.Groups...)
+	if subject.ExactGroups {
+		return groups
+	}
 
-	// Add implicit groups
-	groups = append(groups, "system:authenticated")
-
+	// Add implicit groups, unless they were also given explicitly
+	implicit := []string{"system:authenticated"}
 	if subject.Kind == "ServiceAccount" {
-		groups = append(groups,
+		implicit = append(implicit,
 			"system:serviceaccounts",
 			"system:serviceaccounts:"+subject.Namespace,
 		)
 	}
+	if IsNode(subject) {
+		implicit = append(implicit, NodesGroup)
+	}
+	for _, group := range implicit {
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
 
 	return groups
 }
+
+// BypassingGroup returns the group through which the subject bypasses
+// authorization entirely, or an empty string if RBAC applies normally
+func BypassingGroup(subject Subject, groups []string) string {
+	if subject.Kind == "Group" && subject.Name == SystemMastersGroup {
+		return SystemMastersGroup
+	}
+	for _, group := range groups {
+		if group == SystemMastersGroup {
+			return SystemMastersGroup
+		}
+	}
+	return ""
+}
@@ -155,6 +155,36 @@ func TestRuleMatches(t *testing.T) {
 			request:  PermissionRequest{Verb: "get", APIGroup: "", Resource: "pods"},
 			expected: true,
 		},
+		{
+			name:     "non-resource URL exact match",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}},
+			request:  PermissionRequest{Verb: "get", NonResourceURL: "/metrics"},
+			expected: true,
+		},
+		{
+			name:     "non-resource URL prefix wildcard",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/api/*"}},
+			request:  PermissionRequest{Verb: "get", NonResourceURL: "/api/v1"},
+			expected: true,
+		},
+		{
+			name:     "non-resource URL full wildcard",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"*"}, NonResourceURLs: []string{"*"}},
+			request:  PermissionRequest{Verb: "get", NonResourceURL: "/healthz"},
+			expected: true,
+		},
+		{
+			name:     "non-resource URL not listed",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz"}},
+			request:  PermissionRequest{Verb: "get", NonResourceURL: "/metrics"},
+			expected: false,
+		},
+		{
+			name:     "resource rule does not cover non-resource URL",
+			rule:     rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+			request:  PermissionRequest{Verb: "get", NonResourceURL: "/metrics"},
+			expected: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -167,6 +197,38 @@ func TestRuleMatches(t *testing.T) {
 	}
 }
 
+func TestRuleMismatch(t *testing.T) {
+	rule := rbacv1.PolicyRule{
+		Verbs:         []string{"get", "list"},
+		APIGroups:     []string{"apps"},
+		Resources:     []string{"deployments", "deployments/scale"},
+		ResourceNames: []string{"api"},
+	}
+	tests := []struct {
+		name    string
+		request PermissionRequest
+		want    string
+	}{
+		{"matches", PermissionRequest{Verb: "get", APIGroup: "apps", Resource: "deployments", ResourceName: "api"}, ""},
+		{"verb", PermissionRequest{Verb: "delete", APIGroup: "apps", Resource: "deployments"}, `verb "delete" not in ["get" "list"]`},
+		{"api group", PermissionRequest{Verb: "get", APIGroup: "", Resource: "deployments"}, `apiGroup "" not in ["apps"]`},
+		{"resource", PermissionRequest{Verb: "get", APIGroup: "apps", Resource: "deployments", Subresource: "status"}, `resource "deployments/status" not in ["deployments" "deployments/scale"]`},
+		{"resource name", PermissionRequest{Verb: "get", APIGroup: "apps", Resource: "deployments", ResourceName: "web"}, `resourceName "web" not in ["api"]`},
+		{"non-resource URL", PermissionRequest{Verb: "get", NonResourceURL: "/healthz"}, `nonResourceURL "/healthz" not in []`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RuleMismatch(rule, tt.request)
+			if got != tt.want {
+				t.Errorf("RuleMismatch() = %q, want %q", got, tt.want)
+			}
+			if (got == "") != RuleMatches(rule, tt.request) {
+				t.Errorf("RuleMismatch() = %q disagrees with RuleMatches()", got)
+			}
+		})
+	}
+}
+
 func TestSubjectMatches(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -370,6 +432,17 @@ func TestGetImplicitGroups(t *testing.T) {
 				"system:authenticated",
 			},
 		},
+		{
+			name: "node",
+			subject: Subject{
+				Kind: "User",
+				Name: "system:node:worker-1",
+			},
+			expectedGroups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,92 @@
+package rbac
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Owner kinds recognized from labels and annotations
+const (
+	OwnerHelmRelease = "HelmRelease"
+	OwnerArgoCDApp   = "ArgoCDApplication"
+	OwnerInstance    = "Instance" // app.kubernetes.io/instance without a known manager
+)
+
+// UnmanagedOwner is the group name for objects without any attribution
+const UnmanagedOwner = "unmanaged"
+
+// Owner identifies the release, application, or object that manages an RBAC object
+type Owner struct {
+	Kind      string // HelmRelease, ArgoCDApplication, Instance, or an ownerReference kind
+	Name      string
+	Namespace string // Empty when unknown or cluster-scoped
+}
+
+// String returns a human-readable owner, e.g. "Helm release prod/api"
+func (o Owner) String() string {
+	name := o.Name
+	if o.Namespace != "" {
+		name = o.Namespace + "/" + name
+	}
+	switch o.Kind {
+	case OwnerHelmRelease:
+		return "Helm release " + name
+	case OwnerArgoCDApp:
+		return "Argo CD application " + name
+	case OwnerInstance:
+		return "instance " + name
+	}
+	return o.Kind + " " + name
+}
+
+// OwnerFromMeta attributes an object to its Helm release, Argo CD application,
+// app.kubernetes.io/instance, or controller owner reference, in that order.
+// It returns nil for unmanaged objects.
+func OwnerFromMeta(meta metav1.ObjectMeta) *Owner {
+	if name := meta.Annotations["meta.helm.sh/release-name"]; name != "" {
+		return &Owner{Kind: OwnerHelmRelease, Name: name, Namespace: meta.Annotations["meta.helm.sh/release-namespace"]}
+	}
+
+	if name := meta.Labels["argocd.argoproj.io/instance"]; name != "" {
+		return &Owner{Kind: OwnerArgoCDApp, Name: name}
+	}
+	// Annotation tracking: "<app>:<group>/<kind>:<namespace>/<name>"
+	if id := meta.Annotations["argocd.argoproj.io/tracking-id"]; id != "" {
+		if app, _, ok := strings.Cut(id, ":"); ok && app != "" {
+			return &Owner{Kind: OwnerArgoCDApp, Name: app}
+		}
+	}
+
+	if name := meta.Labels["app.kubernetes.io/instance"]; name != "" {
+		if meta.Labels["app.kubernetes.io/managed-by"] == "Helm" {
+			return &Owner{Kind: OwnerHelmRelease, Name: name, Namespace: meta.Namespace}
+		}
+		return &Owner{Kind: OwnerInstance, Name: name}
+	}
+
+	var ref *metav1.OwnerReference
+	for i := range meta.OwnerReferences {
+		r := &meta.OwnerReferences[i]
+		if r.Controller != nil && *r.Controller {
+			ref = r
+			break
+		}
+		if ref == nil {
+			ref = r
+		}
+	}
+	if ref != nil {
+		return &Owner{Kind: ref.Kind, Name: ref.Name, Namespace: meta.Namespace}
+	}
+	return nil
+}
+
+// Owner returns who manages the grant: the binding's owner, or the role's
+// when the binding is unmanaged. It returns nil when neither is managed.
+func (g PermissionGrant) Owner() *Owner {
+	if g.Binding.Owner != nil {
+		return g.Binding.Owner
+	}
+	return g.Role.Owner
+}
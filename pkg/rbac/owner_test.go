@@ -0,0 +1,72 @@
+package rbac
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOwnerFromMeta(t *testing.T) {
+	controller := true
+
+	tests := []struct {
+		name string
+		meta metav1.ObjectMeta
+		want string // Owner.String(), or "" for nil
+	}{
+		{
+			name: "helm annotations",
+			meta: metav1.ObjectMeta{Annotations: map[string]string{
+				"meta.helm.sh/release-name":      "api",
+				"meta.helm.sh/release-namespace": "prod",
+			}},
+			want: "Helm release prod/api",
+		},
+		{
+			name: "argo cd label",
+			meta: metav1.ObjectMeta{Labels: map[string]string{"argocd.argoproj.io/instance": "platform"}},
+			want: "Argo CD application platform",
+		},
+		{
+			name: "argo cd tracking annotation",
+			meta: metav1.ObjectMeta{Annotations: map[string]string{
+				"argocd.argoproj.io/tracking-id": "platform:rbac.authorization.k8s.io/RoleBinding:prod/api",
+			}},
+			want: "Argo CD application platform",
+		},
+		{
+			name: "instance label managed by helm",
+			meta: metav1.ObjectMeta{Namespace: "prod", Labels: map[string]string{
+				"app.kubernetes.io/instance":   "api",
+				"app.kubernetes.io/managed-by": "Helm",
+			}},
+			want: "Helm release prod/api",
+		},
+		{
+			name: "controller owner reference",
+			meta: metav1.ObjectMeta{Namespace: "prod", OwnerReferences: []metav1.OwnerReference{
+				{Kind: "ConfigMap", Name: "other"},
+				{Kind: "Operator", Name: "db", Controller: &controller},
+			}},
+			want: "Operator prod/db",
+		},
+		{
+			name: "unmanaged",
+			meta: metav1.ObjectMeta{Name: "read-pods", Labels: map[string]string{"team": "a"}},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := OwnerFromMeta(tt.meta)
+			got := ""
+			if owner != nil {
+				got = owner.String()
+			}
+			if got != tt.want {
+				t.Errorf("OwnerFromMeta() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
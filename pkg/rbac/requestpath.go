@@ -0,0 +1,116 @@
+package rbac
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// namespaceSubresources are subresources of a Namespace object, as opposed to
+// resources within the namespace
+var namespaceSubresources = map[string]bool{"status": true, "finalize": true}
+
+// ParseRequestPath converts an HTTP request line such as
+// "GET /api/v1/namespaces/prod/pods/api-123/log" into the permission the API
+// server would authorize. Full URLs are accepted; the scheme and host are
+// ignored. The verb is derived from the method and whether a name is
+// present, following the API server's request info rules. Paths outside a
+// resource, such as /metrics or discovery, become a non-resource URL checked
+// with the lowercased method as its verb.
+func ParseRequestPath(line string) (PermissionRequest, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return PermissionRequest{}, fmt.Errorf("invalid request %q (expected METHOD PATH, e.g. \"GET /api/v1/namespaces/default/pods\")", line)
+	}
+	method := strings.ToUpper(fields[0])
+
+	u, err := url.Parse(fields[1])
+	if err != nil {
+		return PermissionRequest{}, fmt.Errorf("invalid request path %q: %w", fields[1], err)
+	}
+
+	parts := splitPath(u.Path)
+	if len(parts) < 3 || (parts[0] != "api" && parts[0] != "apis") || (parts[0] == "apis" && len(parts) < 4) {
+		if _, err := verbForMethod(method, false, false); err != nil {
+			return PermissionRequest{}, err
+		}
+		return PermissionRequest{Verb: strings.ToLower(method), NonResourceURL: u.Path}, nil
+	}
+
+	var request PermissionRequest
+	if parts[0] == "api" {
+		parts = parts[2:] // api/v1
+	} else {
+		request.APIGroup = parts[1]
+		parts = parts[3:] // apis/GROUP/VERSION
+	}
+
+	// Deprecated /watch/ prefix
+	watch := u.Query().Get("watch") == "true" || u.Query().Get("watch") == "1"
+	if len(parts) > 0 && parts[0] == "watch" {
+		watch = true
+		parts = parts[1:]
+	}
+
+	if len(parts) > 0 && parts[0] == "namespaces" && len(parts) > 1 {
+		request.Namespace = parts[1]
+		// /namespaces/NAME and its own subresources address the Namespace
+		// itself, which like the API server we authorize within that namespace
+		if len(parts) > 2 && !namespaceSubresources[parts[2]] {
+			parts = parts[2:]
+		}
+	}
+
+	if len(parts) == 0 {
+		return PermissionRequest{}, fmt.Errorf("request path %s names no resource", u.Path)
+	}
+	request.Resource = parts[0]
+	if len(parts) > 1 {
+		request.ResourceName = parts[1]
+	}
+	if len(parts) > 2 {
+		request.Subresource = parts[2]
+	}
+
+	request.Verb, err = verbForMethod(method, request.ResourceName != "", watch)
+	if err != nil {
+		return PermissionRequest{}, err
+	}
+	return request, nil
+}
+
+// verbForMethod maps an HTTP method to the RBAC verb it is authorized as
+func verbForMethod(method string, named, watch bool) (string, error) {
+	switch method {
+	case "GET", "HEAD":
+		switch {
+		case watch:
+			return "watch", nil
+		case named:
+			return "get", nil
+		}
+		return "list", nil
+	case "POST":
+		return "create", nil
+	case "PUT":
+		return "update", nil
+	case "PATCH":
+		return "patch", nil
+	case "DELETE":
+		if named {
+			return "delete", nil
+		}
+		return "deletecollection", nil
+	}
+	return "", fmt.Errorf("unsupported HTTP method %q (valid: GET, HEAD, POST, PUT, PATCH, DELETE)", method)
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	for _, p := range strings.Split(path, "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
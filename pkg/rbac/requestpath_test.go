@@ -0,0 +1,76 @@
+package rbac
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRequestPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    PermissionRequest
+		wantErr string
+	}{
+		{
+			name: "core subresource",
+			line: "GET /api/v1/namespaces/prod/pods/api-123/log",
+			want: PermissionRequest{Verb: "get", Resource: "pods", Subresource: "log", ResourceName: "api-123", Namespace: "prod"},
+		},
+		{
+			name: "group list",
+			line: "GET /apis/apps/v1/namespaces/prod/deployments",
+			want: PermissionRequest{Verb: "list", APIGroup: "apps", Resource: "deployments", Namespace: "prod"},
+		},
+		{
+			name: "cluster-scoped",
+			line: "delete https://10.0.0.1:6443/api/v1/nodes/node-1",
+			want: PermissionRequest{Verb: "delete", Resource: "nodes", ResourceName: "node-1"},
+		},
+		{
+			name: "collection delete",
+			line: "DELETE /apis/batch/v1/namespaces/ci/jobs",
+			want: PermissionRequest{Verb: "deletecollection", APIGroup: "batch", Resource: "jobs", Namespace: "ci"},
+		},
+		{
+			name: "watch query",
+			line: "GET /api/v1/namespaces/prod/configmaps?watch=true&resourceVersion=10",
+			want: PermissionRequest{Verb: "watch", Resource: "configmaps", Namespace: "prod"},
+		},
+		{
+			name: "namespace object",
+			line: "PUT /api/v1/namespaces/prod/finalize",
+			want: PermissionRequest{Verb: "update", Resource: "namespaces", Subresource: "finalize", ResourceName: "prod", Namespace: "prod"},
+		},
+		{
+			name: "create",
+			line: "POST /apis/rbac.authorization.k8s.io/v1/clusterrolebindings",
+			want: PermissionRequest{Verb: "create", APIGroup: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"},
+		},
+		{name: "metrics", line: "GET /metrics", want: PermissionRequest{Verb: "get", NonResourceURL: "/metrics"}},
+		{name: "healthz", line: "get https://10.0.0.1:6443/healthz?verbose", want: PermissionRequest{Verb: "get", NonResourceURL: "/healthz"}},
+		{name: "non-resource post", line: "POST /logs/kube.log", want: PermissionRequest{Verb: "post", NonResourceURL: "/logs/kube.log"}},
+		{name: "group discovery", line: "GET /apis/apps", want: PermissionRequest{Verb: "get", NonResourceURL: "/apis/apps"}},
+		{name: "non-resource unknown method", line: "OPTIONS /metrics", wantErr: "unsupported HTTP method"},
+		{name: "missing method", line: "/api/v1/pods", wantErr: "expected METHOD PATH"},
+		{name: "unknown method", line: "OPTIONS /api/v1/pods", wantErr: "unsupported HTTP method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRequestPath(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseRequestPath() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRequestPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRequestPath() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
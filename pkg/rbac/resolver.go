@@ -3,29 +3,131 @@ package rbac
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	rbacv1 "k8s.io/api/rbac/v1"
 
 	"github.com/hardik/kubectl-rbac-why/pkg/client"
 )
 
+const tracerName = "github.com/hardik/kubectl-rbac-why/pkg/rbac"
+
 // Resolver handles RBAC permission resolution
 type Resolver struct {
 	client client.RBACClient
+	tracer trace.Tracer
+
+	// bindings streams the client's bindings, so those that don't apply to
+	// a subject are dropped a page at a time
+	bindings client.BindingStreamer
+
+	// discovery is the client's discovery, when it serves it, for the scope
+	// of resources
+	discovery client.DiscoveryClient
+
+	// namespaces lists the client's namespaces, when it serves them
+	namespaces client.SubjectClient
+
+	evaluationTrace bool
+	keepDuplicates  bool
+	concurrency     int
+	logger          *slog.Logger
+
+	// index, when set, finds the bindings that apply to a subject
+	index *BindingIndex
+}
+
+// ResolverOption configures a Resolver
+type ResolverOption func(*resolverConfig)
+
+type resolverConfig struct {
+	tracerProvider  trace.TracerProvider
+	evaluationTrace bool
+	keepDuplicates  bool
+	concurrency     int
+	index           *BindingIndex
+	logger          *slog.Logger
+}
+
+// WithTracerProvider records resolution phases as spans using tp instead of
+// the global TracerProvider
+func WithTracerProvider(tp trace.TracerProvider) ResolverOption {
+	return func(c *resolverConfig) {
+		c.tracerProvider = tp
+	}
 }
 
 // NewResolver creates a new RBAC resolver
-func NewResolver(c client.RBACClient) *Resolver {
-	return &Resolver{client: c}
+func NewResolver(c client.RBACClient, opts ...ResolverOption) *Resolver {
+	cfg := resolverConfig{tracerProvider: otel.GetTracerProvider(), concurrency: DefaultConcurrency, logger: slog.New(slog.DiscardHandler)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	dc, _ := c.(client.DiscoveryClient)
+	sc, _ := c.(client.SubjectClient)
+	traced := client.NewTracedRBACClient(c, cfg.tracerProvider)
+	return &Resolver{
+		client:          traced,
+		bindings:        traced,
+		discovery:       dc,
+		namespaces:      sc,
+		tracer:          cfg.tracerProvider.Tracer(tracerName),
+		evaluationTrace: cfg.evaluationTrace,
+		keepDuplicates:  cfg.keepDuplicates,
+		concurrency:     cfg.concurrency,
+		index:           cfg.index,
+		logger:          cfg.logger,
+	}
 }
 
-// ParseSubject parses a --as string into a Subject
+// parseServiceAccountRef parses the NAMESPACE and NAME that follow a
+// ServiceAccount prefix, separated by sep
+func parseServiceAccountRef(asString, ref, sep, form string) (Subject, error) {
+	namespace, name, found := strings.Cut(ref, sep)
+	switch {
+	case !found && ref != "":
+		return Subject{}, fmt.Errorf("invalid subject %s: the namespace is missing (expected %s, or use --sa %s to default it to -n)", asString, form, ref)
+	case namespace == "":
+		return Subject{}, fmt.Errorf("invalid subject %s: the namespace is empty (expected %s)", asString, form)
+	case name == "":
+		return Subject{}, fmt.Errorf("invalid subject %s: the name is empty (expected %s)", asString, form)
+	case strings.Contains(name, sep):
+		return Subject{}, fmt.Errorf("invalid subject %s (expected %s)", asString, form)
+	}
+	return Subject{Kind: "ServiceAccount", Namespace: namespace, Name: name}, nil
+}
+
+// ParseSubject parses a --as string into a Subject. A user:, group:, sa:, or
+// sa:// prefix sets the kind; otherwise it is guessed from the name's shape.
 func ParseSubject(asString string) (Subject, error) {
 	if asString == "" {
 		return Subject{}, fmt.Errorf("subject cannot be empty")
 	}
 
+	if name, ok := strings.CutPrefix(asString, UserPrefix); ok {
+		if name == "" {
+			return Subject{}, fmt.Errorf("invalid subject %s (expected user:NAME)", asString)
+		}
+		return Subject{Kind: "User", Name: name}, nil
+	}
+	if name, ok := strings.CutPrefix(asString, GroupPrefix); ok {
+		if name == "" {
+			return Subject{}, fmt.Errorf("invalid subject %s (expected group:NAME)", asString)
+		}
+		return Subject{Kind: "Group", Name: name}, nil
+	}
+	if ref, ok := strings.CutPrefix(asString, ServiceAccountURLPrefix); ok {
+		return parseServiceAccountRef(asString, ref, "/", "sa://NAMESPACE/NAME")
+	}
+	if ref, ok := strings.CutPrefix(asString, ServiceAccountPrefix); ok {
+		return parseServiceAccountRef(asString, ref, ":", "sa:NAMESPACE:NAME")
+	}
+
 	// Format: "system:serviceaccount:namespace:name"
 	if strings.HasPrefix(asString, "system:serviceaccount:") {
 		parts := strings.Split(asString, ":")
@@ -39,6 +141,19 @@ func ParseSubject(asString string) (Subject, error) {
 		}, nil
 	}
 
+	// Format: "system:node:name", a kubelet. Unlike other "system:" names
+	// it's a User, in the system:nodes group.
+	if strings.HasPrefix(asString, NodeUserPrefix) {
+		name := strings.TrimPrefix(asString, NodeUserPrefix)
+		if name == "" || strings.Contains(name, ":") {
+			return Subject{}, fmt.Errorf("invalid node format: %s (expected system:node:name)", asString)
+		}
+		return Subject{
+			Kind: "User",
+			Name: asString,
+		}, nil
+	}
+
 	// Groups typically start with "system:" but aren't serviceaccounts
 	if strings.HasPrefix(asString, "system:") {
 		return Subject{
@@ -62,119 +177,255 @@ func (r *Resolver) ResolvePermission(ctx context.Context, subject Subject, reque
 		Grants:  []PermissionGrant{},
 	}
 
+	ctx, span := r.tracer.Start(ctx, "rbac.ResolvePermission", trace.WithAttributes(
+		attribute.String("rbac.subject.kind", subject.Kind),
+		attribute.String("rbac.namespace", request.Namespace),
+		attribute.String("rbac.verb", request.Verb),
+		attribute.String("rbac.resource", request.FullResource()),
+	))
+	defer func() {
+		span.SetAttributes(
+			attribute.Bool("rbac.allowed", result.Allowed),
+			attribute.Int("rbac.grants", len(result.Grants)),
+		)
+		span.End()
+	}()
+
 	// Get implicit groups for the subject
 	groups := GetImplicitGroups(subject)
+	r.logger.DebugContext(ctx, "resolving", "subject", subject.String(), "groups", groups, "verb", request.Verb, "resource", request.FullResource(), "namespace", request.Namespace)
 
-	// Find all ClusterRoleBindings that reference this subject
-	crbs, err := r.client.ListClusterRoleBindings(ctx)
+	if IsNode(subject) {
+		result.Warnings = append(result.Warnings, NodeAuthorizerWarning(subject))
+	}
+
+	// Members of system:masters are never evaluated against RBAC
+	if group := BypassingGroup(subject, groups); group != "" {
+		r.logger.DebugContext(ctx, "RBAC bypassed", "group", group)
+		result.BypassedVia = group
+		result.Allowed = true
+		return result, nil
+	}
+
+	aggregation := r.newAggregationLookup()
+
+	grants, dangling, diagnostics, err := r.clusterGrants(ctx, subject, groups, request, aggregation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+		return nil, err
+	}
+	result.Grants = append(result.Grants, grants...)
+	result.addDangling(dangling)
+	result.Diagnostics = append(result.Diagnostics, diagnostics...)
+
+	// If namespace is specified, also check RoleBindings in that namespace.
+	// Non-resource URLs are only granted cluster-wide, and so are
+	// cluster-scoped resources: what RoleBindings grant for those is kept
+	// apart to explain a denial.
+	clusterScoped := r.clusterScoped(ctx, request)
+	var scopeMisses []PermissionGrant
+	if request.Namespace != "" && request.NonResourceURL == "" {
+		grants, dangling, diagnostics, err := r.namespaceGrants(ctx, subject, groups, request, aggregation)
+		if err != nil {
+			return nil, err
+		}
+		if clusterScoped {
+			scopeMisses = grants
+			result.Warnings = append(result.Warnings, clusterScopedWarning(request))
+		} else {
+			result.Grants = append(result.Grants, grants...)
+		}
+		result.addDangling(dangling)
+		result.Diagnostics = append(result.Diagnostics, diagnostics...)
+	}
+
+	if !r.keepDuplicates {
+		result.Grants = DedupeGrants(result.Grants)
+		scopeMisses = DedupeGrants(scopeMisses)
 	}
 
-	for _, crb := range crbs.Items {
-		if !r.bindingMatchesSubject(crb.Subjects, subject, groups) {
+	// Grants limited to resourceNames don't allow a request for every object
+	result.Allowed = slices.ContainsFunc(result.Grants, func(g PermissionGrant) bool { return !g.Conditional })
+	result.PartiallyAllowed = !result.Allowed && len(result.Grants) > 0
+	if len(result.Grants) == 0 {
+		result.DenialReasons = r.explainDenial(ctx, subject, groups, request, result, clusterScoped, scopeMisses)
+	}
+	if r.evaluationTrace {
+		if result.Trace, err = r.traceBindings(ctx, subject, groups, request); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// clusterGrants finds the grants from ClusterRoleBindings. Bindings to
+// missing roles are returned as dangling, and roles that can't be read as
+// diagnostics, alongside the grants; only a failure to list the bindings
+// fails the lookup.
+func (r *Resolver) clusterGrants(ctx context.Context, subject Subject, groups []string, request PermissionRequest, aggregation *aggregationLookup) ([]PermissionGrant, []DanglingBinding, []Diagnostic, error) {
+	var grants []PermissionGrant
+	var dangling []DanglingBinding
+	var diagnostics []Diagnostic
+
+	crbs, err := r.clusterRoleBindingsFor(ctx, subject, groups)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+
+	for _, crb := range crbs {
+		matched := bindingMatchesSubject(crb.Subjects, subject, groups)
+		r.logBinding(ctx, "ClusterRoleBinding", "", crb.Name, crb.Subjects, matched)
+		if matched < 0 {
 			continue
 		}
 
+		binding := BindingInfo{
+			Kind:  "ClusterRoleBinding",
+			Name:  crb.Name,
+			Owner: OwnerFromMeta(crb.ObjectMeta),
+		}
+
 		// Get the referenced ClusterRole
 		clusterRole, err := r.client.GetClusterRole(ctx, crb.RoleRef.Name)
+		if d := danglingBinding(binding, crb.RoleRef, err); d != nil {
+			dangling = append(dangling, *d)
+			continue
+		}
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("failed to get cluster role %s: %w", crb.RoleRef.Name, err))
+			diagnostics = append(diagnostics, roleDiagnostic("ClusterRole", "", crb.RoleRef.Name, err))
 			continue
 		}
 
 		// Check each rule in the role
-		for _, rule := range clusterRole.Rules {
+		for i, rule := range clusterRole.Rules {
+			r.logRule(ctx, binding, crb.RoleRef, i, rule, request)
 			if RuleMatches(rule, request) {
 				grant := PermissionGrant{
-					Binding: BindingInfo{
-						Kind: "ClusterRoleBinding",
-						Name: crb.Name,
-					},
+					Binding: binding,
 					Role: RoleInfo{
-						Kind: "ClusterRole",
-						Name: clusterRole.Name,
+						Kind:   "ClusterRole",
+						Name:   clusterRole.Name,
+						Owner:  OwnerFromMeta(clusterRole.ObjectMeta),
+						Source: SourceFromMeta(clusterRole.ObjectMeta),
 					},
-					MatchingRule: rule,
-					Scope:        ScopeClusterWide,
+					MatchedSubject: &crb.Subjects[matched],
+					SubjectIndex:   matched,
+					MatchingRule:   rule,
+					RuleIndex:      i,
+					Conditional:    MatchesOnlyNamed(rule, request),
+					Scope:          ScopeClusterWide,
+					AggregatedFrom: aggregation.source(ctx, clusterRole, rule),
+					Wildcards:      WildcardMatches(rule, request),
 				}
-				result.Grants = append(result.Grants, grant)
+				grants = append(grants, grant)
 			}
 		}
 	}
+	return grants, dangling, diagnostics, nil
+}
 
-	// If namespace is specified, also check RoleBindings in that namespace
-	if request.Namespace != "" {
-		rbs, err := r.client.ListRoleBindings(ctx, request.Namespace)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list role bindings in namespace %s: %w", request.Namespace, err)
+// namespaceGrants finds the grants from RoleBindings in request.Namespace,
+// returning dangling bindings and diagnostics like clusterGrants
+func (r *Resolver) namespaceGrants(ctx context.Context, subject Subject, groups []string, request PermissionRequest, aggregation *aggregationLookup) ([]PermissionGrant, []DanglingBinding, []Diagnostic, error) {
+	var grants []PermissionGrant
+	var dangling []DanglingBinding
+	var diagnostics []Diagnostic
+
+	rbs, err := r.roleBindingsFor(ctx, subject, groups, request.Namespace)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list role bindings in namespace %s: %w", request.Namespace, err)
+	}
+
+	for _, rb := range rbs {
+		matched := bindingMatchesSubject(rb.Subjects, subject, groups)
+		r.logBinding(ctx, "RoleBinding", rb.Namespace, rb.Name, rb.Subjects, matched)
+		if matched < 0 {
+			continue
 		}
 
-		for _, rb := range rbs.Items {
-			if !r.bindingMatchesSubject(rb.Subjects, subject, groups) {
+		binding := BindingInfo{
+			Kind:      "RoleBinding",
+			Name:      rb.Name,
+			Namespace: rb.Namespace,
+			Owner:     OwnerFromMeta(rb.ObjectMeta),
+		}
+		var rules []rbacv1.PolicyRule
+		var roleInfo RoleInfo
+		var clusterRole *rbacv1.ClusterRole
+
+		// RoleBinding can reference either a Role or ClusterRole
+		if rb.RoleRef.Kind == "ClusterRole" {
+			clusterRole, err = r.client.GetClusterRole(ctx, rb.RoleRef.Name)
+			if d := danglingBinding(binding, rb.RoleRef, err); d != nil {
+				dangling = append(dangling, *d)
 				continue
 			}
-
-			var rules []rbacv1.PolicyRule
-			var roleInfo RoleInfo
-
-			// RoleBinding can reference either a Role or ClusterRole
-			if rb.RoleRef.Kind == "ClusterRole" {
-				clusterRole, err := r.client.GetClusterRole(ctx, rb.RoleRef.Name)
-				if err != nil {
-					result.Errors = append(result.Errors, fmt.Errorf("failed to get cluster role %s: %w", rb.RoleRef.Name, err))
-					continue
-				}
-				rules = clusterRole.Rules
-				roleInfo = RoleInfo{
-					Kind: "ClusterRole",
-					Name: clusterRole.Name,
-				}
-			} else {
-				role, err := r.client.GetRole(ctx, request.Namespace, rb.RoleRef.Name)
-				if err != nil {
-					result.Errors = append(result.Errors, fmt.Errorf("failed to get role %s in namespace %s: %w", rb.RoleRef.Name, request.Namespace, err))
-					continue
-				}
-				rules = role.Rules
-				roleInfo = RoleInfo{
-					Kind:      "Role",
-					Name:      role.Name,
-					Namespace: role.Namespace,
-				}
+			if err != nil {
+				diagnostics = append(diagnostics, roleDiagnostic("ClusterRole", "", rb.RoleRef.Name, err))
+				continue
+			}
+			rules = clusterRole.Rules
+			roleInfo = RoleInfo{
+				Kind:   "ClusterRole",
+				Name:   clusterRole.Name,
+				Owner:  OwnerFromMeta(clusterRole.ObjectMeta),
+				Source: SourceFromMeta(clusterRole.ObjectMeta),
+			}
+		} else {
+			role, err := r.client.GetRole(ctx, request.Namespace, rb.RoleRef.Name)
+			if d := danglingBinding(binding, rb.RoleRef, err); d != nil {
+				dangling = append(dangling, *d)
+				continue
+			}
+			if err != nil {
+				diagnostics = append(diagnostics, roleDiagnostic("Role", request.Namespace, rb.RoleRef.Name, err))
+				continue
 			}
+			rules = role.Rules
+			roleInfo = RoleInfo{
+				Kind:      "Role",
+				Name:      role.Name,
+				Namespace: role.Namespace,
+				Owner:     OwnerFromMeta(role.ObjectMeta),
+				Source:    SourceFromMeta(role.ObjectMeta),
+			}
+		}
 
-			// Check each rule
-			for _, rule := range rules {
-				if RuleMatches(rule, request) {
-					grant := PermissionGrant{
-						Binding: BindingInfo{
-							Kind:      "RoleBinding",
-							Name:      rb.Name,
-							Namespace: rb.Namespace,
-						},
-						Role:         roleInfo,
-						MatchingRule: rule,
-						Scope:        ScopeNamespace,
-					}
-					result.Grants = append(result.Grants, grant)
+		// Check each rule
+		for i, rule := range rules {
+			r.logRule(ctx, binding, rb.RoleRef, i, rule, request)
+			if RuleMatches(rule, request) {
+				grant := PermissionGrant{
+					Binding:            binding,
+					Role:               roleInfo,
+					MatchedSubject:     &rb.Subjects[matched],
+					SubjectIndex:       matched,
+					MatchingRule:       rule,
+					RuleIndex:          i,
+					Conditional:        MatchesOnlyNamed(rule, request),
+					Scope:              ScopeNamespace,
+					EffectiveNamespace: rb.Namespace,
+					Wildcards:          WildcardMatches(rule, request),
 				}
+				if clusterRole != nil {
+					grant.AggregatedFrom = aggregation.source(ctx, clusterRole, rule)
+				}
+				grants = append(grants, grant)
 			}
 		}
 	}
-
-	result.Allowed = len(result.Grants) > 0
-	return result, nil
+	return grants, dangling, diagnostics, nil
 }
 
-// bindingMatchesSubject checks if any subject in the binding matches the request subject
-func (r *Resolver) bindingMatchesSubject(subjects []rbacv1.Subject, subject Subject, groups []string) bool {
-	for _, s := range subjects {
+// bindingMatchesSubject returns the index of the first subject in the
+// binding that matches the request subject, directly or through one of
+// groups, or -1 if none does
+func bindingMatchesSubject(subjects []rbacv1.Subject, subject Subject, groups []string) int {
+	for i, s := range subjects {
 		if SubjectMatchesWithGroups(s, subject, groups) {
-			return true
+			return i
 		}
 	}
-	return false
+	return -1
 }
 
 // ResolveAllPermissions gets all permissions for a subject (for risky permission analysis)
@@ -182,14 +433,26 @@ func (r *Resolver) ResolveAllPermissions(ctx context.Context, subject Subject, n
 	var grants []PermissionGrant
 	groups := GetImplicitGroups(subject)
 
+	ctx, span := r.tracer.Start(ctx, "rbac.ResolveAllPermissions", trace.WithAttributes(
+		attribute.String("rbac.subject.kind", subject.Kind),
+		attribute.String("rbac.namespace", namespace),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Int("rbac.grants", len(grants)))
+		span.End()
+	}()
+
+	aggregation := r.newAggregationLookup()
+
 	// Get all ClusterRoleBindings
-	crbs, err := r.client.ListClusterRoleBindings(ctx)
+	crbs, err := r.clusterRoleBindingsFor(ctx, subject, groups)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
 	}
 
-	for _, crb := range crbs.Items {
-		if !r.bindingMatchesSubject(crb.Subjects, subject, groups) {
+	for _, crb := range crbs {
+		matched := bindingMatchesSubject(crb.Subjects, subject, groups)
+		if matched < 0 {
 			continue
 		}
 
@@ -198,18 +461,25 @@ func (r *Resolver) ResolveAllPermissions(ctx context.Context, subject Subject, n
 			continue
 		}
 
-		for _, rule := range clusterRole.Rules {
+		for i, rule := range clusterRole.Rules {
 			grant := PermissionGrant{
 				Binding: BindingInfo{
-					Kind: "ClusterRoleBinding",
-					Name: crb.Name,
+					Kind:  "ClusterRoleBinding",
+					Name:  crb.Name,
+					Owner: OwnerFromMeta(crb.ObjectMeta),
 				},
 				Role: RoleInfo{
-					Kind: "ClusterRole",
-					Name: clusterRole.Name,
+					Kind:   "ClusterRole",
+					Name:   clusterRole.Name,
+					Owner:  OwnerFromMeta(clusterRole.ObjectMeta),
+					Source: SourceFromMeta(clusterRole.ObjectMeta),
 				},
-				MatchingRule: rule,
-				Scope:        ScopeClusterWide,
+				MatchedSubject: &crb.Subjects[matched],
+				SubjectIndex:   matched,
+				MatchingRule:   rule,
+				RuleIndex:      i,
+				Scope:          ScopeClusterWide,
+				AggregatedFrom: aggregation.source(ctx, clusterRole, rule),
 			}
 			grants = append(grants, grant)
 		}
@@ -217,45 +487,58 @@ func (r *Resolver) ResolveAllPermissions(ctx context.Context, subject Subject, n
 
 	// Get RoleBindings if namespace specified
 	if namespace != "" {
-		rbs, err := r.client.ListRoleBindings(ctx, namespace)
+		rbs, err := r.roleBindingsFor(ctx, subject, groups, namespace)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list role bindings: %w", err)
 		}
 
-		for _, rb := range rbs.Items {
-			if !r.bindingMatchesSubject(rb.Subjects, subject, groups) {
+		for _, rb := range rbs {
+			matched := bindingMatchesSubject(rb.Subjects, subject, groups)
+			if matched < 0 {
 				continue
 			}
 
 			var rules []rbacv1.PolicyRule
 			var roleInfo RoleInfo
+			var clusterRole *rbacv1.ClusterRole
 
 			if rb.RoleRef.Kind == "ClusterRole" {
-				clusterRole, err := r.client.GetClusterRole(ctx, rb.RoleRef.Name)
+				clusterRole, err = r.client.GetClusterRole(ctx, rb.RoleRef.Name)
 				if err != nil {
 					continue
 				}
 				rules = clusterRole.Rules
-				roleInfo = RoleInfo{Kind: "ClusterRole", Name: clusterRole.Name}
+				roleInfo = RoleInfo{Kind: "ClusterRole", Name: clusterRole.Name, Owner: OwnerFromMeta(clusterRole.ObjectMeta), Source: SourceFromMeta(clusterRole.ObjectMeta)}
 			} else {
 				role, err := r.client.GetRole(ctx, namespace, rb.RoleRef.Name)
 				if err != nil {
 					continue
 				}
 				rules = role.Rules
-				roleInfo = RoleInfo{Kind: "Role", Name: role.Name, Namespace: role.Namespace}
+				roleInfo = RoleInfo{Kind: "Role", Name: role.Name, Namespace: role.Namespace, Owner: OwnerFromMeta(role.ObjectMeta), Source: SourceFromMeta(role.ObjectMeta)}
 			}
 
-			for _, rule := range rules {
+			for i, rule := range rules {
+				if r.clusterOnlyRule(ctx, rule) {
+					continue
+				}
 				grant := PermissionGrant{
 					Binding: BindingInfo{
 						Kind:      "RoleBinding",
 						Name:      rb.Name,
 						Namespace: rb.Namespace,
+						Owner:     OwnerFromMeta(rb.ObjectMeta),
 					},
-					Role:         roleInfo,
-					MatchingRule: rule,
-					Scope:        ScopeNamespace,
+					Role:               roleInfo,
+					MatchedSubject:     &rb.Subjects[matched],
+					SubjectIndex:       matched,
+					MatchingRule:       rule,
+					RuleIndex:          i,
+					Scope:              ScopeNamespace,
+					EffectiveNamespace: rb.Namespace,
+				}
+				if clusterRole != nil {
+					grant.AggregatedFrom = aggregation.source(ctx, clusterRole, rule)
 				}
 				grants = append(grants, grant)
 			}
@@ -2,14 +2,32 @@ package rbac
 
 import (
 	"context"
+	"slices"
 	"testing"
 
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/hardik/kubectl-rbac-why/pkg/client"
 )
 
+func TestSubject_AsString(t *testing.T) {
+	for _, subject := range []Subject{
+		{Kind: "User", Name: "jane"},
+		{Kind: "User", Name: "system:kube-scheduler"},
+		{Kind: "Group", Name: "dev"},
+		{Kind: "Group", Name: "system:masters"},
+		{Kind: "ServiceAccount", Namespace: "prod", Name: "api"},
+	} {
+		parsed, err := ParseSubject(subject.AsString())
+		if err != nil || parsed.Kind != subject.Kind || parsed.Name != subject.Name || parsed.Namespace != subject.Namespace {
+			t.Errorf("ParseSubject(%q) = %+v, %v; want %+v", subject.AsString(), parsed, err, subject)
+		}
+	}
+}
+
 func TestParseSubject(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -43,6 +61,22 @@ func TestParseSubject(t *testing.T) {
 				Name: "system:masters",
 			},
 		},
+		{
+			name:  "node",
+			input: "system:node:worker-1",
+			expected: Subject{
+				Kind: "User",
+				Name: "system:node:worker-1",
+			},
+		},
+		{
+			name:  "nodes group",
+			input: "system:nodes",
+			expected: Subject{
+				Kind: "Group",
+				Name: "system:nodes",
+			},
+		},
 		{
 			name:  "user",
 			input: "jane",
@@ -59,6 +93,66 @@ func TestParseSubject(t *testing.T) {
 				Name: "jane@example.com",
 			},
 		},
+		{
+			name:     "explicit user",
+			input:    "user:jane",
+			expected: Subject{Kind: "User", Name: "jane"},
+		},
+		{
+			name:     "explicit user with a system: name",
+			input:    "user:system:kube-scheduler",
+			expected: Subject{Kind: "User", Name: "system:kube-scheduler"},
+		},
+		{
+			name:     "explicit group",
+			input:    "group:platform-team",
+			expected: Subject{Kind: "Group", Name: "platform-team"},
+		},
+		{
+			name:     "explicit group with a colon",
+			input:    "group:oidc:platform-team",
+			expected: Subject{Kind: "Group", Name: "oidc:platform-team"},
+		},
+		{
+			name:     "explicit service account",
+			input:    "sa:prod:backend",
+			expected: Subject{Kind: "ServiceAccount", Namespace: "prod", Name: "backend"},
+		},
+		{
+			name:     "service account URL form",
+			input:    "sa://prod/backend",
+			expected: Subject{Kind: "ServiceAccount", Namespace: "prod", Name: "backend"},
+		},
+		{
+			name:        "service account URL form without a namespace",
+			input:       "sa://backend",
+			expectError: true,
+		},
+		{
+			name:        "service account URL form with an empty name",
+			input:       "sa://prod/",
+			expectError: true,
+		},
+		{
+			name:        "explicit user without a name",
+			input:       "user:",
+			expectError: true,
+		},
+		{
+			name:        "explicit group without a name",
+			input:       "group:",
+			expectError: true,
+		},
+		{
+			name:        "explicit service account without a namespace",
+			input:       "sa:backend",
+			expectError: true,
+		},
+		{
+			name:        "explicit service account with too many parts",
+			input:       "sa:prod:backend:extra",
+			expectError: true,
+		},
 		{
 			name:        "empty string",
 			input:       "",
@@ -69,6 +163,11 @@ func TestParseSubject(t *testing.T) {
 			input:       "system:serviceaccount:default",
 			expectError: true,
 		},
+		{
+			name:        "node without a name",
+			input:       "system:node:",
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -167,6 +266,9 @@ func TestResolvePermission_RoleBinding(t *testing.T) {
 	if grant.Role.Name != "pod-reader" {
 		t.Errorf("Grant.Role.Name = %s, expected pod-reader", grant.Role.Name)
 	}
+	if grant.ViaGroup(result.Subject) {
+		t.Errorf("Grant matched via %s, expected the subject itself", grant.MatchedVia())
+	}
 }
 
 func TestResolvePermission_ClusterRoleBinding(t *testing.T) {
@@ -287,6 +389,91 @@ func TestResolvePermission_GroupBinding(t *testing.T) {
 	if len(result.Grants) != 1 {
 		t.Fatalf("ResolvePermission() returned %d grants, expected 1", len(result.Grants))
 	}
+
+	grant := result.Grants[0]
+	if grant.MatchedVia() != "Group system:serviceaccounts" || !grant.ViaGroup(result.Subject) {
+		t.Errorf("MatchedVia() = %q, ViaGroup = %v; want a match through Group system:serviceaccounts", grant.MatchedVia(), grant.ViaGroup(result.Subject))
+	}
+}
+
+func TestResolvePermission_Indexes(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "mixed"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}},
+			{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"secrets"}},
+		},
+	})
+	mockClient.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "mixed"},
+		Subjects: []rbacv1.Subject{
+			{Kind: "User", Name: "bob"},
+			{Kind: "User", Name: "alice"},
+		},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "mixed"},
+	})
+
+	result, err := NewResolver(mockClient).ResolvePermission(context.Background(),
+		Subject{Kind: "User", Name: "alice"},
+		PermissionRequest{Verb: "list", Resource: "secrets"})
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+	if len(result.Grants) != 1 {
+		t.Fatalf("ResolvePermission() returned %d grants, expected 1", len(result.Grants))
+	}
+	if g := result.Grants[0]; g.RuleIndex != 1 || g.SubjectIndex != 1 {
+		t.Errorf("RuleIndex = %d, SubjectIndex = %d; expected 1 and 1", g.RuleIndex, g.SubjectIndex)
+	}
+}
+
+func TestResolvePermission_ResourceNames(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "one-secret", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"tls", "db"}},
+		},
+	})
+	mockClient.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "one-secret", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "one-secret"},
+	})
+	resolver := NewResolver(mockClient)
+	alice := Subject{Kind: "User", Name: "alice"}
+
+	tests := []struct {
+		name             string
+		resourceName     string
+		wantAllowed      bool
+		wantPartially    bool
+		wantConditional  bool
+		wantAllowedNames []string
+	}{
+		{name: "no name", wantPartially: true, wantConditional: true, wantAllowedNames: []string{"db", "tls"}},
+		{name: "listed name", resourceName: "tls", wantAllowed: true},
+		{name: "other name", resourceName: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolver.ResolvePermission(context.Background(), alice,
+				PermissionRequest{Verb: "get", Resource: "secrets", ResourceName: tt.resourceName, Namespace: "default"})
+			if err != nil {
+				t.Fatalf("ResolvePermission() error: %v", err)
+			}
+			if result.Allowed != tt.wantAllowed || result.PartiallyAllowed != tt.wantPartially {
+				t.Errorf("Allowed = %v, PartiallyAllowed = %v; expected %v and %v", result.Allowed, result.PartiallyAllowed, tt.wantAllowed, tt.wantPartially)
+			}
+			if len(result.Grants) > 0 && result.Grants[0].Conditional != tt.wantConditional {
+				t.Errorf("Conditional = %v, expected %v", result.Grants[0].Conditional, tt.wantConditional)
+			}
+			if got := result.AllowedNames(); !slices.Equal(got, tt.wantAllowedNames) {
+				t.Errorf("AllowedNames() = %v, expected %v", got, tt.wantAllowedNames)
+			}
+		})
+	}
 }
 
 func TestResolvePermission_Denied(t *testing.T) {
@@ -347,6 +534,132 @@ func TestResolvePermission_Denied(t *testing.T) {
 	}
 }
 
+func TestResolvePermission_ClusterScoped(t *testing.T) {
+	subject := Subject{Kind: "ServiceAccount", Name: "app", Namespace: "default"}
+	setup := func(resource string, clusterBinding bool) *client.MockRBACClient {
+		mock := client.NewMockRBACClient()
+		mock.AddClusterRole(rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{Name: "reader"},
+			Rules:      []rbacv1.PolicyRule{{Verbs: []string{"list"}, APIGroups: []string{"", "example.com"}, Resources: []string{resource}}},
+		})
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-reader", Namespace: "default"},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "app", Namespace: "default"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+		})
+		if clusterBinding {
+			mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-reader"},
+				Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "app", Namespace: "default"}},
+				RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+			})
+		}
+		mock.Resources = []*metav1.APIResourceList{
+			{GroupVersion: "example.com/v1", APIResources: []metav1.APIResource{{Name: "widgets"}, {Name: "gadgets", Namespaced: true}}},
+		}
+		return mock
+	}
+
+	tests := []struct {
+		name           string
+		request        PermissionRequest
+		clusterBinding bool
+		wantAllowed    bool
+		wantCodes      []string
+	}{
+		{
+			name:      "built-in cluster-scoped resource",
+			request:   PermissionRequest{Verb: "list", Resource: "nodes", Namespace: "default"},
+			wantCodes: []string{DenialClusterScoped},
+		},
+		{
+			name:      "cluster-scoped per discovery",
+			request:   PermissionRequest{Verb: "list", APIGroup: "example.com", Resource: "widgets", Namespace: "default"},
+			wantCodes: []string{DenialClusterScoped},
+		},
+		{
+			name:        "namespaced per discovery",
+			request:     PermissionRequest{Verb: "list", APIGroup: "example.com", Resource: "gadgets", Namespace: "default"},
+			wantAllowed: true,
+		},
+		{
+			name:           "ClusterRoleBinding grants it",
+			request:        PermissionRequest{Verb: "list", Resource: "nodes", Namespace: "default"},
+			clusterBinding: true,
+			wantAllowed:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := setup(tt.request.Resource, tt.clusterBinding)
+			result, err := NewResolver(mock).ResolvePermission(context.Background(), subject, tt.request)
+			if err != nil {
+				t.Fatalf("ResolvePermission() error = %v", err)
+			}
+			if result.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", result.Allowed, tt.wantAllowed)
+			}
+			for _, g := range result.Grants {
+				if g.Binding.Kind == "RoleBinding" && tt.request.Resource != "gadgets" {
+					t.Errorf("grant through %s/%s for a cluster-scoped resource", g.Binding.Namespace, g.Binding.Name)
+				}
+			}
+			var codes []string
+			for _, r := range result.DenialReasons {
+				codes = append(codes, r.Code)
+			}
+			if !slices.Equal(codes, tt.wantCodes) {
+				t.Errorf("denial codes = %v, want %v", codes, tt.wantCodes)
+			}
+			scoped := slices.ContainsFunc(result.Warnings, func(w Warning) bool { return w.Code == WarningClusterScoped })
+			if wantScoped := tt.request.Resource != "gadgets"; scoped != wantScoped {
+				t.Errorf("ClusterScoped warning = %v, want %v", scoped, wantScoped)
+			}
+		})
+	}
+}
+
+func TestResolvePermission_NonResourceURL(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "metrics-reader"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}}},
+	})
+	mockClient.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "prometheus-metrics"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "prometheus", Namespace: "monitoring"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "metrics-reader"},
+	})
+	// A RoleBinding to the same ClusterRole has no effect on non-resource URLs
+	mockClient.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-metrics", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "app", Namespace: "default"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "metrics-reader"},
+	})
+
+	resolver := NewResolver(mockClient)
+	request := PermissionRequest{Verb: "get", NonResourceURL: "/metrics", Namespace: "default"}
+
+	result, err := resolver.ResolvePermission(context.Background(),
+		Subject{Kind: "ServiceAccount", Name: "prometheus", Namespace: "monitoring"}, request)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+	if !result.Allowed || len(result.Grants) != 1 || result.Grants[0].Binding.Name != "prometheus-metrics" {
+		t.Errorf("prometheus: Allowed = %v, grants = %+v, want one grant via prometheus-metrics", result.Allowed, result.Grants)
+	}
+
+	result, err = resolver.ResolvePermission(context.Background(),
+		Subject{Kind: "ServiceAccount", Name: "app", Namespace: "default"}, request)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+	if result.Allowed {
+		t.Errorf("app: Allowed = true, want false; RoleBindings cannot grant non-resource URLs")
+	}
+}
+
 func TestResolvePermission_MultipleGrants(t *testing.T) {
 	mockClient := client.NewMockRBACClient()
 
@@ -504,3 +817,131 @@ func TestResolvePermission_RoleBindingToClusterRole(t *testing.T) {
 		t.Errorf("Grant.Scope = %s, expected namespace", grant.Scope)
 	}
 }
+
+func TestResolvePermission_SystemMastersBypass(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject Subject
+	}{
+		{
+			name:    "user with system:masters group",
+			subject: Subject{Kind: "User", Name: "kubernetes-admin", Groups: []string{"system:masters"}},
+		},
+		{
+			name:    "system:masters group itself",
+			subject: Subject{Kind: "Group", Name: "system:masters"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No bindings at all: the result must still be ALLOWED
+			resolver := NewResolver(client.NewMockRBACClient())
+
+			result, err := resolver.ResolvePermission(
+				context.Background(),
+				tt.subject,
+				PermissionRequest{Verb: "delete", APIGroup: "", Resource: "nodes"},
+			)
+			if err != nil {
+				t.Fatalf("ResolvePermission() error: %v", err)
+			}
+			if !result.Allowed {
+				t.Errorf("ResolvePermission().Allowed = false, expected true")
+			}
+			if result.BypassedVia != SystemMastersGroup {
+				t.Errorf("ResolvePermission().BypassedVia = %q, expected %q", result.BypassedVia, SystemMastersGroup)
+			}
+			if len(result.Grants) != 0 {
+				t.Errorf("ResolvePermission() returned %d grants, expected 0", len(result.Grants))
+			}
+		})
+	}
+}
+
+func TestResolvePermission_OnlyViaSuperuser(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+
+	mockClient.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: ClusterAdminRole},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+		},
+	})
+	mockClient.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "jane-admin"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "jane"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: ClusterAdminRole},
+	})
+
+	resolver := NewResolver(mockClient)
+
+	result, err := resolver.ResolvePermission(
+		context.Background(),
+		Subject{Kind: "User", Name: "jane"},
+		PermissionRequest{Verb: "get", APIGroup: "", Resource: "secrets", Namespace: "default"},
+	)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+	if result.BypassedVia != "" {
+		t.Errorf("ResolvePermission().BypassedVia = %q, expected empty", result.BypassedVia)
+	}
+	if !result.OnlyViaSuperuser() {
+		t.Errorf("OnlyViaSuperuser() = false, expected true")
+	}
+	if len(result.SuperuserGrants()) != 1 {
+		t.Errorf("SuperuserGrants() returned %d grants, expected 1", len(result.SuperuserGrants()))
+	}
+}
+
+func TestResolvePermission_Tracing(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddRole(rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-reader", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		},
+	})
+	mockClient.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "read-pods", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	resolver := NewResolver(mockClient, WithTracerProvider(tp))
+
+	_, err := resolver.ResolvePermission(
+		context.Background(),
+		Subject{Kind: "User", Name: "alice"},
+		PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"},
+	)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	for _, name := range []string{"rbac.ResolvePermission", "rbac.ListClusterRoleBindings", "rbac.ListRoleBindings", "rbac.GetRole"} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("missing span %s", name)
+		}
+	}
+
+	root := spans["rbac.ResolvePermission"]
+	if root == nil {
+		return
+	}
+	for _, attr := range root.Attributes() {
+		if attr.Key == "rbac.grants" && attr.Value.AsInt64() != 1 {
+			t.Errorf("rbac.grants = %d, expected 1", attr.Value.AsInt64())
+		}
+	}
+	if child := spans["rbac.ListRoleBindings"]; child != nil && child.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Errorf("ListRoleBindings span is not a child of ResolvePermission")
+	}
+}
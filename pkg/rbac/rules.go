@@ -0,0 +1,275 @@
+package rbac
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// RuleKey is a single normalized permission atom expanded from a PolicyRule
+type RuleKey struct {
+	Verb           string
+	APIGroup       string
+	Resource       string
+	ResourceName   string
+	NonResourceURL string
+}
+
+// String returns a compact human-readable form of the atom
+func (k RuleKey) String() string {
+	if k.NonResourceURL != "" {
+		return k.Verb + " " + k.NonResourceURL
+	}
+	resource := k.Resource
+	if k.APIGroup != "" {
+		resource += "." + k.APIGroup
+	}
+	if k.ResourceName != "" {
+		resource += "/" + k.ResourceName
+	}
+	return k.Verb + " " + resource
+}
+
+// NormalizeRules expands rules into a sorted, de-duplicated list of atoms so
+// that rule sets written in different shapes can be compared
+func NormalizeRules(rules []rbacv1.PolicyRule) []RuleKey {
+	seen := make(map[RuleKey]bool)
+	var keys []RuleKey
+
+	add := func(k RuleKey) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+
+	for _, rule := range rules {
+		for _, verb := range rule.Verbs {
+			for _, url := range rule.NonResourceURLs {
+				add(RuleKey{Verb: verb, NonResourceURL: url})
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					if len(rule.ResourceNames) == 0 {
+						add(RuleKey{Verb: verb, APIGroup: group, Resource: resource})
+						continue
+					}
+					for _, name := range rule.ResourceNames {
+						add(RuleKey{Verb: verb, APIGroup: group, Resource: resource, ResourceName: name})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].sortKey() < keys[j].sortKey()
+	})
+	return keys
+}
+
+func (k RuleKey) sortKey() string {
+	return strings.Join([]string{k.NonResourceURL, k.APIGroup, k.Resource, k.ResourceName, k.Verb}, "\x00")
+}
+
+// DiffRules compares two rule sets after normalization and returns the atoms
+// present only in a and only in b
+func DiffRules(a, b []rbacv1.PolicyRule) (onlyA, onlyB []RuleKey) {
+	keysA := NormalizeRules(a)
+	keysB := NormalizeRules(b)
+
+	inA := make(map[RuleKey]bool, len(keysA))
+	for _, k := range keysA {
+		inA[k] = true
+	}
+	inB := make(map[RuleKey]bool, len(keysB))
+	for _, k := range keysB {
+		inB[k] = true
+	}
+
+	for _, k := range keysA {
+		if !inB[k] {
+			onlyA = append(onlyA, k)
+		}
+	}
+	for _, k := range keysB {
+		if !inA[k] {
+			onlyB = append(onlyB, k)
+		}
+	}
+	return onlyA, onlyB
+}
+
+// RuleCoverage pairs a rule with the rules of another rule set that cover it
+type RuleCoverage struct {
+	Rule      rbacv1.PolicyRule   `json:"rule"`
+	CoveredBy []rbacv1.PolicyRule `json:"coveredBy"`
+}
+
+// Subsumes reports whether every permission granted by a is also granted by
+// b, honoring wildcards in b. When it does, it returns the rules of b that
+// cover each rule of a.
+func Subsumes(b, a []rbacv1.PolicyRule) ([]RuleCoverage, bool) {
+	var coverage []RuleCoverage
+	for _, rule := range a {
+		covering := make(map[int]bool)
+		for _, k := range NormalizeRules([]rbacv1.PolicyRule{rule}) {
+			found := false
+			for i, candidate := range b {
+				if RuleCovers(candidate, k) {
+					covering[i] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		}
+		if len(covering) == 0 {
+			continue
+		}
+		c := RuleCoverage{Rule: rule}
+		for i, candidate := range b {
+			if covering[i] {
+				c.CoveredBy = append(c.CoveredBy, candidate)
+			}
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage, true
+}
+
+// RuleCovers reports whether rule grants everything the atom k grants. Unlike
+// RuleMatches, a rule restricted to resourceNames does not cover an atom
+// without one.
+func RuleCovers(rule rbacv1.PolicyRule, k RuleKey) bool {
+	if !matchesVerb(rule.Verbs, k.Verb) {
+		return false
+	}
+	if k.NonResourceURL != "" {
+		return coversNonResourceURL(rule.NonResourceURLs, k.NonResourceURL)
+	}
+	if !matchesAPIGroup(rule.APIGroups, k.APIGroup) {
+		return false
+	}
+	resource, subresource, _ := strings.Cut(k.Resource, "/")
+	if !matchesResource(rule.Resources, resource, subresource) {
+		return false
+	}
+	if len(rule.ResourceNames) == 0 {
+		return true
+	}
+	return k.ResourceName != "" && matchesResourceName(rule.ResourceNames, k.ResourceName)
+}
+
+// coversNonResourceURL checks url against exact and trailing-"*" prefix patterns
+func coversNonResourceURL(patterns []string, url string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == url {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionDelta is a permission atom held on only one side of a comparison,
+// with the grants that provide it
+type PermissionDelta struct {
+	Permission RuleKey
+	Grants     []PermissionGrant
+}
+
+// DiffGrants returns the permission atoms granted by a but not covered by any
+// rule in b, and vice versa. Coverage honors wildcards, so "*" on one side
+// and an explicit verb on the other is not reported.
+func DiffGrants(a, b []PermissionGrant) (onlyA, onlyB []PermissionDelta) {
+	return partitionGrants(a, b, false), partitionGrants(b, a, false)
+}
+
+// SharedGrants returns the permission atoms granted by a that some rule in b
+// also covers, with a's grants
+func SharedGrants(a, b []PermissionGrant) []PermissionDelta {
+	return partitionGrants(a, b, true)
+}
+
+// ReroutedGrants returns the permission atoms of the grants in before that
+// after no longer has but still covers through other grants, e.g. when a
+// removed binding duplicated another. Each delta holds the grants of after
+// that cover it.
+func ReroutedGrants(before, after []PermissionGrant) []PermissionDelta {
+	var removed []PermissionGrant
+	for _, g := range before {
+		if !containsGrant(after, g) {
+			removed = append(removed, g)
+		}
+	}
+
+	var deltas []PermissionDelta
+	for _, d := range SharedGrants(removed, after) {
+		delta := PermissionDelta{Permission: d.Permission}
+		for _, g := range after {
+			if RuleCovers(g.MatchingRule, d.Permission) {
+				delta.Grants = append(delta.Grants, g)
+			}
+		}
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+// containsGrant reports whether grants has g's binding, role, and rule
+func containsGrant(grants []PermissionGrant, g PermissionGrant) bool {
+	for _, other := range grants {
+		if other.Binding.Kind == g.Binding.Kind && other.Binding.Namespace == g.Binding.Namespace && other.Binding.Name == g.Binding.Name &&
+			other.Role.Kind == g.Role.Kind && other.Role.Namespace == g.Role.Namespace && other.Role.Name == g.Role.Name &&
+			reflect.DeepEqual(other.MatchingRule, g.MatchingRule) {
+			return true
+		}
+	}
+	return false
+}
+
+// partitionGrants returns the atoms of grants that other covers, or those it
+// doesn't
+func partitionGrants(grants, other []PermissionGrant, covered bool) []PermissionDelta {
+	var rules []rbacv1.PolicyRule
+	for _, g := range other {
+		rules = append(rules, g.MatchingRule)
+	}
+
+	index := make(map[RuleKey]int)
+	var deltas []PermissionDelta
+	for _, g := range grants {
+		for _, k := range NormalizeRules([]rbacv1.PolicyRule{g.MatchingRule}) {
+			if anyRuleCovers(rules, k) != covered {
+				continue
+			}
+			i, seen := index[k]
+			if !seen {
+				i = len(deltas)
+				index[k] = i
+				deltas = append(deltas, PermissionDelta{Permission: k})
+			}
+			deltas[i].Grants = append(deltas[i].Grants, g)
+		}
+	}
+
+	sort.SliceStable(deltas, func(i, j int) bool {
+		return deltas[i].Permission.sortKey() < deltas[j].Permission.sortKey()
+	})
+	return deltas
+}
+
+func anyRuleCovers(rules []rbacv1.PolicyRule, k RuleKey) bool {
+	for _, r := range rules {
+		if RuleCovers(r, k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package rbac
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestNormalizeRules(t *testing.T) {
+	rules := []rbacv1.PolicyRule{
+		{
+			Verbs:     []string{"get", "list"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		},
+		{
+			// Duplicate of one atom above, written differently
+			Verbs:     []string{"get"},
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+		},
+		{
+			Verbs:           []string{"get"},
+			NonResourceURLs: []string{"/healthz"},
+		},
+	}
+
+	keys := NormalizeRules(rules)
+	if len(keys) != 3 {
+		t.Fatalf("NormalizeRules() returned %d keys, expected 3: %v", len(keys), keys)
+	}
+}
+
+func TestDiffRules(t *testing.T) {
+	server := []rbacv1.PolicyRule{
+		{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics"}},
+	}
+	client := []rbacv1.PolicyRule{
+		{Verbs: []string{"list", "get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"db"}},
+	}
+
+	onlyServer, onlyClient := DiffRules(server, client)
+
+	if len(onlyServer) != 1 || onlyServer[0].NonResourceURL != "/metrics" {
+		t.Errorf("DiffRules() onlyServer = %v, expected [get /metrics]", onlyServer)
+	}
+	if len(onlyClient) != 1 || onlyClient[0].ResourceName != "db" {
+		t.Errorf("DiffRules() onlyClient = %v, expected [get secrets/db]", onlyClient)
+	}
+}
+
+func TestSubsumes(t *testing.T) {
+	view := []rbacv1.PolicyRule{
+		{Verbs: []string{"get", "list", "watch"}, APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments"}},
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/*"}},
+		{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz/*"}},
+	}
+
+	tests := []struct {
+		name  string
+		a     []rbacv1.PolicyRule
+		want  bool
+		rules int // coverage entries expected on success
+	}{
+		{
+			name:  "strict subset",
+			a:     []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+			want:  true,
+			rules: 1,
+		},
+		{
+			name:  "resourceNames narrow the rule",
+			a:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}, ResourceNames: []string{"web"}}},
+			want:  true,
+			rules: 1,
+		},
+		{
+			name:  "subresource wildcard",
+			a:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}}},
+			want:  true,
+			rules: 1,
+		},
+		{
+			name:  "non-resource prefix",
+			a:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz/ready"}}},
+			want:  true,
+			rules: 1,
+		},
+		{
+			name: "extra verb",
+			a:    []rbacv1.PolicyRule{{Verbs: []string{"get", "delete"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+			want: false,
+		},
+		{
+			name: "wildcard is not covered by literals",
+			a:    []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coverage, got := Subsumes(view, tt.a)
+			if got != tt.want {
+				t.Fatalf("Subsumes() = %v, want %v", got, tt.want)
+			}
+			if len(coverage) != tt.rules {
+				t.Errorf("Subsumes() returned %d coverage entries, want %d", len(coverage), tt.rules)
+			}
+		})
+	}
+
+	// A resourceNames-restricted rule does not cover the unrestricted one
+	named := []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"web"}}}
+	if _, ok := Subsumes(named, view[:1]); ok {
+		t.Error("Subsumes() treated a resourceNames-restricted rule as covering all names")
+	}
+}
+
+func TestDiffGrants(t *testing.T) {
+	grant := func(binding string, rule rbacv1.PolicyRule) PermissionGrant {
+		return PermissionGrant{Binding: BindingInfo{Kind: "RoleBinding", Name: binding}, MatchingRule: rule}
+	}
+	staging := []PermissionGrant{
+		grant("all-pods", rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods"}}),
+		grant("secrets", rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}),
+	}
+	prod := []PermissionGrant{
+		grant("read-pods", rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}),
+		grant("config", rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}}),
+	}
+
+	onlyStaging, onlyProd := DiffGrants(staging, prod)
+
+	// "*" on pods is not covered by get/list, but get/list is covered by "*"
+	if len(onlyStaging) != 2 || onlyStaging[0].Permission.String() != "* pods" || onlyStaging[1].Permission.String() != "get secrets" {
+		t.Errorf("onlyStaging = %v, want [* pods, get secrets]", onlyStaging)
+	}
+	if len(onlyProd) != 1 || onlyProd[0].Permission.String() != "get configmaps" {
+		t.Fatalf("onlyProd = %v, want [get configmaps]", onlyProd)
+	}
+	if onlyProd[0].Grants[0].Binding.Name != "config" {
+		t.Errorf("onlyProd grant = %+v, want binding config", onlyProd[0].Grants[0])
+	}
+}
+
+func TestReroutedGrants(t *testing.T) {
+	grant := func(binding string, rule rbacv1.PolicyRule) PermissionGrant {
+		return PermissionGrant{Binding: BindingInfo{Kind: "RoleBinding", Name: binding}, MatchingRule: rule}
+	}
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	allPods := rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	secrets := rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}}
+
+	before := []PermissionGrant{grant("read-pods", readPods), grant("admin", allPods), grant("secrets", secrets)}
+	after := []PermissionGrant{grant("admin", allPods)}
+
+	// read-pods is covered by admin; secrets is lost outright
+	got := ReroutedGrants(before, after)
+	if len(got) != 1 || got[0].Permission.String() != "get pods" {
+		t.Fatalf("ReroutedGrants() = %v, want [get pods]", got)
+	}
+	if len(got[0].Grants) != 1 || got[0].Grants[0].Binding.Name != "admin" {
+		t.Errorf("covering grants = %+v, want admin", got[0].Grants)
+	}
+}
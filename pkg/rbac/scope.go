@@ -0,0 +1,164 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/discovery"
+)
+
+// builtinClusterScoped are the built-in cluster-scoped resources, used when
+// discovery isn't available
+var builtinClusterScoped = map[schema.GroupResource]bool{
+	{Resource: "nodes"}:                                                                    true,
+	{Resource: "namespaces"}:                                                               true,
+	{Resource: "persistentvolumes"}:                                                        true,
+	{Resource: "componentstatuses"}:                                                        true,
+	{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}:                         true,
+	{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"}:                  true,
+	{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}:                 true,
+	{Group: "apiregistration.k8s.io", Resource: "apiservices"}:                             true,
+	{Group: "admissionregistration.k8s.io", Resource: "mutatingwebhookconfigurations"}:     true,
+	{Group: "admissionregistration.k8s.io", Resource: "validatingwebhookconfigurations"}:   true,
+	{Group: "admissionregistration.k8s.io", Resource: "validatingadmissionpolicies"}:       true,
+	{Group: "admissionregistration.k8s.io", Resource: "validatingadmissionpolicybindings"}: true,
+	{Group: "certificates.k8s.io", Resource: "certificatesigningrequests"}:                 true,
+	{Group: "storage.k8s.io", Resource: "storageclasses"}:                                  true,
+	{Group: "storage.k8s.io", Resource: "csidrivers"}:                                      true,
+	{Group: "storage.k8s.io", Resource: "csinodes"}:                                        true,
+	{Group: "storage.k8s.io", Resource: "volumeattachments"}:                               true,
+	{Group: "scheduling.k8s.io", Resource: "priorityclasses"}:                              true,
+	{Group: "node.k8s.io", Resource: "runtimeclasses"}:                                     true,
+	{Group: "networking.k8s.io", Resource: "ingressclasses"}:                               true,
+	{Group: "flowcontrol.apiserver.k8s.io", Resource: "flowschemas"}:                       true,
+	{Group: "flowcontrol.apiserver.k8s.io", Resource: "prioritylevelconfigurations"}:       true,
+	{Group: "authentication.k8s.io", Resource: "tokenreviews"}:                             true,
+	{Group: "authentication.k8s.io", Resource: "selfsubjectreviews"}:                       true,
+	{Group: "authorization.k8s.io", Resource: "subjectaccessreviews"}:                      true,
+	{Group: "authorization.k8s.io", Resource: "selfsubjectaccessreviews"}:                  true,
+	{Group: "authorization.k8s.io", Resource: "selfsubjectrulesreviews"}:                   true,
+}
+
+// builtinNamespaced are the built-in namespaced resources, used when
+// discovery isn't available
+var builtinNamespaced = map[schema.GroupResource]bool{
+	{Resource: "pods"}:                                                     true,
+	{Resource: "services"}:                                                 true,
+	{Resource: "endpoints"}:                                                true,
+	{Resource: "configmaps"}:                                               true,
+	{Resource: "secrets"}:                                                  true,
+	{Resource: "serviceaccounts"}:                                          true,
+	{Resource: "persistentvolumeclaims"}:                                   true,
+	{Resource: "events"}:                                                   true,
+	{Resource: "limitranges"}:                                              true,
+	{Resource: "resourcequotas"}:                                           true,
+	{Resource: "replicationcontrollers"}:                                   true,
+	{Resource: "podtemplates"}:                                             true,
+	{Group: "apps", Resource: "deployments"}:                               true,
+	{Group: "apps", Resource: "replicasets"}:                               true,
+	{Group: "apps", Resource: "statefulsets"}:                              true,
+	{Group: "apps", Resource: "daemonsets"}:                                true,
+	{Group: "apps", Resource: "controllerrevisions"}:                       true,
+	{Group: "batch", Resource: "jobs"}:                                     true,
+	{Group: "batch", Resource: "cronjobs"}:                                 true,
+	{Group: "autoscaling", Resource: "horizontalpodautoscalers"}:           true,
+	{Group: "policy", Resource: "poddisruptionbudgets"}:                    true,
+	{Group: "networking.k8s.io", Resource: "ingresses"}:                    true,
+	{Group: "networking.k8s.io", Resource: "networkpolicies"}:              true,
+	{Group: "discovery.k8s.io", Resource: "endpointslices"}:                true,
+	{Group: "events.k8s.io", Resource: "events"}:                           true,
+	{Group: "coordination.k8s.io", Resource: "leases"}:                     true,
+	{Group: "rbac.authorization.k8s.io", Resource: "roles"}:                true,
+	{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}:         true,
+	{Group: "storage.k8s.io", Resource: "csistoragecapacities"}:            true,
+	{Group: "authorization.k8s.io", Resource: "localsubjectaccessreviews"}: true,
+}
+
+// BuiltinNamespaced reports whether a built-in resource is namespaced, for
+// when discovery isn't available. found is false for resources it doesn't
+// know, such as those of CRDs.
+func BuiltinNamespaced(group, resource string) (namespaced, found bool) {
+	gr := schema.GroupResource{Group: group, Resource: resource}
+	switch {
+	case builtinNamespaced[gr]:
+		return true, true
+	case builtinClusterScoped[gr]:
+		return false, true
+	}
+	return false, false
+}
+
+// clusterScoped reports whether request is for a cluster-scoped resource,
+// per discovery when the client serves it and the built-in table otherwise.
+// The API server never evaluates RoleBindings for such a resource, since
+// its requests carry no namespace. The exception is bind on a ClusterRole
+// in a namespace, which is checked for a RoleBinding referencing it.
+func (r *Resolver) clusterScoped(ctx context.Context, request PermissionRequest) bool {
+	if request.NonResourceURL != "" || request.Resource == "*" {
+		return false
+	}
+	if request.Verb == "bind" && request.Resource == "clusterroles" && request.Namespace != "" {
+		return false
+	}
+	if r.discovery != nil {
+		if lists, err := r.discovery.ServerResources(ctx); err == nil {
+			if namespaced, found := discovery.Namespaced(lists, request.APIGroup, request.Resource); found {
+				return !namespaced
+			}
+		}
+	}
+	return builtinClusterScoped[schema.GroupResource{Group: request.APIGroup, Resource: request.Resource}]
+}
+
+// clusterOnlyRule reports whether rule covers only what a RoleBinding can't
+// grant: non-resource URLs and cluster-scoped resources. Such rules are left
+// out of the grants of RoleBinding paths.
+func (r *Resolver) clusterOnlyRule(ctx context.Context, rule rbacv1.PolicyRule) bool {
+	if len(rule.Resources) == 0 {
+		return len(rule.NonResourceURLs) > 0
+	}
+	for _, group := range rule.APIGroups {
+		for _, res := range rule.Resources {
+			resource, _, _ := strings.Cut(res, "/")
+			if group == rbacv1.APIGroupAll || resource == rbacv1.ResourceAll ||
+				!r.clusterScoped(ctx, PermissionRequest{APIGroup: group, Resource: resource}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ScopeNote explains what a RoleBinding to a ClusterRole confers, or returns
+// "" for other grants. Such a binding is often read as giving the
+// ClusterRole's full power, e.g. cluster-admin, when it only applies in
+// the binding's namespace.
+func (g PermissionGrant) ScopeNote() string {
+	if g.Binding.Kind != "RoleBinding" || g.Role.Kind != "ClusterRole" || g.EffectiveNamespace == "" {
+		return ""
+	}
+	return fmt.Sprintf("RoleBinding confines ClusterRole %s to namespace %s: only its rules for namespaced resources apply there, not those for cluster-scoped resources (nodes, namespaces, persistentvolumes) or non-resource URLs",
+		g.Role.Name, g.EffectiveNamespace)
+}
+
+// clusterScopedWarning explains why RoleBindings in namespace are left out
+func clusterScopedWarning(request PermissionRequest) Warning {
+	return Warning{
+		Code: WarningClusterScoped,
+		Message: fmt.Sprintf("%s is cluster-scoped, so RoleBindings in namespace %s don't grant it; only ClusterRoleBindings do",
+			request.FullResource(), request.Namespace),
+	}
+}
+
+// scopeMissReason explains a RoleBinding that would grant request if the
+// resource were namespaced
+func scopeMissReason(g *PermissionGrant, request PermissionRequest) DenialReason {
+	return grantReason(DenialClusterScoped, g,
+		fmt.Sprintf("RoleBinding %s/%s grants this through %s/%s, but %s is cluster-scoped and RoleBindings only grant namespaced resources; bind the role with a ClusterRoleBinding instead",
+			g.Binding.Namespace, g.Binding.Name, g.Role.Kind, g.Role.Name, request.FullResource()))
+}
@@ -0,0 +1,82 @@
+package rbac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SourceAnnotation records where an object read from a local manifest was
+// defined, as FILE#docN. Loaders for offline inputs set it; objects read from
+// a cluster don't have it.
+const SourceAnnotation = "rbac-why.io/source"
+
+// SourceLineAnnotation records the line of the file the object's YAML
+// document starts on, alongside SourceAnnotation
+const SourceLineAnnotation = "rbac-why.io/source-line"
+
+// ManifestSource locates an object within a local manifest file
+type ManifestSource struct {
+	File     string
+	Document int // 1-based index of the YAML document within File
+	Line     int // 1-based line the document starts on, or 0 when unknown
+}
+
+// String returns the source as FILE#docN
+func (s ManifestSource) String() string {
+	return fmt.Sprintf("%s#doc%d", s.File, s.Document)
+}
+
+// SetSource records on meta that the object was defined in document doc of
+// file, starting on line. A line of 0 isn't recorded.
+func SetSource(meta *metav1.ObjectMeta, file string, doc, line int) {
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string)
+	}
+	meta.Annotations[SourceAnnotation] = ManifestSource{File: file, Document: doc}.String()
+	if line > 0 {
+		meta.Annotations[SourceLineAnnotation] = strconv.Itoa(line)
+	}
+}
+
+// SourceFromMeta returns where the object was defined, or nil for objects
+// that didn't come from a local manifest
+func SourceFromMeta(meta metav1.ObjectMeta) *ManifestSource {
+	value := meta.Annotations[SourceAnnotation]
+	i := strings.LastIndex(value, "#doc")
+	if i <= 0 {
+		return nil
+	}
+	doc, err := strconv.Atoi(value[i+len("#doc"):])
+	if err != nil || doc < 1 {
+		return nil
+	}
+	line, err := strconv.Atoi(meta.Annotations[SourceLineAnnotation])
+	if err != nil || line < 1 {
+		line = 0
+	}
+	return &ManifestSource{File: value[:i], Document: doc, Line: line}
+}
+
+// RuleSource returns where the grant's rule is defined, e.g.
+// "rbac/roles.yaml#doc3 rules[2]", or "" when the role didn't come from a
+// local manifest. A rule of a computed aggregate is located in the role it
+// was aggregated from.
+func (g PermissionGrant) RuleSource() string {
+	source, rule := g.RuleLocation()
+	if source == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s rules[%d]", source, rule)
+}
+
+// RuleLocation returns the manifest and rule index RuleSource describes, or
+// nil when the rule didn't come from a local manifest
+func (g PermissionGrant) RuleLocation() (*ManifestSource, int) {
+	if src := g.AggregatedFrom; src != nil && src.Computed {
+		return src.Source, src.RuleIndex
+	}
+	return g.Role.Source, g.RuleIndex
+}
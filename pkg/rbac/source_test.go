@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestSourceFromMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		line  string
+		want  *ManifestSource
+	}{
+		{name: "unset"},
+		{name: "file and document", value: "rbac/roles.yaml#doc3", want: &ManifestSource{File: "rbac/roles.yaml", Document: 3}},
+		{name: "line", value: "rbac/roles.yaml#doc3", line: "12", want: &ManifestSource{File: "rbac/roles.yaml", Document: 3, Line: 12}},
+		{name: "invalid line", value: "rbac/roles.yaml#doc3", line: "x", want: &ManifestSource{File: "rbac/roles.yaml", Document: 3}},
+		{name: "hash in file name", value: "a#doc1.yaml#doc2", want: &ManifestSource{File: "a#doc1.yaml", Document: 2}},
+		{name: "missing document", value: "roles.yaml"},
+		{name: "zero document", value: "roles.yaml#doc0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := metav1.ObjectMeta{Annotations: map[string]string{SourceAnnotation: tt.value}}
+			if tt.line != "" {
+				meta.Annotations[SourceLineAnnotation] = tt.line
+			}
+			got := SourceFromMeta(meta)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("SourceFromMeta(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePermission_RuleSource(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	role := rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}},
+			{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		},
+	}
+	SetSource(&role.ObjectMeta, "rbac/roles.yaml", 3, 12)
+	mock.AddRole(role)
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "jane"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "app"},
+	})
+
+	result, err := NewResolver(mock).ResolvePermission(context.Background(), Subject{Kind: "User", Name: "jane"},
+		PermissionRequest{Verb: "get", Resource: "pods", Namespace: "default"})
+	if err != nil {
+		t.Fatalf("ResolvePermission() error = %v", err)
+	}
+	if len(result.Grants) != 1 {
+		t.Fatalf("got %d grants, want 1", len(result.Grants))
+	}
+	if got, want := result.Grants[0].RuleSource(), "rbac/roles.yaml#doc3 rules[1]"; got != want {
+		t.Errorf("RuleSource() = %q, want %q", got, want)
+	}
+}
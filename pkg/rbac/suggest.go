@@ -0,0 +1,105 @@
+package rbac
+
+import (
+	"regexp"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Suggestion is the smallest role and binding that would grant a denied
+// request: a Role and RoleBinding in the request's namespace, or a
+// ClusterRole and ClusterRoleBinding for cluster-scoped requests
+type Suggestion struct {
+	Name    string
+	Role    runtime.Object
+	Binding runtime.Object
+}
+
+// Objects returns the role followed by its binding, in apply order
+func (s *Suggestion) Objects() []runtime.Object {
+	return []runtime.Object{s.Role, s.Binding}
+}
+
+// SuggestGrant builds the Suggestion granting exactly request to subject
+func SuggestGrant(subject Subject, request PermissionRequest) *Suggestion {
+	rule := SuggestRule(request)
+	name := SuggestionName(subject, request)
+	bindingSubject := rbacv1.Subject{Kind: subject.Kind, Name: subject.Name}
+	if subject.Kind == "ServiceAccount" {
+		bindingSubject.Namespace = subject.Namespace
+	} else {
+		bindingSubject.APIGroup = rbacv1.GroupName
+	}
+	meta := metav1.ObjectMeta{Name: name, Namespace: request.Namespace}
+
+	if request.Namespace == "" || request.NonResourceURL != "" {
+		meta.Namespace = ""
+		return &Suggestion{
+			Name: name,
+			Role: &rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+				ObjectMeta: meta,
+				Rules:      []rbacv1.PolicyRule{rule},
+			},
+			Binding: &rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+				ObjectMeta: meta,
+				Subjects:   []rbacv1.Subject{bindingSubject},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			},
+		}
+	}
+	return &Suggestion{
+		Name: name,
+		Role: &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: meta,
+			Rules:      []rbacv1.PolicyRule{rule},
+		},
+		Binding: &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: meta,
+			Subjects:   []rbacv1.Subject{bindingSubject},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+		},
+	}
+}
+
+// SuggestRule builds the rule granting exactly request
+func SuggestRule(request PermissionRequest) rbacv1.PolicyRule {
+	rule := rbacv1.PolicyRule{Verbs: []string{request.Verb}}
+	if request.NonResourceURL != "" {
+		rule.NonResourceURLs = []string{request.NonResourceURL}
+		return rule
+	}
+	rule.APIGroups = []string{request.APIGroup}
+	rule.Resources = []string{request.FullResource()}
+	if request.ResourceName != "" {
+		rule.ResourceNames = []string{request.ResourceName}
+	}
+	return rule
+}
+
+// invalidNameChars are the characters not allowed in an RBAC object name
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// SuggestionName returns the deterministic name of a suggested role and
+// binding, rbacwhy-<subject>-<verb>-<resource>, e.g.
+// "rbacwhy-prod-api-get-pods-log" for ServiceAccount prod/api
+func SuggestionName(subject Subject, request PermissionRequest) string {
+	parts := []string{"rbacwhy"}
+	if subject.Kind == "ServiceAccount" {
+		parts = append(parts, subject.Namespace)
+	}
+	parts = append(parts, subject.Name, request.Verb, request.FullResource())
+
+	name := strings.ReplaceAll(strings.ToLower(strings.Join(parts, "-")), "*", "all")
+	name = invalidNameChars.ReplaceAllString(name, "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
@@ -0,0 +1,46 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestSuggestGrant(t *testing.T) {
+	subject := Subject{Kind: "ServiceAccount", Name: "api", Namespace: "prod"}
+	request := PermissionRequest{Verb: "get", Resource: "pods", Subresource: "log", Namespace: "prod"}
+
+	s := SuggestGrant(subject, request)
+	if s.Name != "rbacwhy-prod-api-get-pods-log" {
+		t.Errorf("Name = %q, want rbacwhy-prod-api-get-pods-log", s.Name)
+	}
+	role, ok := s.Role.(*rbacv1.Role)
+	if !ok || role.Namespace != "prod" || role.Rules[0].Resources[0] != "pods/log" {
+		t.Fatalf("Role = %+v, want a Role in prod for pods/log", s.Role)
+	}
+	binding, ok := s.Binding.(*rbacv1.RoleBinding)
+	if !ok || binding.RoleRef.Name != s.Name || binding.Subjects[0].Namespace != "prod" {
+		t.Fatalf("Binding = %+v, want a RoleBinding of the Role to the ServiceAccount", s.Binding)
+	}
+
+	// Applying the suggestion grants exactly the request
+	mock := client.NewMockRBACClient()
+	mock.AddRole(*role)
+	mock.AddRoleBinding(*binding)
+	result, err := NewResolver(mock).ResolvePermission(context.Background(), subject, request)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error: %v", err)
+	}
+	if !result.Allowed {
+		t.Error("the suggested Role and RoleBinding don't grant the request")
+	}
+
+	// Cluster-scoped requests get a ClusterRole
+	s = SuggestGrant(Subject{Kind: "User", Name: "jane@example.com"}, PermissionRequest{Verb: "list", Resource: "nodes"})
+	if _, ok := s.Binding.(*rbacv1.ClusterRoleBinding); !ok || s.Name != "rbacwhy-jane-example.com-list-nodes" {
+		t.Errorf("suggestion %q = %T, want rbacwhy-jane-example.com-list-nodes as a ClusterRoleBinding", s.Name, s.Binding)
+	}
+}
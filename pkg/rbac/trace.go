@@ -0,0 +1,146 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// maxTraceItems bounds how many rule values a trace reason lists
+const maxTraceItems = 5
+
+// BindingTrace records how the resolver evaluated one binding for a request
+type BindingTrace struct {
+	Binding BindingInfo
+	RoleRef rbacv1.RoleRef
+
+	// MatchedVia is the binding subject that matched, e.g. "Group
+	// system:authenticated", or empty when the binding doesn't apply
+	MatchedVia string
+
+	// RoleError is set when the roleRef could not be resolved
+	RoleError string
+
+	// Rules are the role's rules, tested in order; only set for matched bindings
+	Rules []RuleTrace
+}
+
+// Matched reports whether the binding applies to the subject
+func (t BindingTrace) Matched() bool {
+	return t.MatchedVia != ""
+}
+
+// RuleTrace is the outcome of testing one rule against the request
+type RuleTrace struct {
+	Index   int
+	Matched bool
+	Reason  string // Why the rule doesn't match; empty when it does
+}
+
+// WithEvaluationTrace makes ResolvePermission record every binding it
+// examined in PermissionResult.Trace
+func WithEvaluationTrace() ResolverOption {
+	return func(c *resolverConfig) {
+		c.evaluationTrace = true
+	}
+}
+
+// traceBindings evaluates every ClusterRoleBinding, and the RoleBindings in
+// the request's namespace, recording why each one does or doesn't grant the
+// request
+func (r *Resolver) traceBindings(ctx context.Context, subject Subject, groups []string, request PermissionRequest) ([]BindingTrace, error) {
+	var traces []BindingTrace
+
+	crbs, err := r.client.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	for _, crb := range crbs.Items {
+		binding := BindingInfo{Kind: "ClusterRoleBinding", Name: crb.Name}
+		traces = append(traces, r.traceBinding(ctx, binding, crb.RoleRef, crb.Subjects, subject, groups, request))
+	}
+
+	if request.Namespace != "" && request.NonResourceURL == "" {
+		rbs, err := r.client.ListRoleBindings(ctx, request.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role bindings in namespace %s: %w", request.Namespace, err)
+		}
+		clusterScoped := r.clusterScoped(ctx, request)
+		for _, rb := range rbs.Items {
+			binding := BindingInfo{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace}
+			trace := r.traceBinding(ctx, binding, rb.RoleRef, rb.Subjects, subject, groups, request)
+			// A rule that matches still doesn't grant a cluster-scoped resource
+			// through a RoleBinding
+			for i := range trace.Rules {
+				if clusterScoped && trace.Rules[i].Matched {
+					trace.Rules[i].Matched = false
+					trace.Rules[i].Reason = fmt.Sprintf("%s is cluster-scoped, which RoleBindings don't grant", request.FullResource())
+				}
+			}
+			traces = append(traces, trace)
+		}
+	}
+	return traces, nil
+}
+
+func (r *Resolver) traceBinding(ctx context.Context, binding BindingInfo, ref rbacv1.RoleRef, subjects []rbacv1.Subject, subject Subject, groups []string, request PermissionRequest) BindingTrace {
+	trace := BindingTrace{Binding: binding, RoleRef: ref, MatchedVia: matchingSubject(subjects, subject, groups)}
+	if !trace.Matched() {
+		return trace
+	}
+
+	rules, _, err := r.boundRules(ctx, ref, binding.Namespace)
+	if d := danglingBinding(binding, ref, err); d != nil {
+		trace.RoleError = d.MissingRole() + " doesn't exist"
+		return trace
+	}
+	if err != nil {
+		trace.RoleError = err.Error()
+		return trace
+	}
+	for i, rule := range rules {
+		reason := RuleMismatch(rule, request)
+		trace.Rules = append(trace.Rules, RuleTrace{Index: i, Matched: reason == "", Reason: reason})
+	}
+	return trace
+}
+
+// matchingSubject describes the binding subject that applies to the request
+// subject, directly or through one of its groups, or returns "" when none does
+func matchingSubject(subjects []rbacv1.Subject, subject Subject, groups []string) string {
+	if i := bindingMatchesSubject(subjects, subject, groups); i >= 0 {
+		return describeBindingSubject(subjects[i])
+	}
+	return ""
+}
+
+// describeBindingSubject returns e.g. "Group dev" or "ServiceAccount prod/api"
+func describeBindingSubject(s rbacv1.Subject) string {
+	if s.Kind == "ServiceAccount" {
+		return "ServiceAccount " + s.Namespace + "/" + s.Name
+	}
+	return s.Kind + " " + s.Name
+}
+
+// boundedList quotes at most maxTraceItems values, so a rule listing every
+// resource of a large API doesn't flood the trace
+func boundedList(values []string) string {
+	if len(values) == 0 {
+		return "[]"
+	}
+	shown := values
+	if len(shown) > maxTraceItems {
+		shown = shown[:maxTraceItems]
+	}
+	quoted := make([]string, len(shown))
+	for i, v := range shown {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	list := strings.Join(quoted, " ")
+	if more := len(values) - len(shown); more > 0 {
+		list += fmt.Sprintf(" ... +%d more", more)
+	}
+	return "[" + list + "]"
+}
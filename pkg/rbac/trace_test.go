@@ -0,0 +1,81 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestResolvePermission_Trace(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "reader"},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list", "watch"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"a", "b", "c", "d", "e", "f", "g"}, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "authenticated-read"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:authenticated"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "someone-else"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "dangling", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "alice"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "deleted"},
+	})
+
+	request := PermissionRequest{Verb: "get", Resource: "pods", Namespace: "dev"}
+	subject := Subject{Kind: "User", Name: "alice"}
+
+	untraced, err := NewResolver(mock).ResolvePermission(context.Background(), subject, request)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error = %v", err)
+	}
+	if untraced.Trace != nil {
+		t.Errorf("Trace = %v without WithEvaluationTrace, want nil", untraced.Trace)
+	}
+
+	result, err := NewResolver(mock, WithEvaluationTrace()).ResolvePermission(context.Background(), subject, request)
+	if err != nil {
+		t.Fatalf("ResolvePermission() error = %v", err)
+	}
+	if len(result.Trace) != 3 {
+		t.Fatalf("got %d traced bindings, want 3", len(result.Trace))
+	}
+
+	crb := result.Trace[0]
+	if crb.MatchedVia != "Group system:authenticated" {
+		t.Errorf("MatchedVia = %q, want Group system:authenticated", crb.MatchedVia)
+	}
+	wantRules := []RuleTrace{
+		{Index: 0, Reason: `verb "get" not in ["list" "watch"]`},
+		{Index: 1, Reason: `apiGroup "" not in ["apps"]`},
+		{Index: 2, Reason: `resource "pods" not in ["a" "b" "c" "d" "e" ... +2 more]`},
+		{Index: 3, Matched: true},
+	}
+	if !reflect.DeepEqual(crb.Rules, wantRules) {
+		t.Errorf("Rules = %+v, want %+v", crb.Rules, wantRules)
+	}
+
+	if other := result.Trace[1]; other.Matched() || other.Rules != nil {
+		t.Errorf("binding for another user = %+v, want unmatched without rules", other)
+	}
+
+	if dangling := result.Trace[2]; dangling.MatchedVia != "User alice" || dangling.RoleError == "" {
+		t.Errorf("dangling binding = %+v, want matched with a roleRef error", dangling)
+	}
+}
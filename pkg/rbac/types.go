@@ -1,6 +1,10 @@
 package rbac
 
 import (
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 )
 
@@ -12,10 +16,18 @@ type PermissionRequest struct {
 	Subresource  string
 	ResourceName string
 	Namespace    string // Empty for cluster-scoped resources
+
+	// NonResourceURL is set instead of the resource fields for a request to
+	// a path such as /metrics or /healthz
+	NonResourceURL string
 }
 
-// FullResource returns the resource with subresource if present (e.g., "pods/exec")
+// FullResource returns the resource with subresource if present (e.g.,
+// "pods/exec"), or the non-resource URL
 func (p PermissionRequest) FullResource() string {
+	if p.NonResourceURL != "" {
+		return p.NonResourceURL
+	}
 	if p.Subresource != "" {
 		return p.Resource + "/" + p.Subresource
 	}
@@ -24,10 +36,35 @@ func (p PermissionRequest) FullResource() string {
 
 // Subject represents who is requesting access
 type Subject struct {
-	Kind      string   // User, Group, ServiceAccount
+	Kind      string // User, Group, ServiceAccount
 	Name      string
 	Namespace string   // Only for ServiceAccount
 	Groups    []string // Explicit groups (e.g., from client certificate)
+	Origin    string   // How the subject was derived (e.g., "--sa prod/api"), for display only
+
+	// UID is the user's UID, from --as-uid or a SubjectAccessReview. RBAC
+	// matching ignores it; it is passed on to SubjectAccessReviews, since
+	// webhook authorizers may key on it.
+	UID string
+
+	// Extra is the user's extra attributes, from --as-user-extra or a
+	// SubjectAccessReview. Like UID, RBAC ignores them and they are passed
+	// on to SubjectAccessReviews.
+	Extra map[string][]string
+
+	// ExactGroups means Groups is the complete group list (e.g., from a
+	// SubjectAccessReview) and no implicit groups are added
+	ExactGroups bool
+
+	// GroupMappings are the static group file entries that added to Groups
+	GroupMappings []GroupMapping
+}
+
+// GroupMapping records groups added to a subject by a static mapping entry
+type GroupMapping struct {
+	Source  string // File and line of the entry, e.g. "groups.yaml:3"
+	Pattern string
+	Groups  []string
 }
 
 // String returns a human-readable representation of the subject
@@ -38,6 +75,62 @@ func (s Subject) String() string {
 	return s.Kind + " " + s.Name
 }
 
+// Canonical returns the identity string the API server uses for the subject
+// (e.g., "system:serviceaccount:default:my-sa")
+func (s Subject) Canonical() string {
+	if s.Kind == "ServiceAccount" {
+		return "system:serviceaccount:" + s.Namespace + ":" + s.Name
+	}
+	return s.Name
+}
+
+// AsString returns the subject in a form ParseSubject reads back as the same
+// subject, e.g. for --as or a checks file: the ServiceAccount username, or
+// the name with a group: or user: prefix where its shape alone would be
+// read as the other kind
+func (s Subject) AsString() string {
+	switch s.Kind {
+	case "ServiceAccount":
+		return s.Canonical()
+	case "Group":
+		return GroupPrefix + s.Name
+	}
+	if parsed, err := ParseSubject(s.Name); err != nil || parsed.Kind != s.Kind {
+		return UserPrefix + s.Name
+	}
+	return s.Name
+}
+
+// SystemMastersGroup is the hard-coded superuser group. Members bypass
+// authorization entirely, so no RBAC binding is needed for them to be allowed.
+const SystemMastersGroup = "system:masters"
+
+// NodeUserPrefix starts the username of a kubelet, e.g. system:node:worker-1.
+// Kubelets are in NodesGroup.
+const NodeUserPrefix = "system:node:"
+
+// Prefixes that name a subject's kind explicitly in --as, e.g.
+// group:platform-team, instead of it being guessed from the name
+const (
+	UserPrefix           = "user:"
+	GroupPrefix          = "group:"
+	ServiceAccountPrefix = "sa:"
+
+	// ServiceAccountURLPrefix starts the sa://NAMESPACE/NAME form
+	ServiceAccountURLPrefix = "sa://"
+)
+
+// NodesGroup is the group every kubelet is in
+const NodesGroup = "system:nodes"
+
+// IsNode reports whether the subject is a kubelet
+func IsNode(subject Subject) bool {
+	return subject.Kind == "User" && strings.HasPrefix(subject.Name, NodeUserPrefix)
+}
+
+// ClusterAdminRole is the default ClusterRole granting full control of the cluster
+const ClusterAdminRole = "cluster-admin"
+
 // GrantScope indicates whether a grant is namespace-scoped or cluster-wide
 type GrantScope string
 
@@ -51,6 +144,7 @@ type BindingInfo struct {
 	Kind      string // RoleBinding or ClusterRoleBinding
 	Name      string
 	Namespace string // Empty for ClusterRoleBinding
+	Owner     *Owner // Release or application that manages the binding, if any
 }
 
 // RoleInfo contains information about a Role or ClusterRole
@@ -58,6 +152,10 @@ type RoleInfo struct {
 	Kind      string // Role or ClusterRole
 	Name      string
 	Namespace string // Empty for ClusterRole
+	Owner     *Owner // Release or application that manages the role, if any
+
+	// Source is where the role is defined when it was read from a local manifest
+	Source *ManifestSource
 }
 
 // PermissionGrant represents a single path by which permission is granted
@@ -66,10 +164,104 @@ type PermissionGrant struct {
 	Binding BindingInfo
 	// The role/clusterrole that contains the rule
 	Role RoleInfo
+	// MatchedSubject is the binding's subject entry that applies to the
+	// subject, directly or through one of its groups, and SubjectIndex its
+	// position in the binding's subjects
+	MatchedSubject *rbacv1.Subject
+	SubjectIndex   int
 	// The specific rule that grants the permission
 	MatchingRule rbacv1.PolicyRule
+	// RuleIndex is the position of MatchingRule in the role's rules
+	RuleIndex int
+	// AlsoMatchedRules are the role's other rules that match the request,
+	// when grants through the same binding and role were merged
+	AlsoMatchedRules []IndexedRule
+	// Conditional is set when MatchingRule is restricted to resourceNames
+	// and the request names no object, so the grant covers only those names
+	Conditional bool
 	// Scope of the grant
 	Scope GrantScope
+	// EffectiveNamespace is the namespace a RoleBinding grant is confined
+	// to, empty for cluster-wide grants
+	EffectiveNamespace string
+	// AggregatedFrom is the ClusterRole MatchingRule was aggregated from, when
+	// Role is an aggregated ClusterRole
+	AggregatedFrom *AggregationSource
+	// Wildcards are the parts of MatchingRule that cover the request only
+	// through a wildcard, e.g. WildcardVerb; see WildcardMatches
+	Wildcards []string
+	// NarrowedRule is the least-privilege form of MatchingRule that still
+	// grants the request, with --suggest-least-privilege
+	NarrowedRule *rbacv1.PolicyRule
+	// Commands are the kubectl commands that show Binding and Role, with
+	// --show-commands
+	Commands []string
+}
+
+// SelfSubjectRulesReviewKind is the binding kind of grants built from a
+// SelfSubjectRulesReview, whose bindings and roles are unknown
+const SelfSubjectRulesReviewKind = "SelfSubjectRulesReview"
+
+// GrantsFromRules wraps rules the API server reported for namespace as
+// grants, so they can be analyzed like resolved ones
+func GrantsFromRules(rules []rbacv1.PolicyRule, namespace string) []PermissionGrant {
+	grants := make([]PermissionGrant, 0, len(rules))
+	for i, rule := range rules {
+		grants = append(grants, PermissionGrant{
+			Binding:      BindingInfo{Kind: SelfSubjectRulesReviewKind, Namespace: namespace},
+			MatchingRule: rule,
+			RuleIndex:    i,
+			Scope:        ScopeNamespace,
+			// Rules reviewed for a namespace apply only there
+			EffectiveNamespace: namespace,
+		})
+	}
+	return grants
+}
+
+// ChainUnknown reports whether the grant came from a SelfSubjectRulesReview
+// rather than from a binding and role
+func (g PermissionGrant) ChainUnknown() bool {
+	return g.Binding.Kind == SelfSubjectRulesReviewKind
+}
+
+// MatchedVia describes MatchedSubject, e.g. "Group system:serviceaccounts:prod",
+// or returns "" when it's unknown
+func (g PermissionGrant) MatchedVia() string {
+	if g.MatchedSubject == nil {
+		return ""
+	}
+	return describeBindingSubject(*g.MatchedSubject)
+}
+
+// ViaGroup reports whether the grant applies to subject only through one of
+// its groups rather than naming it
+func (g PermissionGrant) ViaGroup(subject Subject) bool {
+	m := g.MatchedSubject
+	return m != nil && m.Kind == "Group" && (subject.Kind != "Group" || subject.Name != m.Name)
+}
+
+// Superuser reports whether the grant comes from the cluster-admin ClusterRole
+func (g PermissionGrant) Superuser() bool {
+	return g.Role.Kind == "ClusterRole" && g.Role.Name == ClusterAdminRole
+}
+
+// How a grant's rule covers a request for a named object
+const (
+	NameMatchExplicit = "explicit" // The rule lists the name in resourceNames
+	NameMatchAny      = "any"      // The rule has no resourceNames and covers every object
+)
+
+// NameMatch reports how the grant covers request's object name: explicitly
+// or as one of any names, or "" when the request doesn't name an object
+func (g PermissionGrant) NameMatch(request PermissionRequest) string {
+	if request.ResourceName == "" {
+		return ""
+	}
+	if len(g.MatchingRule.ResourceNames) > 0 {
+		return NameMatchExplicit
+	}
+	return NameMatchAny
 }
 
 // PermissionResult holds all grants for a permission check
@@ -78,13 +270,252 @@ type PermissionResult struct {
 	Subject Subject
 	Allowed bool
 	Grants  []PermissionGrant
-	Errors  []error
+
+	// Diagnostics are the problems met while resolving the result, such as
+	// a role that couldn't be read
+	Diagnostics []Diagnostic
+
+	// BypassedVia is set to the group through which the subject skips
+	// authorization entirely (e.g., system:masters). Grants is empty in that case.
+	BypassedVia string
+
+	// PartiallyAllowed is set when every grant is Conditional: the request
+	// is allowed only for the objects AllowedNames lists, and Allowed is false
+	PartiallyAllowed bool
+
+	// DenialReasons explains why a denied request falls short
+	DenialReasons []DenialReason
+
+	// DanglingBindings are bindings that apply to the subject but refer to
+	// a role that doesn't exist
+	DanglingBindings []DanglingBinding
+
+	// Trace is every binding examined and why it did or didn't grant the
+	// request, when the resolver was created WithEvaluationTrace
+	Trace []BindingTrace
+
+	// Review is the SubjectAccessReview the check was read from, with its
+	// status filled in from the result
+	Review *authorizationv1.SubjectAccessReview
+
+	// Verification is the API server's own decision, with --verify
+	Verification *Verification
+
+	// Suggestion is the role and binding that would grant a denied request,
+	// with --suggest
+	Suggestion *Suggestion
+
+	// Commands are the kubectl commands that reproduce the check, with
+	// --show-commands
+	Commands []string
+
+	// Warnings are caveats about the result, such as a resource the cluster
+	// doesn't serve
+	Warnings []Warning
+
+	// Mode is how the result was reached; empty is the same as ModeResolved
+	Mode string
+	// Limitation explains what a result not reached by ModeResolved lacks
+	Limitation string
+	// ServerReason is the API server's explanation of a ModeSelfAccessReview
+	// decision, when it gives one
+	ServerReason string
+}
+
+// How a result was reached
+const (
+	// ModeResolved results come from reading the RBAC objects and carry the
+	// grant chain
+	ModeResolved = "resolved"
+	// ModeSelfAccessReview results are the API server's answer to a
+	// SelfSubjectAccessReview, used when the caller can't read the RBAC
+	// objects; they have no grants
+	ModeSelfAccessReview = "self-access-review"
+)
+
+// Warning is a caveat about a result
+type Warning struct {
+	Code    string
+	Message string
+}
+
+// Warning codes
+const (
+	// WarningUnknownResource means the cluster doesn't serve the resource
+	WarningUnknownResource = "UnknownResource"
+	// WarningNodeAuthorizer means the subject is a kubelet, which the Node
+	// authorizer grants access RBAC doesn't show
+	WarningNodeAuthorizer = "NodeAuthorizer"
+	// WarningClusterScoped means the resource is cluster-scoped, so the
+	// RoleBindings in the requested namespace were left out
+	WarningClusterScoped = "ClusterScoped"
+)
+
+// NodeAuthorizerWarning returns the caveat for checking a kubelet's access
+func NodeAuthorizerWarning(subject Subject) Warning {
+	return Warning{
+		Code: WarningNodeAuthorizer,
+		Message: subject.Name + " is a kubelet, which is authorized mainly by the Node authorizer rather than RBAC; " +
+			"it can read the Pods, Secrets, ConfigMaps, and volumes of Pods scheduled to its node without an RBAC grant, " +
+			"so this RBAC-only answer is incomplete",
+	}
+}
+
+// addDangling records dangling bindings, with a diagnostic for each
+func (r *PermissionResult) addDangling(dangling []DanglingBinding) {
+	for _, d := range dangling {
+		r.DanglingBindings = append(r.DanglingBindings, d)
+		r.Diagnostics = append(r.Diagnostics, d.Diagnostic())
+	}
+}
+
+// AllowedNames returns the object names a PartiallyAllowed result is
+// allowed for, sorted
+func (r *PermissionResult) AllowedNames() []string {
+	return conditionalNames(r.Grants)
+}
+
+// conditionalNames returns the resourceNames of the conditional grants, sorted
+func conditionalNames(grants []PermissionGrant) []string {
+	var names []string
+	for _, g := range grants {
+		if !g.Conditional {
+			continue
+		}
+		// A conditional grant's merged rules are all conditional too
+		names = append(names, g.MatchingRule.ResourceNames...)
+		for _, also := range g.AlsoMatchedRules {
+			names = append(names, also.Rule.ResourceNames...)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// SuperuserGrants returns the grants that come from the cluster-admin ClusterRole
+func (r *PermissionResult) SuperuserGrants() []PermissionGrant {
+	var grants []PermissionGrant
+	for _, g := range r.Grants {
+		if g.Superuser() {
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+// OnlyViaSuperuser reports whether every grant comes from the cluster-admin ClusterRole
+func (r *PermissionResult) OnlyViaSuperuser() bool {
+	return len(r.Grants) > 0 && len(r.SuperuserGrants()) == len(r.Grants)
+}
+
+// OnlyViaWildcards reports whether every grant matches the request only
+// through a wildcard, so a narrower rule would serve
+func (r *PermissionResult) OnlyViaWildcards() bool {
+	for _, g := range r.Grants {
+		if len(g.Wildcards) == 0 {
+			return false
+		}
+	}
+	return len(r.Grants) > 0
+}
+
+// Risk severities, from most to least severe
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// Severities lists the valid severities, most severe first
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow}
+
+// SeverityRank orders severities so they can be compared against thresholds.
+// Higher is more severe; unknown severities rank lowest.
+func SeverityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
 }
 
 // RiskyPermission identifies a potentially dangerous permission
 type RiskyPermission struct {
 	Category    string // e.g., "secrets", "privilege-escalation", "node-access"
 	Description string
-	Severity    string // "critical", "high", "medium"
+	Severity    string // "critical", "high", "medium", "low"
 	Grants      []PermissionGrant
+
+	// DefaultSeverity is the built-in severity when Severity was overridden
+	// by configuration
+	DefaultSeverity string
+
+	// Exposure is set when the subject's token is easy to steal, and
+	// UnexposedSeverity is the severity before it was raised for that
+	Exposure          *TokenExposure
+	UnexposedSeverity string
+
+	// BypassedVia is set to the group through which the subject skips
+	// authorization; such a risk has no grants
+	BypassedVia string
+}
+
+// TokenExposure describes how easily a ServiceAccount's token can be stolen
+type TokenExposure struct {
+	// LegacyTokenSecrets are long-lived kubernetes.io/service-account-token Secrets
+	LegacyTokenSecrets []string `json:"legacyTokenSecrets,omitempty"`
+	// SensitiveNamespace is set when the ServiceAccount is in a namespace
+	// configured as sensitive
+	SensitiveNamespace bool `json:"sensitiveNamespace,omitempty"`
+	// Automount reports that the ServiceAccount leaves automountServiceAccountToken enabled
+	Automount bool `json:"automount,omitempty"`
+	// AutomountingPods are pods running as the ServiceAccount with a token mounted
+	AutomountingPods []string `json:"automountingPods,omitempty"`
+}
+
+// Exposed reports whether the token is easy to steal: a legacy token Secret
+// exists, or automounting is left enabled in a sensitive namespace
+func (e *TokenExposure) Exposed() bool {
+	if e == nil {
+		return false
+	}
+	if len(e.LegacyTokenSecrets) > 0 {
+		return true
+	}
+	return e.SensitiveNamespace && (e.Automount || len(e.AutomountingPods) > 0)
+}
+
+// String summarizes why the token is exposed
+func (e *TokenExposure) String() string {
+	var parts []string
+	if len(e.LegacyTokenSecrets) > 0 {
+		parts = append(parts, "legacy token Secret(s) "+strings.Join(e.LegacyTokenSecrets, ", "))
+	}
+	if e.SensitiveNamespace {
+		if e.Automount {
+			parts = append(parts, "automountServiceAccountToken enabled in a sensitive namespace")
+		}
+		if len(e.AutomountingPods) > 0 {
+			parts = append(parts, "token mounted by pod(s) "+strings.Join(e.AutomountingPods, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// RaiseSeverity returns the next more severe level, or severity itself when
+// it is already critical or unknown
+func RaiseSeverity(severity string) string {
+	for i, s := range Severities {
+		if s == severity && i > 0 {
+			return Severities[i-1]
+		}
+	}
+	return severity
 }
@@ -0,0 +1,120 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// broadGroups are built-in groups whose members are hard to enumerate
+var broadGroups = map[string]string{
+	"system:authenticated":   "every authenticated user",
+	"system:unauthenticated": "every anonymous request",
+	"system:serviceaccounts": "every ServiceAccount in the cluster",
+}
+
+// BroadGroup describes why binding a group is broad, or returns "" for
+// ordinary groups
+func BroadGroup(name string) string {
+	if why, ok := broadGroups[name]; ok {
+		return why
+	}
+	if ns, ok := strings.CutPrefix(name, "system:serviceaccounts:"); ok {
+		return "every ServiceAccount in namespace " + ns
+	}
+	return ""
+}
+
+// RoleUsage is a binding whose roleRef points at a role
+type RoleUsage struct {
+	Binding  BindingInfo
+	Subjects []rbacv1.Subject
+}
+
+// RoleUsageReport lists every binding of a role and what it reaches
+type RoleUsageReport struct {
+	Role   RoleInfo
+	Exists bool // False when the bindings are dangling
+	Usages []RoleUsage
+
+	// Namespaces are those reached through RoleBindings; ClusterWide is set
+	// when a ClusterRoleBinding grants the role in every namespace
+	Namespaces  []string
+	ClusterWide bool
+	// Subjects counts distinct subjects across all bindings
+	Subjects int
+}
+
+// RoleUsages finds every binding that refers to role. For a ClusterRole
+// that is ClusterRoleBindings and RoleBindings in all namespaces; for a Role,
+// RoleBindings in its namespace.
+func (r *Resolver) RoleUsages(ctx context.Context, role RoleInfo) (*RoleUsageReport, error) {
+	report := &RoleUsageReport{Role: role}
+
+	var err error
+	if role.Kind == "ClusterRole" {
+		var cr *rbacv1.ClusterRole
+		if cr, err = r.client.GetClusterRole(ctx, role.Name); err == nil {
+			report.Role.Owner = OwnerFromMeta(cr.ObjectMeta)
+		}
+	} else {
+		var rl *rbacv1.Role
+		if rl, err = r.client.GetRole(ctx, role.Namespace, role.Name); err == nil {
+			report.Role.Owner = OwnerFromMeta(rl.ObjectMeta)
+		}
+	}
+	report.Exists = err == nil
+
+	if role.Kind == "ClusterRole" {
+		crbs, err := r.client.ListClusterRoleBindings(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+		}
+		for _, crb := range crbs.Items {
+			if crb.RoleRef.Kind == "ClusterRole" && crb.RoleRef.Name == role.Name {
+				report.Usages = append(report.Usages, RoleUsage{
+					Binding:  BindingInfo{Kind: "ClusterRoleBinding", Name: crb.Name, Owner: OwnerFromMeta(crb.ObjectMeta)},
+					Subjects: crb.Subjects,
+				})
+				report.ClusterWide = true
+			}
+		}
+	}
+
+	rbs, err := r.client.ListRoleBindings(ctx, role.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	namespaces := make(map[string]bool)
+	for _, rb := range rbs.Items {
+		if rb.RoleRef.Kind != role.Kind || rb.RoleRef.Name != role.Name {
+			continue
+		}
+		report.Usages = append(report.Usages, RoleUsage{
+			Binding:  BindingInfo{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace, Owner: OwnerFromMeta(rb.ObjectMeta)},
+			Subjects: rb.Subjects,
+		})
+		if !namespaces[rb.Namespace] {
+			namespaces[rb.Namespace] = true
+			report.Namespaces = append(report.Namespaces, rb.Namespace)
+		}
+	}
+
+	sort.Strings(report.Namespaces)
+
+	subjects := make(map[string]bool)
+	for _, u := range report.Usages {
+		for _, s := range u.Subjects {
+			ns := s.Namespace
+			if s.Kind == "ServiceAccount" && ns == "" {
+				ns = u.Binding.Namespace
+			}
+			subjects[s.Kind+"/"+ns+"/"+s.Name] = true
+		}
+	}
+	report.Subjects = len(subjects)
+	return report, nil
+}
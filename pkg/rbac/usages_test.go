@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestRoleUsages(t *testing.T) {
+	mockClient := client.NewMockRBACClient()
+	mockClient.AddClusterRole(rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "pod-reader"}})
+	mockClient.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "everyone-reads"},
+		Subjects:   []rbacv1.Subject{{Kind: "Group", Name: "system:authenticated"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "pod-reader"},
+	})
+	for _, ns := range []string{"prod", "dev"} {
+		mockClient.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "read", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "ci"}, {Kind: "User", Name: "alice"}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "pod-reader"},
+		})
+	}
+	// Same name, different kind: must not be counted
+	mockClient.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "local", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "pod-reader"},
+	})
+
+	report, err := NewResolver(mockClient).RoleUsages(context.Background(), RoleInfo{Kind: "ClusterRole", Name: "pod-reader"})
+	if err != nil {
+		t.Fatalf("RoleUsages() error = %v", err)
+	}
+	if !report.Exists || !report.ClusterWide {
+		t.Errorf("Exists = %t, ClusterWide = %t, want both true", report.Exists, report.ClusterWide)
+	}
+	if len(report.Usages) != 3 {
+		t.Errorf("got %d usages, want 3", len(report.Usages))
+	}
+	if fmt.Sprint(report.Namespaces) != "[dev prod]" {
+		t.Errorf("Namespaces = %v, want [dev prod]", report.Namespaces)
+	}
+	// system:authenticated, alice, and a ci ServiceAccount in each namespace
+	if report.Subjects != 4 {
+		t.Errorf("Subjects = %d, want 4", report.Subjects)
+	}
+
+	report, err = NewResolver(mockClient).RoleUsages(context.Background(), RoleInfo{Kind: "Role", Name: "pod-reader", Namespace: "dev"})
+	if err != nil {
+		t.Fatalf("RoleUsages() error = %v", err)
+	}
+	if report.Exists || report.ClusterWide || len(report.Usages) != 1 {
+		t.Errorf("Role report = %+v, want one dangling usage", report)
+	}
+}
+
+func TestBroadGroup(t *testing.T) {
+	tests := []struct {
+		group string
+		broad bool
+	}{
+		{group: "system:authenticated", broad: true},
+		{group: "system:serviceaccounts", broad: true},
+		{group: "system:serviceaccounts:kube-system", broad: true},
+		{group: "developers", broad: false},
+	}
+	for _, tt := range tests {
+		if got := BroadGroup(tt.group) != ""; got != tt.broad {
+			t.Errorf("BroadGroup(%q) broad = %t, want %t", tt.group, got, tt.broad)
+		}
+	}
+}
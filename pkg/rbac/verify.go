@@ -0,0 +1,73 @@
+package rbac
+
+import (
+	"slices"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// Verification is the API server's decision for the same request, read from
+// a SubjectAccessReview
+type Verification struct {
+	Allowed bool
+	// Denied is set when an authorizer explicitly denied the request, rather
+	// than none of them allowing it
+	Denied          bool
+	Reason          string
+	EvaluationError string
+}
+
+// Agrees reports whether the API server reached the same decision as the
+// local resolution
+func (v *Verification) Agrees(allowed bool) bool {
+	return v.Allowed == allowed
+}
+
+// NewSubjectAccessReview builds the SubjectAccessReview that asks the API
+// server about request on behalf of subject. The review carries the
+// subject's effective groups, implicit ones included, since the API server
+// does not look them up itself.
+func NewSubjectAccessReview(subject Subject, request PermissionRequest) *authorizationv1.SubjectAccessReview {
+	groups := GetImplicitGroups(subject)
+	spec := authorizationv1.SubjectAccessReviewSpec{Groups: groups, UID: subject.UID}
+	for key, values := range subject.Extra {
+		if spec.Extra == nil {
+			spec.Extra = make(map[string]authorizationv1.ExtraValue, len(subject.Extra))
+		}
+		spec.Extra[key] = values
+	}
+	if subject.Kind == "Group" {
+		if !slices.Contains(groups, subject.Name) {
+			spec.Groups = append([]string{subject.Name}, groups...)
+		}
+	} else {
+		spec.User = subject.Canonical()
+	}
+
+	if request.NonResourceURL != "" {
+		spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{
+			Verb: request.Verb,
+			Path: request.NonResourceURL,
+		}
+	} else {
+		spec.ResourceAttributes = &authorizationv1.ResourceAttributes{
+			Namespace:   request.Namespace,
+			Verb:        request.Verb,
+			Group:       request.APIGroup,
+			Resource:    request.Resource,
+			Subresource: request.Subresource,
+			Name:        request.ResourceName,
+		}
+	}
+	return &authorizationv1.SubjectAccessReview{Spec: spec}
+}
+
+// VerificationFromStatus converts the status of a SubjectAccessReview
+func VerificationFromStatus(status authorizationv1.SubjectAccessReviewStatus) *Verification {
+	return &Verification{
+		Allowed:         status.Allowed,
+		Denied:          status.Denied,
+		Reason:          status.Reason,
+		EvaluationError: status.EvaluationError,
+	}
+}
@@ -0,0 +1,35 @@
+package rbac
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewSubjectAccessReview(t *testing.T) {
+	review := NewSubjectAccessReview(
+		Subject{Kind: "Group", Name: "platform"},
+		PermissionRequest{Verb: "get", NonResourceURL: "/metrics"},
+	)
+	spec := review.Spec
+	if spec.User != "" || !slices.Equal(spec.Groups, []string{"platform", "system:authenticated"}) {
+		t.Errorf("user = %q, groups = %v, want only the group and system:authenticated", spec.User, spec.Groups)
+	}
+	if spec.ResourceAttributes != nil || spec.NonResourceAttributes == nil || spec.NonResourceAttributes.Path != "/metrics" {
+		t.Errorf("spec = %+v, want non-resource attributes for /metrics", spec)
+	}
+
+	review = NewSubjectAccessReview(
+		Subject{Kind: "User", Name: "alice", Groups: []string{"dev"}, UID: "1234"},
+		PermissionRequest{Verb: "create", APIGroup: "apps", Resource: "deployments", Subresource: "scale", ResourceName: "api", Namespace: "prod"},
+	)
+	ra := review.Spec.ResourceAttributes
+	if review.Spec.User != "alice" || ra == nil || ra.Group != "apps" || ra.Subresource != "scale" || ra.Name != "api" || ra.Namespace != "prod" {
+		t.Errorf("spec = %+v, want alice's request for deployments/scale api in prod", review.Spec)
+	}
+	if !slices.Equal(review.Spec.Groups, []string{"dev", "system:authenticated"}) {
+		t.Errorf("groups = %v, want the explicit and implicit groups", review.Spec.Groups)
+	}
+	if review.Spec.UID != "1234" {
+		t.Errorf("uid = %q, want the subject's UID", review.Spec.UID)
+	}
+}
@@ -0,0 +1,170 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// SubjectGrants are all the paths through which one binding subject is
+// granted a request
+type SubjectGrants struct {
+	Subject rbacv1.Subject
+	Grants  []PermissionGrant
+}
+
+// String returns the subject as "Kind name", with the namespace for a
+// ServiceAccount
+func (s SubjectGrants) String() string {
+	if s.Subject.Kind == "ServiceAccount" {
+		return "ServiceAccount " + s.Subject.Namespace + "/" + s.Subject.Name
+	}
+	return s.Subject.Kind + " " + s.Subject.Name
+}
+
+// Restricted reports whether every grant is limited to resourceNames, so
+// that the subject can only reach the objects AllowedNames returns
+func (s SubjectGrants) Restricted() bool {
+	return len(s.Grants) > 0 && !slices.ContainsFunc(s.Grants, func(g PermissionGrant) bool { return !g.Conditional })
+}
+
+// AllowedNames returns the object names a Restricted subject is allowed
+// for, sorted
+func (s SubjectGrants) AllowedNames() []string {
+	return conditionalNames(s.Grants)
+}
+
+// WhoCan finds every subject named in a binding that grants request: all
+// ClusterRoleBindings, plus the RoleBindings in request.Namespace, or in all
+// namespaces with allNamespaces. Non-resource URLs and cluster-scoped
+// resources are only granted through ClusterRoleBindings. A subject bound
+// several times appears once with every path. Subjects are sorted by kind,
+// then namespace and name.
+func (r *Resolver) WhoCan(ctx context.Context, request PermissionRequest, allNamespaces bool) ([]SubjectGrants, error) {
+	subjects, _, err := r.whoCan(ctx, request, allNamespaces, false)
+	return subjects, err
+}
+
+// SkippedNamespace is a namespace left out of a lookup because its
+// RoleBindings couldn't be listed
+type SkippedNamespace struct {
+	Namespace string
+	Err       error
+}
+
+// WhoCanKeepGoing is WhoCan in all namespaces, except that when the
+// RoleBindings of every namespace can't be listed at once, they are listed
+// one namespace at a time. The namespaces that fail are skipped and
+// returned, and the subjects are those of the remaining namespaces.
+func (r *Resolver) WhoCanKeepGoing(ctx context.Context, request PermissionRequest) ([]SubjectGrants, []SkippedNamespace, error) {
+	return r.whoCan(ctx, request, true, true)
+}
+
+func (r *Resolver) whoCan(ctx context.Context, request PermissionRequest, allNamespaces, keepGoing bool) ([]SubjectGrants, []SkippedNamespace, error) {
+	bySubject := make(map[rbacv1.Subject]*SubjectGrants)
+	add := func(subjects []rbacv1.Subject, grant PermissionGrant) {
+		for _, s := range subjects {
+			key := rbacv1.Subject{Kind: s.Kind, Name: s.Name, Namespace: s.Namespace}
+			if s.Kind != "ServiceAccount" {
+				key.Namespace = ""
+			}
+			sg, ok := bySubject[key]
+			if !ok {
+				sg = &SubjectGrants{Subject: key}
+				bySubject[key] = sg
+			}
+			sg.Grants = append(sg.Grants, grant)
+		}
+	}
+
+	crbs, err := r.client.ListClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	for _, crb := range crbs.Items {
+		rules, role, err := r.boundRules(ctx, crb.RoleRef, "")
+		if err != nil {
+			continue
+		}
+		binding := BindingInfo{Kind: "ClusterRoleBinding", Name: crb.Name, Owner: OwnerFromMeta(crb.ObjectMeta)}
+		for i, rule := range rules {
+			if RuleMatches(rule, request) {
+				add(crb.Subjects, PermissionGrant{Binding: binding, Role: role, MatchingRule: rule, RuleIndex: i, Conditional: MatchesOnlyNamed(rule, request), Scope: ScopeClusterWide})
+			}
+		}
+	}
+
+	namespace := request.Namespace
+	if allNamespaces {
+		namespace = ""
+	}
+	// RoleBindings don't grant non-resource URLs or cluster-scoped resources
+	var skipped []SkippedNamespace
+	if (namespace != "" || allNamespaces) && request.NonResourceURL == "" && !r.clusterScoped(ctx, request) {
+		var rbs []rbacv1.RoleBinding
+		rbs, skipped, err = r.whoCanRoleBindings(ctx, namespace, keepGoing)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, rb := range rbs {
+			rules, role, err := r.boundRules(ctx, rb.RoleRef, rb.Namespace)
+			if err != nil {
+				continue
+			}
+			binding := BindingInfo{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace, Owner: OwnerFromMeta(rb.ObjectMeta)}
+			for i, rule := range rules {
+				if RuleMatches(rule, request) {
+					add(rb.Subjects, PermissionGrant{Binding: binding, Role: role, MatchingRule: rule, RuleIndex: i, Conditional: MatchesOnlyNamed(rule, request), Scope: ScopeNamespace, EffectiveNamespace: rb.Namespace})
+				}
+			}
+		}
+	}
+
+	result := make([]SubjectGrants, 0, len(bySubject))
+	for _, sg := range bySubject {
+		result = append(result, *sg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i].Subject, result[j].Subject
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return result, skipped, nil
+}
+
+// whoCanRoleBindings lists the RoleBindings in namespace. With keepGoing,
+// when those of all namespaces can't be listed at once, they are listed one
+// namespace at a time, skipping the namespaces that fail.
+func (r *Resolver) whoCanRoleBindings(ctx context.Context, namespace string, keepGoing bool) ([]rbacv1.RoleBinding, []SkippedNamespace, error) {
+	rbs, err := r.client.ListRoleBindings(ctx, namespace)
+	if err == nil {
+		return rbs.Items, nil, nil
+	}
+	if !keepGoing || namespace != "" || r.namespaces == nil {
+		return nil, nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	namespaces, nsErr := r.namespaces.ListNamespaces(ctx)
+	if nsErr != nil {
+		return nil, nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+
+	var items []rbacv1.RoleBinding
+	var skipped []SkippedNamespace
+	for _, ns := range namespaces.Items {
+		rbs, err := r.client.ListRoleBindings(ctx, ns.Name)
+		if err != nil {
+			skipped = append(skipped, SkippedNamespace{Namespace: ns.Name, Err: err})
+			continue
+		}
+		items = append(items, rbs.Items...)
+	}
+	return items, skipped, nil
+}
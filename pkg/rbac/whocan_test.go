@@ -0,0 +1,179 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/client"
+)
+
+func TestWhoCan(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list"}}},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}},
+	})
+	mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "auditors"},
+		Subjects: []rbacv1.Subject{
+			{Kind: "Group", Name: "auditors"},
+			{Kind: "User", Name: "alice"},
+		},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod-secrets", Namespace: "prod"},
+		Subjects: []rbacv1.Subject{
+			{Kind: "User", Name: "alice"},
+			{Kind: "ServiceAccount", Name: "api", Namespace: "prod"},
+		},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev-secrets", Namespace: "dev"},
+		Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "ci", Namespace: "dev"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+	})
+	mock.AddRoleBinding(rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod-pods", Namespace: "prod"},
+		Subjects:   []rbacv1.Subject{{Kind: "User", Name: "bob"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "pod-reader"},
+	})
+
+	tests := []struct {
+		name          string
+		namespace     string
+		allNamespaces bool
+		want          map[string]int // subject -> number of paths
+	}{
+		{
+			name:      "namespace",
+			namespace: "prod",
+			want:      map[string]int{"Group auditors": 1, "ServiceAccount prod/api": 1, "User alice": 2},
+		},
+		{
+			name: "cluster-wide only",
+			want: map[string]int{"Group auditors": 1, "User alice": 1},
+		},
+		{
+			name:          "all namespaces",
+			allNamespaces: true,
+			want:          map[string]int{"Group auditors": 1, "ServiceAccount dev/ci": 1, "ServiceAccount prod/api": 1, "User alice": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := PermissionRequest{Verb: "get", Resource: "secrets", Namespace: tt.namespace}
+			subjects, err := NewResolver(mock).WhoCan(context.Background(), request, tt.allNamespaces)
+			if err != nil {
+				t.Fatalf("WhoCan() error = %v", err)
+			}
+			got := make(map[string]int)
+			for _, sg := range subjects {
+				got[sg.String()] = len(sg.Grants)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("WhoCan() = %v, want %v", got, tt.want)
+			}
+			for s, n := range tt.want {
+				if got[s] != n {
+					t.Errorf("WhoCan()[%s] = %d path(s), want %d", s, got[s], n)
+				}
+			}
+			for i := 1; i < len(subjects); i++ {
+				if subjects[i-1].Subject.Kind > subjects[i].Subject.Kind {
+					t.Errorf("subjects not sorted by kind: %v", subjects)
+				}
+			}
+		})
+	}
+}
+
+func TestWhoCan_ResourceNames(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls-reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"tls"}, Verbs: []string{"get"}}},
+	})
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+	})
+	for name, role := range map[string]string{"alice": "tls-reader", "bob": "secret-reader"} {
+		mock.AddClusterRoleBinding(rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Subjects:   []rbacv1.Subject{{Kind: "User", Name: name}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: role},
+		})
+	}
+
+	subjects, err := NewResolver(mock).WhoCan(context.Background(), PermissionRequest{Verb: "get", Resource: "secrets"}, false)
+	if err != nil {
+		t.Fatalf("WhoCan() error = %v", err)
+	}
+	if len(subjects) != 2 {
+		t.Fatalf("WhoCan() = %v, want alice and bob", subjects)
+	}
+	for _, sg := range subjects {
+		restricted := sg.Subject.Name == "alice"
+		if sg.Restricted() != restricted || sg.Grants[0].Conditional != restricted {
+			t.Errorf("%s: Restricted() = %v, Conditional = %v, want %v", sg, sg.Restricted(), sg.Grants[0].Conditional, restricted)
+		}
+	}
+
+	// A request for the named object isn't restricted
+	subjects, err = NewResolver(mock).WhoCan(context.Background(), PermissionRequest{Verb: "get", Resource: "secrets", ResourceName: "tls"}, false)
+	if err != nil {
+		t.Fatalf("WhoCan() error = %v", err)
+	}
+	for _, sg := range subjects {
+		if sg.Restricted() {
+			t.Errorf("%s: Restricted() = true for a request naming tls", sg)
+		}
+	}
+}
+
+func TestWhoCanKeepGoing(t *testing.T) {
+	mock := client.NewMockRBACClient()
+	mock.AddClusterRole(rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "secret-reader"},
+		Rules:      []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+	})
+	for _, ns := range []string{"dev", "prod"} {
+		mock.Namespaces = append(mock.Namespaces, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		mock.AddRoleBinding(rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "secrets", Namespace: ns},
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: "api", Namespace: ns}},
+			RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", Name: "secret-reader"},
+		})
+	}
+	// RoleBindings can only be listed per namespace, and not at all in prod
+	denied := errors.New("forbidden")
+	mock.ListRoleBindingsErrors = map[string]error{"": denied, "prod": denied}
+	request := PermissionRequest{Verb: "get", Resource: "secrets"}
+
+	if _, err := NewResolver(mock).WhoCan(context.Background(), request, true); err == nil {
+		t.Error("WhoCan() succeeded, want the error listing every namespace's RoleBindings")
+	}
+
+	subjects, skipped, err := NewResolver(mock).WhoCanKeepGoing(context.Background(), request)
+	if err != nil {
+		t.Fatalf("WhoCanKeepGoing() error = %v", err)
+	}
+	if len(subjects) != 1 || subjects[0].String() != "ServiceAccount dev/api" {
+		t.Errorf("subjects = %v, want ServiceAccount dev/api", subjects)
+	}
+	if len(skipped) != 1 || skipped[0].Namespace != "prod" || !errors.Is(skipped[0].Err, denied) {
+		t.Errorf("skipped = %+v, want prod", skipped)
+	}
+}
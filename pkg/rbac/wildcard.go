@@ -0,0 +1,143 @@
+package rbac
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// WildcardGrant is a rule that grants a verb on every resource, either in all
+// API groups (a full wildcard) or in the groups it lists
+type WildcardGrant struct {
+	PermissionGrant
+	AllGroups bool
+}
+
+// IsWildcardResource reports whether a resource argument asks about all
+// resources rather than one named "*"
+func IsWildcardResource(resource string) bool {
+	return resource == rbacv1.ResourceAll
+}
+
+// WildcardGrants finds the rules bound to subject whose resources contain "*"
+// and whose verbs cover request.Verb. When request.APIGroup is set, only
+// rules covering that group are returned. Rules restricted to resourceNames
+// are skipped, since they do not grant every object.
+func (r *Resolver) WildcardGrants(ctx context.Context, subject Subject, request PermissionRequest) ([]WildcardGrant, error) {
+	grants, err := r.ResolveAllPermissions(ctx, subject, request.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var wildcards []WildcardGrant
+	for _, g := range grants {
+		rule := g.MatchingRule
+		if !slices.Contains(rule.Resources, rbacv1.ResourceAll) || len(rule.ResourceNames) > 0 {
+			continue
+		}
+		if !matchesVerb(rule.Verbs, request.Verb) {
+			continue
+		}
+		if request.APIGroup != "" && !matchesAPIGroup(rule.APIGroups, request.APIGroup) {
+			continue
+		}
+		wildcards = append(wildcards, WildcardGrant{
+			PermissionGrant: g,
+			AllGroups:       slices.Contains(rule.APIGroups, rbacv1.APIGroupAll),
+		})
+	}
+
+	// Full wildcards first: they are the ones worth acting on
+	slices.SortStableFunc(wildcards, func(a, b WildcardGrant) int {
+		switch {
+		case a.AllGroups == b.AllGroups:
+			return 0
+		case a.AllGroups:
+			return -1
+		}
+		return 1
+	})
+	return wildcards, nil
+}
+
+// Parts of a rule that can match a request through a wildcard
+const (
+	WildcardVerb           = "verb"
+	WildcardAPIGroup       = "apiGroup"
+	WildcardResource       = "resource"
+	WildcardNonResourceURL = "nonResourceURL"
+)
+
+// WildcardMatches returns the parts of rule, which must match request, that
+// cover it only through a wildcard such as "*" or "pods/*" rather than by
+// listing the requested value
+func WildcardMatches(rule rbacv1.PolicyRule, request PermissionRequest) []string {
+	var wildcards []string
+	if !slices.Contains(rule.Verbs, request.Verb) {
+		wildcards = append(wildcards, WildcardVerb)
+	}
+	if request.NonResourceURL != "" {
+		if !slices.Contains(rule.NonResourceURLs, request.NonResourceURL) {
+			wildcards = append(wildcards, WildcardNonResourceURL)
+		}
+		return wildcards
+	}
+	if !slices.Contains(rule.APIGroups, request.APIGroup) {
+		wildcards = append(wildcards, WildcardAPIGroup)
+	}
+	if !slices.Contains(rule.Resources, request.FullResource()) {
+		wildcards = append(wildcards, WildcardResource)
+	}
+	return wildcards
+}
+
+// RuleWildcards returns the parts of rule that contain a wildcard, for
+// analyses that look at rules without a specific request
+func RuleWildcards(rule rbacv1.PolicyRule) []string {
+	var wildcards []string
+	if slices.Contains(rule.Verbs, rbacv1.VerbAll) {
+		wildcards = append(wildcards, WildcardVerb)
+	}
+	if slices.Contains(rule.APIGroups, rbacv1.APIGroupAll) {
+		wildcards = append(wildcards, WildcardAPIGroup)
+	}
+	if slices.ContainsFunc(rule.Resources, hasWildcard) {
+		wildcards = append(wildcards, WildcardResource)
+	}
+	if slices.ContainsFunc(rule.NonResourceURLs, hasWildcard) {
+		wildcards = append(wildcards, WildcardNonResourceURL)
+	}
+	return wildcards
+}
+
+func hasWildcard(s string) bool {
+	return strings.Contains(s, "*")
+}
+
+// NarrowRule returns rule with each wildcard it matched request through
+// replaced by the requested value. Explicit entries are kept, so the result
+// grants no more than rule and still grants request.
+func NarrowRule(rule rbacv1.PolicyRule, request PermissionRequest) rbacv1.PolicyRule {
+	narrowed := *rule.DeepCopy()
+	for _, part := range WildcardMatches(rule, request) {
+		switch part {
+		case WildcardVerb:
+			narrowed.Verbs = narrowList(rule.Verbs, request.Verb)
+		case WildcardAPIGroup:
+			narrowed.APIGroups = narrowList(rule.APIGroups, request.APIGroup)
+		case WildcardResource:
+			narrowed.Resources = narrowList(rule.Resources, request.FullResource())
+		case WildcardNonResourceURL:
+			narrowed.NonResourceURLs = narrowList(rule.NonResourceURLs, request.NonResourceURL)
+		}
+	}
+	return narrowed
+}
+
+// narrowList drops the wildcard entries of list and adds value
+func narrowList(list []string, value string) []string {
+	narrowed := slices.DeleteFunc(slices.Clone(list), hasWildcard)
+	return append(narrowed, value)
+}
@@ -0,0 +1,62 @@
+package rbac
+
+import (
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestWildcardMatches(t *testing.T) {
+	getPods := PermissionRequest{Verb: "get", Resource: "pods", Subresource: "log"}
+	tests := []struct {
+		name         string
+		rule         rbacv1.PolicyRule
+		request      PermissionRequest
+		wantParts    []string
+		wantNarrowed rbacv1.PolicyRule
+	}{
+		{
+			name:         "explicit rule",
+			rule:         rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}},
+			request:      getPods,
+			wantNarrowed: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}},
+		},
+		{
+			name:         "full wildcard",
+			rule:         rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+			request:      getPods,
+			wantParts:    []string{WildcardVerb, WildcardAPIGroup, WildcardResource},
+			wantNarrowed: rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods/log"}},
+		},
+		{
+			name:         "subresource wildcard keeps explicit entries",
+			rule:         rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods/*", "services"}},
+			request:      getPods,
+			wantParts:    []string{WildcardResource},
+			wantNarrowed: rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"services", "pods/log"}},
+		},
+		{
+			name:         "non-resource URL prefix",
+			rule:         rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics*"}},
+			request:      PermissionRequest{Verb: "get", NonResourceURL: "/metrics/cadvisor"},
+			wantParts:    []string{WildcardNonResourceURL},
+			wantNarrowed: rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/metrics/cadvisor"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WildcardMatches(tt.rule, tt.request); !slices.Equal(got, tt.wantParts) {
+				t.Errorf("WildcardMatches() = %v, want %v", got, tt.wantParts)
+			}
+			narrowed := NarrowRule(tt.rule, tt.request)
+			if !slices.Equal(narrowed.Verbs, tt.wantNarrowed.Verbs) || !slices.Equal(narrowed.APIGroups, tt.wantNarrowed.APIGroups) ||
+				!slices.Equal(narrowed.Resources, tt.wantNarrowed.Resources) || !slices.Equal(narrowed.NonResourceURLs, tt.wantNarrowed.NonResourceURLs) {
+				t.Errorf("NarrowRule() = %+v, want %+v", narrowed, tt.wantNarrowed)
+			}
+			if !RuleMatches(narrowed, tt.request) {
+				t.Errorf("NarrowRule() = %+v no longer grants the request", narrowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Enabled reports whether an OTLP endpoint is configured in the environment
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup returns a TracerProvider that exports spans over OTLP/HTTP when an
+// OTLP endpoint is configured, and a no-op provider otherwise. The provider is
+// also installed globally. Call shutdown before exiting to flush spans.
+func Setup(ctx context.Context) (tp trace.TracerProvider, shutdown func(context.Context) error, err error) {
+	if !Enabled() {
+		return noop.NewTracerProvider(), func(context.Context) error { return nil }, nil
+	}
+
+	// Endpoint, headers, and TLS settings are read from the standard OTEL_* variables
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	sdkProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(sdkProvider)
+	return sdkProvider, sdkProvider.Shutdown, nil
+}
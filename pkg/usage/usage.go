@@ -0,0 +1,312 @@
+// Package usage reads API server audit logs to find which permissions a
+// subject actually exercises, and compares them with its effective grants.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+// event is the subset of an audit.k8s.io/v1 Event that usage analysis needs
+type event struct {
+	Stage string `json:"stage"`
+	Verb  string `json:"verb"`
+	User  struct {
+		Username string   `json:"username"`
+		Groups   []string `json:"groups"`
+	} `json:"user"`
+	ObjectRef *struct {
+		Resource    string `json:"resource"`
+		Subresource string `json:"subresource"`
+		Namespace   string `json:"namespace"`
+		APIGroup    string `json:"apiGroup"`
+	} `json:"objectRef"`
+	// RequestURI is the path of a non-resource request, which has no objectRef
+	RequestURI               string    `json:"requestURI"`
+	RequestReceivedTimestamp time.Time `json:"requestReceivedTimestamp"`
+}
+
+// Tuple is one kind of request a subject made, to a resource or to a
+// non-resource URL such as /healthz
+type Tuple struct {
+	Verb           string
+	APIGroup       string
+	Resource       string
+	Subresource    string
+	Namespace      string
+	NonResourceURL string
+}
+
+// Request converts the tuple into a permission request
+func (t Tuple) Request() rbac.PermissionRequest {
+	if t.NonResourceURL != "" {
+		return rbac.PermissionRequest{Verb: t.Verb, NonResourceURL: t.NonResourceURL}
+	}
+	return rbac.PermissionRequest{
+		Verb:        t.Verb,
+		APIGroup:    t.APIGroup,
+		Resource:    t.Resource,
+		Subresource: t.Subresource,
+		Namespace:   t.Namespace,
+	}
+}
+
+// Observed counts the requests a subject made over the log window
+type Observed struct {
+	Tuples map[Tuple]int
+	// Events is the number of matching events
+	Events int
+	// From and To bound the timestamps of all events read, not only matching ones
+	From, To time.Time
+	// ReadVerbsLogged is set when the log contains get, list, or watch events
+	// from anyone; audit policies often drop them
+	ReadVerbsLogged bool
+}
+
+// Load reads audit events from a file, or from every file in a directory, and
+// aggregates the requests made by subject. Each line must be a JSON audit
+// event; lines that are not are skipped. Only ResponseComplete events are
+// counted, so requests logged at several stages count once.
+func Load(path string, subject rbac.Subject) (*Observed, error) {
+	files := []string{path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log directory: %w", err)
+		}
+		files = nil
+		for _, e := range entries {
+			if !e.IsDir() {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	observed := &Observed{Tuples: make(map[Tuple]int)}
+	for _, file := range files {
+		if err := observed.readFile(file, subject); err != nil {
+			return nil, err
+		}
+	}
+	return observed, nil
+}
+
+func (o *Observed) readFile(file string, subject rbac.Subject) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Verb == "" {
+			continue
+		}
+		if e.Stage != "" && e.Stage != "ResponseComplete" {
+			continue
+		}
+		o.observe(e, subject)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return nil
+}
+
+func (o *Observed) observe(e event, subject rbac.Subject) {
+	if ts := e.RequestReceivedTimestamp; !ts.IsZero() {
+		if o.From.IsZero() || ts.Before(o.From) {
+			o.From = ts
+		}
+		if ts.After(o.To) {
+			o.To = ts
+		}
+	}
+	if isReadVerb(e.Verb) {
+		o.ReadVerbsLogged = true
+	}
+	if !eventMatchesSubject(e, subject) {
+		return
+	}
+	if e.ObjectRef == nil {
+		path, _, _ := strings.Cut(e.RequestURI, "?")
+		if path == "" {
+			return
+		}
+		o.Events++
+		o.Tuples[Tuple{Verb: e.Verb, NonResourceURL: path}]++
+		return
+	}
+	o.Events++
+	o.Tuples[Tuple{
+		Verb:        e.Verb,
+		APIGroup:    e.ObjectRef.APIGroup,
+		Resource:    e.ObjectRef.Resource,
+		Subresource: e.ObjectRef.Subresource,
+		Namespace:   e.ObjectRef.Namespace,
+	}]++
+}
+
+func eventMatchesSubject(e event, subject rbac.Subject) bool {
+	if subject.Kind == "Group" {
+		for _, g := range e.User.Groups {
+			if g == subject.Name {
+				return true
+			}
+		}
+		return false
+	}
+	return e.User.Username == subject.Canonical()
+}
+
+func isReadVerb(verb string) bool {
+	return verb == "get" || verb == "list" || verb == "watch"
+}
+
+// GrantUsage is a grant and how often the observed requests exercised it
+type GrantUsage struct {
+	rbac.PermissionGrant
+	Requests int
+	// UnusedVerbs are verbs the rule lists that no observed request used
+	UnusedVerbs []string
+	// Caveat explains why an unused grant may still be needed
+	Caveat string
+}
+
+// Compare matches observed requests against grants. A request exercises a
+// grant when the rule matches it and, for RoleBindings, the request was made
+// in the binding's namespace. Grants are returned unused first.
+func Compare(grants []rbac.PermissionGrant, observed *Observed) []GrantUsage {
+	var usages []GrantUsage
+	for _, g := range grants {
+		u := GrantUsage{PermissionGrant: g}
+		usedVerbs := make(map[string]bool)
+		for t, count := range observed.Tuples {
+			if g.Scope == rbac.ScopeNamespace && t.Namespace != g.Binding.Namespace {
+				continue
+			}
+			if rbac.RuleMatches(g.MatchingRule, t.Request()) {
+				u.Requests += count
+				usedVerbs[t.Verb] = true
+			}
+		}
+		for _, v := range g.MatchingRule.Verbs {
+			if v != rbacv1.VerbAll && !usedVerbs[v] {
+				u.UnusedVerbs = append(u.UnusedVerbs, v)
+			}
+		}
+		if u.Requests == 0 {
+			u.UnusedVerbs = nil
+			u.Caveat = caveat(g.MatchingRule, observed)
+		}
+		usages = append(usages, u)
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		return usages[i].Requests == 0 && usages[j].Requests > 0
+	})
+	return usages
+}
+
+// caveat explains why an unexercised rule may still be in use
+func caveat(rule rbacv1.PolicyRule, observed *Observed) string {
+	reads := false
+	for _, v := range rule.Verbs {
+		if isReadVerb(v) || v == rbacv1.VerbAll {
+			reads = true
+		}
+	}
+	switch {
+	case reads && !observed.ReadVerbsLogged:
+		return "the log has no get/list/watch events at all; the audit policy probably drops them"
+	case reads:
+		return "controllers list and watch once at startup and hold the watch open; a restart outside the log window would not show up"
+	}
+	return ""
+}
+
+// Propose builds the rules that cover exactly the observed requests: the
+// rule rbac.SuggestRule grants each request with, merged into one rule per
+// API group and resource, or per non-resource URL, with the verbs used on it
+func Propose(observed *Observed) []rbacv1.PolicyRule {
+	type key struct{ group, resource, url string }
+	rules := make(map[key]*rbacv1.PolicyRule)
+	for t := range observed.Tuples {
+		rule := rbac.SuggestRule(t.Request())
+		k := key{strings.Join(rule.APIGroups, ","), strings.Join(rule.Resources, ","), strings.Join(rule.NonResourceURLs, ",")}
+		if merged, ok := rules[k]; ok {
+			merged.Verbs = append(merged.Verbs, rule.Verbs...)
+			continue
+		}
+		rules[k] = &rule
+	}
+
+	var keys []key
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	// Resource rules first, then non-resource URLs
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].url != keys[j].url {
+			return keys[i].url < keys[j].url
+		}
+		if keys[i].group != keys[j].group {
+			return keys[i].group < keys[j].group
+		}
+		return keys[i].resource < keys[j].resource
+	})
+
+	var proposed []rbacv1.PolicyRule
+	for _, k := range keys {
+		rule := rules[k]
+		sort.Strings(rule.Verbs)
+		rule.Verbs = slices.Compact(rule.Verbs)
+		proposed = append(proposed, *rule)
+	}
+	return proposed
+}
+
+// Namespaces lists the namespaces the observed requests were made in, with ""
+// for cluster-scoped requests
+func (o *Observed) Namespaces() []string {
+	seen := make(map[string]bool)
+	var namespaces []string
+	for t := range o.Tuples {
+		if !seen[t.Namespace] {
+			seen[t.Namespace] = true
+			namespaces = append(namespaces, t.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// String formats a tuple as "verb resource in namespace", or "verb /url"
+func (t Tuple) String() string {
+	if t.NonResourceURL != "" {
+		return t.Verb + " " + t.NonResourceURL
+	}
+	var b strings.Builder
+	b.WriteString(t.Verb + " " + rbac.ResourceArg(t.Request()))
+	if t.Namespace != "" {
+		b.WriteString(" in " + t.Namespace)
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package usage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/hardik/kubectl-rbac-why/pkg/rbac"
+)
+
+const auditLog = `{"stage":"RequestReceived","verb":"get","user":{"username":"system:serviceaccount:ci:deployer"},"objectRef":{"resource":"deployments","namespace":"ci","apiGroup":"apps"},"requestReceivedTimestamp":"2026-10-01T10:00:00Z"}
+{"stage":"ResponseComplete","verb":"get","user":{"username":"system:serviceaccount:ci:deployer"},"objectRef":{"resource":"deployments","namespace":"ci","apiGroup":"apps"},"requestReceivedTimestamp":"2026-10-01T10:00:00Z"}
+{"stage":"ResponseComplete","verb":"patch","user":{"username":"system:serviceaccount:ci:deployer"},"objectRef":{"resource":"deployments","namespace":"ci","apiGroup":"apps"},"requestReceivedTimestamp":"2026-10-01T10:05:00Z"}
+{"stage":"ResponseComplete","verb":"list","user":{"username":"alice"},"objectRef":{"resource":"pods","namespace":"ci"},"requestReceivedTimestamp":"2026-10-02T09:00:00Z"}
+not json
+{"stage":"ResponseComplete","verb":"get","user":{"username":"system:serviceaccount:ci:deployer"},"requestURI":"/healthz?verbose","requestReceivedTimestamp":"2026-10-01T10:06:00Z"}
+`
+
+func TestUsage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "audit.log"), []byte(auditLog), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	subject := rbac.Subject{Kind: "ServiceAccount", Namespace: "ci", Name: "deployer"}
+
+	observed, err := Load(dir, subject)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if observed.Events != 3 || len(observed.Tuples) != 3 {
+		t.Errorf("Events = %d, Tuples = %v, want 3 ResponseComplete events", observed.Events, observed.Tuples)
+	}
+	if observed.Tuples[Tuple{Verb: "get", NonResourceURL: "/healthz"}] != 1 {
+		t.Errorf("Tuples = %v, want get /healthz without its query", observed.Tuples)
+	}
+	if !observed.ReadVerbsLogged {
+		t.Error("ReadVerbsLogged = false, want true")
+	}
+	if got := observed.To.Sub(observed.From).Hours(); got != 23 {
+		t.Errorf("window = %vh, want 23h across all events", got)
+	}
+
+	binding := rbac.BindingInfo{Kind: "RoleBinding", Name: "deployer", Namespace: "ci"}
+	grants := []rbac.PermissionGrant{
+		{Binding: binding, Scope: rbac.ScopeNamespace, MatchingRule: rbacv1.PolicyRule{Verbs: []string{"get", "patch", "delete"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}},
+		{Binding: binding, Scope: rbac.ScopeNamespace, MatchingRule: rbacv1.PolicyRule{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"secrets"}}},
+		{Binding: rbac.BindingInfo{Kind: "RoleBinding", Name: "deployer", Namespace: "prod"}, Scope: rbac.ScopeNamespace, MatchingRule: rbacv1.PolicyRule{Verbs: []string{"patch"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}},
+	}
+	usages := Compare(grants, observed)
+
+	var got []string
+	for _, u := range usages {
+		got = append(got, fmt.Sprintf("%s/%s=%d%v", u.Binding.Namespace, strings.Join(u.MatchingRule.Resources, ","), u.Requests, u.UnusedVerbs))
+	}
+	want := "[ci/secrets=0[] prod/deployments=0[] ci/deployments=2[delete]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Compare() = %v, want %s", got, want)
+	}
+	if usages[0].Caveat == "" {
+		t.Error("unused list rule should carry the informer caveat")
+	}
+
+	rules := Propose(observed)
+	if len(rules) != 2 || fmt.Sprint(rules[0].Verbs) != "[get patch]" || rules[0].Resources[0] != "deployments" ||
+		fmt.Sprint(rules[1].Verbs, rules[1].NonResourceURLs, rules[1].Resources) != "[get] [/healthz] []" {
+		t.Errorf("Propose() = %+v, want get, patch deployments and get /healthz", rules)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -90,6 +91,27 @@ func runRbacWhy(args ...string) (string, error) {
 	return stdout.String(), nil
 }
 
+// runRbacWhyExitCode runs the binary and returns its stdout and exit code,
+// which is part of the command's contract
+func runRbacWhyExitCode(args ...string) (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, binaryPath, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return stdout.String(), 0, nil
+}
+
 func TestCanI_ServiceAccountGetSecrets(t *testing.T) {
 	out, err := runRbacWhy(
 		"can-i",
@@ -118,7 +140,7 @@ func TestCanI_ServiceAccountGetSecrets(t *testing.T) {
 }
 
 func TestCanI_ServiceAccountDenied(t *testing.T) {
-	out, err := runRbacWhy(
+	out, code, err := runRbacWhyExitCode(
 		"can-i",
 		"--as", "system:serviceaccount:test-ns:test-sa",
 		"delete", "secrets",
@@ -132,6 +154,44 @@ func TestCanI_ServiceAccountDenied(t *testing.T) {
 	if !strings.Contains(out, "DENIED") {
 		t.Errorf("expected DENIED, got: %s", out)
 	}
+	// Like kubectl auth can-i, a denied check exits 1
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestCanI_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "allowed", args: []string{"get", "secrets"}, want: 0},
+		{name: "denied", args: []string{"delete", "secrets"}, want: 1},
+		{name: "denied json", args: []string{"delete", "secrets", "-o", "json"}, want: 1},
+		{name: "denied with --no-exit-code", args: []string{"delete", "secrets", "--no-exit-code"}, want: 0},
+		{name: "evaluation error", args: []string{"get", "secrets", "--context", "no-such-context"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"can-i", "--as", "system:serviceaccount:test-ns:test-sa", "-n", testNS}, tt.args...)
+			out, code, err := runRbacWhyExitCode(args...)
+			if err != nil {
+				t.Fatalf("command failed: %v", err)
+			}
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d", code, tt.want)
+			}
+			// JSON is written in full before the command exits
+			if strings.Contains(tt.name, "json") {
+				var result output.JSONOutput
+				if err := json.Unmarshal([]byte(out), &result); err != nil {
+					t.Errorf("invalid JSON output: %v\n%s", err, out)
+				}
+			}
+		})
+	}
 }
 
 func TestCanI_ClusterRoleBinding(t *testing.T) {